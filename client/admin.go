@@ -0,0 +1,284 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// CreateWebhook subscribes a URL to task events. The signing secret is only
+// returned here.
+func (c *Client) CreateWebhook(ctx context.Context, req CreateWebhookRequest) (*CreatedWebhook, error) {
+	var hook CreatedWebhook
+	if err := c.call(ctx, request{method: http.MethodPost, path: "/webhooks", body: req}, &hook); err != nil {
+		return nil, err
+	}
+	return &hook, nil
+}
+
+// ListWebhooks returns the webhooks of the workspace
+func (c *Client) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	var hooks []Webhook
+	if err := c.call(ctx, request{method: http.MethodGet, path: "/webhooks"}, &hooks); err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+// DeleteWebhook removes a webhook
+func (c *Client) DeleteWebhook(ctx context.Context, id int64) error {
+	return c.call(ctx, request{method: http.MethodDelete, path: webhookPath(id)}, nil)
+}
+
+// ListDeliveries returns the recent delivery attempts of a webhook
+func (c *Client) ListDeliveries(ctx context.Context, id int64) ([]WebhookDelivery, error) {
+	var deliveries []WebhookDelivery
+	err := c.call(ctx, request{method: http.MethodGet, path: webhookPath(id) + "/deliveries"}, &deliveries)
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// CreateAPIKey creates an API key; the secret is only returned here.
+// It needs the admin key.
+func (c *Client) CreateAPIKey(ctx context.Context, req CreateAPIKeyRequest) (*CreatedAPIKey, error) {
+	var key CreatedAPIKey
+	if err := c.call(ctx, request{method: http.MethodPost, path: "/apikeys", body: req, cred: adminKey}, &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// ListAPIKeys returns every API key, with the prefix of its secret. It
+// needs the admin key.
+func (c *Client) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	var keys []APIKey
+	if err := c.call(ctx, request{method: http.MethodGet, path: "/admin/apikeys", cred: adminKey}, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// RotateAPIKey gives a key a new secret, returned only here; the old one
+// stops working at once. It needs the admin key.
+func (c *Client) RotateAPIKey(ctx context.Context, id int64) (*CreatedAPIKey, error) {
+	var key CreatedAPIKey
+	path := "/admin/apikeys/" + strconv.FormatInt(id, 10) + "/rotate"
+	if err := c.call(ctx, request{method: http.MethodPost, path: path, cred: adminKey, once: true}, &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// CreateWorkspace creates a workspace. It needs the admin key, as do the
+// other workspace methods.
+func (c *Client) CreateWorkspace(ctx context.Context, req CreateWorkspaceRequest) (*Workspace, error) {
+	var ws Workspace
+	err := c.call(ctx, request{method: http.MethodPost, path: "/workspaces", body: req, cred: adminKey}, &ws)
+	if err != nil {
+		return nil, err
+	}
+	return &ws, nil
+}
+
+// ListWorkspaces returns every workspace
+func (c *Client) ListWorkspaces(ctx context.Context) ([]Workspace, error) {
+	var list []Workspace
+	if err := c.call(ctx, request{method: http.MethodGet, path: "/workspaces", cred: adminKey}, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// GetWorkspace returns a workspace
+func (c *Client) GetWorkspace(ctx context.Context, id string) (*Workspace, error) {
+	var ws Workspace
+	err := c.call(ctx, request{method: http.MethodGet, path: workspacePath(id), cred: adminKey}, &ws)
+	if err != nil {
+		return nil, err
+	}
+	return &ws, nil
+}
+
+// UpdateWorkspace renames a workspace
+func (c *Client) UpdateWorkspace(ctx context.Context, id string, req UpdateWorkspaceRequest) (*Workspace, error) {
+	var ws Workspace
+	err := c.call(ctx, request{method: http.MethodPut, path: workspacePath(id), body: req, cred: adminKey}, &ws)
+	if err != nil {
+		return nil, err
+	}
+	return &ws, nil
+}
+
+// DeleteWorkspace deletes an empty workspace
+func (c *Client) DeleteWorkspace(ctx context.Context, id string) error {
+	return c.call(ctx, request{method: http.MethodDelete, path: workspacePath(id), cred: adminKey}, nil)
+}
+
+// AuditQuery filters the audit log; zero fields match everything
+type AuditQuery struct {
+	Actor     string
+	Workspace string
+	Method    string
+	Route     string
+
+	// Result is "success" or "failure"
+	Result string
+
+	Since time.Time
+	Until time.Time
+
+	// Limit defaults to 100 on the server, at most 1000
+	Limit int
+}
+
+// Audit returns audit log entries, newest first. It needs the admin key.
+func (c *Client) Audit(ctx context.Context, q AuditQuery) ([]AuditEntry, error) {
+	query := url.Values{}
+	for name, v := range map[string]string{
+		"actor": q.Actor, "workspace": q.Workspace, "method": q.Method,
+		"route": q.Route, "result": q.Result,
+	} {
+		if v != "" {
+			query.Set(name, v)
+		}
+	}
+	if !q.Since.IsZero() {
+		query.Set("since", q.Since.Format(time.RFC3339))
+	}
+	if !q.Until.IsZero() {
+		query.Set("until", q.Until.Format(time.RFC3339))
+	}
+	if q.Limit > 0 {
+		query.Set("limit", strconv.Itoa(q.Limit))
+	}
+
+	var entries []AuditEntry
+	err := c.call(ctx, request{method: http.MethodGet, path: "/audit", query: query, cred: adminKey}, &entries)
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// SlowReport ranks routes by latency budget breaches over the last hours,
+// or the server's default window when hours is zero. It needs the admin
+// key.
+func (c *Client) SlowReport(ctx context.Context, hours int) (*SlowReport, error) {
+	query := url.Values{}
+	if hours > 0 {
+		query.Set("hours", strconv.Itoa(hours))
+	}
+	var report SlowReport
+	err := c.call(ctx, request{method: http.MethodGet, path: "/admin/slow-report", query: query, cred: adminKey}, &report)
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// Jobs returns the state of the background jobs. It needs the admin key,
+// as do AbortJob and RequeueJob.
+func (c *Client) Jobs(ctx context.Context) ([]JobStatus, error) {
+	var jobs []JobStatus
+	if err := c.call(ctx, request{method: http.MethodGet, path: "/admin/jobs", cred: adminKey}, &jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// AbortJob cancels the current run of a job
+func (c *Client) AbortJob(ctx context.Context, name string) (*JobStatus, error) {
+	return c.jobAction(ctx, name, "abort")
+}
+
+// RequeueJob runs a job again, aborting it first if it is stuck
+func (c *Client) RequeueJob(ctx context.Context, name string) (*JobStatus, error) {
+	return c.jobAction(ctx, name, "requeue")
+}
+
+func (c *Client) jobAction(ctx context.Context, name, action string) (*JobStatus, error) {
+	var status JobStatus
+	path := "/admin/jobs/" + url.PathEscape(name) + "/" + action
+	if err := c.call(ctx, request{method: http.MethodPost, path: path, cred: adminKey}, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// StorageStats counts what the server stores. It needs the admin key, as
+// do Compact, Maintenance, SetMaintenance and Diagnostics.
+func (c *Client) StorageStats(ctx context.Context) (*StorageStats, error) {
+	var stats StorageStats
+	if err := c.call(ctx, request{method: http.MethodGet, path: "/admin/stats", cred: adminKey}, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// Compact reclaims storage left by deleted records and drops dangling
+// links
+func (c *Client) Compact(ctx context.Context) (*CompactResult, error) {
+	var result CompactResult
+	if err := c.call(ctx, request{method: http.MethodPost, path: "/admin/compact", cred: adminKey}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Maintenance reports whether maintenance mode is on
+func (c *Client) Maintenance(ctx context.Context) (*MaintenanceState, error) {
+	var state MaintenanceState
+	if err := c.call(ctx, request{method: http.MethodGet, path: "/admin/maintenance", cred: adminKey}, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// SetMaintenance turns maintenance mode on, with a message for clients,
+// or off. While it is on, task writes fail with ErrMaintenance.
+func (c *Client) SetMaintenance(ctx context.Context, enabled bool, message string) (*MaintenanceState, error) {
+	body := MaintenanceRequest{Enabled: enabled, Message: message}
+	var state MaintenanceState
+	err := c.call(ctx, request{method: http.MethodPut, path: "/admin/maintenance", body: body, cred: adminKey}, &state)
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// Diagnostics returns the server's build, runtime, maintenance, storage
+// and job state in one report
+func (c *Client) Diagnostics(ctx context.Context) (*Diagnostics, error) {
+	var report Diagnostics
+	if err := c.call(ctx, request{method: http.MethodGet, path: "/admin/diagnostics", cred: adminKey}, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// Seed replaces every task, workspace, API key and webhook with fx, or,
+// when fx is nil, reloads the fixtures loaded last. Only servers in
+// development mode serve it; it needs the admin key when auth is enabled.
+func (c *Client) Seed(ctx context.Context, fx *Fixtures) (*SeedResult, error) {
+	req := request{method: http.MethodPost, path: "/admin/seed", cred: adminKey}
+	if fx != nil {
+		req.body = fx
+	}
+	var result SeedResult
+	if err := c.call(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func webhookPath(id int64) string {
+	return "/webhooks/" + strconv.FormatInt(id, 10)
+}
+
+func workspacePath(id string) string {
+	return "/workspaces/" + url.PathEscape(id)
+}
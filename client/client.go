@@ -0,0 +1,317 @@
+// Package client is the Go client for the task API. It covers every HTTP
+// endpoint with typed methods, retries requests the server rejected before
+// handling them, and maps error responses onto *APIError values that can be
+// matched with errors.Is against the Err* sentinels.
+//
+//	c, err := client.New("https://tasks.example.com", client.WithAPIKey(key))
+//	task, err := c.CreateTask(ctx, client.CreateTaskRequest{Title: "Write docs"})
+//	for task, err := range c.Tasks(ctx, client.TaskQuery{}) { ... }
+//
+// Watch streams task events from the WebSocket API at /ws; it does not
+// send mutations over the socket, which the HTTP methods already cover.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client calls the task API. It is safe for concurrent use.
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	apiKey     string
+	adminKey   string
+	workspace  string
+	userAgent  string
+	retry      RetryPolicy
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// RetryPolicy controls how failed requests are retried. Delays grow
+// exponentially from BaseDelay up to MaxDelay with full jitter; a
+// Retry-After header from the server takes precedence.
+type RetryPolicy struct {
+	// MaxAttempts includes the first attempt; 1 disables retries
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy makes up to three attempts
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: 200 * time.Millisecond, MaxDelay: 5 * time.Second}
+
+// WithHTTPClient sends requests with hc instead of http.DefaultClient
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithAPIKey authenticates task API requests with an API key or JWT
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// WithAdminKey authenticates the admin endpoints: API keys, workspaces,
+// the audit log and /admin
+func WithAdminKey(key string) Option {
+	return func(c *Client) {
+		c.adminKey = key
+	}
+}
+
+// WithWorkspace scopes task requests to a workspace
+func WithWorkspace(id string) Option {
+	return func(c *Client) {
+		c.workspace = id
+	}
+}
+
+// WithUserAgent sets the User-Agent header
+func WithUserAgent(ua string) Option {
+	return func(c *Client) {
+		c.userAgent = ua
+	}
+}
+
+// WithRetry replaces DefaultRetryPolicy
+func WithRetry(p RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = p
+	}
+}
+
+// New creates a client for the API at baseURL, such as
+// "http://localhost:8080"
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("client: invalid base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("client: base URL %q must be http or https", baseURL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+
+	c := &Client{
+		baseURL:    u,
+		httpClient: http.DefaultClient,
+		userAgent:  "cert-tasks-go",
+		retry:      DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.retry.MaxAttempts < 1 {
+		c.retry.MaxAttempts = 1
+	}
+	return c, nil
+}
+
+// credential selects the key a request is sent with
+type credential int
+
+const (
+	apiKey credential = iota
+	adminKey
+	noKey
+)
+
+// request describes one API call
+type request struct {
+	method string
+	path   string // escaped
+	query  url.Values
+	cred   credential
+
+	// once disables retries, for requests whose effect is single-use
+	once bool
+
+	// ifMatch is sent as If-Match, making a write conditional on an ETag
+	ifMatch string
+
+	// body is JSON-encoded when set; raw is sent as is, with contentType
+	body        any
+	raw         io.Reader
+	contentType string
+}
+
+// do sends req, retrying when allowed, and returns the successful
+// response; the caller closes its body. Error responses become *APIError.
+func (c *Client) do(ctx context.Context, req request) (*http.Response, error) {
+	var payload []byte
+	if req.body != nil {
+		var err error
+		if payload, err = json.Marshal(req.body); err != nil {
+			return nil, fmt.Errorf("client: encoding request: %w", err)
+		}
+	}
+
+	attempts := c.retry.MaxAttempts
+	if req.raw != nil || req.once {
+		attempts = 1 // streams cannot be replayed; single-use requests not repeated
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := c.send(ctx, req, payload)
+		if err == nil && resp.StatusCode < 400 {
+			return resp, nil
+		}
+
+		var wait time.Duration
+		retry := attempt < attempts
+		if err != nil {
+			retry = retry && idempotent(req.method) && ctx.Err() == nil
+		} else {
+			wait = retryAfter(resp.Header)
+			apiErr := decodeError(resp)
+			resp.Body.Close()
+			err = apiErr
+			// Maintenance lasts longer than any retry would wait
+			retry = retry && retryable(req.method, resp.StatusCode) && !errors.Is(apiErr, ErrMaintenance)
+		}
+		if !retry {
+			return nil, err
+		}
+
+		if wait == 0 {
+			wait = c.backoff(attempt)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// send makes one attempt
+func (c *Client) send(ctx context.Context, req request, payload []byte) (*http.Response, error) {
+	u, err := url.Parse(c.baseURL.String() + req.path)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = req.query.Encode()
+
+	var body io.Reader
+	switch {
+	case req.raw != nil:
+		body = req.raw
+	case payload != nil:
+		body = bytes.NewReader(payload)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case req.raw != nil:
+		httpReq.Header.Set("Content-Type", req.contentType)
+	case payload != nil:
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	httpReq.Header.Set("User-Agent", c.userAgent)
+	if req.ifMatch != "" {
+		httpReq.Header.Set("If-Match", req.ifMatch)
+	}
+	if c.workspace != "" && req.cred == apiKey {
+		httpReq.Header.Set("X-Workspace-ID", c.workspace)
+	}
+	key := c.apiKey
+	if req.cred == adminKey {
+		key = c.adminKey
+	}
+	if key != "" && req.cred != noKey {
+		httpReq.Header.Set("Authorization", "Bearer "+key)
+	}
+
+	return c.httpClient.Do(httpReq)
+}
+
+// call sends req and decodes the JSON response into out, unless out is nil
+func (c *Client) call(ctx context.Context, req request, out any) error {
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	return decodeJSON(resp, out)
+}
+
+// decodeJSON decodes a successful response body into out
+func decodeJSON(resp *http.Response, out any) error {
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("client: decoding %s %s response: %w", resp.Request.Method, resp.Request.URL.Path, err)
+	}
+	return nil
+}
+
+// idempotent reports whether a request can be repeated after a network
+// error without risking a duplicate effect
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryable reports whether a response status is worth retrying. Rate
+// limiting and draining reject requests before they are handled, so those
+// are retried for every method.
+func retryable(method string, status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return idempotent(method)
+	}
+	return false
+}
+
+// maxRetryAfter caps the wait a server can ask for
+const maxRetryAfter = time.Minute
+
+// retryAfter reads a Retry-After header given in seconds
+func retryAfter(h http.Header) time.Duration {
+	seconds, err := strconv.Atoi(h.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return min(time.Duration(seconds)*time.Second, maxRetryAfter)
+}
+
+// backoff returns the jittered delay before retrying after attempt
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.retry.BaseDelay << (attempt - 1)
+	if d <= 0 || d > c.retry.MaxDelay {
+		d = c.retry.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return rand.N(d) + 1
+}
@@ -0,0 +1,414 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/client"
+	"github.com/light-bringer/cert-tasks/tasktest"
+)
+
+func TestClient_Tasks(t *testing.T) {
+	ctx := context.Background()
+	srv := tasktest.NewServer(t, tasktest.WithAuth())
+	admin := srv.Client()
+
+	if _, err := admin.CreateWorkspace(ctx, client.CreateWorkspaceRequest{ID: "acme", Name: "Acme"}); err != nil {
+		t.Fatal(err)
+	}
+	key, err := admin.CreateAPIKey(ctx, client.CreateAPIKeyRequest{Name: "sdk", Scope: client.ScopeReadWrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := srv.Client(client.WithAPIKey(key.Key), client.WithWorkspace("acme"))
+
+	var ids []int64
+	for _, title := range []string{"one", "two", "three"} {
+		task, err := c.CreateTask(ctx, client.CreateTaskRequest{Title: title, Estimate: len(title)})
+		if err != nil {
+			t.Fatalf("CreateTask(%q): %v", title, err)
+		}
+		if task.WorkspaceID != "acme" {
+			t.Errorf("workspace = %q, want acme", task.WorkspaceID)
+		}
+		ids = append(ids, task.ID)
+	}
+
+	task, err := c.UpdateTask(ctx, ids[0], client.UpdateTaskRequest{Title: "one", Status: client.StatusDone})
+	if err != nil || task.Status != client.StatusDone {
+		t.Fatalf("UpdateTask = %+v, %v", task, err)
+	}
+	if _, err := c.CreateLink(ctx, ids[1], client.CreateLinkRequest{Type: client.LinkRelatesTo, TaskID: ids[0]}); err != nil {
+		t.Fatalf("CreateLink: %v", err)
+	}
+
+	t.Run("revisions", func(t *testing.T) {
+		revisions, err := c.ListRevisions(ctx, ids[0])
+		if err != nil || len(revisions) != 1 || revisions[0].Number != 1 || revisions[0].Status != client.StatusTodo {
+			t.Fatalf("ListRevisions = %+v, %v", revisions, err)
+		}
+		task, err := c.RestoreRevision(ctx, ids[0], 1)
+		if err != nil || task.Status != client.StatusTodo {
+			t.Fatalf("RestoreRevision = %+v, %v", task, err)
+		}
+		if _, err := c.UpdateTask(ctx, ids[0], client.UpdateTaskRequest{Title: "one", Status: client.StatusDone}); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("pages", func(t *testing.T) {
+		var got []int64
+		for task, err := range c.Tasks(ctx, client.TaskQuery{Limit: 2}) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, task.ID)
+		}
+		if len(got) != 3 || got[0] != ids[0] || got[2] != ids[2] {
+			t.Errorf("Tasks = %v, want %v", got, ids)
+		}
+
+		page, err := c.ListTasks(ctx, client.TaskQuery{Limit: 2})
+		if err != nil || len(page.Tasks) != 2 || page.NextCursor != ids[1] || page.Total != 3 {
+			t.Errorf("ListTasks = %+v, %v", page, err)
+		}
+
+		page, err = c.ListTasks(ctx, client.TaskQuery{Status: client.StatusTodo})
+		if err != nil || len(page.Tasks) != 2 || page.Tasks[0].ID != ids[1] || page.Total != 2 || page.TotalEstimate != 8 {
+			t.Errorf("ListTasks by status = %+v, %v", page, err)
+		}
+
+		page, err = c.ListTasks(ctx, client.TaskQuery{Fields: []string{"id", "status"}})
+		if err != nil || len(page.Tasks) != 3 || page.Tasks[0].ID != ids[0] || page.Tasks[0].Title != "" {
+			t.Errorf("ListTasks with fields = %+v, %v", page, err)
+		}
+
+		if _, err := c.ListTasks(ctx, client.TaskQuery{Expand: []string{"links"}}); err != nil {
+			t.Errorf("ListTasks with expand: %v", err)
+		}
+	})
+
+	t.Run("complete and reopen", func(t *testing.T) {
+		task, err := c.CompleteTask(ctx, ids[2])
+		if err != nil || task.Status != client.StatusDone || task.CompletedAt == nil || task.Title != "three" {
+			t.Fatalf("CompleteTask = %+v, %v", task, err)
+		}
+		task, err = c.ReopenTask(ctx, ids[2])
+		if err != nil || task.Status != client.StatusTodo || task.CompletedAt != nil {
+			t.Errorf("ReopenTask = %+v, %v", task, err)
+		}
+	})
+
+	t.Run("reports", func(t *testing.T) {
+		burndown, err := c.Burndown(ctx, client.ReportQuery{Project: "acme"})
+		if err != nil || burndown.WorkspaceID != "acme" || len(burndown.Points) == 0 {
+			t.Fatalf("Burndown = %+v, %v", burndown, err)
+		}
+		if last := burndown.Points[len(burndown.Points)-1]; last.Open != 2 || last.Done != 1 {
+			t.Errorf("last burndown point = %+v, want 2 open and 1 done", last)
+		}
+		throughput, err := c.Throughput(ctx, client.ReportQuery{Bucket: client.BucketWeek})
+		if err != nil || throughput.Bucket != client.BucketWeek || len(throughput.Buckets) == 0 {
+			t.Fatalf("Throughput = %+v, %v", throughput, err)
+		}
+		if last := throughput.Buckets[len(throughput.Buckets)-1]; last.Created != 3 {
+			t.Errorf("last throughput bucket = %+v, want 3 created", last)
+		}
+		workload, err := c.Workload(ctx, client.ReportQuery{})
+		if err != nil || workload.Open != 2 || workload.Estimate != 8 || len(workload.Owners) != 1 {
+			t.Errorf("Workload = %+v, %v", workload, err)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := c.GetTask(ctx, 999)
+		var apiErr *client.APIError
+		if !errors.Is(err, client.ErrNotFound) || !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+			t.Errorf("GetTask(999) error = %v, want not_found", err)
+		}
+
+		_, err = c.CreateTask(ctx, client.CreateTaskRequest{})
+		if !errors.Is(err, client.ErrValidationFailed) || !errors.As(err, &apiErr) || len(apiErr.Fields) == 0 {
+			t.Errorf("CreateTask({}) error = %v, want validation_failed with fields", err)
+		}
+
+		if _, err := admin.GetTask(ctx, ids[0]); !errors.Is(err, client.ErrUnauthorized) {
+			t.Errorf("GetTask without API key error = %v, want unauthorized", err)
+		}
+	})
+
+	t.Run("conditional writes", func(t *testing.T) {
+		task, etag, err := c.GetTaskWithETag(ctx, ids[1])
+		if err != nil || etag == "" {
+			t.Fatalf("GetTaskWithETag = %+v, %q, %v", task, etag, err)
+		}
+		update := client.UpdateTaskRequest{Title: "two (renamed)", Status: client.StatusTodo}
+		if _, err := c.UpdateTaskIfMatch(ctx, ids[1], etag, update); err != nil {
+			t.Fatalf("UpdateTaskIfMatch: %v", err)
+		}
+		if _, err := c.UpdateTaskIfMatch(ctx, ids[1], etag, update); !errors.Is(err, client.ErrPreconditionFailed) {
+			t.Errorf("stale UpdateTaskIfMatch error = %v, want precondition_failed", err)
+		}
+		if err := c.DeleteTaskIfMatch(ctx, ids[1], etag); !errors.Is(err, client.ErrPreconditionFailed) {
+			t.Errorf("stale DeleteTaskIfMatch error = %v, want precondition_failed", err)
+		}
+	})
+
+	t.Run("export and import", func(t *testing.T) {
+		body, err := c.ExportTasks(ctx, client.FormatNDJSON, client.TaskFilter{Status: client.StatusTodo})
+		if err != nil {
+			t.Fatal(err)
+		}
+		backup, _ := io.ReadAll(body)
+		body.Close()
+		if lines := strings.Count(string(backup), "\n"); lines != 2 {
+			t.Fatalf("export has %d lines, want 2", lines)
+		}
+
+		result, err := c.ImportTasks(ctx, client.FormatNDJSON, strings.NewReader(string(backup)), true)
+		if err != nil || !result.DryRun || result.Imported != 2 {
+			t.Errorf("ImportTasks = %+v, %v", result, err)
+		}
+	})
+
+	t.Run("async export and import", func(t *testing.T) {
+		await := func(job *client.TaskJob) *client.TaskJob {
+			t.Helper()
+			for job.State != client.JobSucceeded && job.State != client.JobFailed {
+				time.Sleep(10 * time.Millisecond)
+				var err error
+				if job, err = c.GetJob(ctx, job.ID); err != nil {
+					t.Fatalf("GetJob: %v", err)
+				}
+			}
+			return job
+		}
+
+		job, err := c.StartExport(ctx, client.FormatCSV, client.TaskFilter{Status: client.StatusTodo})
+		if err != nil {
+			t.Fatalf("StartExport: %v", err)
+		}
+		if job = await(job); job.State != client.JobSucceeded || job.Progress.Done != 2 {
+			t.Fatalf("export job = %+v", job)
+		}
+		body, err := c.DownloadJob(ctx, job.ID)
+		if err != nil {
+			t.Fatalf("DownloadJob: %v", err)
+		}
+		csv, _ := io.ReadAll(body)
+		body.Close()
+
+		job, err = c.StartImport(ctx, client.FormatCSV, strings.NewReader(string(csv)), true)
+		if err != nil {
+			t.Fatalf("StartImport: %v", err)
+		}
+		if job = await(job); job.Result == nil || !job.Result.DryRun || job.Result.Imported != 2 {
+			t.Errorf("import job = %+v, result %+v", job, job.Result)
+		}
+	})
+
+	t.Run("bulk delete", func(t *testing.T) {
+		filter := client.TaskFilter{Status: client.StatusDone}
+		preview, err := c.PreviewDeleteTasks(ctx, filter)
+		if err != nil || preview.Count != 1 {
+			t.Fatalf("PreviewDeleteTasks = %+v, %v", preview, err)
+		}
+		result, err := c.ConfirmDeleteTasks(ctx, filter, preview.Token)
+		if err != nil || result.Deleted != 1 {
+			t.Fatalf("ConfirmDeleteTasks = %+v, %v", result, err)
+		}
+		if _, err := c.ConfirmDeleteTasks(ctx, filter, preview.Token); !errors.Is(err, client.ErrInvalidConfirmation) {
+			t.Errorf("reused token error = %v, want invalid_confirmation", err)
+		}
+	})
+
+	t.Run("undo", func(t *testing.T) {
+		token, err := c.DeleteTaskWithUndo(ctx, ids[2])
+		if err != nil || token == "" {
+			t.Fatalf("DeleteTaskWithUndo = %q, %v", token, err)
+		}
+		result, err := c.Undo(ctx, token)
+		if err != nil || result.Restored != 1 || result.Tasks[0].ID != ids[2] {
+			t.Fatalf("Undo = %+v, %v", result, err)
+		}
+		if _, err := c.Undo(ctx, token); !errors.Is(err, client.ErrNotFound) {
+			t.Errorf("reused token error = %v, want not_found", err)
+		}
+	})
+
+	if err := c.DeleteTask(ctx, ids[2]); err != nil {
+		t.Errorf("DeleteTask: %v", err)
+	}
+	if info, err := c.Version(ctx); err != nil || info.Version == "" {
+		t.Errorf("Version = %+v, %v", info, err)
+	}
+}
+
+func TestClient_Admin(t *testing.T) {
+	ctx := context.Background()
+	srv := tasktest.NewServer(t, tasktest.WithAuth())
+	admin := srv.Client()
+
+	key, err := admin.CreateAPIKey(ctx, client.CreateAPIKeyRequest{Name: "ops", Scope: client.ScopeReadWrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := admin.ListAPIKeys(ctx)
+	if err != nil || len(keys) != 1 || keys[0].Prefix != key.Prefix {
+		t.Fatalf("ListAPIKeys = %+v, %v", keys, err)
+	}
+
+	rotated, err := admin.RotateAPIKey(ctx, key.ID)
+	if err != nil || rotated.ID != key.ID || rotated.Key == key.Key {
+		t.Fatalf("RotateAPIKey = %+v, %v", rotated, err)
+	}
+	if _, err := srv.Client(client.WithAPIKey(key.Key)).ListWebhooks(ctx); !errors.Is(err, client.ErrUnauthorized) {
+		t.Errorf("old secret error = %v, want unauthorized", err)
+	}
+	c := srv.Client(client.WithAPIKey(rotated.Key))
+
+	state, err := admin.SetMaintenance(ctx, true, "compacting")
+	if err != nil || !state.Enabled {
+		t.Fatalf("SetMaintenance = %+v, %v", state, err)
+	}
+	if _, err := c.CreateTask(ctx, client.CreateTaskRequest{Title: "t"}); !errors.Is(err, client.ErrMaintenance) {
+		t.Errorf("CreateTask in maintenance error = %v, want maintenance", err)
+	}
+	if _, err := admin.Compact(ctx); err != nil {
+		t.Errorf("Compact: %v", err)
+	}
+	if _, err := admin.SetMaintenance(ctx, false, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateTask(ctx, client.CreateTaskRequest{Title: "t"}); err != nil {
+		t.Errorf("CreateTask after maintenance: %v", err)
+	}
+
+	if stats, err := admin.StorageStats(ctx); err != nil || stats.Tasks != 1 || stats.APIKeys != 1 {
+		t.Errorf("StorageStats = %+v, %v", stats, err)
+	}
+	report, err := admin.Diagnostics(ctx)
+	if err != nil || report.Maintenance.Enabled || report.Storage == nil || report.Runtime.Goroutines == 0 {
+		t.Errorf("Diagnostics = %+v, %v", report, err)
+	}
+}
+
+func TestClient_Retry(t *testing.T) {
+	policy := client.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	tests := []struct {
+		name         string
+		statuses     []int
+		call         func(*client.Client) error
+		wantAttempts int32
+		wantErr      *client.APIError
+	}{
+		{
+			name:     "rate limited POST is retried",
+			statuses: []int{http.StatusTooManyRequests, http.StatusCreated},
+			call: func(c *client.Client) error {
+				_, err := c.CreateTask(context.Background(), client.CreateTaskRequest{Title: "t"})
+				return err
+			},
+			wantAttempts: 2,
+		},
+		{
+			name:     "bad gateway GET is retried",
+			statuses: []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusOK},
+			call: func(c *client.Client) error {
+				_, err := c.GetTask(context.Background(), 1)
+				return err
+			},
+			wantAttempts: 3,
+		},
+		{
+			name:     "bad gateway POST is not retried",
+			statuses: []int{http.StatusBadGateway},
+			call: func(c *client.Client) error {
+				_, err := c.CreateTask(context.Background(), client.CreateTaskRequest{Title: "t"})
+				return err
+			},
+			wantAttempts: 1,
+		},
+		{
+			name:     "attempts run out",
+			statuses: []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+			call: func(c *client.Client) error {
+				_, err := c.GetTask(context.Background(), 1)
+				return err
+			},
+			wantAttempts: 3,
+			wantErr:      client.ErrShuttingDown,
+		},
+		{
+			name:     "import streams are not retried",
+			statuses: []int{http.StatusTooManyRequests},
+			call: func(c *client.Client) error {
+				_, err := c.ImportTasks(context.Background(), client.FormatCSV, strings.NewReader("title\nt\n"), false)
+				return err
+			},
+			wantAttempts: 1,
+			wantErr:      client.ErrRateLimited,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := attempts.Add(1)
+				status := tt.statuses[min(int(n), len(tt.statuses))-1]
+				w.Header().Set("Content-Type", "application/json")
+				switch status {
+				case http.StatusTooManyRequests:
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(status)
+					io.WriteString(w, `{"code":"rate_limited","message":"slow down"}`)
+				case http.StatusServiceUnavailable:
+					// The problem+json error format
+					w.Header().Set("Content-Type", "application/problem+json")
+					w.WriteHeader(status)
+					io.WriteString(w, `{"type":"/problems/shutting_down","title":"Service Unavailable","status":503,"detail":"draining","code":"shutting_down"}`)
+				case http.StatusBadGateway:
+					w.WriteHeader(status)
+					io.WriteString(w, "bad gateway")
+				default:
+					w.WriteHeader(status)
+					io.WriteString(w, `{"id":1,"title":"t","status":"todo"}`)
+				}
+			}))
+			defer ts.Close()
+
+			c, err := client.New(ts.URL, client.WithRetry(policy))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = tt.call(c)
+			if got := attempts.Load(); got != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", got, tt.wantAttempts)
+			}
+			switch {
+			case tt.wantErr != nil && !errors.Is(err, tt.wantErr):
+				t.Errorf("error = %v, want %s", err, tt.wantErr.Code)
+			case tt.wantErr == nil && tt.statuses[len(tt.statuses)-1] < 400 && err != nil:
+				t.Errorf("error = %v, want success", err)
+			case tt.statuses[len(tt.statuses)-1] >= 400 && err == nil:
+				t.Error("call succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, url := range []string{"localhost:8080", "ftp://example.com", "://"} {
+		if _, err := client.New(url); err == nil {
+			t.Errorf("New(%q) succeeded", url)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// APIError is an error response from the API. Match it against the Err*
+// sentinels with errors.Is, or read its code with errors.As.
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Fields     []FieldError
+	RequestID  string
+}
+
+// FieldError describes a problem with one request field
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+
+	// Candidates lists what an ambiguous value, such as a due date, could
+	// mean
+	Candidates []string `json:"candidates,omitempty"`
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("api error %d %s: %s", e.StatusCode, e.Code, e.Message)
+	if e.RequestID != "" {
+		msg += " (request " + e.RequestID + ")"
+	}
+	return msg
+}
+
+// Is matches errors with the same code, so errors.Is(err, ErrNotFound)
+// holds for any not_found response
+func (e *APIError) Is(target error) bool {
+	t, ok := target.(*APIError)
+	return ok && t.StatusCode == 0 && t.Code == e.Code
+}
+
+// Sentinels for the API's error codes, to be used with errors.Is
+var (
+	ErrInvalidJSON         = &APIError{Code: "invalid_json"}
+	ErrInvalidCSV          = &APIError{Code: "invalid_csv"}
+	ErrBodyTooLarge        = &APIError{Code: "body_too_large"}
+	ErrInvalidID           = &APIError{Code: "invalid_id"}
+	ErrInvalidQuery        = &APIError{Code: "invalid_query"}
+	ErrValidationFailed    = &APIError{Code: "validation_failed"}
+	ErrContentRejected     = &APIError{Code: "content_rejected"}
+	ErrNotFound            = &APIError{Code: "not_found"}
+	ErrWorkspaceNotFound   = &APIError{Code: "workspace_not_found"}
+	ErrMethodNotAllowed    = &APIError{Code: "method_not_allowed"}
+	ErrLinkTargetNotFound  = &APIError{Code: "link_target_not_found"}
+	ErrSelfLink            = &APIError{Code: "self_link"}
+	ErrConflict            = &APIError{Code: "conflict"}
+	ErrPreconditionFailed  = &APIError{Code: "precondition_failed"}
+	ErrTaskLimitReached    = &APIError{Code: "task_limit_reached"}
+	ErrNotImplemented      = &APIError{Code: "not_implemented"}
+	ErrSearchUnavailable   = &APIError{Code: "search_unavailable"}
+	ErrInvalidConfirmation = &APIError{Code: "invalid_confirmation"}
+	ErrShuttingDown        = &APIError{Code: "shutting_down"}
+	ErrRequestTimeout      = &APIError{Code: "request_timeout"}
+	ErrMaintenance         = &APIError{Code: "maintenance"}
+	ErrRateLimited         = &APIError{Code: "rate_limited"}
+	ErrUnauthorized        = &APIError{Code: "unauthorized"}
+	ErrForbidden           = &APIError{Code: "forbidden"}
+	ErrInternal            = &APIError{Code: "internal_error"}
+)
+
+// maxErrorBody caps how much of an error response is read
+const maxErrorBody = 64 << 10
+
+// decodeError reads an error response in either of the server's formats:
+// the default JSON body or RFC 7807 problem+json
+func decodeError(resp *http.Response) *APIError {
+	apiErr := &APIError{StatusCode: resp.StatusCode, RequestID: resp.Header.Get("X-Request-ID")}
+
+	var body struct {
+		Code      string       `json:"code"`
+		Message   string       `json:"message"`
+		Detail    string       `json:"detail"`
+		Fields    []FieldError `json:"fields"`
+		RequestID string       `json:"request_id"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	if err := json.Unmarshal(data, &body); err != nil || body.Code == "" {
+		// Not an API error body, e.g. from a proxy in front of the server
+		apiErr.Message = strings.TrimSpace(string(data))
+		if apiErr.Message == "" {
+			apiErr.Message = http.StatusText(resp.StatusCode)
+		}
+		return apiErr
+	}
+
+	apiErr.Code = body.Code
+	apiErr.Message = body.Message
+	if apiErr.Message == "" {
+		apiErr.Message = body.Detail
+	}
+	apiErr.Fields = body.Fields
+	if body.RequestID != "" {
+		apiErr.RequestID = body.RequestID
+	}
+	return apiErr
+}
@@ -0,0 +1,68 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+// Health returns the dependency report. A report with a critical
+// dependency down comes with status 503 and is returned without an error.
+func (c *Client) Health(ctx context.Context) (*HealthReport, error) {
+	resp, err := c.send(ctx, request{method: http.MethodGet, path: "/health", cred: noKey}, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusServiceUnavailable {
+		return nil, decodeError(resp)
+	}
+	var report HealthReport
+	if err := decodeJSON(resp, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// Version returns the server's build version
+func (c *Client) Version(ctx context.Context) (*VersionInfo, error) {
+	var info VersionInfo
+	if err := c.call(ctx, request{method: http.MethodGet, path: "/version", cred: noKey}, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// Changelog returns the API changes per release, newest first
+func (c *Client) Changelog(ctx context.Context) (*Changelog, error) {
+	var cl Changelog
+	if err := c.call(ctx, request{method: http.MethodGet, path: "/changelog.json", cred: noKey}, &cl); err != nil {
+		return nil, err
+	}
+	return &cl, nil
+}
+
+// Schema returns a JSON Schema document, such as "task.json"
+func (c *Client) Schema(ctx context.Context, name string) (json.RawMessage, error) {
+	if name == "" {
+		return nil, errors.New("client: schema name is required")
+	}
+	var doc json.RawMessage
+	err := c.call(ctx, request{method: http.MethodGet, path: "/schemas/" + url.PathEscape(name), cred: noKey}, &doc)
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// OpenAPI returns the OpenAPI document describing the API
+func (c *Client) OpenAPI(ctx context.Context) (json.RawMessage, error) {
+	var doc json.RawMessage
+	if err := c.call(ctx, request{method: http.MethodGet, path: "/openapi.json", cred: noKey}, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ReportQuery selects the workspace and range of a report. Zero values
+// leave the server's defaults: the client's workspace, day buckets and the
+// last 30 days, or 12 weeks.
+type ReportQuery struct {
+	// Project is the workspace to report on, which must be the client's
+	// own if it set one with WithWorkspace
+	Project string
+
+	From, To time.Time
+
+	// Bucket is BucketDay or BucketWeek
+	Bucket string
+}
+
+// Burndown returns the open and done task counts at the end of each bucket
+func (c *Client) Burndown(ctx context.Context, q ReportQuery) (*BurndownReport, error) {
+	var report BurndownReport
+	if err := c.call(ctx, request{method: http.MethodGet, path: "/reports/burndown", query: q.values()}, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// Throughput returns the tasks created and completed in each bucket
+func (c *Client) Throughput(ctx context.Context, q ReportQuery) (*ThroughputReport, error) {
+	var report ThroughputReport
+	if err := c.call(ctx, request{method: http.MethodGet, path: "/reports/throughput", query: q.values()}, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// Workload returns the open tasks and their estimated effort, in total and
+// by owner. Only q.Project is used.
+func (c *Client) Workload(ctx context.Context, q ReportQuery) (*WorkloadReport, error) {
+	query := url.Values{}
+	if q.Project != "" {
+		query.Set("project", q.Project)
+	}
+	var report WorkloadReport
+	if err := c.call(ctx, request{method: http.MethodGet, path: "/reports/workload", query: query}, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+func (q ReportQuery) values() url.Values {
+	query := url.Values{}
+	if q.Project != "" {
+		query.Set("project", q.Project)
+	}
+	if !q.From.IsZero() {
+		query.Set("from", q.From.Format(time.RFC3339))
+	}
+	if !q.To.IsZero() {
+		query.Set("to", q.To.Format(time.RFC3339))
+	}
+	if q.Bucket != "" {
+		query.Set("bucket", q.Bucket)
+	}
+	return query
+}
@@ -0,0 +1,455 @@
+package client
+
+import (
+	"context"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// CreateTask creates a task
+func (c *Client) CreateTask(ctx context.Context, req CreateTaskRequest) (*Task, error) {
+	var task Task
+	err := c.call(ctx, request{method: http.MethodPost, path: "/tasks", body: req}, &task)
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// GetTask returns the task with the given ID
+func (c *Client) GetTask(ctx context.Context, id int64) (*Task, error) {
+	var task Task
+	if err := c.call(ctx, request{method: http.MethodGet, path: taskPath(id)}, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// GetTaskWithETag returns the task with the given ID and its ETag, for
+// UpdateTaskIfMatch and DeleteTaskIfMatch
+func (c *Client) GetTaskWithETag(ctx context.Context, id int64) (*Task, string, error) {
+	resp, err := c.do(ctx, request{method: http.MethodGet, path: taskPath(id)})
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	var task Task
+	if err := decodeJSON(resp, &task); err != nil {
+		return nil, "", err
+	}
+	return &task, resp.Header.Get("ETag"), nil
+}
+
+// UpdateTask replaces the title, description, status and due date of a task
+func (c *Client) UpdateTask(ctx context.Context, id int64, req UpdateTaskRequest) (*Task, error) {
+	var task Task
+	if err := c.call(ctx, request{method: http.MethodPut, path: taskPath(id), body: req}, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// UpdateTaskIfMatch updates a task only if it is unchanged since etag was
+// read, returning an error matching ErrPreconditionFailed otherwise
+func (c *Client) UpdateTaskIfMatch(ctx context.Context, id int64, etag string, req UpdateTaskRequest) (*Task, error) {
+	var task Task
+	err := c.call(ctx, request{method: http.MethodPut, path: taskPath(id), body: req, ifMatch: etag}, &task)
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// DeleteTask deletes a task
+func (c *Client) DeleteTask(ctx context.Context, id int64) error {
+	return c.call(ctx, request{method: http.MethodDelete, path: taskPath(id)}, nil)
+}
+
+// DeleteTaskWithUndo deletes a task and returns the token Undo takes to
+// put it back, or "" if the server has undo turned off
+func (c *Client) DeleteTaskWithUndo(ctx context.Context, id int64) (string, error) {
+	resp, err := c.do(ctx, request{method: http.MethodDelete, path: taskPath(id)})
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return resp.Header.Get(undoTokenHeader), nil
+}
+
+// DeleteTaskIfMatch deletes a task only if it is unchanged since etag was
+// read, returning an error matching ErrPreconditionFailed otherwise
+func (c *Client) DeleteTaskIfMatch(ctx context.Context, id int64, etag string) error {
+	return c.call(ctx, request{method: http.MethodDelete, path: taskPath(id), ifMatch: etag}, nil)
+}
+
+// CompleteTask marks a task done and returns it, leaving its other fields
+// as they are
+func (c *Client) CompleteTask(ctx context.Context, id int64) (*Task, error) {
+	var task Task
+	if err := c.call(ctx, request{method: http.MethodPost, path: taskPath(id) + "/complete"}, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// ReopenTask marks a done task todo again and returns it
+func (c *Client) ReopenTask(ctx context.Context, id int64) (*Task, error) {
+	var task Task
+	if err := c.call(ctx, request{method: http.MethodPost, path: taskPath(id) + "/reopen"}, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// CreateLink adds a typed link from a task to another and returns the task
+func (c *Client) CreateLink(ctx context.Context, id int64, req CreateLinkRequest) (*Task, error) {
+	var task Task
+	err := c.call(ctx, request{method: http.MethodPost, path: taskPath(id) + "/links", body: req}, &task)
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// ListRevisions returns the previous versions of a task, oldest first
+func (c *Client) ListRevisions(ctx context.Context, id int64) ([]TaskRevision, error) {
+	var revisions []TaskRevision
+	if err := c.call(ctx, request{method: http.MethodGet, path: taskPath(id) + "/revisions"}, &revisions); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// RestoreRevision puts a task back to revision n and returns the task; the
+// version it replaces becomes a new revision
+func (c *Client) RestoreRevision(ctx context.Context, id int64, n int) (*Task, error) {
+	var task Task
+	path := taskPath(id) + "/revisions/" + strconv.Itoa(n) + "/restore"
+	if err := c.call(ctx, request{method: http.MethodPost, path: path}, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// TaskQuery selects tasks to list
+type TaskQuery struct {
+	// Search is a full-text query; search results are not paginated
+	Search string
+
+	// Status lists only tasks in this status, "todo" or "done". It
+	// cannot be combined with Search.
+	Status TaskStatus
+
+	// Limit is the page size, up to 1000; zero lists everything at once
+	Limit int
+
+	// Offset skips tasks; After starts after a task ID. They cannot be
+	// combined.
+	Offset int
+	After  int64
+
+	// Fields limits the tasks to these JSON fields, such as "id" and
+	// "title"; the others are left zero. Empty returns every field.
+	Fields []string
+
+	// Expand embeds related resources, such as "links" to fill in each
+	// TaskLink's Task, or "links.links" to go a level deeper
+	Expand []string
+}
+
+// Page is one page of a task listing
+type Page struct {
+	Tasks []Task
+
+	// NextCursor is the After value of the next page, or zero on the last
+	NextCursor int64
+
+	// Total is the number of tasks across every page
+	Total int
+
+	// TotalEstimate adds up the estimates of the tasks across every page,
+	// in the workspace's estimate unit. Searches leave it zero.
+	TotalEstimate int
+}
+
+// ListTasks returns one page of tasks, ordered by ID
+func (c *Client) ListTasks(ctx context.Context, q TaskQuery) (*Page, error) {
+	query := url.Values{}
+	if q.Search != "" {
+		query.Set("q", q.Search)
+	}
+	if q.Limit > 0 {
+		query.Set("limit", strconv.Itoa(q.Limit))
+	}
+	if q.Offset > 0 {
+		query.Set("offset", strconv.Itoa(q.Offset))
+	}
+	if q.After > 0 {
+		query.Set("after", strconv.FormatInt(q.After, 10))
+	}
+	if len(q.Fields) > 0 {
+		query.Set("fields", strings.Join(q.Fields, ","))
+	}
+	if len(q.Expand) > 0 {
+		query.Set("expand", strings.Join(q.Expand, ","))
+	}
+
+	path := "/tasks"
+	if q.Status != "" {
+		path += "/status/" + url.PathEscape(string(q.Status))
+	}
+	resp, err := c.do(ctx, request{method: http.MethodGet, path: path, query: query})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	page := &Page{}
+	if err := decodeJSON(resp, &page.Tasks); err != nil {
+		return nil, err
+	}
+	if cursor := resp.Header.Get("X-Next-Cursor"); cursor != "" {
+		page.NextCursor, _ = strconv.ParseInt(cursor, 10, 64)
+	}
+	page.Total, _ = strconv.Atoi(resp.Header.Get("X-Total-Count"))
+	page.TotalEstimate, _ = strconv.Atoi(resp.Header.Get("X-Total-Estimate"))
+	return page, nil
+}
+
+// defaultPageSize is the page size Tasks uses when the query sets none
+const defaultPageSize = 100
+
+// Tasks iterates over every task matching q, fetching pages with the
+// cursor as it goes. Iteration stops after the first error, which is
+// yielded with a nil task.
+func (c *Client) Tasks(ctx context.Context, q TaskQuery) iter.Seq2[*Task, error] {
+	return func(yield func(*Task, error) bool) {
+		if q.Limit == 0 && q.Search == "" {
+			q.Limit = defaultPageSize
+		}
+		for {
+			page, err := c.ListTasks(ctx, q)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			for i := range page.Tasks {
+				if !yield(&page.Tasks[i], nil) {
+					return
+				}
+			}
+			if page.NextCursor == 0 {
+				return
+			}
+			q.Offset, q.After = 0, page.NextCursor
+		}
+	}
+}
+
+// TaskFilter selects tasks for bulk delete and export. Bulk delete needs
+// at least one of them.
+type TaskFilter struct {
+	Status TaskStatus
+	Search string
+}
+
+func (f TaskFilter) query() url.Values {
+	query := url.Values{}
+	if f.Status != "" {
+		query.Set("status", string(f.Status))
+	}
+	if f.Search != "" {
+		query.Set("q", f.Search)
+	}
+	return query
+}
+
+// PreviewDeleteTasks counts the tasks a bulk delete would remove and
+// returns the token that confirms it
+func (c *Client) PreviewDeleteTasks(ctx context.Context, f TaskFilter) (*BulkDeletePreview, error) {
+	var preview BulkDeletePreview
+	err := c.call(ctx, request{method: http.MethodDelete, path: "/tasks", query: f.query()}, &preview)
+	if err != nil {
+		return nil, err
+	}
+	return &preview, nil
+}
+
+// ConfirmDeleteTasks deletes the tasks of a preview, provided the filter
+// still matches exactly the same tasks
+func (c *Client) ConfirmDeleteTasks(ctx context.Context, f TaskFilter, token string) (*BulkDeleteResult, error) {
+	result, _, err := c.ConfirmDeleteTasksWithUndo(ctx, f, token)
+	return result, err
+}
+
+// ConfirmDeleteTasksWithUndo is ConfirmDeleteTasks, also returning the
+// token Undo takes to put the tasks back, or "" if the server has undo
+// turned off
+func (c *Client) ConfirmDeleteTasksWithUndo(ctx context.Context, f TaskFilter, token string) (*BulkDeleteResult, string, error) {
+	query := f.query()
+	query.Set("confirm", token)
+
+	resp, err := c.do(ctx, request{method: http.MethodDelete, path: "/tasks", query: query, once: true})
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	var result BulkDeleteResult
+	if err := decodeJSON(resp, &result); err != nil {
+		return nil, "", err
+	}
+	return &result, resp.Header.Get(undoTokenHeader), nil
+}
+
+// undoTokenHeader carries the token that undoes a delete
+const undoTokenHeader = "X-Undo-Token"
+
+// Undo puts back the tasks removed by the delete that returned token.
+// Tokens are single-use and expire after the server's undo window, after
+// which Undo fails with an error matching ErrNotFound.
+func (c *Client) Undo(ctx context.Context, token string) (*UndoResult, error) {
+	var result UndoResult
+	err := c.call(ctx, request{method: http.MethodPost, path: "/undo/" + url.PathEscape(token), once: true}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Export formats
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// ExportTasks streams the tasks matching f in format, FormatCSV or
+// FormatNDJSON. The caller closes the returned reader.
+func (c *Client) ExportTasks(ctx context.Context, format string, f TaskFilter) (io.ReadCloser, error) {
+	query := f.query()
+	query.Set("format", format)
+
+	resp, err := c.do(ctx, request{method: http.MethodGet, path: "/tasks/export", query: query})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// ImportTasks creates tasks from a CSV file or an NDJSON backup read from
+// r. In a dry run the file is only validated. Imports are not retried, as
+// r cannot be replayed.
+func (c *Client) ImportTasks(ctx context.Context, format string, r io.Reader, dryRun bool) (*ImportResult, error) {
+	contentType := "text/csv"
+	if format == FormatNDJSON {
+		contentType = "application/x-ndjson"
+	}
+	query := url.Values{"format": {format}}
+	if dryRun {
+		query.Set("dry_run", "true")
+	}
+
+	var result ImportResult
+	err := c.call(ctx, request{
+		method: http.MethodPost, path: "/tasks/import", query: query,
+		raw: r, contentType: contentType,
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// StartExport starts exporting the tasks matching f in format as a
+// background job. Poll it with GetJob and fetch the file with DownloadJob
+// once it has succeeded.
+func (c *Client) StartExport(ctx context.Context, format string, f TaskFilter) (*TaskJob, error) {
+	query := f.query()
+	query.Set("format", format)
+	query.Set("async", "true")
+
+	var job TaskJob
+	if err := c.call(ctx, request{method: http.MethodGet, path: "/tasks/export", query: query}, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// StartImport uploads a file as ImportTasks does, but imports it as a
+// background job; the finished job holds the ImportResult
+func (c *Client) StartImport(ctx context.Context, format string, r io.Reader, dryRun bool) (*TaskJob, error) {
+	contentType := "text/csv"
+	if format == FormatNDJSON {
+		contentType = "application/x-ndjson"
+	}
+	query := url.Values{"format": {format}, "async": {"true"}}
+	if dryRun {
+		query.Set("dry_run", "true")
+	}
+
+	var job TaskJob
+	err := c.call(ctx, request{
+		method: http.MethodPost, path: "/tasks/import", query: query,
+		raw: r, contentType: contentType,
+	}, &job)
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetJob returns the state and progress of an import or export job
+func (c *Client) GetJob(ctx context.Context, id int64) (*TaskJob, error) {
+	var job TaskJob
+	if err := c.call(ctx, request{method: http.MethodGet, path: jobPath(id)}, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// DownloadJob streams the file a succeeded export job produced. The caller
+// closes the returned reader.
+func (c *Client) DownloadJob(ctx context.Context, id int64) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, request{method: http.MethodGet, path: jobPath(id) + "/download"})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func jobPath(id int64) string {
+	return "/jobs/" + strconv.FormatInt(id, 10)
+}
+
+// CalendarToken returns the token and path of the caller's calendar feed
+func (c *Client) CalendarToken(ctx context.Context) (*CalendarFeed, error) {
+	var feed CalendarFeed
+	if err := c.call(ctx, request{method: http.MethodGet, path: "/calendar/token"}, &feed); err != nil {
+		return nil, err
+	}
+	return &feed, nil
+}
+
+// CalendarFeed streams the iCalendar feed a token grants access to.
+// component is "vevent" (the default when empty) or "vtodo". The caller
+// closes the returned reader.
+func (c *Client) CalendarFeed(ctx context.Context, token, component string) (io.ReadCloser, error) {
+	query := url.Values{"token": {token}}
+	if component != "" {
+		query.Set("component", component)
+	}
+	resp, err := c.do(ctx, request{method: http.MethodGet, path: "/calendar.ics", query: query, cred: noKey})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func taskPath(id int64) string {
+	return "/tasks/" + strconv.FormatInt(id, 10)
+}
@@ -0,0 +1,121 @@
+package client
+
+import (
+	"github.com/light-bringer/cert-tasks/internal/audit"
+	"github.com/light-bringer/cert-tasks/internal/changelog"
+	"github.com/light-bringer/cert-tasks/internal/diagnostics"
+	"github.com/light-bringer/cert-tasks/internal/health"
+	"github.com/light-bringer/cert-tasks/internal/latency"
+	"github.com/light-bringer/cert-tasks/internal/maintenance"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+	"github.com/light-bringer/cert-tasks/internal/scheduler"
+	"github.com/light-bringer/cert-tasks/internal/seed"
+	"github.com/light-bringer/cert-tasks/internal/version"
+)
+
+// The API's documents. They are the server's own types, so the client
+// cannot drift from what the server sends.
+type (
+	Task              = models.Task
+	TaskStatus        = models.TaskStatus
+	TaskLink          = models.TaskLink
+	LinkType          = models.LinkType
+	CreateTaskRequest = models.CreateTaskRequest
+	UpdateTaskRequest = models.UpdateTaskRequest
+	CreateLinkRequest = models.CreateLinkRequest
+	TaskRevision      = models.TaskRevision
+	BulkDeletePreview = models.BulkDeletePreview
+	BulkDeleteResult  = models.BulkDeleteResult
+	UndoResult        = models.UndoResult
+	ImportResult      = models.ImportResult
+	ImportRowError    = models.ImportRowError
+	TaskJob           = models.TaskJob
+	JobState          = models.JobState
+	JobProgress       = models.JobProgress
+	CalendarFeed      = models.CalendarFeed
+	BurndownReport    = models.BurndownReport
+	BurndownPoint     = models.BurndownPoint
+	ThroughputReport  = models.ThroughputReport
+	ThroughputBucket  = models.ThroughputBucket
+	WorkloadReport    = models.WorkloadReport
+	WorkloadGroup     = models.WorkloadGroup
+
+	Webhook              = models.Webhook
+	TaskEventType        = models.TaskEventType
+	TaskEvent            = models.TaskEvent
+	CreateWebhookRequest = models.CreateWebhookRequest
+	CreatedWebhook       = models.CreatedWebhook
+	WebhookDelivery      = models.WebhookDelivery
+	DeliveryStatus       = models.DeliveryStatus
+
+	APIKey                 = models.APIKey
+	APIKeyScope            = models.APIKeyScope
+	CreateAPIKeyRequest    = models.CreateAPIKeyRequest
+	CreatedAPIKey          = models.CreatedAPIKey
+	Workspace              = models.Workspace
+	CreateWorkspaceRequest = models.CreateWorkspaceRequest
+	UpdateWorkspaceRequest = models.UpdateWorkspaceRequest
+
+	AuditEntry   = audit.Entry
+	SlowReport   = latency.Report
+	JobStatus    = scheduler.Status
+	HealthReport = health.Report
+	VersionInfo  = version.Info
+	Changelog    = changelog.Changelog
+
+	StorageStats       = repository.Stats
+	CompactResult      = repository.CompactResult
+	DurationStats      = repository.DurationStats
+	MaintenanceState   = maintenance.State
+	MaintenanceRequest = maintenance.Request
+	Diagnostics        = diagnostics.Report
+	RuntimeStats       = diagnostics.Runtime
+
+	Fixtures      = seed.Fixtures
+	SeedWorkspace = seed.Workspace
+	SeedAPIKey    = seed.APIKey
+	SeedTask      = seed.Task
+	SeedResult    = seed.Result
+)
+
+// Task statuses
+const (
+	StatusTodo = models.StatusTodo
+	StatusDone = models.StatusDone
+)
+
+// Report bucket sizes
+const (
+	BucketDay  = models.BucketDay
+	BucketWeek = models.BucketWeek
+)
+
+// Link types
+const (
+	LinkRelatesTo   = models.LinkRelatesTo
+	LinkDuplicateOf = models.LinkDuplicateOf
+	LinkCausedBy    = models.LinkCausedBy
+)
+
+// Webhook event types
+const (
+	EventTaskCreated   = models.EventTaskCreated
+	EventTaskUpdated   = models.EventTaskUpdated
+	EventTaskCompleted = models.EventTaskCompleted
+	EventTaskDeleted   = models.EventTaskDeleted
+)
+
+// Job states
+const (
+	JobPending   = models.JobPending
+	JobRunning   = models.JobRunning
+	JobSucceeded = models.JobSucceeded
+	JobFailed    = models.JobFailed
+)
+
+// API key scopes
+const (
+	ScopeRead      = models.ScopeRead
+	ScopeReadWrite = models.ScopeReadWrite
+)
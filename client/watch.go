@@ -0,0 +1,101 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"strings"
+
+	"golang.org/x/net/websocket"
+)
+
+// WatchOptions narrows the events Watch receives; empty fields match all
+type WatchOptions struct {
+	Events  []TaskEventType
+	TaskIDs []int64
+}
+
+// watchMessage is the subset of WebSocket messages Watch reads and sends
+type watchMessage struct {
+	Type    string          `json:"type"`
+	Event   *TaskEvent      `json:"event,omitempty"`
+	Message string          `json:"message,omitempty"`
+	Events  []TaskEventType `json:"events,omitempty"`
+	TaskIDs []int64         `json:"task_ids,omitempty"`
+}
+
+// Watch streams the workspace's task events over the WebSocket API until
+// ctx is done, answering the server's pings. Iteration ends after the first
+// error, which is yielded with a nil event; cancelling ctx ends it without
+// one. Watch does not reconnect.
+func (c *Client) Watch(ctx context.Context, opts WatchOptions) iter.Seq2[*TaskEvent, error] {
+	return func(yield func(*TaskEvent, error) bool) {
+		ws, err := c.dialWebSocket(ctx)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer ws.Close()
+
+		stop := context.AfterFunc(ctx, func() { ws.Close() })
+		defer stop()
+
+		subscribe := watchMessage{Type: "subscribe", Events: opts.Events, TaskIDs: opts.TaskIDs}
+		if err := websocket.JSON.Send(ws, subscribe); err != nil {
+			yield(nil, fmt.Errorf("client: subscribing: %w", err))
+			return
+		}
+
+		for {
+			var msg watchMessage
+			if err := websocket.JSON.Receive(ws, &msg); err != nil {
+				if ctx.Err() == nil {
+					yield(nil, fmt.Errorf("client: reading events: %w", err))
+				}
+				return
+			}
+
+			switch msg.Type {
+			case "event":
+				if msg.Event != nil && !yield(msg.Event, nil) {
+					return
+				}
+			case "ping":
+				if err := websocket.JSON.Send(ws, watchMessage{Type: "pong"}); err != nil {
+					yield(nil, fmt.Errorf("client: answering ping: %w", err))
+					return
+				}
+			case "error":
+				yield(nil, errors.New("client: watch: "+msg.Message))
+				return
+			}
+		}
+	}
+}
+
+// dialWebSocket opens a connection to /ws with the client's credentials.
+// The Origin is the server's own, which it always accepts.
+func (c *Client) dialWebSocket(ctx context.Context) (*websocket.Conn, error) {
+	location := *c.baseURL
+	location.Scheme = strings.Replace(location.Scheme, "http", "ws", 1)
+	location.Path += "/ws"
+
+	cfg, err := websocket.NewConfig(location.String(), c.baseURL.String())
+	if err != nil {
+		return nil, err
+	}
+	if c.apiKey != "" {
+		cfg.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	if c.workspace != "" {
+		cfg.Header.Set("X-Workspace-ID", c.workspace)
+	}
+	cfg.Header.Set("User-Agent", c.userAgent)
+
+	ws, err := cfg.DialContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("client: connecting to %s: %w", location.Redacted(), err)
+	}
+	return ws, nil
+}
@@ -2,40 +2,119 @@ package main
 
 import (
 	"context"
-	"log"
+	"errors"
+	"flag"
+	"io"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
 
-	"github.com/light-bringer/cert-tasks/internal/handlers"
-	"github.com/light-bringer/cert-tasks/internal/repository"
-	"github.com/light-bringer/cert-tasks/internal/server"
+	"github.com/light-bringer/cert-tasks/internal/config"
+	"github.com/light-bringer/cert-tasks/internal/logging"
+	"github.com/light-bringer/cert-tasks/internal/redact"
+	"github.com/light-bringer/cert-tasks/internal/startup"
+	"github.com/light-bringer/cert-tasks/internal/systemd"
+	"github.com/light-bringer/cert-tasks/internal/tracing"
+	"github.com/light-bringer/cert-tasks/tasks"
 )
 
 func main() {
-	// Get port from environment variable or use default
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = ":8080"
-	} else {
-		port = ":" + port
-	}
-
-	// Initialize repository
-	repo := repository.NewMemoryRepository()
+	personalMode := flag.Bool("personal", false, "run as a local personal task app (data in ~/.cert-tasks, embedded UI, automatic backups)")
+	checkOnly := flag.Bool("check", false, "validate the configuration and exit without starting the server")
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "YAML configuration file; environment variables override its settings")
+	seedFile := flag.String("seed", os.Getenv("SEED_FILE"), "JSON fixtures of workspaces, API keys and tasks to load at startup (memory storage only)")
+	devMode := flag.Bool("dev", false, "development mode: serve POST /admin/seed, which replaces all data with fixtures (memory storage only)")
+	flag.Parse()
 
-	// Initialize handlers
-	taskHandler := handlers.NewTaskHandler(repo)
+	cfg, errs := config.Load(*configFile, *personalMode)
+	if *checkOnly {
+		if !startup.CheckConfig(os.Stdout, cfg, errs) {
+			os.Exit(1)
+		}
+		return
+	}
 
-	// Create server
-	srv := server.NewServer(taskHandler)
+	// The level can be changed at /admin/loglevel while the server runs.
+	// Personal data fields are masked in every record, not only in the
+	// request log.
+	logLevel := new(slog.LevelVar)
+	logLevel.Set(cfg.Log.Level)
+	redactor := redact.New(cfg.Log.Redact)
+	newLogger := func(w io.Writer) *slog.Logger {
+		return slog.New(redact.Handler(logging.New(w, logLevel).Handler(), redactor))
+	}
+	slog.SetDefault(newLogger(os.Stderr))
+	if len(errs) > 0 {
+		fatal("invalid configuration", errors.Join(errs...))
+	}
+	if cfg.Log.File != "" {
+		logFile, err := logging.OpenRotating(cfg.Log.File, int64(cfg.Log.MaxSizeMB)<<20, cfg.Log.MaxAge, cfg.Log.MaxBackups)
+		if err != nil {
+			fatal("opening log file", err)
+		}
+		defer logFile.Close()
+		slog.SetDefault(newLogger(logFile))
+	}
 
 	// Create context that listens for interrupt signals
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	// Run server
-	if err := srv.Run(ctx, port); err != nil {
-		log.Fatal(err)
+	// Tracing is configured from the standard OTEL_* environment variables
+	shutdownTracing, err := tracing.Setup(ctx, "cert-tasks")
+	if err != nil {
+		fatal("configuring tracing", err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			slog.Error("shutting down tracing", slog.Any("error", err))
+		}
+	}()
+
+	// Sockets passed by systemd replace the configured addresses
+	sockets, err := systemd.Listeners()
+	if err != nil {
+		fatal("taking sockets from systemd", err)
+	}
+	for name, l := range sockets {
+		if name != systemd.API && name != systemd.Redirect && name != systemd.Admin {
+			slog.Warn("ignoring socket from systemd", slog.String("name", name))
+			l.Close()
+		}
+	}
+
+	// Under systemd, Type=notify services report when they accept
+	// connections and when they start shutting down
+	go func() {
+		<-ctx.Done()
+		systemd.Notify(systemd.Stopping)
+	}()
+
+	opts := []tasks.Option{
+		tasks.WithLogLevel(logLevel),
+		tasks.WithListeners(sockets[systemd.API], sockets[systemd.Redirect], sockets[systemd.Admin]),
+		tasks.WithReady(func() {
+			if err := systemd.Notify(systemd.Ready); err != nil {
+				slog.Warn("notifying systemd", slog.Any("error", err))
+			}
+		}),
+	}
+	if *seedFile != "" {
+		opts = append(opts, tasks.WithSeedFile(*seedFile))
+	}
+	if *devMode {
+		opts = append(opts, tasks.WithDevMode())
 	}
+
+	// The server is the one other programs embed through package tasks
+	if err := tasks.Run(ctx, cfg, opts...); err != nil {
+		fatal("server failed", err)
+	}
+}
+
+// fatal logs err and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, slog.Any("error", err))
+	os.Exit(1)
 }
@@ -0,0 +1,7 @@
+package main
+
+// Plugins are compiled in: import each plugin's package here for its
+// side effects, then enable it under plugins in the configuration. For
+// example:
+//
+//	import _ "example.com/cert-tasks-audit-mirror"
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/light-bringer/cert-tasks/client"
+)
+
+// listLimit is the most tasks a /list reply shows
+const listLimit = 20
+
+// helpText answers /help and /start
+const helpText = `Commands:
+/add TITLE - create a task
+/list [todo|done|all] - list tasks, those to do by default
+/done ID - mark a task as done
+/workspace - show the workspace this chat uses`
+
+// bot answers chat commands by calling the API in the workspace mapped to
+// each chat
+type bot struct {
+	server           string
+	apiKey           string
+	chats            map[string]string
+	defaultWorkspace string
+}
+
+func newBot(server, apiKey string, chats map[string]string, defaultWorkspace string) (*bot, error) {
+	// Fail at start, not on the first message
+	if _, err := client.New(server); err != nil {
+		return nil, err
+	}
+	return &bot{server: server, apiKey: apiKey, chats: chats, defaultWorkspace: defaultWorkspace}, nil
+}
+
+// parseChats parses a comma-separated list of platform:chat=workspace
+// entries, as in "telegram:12345=acme,discord:67890=ops"
+func parseChats(s string) (map[string]string, error) {
+	chats := make(map[string]string)
+	if s == "" {
+		return chats, nil
+	}
+	for _, entry := range strings.Split(s, ",") {
+		chat, workspace, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		platform, id, hasPlatform := strings.Cut(chat, ":")
+		if !ok || !hasPlatform || (platform != "telegram" && platform != "discord") || id == "" || workspace == "" {
+			return nil, fmt.Errorf("%q is not telegram:CHAT=WORKSPACE or discord:CHANNEL=WORKSPACE", entry)
+		}
+		chats[chat] = workspace
+	}
+	return chats, nil
+}
+
+// handle runs the command in text, sent in chat ("telegram:12345" or
+// "discord:67890"), and returns the reply. Text that is not a command gets
+// no reply.
+func (b *bot) handle(ctx context.Context, chat, text string) string {
+	name, args, _ := strings.Cut(strings.TrimSpace(text), " ")
+	if !strings.HasPrefix(name, "/") {
+		return ""
+	}
+	// Telegram addresses commands in groups as /add@SomeBot
+	name, _, _ = strings.Cut(strings.TrimPrefix(name, "/"), "@")
+	args = strings.TrimSpace(args)
+
+	if name == "help" || name == "start" {
+		return helpText
+	}
+
+	workspace, ok := b.chats[chat]
+	if !ok {
+		workspace = b.defaultWorkspace
+	}
+	if workspace == "" {
+		return "This chat is not linked to a workspace. Ask the operator to add " + chat + " to BOT_CHATS."
+	}
+	c, _ := client.New(b.server, client.WithAPIKey(b.apiKey), client.WithWorkspace(workspace), client.WithUserAgent("cert-tasks-bot"))
+
+	var reply string
+	var err error
+	switch name {
+	case "add":
+		reply, err = add(ctx, c, args)
+	case "list":
+		reply, err = list(ctx, c, args)
+	case "done":
+		reply, err = done(ctx, c, args)
+	case "workspace":
+		reply = "This chat uses workspace " + workspace + "."
+	default:
+		reply = "Unknown command /" + name + ". Try /help."
+	}
+	if err != nil {
+		return errorReply(err)
+	}
+	return reply
+}
+
+func add(ctx context.Context, c *client.Client, title string) (string, error) {
+	if title == "" {
+		return "Usage: /add TITLE", nil
+	}
+	task, err := c.CreateTask(ctx, client.CreateTaskRequest{Title: title})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Created #%d: %s", task.ID, task.Title), nil
+}
+
+func list(ctx context.Context, c *client.Client, status string) (string, error) {
+	switch status {
+	case "":
+		status = string(client.StatusTodo)
+	case string(client.StatusTodo), string(client.StatusDone), "all":
+	default:
+		return "Usage: /list [todo|done|all]", nil
+	}
+
+	var lines []string
+	more := 0
+	for task, err := range c.Tasks(ctx, client.TaskQuery{}) {
+		if err != nil {
+			return "", err
+		}
+		if status != "all" && string(task.Status) != status {
+			continue
+		}
+		if len(lines) == listLimit {
+			more++
+			continue
+		}
+		line := fmt.Sprintf("#%d %s", task.ID, task.Title)
+		if task.Status == client.StatusDone {
+			line += " (done)"
+		} else if task.DueAt != nil {
+			line += " (due " + task.DueAt.Format("2 Jan 2006") + ")"
+		}
+		lines = append(lines, line)
+	}
+
+	if len(lines) == 0 {
+		return "No tasks.", nil
+	}
+	if more > 0 {
+		lines = append(lines, fmt.Sprintf("…and %d more", more))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func done(ctx context.Context, c *client.Client, arg string) (string, error) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(arg, "#"), 10, 64)
+	if err != nil || id < 1 {
+		return "Usage: /done ID", nil
+	}
+	task, err := c.CompleteTask(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Completed #%d: %s", task.ID, task.Title), nil
+}
+
+// errorReply turns an API error into a message for the chat
+func errorReply(err error) string {
+	var apiErr *client.APIError
+	if errors.As(err, &apiErr) {
+		if len(apiErr.Fields) > 0 {
+			return "Sorry, " + apiErr.Fields[0].Message
+		}
+		return "Sorry, " + apiErr.Message
+	}
+	slog.Warn("calling the task API failed", slog.Any("error", err))
+	return "Sorry, the task server is not reachable right now."
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Discord interaction and response types
+const (
+	discordPing               = 1
+	discordApplicationCommand = 2
+
+	discordPong           = 1
+	discordChannelMessage = 4
+)
+
+// discordDeadline leaves time to respond within Discord's 3 seconds
+const discordDeadline = 2500 * time.Millisecond
+
+// discordCommands are the slash commands to register for the application,
+// as the body of Discord's bulk overwrite endpoint; --discord-commands
+// prints them
+const discordCommands = `[
+  {"name": "add", "description": "Create a task", "options": [{"type": 3, "name": "title", "description": "Task title", "required": true}]},
+  {"name": "list", "description": "List tasks", "options": [{"type": 3, "name": "status", "description": "todo, done or all", "choices": [{"name": "todo", "value": "todo"}, {"name": "done", "value": "done"}, {"name": "all", "value": "all"}]}]},
+  {"name": "done", "description": "Mark a task as done", "options": [{"type": 4, "name": "id", "description": "Task ID", "required": true}]},
+  {"name": "workspace", "description": "Show the workspace this channel uses"},
+  {"name": "help", "description": "List the commands"}
+]`
+
+// discord serves the application's interactions endpoint: Discord POSTs
+// each slash command to it, signed with the application's key, and the
+// reply is the response
+type discord struct {
+	publicKey ed25519.PublicKey
+	bot       *bot
+}
+
+func newDiscord(publicKey string, b *bot) (*discord, error) {
+	key, err := hex.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%q is not a hex Ed25519 public key", publicKey)
+	}
+	return &discord{publicKey: key, bot: b}, nil
+}
+
+// discordInteraction is the part of an interaction the bot reads
+type discordInteraction struct {
+	Type      int    `json:"type"`
+	ChannelID string `json:"channel_id"`
+	Data      struct {
+		Name    string `json:"name"`
+		Options []struct {
+			Value json.RawMessage `json:"value"`
+		} `json:"options"`
+	} `json:"data"`
+}
+
+func (d *discord) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// Discord checks that unsigned requests are rejected
+	sig, err := hex.DecodeString(r.Header.Get("X-Signature-Ed25519"))
+	timestamp := r.Header.Get("X-Signature-Timestamp")
+	if err != nil || !ed25519.Verify(d.publicKey, append([]byte(timestamp), body...), sig) {
+		http.Error(w, "invalid request signature", http.StatusUnauthorized)
+		return
+	}
+
+	var in discordInteraction
+	if err := json.Unmarshal(body, &in); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var resp any
+	switch in.Type {
+	case discordPing:
+		resp = map[string]int{"type": discordPong}
+	case discordApplicationCommand:
+		// Options become arguments, as if the command had been typed
+		text := []string{"/" + in.Data.Name}
+		for _, opt := range in.Data.Options {
+			// Strings are quoted; integers, such as task IDs, are used as sent
+			var arg string
+			if json.Unmarshal(opt.Value, &arg) != nil {
+				arg = string(opt.Value)
+			}
+			text = append(text, arg)
+		}
+		// Discord drops responses that take longer than 3 seconds
+		ctx, cancel := context.WithTimeout(r.Context(), discordDeadline)
+		defer cancel()
+		reply := d.bot.handle(ctx, "discord:"+in.ChannelID, strings.Join(text, " "))
+		resp = map[string]any{
+			"type": discordChannelMessage,
+			"data": map[string]string{"content": reply},
+		}
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,115 @@
+// Command bot lets chat users create, list and complete tasks from
+// Telegram and Discord. It talks to the API through the client package,
+// like taskctl, so it runs anywhere the API is reachable. Each chat is
+// mapped to a workspace by the operator; settings come from flags or the
+// BOT_* environment variables.
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// config holds the bot's settings
+type config struct {
+	server           string
+	apiKey           string
+	chats            string
+	defaultWorkspace string
+	telegramToken    string
+	discordPublicKey string
+	discordAddr      string
+}
+
+func main() {
+	var cfg config
+	flag.StringVar(&cfg.server, "server", cmp.Or(os.Getenv("BOT_SERVER"), "http://localhost:8080"), "API base URL (BOT_SERVER)")
+	flag.StringVar(&cfg.apiKey, "api-key", os.Getenv("BOT_API_KEY"), "API key the bot acts with (BOT_API_KEY)")
+	flag.StringVar(&cfg.chats, "chats", os.Getenv("BOT_CHATS"), "chat to workspace mapping, e.g. telegram:12345=acme,discord:67890=ops (BOT_CHATS)")
+	flag.StringVar(&cfg.defaultWorkspace, "default-workspace", os.Getenv("BOT_DEFAULT_WORKSPACE"), "workspace of chats not in --chats; empty ignores them (BOT_DEFAULT_WORKSPACE)")
+	flag.StringVar(&cfg.telegramToken, "telegram-token", os.Getenv("BOT_TELEGRAM_TOKEN"), "Telegram bot token; empty disables Telegram (BOT_TELEGRAM_TOKEN)")
+	flag.StringVar(&cfg.discordPublicKey, "discord-public-key", os.Getenv("BOT_DISCORD_PUBLIC_KEY"), "Discord application public key; empty disables Discord (BOT_DISCORD_PUBLIC_KEY)")
+	flag.StringVar(&cfg.discordAddr, "discord-addr", cmp.Or(os.Getenv("BOT_DISCORD_ADDR"), ":8081"), "listen address of the Discord interactions endpoint (BOT_DISCORD_ADDR)")
+	printCommands := flag.Bool("discord-commands", false, "print the Discord slash commands to register and exit")
+	flag.Parse()
+
+	if *printCommands {
+		fmt.Println(discordCommands)
+		return
+	}
+
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, cfg); err != nil {
+		slog.Error("bot failed", slog.Any("error", err))
+		os.Exit(1)
+	}
+}
+
+// run starts the configured platforms and blocks until ctx is done
+func run(ctx context.Context, cfg config) error {
+	if cfg.telegramToken == "" && cfg.discordPublicKey == "" {
+		return errors.New("set --telegram-token or --discord-public-key")
+	}
+	chats, err := parseChats(cfg.chats)
+	if err != nil {
+		return fmt.Errorf("--chats: %w", err)
+	}
+	b, err := newBot(cfg.server, cfg.apiKey, chats, cfg.defaultWorkspace)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	if cfg.telegramToken != "" {
+		tg := newTelegram(telegramAPI, cfg.telegramToken, b)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tg.run(ctx)
+		}()
+		slog.Info("polling Telegram")
+	}
+	if cfg.discordPublicKey != "" {
+		dc, err := newDiscord(cfg.discordPublicKey, b)
+		if err != nil {
+			return fmt.Errorf("--discord-public-key: %w", err)
+		}
+		srv := &http.Server{Addr: cfg.discordAddr, Handler: dc, ReadHeaderTimeout: 10 * time.Second}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				errs <- fmt.Errorf("discord endpoint: %w", err)
+			}
+		}()
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			srv.Shutdown(shutdownCtx)
+		}()
+		slog.Info("serving Discord interactions", slog.String("addr", cfg.discordAddr))
+	}
+
+	select {
+	case <-ctx.Done():
+		wg.Wait()
+		return nil
+	case err := <-errs:
+		return err
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/client"
+	"github.com/light-bringer/cert-tasks/tasktest"
+)
+
+// newTestBot returns a bot for srv with telegram:1 mapped to workspace
+// acme and other chats ignored
+func newTestBot(t *testing.T, srv *tasktest.Server) *bot {
+	t.Helper()
+	ctx := context.Background()
+	admin := srv.Client()
+	if _, err := admin.CreateWorkspace(ctx, client.CreateWorkspaceRequest{ID: "acme", Name: "Acme"}); err != nil {
+		t.Fatal(err)
+	}
+	key, err := admin.CreateAPIKey(ctx, client.CreateAPIKeyRequest{Name: "bot", Scope: client.ScopeReadWrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chats, err := parseChats("telegram:1=acme, discord:42=acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := newBot(srv.URL, key.Key, chats, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestBot_Commands(t *testing.T) {
+	srv := tasktest.NewServer(t, tasktest.WithAuth())
+	b := newTestBot(t, srv)
+	ctx := context.Background()
+
+	steps := []struct {
+		chat, text, want string
+	}{
+		{"telegram:1", "/add Renew cert", "Created #1: Renew cert"},
+		{"telegram:1", "/add@TasksBot Rotate keys", "Created #2: Rotate keys"},
+		{"telegram:1", "/done 1", "Completed #1: Renew cert"},
+		{"telegram:1", "/list", "#2 Rotate keys"},
+		{"telegram:1", "/list all", "#1 Renew cert (done)\n#2 Rotate keys"},
+		{"telegram:1", "/list soon", "Usage: /list [todo|done|all]"},
+		{"telegram:1", "/done 99", "Sorry, task not found"},
+		{"telegram:1", "/add", "Usage: /add TITLE"},
+		{"telegram:1", "/workspace", "This chat uses workspace acme."},
+		{"telegram:1", "/archive 2", "Unknown command /archive. Try /help."},
+		{"telegram:1", "hello", ""},
+		{"telegram:2", "/list", "This chat is not linked to a workspace. Ask the operator to add telegram:2 to BOT_CHATS."},
+	}
+	for _, step := range steps {
+		if got := b.handle(ctx, step.chat, step.text); got != step.want {
+			t.Errorf("%s %q = %q, want %q", step.chat, step.text, got, step.want)
+		}
+	}
+
+	// Unmapped chats use the default workspace, which the tasks above
+	// were not created in
+	fallback := *b
+	fallback.defaultWorkspace = "default"
+	if got := fallback.handle(ctx, "telegram:2", "/list all"); got != "No tasks." {
+		t.Errorf("/list all in the default workspace = %q, want none", got)
+	}
+
+	if _, err := parseChats("slack:1=acme"); err == nil {
+		t.Error("parseChats accepted an unknown platform")
+	}
+}
+
+func TestTelegram(t *testing.T) {
+	srv := tasktest.NewServer(t, tasktest.WithAuth())
+	b := newTestBot(t, srv)
+
+	// A fake Bot API serving one message, then no more
+	var mu sync.Mutex
+	var offsets []string
+	sent := make(chan map[string]string, 1)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bottoken/getUpdates":
+			mu.Lock()
+			offsets = append(offsets, r.URL.Query().Get("offset"))
+			first := len(offsets) == 1
+			mu.Unlock()
+			if first {
+				io.WriteString(w, `{"ok":true,"result":[{"update_id":7,"message":{"text":"/add Water plants","chat":{"id":1}}}]}`)
+				return
+			}
+			select {
+			case <-r.Context().Done():
+			case <-time.After(50 * time.Millisecond):
+			}
+			io.WriteString(w, `{"ok":true,"result":[]}`)
+		case "/bottoken/sendMessage":
+			var msg map[string]string
+			json.NewDecoder(r.Body).Decode(&msg)
+			sent <- msg
+			io.WriteString(w, `{"ok":true,"result":{}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		newTelegram(api.URL+"/bot", "token", b).run(ctx)
+		close(done)
+	}()
+
+	select {
+	case msg := <-sent:
+		if msg["chat_id"] != "1" || msg["text"] != "Created #1: Water plants" {
+			t.Errorf("sendMessage = %v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reply sent")
+	}
+
+	// The next poll acknowledges the update
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		polled := append([]string(nil), offsets...)
+		mu.Unlock()
+		if len(polled) >= 2 {
+			if polled[1] != "8" {
+				t.Errorf("getUpdates offsets = %v, want the update acknowledged with 8", polled)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("getUpdates offsets = %v, want a second poll", polled)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+}
+
+func TestDiscord(t *testing.T) {
+	srv := tasktest.NewServer(t, tasktest.WithAuth())
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	d, err := newDiscord(hex.EncodeToString(pub), newTestBot(t, srv))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(body string, key ed25519.PrivateKey) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		timestamp := "1700000000"
+		req.Header.Set("X-Signature-Timestamp", timestamp)
+		req.Header.Set("X-Signature-Ed25519", hex.EncodeToString(ed25519.Sign(key, []byte(timestamp+body))))
+		rec := httptest.NewRecorder()
+		d.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := post(`{"type":1}`, priv); rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `{"type":1}` {
+		t.Errorf("ping = %v %s", rec.Code, rec.Body)
+	}
+
+	_, other, _ := ed25519.GenerateKey(rand.Reader)
+	if rec := post(`{"type":1}`, other); rec.Code != http.StatusUnauthorized {
+		t.Errorf("wrongly signed ping = %v, want %v", rec.Code, http.StatusUnauthorized)
+	}
+
+	post(`{"type":2,"channel_id":"42","data":{"name":"add","options":[{"name":"title","value":"Plan sprint"}]}}`, priv)
+	rec := post(`{"type":2,"channel_id":"42","data":{"name":"done","options":[{"name":"id","value":1}]}}`, priv)
+	var resp struct {
+		Type int `json:"type"`
+		Data struct {
+			Content string `json:"content"`
+		} `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.Type != discordChannelMessage || resp.Data.Content != "Completed #1: Plan sprint" {
+		t.Errorf("done = %+v", resp)
+	}
+
+	if !json.Valid(bytes.TrimSpace([]byte(discordCommands))) {
+		t.Error("discordCommands is not valid JSON")
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// telegramAPI is the Bot API base URL; the token is appended
+const telegramAPI = "https://api.telegram.org/bot"
+
+// telegramPoll is how long a getUpdates call waits for messages
+const telegramPoll = 30 * time.Second
+
+// telegram receives messages by long polling the Bot API and answers them
+// in the same chat
+type telegram struct {
+	base   string
+	bot    *bot
+	client *http.Client
+
+	// retryAfter is the wait after a failed poll
+	retryAfter time.Duration
+}
+
+func newTelegram(api, token string, b *bot) *telegram {
+	return &telegram{
+		base:       api + token,
+		bot:        b,
+		client:     &http.Client{Timeout: telegramPoll + 10*time.Second},
+		retryAfter: 5 * time.Second,
+	}
+}
+
+// telegramUpdate is the part of a Bot API update the bot reads
+type telegramUpdate struct {
+	UpdateID int64 `json:"update_id"`
+	Message  *struct {
+		Text string `json:"text"`
+		Chat struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+	} `json:"message"`
+}
+
+// run polls for messages until ctx is done. Updates are acknowledged once
+// handled, so a message arriving during a restart is answered after it.
+func (t *telegram) run(ctx context.Context) {
+	var offset int64
+	for ctx.Err() == nil {
+		updates, err := t.updates(ctx, offset)
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Warn("polling Telegram failed", slog.Any("error", err))
+				select {
+				case <-ctx.Done():
+				case <-time.After(t.retryAfter):
+				}
+			}
+			continue
+		}
+
+		for _, u := range updates {
+			offset = u.UpdateID + 1
+			if u.Message == nil || u.Message.Text == "" {
+				continue
+			}
+			chatID := strconv.FormatInt(u.Message.Chat.ID, 10)
+			reply := t.bot.handle(ctx, "telegram:"+chatID, u.Message.Text)
+			if reply == "" {
+				continue
+			}
+			if err := t.send(ctx, chatID, reply); err != nil {
+				slog.Warn("replying on Telegram failed", slog.String("chat", chatID), slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// updates long polls for updates from offset on
+func (t *telegram) updates(ctx context.Context, offset int64) ([]telegramUpdate, error) {
+	query := url.Values{
+		"offset":          {strconv.FormatInt(offset, 10)},
+		"timeout":         {strconv.Itoa(int(telegramPoll.Seconds()))},
+		"allowed_updates": {`["message"]`},
+	}
+	var updates []telegramUpdate
+	err := t.call(ctx, http.MethodGet, "/getUpdates?"+query.Encode(), nil, &updates)
+	return updates, err
+}
+
+// send posts text to a chat
+func (t *telegram) send(ctx context.Context, chatID, text string) error {
+	body, _ := json.Marshal(map[string]string{"chat_id": chatID, "text": text})
+	return t.call(ctx, http.MethodPost, "/sendMessage", body, nil)
+}
+
+// call makes a Bot API request and decodes its result into v
+func (t *telegram) call(ctx context.Context, method, path string, body []byte, v any) error {
+	req, err := http.NewRequestWithContext(ctx, method, t.base+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		// The error names the URL, which holds the token
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	var envelope struct {
+		OK          bool            `json:"ok"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return fmt.Errorf("telegram: status %d: %w", resp.StatusCode, err)
+	}
+	if !envelope.OK {
+		return fmt.Errorf("telegram: %s", envelope.Description)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(envelope.Result, v)
+}
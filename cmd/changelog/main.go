@@ -0,0 +1,29 @@
+// Command changelog regenerates the API changelog from the //api:changelog
+// annotations in the source tree. It is normally run through go generate.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/light-bringer/cert-tasks/internal/changelog"
+)
+
+func main() {
+	root := flag.String("root", ".", "module root to scan for annotations")
+	out := flag.String("out", "internal/changelog/changelog.json", "file to write the changelog to")
+	flag.Parse()
+
+	c, err := changelog.Collect(*root)
+	if err != nil {
+		log.Fatal(err)
+	}
+	data, err := c.Marshal()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, data, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/client"
+	"github.com/light-bringer/cert-tasks/internal/config"
+	"github.com/light-bringer/cert-tasks/internal/startup"
+	"github.com/spf13/cobra"
+)
+
+func (a *app) listCmd() *cobra.Command {
+	var status, search string
+	var limit int
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List tasks",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkStatus(status); err != nil {
+				return err
+			}
+			c, err := a.client()
+			if err != nil {
+				return err
+			}
+
+			tasks := []client.Task{}
+			for task, err := range c.Tasks(cmd.Context(), client.TaskQuery{Search: search}) {
+				if err != nil {
+					return err
+				}
+				if status != "" && task.Status != client.TaskStatus(status) {
+					continue
+				}
+				tasks = append(tasks, *task)
+				if limit > 0 && len(tasks) == limit {
+					break
+				}
+			}
+
+			if a.output == outputJSON {
+				return a.printJSON(tasks)
+			}
+			return a.printTasks(tasks...)
+		},
+	}
+	cmd.Flags().StringVar(&status, "status", "", "only tasks with this status: todo or done")
+	cmd.Flags().StringVarP(&search, "search", "q", "", "full-text search query")
+	cmd.Flags().IntVar(&limit, "limit", 0, "list at most this many tasks; 0 lists all")
+	return cmd
+}
+
+func (a *app) createCmd() *cobra.Command {
+	var description, due string
+
+	cmd := &cobra.Command{
+		Use:   "create TITLE",
+		Short: "Create a task",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req := client.CreateTaskRequest{Title: args[0], Description: description}
+			if due != "" {
+				t, err := parseDue(due)
+				if err != nil {
+					return err
+				}
+				req.DueAt = &t
+			}
+
+			c, err := a.client()
+			if err != nil {
+				return err
+			}
+			task, err := c.CreateTask(cmd.Context(), req)
+			if err != nil {
+				return err
+			}
+			return a.printTask(task)
+		},
+	}
+	cmd.Flags().StringVarP(&description, "description", "d", "", "task description")
+	cmd.Flags().StringVar(&due, "due", "", "due date, as 2006-01-02 or an RFC 3339 time")
+	return cmd
+}
+
+func (a *app) doneCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "done ID...",
+		Short: "Mark tasks as done",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ids, err := parseIDs(args)
+			if err != nil {
+				return err
+			}
+			c, err := a.client()
+			if err != nil {
+				return err
+			}
+
+			for _, id := range ids {
+				task, err := c.CompleteTask(cmd.Context(), id)
+				if err != nil {
+					return err
+				}
+				if err := a.printTask(task); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func (a *app) deleteCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete ID...",
+		Short: "Delete tasks",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ids, err := parseIDs(args)
+			if err != nil {
+				return err
+			}
+			c, err := a.client()
+			if err != nil {
+				return err
+			}
+
+			for _, id := range ids {
+				if err := c.DeleteTask(cmd.Context(), id); err != nil {
+					return err
+				}
+				if a.output == outputTable {
+					fmt.Fprintf(a.out, "deleted %d\n", id)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func (a *app) exportCmd() *cobra.Command {
+	var format, status, search, file string
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export tasks as CSV or NDJSON",
+		Long:  "Export tasks as CSV or NDJSON. The NDJSON export is a backup that POST /tasks/import?format=ndjson restores.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != client.FormatCSV && format != client.FormatNDJSON {
+				return fmt.Errorf("--format must be %q or %q", client.FormatCSV, client.FormatNDJSON)
+			}
+			if err := checkStatus(status); err != nil {
+				return err
+			}
+			c, err := a.client()
+			if err != nil {
+				return err
+			}
+
+			body, err := c.ExportTasks(cmd.Context(), format, client.TaskFilter{Status: client.TaskStatus(status), Search: search})
+			if err != nil {
+				return err
+			}
+			defer body.Close()
+
+			if file == "" {
+				_, err = io.Copy(a.out, body)
+				return err
+			}
+			f, err := os.Create(file)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(f, body); err != nil {
+				f.Close()
+				return err
+			}
+			return f.Close()
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", client.FormatCSV, "csv or ndjson")
+	cmd.Flags().StringVar(&status, "status", "", "only tasks with this status: todo or done")
+	cmd.Flags().StringVarP(&search, "search", "q", "", "full-text search query")
+	cmd.Flags().StringVarP(&file, "file", "f", "", "write to this file instead of stdout")
+	return cmd
+}
+
+func (a *app) watchCmd() *cobra.Command {
+	var events []string
+	var taskIDs []int64
+
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Print task events as they happen",
+		Long:  "Print task events as they happen, until interrupted. With --output json, each event is printed as one line of JSON.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts := client.WatchOptions{TaskIDs: taskIDs}
+			for _, e := range events {
+				if !client.TaskEventType(e).IsValid() {
+					return fmt.Errorf("unknown event %q", e)
+				}
+				opts.Events = append(opts.Events, client.TaskEventType(e))
+			}
+			c, err := a.client()
+			if err != nil {
+				return err
+			}
+
+			enc := json.NewEncoder(a.out)
+			for event, err := range c.Watch(cmd.Context(), opts) {
+				if err != nil {
+					return err
+				}
+				if a.output == outputJSON {
+					if err := enc.Encode(event); err != nil {
+						return err
+					}
+					continue
+				}
+				fmt.Fprintf(a.out, "%s  %-15s %d  %s\n",
+					event.CreatedAt.Local().Format(time.TimeOnly), event.Type, event.Task.ID, event.Task.Title)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringSliceVar(&events, "event", nil, "only these events, e.g. task.created,task.completed")
+	cmd.Flags().Int64SliceVar(&taskIDs, "task", nil, "only events of these task IDs")
+	return cmd
+}
+
+func (a *app) configCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Work with the server configuration",
+	}
+
+	var file string
+	var personalMode bool
+	validate := &cobra.Command{
+		Use:   "validate",
+		Short: "Check the server configuration without starting the server",
+		Long: "Load the server configuration from --config and the environment, as the server would, and check it and what it points at: " +
+			"the listen addresses, TLS material, the storage and the webhook URLs stored in it. " +
+			"Prints one line per check, the same as api --check, and fails if any check failed.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, errs := config.Load(file, personalMode)
+			if !startup.CheckConfig(a.out, cfg, errs) {
+				return errors.New("configuration check failed")
+			}
+			return nil
+		},
+	}
+	validate.Flags().StringVar(&file, "config", os.Getenv("CONFIG_FILE"), "YAML configuration file (CONFIG_FILE); environment variables override its settings")
+	validate.Flags().BoolVar(&personalMode, "personal", false, "check the personal app configuration")
+	cmd.AddCommand(validate)
+	return cmd
+}
+
+// printTask writes one task in the output format
+func (a *app) printTask(task *client.Task) error {
+	if a.output == outputJSON {
+		return a.printJSON(task)
+	}
+	return a.printTasks(*task)
+}
+
+// printTasks writes tasks as a table
+func (a *app) printTasks(tasks ...client.Task) error {
+	w := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATUS\tDUE\tTITLE")
+	for _, t := range tasks {
+		due := "-"
+		if t.DueAt != nil {
+			due = t.DueAt.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", t.ID, t.Status, due, t.Title)
+	}
+	return w.Flush()
+}
+
+// checkStatus validates a --status flag; empty means any status
+func checkStatus(status string) error {
+	switch client.TaskStatus(status) {
+	case "", client.StatusTodo, client.StatusDone:
+		return nil
+	}
+	return fmt.Errorf("--status must be %q or %q", client.StatusTodo, client.StatusDone)
+}
+
+// parseDue accepts a date, due at midnight UTC, or an RFC 3339 time
+func parseDue(s string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("--due must be a date such as 2026-01-02 or an RFC 3339 time")
+	}
+	return t, nil
+}
+
+func parseIDs(args []string) ([]int64, error) {
+	ids := make([]int64, len(args))
+	for i, arg := range args {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil || id < 1 {
+			return nil, fmt.Errorf("%q is not a task ID", arg)
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
@@ -0,0 +1,97 @@
+// Command taskctl manages tasks on a running server from the command line.
+// It talks to the API through the client package; the server, API key and
+// workspace come from flags or the TASKCTL_* environment variables.
+// taskctl config validate checks a server configuration instead, like
+// api --check.
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/light-bringer/cert-tasks/client"
+	"github.com/spf13/cobra"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := newRootCmd(os.Stdout).ExecuteContext(ctx); err != nil {
+		os.Exit(1)
+	}
+}
+
+// Output modes
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// app holds the global flags shared by every subcommand
+type app struct {
+	out       io.Writer
+	server    string
+	apiKey    string
+	workspace string
+	output    string
+}
+
+func newRootCmd(out io.Writer) *cobra.Command {
+	a := &app{out: out}
+
+	root := &cobra.Command{
+		Use:          "taskctl",
+		Short:        "Manage tasks on a cert-tasks server",
+		SilenceUsage: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if a.output != outputTable && a.output != outputJSON {
+				return fmt.Errorf("--output must be %q or %q", outputTable, outputJSON)
+			}
+			return nil
+		},
+	}
+	root.SetOut(out)
+
+	flags := root.PersistentFlags()
+	flags.StringVar(&a.server, "server", cmp.Or(os.Getenv("TASKCTL_SERVER"), "http://localhost:8080"), "API base URL (TASKCTL_SERVER)")
+	flags.StringVar(&a.apiKey, "api-key", os.Getenv("TASKCTL_API_KEY"), "API key or JWT (TASKCTL_API_KEY)")
+	flags.StringVar(&a.workspace, "workspace", os.Getenv("TASKCTL_WORKSPACE"), "workspace to act in (TASKCTL_WORKSPACE)")
+	flags.StringVarP(&a.output, "output", "o", outputTable, "output format: table or json")
+
+	root.AddCommand(
+		a.listCmd(),
+		a.createCmd(),
+		a.doneCmd(),
+		a.deleteCmd(),
+		a.exportCmd(),
+		a.watchCmd(),
+		a.configCmd(),
+	)
+	return root
+}
+
+// client creates an API client from the global flags
+func (a *app) client() (*client.Client, error) {
+	var opts []client.Option
+	if a.apiKey != "" {
+		opts = append(opts, client.WithAPIKey(a.apiKey))
+	}
+	if a.workspace != "" {
+		opts = append(opts, client.WithWorkspace(a.workspace))
+	}
+	return client.New(a.server, append(opts, client.WithUserAgent("taskctl"))...)
+}
+
+// printJSON writes v as indented JSON
+func (a *app) printJSON(v any) error {
+	enc := json.NewEncoder(a.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/tasktest"
+)
+
+// run executes taskctl with args against srv and returns its output
+func run(t *testing.T, srv *tasktest.Server, args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	cmd := newRootCmd(&out)
+	cmd.SetErr(&out)
+	cmd.SetArgs(append([]string{"--server", srv.URL}, args...))
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func TestCommands(t *testing.T) {
+	srv := tasktest.NewServer(t)
+
+	for _, title := range []string{"Renew cert", "Rotate keys"} {
+		if out, err := run(t, srv, "create", title, "--due", "2026-03-01"); err != nil {
+			t.Fatalf("create %q: %v\n%s", title, err, out)
+		}
+	}
+
+	out, err := run(t, srv, "done", "1")
+	if err != nil || !strings.Contains(out, "done") {
+		t.Fatalf("done 1 = %q, %v", out, err)
+	}
+
+	out, err = run(t, srv, "list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "ID") || !strings.Contains(lines[2], "Rotate keys") {
+		t.Errorf("list table:\n%s", out)
+	}
+
+	out, err = run(t, srv, "list", "-o", "json", "--status", "todo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tasks []models.Task
+	if err := json.Unmarshal([]byte(out), &tasks); err != nil || len(tasks) != 1 || tasks[0].Title != "Rotate keys" {
+		t.Errorf("list -o json --status todo = %s, %v", out, err)
+	}
+	if tasks[0].DueAt == nil || !tasks[0].DueAt.Equal(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("due_at = %v, want 2026-03-01", tasks[0].DueAt)
+	}
+
+	file := filepath.Join(t.TempDir(), "tasks.ndjson")
+	if out, err := run(t, srv, "export", "--format", "ndjson", "-f", file); err != nil {
+		t.Fatalf("export: %v\n%s", err, out)
+	}
+	if b, _ := os.ReadFile(file); strings.Count(string(b), "\n") != 2 {
+		t.Errorf("export file:\n%s", b)
+	}
+
+	if out, err := run(t, srv, "delete", "1", "2"); err != nil {
+		t.Fatalf("delete: %v\n%s", err, out)
+	}
+	if out, err := run(t, srv, "done", "1"); err == nil || !strings.Contains(out, "not found") {
+		t.Errorf("done on deleted task = %q, %v", out, err)
+	}
+}
+
+func TestCommands_InvalidFlags(t *testing.T) {
+	srv := tasktest.NewServer(t)
+
+	for _, args := range [][]string{
+		{"list", "-o", "yaml"},
+		{"list", "--status", "doing"},
+		{"create", "t", "--due", "tomorrow"},
+		{"delete", "abc"},
+		{"export", "--format", "xml"},
+		{"watch", "--event", "task.exploded"},
+	} {
+		if _, err := run(t, srv, args...); err == nil {
+			t.Errorf("taskctl %s succeeded", strings.Join(args, " "))
+		}
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for a writer and a reader goroutine
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestWatch(t *testing.T) {
+	srv := tasktest.NewServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var out syncBuffer
+	cmd := newRootCmd(&out)
+	cmd.SetArgs([]string{"--server", srv.URL, "watch", "-o", "json", "--event", "task.created"})
+	done := make(chan error, 1)
+	go func() { done <- cmd.ExecuteContext(ctx) }()
+
+	// Events published before the subscription lands are missed, so keep
+	// creating tasks until one shows up
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(out.String(), `"task.created"`) {
+		if time.Now().After(deadline) {
+			t.Fatalf("no event received; output:\n%s", out.String())
+		}
+		if _, err := run(t, srv, "create", "Watched"); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("watch returned %v after cancel", err)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	os.WriteFile(file, []byte("server:\n  addr: 127.0.0.1:0\nstorage:\n  dsn: file://"+filepath.Join(dir, "tasks.json")+"\n"), 0o600)
+
+	var out bytes.Buffer
+	cmd := newRootCmd(&out)
+	cmd.SetErr(&out)
+	cmd.SetArgs([]string{"config", "validate", "--config", file})
+	if err := cmd.Execute(); err != nil || !strings.Contains(out.String(), "ok    storage file") {
+		t.Errorf("config validate = %v\n%s", err, out.String())
+	}
+
+	t.Setenv("CONTENT_POLICY_FILE", filepath.Join(dir, "missing.json"))
+	out.Reset()
+	cmd = newRootCmd(&out)
+	cmd.SetErr(&out)
+	cmd.SetArgs([]string{"config", "validate", "--config", file})
+	if err := cmd.Execute(); err == nil || !strings.Contains(out.String(), "FAIL  content policies") {
+		t.Errorf("config validate with a missing policy file = %v\n%s", err, out.String())
+	}
+}
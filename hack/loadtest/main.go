@@ -0,0 +1,301 @@
+// Command loadtest drives a running server with a weighted mix of task
+// requests and reports throughput and latency percentiles per operation.
+//
+//	go run ./hack/loadtest -url http://localhost:8080 -duration 30s -concurrency 64
+//
+// It seeds tasks to read and update first, then runs either closed-loop,
+// each worker sending its next request as soon as the last one returns,
+// or at a fixed total -rate. Pass -json for a machine-readable report to
+// compare between releases.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// options holds the command-line flags
+type options struct {
+	url         string
+	apiKey      string
+	workspace   string
+	duration    time.Duration
+	concurrency int
+	rate        float64
+	seed        int
+	mix         mix
+	json        bool
+}
+
+func main() {
+	cfg := options{mix: defaultMix()}
+	flag.StringVar(&cfg.url, "url", "http://localhost:8080", "server base URL")
+	flag.StringVar(&cfg.apiKey, "api-key", os.Getenv("LOADTEST_API_KEY"), "API key sent as X-API-Key, if the server requires one")
+	flag.StringVar(&cfg.workspace, "workspace", "", "workspace sent as X-Workspace-ID")
+	flag.DurationVar(&cfg.duration, "duration", 10*time.Second, "how long to send requests")
+	flag.IntVar(&cfg.concurrency, "concurrency", 16, "number of concurrent workers")
+	flag.Float64Var(&cfg.rate, "rate", 0, "total requests per second; 0 sends as fast as the workers can")
+	flag.IntVar(&cfg.seed, "seed", 100, "tasks to create before the run")
+	flag.Var(&cfg.mix, "mix", "operation weights, e.g. get=60,list=20,create=10,update=10")
+	flag.BoolVar(&cfg.json, "json", false, "print the report as JSON")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	report, err := run(ctx, cfg, http.DefaultClient)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "loadtest:", err)
+		os.Exit(1)
+	}
+	if cfg.json {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+		return
+	}
+	report.WriteText(os.Stdout)
+}
+
+// op names a kind of request in the mix
+type op string
+
+const (
+	opGet    op = "get"
+	opList   op = "list"
+	opSearch op = "search"
+	opCreate op = "create"
+	opUpdate op = "update"
+)
+
+// ops lists every operation in report order
+var ops = []op{opGet, opList, opSearch, opCreate, opUpdate}
+
+// mix is the relative weight of each operation
+type mix map[op]int
+
+func defaultMix() mix {
+	return mix{opGet: 60, opList: 20, opCreate: 10, opUpdate: 10}
+}
+
+// String formats the mix as the -mix flag takes it
+func (m mix) String() string {
+	var parts []string
+	for _, o := range ops {
+		if m[o] > 0 {
+			parts = append(parts, fmt.Sprintf("%s=%d", o, m[o]))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// Set parses a -mix flag value, replacing the defaults
+func (m mix) Set(s string) error {
+	clear(m)
+	for _, part := range strings.Split(s, ",") {
+		name, weight, ok := strings.Cut(strings.TrimSpace(part), "=")
+		n, err := strconv.Atoi(weight)
+		if !ok || err != nil || n < 0 {
+			return fmt.Errorf("%q is not op=weight", part)
+		}
+		known := false
+		for _, o := range ops {
+			known = known || op(name) == o
+		}
+		if !known {
+			return fmt.Errorf("unknown operation %q; use get, list, search, create or update", name)
+		}
+		m[op(name)] = n
+	}
+	total := 0
+	for _, n := range m {
+		total += n
+	}
+	if total == 0 {
+		return errors.New("the mix needs at least one positive weight")
+	}
+	return nil
+}
+
+// pick chooses an operation with probability proportional to its weight
+func (m mix) pick(rng *rand.Rand) op {
+	total := 0
+	for _, o := range ops {
+		total += m[o]
+	}
+	n := rng.IntN(total)
+	for _, o := range ops {
+		if n < m[o] {
+			return o
+		}
+		n -= m[o]
+	}
+	return opGet
+}
+
+// target sends requests to the server under test and remembers the tasks
+// it created, for reads and updates to pick from
+type target struct {
+	cfg    options
+	client *http.Client
+
+	mu  sync.Mutex
+	ids []int64
+}
+
+// run seeds the server, sends requests until the duration is up or ctx
+// ends, and reports on them
+func run(ctx context.Context, cfg options, client *http.Client) (*Report, error) {
+	t := &target{cfg: cfg, client: client}
+	for i := range cfg.seed {
+		if _, err := t.do(ctx, opCreate, rand.New(rand.NewPCG(uint64(i), 0))); err != nil {
+			return nil, fmt.Errorf("seeding task %d: %w", i+1, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.duration)
+	defer cancel()
+
+	// With a rate, workers wait for a tick before each request
+	var ticks <-chan time.Time
+	if cfg.rate > 0 {
+		ticker := time.NewTicker(max(time.Duration(float64(time.Second)/cfg.rate), time.Microsecond))
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	recorders := make([]*recorder, cfg.concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := range recorders {
+		rec := newRecorder()
+		recorders[i] = rec
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rng := rand.New(rand.NewPCG(rand.Uint64(), uint64(i)))
+			for {
+				if ticks != nil {
+					select {
+					case <-ticks:
+					case <-ctx.Done():
+						return
+					}
+				}
+				if ctx.Err() != nil {
+					return
+				}
+				o := cfg.mix.pick(rng)
+				began := time.Now()
+				status, err := t.do(ctx, o, rng)
+				if ctx.Err() != nil {
+					// Cut off by the end of the run, not by the server
+					return
+				}
+				rec.record(o, time.Since(began), status, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return newReport(time.Since(start), recorders), nil
+}
+
+// do sends one request of kind o and returns its status. Statuses of 400
+// and above are errors.
+func (t *target) do(ctx context.Context, o op, rng *rand.Rand) (int, error) {
+	var method, path string
+	var body any
+	switch o {
+	case opGet:
+		method, path = http.MethodGet, fmt.Sprintf("/tasks/%d", t.randomID(rng))
+	case opList:
+		method, path = http.MethodGet, "/tasks?limit=50&offset="+strconv.Itoa(rng.IntN(t.count()+1))
+	case opSearch:
+		method, path = http.MethodGet, "/tasks?q=load+"+strconv.Itoa(rng.IntN(10))
+	case opCreate:
+		method, path = http.MethodPost, "/tasks"
+		body = map[string]string{"title": fmt.Sprintf("load %d", rng.IntN(1000)), "description": "created by hack/loadtest"}
+	case opUpdate:
+		method, path = http.MethodPut, fmt.Sprintf("/tasks/%d", t.randomID(rng))
+		status := "todo"
+		if rng.IntN(2) == 0 {
+			status = "done"
+		}
+		body = map[string]string{"title": fmt.Sprintf("load %d", rng.IntN(1000)), "description": "updated by hack/loadtest", "status": status}
+	}
+
+	var payload io.Reader
+	if body != nil {
+		b, _ := json.Marshal(body)
+		payload = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(t.cfg.url, "/")+path, payload)
+	if err != nil {
+		return 0, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if t.cfg.apiKey != "" {
+		req.Header.Set("X-API-Key", t.cfg.apiKey)
+	}
+	if t.cfg.workspace != "" {
+		req.Header.Set("X-Workspace-ID", t.cfg.workspace)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if o == opCreate && resp.StatusCode == http.StatusCreated {
+		var created struct {
+			ID int64 `json:"id"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&created); err == nil {
+			t.mu.Lock()
+			t.ids = append(t.ids, created.ID)
+			t.mu.Unlock()
+		}
+	}
+	// Drain the body so the connection is reused
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 400 {
+		return resp.StatusCode, fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// randomID returns one of the created task IDs, or 1 if there are none
+func (t *target) randomID(rng *rand.Rand) int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.ids) == 0 {
+		return 1
+	}
+	return t.ids[rng.IntN(len(t.ids))]
+}
+
+// count returns the number of created tasks
+func (t *target) count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.ids)
+}
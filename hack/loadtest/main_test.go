@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"math/rand/v2"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/config"
+	"github.com/light-bringer/cert-tasks/internal/handlers"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+	"github.com/light-bringer/cert-tasks/internal/server"
+)
+
+func TestMix(t *testing.T) {
+	m := mix{}
+	if err := m.Set("get=3, update=1"); err != nil {
+		t.Fatal(err)
+	}
+	if m.String() != "get=3,update=1" {
+		t.Errorf("String() = %q", m.String())
+	}
+	counts := make(map[op]int)
+	rng := rand.New(rand.NewPCG(1, 2))
+	for range 4000 {
+		counts[m.pick(rng)]++
+	}
+	if counts[opGet] < 2800 || counts[opGet] > 3200 || counts[opUpdate]+counts[opGet] != 4000 {
+		t.Errorf("picked %v from %v", counts, m)
+	}
+
+	for _, bad := range []string{"get", "get=-1", "delete=1", "get=0"} {
+		if err := (mix{}).Set(bad); err == nil {
+			t.Errorf("Set(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var sorted []time.Duration
+	for i := 1; i <= 100; i++ {
+		sorted = append(sorted, time.Duration(i)*time.Millisecond)
+	}
+	for p, want := range map[int]time.Duration{50: 50 * time.Millisecond, 99: 99 * time.Millisecond, 100: 100 * time.Millisecond} {
+		if got := percentile(sorted, p); got != want {
+			t.Errorf("percentile(%d) = %v, want %v", p, got, want)
+		}
+	}
+	if got := percentile(sorted[:1], 99); got != time.Millisecond {
+		t.Errorf("percentile of one = %v", got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	srv := httptest.NewServer(server.NewServer(config.Default(false).Server, handlers.NewTaskHandler(repo)).Handler())
+	defer srv.Close()
+
+	cfg := options{
+		url:         srv.URL,
+		duration:    200 * time.Millisecond,
+		concurrency: 4,
+		seed:        10,
+		mix:         mix{opGet: 1, opList: 1, opSearch: 1, opCreate: 1, opUpdate: 1},
+	}
+	report, err := run(context.Background(), cfg, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Requests == 0 || report.Errors != 0 || len(report.Operations) != len(ops) {
+		t.Fatalf("report = %+v", report)
+	}
+
+	var out bytes.Buffer
+	report.WriteText(&out)
+	for _, want := range []string{"req/s", "p99", "create", "statuses: 200="} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("text report lacks %q:\n%s", want, out.String())
+		}
+	}
+}
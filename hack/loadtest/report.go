@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"text/tabwriter"
+	"time"
+)
+
+// recorder collects the results of one worker, so workers never contend
+// on a shared lock while the run is going
+type recorder struct {
+	latencies map[op][]time.Duration
+	errors    map[op]int
+	statuses  map[int]int
+}
+
+func newRecorder() *recorder {
+	return &recorder{
+		latencies: make(map[op][]time.Duration),
+		errors:    make(map[op]int),
+		statuses:  make(map[int]int),
+	}
+}
+
+// record adds the result of one request
+func (r *recorder) record(o op, latency time.Duration, status int, err error) {
+	r.latencies[o] = append(r.latencies[o], latency)
+	r.statuses[status]++
+	if err != nil {
+		r.errors[o]++
+	}
+}
+
+// Report summarizes a run
+type Report struct {
+	Duration   time.Duration `json:"duration_ns"`
+	Requests   int           `json:"requests"`
+	Errors     int           `json:"errors"`
+	Throughput float64       `json:"requests_per_second"`
+
+	// Statuses counts responses by HTTP status; 0 counts transport errors
+	Statuses   map[int]int `json:"statuses"`
+	Operations []OpReport  `json:"operations"`
+}
+
+// OpReport summarizes the requests of one operation. Latencies include
+// errors, which are counted separately.
+type OpReport struct {
+	Op         string        `json:"op"`
+	Requests   int           `json:"requests"`
+	Errors     int           `json:"errors"`
+	Throughput float64       `json:"requests_per_second"`
+	Mean       time.Duration `json:"mean_ns"`
+	P50        time.Duration `json:"p50_ns"`
+	P90        time.Duration `json:"p90_ns"`
+	P99        time.Duration `json:"p99_ns"`
+	Max        time.Duration `json:"max_ns"`
+}
+
+// newReport merges the recorders of a run that took elapsed
+func newReport(elapsed time.Duration, recorders []*recorder) *Report {
+	report := &Report{Duration: elapsed, Statuses: make(map[int]int)}
+	for _, o := range ops {
+		var latencies []time.Duration
+		errors := 0
+		for _, r := range recorders {
+			latencies = append(latencies, r.latencies[o]...)
+			errors += r.errors[o]
+		}
+		if len(latencies) == 0 {
+			continue
+		}
+		slices.Sort(latencies)
+		var total time.Duration
+		for _, l := range latencies {
+			total += l
+		}
+		report.Operations = append(report.Operations, OpReport{
+			Op:         string(o),
+			Requests:   len(latencies),
+			Errors:     errors,
+			Throughput: float64(len(latencies)) / elapsed.Seconds(),
+			Mean:       total / time.Duration(len(latencies)),
+			P50:        percentile(latencies, 50),
+			P90:        percentile(latencies, 90),
+			P99:        percentile(latencies, 99),
+			Max:        latencies[len(latencies)-1],
+		})
+		report.Requests += len(latencies)
+		report.Errors += errors
+	}
+	for _, r := range recorders {
+		for status, n := range r.statuses {
+			report.Statuses[status] += n
+		}
+	}
+	report.Throughput = float64(report.Requests) / elapsed.Seconds()
+	return report
+}
+
+// percentile returns the p-th percentile of sorted latencies, by the
+// nearest-rank method
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
+
+// WriteText prints the report as a table
+func (r *Report) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "%d requests in %s, %.1f req/s, %d errors\n\n",
+		r.Requests, r.Duration.Round(time.Millisecond), r.Throughput, r.Errors)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "op\trequests\terrors\treq/s\tmean\tp50\tp90\tp99\tmax\t")
+	for _, o := range r.Operations {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%s\t%s\t%s\t%s\t%s\t\n",
+			o.Op, o.Requests, o.Errors, o.Throughput,
+			round(o.Mean), round(o.P50), round(o.P90), round(o.P99), round(o.Max))
+	}
+	tw.Flush()
+
+	statuses := make([]int, 0, len(r.Statuses))
+	for status := range r.Statuses {
+		statuses = append(statuses, status)
+	}
+	slices.Sort(statuses)
+	fmt.Fprint(w, "\nstatuses:")
+	for _, status := range statuses {
+		label := fmt.Sprint(status)
+		if status == 0 {
+			label = "transport error"
+		}
+		fmt.Fprintf(w, " %s=%d", label, r.Statuses[status])
+	}
+	fmt.Fprintln(w)
+}
+
+// round shortens a latency for display
+func round(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	}
+	return d.Round(time.Microsecond)
+}
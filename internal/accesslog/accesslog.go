@@ -0,0 +1,216 @@
+// Package accesslog writes one JSON record per completed request to the
+// configured sinks: stdout, a size-rotated file and syslog. Under load,
+// successful requests can be sampled so the log keeps up with traffic:
+// once more than SampleAfter requests have succeeded in the current
+// second, each further one is kept with probability SampleRate. Failed
+// requests, those answered with 4xx or 5xx, are always kept.
+package accesslog
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/light-bringer/cert-tasks/internal/forwarded"
+	"github.com/light-bringer/cert-tasks/internal/logging"
+)
+
+// Sink names
+const (
+	Stdout = "stdout"
+	File   = "file"
+	Syslog = "syslog"
+)
+
+// Defaults for the file sink
+const (
+	DefaultMaxSizeMB  = 100
+	DefaultMaxBackups = 5
+)
+
+// stdout is where the stdout sink writes
+var stdout io.Writer = os.Stdout
+
+// metrics counts written and sampled out records for /debug/vars on the
+// admin listener
+var metrics = expvar.NewMap("access_log")
+
+// Config selects the sinks and sampling of the access log
+type Config struct {
+	// Sinks are the sinks records go to; empty means stdout only
+	Sinks []string `yaml:"sinks"`
+
+	// File is the path the file sink writes to. It is rotated once it
+	// would grow past MaxSizeMB (0 means DefaultMaxSizeMB), keeping
+	// MaxBackups older files as File.1 (the newest) to File.N.
+	File       string `yaml:"file"`
+	MaxSizeMB  int    `yaml:"max_size_mb"`
+	MaxBackups int    `yaml:"max_backups"`
+
+	// SyslogAddr is the syslog server, as udp://host:514 or
+	// tcp://host:514; empty means the local syslog daemon
+	SyslogAddr string `yaml:"syslog_addr"`
+
+	// SampleRate is the share, from 0 to 1, of successful requests kept
+	// once more than SampleAfter have succeeded in the same second. A
+	// SampleAfter of 0 turns sampling off.
+	SampleRate  float64 `yaml:"sample_rate"`
+	SampleAfter int     `yaml:"sample_after"`
+}
+
+// Validate reports the first problem with c
+func (c Config) Validate() error {
+	for _, sink := range c.Sinks {
+		switch sink {
+		case Stdout, Syslog:
+		case File:
+			if c.File == "" {
+				return errors.New("the file sink needs a file")
+			}
+		default:
+			return fmt.Errorf("unknown sink %q", sink)
+		}
+	}
+	if c.MaxSizeMB < 0 {
+		return fmt.Errorf("max_size_mb %d is negative", c.MaxSizeMB)
+	}
+	if c.MaxBackups < 0 {
+		return fmt.Errorf("max_backups %d is negative", c.MaxBackups)
+	}
+	if c.SyslogAddr != "" && !strings.HasPrefix(c.SyslogAddr, "udp://") && !strings.HasPrefix(c.SyslogAddr, "tcp://") {
+		return fmt.Errorf("syslog_addr %q is not udp://host:port or tcp://host:port", c.SyslogAddr)
+	}
+	if c.SampleRate < 0 || c.SampleRate > 1 {
+		return fmt.Errorf("sample_rate %g is not between 0 and 1", c.SampleRate)
+	}
+	if c.SampleAfter < 0 {
+		return fmt.Errorf("sample_after %d is negative", c.SampleAfter)
+	}
+	return nil
+}
+
+// Logger writes access records to its sinks
+type Logger struct {
+	handlers []slog.Handler
+	closers  []func() error
+
+	sampleRate  float64
+	sampleAfter int
+	now         func() time.Time
+	keep        func(rate float64) bool
+
+	// second and succeeded count successful requests in the current
+	// second
+	mu        sync.Mutex
+	second    int64
+	succeeded int
+}
+
+// New opens the sinks of cfg. Close the Logger to close them.
+func New(cfg Config) (*Logger, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	l := &Logger{
+		sampleRate:  cfg.SampleRate,
+		sampleAfter: cfg.SampleAfter,
+		now:         time.Now,
+		keep:        func(rate float64) bool { return rand.Float64() < rate },
+	}
+
+	maxSize := cfg.MaxSizeMB
+	if maxSize == 0 {
+		maxSize = DefaultMaxSizeMB
+	}
+	sinks := cfg.Sinks
+	if len(sinks) == 0 {
+		sinks = []string{Stdout}
+	}
+	for _, sink := range sinks {
+		switch sink {
+		case Stdout:
+			l.handlers = append(l.handlers, slog.NewJSONHandler(stdout, nil))
+		case File:
+			f, err := logging.OpenRotating(cfg.File, int64(maxSize)<<20, 0, cfg.MaxBackups)
+			if err != nil {
+				l.Close()
+				return nil, err
+			}
+			l.handlers = append(l.handlers, slog.NewJSONHandler(f, nil))
+			l.closers = append(l.closers, f.Close)
+		case Syslog:
+			w, err := dialSyslog(cfg.SyslogAddr)
+			if err != nil {
+				l.Close()
+				return nil, fmt.Errorf("syslog: %w", err)
+			}
+			l.handlers = append(l.handlers, slog.NewJSONHandler(w, nil))
+			l.closers = append(l.closers, w.Close)
+		}
+	}
+	return l, nil
+}
+
+// Log records a completed request, unless it is sampled out
+func (l *Logger) Log(r *http.Request, status, bytes int, duration time.Duration) {
+	if status < http.StatusBadRequest && !l.sample() {
+		metrics.Add("sampled_out", 1)
+		return
+	}
+
+	record := slog.NewRecord(l.now(), slog.LevelInfo, "request completed", 0)
+	record.AddAttrs(
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.String("scheme", forwarded.Scheme(r)),
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.Int("status", status),
+		slog.Int("bytes", bytes),
+		slog.Float64("duration_ms", float64(duration.Microseconds())/1000),
+		slog.String("remote_addr", r.RemoteAddr),
+		slog.String("user_agent", r.UserAgent()),
+	)
+	for _, h := range l.handlers {
+		if err := h.Handle(context.Background(), record.Clone()); err != nil {
+			metrics.Add("failed", 1)
+		}
+	}
+	metrics.Add("written", 1)
+}
+
+// sample reports whether to keep the record of a successful request
+func (l *Logger) sample() bool {
+	if l.sampleAfter == 0 || l.sampleRate >= 1 {
+		return true
+	}
+	second := l.now().Unix()
+
+	l.mu.Lock()
+	if second != l.second {
+		l.second, l.succeeded = second, 0
+	}
+	l.succeeded++
+	underLoad := l.succeeded > l.sampleAfter
+	l.mu.Unlock()
+
+	return !underLoad || l.keep(l.sampleRate)
+}
+
+// Close closes the file and syslog sinks
+func (l *Logger) Close() error {
+	var errs []error
+	for _, c := range l.closers {
+		errs = append(errs, c())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,94 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogger_Sampling(t *testing.T) {
+	var buf bytes.Buffer
+	stdout = &buf
+	defer func() { stdout = os.Stdout }()
+
+	l, err := New(Config{SampleRate: 0.5, SampleAfter: 2})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+	kept := 0
+	l.keep = func(rate float64) bool {
+		kept++
+		return kept%2 == 0
+	}
+
+	r := httptest.NewRequest("GET", "/tasks", nil)
+	// Two successes pass, then every other one is kept
+	for range 6 {
+		l.Log(r, 200, 10, time.Millisecond)
+	}
+	// Failures are always kept
+	l.Log(r, 500, 10, time.Millisecond)
+	// A new second starts the count again
+	now = now.Add(time.Second)
+	l.Log(r, 200, 10, time.Millisecond)
+
+	var statuses []int
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record struct {
+			Msg    string `json:"msg"`
+			Path   string `json:"path"`
+			Status int    `json:"status"`
+		}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("record %q: %v", line, err)
+		}
+		if record.Msg != "request completed" || record.Path != "/tasks" {
+			t.Errorf("record = %s", line)
+		}
+		statuses = append(statuses, record.Status)
+	}
+	want := []int{200, 200, 200, 200, 500, 200}
+	if len(statuses) != len(want) {
+		t.Fatalf("statuses = %v, want %v", statuses, want)
+	}
+	for i := range want {
+		if statuses[i] != want[i] {
+			t.Fatalf("statuses = %v, want %v", statuses, want)
+		}
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name string
+		c    Config
+		want string
+	}{
+		{"valid", Config{Sinks: []string{Stdout, File}, File: "access.log", SampleRate: 0.1, SampleAfter: 100}, ""},
+		{"sink", Config{Sinks: []string{"kafka"}}, `unknown sink "kafka"`},
+		{"file", Config{Sinks: []string{File}}, "needs a file"},
+		{"syslog", Config{Sinks: []string{Syslog}, SyslogAddr: "logs.internal:514"}, "not udp://"},
+		{"rate", Config{SampleRate: 1.5}, "not between 0 and 1"},
+		{"after", Config{SampleAfter: -1}, "sample_after -1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.c.Validate()
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !windows && !plan9
+
+package accesslog
+
+import (
+	"io"
+	"log/syslog"
+	"strings"
+)
+
+// dialSyslog connects to the syslog server at addr, or the local daemon
+// if addr is empty. Each record is sent as one message.
+func dialSyslog(addr string) (io.WriteCloser, error) {
+	network, raddr, _ := strings.Cut(addr, "://")
+	return syslog.Dial(network, raddr, syslog.LOG_INFO|syslog.LOG_LOCAL0, "cert-tasks")
+}
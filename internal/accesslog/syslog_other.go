@@ -0,0 +1,13 @@
+//go:build windows || plan9
+
+package accesslog
+
+import (
+	"errors"
+	"io"
+)
+
+// dialSyslog fails: log/syslog does not support this platform
+func dialSyslog(addr string) (io.WriteCloser, error) {
+	return nil, errors.New("not supported on this platform")
+}
@@ -0,0 +1,262 @@
+// Package app composes the server from its configuration: storage, the
+// task service, handlers, middlewares, background work and the HTTP
+// servers. cmd/api runs it through the public tasks package, and tests can
+// build the whole server with New, without the binary.
+//
+// New runs a fixed list of steps, each wiring one feature into the App.
+// A new feature gets a step of its own, in the place its dependencies
+// allow, rather than growing another.
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/light-bringer/cert-tasks/internal/audit"
+	"github.com/light-bringer/cert-tasks/internal/config"
+	"github.com/light-bringer/cert-tasks/internal/content"
+	"github.com/light-bringer/cert-tasks/internal/handlers"
+	"github.com/light-bringer/cert-tasks/internal/health"
+	"github.com/light-bringer/cert-tasks/internal/ids"
+	"github.com/light-bringer/cert-tasks/internal/maintenance"
+	"github.com/light-bringer/cert-tasks/internal/realtime"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+	"github.com/light-bringer/cert-tasks/internal/scheduler"
+	"github.com/light-bringer/cert-tasks/internal/seed"
+	"github.com/light-bringer/cert-tasks/internal/server"
+	"github.com/light-bringer/cert-tasks/internal/service"
+	"github.com/light-bringer/cert-tasks/internal/startup"
+)
+
+// Options are what a program adds to the server the configuration
+// describes
+type Options struct {
+	// Repository stores tasks instead of the configured backend
+	Repository repository.TaskRepository
+
+	// Hooks are called around every change to a task, after those of the
+	// server's own features
+	Hooks []repository.Hooks
+
+	// Middlewares wrap every request, after the plugins' middlewares
+	Middlewares []func(http.Handler) http.Handler
+
+	// Mounts are served inside the authenticated task routes
+	Mounts []Mount
+
+	// LogLevel is the level /admin/loglevel changes; nil gives the server
+	// a level of its own, starting at the configured one
+	LogLevel *slog.LevelVar
+
+	// SeedFile holds fixtures loaded at startup; memory storage only
+	SeedFile string
+
+	// DevMode serves POST /admin/seed; memory storage only
+	DevMode bool
+
+	// Listeners already open, such as sockets from systemd; nil ones are
+	// opened on the configured addresses
+	APIListener, RedirectListener, AdminListener net.Listener
+
+	// Ready is called once the server accepts connections
+	Ready func()
+}
+
+// Mount serves Handler under Prefix
+type Mount struct {
+	Prefix  string
+	Handler http.Handler
+}
+
+// App is a composed server and the background work that goes with it
+type App struct {
+	// Repository is the repository the handlers change tasks through,
+	// with every feature's hooks
+	Repository repository.TaskRepository
+
+	// Tasks is the task service the handlers use
+	Tasks *service.Tasks
+
+	// TaskHandler handles the API's requests
+	TaskHandler *handlers.TaskHandler
+
+	// Server serves TaskHandler with the configured middlewares
+	Server *server.Server
+
+	// Report holds the outcome of the startup self-checks
+	Report *startup.Report
+
+	cfg  *config.Config
+	opts Options
+
+	// Set up by the storage steps
+	backend    string
+	idFormat   ids.Format
+	repoOpts   []repository.MemoryOption
+	memRepo    *repository.MemoryRepository
+	repo       repository.TaskRepository
+	fileStore  *repository.FileRepository
+	seeder     *seed.Seeder
+	workspaces repository.WorkspaceRepository
+	webhooks   repository.WebhookRepository
+	revisions  repository.RevisionRepository
+	apiKeys    repository.APIKeyRepository
+
+	// Shared by later steps
+	logLevel *slog.LevelVar
+	sched    *scheduler.Scheduler
+	registry *health.Registry
+	hub      *realtime.Hub
+	mode     *maintenance.Mode
+	notify   []repository.NotifyFunc
+	policies *content.PolicySet
+	auditLog *audit.Log
+
+	serverOpts  []server.Option
+	handlerOpts []handlers.Option
+
+	// background runs until its context is done
+	background []func(ctx context.Context)
+
+	// closers release what New opened, in reverse order
+	closers []func()
+}
+
+// New validates cfg and composes the server it describes, checking on the
+// way that its storage and files are usable. The startup report is written
+// where the configuration says, whether or not New succeeds. The caller
+// must Close the App.
+func New(cfg *config.Config, opts Options) (_ *App, err error) {
+	a := &App{
+		cfg:      cfg,
+		opts:     opts,
+		logLevel: opts.LogLevel,
+		sched:    scheduler.New(),
+		Report:   startup.NewReport(),
+	}
+	defer func() {
+		if err != nil {
+			if a.Report.OK {
+				// Failed outside the named checks
+				a.Report.Record("startup", "", err)
+			}
+			a.Close()
+		}
+		if err := a.Report.Output(cfg.Log.StartupReport); err != nil {
+			slog.Warn("writing the startup report failed", slog.Any("error", err))
+		}
+	}()
+
+	if errs := cfg.Validate(); len(errs) > 0 {
+		return nil, a.Report.Record("configuration", "", fmt.Errorf("invalid configuration: %w", errors.Join(errs...)))
+	}
+	a.Report.Record("configuration", "", nil)
+	if a.logLevel == nil {
+		a.logLevel = new(slog.LevelVar)
+		a.logLevel.Set(cfg.Log.Level)
+	}
+
+	// In dependency order: storage first, the task handler and the server
+	// last
+	steps := []func() error{
+		a.openStorage,
+		a.personalMode,
+		a.writeBehind,
+		a.loadFixtures,
+		a.takeStores,
+		a.selfCheck,
+		a.authentication,
+		a.demoMode,
+		a.captureMode,
+		a.adminListener,
+		a.healthChecks,
+		a.rateLimiting,
+		a.handlerSettings,
+		a.realtime,
+		a.operations,
+		a.backups,
+		a.webhookDelivery,
+		a.eventPublishing,
+		a.chatNotifications,
+		a.plugins,
+		a.embedding,
+		a.contentRules,
+		a.jobQueue,
+		a.taskHandler,
+		a.cleanup,
+		a.erasure,
+		a.server,
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// Handler returns the server's router, for serving it from another
+// http.Server. Background work runs only while RunBackground does.
+func (a *App) Handler() http.Handler {
+	return a.Server.Handler()
+}
+
+// Run listens on the configured addresses and runs the background work
+// until ctx is done, then shuts down gracefully
+func (a *App) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.RunBackground(ctx)
+	}()
+	err := a.Server.Run(ctx)
+	cancel()
+	<-done
+	return err
+}
+
+// RunBackground runs the job queue, webhook and event delivery and the
+// scheduled jobs until ctx is done and they have stopped
+func (a *App) RunBackground(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, run := range a.background {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			run(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+// Close saves queued task writes and releases what the App opened. It
+// must be called after Run or RunBackground has returned.
+func (a *App) Close() {
+	if a.fileStore != nil {
+		if err := a.fileStore.Flush(); err != nil {
+			slog.Error("saving queued task writes", slog.Any("error", err))
+		}
+	}
+	for i := len(a.closers) - 1; i >= 0; i-- {
+		a.closers[i]()
+	}
+	a.closers = nil
+}
+
+// run adds background work
+func (a *App) run(fn func(ctx context.Context)) {
+	a.background = append(a.background, fn)
+}
+
+// onClose adds a function Close calls
+func (a *App) onClose(fn func()) {
+	a.closers = append(a.closers, fn)
+}
@@ -0,0 +1,296 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/backup"
+	"github.com/light-bringer/cert-tasks/internal/config"
+	"github.com/light-bringer/cert-tasks/internal/erasure"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+	"github.com/light-bringer/cert-tasks/internal/service"
+	"github.com/light-bringer/cert-tasks/internal/startup"
+)
+
+// testAdminKey is the admin key do sends, which tests set as
+// cfg.Auth.AdminKey to reach the admin endpoints
+const testAdminKey = "test-admin-key-0123456789abcdef0123"
+
+func do(t *testing.T, h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+testAdminKey)
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestNew_FileStorage(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.Default(false)
+	cfg.Storage.DSN = "file://" + filepath.Join(dir, "tasks.json")
+	cfg.Jobs.Dir = filepath.Join(dir, "jobs")
+
+	a, err := New(cfg, Options{})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if rec := do(t, a.Handler(), "POST", "/tasks", `{"title":"persisted"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("POST /tasks = %d %s", rec.Code, rec.Body)
+	}
+	a.Close()
+
+	// A second App on the same configuration reads the snapshot back
+	a, err = New(cfg, Options{})
+	if err != nil {
+		t.Fatalf("New() again error = %v", err)
+	}
+	defer a.Close()
+	rec := do(t, a.Handler(), "GET", "/tasks", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"persisted"`) {
+		t.Errorf("GET /tasks after reopening = %d %s, want the stored task", rec.Code, rec.Body)
+	}
+}
+
+func TestNew_Backups(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.Default(false)
+	cfg.Storage.DSN = "file://" + filepath.Join(dir, "tasks.json")
+	cfg.Jobs.Dir = filepath.Join(dir, "jobs")
+	cfg.Backup.Destination = "file://" + filepath.Join(dir, "backups")
+	cfg.Auth.AdminKey = testAdminKey
+
+	a, err := New(cfg, Options{})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	do(t, a.Handler(), "POST", "/tasks", `{"title":"backed up"}`)
+
+	rec := do(t, a.Handler(), "POST", "/admin/backup", "")
+	var info backup.Info
+	json.NewDecoder(rec.Body).Decode(&info)
+	if rec.Code != http.StatusCreated || info.Counts.Tasks != 1 {
+		t.Fatalf("POST /admin/backup = %d %+v", rec.Code, info)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "backups", info.Name)); err != nil {
+		t.Errorf("archive not written: %v", err)
+	}
+	a.Close()
+
+	cfg.Auth.AdminKey = ""
+	a, err = New(cfg, Options{})
+	if err != nil {
+		t.Fatalf("New() without an admin key error = %v", err)
+	}
+	defer a.Close()
+	if rec := do(t, a.Handler(), "POST", "/admin/restore", `{"name": "`+info.Name+`", "policy": "replace"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("POST /admin/restore without an admin key = %d, want 404", rec.Code)
+	}
+}
+
+func TestNew_EncryptedStorage(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.Default(false)
+	cfg.Storage.DSN = "file://" + filepath.Join(dir, "tasks.json")
+	cfg.Storage.Encryption.Key = strings.Repeat("ab", 32)
+
+	a, err := New(cfg, Options{})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	do(t, a.Handler(), "POST", "/tasks", `{"title":"confidential"}`)
+	a.Close()
+
+	data, err := os.ReadFile(filepath.Join(dir, "tasks.json"))
+	if err != nil || strings.Contains(string(data), "confidential") {
+		t.Fatalf("snapshot = %q, %v, want it encrypted", data, err)
+	}
+
+	cfg.Storage.Encryption.Key = strings.Repeat("cd", 32)
+	if a, err := New(cfg, Options{}); err == nil {
+		a.Close()
+		t.Fatal("New() with another key succeeded")
+	}
+	cfg.Storage.Encryption.PreviousKeys = []string{strings.Repeat("ab", 32)}
+	a, err = New(cfg, Options{})
+	if err != nil {
+		t.Fatalf("New() after rotating the key error = %v", err)
+	}
+	defer a.Close()
+	if rec := do(t, a.Handler(), "GET", "/tasks", ""); !strings.Contains(rec.Body.String(), "confidential") {
+		t.Errorf("GET /tasks = %s, want the stored task", rec.Body)
+	}
+}
+
+func TestNew_Erasure(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.Default(false)
+	cfg.Storage.DSN = "file://" + filepath.Join(dir, "tasks.json")
+	cfg.Erasure.SigningSecret = strings.Repeat("e", 32)
+	cfg.Auth.AdminKey = testAdminKey
+
+	a, err := New(cfg, Options{})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	alice := repository.WithOwner(context.Background(), "alice")
+	a.Repository.Create(alice, &models.Task{Title: "Call back", Description: "Jane, 555-0100"})
+
+	rec := do(t, a.Handler(), "DELETE", "/users/alice/data?mode=anonymize", "")
+	var signed erasure.Signed
+	json.NewDecoder(rec.Body).Decode(&signed)
+	if rec.Code != http.StatusOK || !erasure.Verify(signed, []byte(cfg.Erasure.SigningSecret)) {
+		t.Fatalf("DELETE /users/alice/data = %d %+v, want a report signed with the configured secret", rec.Code, signed)
+	}
+	a.Close()
+
+	data, err := os.ReadFile(filepath.Join(dir, "tasks.json"))
+	if err != nil || strings.Contains(string(data), "Jane") || strings.Contains(string(data), "alice") || !strings.Contains(string(data), "Call back") {
+		t.Errorf("snapshot = %s, %v, want the task kept without owner and description", data, err)
+	}
+
+	// Neither a random signing secret nor an open endpoint stands in for
+	// missing settings
+	for name, unset := range map[string]func(*config.Config){
+		"no signing secret": func(cfg *config.Config) { cfg.Erasure.SigningSecret = "" },
+		"no admin key":      func(cfg *config.Config) { cfg.Auth.AdminKey = "" },
+	} {
+		cfg := config.Default(false)
+		cfg.Erasure.SigningSecret = strings.Repeat("e", 32)
+		cfg.Auth.AdminKey = testAdminKey
+		unset(cfg)
+		a, err := New(cfg, Options{})
+		if err != nil {
+			t.Fatalf("%s: New() error = %v", name, err)
+		}
+		if rec := do(t, a.Handler(), "DELETE", "/users/alice/data", ""); rec.Code != http.StatusNotFound {
+			t.Errorf("%s: DELETE /users/alice/data = %d, want 404", name, rec.Code)
+		}
+		if rec := do(t, a.Handler(), "GET", "/users/alice/export", ""); rec.Code != http.StatusNotFound {
+			t.Errorf("%s: GET /users/alice/export = %d, want 404", name, rec.Code)
+		}
+		a.Close()
+	}
+}
+
+func TestNew_SharedService(t *testing.T) {
+	policies := filepath.Join(t.TempDir(), "policies.json")
+	if err := os.WriteFile(policies, []byte(`{"default": {"max_links": 1}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Default(false)
+	cfg.Content.PolicyFile = policies
+
+	a, err := New(cfg, Options{})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer a.Close()
+
+	// The service the App exposes is the one behind the API, with the
+	// configured content policies
+	_, err = a.Tasks.Create(context.Background(), models.CreateTaskRequest{Title: "t", Description: "see https://example.com and https://example.org"}, service.In(time.UTC))
+	if !errors.Is(err, service.ErrContentRejected) {
+		t.Errorf("Tasks.Create(link) error = %v, want ErrContentRejected", err)
+	}
+	task, err := a.Tasks.Create(context.Background(), models.CreateTaskRequest{Title: "from the service"}, service.In(time.UTC))
+	if err != nil {
+		t.Fatalf("Tasks.Create() error = %v", err)
+	}
+	if rec := do(t, a.Handler(), "GET", "/tasks", ""); !strings.Contains(rec.Body.String(), task.Title) {
+		t.Errorf("GET /tasks = %s, want the task created through the service", rec.Body)
+	}
+	if rec := do(t, a.Handler(), "POST", "/tasks", `{"title":"t","description":"see https://example.com and https://example.org"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("POST /tasks with a link = %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestNew_StartupReport(t *testing.T) {
+	dir := t.TempDir()
+	snapshot := filepath.Join(dir, "tasks.json")
+	if err := os.WriteFile(snapshot, []byte(`{"last_id":1,"tasks":[{"id":1,"title":"old","status":"todo"}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Default(false)
+	cfg.Storage.DSN = "file://" + snapshot
+	cfg.Storage.Migrate = false
+	cfg.Log.StartupReport = filepath.Join(dir, "startup.json")
+
+	// An unversioned snapshot is left alone when migrations are off
+	if a, err := New(cfg, Options{}); !errors.Is(err, startup.ErrMigrationPending) {
+		if err == nil {
+			a.Close()
+		}
+		t.Fatalf("New() error = %v, want ErrMigrationPending", err)
+	}
+	var report startup.Report
+	data, err := os.ReadFile(cfg.Log.StartupReport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("startup report %s: %v", data, err)
+	}
+	if last := report.Checks[len(report.Checks)-1]; report.OK || last.Name != "storage migrations" || last.OK {
+		t.Errorf("report = %+v, want the failed migration check last", report)
+	}
+
+	cfg.Storage.Migrate = true
+	a, err := New(cfg, Options{})
+	if err != nil {
+		t.Fatalf("New() with migrations error = %v", err)
+	}
+	defer a.Close()
+	if !a.Report.OK || len(a.Report.Checks) < 3 {
+		t.Errorf("report = %+v, want configuration, storage and migration checks passed", a.Report)
+	}
+	if rec := do(t, a.Handler(), "GET", "/tasks", ""); !strings.Contains(rec.Body.String(), `"old"`) {
+		t.Errorf("GET /tasks = %s, want the migrated task", rec.Body)
+	}
+}
+
+func TestNew_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(*config.Config, *Options)
+	}{
+		{"invalid configuration", func(cfg *config.Config, _ *Options) {
+			cfg.Storage.IDFormat = "bogus"
+		}},
+		{"missing policy file", func(cfg *config.Config, _ *Options) {
+			cfg.Content.PolicyFile = filepath.Join(t.TempDir(), "missing.json")
+		}},
+		{"backups in demo mode", func(cfg *config.Config, _ *Options) {
+			cfg.Demo.Enabled = true
+			cfg.Backup.Destination = "file://" + t.TempDir()
+		}},
+		{"demo mode in file storage", func(cfg *config.Config, _ *Options) {
+			cfg.Demo.Enabled = true
+			cfg.Storage.DSN = "file://" + filepath.Join(t.TempDir(), "tasks.json")
+		}},
+		{"fixtures in file storage", func(cfg *config.Config, opts *Options) {
+			cfg.Storage.DSN = "file://" + filepath.Join(t.TempDir(), "tasks.json")
+			opts.DevMode = true
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default(false)
+			var opts Options
+			tt.setup(cfg, &opts)
+			if a, err := New(cfg, opts); err == nil {
+				a.Close()
+				t.Error("New() error = nil, want an error")
+			}
+		})
+	}
+}
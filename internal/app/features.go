@@ -0,0 +1,604 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/accesslog"
+	"github.com/light-bringer/cert-tasks/internal/audit"
+	"github.com/light-bringer/cert-tasks/internal/auth"
+	"github.com/light-bringer/cert-tasks/internal/backup"
+	"github.com/light-bringer/cert-tasks/internal/blob"
+	"github.com/light-bringer/cert-tasks/internal/bodylog"
+	"github.com/light-bringer/cert-tasks/internal/calendar"
+	"github.com/light-bringer/cert-tasks/internal/capture"
+	"github.com/light-bringer/cert-tasks/internal/chat"
+	"github.com/light-bringer/cert-tasks/internal/cleanup"
+	"github.com/light-bringer/cert-tasks/internal/content"
+	"github.com/light-bringer/cert-tasks/internal/erasure"
+	"github.com/light-bringer/cert-tasks/internal/events"
+	"github.com/light-bringer/cert-tasks/internal/handlers"
+	"github.com/light-bringer/cert-tasks/internal/health"
+	"github.com/light-bringer/cert-tasks/internal/i18n"
+	"github.com/light-bringer/cert-tasks/internal/jobs"
+	"github.com/light-bringer/cert-tasks/internal/maintenance"
+	"github.com/light-bringer/cert-tasks/internal/outbound"
+	"github.com/light-bringer/cert-tasks/internal/ratelimit"
+	"github.com/light-bringer/cert-tasks/internal/realtime"
+	"github.com/light-bringer/cert-tasks/internal/recovery"
+	"github.com/light-bringer/cert-tasks/internal/redact"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+	"github.com/light-bringer/cert-tasks/internal/scheduler"
+	"github.com/light-bringer/cert-tasks/internal/server"
+	"github.com/light-bringer/cert-tasks/internal/service"
+	"github.com/light-bringer/cert-tasks/internal/webhook"
+	"github.com/light-bringer/cert-tasks/plugin"
+	"github.com/redis/go-redis/v9"
+)
+
+// authentication requires API keys or JWTs and keeps an audit log. The
+// admin endpoints need the admin key even with auth disabled.
+func (a *App) authentication() error {
+	cfg := a.cfg.Auth
+	if cfg.AdminKey == "" {
+		slog.Warn("no admin key set, so the admin endpoints are not served")
+	}
+	if !cfg.Enabled {
+		a.serverOpts = append(a.serverOpts, server.WithAdminKey(cfg.AdminKey))
+		return nil
+	}
+	keys, ok := a.repo.(repository.APIKeyRepository)
+	if !ok {
+		return errors.New("enabling auth: storage backend cannot store API keys")
+	}
+	a.apiKeys = keys
+
+	var authOpts []auth.Option
+	if jwtCfg := cfg.JWT; jwtCfg.Enabled() {
+		verifier := auth.NewJWTVerifier(auth.JWTConfig{
+			Secret:   jwtCfg.Secret,
+			JWKSURL:  jwtCfg.JWKSURL,
+			Client:   outbound.Client(a.cfg.Outbound.JWKS),
+			Issuer:   jwtCfg.Issuer,
+			Audience: jwtCfg.Audience,
+		})
+		if jwtCfg.JWKSURL != "" {
+			// Unknown key IDs also trigger a refetch; this picks up
+			// rotations before the first token signed with a new key
+			a.sched.Add(scheduler.Job{
+				Name:       "jwks-refresh",
+				Interval:   15 * time.Minute,
+				StuckAfter: time.Minute,
+				RunAtStart: true,
+				Run: func(ctx context.Context, beat func()) error {
+					return verifier.Refresh(ctx)
+				},
+			})
+		}
+		authOpts = append(authOpts, auth.WithJWT(verifier))
+		slog.Info("JWT authentication enabled", slog.Bool("jwks", jwtCfg.JWKSURL != ""))
+	}
+
+	a.serverOpts = append(a.serverOpts, server.WithAuth(auth.New(keys, authOpts...), cfg.AdminKey))
+	slog.Info("API key authentication enabled")
+
+	auditLog := audit.New(audit.DefaultMaxEntries)
+	if cfg.AuditFile != "" {
+		var closeAudit func() error
+		var err error
+		auditLog, closeAudit, err = audit.Open(cfg.AuditFile, audit.DefaultMaxEntries)
+		if err != nil {
+			return fmt.Errorf("opening audit log: %w", err)
+		}
+		a.onClose(func() { closeAudit() })
+	}
+	a.auditLog = auditLog
+	a.serverOpts = append(a.serverOpts, server.WithAudit(auditLog))
+	return nil
+}
+
+// captureMode samples real traffic into OpenAPI examples
+func (a *App) captureMode() error {
+	cfg := a.cfg.Capture
+	if cfg.File == "" {
+		return nil
+	}
+	recorder := capture.New(cfg.Config())
+	a.sched.Add(scheduler.Job{
+		Name:       "capture-flush",
+		Interval:   time.Minute,
+		StuckAfter: time.Minute,
+		Run: func(ctx context.Context, beat func()) error {
+			return recorder.WriteFile(cfg.File)
+		},
+	})
+
+	// Keep what was captured since the last flush
+	a.run(func(ctx context.Context) {
+		<-ctx.Done()
+		if err := recorder.WriteFile(cfg.File); err != nil {
+			slog.Error("writing captured examples failed", slog.Any("error", err))
+		}
+	})
+
+	a.serverOpts = append(a.serverOpts, server.WithMiddleware(recorder.Middleware))
+	slog.Info("capturing examples",
+		slog.String("file", cfg.File),
+		slog.Float64("sample_rate", cfg.SampleRate),
+	)
+	return nil
+}
+
+// adminListener serves pprof and expvar, never on the public port
+func (a *App) adminListener() error {
+	addr, ln := a.cfg.Server.AdminAddr, a.opts.AdminListener
+	if addr == "" && ln == nil {
+		return nil
+	}
+	a.run(func(ctx context.Context) {
+		if err := server.RunAdmin(ctx, addr, ln); err != nil {
+			slog.Error("admin server failed", slog.Any("error", err))
+		}
+	})
+	return nil
+}
+
+// healthChecks watches the dependencies; search failures degrade ?q=
+// queries only
+func (a *App) healthChecks() error {
+	repo := a.repo
+	a.registry = health.NewRegistry()
+	a.registry.Register(health.Storage, true, func(ctx context.Context) error {
+		_, err := repo.List(ctx, repository.ListOptions{Limit: 1})
+		return err
+	})
+	if repo.Capabilities().FullTextSearch {
+		a.registry.Register(health.Search, false, func(ctx context.Context) error {
+			_, err := repo.Search(ctx, "health-check")
+			return err
+		})
+	}
+	a.sched.Add(scheduler.Job{
+		Name:       "health-check",
+		Interval:   30 * time.Second,
+		StuckAfter: 30 * time.Second,
+		RunAtStart: true,
+		Run: func(ctx context.Context, beat func()) error {
+			a.registry.CheckAll(ctx)
+			return nil
+		},
+	})
+	a.serverOpts = append(a.serverOpts, server.WithHealth(a.registry))
+	return nil
+}
+
+// rateLimiting limits requests per client, in memory or in Redis
+func (a *App) rateLimiting() error {
+	rl := a.cfg.Server.RateLimit
+	if !rl.Enabled() {
+		return nil
+	}
+	store := ratelimit.Store(ratelimit.NewMemoryStore())
+	if rl.Store != "memory" {
+		opts, err := redis.ParseURL(rl.Store)
+		if err != nil {
+			return fmt.Errorf("parsing rate limit store URL: %w", err)
+		}
+		client := redis.NewClient(opts)
+		a.onClose(func() { client.Close() })
+		store = ratelimit.NewRedisStore(client, "cert-tasks:ratelimit:")
+	}
+	a.serverOpts = append(a.serverOpts, server.WithRateLimit(ratelimit.New(store, rl.Config())))
+	slog.Info("rate limiting enabled", slog.Float64("rps", rl.RPS), slog.Int("burst", rl.Burst))
+	return nil
+}
+
+// handlerSettings passes the handler its limits and the optional stores
+func (a *App) handlerSettings() error {
+	cfg := a.cfg.Server
+	a.handlerOpts = append(a.handlerOpts,
+		handlers.WithHealth(a.registry),
+		handlers.WithMaxBodyBytes(cfg.MaxBodyBytes),
+		handlers.WithCacheControl(cfg.CacheControl),
+		handlers.WithIDFormat(a.idFormat),
+		handlers.WithUndoWindow(cfg.UndoWindow),
+	)
+	if a.apiKeys != nil {
+		a.handlerOpts = append(a.handlerOpts, handlers.WithAPIKeys(a.apiKeys))
+	}
+	if a.workspaces != nil {
+		a.handlerOpts = append(a.handlerOpts, handlers.WithWorkspaces(a.workspaces))
+	}
+	if a.revisions != nil {
+		a.handlerOpts = append(a.handlerOpts, handlers.WithRevisions(a.revisions))
+	}
+
+	// Calendar feed tokens stay valid across restarts only with a
+	// configured secret
+	calendarSecret := []byte(a.cfg.Auth.CalendarSecret)
+	if len(calendarSecret) == 0 {
+		calendarSecret = make([]byte, 32)
+		rand.Read(calendarSecret)
+		slog.Warn("no calendar secret configured; calendar feed URLs will stop working on restart")
+	}
+	a.handlerOpts = append(a.handlerOpts, handlers.WithCalendar(calendar.NewTokens(calendarSecret)))
+	return nil
+}
+
+// realtime pushes task changes to WebSocket clients
+func (a *App) realtime() error {
+	realtimeCfg := realtime.DefaultConfig()
+	realtimeCfg.AllowedOrigins = a.cfg.Server.CORS.AllowedOrigins
+	a.hub = realtime.NewHub(realtimeCfg)
+	a.run(func(ctx context.Context) {
+		<-ctx.Done()
+		a.hub.Close()
+	})
+	a.notify = append(a.notify, a.hub.Publish)
+	a.serverOpts = append(a.serverOpts, server.WithRealtime(a.hub))
+	if a.cfg.Server.Docs {
+		a.serverOpts = append(a.serverOpts, server.WithDocs())
+	}
+	return nil
+}
+
+// operations sets up what operators switch at runtime: maintenance mode,
+// body logging and the log level, and the redaction of logs, the access
+// log and panic reporting
+func (a *App) operations() error {
+	cfg := a.cfg
+
+	// Maintenance (read-only) mode can be on from the start, e.g. while a
+	// storage migration finishes
+	a.mode = &maintenance.Mode{}
+	if cfg.Server.ReadOnly {
+		a.mode.Set(true, cfg.Server.ReadOnlyMessage)
+		slog.Warn("starting in maintenance mode; writes get 503 until PUT /admin/maintenance turns it off")
+	}
+	a.serverOpts = append(a.serverOpts, server.WithMaintenance(a.mode))
+
+	// Personal data fields are masked in the request log, logged bodies
+	// and exports asked to be redacted
+	redactor := redact.New(cfg.Log.Redact)
+	a.serverOpts = append(a.serverOpts, server.WithRedactor(redactor))
+	a.handlerOpts = append(a.handlerOpts, handlers.WithRedactor(redactor))
+
+	// Body logging can be on from the start; either way it is toggled at
+	// /admin/debug/bodies
+	bodies := bodylog.New(cfg.Log.BodyMaxBytes, redactor)
+	if cfg.Log.Bodies {
+		bodies.Set(true, 0)
+		slog.Warn("logging request and response bodies; turn it off at PUT /admin/debug/bodies")
+	}
+	a.serverOpts = append(a.serverOpts, server.WithBodyLog(bodies), server.WithLogLevel(a.logLevel))
+
+	accessLog, err := accesslog.New(cfg.Log.Access)
+	if err != nil {
+		return fmt.Errorf("opening access log: %w", err)
+	}
+	a.onClose(func() { accessLog.Close() })
+	a.serverOpts = append(a.serverOpts, server.WithAccessLog(accessLog))
+
+	// Panics are always logged with their stack; with a DSN they are also
+	// sent to Sentry
+	if cfg.Panics.Enabled() {
+		reporter, err := recovery.NewSentry(cfg.Panics.SentryDSN, cfg.Panics.Environment, cfg.Outbound.Notifier)
+		if err != nil {
+			return fmt.Errorf("configuring panic reporting: %w", err)
+		}
+		a.serverOpts = append(a.serverOpts, server.WithPanicReporter(reporter))
+		slog.Info("reporting panics to Sentry", slog.String("environment", cfg.Panics.Environment))
+	}
+	return nil
+}
+
+// backups serves POST /admin/backup and /admin/restore, writing archives
+// to the configured destination
+func (a *App) backups() error {
+	cfg := a.cfg.Backup
+	if !cfg.Enabled() {
+		return nil
+	}
+	if a.cfg.Demo.Enabled {
+		return errors.New("enabling backups: backups are not available in demo mode")
+	}
+	if a.cfg.Auth.AdminKey == "" {
+		// A restore replaces every task; it is never served unguarded
+		slog.Warn("backups need an admin key; /admin/backup and /admin/restore are not served")
+		return nil
+	}
+	dumper, ok := a.repo.(repository.Dumper)
+	if !ok {
+		return errors.New("enabling backups: storage backend cannot be backed up")
+	}
+	store, err := blob.Open(cfg.Destination, outbound.Client(a.cfg.Outbound.Blob))
+	if err != nil {
+		return fmt.Errorf("enabling backups: %w", err)
+	}
+	a.serverOpts = append(a.serverOpts, server.WithBackups(backup.New(dumper, store, cfg.Destination)))
+	slog.Info("backups enabled", slog.String("destination", cfg.Destination))
+	return nil
+}
+
+// webhookDelivery sends task changes to the registered webhooks. There
+// are no webhooks in demo mode: a public sandbox must not make requests to
+// URLs chosen by anonymous visitors. Elsewhere deliveries only reach public
+// addresses and webhooks.allowed_networks, so a webhook cannot make the
+// server call its own network.
+func (a *App) webhookDelivery() error {
+	if a.webhooks == nil || a.cfg.Demo.Enabled {
+		return nil
+	}
+	client := outbound.PublicClient(a.cfg.Outbound.Webhook, a.cfg.Webhooks.AllowedNetworks)
+	dispatcher := webhook.New(a.webhooks, client, webhook.DefaultConfig())
+	a.run(dispatcher.Run)
+	a.notify = append(a.notify, dispatcher.Publish)
+	a.handlerOpts = append(a.handlerOpts, handlers.WithWebhooks(a.webhooks, dispatcher))
+	return nil
+}
+
+// eventPublishing sends every task change as a CloudEvent on NATS or Kafka
+func (a *App) eventPublishing() error {
+	cfg := a.cfg.Events
+	if !cfg.Enabled() {
+		return nil
+	}
+	pub, err := events.New(cfg.Driver, cfg.URL, cfg.Topic, a.cfg.Outbound.Notifier)
+	if err != nil {
+		return fmt.Errorf("connecting to the event broker: %w", err)
+	}
+	emitter := events.NewEmitter(pub, cfg.Source, events.DefaultQueueSize)
+	a.run(func(ctx context.Context) {
+		emitter.Run(ctx)
+		if err := pub.Close(); err != nil {
+			slog.Warn("closing the event broker connection failed", slog.Any("error", err))
+		}
+	})
+	a.notify = append(a.notify, emitter.Publish)
+	slog.Info("publishing task events",
+		slog.String("driver", cfg.Driver),
+		slog.String("topic", cfg.Topic),
+	)
+	return nil
+}
+
+// chatNotifications posts task events and overdue tasks to Slack and
+// Teams
+func (a *App) chatNotifications() error {
+	n := a.cfg.Notifications
+	if !n.Enabled() {
+		return nil
+	}
+	notifier, err := chat.New(n.Connectors, a.repo, a.workspaces, outbound.Client(a.cfg.Outbound.Notifier), chat.DefaultQueueSize)
+	if err != nil {
+		return fmt.Errorf("configuring chat notifications: %w", err)
+	}
+	a.run(notifier.Run)
+	a.notify = append(a.notify, notifier.Publish)
+	a.sched.Add(scheduler.Job{
+		Name:       "overdue-notifications",
+		Interval:   n.OverdueInterval,
+		StuckAfter: time.Minute,
+		Run: func(ctx context.Context, beat func()) error {
+			return notifier.CheckOverdue(ctx)
+		},
+	})
+	slog.Info("posting chat notifications", slog.Int("connectors", len(n.Connectors)))
+	return nil
+}
+
+// plugins sets up the plugins compiled into the program and enabled in
+// the configuration, adding their middlewares, task event consumers and
+// routes
+func (a *App) plugins() error {
+	plugins, err := plugin.Load(a.cfg.Plugins)
+	if err != nil {
+		return fmt.Errorf("loading plugins: %w", err)
+	}
+	for _, consume := range plugins.Consumers() {
+		a.notify = append(a.notify, repository.NotifyFunc(consume))
+	}
+	a.serverOpts = append(a.serverOpts, server.WithMiddleware(plugins.Middlewares()...))
+	for prefix, routes := range plugins.Routes() {
+		a.serverOpts = append(a.serverOpts, server.WithMount(prefix, routes))
+	}
+	if names := plugins.Names(); len(names) > 0 {
+		slog.Info("loaded plugins", slog.Any("plugins", names))
+	}
+	return nil
+}
+
+// embedding adds the middlewares and routes of the program embedding the
+// server, after the plugins'
+func (a *App) embedding() error {
+	a.serverOpts = append(a.serverOpts, server.WithMiddleware(a.opts.Middlewares...))
+	for _, m := range a.opts.Mounts {
+		a.serverOpts = append(a.serverOpts, server.WithMount(m.Prefix, m.Handler))
+	}
+	return nil
+}
+
+// contentRules sets the error format, the translations of error messages
+// and the content policies
+func (a *App) contentRules() error {
+	cfg := a.cfg
+	if cfg.Server.ErrorFormat == "problem+json" {
+		a.handlerOpts = append(a.handlerOpts, handlers.WithProblemDetails())
+	}
+	if cfg.Server.TranslationsDir != "" {
+		translations, err := i18n.Load(cfg.Server.TranslationsDir)
+		if err != nil {
+			return a.Report.Record("translations", cfg.Server.TranslationsDir, fmt.Errorf("loading translations: %w", err))
+		}
+		a.Report.Record("translations", cfg.Server.TranslationsDir, nil)
+		a.handlerOpts = append(a.handlerOpts, handlers.WithTranslations(translations))
+		slog.Info("loaded translations", slog.Any("languages", translations.Languages()))
+	}
+	if cfg.Content.PolicyFile != "" {
+		path := cfg.Content.PolicyFile
+		f, err := os.Open(path)
+		if err != nil {
+			return a.Report.Record("content policies", path, fmt.Errorf("opening content policy file: %w", err))
+		}
+		policies, err := content.LoadPolicies(f)
+		f.Close()
+		if err != nil {
+			return a.Report.Record("content policies", path, fmt.Errorf("loading content policies: %w", err))
+		}
+		a.Report.Record("content policies", path, nil)
+		a.policies = policies
+		a.handlerOpts = append(a.handlerOpts, handlers.WithContentPolicies(policies))
+	}
+	return nil
+}
+
+// jobQueue runs one-off work such as asynchronous imports and exports,
+// retried with backoff, its records kept in a file when configured
+func (a *App) jobQueue() error {
+	cfg := a.cfg.Jobs
+	queue := jobs.New(cfg.Config())
+	if cfg.File != "" {
+		var err error
+		queue, err = jobs.Open(cfg.File, cfg.Config())
+		if err != nil {
+			return fmt.Errorf("opening job queue: %w", err)
+		}
+	}
+	jobsDir := cfg.Dir
+	if jobsDir == "" {
+		var err error
+		jobsDir, err = os.MkdirTemp("", "cert-tasks-jobs-")
+		if err != nil {
+			return fmt.Errorf("creating job directory: %w", err)
+		}
+		a.onClose(func() { os.RemoveAll(jobsDir) })
+	} else if err := os.MkdirAll(jobsDir, 0o700); err != nil {
+		return fmt.Errorf("creating job directory: %w", err)
+	}
+	exports, err := a.exportStore(jobsDir)
+	if err != nil {
+		return err
+	}
+	a.run(queue.Run)
+	a.serverOpts = append(a.serverOpts, server.WithJobQueue(queue))
+	a.handlerOpts = append(a.handlerOpts,
+		handlers.WithJobs(queue, jobsDir, exports),
+		handlers.WithDownloadLinks(a.downloadSigner(exports), a.cfg.Blob.LinkTTL),
+	)
+	return nil
+}
+
+// exportStore opens the blob store finished exports are kept in, which
+// is the job directory unless one is configured
+func (a *App) exportStore(jobsDir string) (blob.Store, error) {
+	if a.cfg.Blob.URL == "" {
+		return blob.NewDir(jobsDir)
+	}
+	store, err := blob.Open(a.cfg.Blob.URL, outbound.Client(a.cfg.Outbound.Blob))
+	if err != nil {
+		return nil, fmt.Errorf("opening blob store: %w", err)
+	}
+	return store, nil
+}
+
+// downloadSigner signs download links to exports: S3 signs its own, and
+// the server signs and serves links to a directory at /blobs/. Links are
+// short-lived, so a random secret losing them on restart is acceptable.
+func (a *App) downloadSigner(store blob.Store) blob.Signer {
+	if signer, ok := store.(blob.Signer); ok {
+		return signer
+	}
+	secret := []byte(a.cfg.Blob.SigningSecret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		rand.Read(secret)
+	}
+	return blob.NewLinks(store, secret, "/blobs/")
+}
+
+// taskHandler composes the repository the handlers use, the task service
+// and the handler. Features that act on every change plug in as
+// repository hooks, so they work the same whatever the backend.
+func (a *App) taskHandler() error {
+	hooks := append([]repository.Hooks{repository.NotifyHooks(a.notify...)}, a.opts.Hooks...)
+	hooked := repository.NewHookedRepository(a.repo, hooks...)
+	a.Repository = hooked
+
+	traced := repository.NewTracedRepository(hooked)
+	a.Tasks = service.NewTasks(traced, service.WithContentPolicies(a.policies))
+	a.TaskHandler = handlers.NewTaskHandler(traced, append(a.handlerOpts, handlers.WithTasks(a.Tasks))...)
+	return nil
+}
+
+// cleanup applies the retention policies. The janitor deletes through the
+// hooked repository so webhooks and subscribers hear of each delete, and
+// leaves data alone in maintenance mode.
+func (a *App) cleanup() error {
+	cfg := a.cfg.Cleanup
+	if !cfg.Enabled() {
+		return nil
+	}
+	janitor := cleanup.New(a.Repository, cfg.Policies)
+	a.sched.Add(scheduler.Job{
+		Name:       "cleanup",
+		Interval:   cfg.Interval,
+		StuckAfter: 5 * time.Minute,
+		Run: func(ctx context.Context, beat func()) error {
+			if a.mode.State().Enabled {
+				return nil
+			}
+			_, err := janitor.Run(ctx, beat)
+			return err
+		},
+	})
+	a.serverOpts = append(a.serverOpts, server.WithCleanup(janitor))
+	slog.Info("cleanup enabled",
+		slog.Duration("interval", cfg.Interval),
+		slog.Int("policies", len(cfg.Policies)),
+	)
+	return nil
+}
+
+// erasure serves DELETE /users/{id}/data and the user data export. Like
+// the janitor, the eraser deletes through the hooked repository;
+// anonymizing instead needs storage that can clear task owners. Both need
+// the admin key and a configured signing secret, as a report signed with a
+// random one could not be verified after a restart.
+func (a *App) erasure() error {
+	secret := []byte(a.cfg.Erasure.SigningSecret)
+	if len(secret) == 0 || a.cfg.Auth.AdminKey == "" {
+		slog.Info("user data requests need an admin key and erasure.signing_secret; DELETE /users/{id}/data and GET /users/{id}/export are not served")
+		return nil
+	}
+	var opts []erasure.Option
+	if anonymizer, ok := a.repo.(repository.Anonymizer); ok {
+		opts = append(opts, erasure.WithAnonymizer(anonymizer))
+	}
+	if a.webhooks != nil {
+		opts = append(opts, erasure.WithWebhooks(a.webhooks))
+	}
+	if a.auditLog != nil {
+		opts = append(opts, erasure.WithAudit(a.auditLog))
+	}
+	a.serverOpts = append(a.serverOpts, server.WithErasure(erasure.New(a.Repository, secret, opts...)), server.WithUserExport())
+	return nil
+}
+
+// server runs the scheduler and creates the HTTP server
+func (a *App) server() error {
+	a.run(a.sched.Run)
+	a.serverOpts = append(a.serverOpts,
+		server.WithScheduler(a.sched),
+		server.WithListeners(a.opts.APIListener, a.opts.RedirectListener),
+	)
+	if a.opts.Ready != nil {
+		a.serverOpts = append(a.serverOpts, server.WithReady(a.opts.Ready))
+	}
+	a.Server = server.NewServer(a.cfg.Server, a.TaskHandler, a.serverOpts...)
+	return nil
+}
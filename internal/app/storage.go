@@ -0,0 +1,247 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/config"
+	"github.com/light-bringer/cert-tasks/internal/demo"
+	"github.com/light-bringer/cert-tasks/internal/encryption"
+	"github.com/light-bringer/cert-tasks/internal/ids"
+	"github.com/light-bringer/cert-tasks/internal/personal"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+	"github.com/light-bringer/cert-tasks/internal/scheduler"
+	"github.com/light-bringer/cert-tasks/internal/seed"
+	"github.com/light-bringer/cert-tasks/internal/server"
+	"github.com/light-bringer/cert-tasks/internal/startup"
+	"github.com/light-bringer/cert-tasks/internal/tracing"
+)
+
+// openStorage opens the repository the storage DSN selects, unless one
+// was given
+func (a *App) openStorage() error {
+	cfg := a.cfg
+	backend, snapshotPath, _ := cfg.Storage.Backend() // validated by New
+	a.backend = backend
+	a.idFormat, _ = ids.ParseFormat(cfg.Storage.IDFormat)
+	a.repoOpts = []repository.MemoryOption{repository.WithIDFormat(a.idFormat)}
+	if cfg.Storage.NodeID != nil {
+		snowflake, _ := ids.NewSnowflake(*cfg.Storage.NodeID) // validated by New
+		a.repoOpts = append(a.repoOpts, repository.WithSnowflake(snowflake))
+	}
+	a.serverOpts = append(a.serverOpts, server.WithMiddleware(tracing.Middleware))
+
+	switch {
+	case a.opts.Repository != nil:
+		a.repo = a.opts.Repository
+	case backend == config.BackendFile:
+		if err := a.openSnapshot(snapshotPath); err != nil {
+			return err
+		}
+	default:
+		a.memRepo = repository.NewMemoryRepository(a.repoOpts...)
+		a.repo = a.memRepo
+	}
+	return nil
+}
+
+// openSnapshot stores tasks in the snapshot file at path, encrypted if a
+// key is configured
+func (a *App) openSnapshot(path string) error {
+	var keys *encryption.Keyring
+	if enc := a.cfg.Storage.Encryption; enc.Enabled() {
+		var err error
+		if keys, err = enc.Keyring(); err != nil {
+			return a.Report.Record("storage", "file "+path, fmt.Errorf("loading encryption key: %w", err))
+		}
+		slog.Info("snapshot encryption enabled", slog.String("key_id", keys.KeyID()))
+	}
+	fileRepo, err := repository.NewEncryptedFileRepository(path, keys, a.repoOpts...)
+	if err != nil {
+		return a.Report.Record("storage", "file "+path, fmt.Errorf("opening task snapshot: %w", err))
+	}
+	a.memRepo = fileRepo.MemoryRepository
+	a.repo = fileRepo
+	a.fileStore = fileRepo
+	return nil
+}
+
+// personalMode keeps tasks in the home directory, backs them up and
+// serves the UI
+func (a *App) personalMode() error {
+	if !a.cfg.Personal {
+		return nil
+	}
+	personalCfg, err := personal.DefaultConfig()
+	if err != nil {
+		return fmt.Errorf("resolving personal data directory: %w", err)
+	}
+	if err := personalCfg.Prepare(); err != nil {
+		return fmt.Errorf("preparing personal data directory: %w", err)
+	}
+
+	// An explicit STORAGE_DSN or repository replaces the default snapshot
+	// file
+	if a.fileStore == nil && a.cfg.Storage.DSN == "" && a.opts.Repository == nil {
+		if err := a.openSnapshot(personalCfg.SnapshotPath()); err != nil {
+			return err
+		}
+	}
+
+	if fileRepo, ok := a.repo.(*repository.FileRepository); ok {
+		backups := personal.NewBackups(personalCfg, func(f *os.File) error {
+			return fileRepo.WriteSnapshot(f)
+		})
+		a.sched.Add(scheduler.Job{
+			Name:       "personal-backup",
+			Interval:   personalCfg.BackupInterval,
+			StuckAfter: 5 * time.Minute,
+			RunAtStart: true,
+			Run: func(ctx context.Context, beat func()) error {
+				_, err := backups.Backup()
+				return err
+			},
+		})
+	}
+
+	a.serverOpts = append(a.serverOpts, server.WithUI())
+	slog.Info("personal mode enabled",
+		slog.String("data_dir", personalCfg.DataDir),
+		slog.String("ui", "http://"+a.cfg.Server.Addr+"/ui/"),
+	)
+	return nil
+}
+
+// writeBehind batches snapshot writes when configured
+func (a *App) writeBehind() error {
+	cfg := a.cfg.Storage
+	if a.fileStore == nil || cfg.WriteBehind <= 0 {
+		return nil
+	}
+	a.fileStore.EnableWriteBehind(cfg.WriteBehind, cfg.WriteBehindMaxPending)
+	slog.Info("write-behind enabled",
+		slog.Duration("max_delay", cfg.WriteBehind),
+		slog.Int("max_pending", cfg.WriteBehindMaxPending),
+	)
+	return nil
+}
+
+// loadFixtures loads the seed file and, in development mode, serves POST
+// /admin/seed. Fixtures replace all data, so they are only loaded into
+// memory storage.
+func (a *App) loadFixtures() error {
+	if a.opts.SeedFile == "" && !a.opts.DevMode {
+		return nil
+	}
+	if a.backend != config.BackendMemory || a.cfg.Personal || a.memRepo == nil {
+		return errors.New("loading fixtures: fixtures and development mode require memory storage")
+	}
+	a.seeder = seed.New(a.memRepo)
+	if path := a.opts.SeedFile; path != "" {
+		fx, err := seed.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading seed file: %w", err)
+		}
+		result, err := a.seeder.Load(fx)
+		if err != nil {
+			return fmt.Errorf("loading seed file: %w", err)
+		}
+		slog.Info("loaded fixtures",
+			slog.String("file", path),
+			slog.Int("workspaces", result.Workspaces),
+			slog.Int("api_keys", result.APIKeys),
+			slog.Int("tasks", result.Tasks),
+		)
+	}
+	if a.opts.DevMode {
+		a.serverOpts = append(a.serverOpts, server.WithSeed(a.seeder))
+		slog.Warn("development mode enabled; POST /admin/seed replaces all data")
+	}
+	return nil
+}
+
+// takeStores takes the optional stores the repository provides.
+// Workspaces and API keys are stored with the tasks, so file storage
+// persists them. This runs before demo mode wraps the repository.
+func (a *App) takeStores() error {
+	a.workspaces, _ = a.repo.(repository.WorkspaceRepository)
+	a.webhooks, _ = a.repo.(repository.WebhookRepository)
+	a.revisions, _ = a.repo.(repository.RevisionRepository)
+	if storage, ok := a.repo.(repository.Maintainer); ok {
+		a.serverOpts = append(a.serverOpts, server.WithStorage(storage))
+	}
+	return nil
+}
+
+// selfCheck checks that the storage answers and is in the current format,
+// migrating a snapshot if allowed, and that the TLS files are usable,
+// before anything listens
+func (a *App) selfCheck() error {
+	storage := "memory"
+	switch {
+	case a.opts.Repository != nil:
+		storage = "embedded repository"
+	case a.fileStore != nil && a.fileStore.Encrypted():
+		storage = "encrypted file " + a.fileStore.Path()
+	case a.fileStore != nil:
+		storage = "file " + a.fileStore.Path()
+	}
+	if err := a.Report.Record("storage", storage, startup.Storage(context.Background(), a.repo)); err != nil {
+		return err
+	}
+
+	if a.fileStore != nil {
+		detail, err := startup.Migrate(a.fileStore, a.cfg.Storage.Migrate)
+		if err := a.Report.Record("storage migrations", detail, err); err != nil {
+			return err
+		}
+		if detail != "" {
+			slog.Info("storage migrations", slog.String("result", detail))
+		}
+	}
+
+	if tlsCfg := a.cfg.Server.TLS; tlsCfg.Enabled() {
+		if err := a.Report.Record("tls", "", startup.TLS(tlsCfg)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// demoMode runs a capped, periodically wiped, watermarked public sandbox
+func (a *App) demoMode() error {
+	cfg := a.cfg.Demo
+	if !cfg.Enabled {
+		return nil
+	}
+	if a.backend != config.BackendMemory || a.cfg.Personal || a.memRepo == nil {
+		return errors.New("enabling demo mode: demo mode requires memory storage")
+	}
+	// Resets return to the fixtures, if any
+	var store demo.Resetter = a.memRepo
+	if a.seeder != nil {
+		store = a.seeder
+	}
+	demoMode := demo.New(store, cfg.Config())
+	a.sched.Add(scheduler.Job{
+		Name:       "demo-reset",
+		Interval:   cfg.ResetInterval,
+		StuckAfter: time.Minute,
+		Run: func(ctx context.Context, beat func()) error {
+			demoMode.Reset()
+			return nil
+		},
+	})
+
+	a.repo = repository.NewLimitedRepository(a.repo, cfg.MaxTasks)
+	a.serverOpts = append(a.serverOpts, server.WithMiddleware(demoMode.Middleware))
+	slog.Info("demo mode enabled",
+		slog.Int("max_tasks", cfg.MaxTasks),
+		slog.Duration("reset_interval", cfg.ResetInterval),
+	)
+	return nil
+}
@@ -0,0 +1,400 @@
+// Package audit records who changed what through the API. Every
+// authenticated mutating request becomes an Entry holding the actor, the
+// route, a summary of the payload (its field names, never their values),
+// the result and the client IP. Entries are kept in memory for GET /audit
+// and, when a file is configured, appended to it as JSON lines.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/light-bringer/cert-tasks/internal/auth"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+	"github.com/light-bringer/cert-tasks/internal/requestid"
+)
+
+// DefaultMaxEntries is how many entries are kept in memory for queries
+const DefaultMaxEntries = 10000
+
+// maxSummaryBytes bounds how much of a request body is read for its
+// summary; larger bodies are summarized by size only
+const maxSummaryBytes = 64 << 10
+
+// Results recorded in Entry.Result
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+// Entry is one audited request
+type Entry struct {
+	ID        int64     `json:"id"`
+	Time      time.Time `json:"time"`
+	Actor     string    `json:"actor"`
+	Workspace string    `json:"workspace,omitempty"`
+	Method    string    `json:"method"`
+	Route     string    `json:"route"`
+	Path      string    `json:"path"`
+	Payload   Payload   `json:"payload"`
+	Status    int       `json:"status"`
+	Result    string    `json:"result"`
+	IP        string    `json:"ip"`
+	RequestID string    `json:"request_id,omitempty"`
+}
+
+// Payload summarizes a request body without its content
+type Payload struct {
+	// Fields are the top-level members of a JSON object body, sorted
+	Fields []string `json:"fields,omitempty"`
+
+	// Bytes is the body size
+	Bytes int `json:"bytes"`
+}
+
+// Filter selects entries; zero fields match everything
+type Filter struct {
+	Actor     string
+	Workspace string
+	Method    string
+	Route     string
+	Result    string
+	Since     time.Time
+	Until     time.Time
+
+	// Limit caps the number of entries returned, newest first
+	Limit int
+}
+
+// matches reports whether e passes f
+func (f Filter) matches(e *Entry) bool {
+	return (f.Actor == "" || e.Actor == f.Actor) &&
+		(f.Workspace == "" || e.Workspace == f.Workspace) &&
+		(f.Method == "" || e.Method == f.Method) &&
+		(f.Route == "" || e.Route == f.Route) &&
+		(f.Result == "" || e.Result == f.Result) &&
+		(f.Since.IsZero() || !e.Time.Before(f.Since)) &&
+		(f.Until.IsZero() || e.Time.Before(f.Until))
+}
+
+// Log holds the most recent entries and appends every entry to an
+// optional sink
+type Log struct {
+	max int
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries []*Entry // oldest first, at most max
+	lastID  int64
+	sink    io.Writer
+
+	// path is the file sink's, which Anonymize rewrites
+	path string
+}
+
+// New creates a Log keeping the latest max entries in memory
+func New(max int) *Log {
+	return &Log{max: max, now: time.Now}
+}
+
+// Open creates a Log that appends entries to the file at path, creating
+// it if needed, and primes the in-memory entries from its tail so queries
+// survive restarts. The returned close function closes the file.
+func Open(path string, max int) (*Log, func() error, error) {
+	l := New(max)
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, nil, fmt.Errorf("opening audit log: %w", err)
+	}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			f.Close()
+			return nil, nil, fmt.Errorf("reading audit log line %d: %w", line, err)
+		}
+		l.keep(&e)
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("reading audit log: %w", err)
+	}
+
+	l.sink, l.path = f, path
+	return l, l.closeFile, nil
+}
+
+// closeFile closes the file sink, which Anonymize may have reopened
+func (l *Log) closeFile() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if c, ok := l.sink.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Record stores e, assigning its ID and, if unset, its time
+func (l *Log) Record(e Entry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if e.Time.IsZero() {
+		e.Time = l.now()
+	}
+	e.ID = l.lastID + 1
+	l.keep(&e)
+
+	if l.sink != nil {
+		line, _ := json.Marshal(e)
+		if _, err := l.sink.Write(append(line, '\n')); err != nil {
+			// The request has already been served; losing the durable
+			// copy must be visible to operators
+			slog.Error("writing audit entry failed", slog.Int64("audit_id", e.ID), slog.Any("error", err))
+		}
+	}
+}
+
+// keep appends e to the in-memory entries, dropping the oldest beyond max;
+// l.mu must be held or l not yet shared
+func (l *Log) keep(e *Entry) {
+	l.entries = append(l.entries, e)
+	if len(l.entries) > l.max {
+		l.entries = append(l.entries[:0], l.entries[len(l.entries)-l.max:]...)
+	}
+	if e.ID > l.lastID {
+		l.lastID = e.ID
+	}
+}
+
+// Query returns copies of the entries matching f, newest first, and how
+// many entries match before f.Limit applies
+func (l *Log) Query(f Filter) (entries []Entry, total int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	out := []Entry{}
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		e := l.entries[i]
+		if !f.matches(e) {
+			continue
+		}
+		total++
+		if f.Limit <= 0 || len(out) < f.Limit {
+			out = append(out, *e)
+		}
+	}
+	return out, total
+}
+
+// Anonymize replaces actor with replacement and removes the client IP in
+// every entry actor made, for erasing a user's data, and returns how many
+// entries changed. With a file sink the whole file is rewritten, not only
+// the entries kept in memory, and the count is the file's.
+func (l *Log) Anonymize(actor, replacement string) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n := 0
+	for _, e := range l.entries {
+		if e.Actor == actor {
+			anonymize(e, replacement)
+			n++
+		}
+	}
+	if l.path == "" {
+		return n, nil
+	}
+	return l.rewriteFile(actor, replacement)
+}
+
+// anonymize removes what identifies the actor of e
+func anonymize(e *Entry, replacement string) {
+	e.Actor = replacement
+	e.IP = ""
+}
+
+// rewriteFile anonymizes actor's entries in the file sink by writing a
+// copy and renaming it over the file, then reopens it for appending. l.mu
+// must be held.
+func (l *Log) rewriteFile(actor, replacement string) (int, error) {
+	src, err := os.Open(l.path)
+	if err != nil {
+		return 0, fmt.Errorf("opening audit log: %w", err)
+	}
+	defer src.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), filepath.Base(l.path)+".*.tmp")
+	if err != nil {
+		return 0, fmt.Errorf("rewriting audit log: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	n := 0
+	w := bufio.NewWriter(tmp)
+	scanner := bufio.NewScanner(src)
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return 0, fmt.Errorf("reading audit log line %d: %w", line, err)
+		}
+		if e.Actor == actor {
+			anonymize(&e, replacement)
+			n++
+		}
+		data, _ := json.Marshal(e)
+		w.Write(append(data, '\n'))
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("reading audit log: %w", err)
+	}
+	if err := w.Flush(); err != nil {
+		return 0, fmt.Errorf("rewriting audit log: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return 0, fmt.Errorf("rewriting audit log: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, fmt.Errorf("rewriting audit log: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), l.path); err != nil {
+		return 0, fmt.Errorf("rewriting audit log: %w", err)
+	}
+
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return 0, fmt.Errorf("reopening audit log: %w", err)
+	}
+	if c, ok := l.sink.(io.Closer); ok {
+		c.Close()
+	}
+	l.sink = f
+	return n, nil
+}
+
+// Middleware records mutating requests. It must run inside the chi router,
+// after authentication and workspace resolution, so the route, actor and
+// workspace are known. Requests without an authenticated caller are
+// recorded as fallbackActor, e.g. "admin" behind the admin key.
+func (l *Log) Middleware(fallbackActor string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body := &summaryBuffer{}
+			if r.Body != nil {
+				r.Body = struct {
+					io.Reader
+					io.Closer
+				}{io.TeeReader(r.Body, body), r.Body}
+			}
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			result := ResultSuccess
+			if status >= http.StatusBadRequest {
+				result = ResultFailure
+			}
+			route := r.URL.Path
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+
+			l.Record(Entry{
+				Actor:     actor(r, fallbackActor),
+				Workspace: repository.WorkspaceFromContext(r.Context()),
+				Method:    r.Method,
+				Route:     route,
+				Path:      r.URL.Path,
+				Payload:   body.summary(),
+				Status:    status,
+				Result:    result,
+				IP:        clientIP(r),
+				RequestID: requestid.FromRequest(r),
+			})
+		})
+	}
+}
+
+// actor identifies the caller the way rate limiting does: "key:<id>" for
+// API keys and "user:<subject>" for JWTs
+func actor(r *http.Request, fallback string) string {
+	if key, ok := auth.FromContext(r.Context()); ok {
+		return "key:" + strconv.FormatInt(key.ID, 10)
+	}
+	if subject, ok := auth.SubjectFromContext(r.Context()); ok {
+		return "user:" + subject
+	}
+	return fallback
+}
+
+// clientIP returns the client address of r without its port; behind a
+// trusted proxy forwarded.Middleware has already taken it from
+// X-Forwarded-For
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// summaryBuffer counts every body byte but keeps only the first
+// maxSummaryBytes for finding field names
+type summaryBuffer struct {
+	buf   bytes.Buffer
+	bytes int
+}
+
+func (b *summaryBuffer) Write(p []byte) (int, error) {
+	b.bytes += len(p)
+	if room := maxSummaryBytes - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(room, len(p))])
+	}
+	return len(p), nil
+}
+
+// summary returns the body's size and, for a complete JSON object, its
+// member names
+func (b *summaryBuffer) summary() Payload {
+	p := Payload{Bytes: b.bytes}
+	if b.bytes > maxSummaryBytes {
+		return p
+	}
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(b.buf.Bytes(), &members); err != nil {
+		return p
+	}
+	for name := range members {
+		p.Fields = append(p.Fields, name)
+	}
+	sort.Strings(p.Fields)
+	return p
+}
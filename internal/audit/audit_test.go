@@ -0,0 +1,159 @@
+package audit
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+func TestLog_Query(t *testing.T) {
+	l := New(3)
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, actor := range []string{"key:1", "key:2", "key:1", "user:alice"} {
+		result := ResultSuccess
+		if i == 2 {
+			result = ResultFailure
+		}
+		l.Record(Entry{Time: start.Add(time.Duration(i) * time.Hour), Actor: actor, Method: "POST", Route: "/tasks", Result: result})
+	}
+
+	ids := func(entries []Entry) []int64 {
+		var out []int64
+		for _, e := range entries {
+			out = append(out, e.ID)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []int64
+		total  int
+	}{
+		{"all, newest first, oldest dropped", Filter{}, []int64{4, 3, 2}, 3},
+		{"actor", Filter{Actor: "key:1"}, []int64{3}, 1},
+		{"result", Filter{Result: ResultSuccess}, []int64{4, 2}, 2},
+		{"since", Filter{Since: start.Add(2 * time.Hour)}, []int64{4, 3}, 2},
+		{"until", Filter{Until: start.Add(2 * time.Hour)}, []int64{2}, 1},
+		{"limit", Filter{Limit: 1}, []int64{4}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, total := l.Query(tt.filter)
+			if got := ids(entries); !slices.Equal(got, tt.want) || total != tt.total {
+				t.Errorf("Query() IDs = %v, total %d, want %v, total %d", got, total, tt.want, tt.total)
+			}
+		})
+	}
+}
+
+func TestOpen_PersistsEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	l, closeLog, err := Open(path, 10)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	l.Record(Entry{Actor: "key:1", Method: "POST", Route: "/tasks"})
+	l.Record(Entry{Actor: "key:2", Method: "DELETE", Route: "/tasks/{id}"})
+	closeLog()
+
+	reopened, closeLog, err := Open(path, 10)
+	if err != nil {
+		t.Fatalf("reopening error = %v", err)
+	}
+	defer closeLog()
+	reopened.Record(Entry{Actor: "admin", Method: "POST", Route: "/workspaces"})
+
+	entries, _ := reopened.Query(Filter{})
+	if len(entries) != 3 || entries[0].ID != 3 || entries[2].Actor != "key:1" {
+		t.Errorf("entries after reopening = %+v, want 3 with IDs continuing", entries)
+	}
+}
+
+func TestLog_Anonymize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	// Only the latest two entries are kept in memory
+	l, closeLog, err := Open(path, 2)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer closeLog()
+	l.Record(Entry{Actor: "user:alice", Method: "POST", Route: "/tasks", IP: "192.0.2.1"})
+	l.Record(Entry{Actor: "key:1", Method: "POST", Route: "/tasks", IP: "192.0.2.2"})
+	l.Record(Entry{Actor: "user:alice", Method: "DELETE", Route: "/tasks/{id}", IP: "192.0.2.1"})
+
+	n, err := l.Anonymize("user:alice", "erased")
+	if err != nil || n != 2 {
+		t.Fatalf("Anonymize() = %d, %v, want 2 entries from the file", n, err)
+	}
+	l.Record(Entry{Actor: "admin", Method: "DELETE", Route: "/users/{id}/data"})
+
+	if entries, _ := l.Query(Filter{Actor: "user:alice"}); len(entries) != 0 {
+		t.Errorf("entries still naming alice = %+v", entries)
+	}
+	data, _ := os.ReadFile(path)
+	if bytes.Contains(data, []byte("alice")) || bytes.Contains(data, []byte("192.0.2.1")) {
+		t.Errorf("file = %s, want alice and the address gone", data)
+	}
+	if lines := bytes.Count(data, []byte("\n")); lines != 4 || !bytes.Contains(data, []byte("192.0.2.2")) {
+		t.Errorf("file = %s, want 4 entries with others untouched", data)
+	}
+}
+
+func TestLog_Middleware(t *testing.T) {
+	l := New(10)
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			next.ServeHTTP(w, req.WithContext(repository.WithWorkspace(req.Context(), "acme")))
+		})
+	})
+	r.Use(l.Middleware("admin"))
+	r.Post("/tasks", func(w http.ResponseWriter, req *http.Request) {
+		var buf [256]byte
+		req.Body.Read(buf[:])
+		w.WriteHeader(http.StatusCreated)
+	})
+	r.Put("/tasks/{id}", func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	})
+	r.Get("/tasks", func(w http.ResponseWriter, req *http.Request) {})
+
+	serve := func(method, path, body string) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.RemoteAddr = "203.0.113.7:51234"
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	serve("POST", "/tasks", `{"title":"secret plans","status":"todo"}`)
+	serve("PUT", "/tasks/7", `{}`)
+	serve("GET", "/tasks", "")
+
+	entries, _ := l.Query(Filter{})
+	if len(entries) != 2 {
+		t.Fatalf("recorded %d entries, want 2 (reads are not audited)", len(entries))
+	}
+
+	update, create := entries[0], entries[1]
+	if create.Actor != "admin" || create.Workspace != "acme" || create.Route != "/tasks" || create.Status != http.StatusCreated ||
+		create.Result != ResultSuccess || create.IP != "203.0.113.7" {
+		t.Errorf("create entry = %+v", create)
+	}
+	if !slices.Equal(create.Payload.Fields, []string{"status", "title"}) || create.Payload.Bytes != 40 {
+		t.Errorf("create payload = %+v, want field names and size only", create.Payload)
+	}
+	if update.Route != "/tasks/{id}" || update.Path != "/tasks/7" || update.Result != ResultFailure {
+		t.Errorf("update entry = %+v, want failed /tasks/{id}", update)
+	}
+}
@@ -0,0 +1,264 @@
+// Package auth authenticates task API requests with API keys or JWTs.
+// Keys are random secrets handed out once; only their SHA-256 hash is
+// stored, which is enough for high-entropy secrets and lets lookups go
+// straight to the repository by hash. JWT subjects are users: their
+// requests are scoped to the tasks they own. Either credential may be
+// bound to a workspace, which the request is then confined to.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/light-bringer/cert-tasks/internal/logging"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+// HeaderAPIKey carries an API key as an alternative to
+// "Authorization: Bearer <key>"
+//
+//api:changelog 0.2.0 added header Authorization: Task API requests authenticate with "Bearer <api key>" when auth is enabled
+//api:changelog 0.2.0 changed header Authorization: Also accepts "Bearer <JWT>"; JWT users only see and modify their own tasks, in the workspace named by the workspace_id claim if present
+//api:changelog 0.2.0 changed header Authorization: A JWT's zoneinfo claim sets the time zone the user's due dates are read and shown in, unless X-Timezone overrides it
+//api:changelog 0.2.0 added header X-API-Key: Alternative to the Authorization header for passing an API key
+const HeaderAPIKey = "X-API-Key"
+
+// keyPrefix marks cert-tasks secrets so they are recognizable in config
+// files and secret scanners
+const keyPrefix = "ctk_"
+
+// prefixLength is how much of a secret is kept in clear to identify the key
+const prefixLength = len(keyPrefix) + 8
+
+// ErrInvalidKey is returned for a missing, malformed or unknown key
+var ErrInvalidKey = errors.New("invalid api key")
+
+// NewKey generates a key with a fresh secret. The secret is returned
+// separately and is not recoverable from the key.
+func NewKey(name string, scope models.APIKeyScope, workspaceID string) (*models.APIKey, string, error) {
+	secret, _, err := NewSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	key, err := KeyFromSecret(name, scope, workspaceID, secret)
+	return key, secret, err
+}
+
+// NewSecret generates a fresh secret and returns it with its prefix, the
+// part stored in clear to identify the key. Rotating a key stores the
+// prefix and Hash(secret) in place of the old ones.
+func NewSecret() (secret, prefix string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("generating api key: %w", err)
+	}
+	secret = keyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return secret, secret[:prefixLength], nil
+}
+
+// minSecretLength keeps chosen secrets from being guessable
+const minSecretLength = 32
+
+// KeyFromSecret returns the key for a secret chosen by the caller, such as
+// a fixture that tests authenticate with. The secret must start with
+// "ctk_" and be at least 32 characters long.
+func KeyFromSecret(name string, scope models.APIKeyScope, workspaceID, secret string) (*models.APIKey, error) {
+	if !strings.HasPrefix(secret, keyPrefix) || len(secret) < minSecretLength {
+		return nil, fmt.Errorf("api key secret must start with %q and be at least %d characters", keyPrefix, minSecretLength)
+	}
+	return &models.APIKey{
+		Name:        name,
+		Scope:       scope,
+		Prefix:      secret[:prefixLength],
+		WorkspaceID: workspaceID,
+		Hash:        Hash(secret),
+	}, nil
+}
+
+// Hash returns the stored form of a secret
+func Hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Allows reports whether a key with the given scope may make a request
+// with method; read keys are limited to safe methods
+func Allows(scope models.APIKeyScope, method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return scope.IsValid()
+	}
+	return scope == models.ScopeReadWrite
+}
+
+// Authenticator checks API keys against a repository, and JWTs if
+// configured
+type Authenticator struct {
+	keys repository.APIKeyRepository
+	jwt  *JWTVerifier
+}
+
+// Option configures an Authenticator
+type Option func(*Authenticator)
+
+// WithJWT also accepts bearer JWTs validated by verifier
+func WithJWT(verifier *JWTVerifier) Option {
+	return func(a *Authenticator) {
+		a.jwt = verifier
+	}
+}
+
+// New creates an authenticator for the keys in repo
+func New(keys repository.APIKeyRepository, opts ...Option) *Authenticator {
+	a := &Authenticator{keys: keys}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Authenticate returns the key for secret, or ErrInvalidKey
+func (a *Authenticator) Authenticate(ctx context.Context, secret string) (*models.APIKey, error) {
+	if !strings.HasPrefix(secret, keyPrefix) {
+		return nil, ErrInvalidKey
+	}
+	key, err := a.keys.GetAPIKeyByHash(ctx, Hash(secret))
+	if errors.Is(err, repository.ErrAPIKeyNotFound) {
+		return nil, ErrInvalidKey
+	}
+	return key, err
+}
+
+// Middleware requires a valid API key with a scope allowing the request,
+// or a valid JWT. Requests without valid credentials are passed to
+// unauthorized after setting WWW-Authenticate; requests the key's scope
+// does not allow are passed to forbidden. The key is available to later
+// handlers via FromContext; a JWT's subject via SubjectFromContext, and
+// it scopes repository calls to the subject's tasks. The workspace either
+// credential is bound to is available via WorkspaceFromContext.
+func (a *Authenticator) Middleware(unauthorized, forbidden http.HandlerFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			secret := credential(r)
+			if a.jwt != nil && looksLikeJWT(secret) {
+				claims, err := a.jwt.Verify(r.Context(), secret)
+				if err != nil {
+					logging.FromContext(r.Context()).Info("rejected token", slog.Any("error", err))
+					w.Header().Set("WWW-Authenticate", `Bearer realm="cert-tasks", error="invalid_token"`)
+					unauthorized(w, r)
+					return
+				}
+				ctx := context.WithValue(r.Context(), subjectKey{}, claims.Subject)
+				ctx = withWorkspace(ctx, claims.WorkspaceID)
+				if claims.Timezone != "" {
+					ctx = context.WithValue(ctx, timezoneKey{}, claims.Timezone)
+				}
+				next.ServeHTTP(w, r.WithContext(repository.WithOwner(ctx, claims.Subject)))
+				return
+			}
+
+			key, err := a.Authenticate(r.Context(), secret)
+			if err != nil {
+				if !errors.Is(err, ErrInvalidKey) {
+					logging.FromContext(r.Context()).Error("looking up api key", slog.Any("error", err))
+				}
+				w.Header().Set("WWW-Authenticate", `Bearer realm="cert-tasks"`)
+				unauthorized(w, r)
+				return
+			}
+
+			if !Allows(key.Scope, r.Method) {
+				forbidden(w, r)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), contextKey{}, key)
+			next.ServeHTTP(w, r.WithContext(withWorkspace(ctx, key.WorkspaceID)))
+		})
+	}
+}
+
+// AdminMiddleware requires the configured admin key, for endpoints that
+// manage the server rather than tasks. An empty adminKey lets no request
+// through.
+func AdminMiddleware(adminKey string, unauthorized http.HandlerFunc) func(http.Handler) http.Handler {
+	want := []byte(adminKey)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(want) == 0 || subtle.ConstantTimeCompare([]byte(credential(r)), want) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="cert-tasks admin"`)
+				unauthorized(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// contextKey is the context key for the authenticated API key
+type contextKey struct{}
+
+// subjectKey is the context key for the authenticated JWT subject
+type subjectKey struct{}
+
+// workspaceKey is the context key for the workspace the credential is
+// bound to
+type workspaceKey struct{}
+
+// timezoneKey is the context key for the time zone of the JWT's user
+type timezoneKey struct{}
+
+// FromContext returns the API key a request authenticated with
+func FromContext(ctx context.Context) (*models.APIKey, bool) {
+	key, ok := ctx.Value(contextKey{}).(*models.APIKey)
+	return key, ok
+}
+
+// SubjectFromContext returns the subject of the JWT a request
+// authenticated with
+func SubjectFromContext(ctx context.Context) (string, bool) {
+	subject, ok := ctx.Value(subjectKey{}).(string)
+	return subject, ok
+}
+
+// WorkspaceFromContext returns the workspace the request's credential is
+// bound to, if any
+func WorkspaceFromContext(ctx context.Context) (string, bool) {
+	workspace, ok := ctx.Value(workspaceKey{}).(string)
+	return workspace, ok
+}
+
+// TimezoneFromContext returns the time zone named by the zoneinfo claim of
+// the JWT a request authenticated with. It is not checked; callers fall
+// back to another zone if it does not load.
+func TimezoneFromContext(ctx context.Context) (string, bool) {
+	timezone, ok := ctx.Value(timezoneKey{}).(string)
+	return timezone, ok
+}
+
+// withWorkspace records the credential's workspace binding, if it has one
+func withWorkspace(ctx context.Context, workspace string) context.Context {
+	if workspace == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, workspaceKey{}, workspace)
+}
+
+// credential returns the secret from the Authorization or X-API-Key header
+func credential(r *http.Request) string {
+	if scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return r.Header.Get(HeaderAPIKey)
+}
@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+func TestNewKey(t *testing.T) {
+	key, secret, err := NewKey("ci", models.ScopeRead, "")
+	if err != nil {
+		t.Fatalf("NewKey() error = %v", err)
+	}
+	if !strings.HasPrefix(secret, keyPrefix) || !strings.HasPrefix(secret, key.Prefix) {
+		t.Errorf("secret %q, prefix %q: want %q prefix shared by both", secret, key.Prefix, keyPrefix)
+	}
+	if key.Hash != Hash(secret) || strings.Contains(key.Hash, secret) {
+		t.Errorf("hash = %q, want the hash of the secret", key.Hash)
+	}
+
+	_, other, _ := NewKey("ci", models.ScopeRead, "")
+	if other == secret {
+		t.Error("two keys share a secret")
+	}
+}
+
+func TestKeyFromSecret(t *testing.T) {
+	secret := "ctk_fixture_0123456789abcdefghijkl"
+	key, err := KeyFromSecret("fixture", models.ScopeReadWrite, "acme", secret)
+	if err != nil || key.Hash != Hash(secret) || key.Prefix != "ctk_fixture_" || key.WorkspaceID != "acme" {
+		t.Errorf("KeyFromSecret = %+v, %v", key, err)
+	}
+	for _, bad := range []string{"ctk_short", strings.Repeat("x", 40)} {
+		if _, err := KeyFromSecret("fixture", models.ScopeRead, "", bad); err == nil {
+			t.Errorf("KeyFromSecret(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestAllows(t *testing.T) {
+	tests := []struct {
+		scope  models.APIKeyScope
+		method string
+		want   bool
+	}{
+		{models.ScopeRead, http.MethodGet, true},
+		{models.ScopeRead, http.MethodHead, true},
+		{models.ScopeRead, http.MethodPost, false},
+		{models.ScopeRead, http.MethodDelete, false},
+		{models.ScopeReadWrite, http.MethodGet, true},
+		{models.ScopeReadWrite, http.MethodPut, true},
+		{"admin", http.MethodGet, false},
+	}
+
+	for _, tt := range tests {
+		if got := Allows(tt.scope, tt.method); got != tt.want {
+			t.Errorf("Allows(%q, %s) = %v, want %v", tt.scope, tt.method, got, tt.want)
+		}
+	}
+}
+
+func TestAuthenticator_Middleware(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	newKey := func(scope models.APIKeyScope) string {
+		key, secret, _ := NewKey("test", scope, "")
+		repo.CreateAPIKey(context.Background(), key)
+		return secret
+	}
+	readKey := newKey(models.ScopeRead)
+	writeKey := newKey(models.ScopeReadWrite)
+
+	h := New(repo).Middleware(
+		func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusUnauthorized) },
+		func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusForbidden) },
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key, ok := FromContext(r.Context()); !ok || key.Name != "test" {
+			t.Errorf("FromContext() = %+v, %v; want the authenticated key", key, ok)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name       string
+		method     string
+		header     string
+		value      string
+		wantStatus int
+	}{
+		{"bearer", "GET", "Authorization", "Bearer " + readKey, http.StatusOK},
+		{"bearer is case-insensitive", "GET", "Authorization", "bearer " + readKey, http.StatusOK},
+		{"x-api-key", "GET", HeaderAPIKey, readKey, http.StatusOK},
+		{"write with read_write key", "POST", HeaderAPIKey, writeKey, http.StatusOK},
+		{"write with read key", "POST", HeaderAPIKey, readKey, http.StatusForbidden},
+		{"missing", "GET", "", "", http.StatusUnauthorized},
+		{"unknown key", "GET", HeaderAPIKey, keyPrefix + "unknown", http.StatusUnauthorized},
+		{"not a key", "GET", "Authorization", "Basic dXNlcjpwYXNz", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/tasks", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %v, want %v", rec.Code, tt.wantStatus)
+			}
+			if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 response has no WWW-Authenticate")
+			}
+		})
+	}
+}
+
+func TestAdminMiddleware(t *testing.T) {
+	adminKey := strings.Repeat("a", 32)
+	h := AdminMiddleware(adminKey, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, tt := range []struct {
+		credential string
+		want       int
+	}{
+		{adminKey, http.StatusOK},
+		{adminKey[:31], http.StatusUnauthorized},
+		{"", http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest("POST", "/apikeys", nil)
+		req.Header.Set("Authorization", "Bearer "+tt.credential)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("credential %q: status = %v, want %v", tt.credential, rec.Code, tt.want)
+		}
+	}
+
+	// An unset key must not match a request without a credential
+	unset := AdminMiddleware("", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	rec := httptest.NewRecorder()
+	unset.ServeHTTP(rec, httptest.NewRequest("POST", "/apikeys", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("empty admin key: status = %v, want %v", rec.Code, http.StatusUnauthorized)
+	}
+}
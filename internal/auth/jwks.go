@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// minRefreshInterval limits how often an unknown kid triggers a refetch,
+// so tokens with made-up key IDs cannot hammer the JWKS endpoint
+const minRefreshInterval = time.Minute
+
+// maxJWKSBytes caps the size of a fetched key set
+const maxJWKSBytes = 1 << 20
+
+// jwks caches the public keys published at a JWKS URL by key ID
+type jwks struct {
+	url    string
+	client *http.Client
+	now    func() time.Time
+
+	mu      sync.RWMutex
+	keys    map[string]any
+	fetched time.Time
+}
+
+func newJWKS(url string, client *http.Client) *jwks {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &jwks{url: url, client: client, now: time.Now}
+}
+
+// key returns the key with ID kid, refetching the set once if the key is
+// unknown, since the issuer may have rotated keys
+func (s *jwks) key(ctx context.Context, kid string) (any, error) {
+	s.mu.RLock()
+	key, ok := s.keys[kid]
+	stale := s.now().Sub(s.fetched) >= minRefreshInterval
+	s.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	if stale {
+		if err := s.refresh(ctx); err != nil {
+			return nil, err
+		}
+		s.mu.RLock()
+		key, ok = s.keys[kid]
+		s.mu.RUnlock()
+		if ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key ID %q", kid)
+}
+
+// refresh replaces the cached keys with the current set
+func (s *jwks) refresh(ctx context.Context) error {
+	keys, err := s.fetch(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetched = s.now()
+	if err != nil {
+		slog.Warn("fetching JWKS failed", slog.String("url", s.url), slog.Any("error", err))
+		return err
+	}
+	s.keys = keys
+	return nil
+}
+
+// fetch downloads and parses the key set
+func (s *jwks) fetch(ctx context.Context) (map[string]any, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("fetching JWKS: %w", err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching JWKS: unexpected status %s", resp.Status)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(nil, resp.Body, maxJWKSBytes)).Decode(&set); err != nil {
+		return nil, fmt.Errorf("decoding JWKS: %w", err)
+	}
+
+	keys := make(map[string]any, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			// One unusable key must not take down the others
+			slog.Warn("skipping JWKS key", slog.String("kid", k.Kid), slog.Any("error", err))
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+// jwk is a JSON Web Key (RFC 7517) holding an RSA or EC public key
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey converts the JWK to an *rsa.PublicKey or *ecdsa.PublicKey
+func (k jwk) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("decoding n: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("decoding e: %w", err)
+		}
+		exp := new(big.Int).SetBytes(e)
+		if len(n) == 0 || !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA key")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("decoding x: %w", err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("decoding y: %w", err)
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) != size || len(y) != size {
+			return nil, errors.New("invalid EC key coordinates")
+		}
+		point := append(append([]byte{4}, x...), y...)
+		return ecdsa.ParseUncompressedPublicKey(curve, point)
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrInvalidToken is returned for a JWT that fails validation
+var ErrInvalidToken = errors.New("invalid token")
+
+// clockSkew is tolerated between the token issuer's clock and ours
+const clockSkew = 30 * time.Second
+
+// JWTConfig configures JWT validation. At least one of Secret and JWKSURL
+// must be set.
+type JWTConfig struct {
+	// Secret verifies HS256/384/512 tokens
+	Secret string
+
+	// JWKSURL is fetched for the public keys verifying RS*, PS* and ES*
+	// tokens, matched by the token's "kid" header
+	JWKSURL string
+
+	// Client fetches JWKSURL; it should be an outbound.Client
+	Client *http.Client
+
+	// Issuer and Audience, when set, must match the token's claims
+	Issuer   string
+	Audience string
+}
+
+// Claims are the parts of a verified JWT the API uses
+type Claims struct {
+	// Subject is the user the token was issued to
+	Subject string
+
+	// WorkspaceID, from the "workspace_id" claim, binds the token to one
+	// workspace; empty if the token may pick one
+	WorkspaceID string
+
+	// Timezone, from the OpenID Connect "zoneinfo" claim, is the user's
+	// IANA time zone as kept in their identity provider profile
+	Timezone string
+}
+
+// tokenClaims are the registered claims plus workspace_id and zoneinfo
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	WorkspaceID string `json:"workspace_id"`
+	Zoneinfo    string `json:"zoneinfo"`
+}
+
+// JWTVerifier validates JWTs and extracts their claims
+type JWTVerifier struct {
+	secret []byte
+	jwks   *jwks
+	parser *jwt.Parser
+}
+
+// NewJWTVerifier creates a verifier for cfg. Tokens must carry exp and
+// sub claims and be signed with an algorithm matching a configured key
+// source, so an HMAC token can never be checked against a public key.
+func NewJWTVerifier(cfg JWTConfig) *JWTVerifier {
+	v := &JWTVerifier{}
+	var methods []string
+	if cfg.Secret != "" {
+		v.secret = []byte(cfg.Secret)
+		methods = append(methods, "HS256", "HS384", "HS512")
+	}
+	if cfg.JWKSURL != "" {
+		v.jwks = newJWKS(cfg.JWKSURL, cfg.Client)
+		methods = append(methods, "RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512")
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(clockSkew),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	v.parser = jwt.NewParser(opts...)
+	return v
+}
+
+// Verify validates token and returns its claims
+func (v *JWTVerifier) Verify(ctx context.Context, token string) (Claims, error) {
+	var c tokenClaims
+	_, err := v.parser.ParseWithClaims(token, &c, func(t *jwt.Token) (any, error) {
+		return v.key(ctx, t)
+	})
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	if c.Subject == "" {
+		return Claims{}, fmt.Errorf("%w: no subject", ErrInvalidToken)
+	}
+	return Claims{Subject: c.Subject, WorkspaceID: c.WorkspaceID, Timezone: c.Zoneinfo}, nil
+}
+
+// Refresh fetches the JWKS again, if one is configured
+func (v *JWTVerifier) Refresh(ctx context.Context) error {
+	if v.jwks == nil {
+		return nil
+	}
+	return v.jwks.refresh(ctx)
+}
+
+// key returns the key verifying t
+func (v *JWTVerifier) key(ctx context.Context, t *jwt.Token) (any, error) {
+	if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok {
+		if v.secret == nil {
+			return nil, errors.New("HMAC tokens are not accepted")
+		}
+		return v.secret, nil
+	}
+
+	if v.jwks == nil {
+		return nil, errors.New("public key tokens are not accepted")
+	}
+	kid, _ := t.Header["kid"].(string)
+	return v.jwks.key(ctx, kid)
+}
+
+// looksLikeJWT tells bearer JWTs apart from API key secrets
+func looksLikeJWT(credential string) bool {
+	return !strings.HasPrefix(credential, keyPrefix) && strings.Count(credential, ".") == 2
+}
@@ -0,0 +1,233 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+// sign returns a token for claims signed with key using method
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// claims returns valid claims for subject, with overrides applied
+func claims(subject string, overrides jwt.MapClaims) jwt.MapClaims {
+	c := jwt.MapClaims{"sub": subject, "exp": time.Now().Add(time.Hour).Unix()}
+	for k, v := range overrides {
+		if v == nil {
+			delete(c, k)
+		} else {
+			c[k] = v
+		}
+	}
+	return c
+}
+
+func TestJWTVerifier_HMAC(t *testing.T) {
+	v := NewJWTVerifier(JWTConfig{Secret: testSecret, Issuer: "https://login.example.com", Audience: "cert-tasks"})
+	valid := jwt.MapClaims{"iss": "https://login.example.com", "aud": "cert-tasks"}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantSub string
+	}{
+		{"valid", sign(t, jwt.SigningMethodHS256, "", []byte(testSecret), claims("alice", valid)), "alice"},
+		{"HS512", sign(t, jwt.SigningMethodHS512, "", []byte(testSecret), claims("alice", valid)), "alice"},
+		{"wrong secret", sign(t, jwt.SigningMethodHS256, "", []byte(strings.Repeat("x", 32)), claims("alice", valid)), ""},
+		{"expired", sign(t, jwt.SigningMethodHS256, "", []byte(testSecret), claims("alice", jwt.MapClaims{"iss": valid["iss"], "aud": valid["aud"], "exp": time.Now().Add(-time.Hour).Unix()})), ""},
+		{"no expiry", sign(t, jwt.SigningMethodHS256, "", []byte(testSecret), claims("alice", jwt.MapClaims{"iss": valid["iss"], "aud": valid["aud"], "exp": nil})), ""},
+		{"no subject", sign(t, jwt.SigningMethodHS256, "", []byte(testSecret), claims("", valid)), ""},
+		{"wrong issuer", sign(t, jwt.SigningMethodHS256, "", []byte(testSecret), claims("alice", jwt.MapClaims{"iss": "https://evil.example.com", "aud": "cert-tasks"})), ""},
+		{"wrong audience", sign(t, jwt.SigningMethodHS256, "", []byte(testSecret), claims("alice", jwt.MapClaims{"iss": valid["iss"], "aud": "other"})), ""},
+		{"alg none", sign(t, jwt.SigningMethodNone, "", jwt.UnsafeAllowNoneSignatureType, claims("alice", valid)), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := v.Verify(context.Background(), tt.token)
+			if tt.wantSub == "" {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Errorf("Verify() = %q, %v; want ErrInvalidToken", c.Subject, err)
+				}
+				return
+			}
+			if err != nil || c.Subject != tt.wantSub {
+				t.Errorf("Verify() = %q, %v; want %q", c.Subject, err, tt.wantSub)
+			}
+		})
+	}
+}
+
+// jwksServer serves the public keys given to it as a JWKS and counts
+// fetches
+type jwksServer struct {
+	*httptest.Server
+	keys    atomic.Value // []map[string]string
+	fetches atomic.Int32
+}
+
+func newJWKSServer(t *testing.T) *jwksServer {
+	s := &jwksServer{}
+	s.keys.Store([]map[string]string{})
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.fetches.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{"keys": s.keys.Load()})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) publish(keys ...map[string]string) {
+	s.keys.Store(keys)
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{"kty": "RSA", "kid": kid, "use": "sig", "n": b64(key.N.Bytes()), "e": b64(big.NewInt(int64(key.E)).Bytes())}
+}
+
+func ecJWK(kid string, key *ecdsa.PublicKey) map[string]string {
+	point, _ := key.Bytes()
+	size := (len(point) - 1) / 2
+	return map[string]string{"kty": "EC", "kid": kid, "crv": key.Curve.Params().Name, "x": b64(point[1 : 1+size]), "y": b64(point[1+size:])}
+}
+
+func TestJWTVerifier_JWKS(t *testing.T) {
+	ctx := context.Background()
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	srv := newJWKSServer(t)
+	srv.publish(rsaJWK("rsa-1", &rsaKey.PublicKey), ecJWK("ec-1", &ecKey.PublicKey))
+	v := NewJWTVerifier(JWTConfig{JWKSURL: srv.URL, Client: srv.Client()})
+	if err := v.Refresh(ctx); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	for name, token := range map[string]string{
+		"RS256": sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims("alice", nil)),
+		"PS256": sign(t, jwt.SigningMethodPS256, "rsa-1", rsaKey, claims("alice", nil)),
+		"ES256": sign(t, jwt.SigningMethodES256, "ec-1", ecKey, claims("alice", nil)),
+	} {
+		if c, err := v.Verify(ctx, token); err != nil || c.Subject != "alice" {
+			t.Errorf("%s: Verify() = %q, %v; want alice", name, c.Subject, err)
+		}
+	}
+
+	// An HMAC token must not be checked against a public key, whatever
+	// secret it was signed with
+	pub, _ := json.Marshal(rsaJWK("rsa-1", &rsaKey.PublicKey))
+	forged := sign(t, jwt.SigningMethodHS256, "rsa-1", pub, claims("mallory", nil))
+	if _, err := v.Verify(ctx, forged); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("HMAC token against JWKS: err = %v, want ErrInvalidToken", err)
+	}
+
+	// A token signed by a key that is not published is rejected
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	if _, err := v.Verify(ctx, sign(t, jwt.SigningMethodRS256, "rsa-1", other, claims("mallory", nil))); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("wrong key: err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestJWTVerifier_KeyRotation(t *testing.T) {
+	ctx := context.Background()
+	oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	srv := newJWKSServer(t)
+	srv.publish(rsaJWK("old", &oldKey.PublicKey))
+	v := NewJWTVerifier(JWTConfig{JWKSURL: srv.URL, Client: srv.Client()})
+	now := time.Now()
+	v.jwks.now = func() time.Time { return now }
+	v.Refresh(ctx)
+
+	// Unknown key IDs refetch at most once per minRefreshInterval
+	rotated := sign(t, jwt.SigningMethodRS256, "new", newKey, claims("alice", nil))
+	srv.publish(rsaJWK("old", &oldKey.PublicKey), rsaJWK("new", &newKey.PublicKey))
+	if _, err := v.Verify(ctx, rotated); err == nil {
+		t.Fatal("Verify() succeeded before the refresh interval passed")
+	}
+	if n := srv.fetches.Load(); n != 1 {
+		t.Errorf("fetches = %d, want 1", n)
+	}
+
+	now = now.Add(minRefreshInterval)
+	if c, err := v.Verify(ctx, rotated); err != nil || c.Subject != "alice" {
+		t.Errorf("Verify() after rotation = %q, %v; want alice", c.Subject, err)
+	}
+	if n := srv.fetches.Load(); n != 2 {
+		t.Errorf("fetches = %d, want 2", n)
+	}
+}
+
+func TestAuthenticator_JWT(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	a := New(repo, WithJWT(NewJWTVerifier(JWTConfig{Secret: testSecret})))
+
+	var subject, owner, workspace, timezone string
+	h := a.Middleware(
+		func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusUnauthorized) },
+		func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusForbidden) },
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subject, _ = SubjectFromContext(r.Context())
+		owner = repository.OwnerFromContext(r.Context())
+		workspace, _ = WorkspaceFromContext(r.Context())
+		timezone, _ = TimezoneFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest("POST", "/tasks", nil)
+	req.Header.Set("Authorization", "Bearer "+sign(t, jwt.SigningMethodHS256, "", []byte(testSecret), claims("alice", nil)))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || subject != "alice" || owner != "alice" {
+		t.Errorf("status %v, subject %q, owner %q; want 200 scoped to alice", rec.Code, subject, owner)
+	}
+	if workspace != "" {
+		t.Errorf("workspace = %q for a token without workspace_id, want none", workspace)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+sign(t, jwt.SigningMethodHS256, "", []byte(testSecret), claims("alice", jwt.MapClaims{"workspace_id": "acme", "zoneinfo": "Europe/Paris"})))
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || workspace != "acme" || timezone != "Europe/Paris" {
+		t.Errorf("status %v, workspace %q, timezone %q; want 200 bound to acme in Europe/Paris", rec.Code, workspace, timezone)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+sign(t, jwt.SigningMethodHS256, "", []byte(strings.Repeat("x", 32)), claims("alice", nil)))
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Header().Get("WWW-Authenticate"), "invalid_token") {
+		t.Errorf("bad signature: status %v, WWW-Authenticate %q; want 401 invalid_token", rec.Code, rec.Header().Get("WWW-Authenticate"))
+	}
+}
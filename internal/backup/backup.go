@@ -0,0 +1,291 @@
+// Package backup writes the whole repository to an archive in an object
+// store and restores it from one. An archive is a gzipped tarball holding
+// metadata.json and one NDJSON file per kind of record: tasks, revisions,
+// workspaces, API keys (with their hashes) and webhooks (with their
+// secrets), so it is as sensitive as the storage itself.
+//
+// A restore merges the archive with what is stored under a Policy, and can
+// be run as a dry run that reports what it would change.
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/blob"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+	"github.com/light-bringer/cert-tasks/internal/version"
+)
+
+// Format is the version of the archive layout. Archives in a newer format
+// are refused.
+const Format = 1
+
+// metadataFile is the archive entry describing the backup
+const metadataFile = "metadata.json"
+
+// The archive entries holding records, one JSON object per line
+const (
+	tasksFile      = "tasks.ndjson"
+	revisionsFile  = "revisions.ndjson"
+	workspacesFile = "workspaces.ndjson"
+	apiKeysFile    = "api_keys.ndjson"
+	webhooksFile   = "webhooks.ndjson"
+)
+
+var (
+	// ErrInvalidArchive is returned when an archive cannot be read
+	ErrInvalidArchive = errors.New("invalid backup archive")
+
+	// ErrNotFound is returned when the store has no archive by the name
+	ErrNotFound = errors.New("backup not found")
+
+	// ErrConflict is returned by a restore with PolicyFail when the
+	// archive holds records that are already stored
+	ErrConflict = errors.New("backup conflicts with stored records")
+)
+
+// Counts is how many records of each kind a backup holds
+type Counts struct {
+	Tasks      int `json:"tasks"`
+	Revisions  int `json:"revisions"`
+	Workspaces int `json:"workspaces"`
+	APIKeys    int `json:"api_keys"`
+	Webhooks   int `json:"webhooks"`
+}
+
+// Metadata is the metadata.json entry of an archive
+type Metadata struct {
+	Format          int          `json:"format"`
+	CreatedAt       time.Time    `json:"created_at"`
+	Server          version.Info `json:"server"`
+	SnapshotVersion int          `json:"snapshot_version"`
+	LastID          int64        `json:"last_id"`
+	Counts          Counts       `json:"counts"`
+}
+
+// Info describes a backup just written
+type Info struct {
+	Name        string    `json:"name"`
+	Destination string    `json:"destination"`
+	CreatedAt   time.Time `json:"created_at"`
+	SizeBytes   int64     `json:"size_bytes"`
+	Counts      Counts    `json:"counts"`
+}
+
+// Manager writes backups of repo to store and restores them
+type Manager struct {
+	repo        repository.Dumper
+	store       blob.Store
+	destination string
+	now         func() time.Time
+
+	// mu keeps backups and restores from overlapping
+	mu sync.Mutex
+}
+
+// New creates a Manager. destination is the store's URL, reported in Info.
+func New(repo repository.Dumper, store blob.Store, destination string) *Manager {
+	return &Manager{repo: repo, store: store, destination: destination, now: time.Now}
+}
+
+// Backup writes everything stored to a new archive, named after the time
+func (m *Manager) Backup(ctx context.Context) (*Info, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	d, err := m.repo.Dump(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading storage: %w", err)
+	}
+	meta := Metadata{
+		Format:          Format,
+		CreatedAt:       m.now().UTC().Truncate(time.Second),
+		Server:          version.Get(),
+		SnapshotVersion: repository.SnapshotVersion,
+		LastID:          d.LastID,
+		Counts:          countDump(d),
+	}
+	var buf bytes.Buffer
+	if err := writeArchive(&buf, meta, d); err != nil {
+		return nil, fmt.Errorf("writing archive: %w", err)
+	}
+
+	info := &Info{
+		Name:        "cert-tasks-" + meta.CreatedAt.Format("20060102T150405Z") + ".tar.gz",
+		Destination: m.destination,
+		CreatedAt:   meta.CreatedAt,
+		SizeBytes:   int64(buf.Len()),
+		Counts:      meta.Counts,
+	}
+	if err := m.store.Put(ctx, info.Name, &buf, info.SizeBytes); err != nil {
+		return nil, fmt.Errorf("storing %s: %w", info.Name, err)
+	}
+	return info, nil
+}
+
+// countDump counts the records in d
+func countDump(d *repository.Dump) Counts {
+	return Counts{
+		Tasks:      len(d.Tasks),
+		Revisions:  len(d.Revisions),
+		Workspaces: len(d.Workspaces),
+		APIKeys:    len(d.APIKeys),
+		Webhooks:   len(d.Webhooks),
+	}
+}
+
+// apiKeyRecord is an API key in an archive, including the hash that
+// models.APIKey leaves out of its JSON form
+type apiKeyRecord struct {
+	*models.APIKey
+	Hash string `json:"hash"`
+}
+
+// webhookRecord is a webhook in an archive, including the signing secret
+// that models.Webhook leaves out of its JSON form
+type webhookRecord struct {
+	*models.Webhook
+	Secret string `json:"secret"`
+}
+
+// writeArchive writes meta and d to w as a gzipped tarball
+func writeArchive(w io.Writer, meta Metadata, d *repository.Dump) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	metaJSON, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	entries := []struct {
+		name string
+		body []byte
+	}{
+		{metadataFile, append(metaJSON, '\n')},
+		{tasksFile, ndjson(d.Tasks, func(t *models.Task) any { return t })},
+		{revisionsFile, ndjson(d.Revisions, func(r *models.TaskRevision) any { return r })},
+		{workspacesFile, ndjson(d.Workspaces, func(ws *models.Workspace) any { return ws })},
+		{apiKeysFile, ndjson(d.APIKeys, func(k *models.APIKey) any { return apiKeyRecord{APIKey: k, Hash: k.Hash} })},
+		{webhooksFile, ndjson(d.Webhooks, func(h *models.Webhook) any { return webhookRecord{Webhook: h, Secret: h.Secret} })},
+	}
+	for _, e := range entries {
+		hdr := &tar.Header{
+			Name:    e.name,
+			Mode:    0o600,
+			Size:    int64(len(e.body)),
+			ModTime: meta.CreatedAt,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(e.body); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// ndjson encodes each item's record on a line of its own
+func ndjson[T any](items []T, record func(T) any) []byte {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, item := range items {
+		// The models marshal without error
+		enc.Encode(record(item))
+	}
+	return buf.Bytes()
+}
+
+// readArchive reads an archive written by writeArchive
+func readArchive(r io.Reader) (*Metadata, *repository.Dump, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	defer gz.Close()
+
+	var meta *Metadata
+	d := &repository.Dump{}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+		}
+		switch hdr.Name {
+		case metadataFile:
+			meta = &Metadata{}
+			err = json.NewDecoder(tr).Decode(meta)
+		case tasksFile:
+			d.Tasks, err = readNDJSON(tr, func(t *models.Task) *models.Task { return t })
+		case revisionsFile:
+			d.Revisions, err = readNDJSON(tr, func(r *models.TaskRevision) *models.TaskRevision { return r })
+		case workspacesFile:
+			d.Workspaces, err = readNDJSON(tr, func(ws *models.Workspace) *models.Workspace { return ws })
+		case apiKeysFile:
+			d.APIKeys, err = readNDJSON(tr, func(k *apiKeyRecord) *models.APIKey {
+				key := k.APIKey
+				if key == nil {
+					key = &models.APIKey{}
+				}
+				key.Hash = k.Hash
+				return key
+			})
+		case webhooksFile:
+			d.Webhooks, err = readNDJSON(tr, func(h *webhookRecord) *models.Webhook {
+				hook := h.Webhook
+				if hook == nil {
+					hook = &models.Webhook{}
+				}
+				hook.Secret = h.Secret
+				return hook
+			})
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %s: %v", ErrInvalidArchive, hdr.Name, err)
+		}
+	}
+	if meta == nil {
+		return nil, nil, fmt.Errorf("%w: no %s", ErrInvalidArchive, metadataFile)
+	}
+	if meta.Format > Format {
+		return nil, nil, fmt.Errorf("%w: format %d is newer than this server reads (%d)", ErrInvalidArchive, meta.Format, Format)
+	}
+	d.LastID = meta.LastID
+	return meta, d, nil
+}
+
+// readNDJSON decodes one record per line and converts each to a model
+func readNDJSON[R any, T any](r io.Reader, model func(*R) T) ([]T, error) {
+	var items []T
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64<<10), 16<<20)
+	for line := 1; sc.Scan(); line++ {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		rec := new(R)
+		if err := json.Unmarshal(sc.Bytes(), rec); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		items = append(items, model(rec))
+	}
+	return items, sc.Err()
+}
@@ -0,0 +1,193 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/light-bringer/cert-tasks/internal/blob"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+// seeded returns a repository holding the tasks titled, an API key and a
+// webhook
+func seeded(t *testing.T, titles ...string) *repository.MemoryRepository {
+	t.Helper()
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	for _, title := range titles {
+		if _, err := repo.Create(ctx, &models.Task{Title: title, Status: models.StatusTodo}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := repo.CreateAPIKey(ctx, &models.APIKey{Name: "ci", Hash: "hash-ci"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateWebhook(ctx, &models.Webhook{URL: "https://example.com/hook", Secret: "s3cret"}); err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+func newManager(t *testing.T, repo repository.Dumper) (*Manager, blob.Store) {
+	t.Helper()
+	store, err := blob.NewDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(repo, store, "file:///backups"), store
+}
+
+func titles(t *testing.T, repo *repository.MemoryRepository) []string {
+	t.Helper()
+	d, err := repo.Dump(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for _, task := range d.Tasks {
+		out = append(out, task.Title)
+	}
+	return out
+}
+
+func TestBackupRestore(t *testing.T) {
+	ctx := context.Background()
+	source := seeded(t, "one", "two")
+	m, store := newManager(t, source)
+
+	info, err := m.Backup(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(info.Name, "cert-tasks-") || !strings.HasSuffix(info.Name, ".tar.gz") {
+		t.Errorf("Name = %q", info.Name)
+	}
+	if info.Counts.Tasks != 2 || info.Counts.APIKeys != 1 || info.Counts.Webhooks != 1 || info.SizeBytes == 0 {
+		t.Errorf("Info = %+v", info)
+	}
+
+	target := repository.NewMemoryRepository()
+	restorer := New(target, store, "file:///backups")
+	res, err := restorer.Restore(ctx, Request{Name: info.Name, Policy: PolicyReplace})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Tasks.Created != 2 || res.APIKeys.Created != 1 || res.Webhooks.Created != 1 {
+		t.Errorf("Result = %+v", res)
+	}
+	if got := titles(t, target); len(got) != 2 || got[0] != "one" || got[1] != "two" {
+		t.Errorf("titles = %v, want [one two]", got)
+	}
+
+	// Secrets survive the round trip
+	key, err := target.GetAPIKeyByHash(ctx, "hash-ci")
+	if err != nil || key.Name != "ci" {
+		t.Errorf("GetAPIKeyByHash = %+v, %v", key, err)
+	}
+	d, _ := target.Dump(ctx)
+	if d.Webhooks[0].Secret != "s3cret" {
+		t.Errorf("webhook secret = %q, want s3cret", d.Webhooks[0].Secret)
+	}
+
+	// IDs already handed out are not reused
+	task, _ := target.Create(ctx, &models.Task{Title: "three", Status: models.StatusTodo})
+	if task.ID != 3 {
+		t.Errorf("next ID = %d, want 3", task.ID)
+	}
+}
+
+func TestRestore_Policies(t *testing.T) {
+	ctx := context.Background()
+	m, store := newManager(t, seeded(t, "archived one", "archived two"))
+	info, err := m.Backup(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		policy    Policy
+		want      []string
+		tasks     Changes
+		wantError error
+	}{
+		{PolicyReplace, []string{"archived one", "archived two"}, Changes{Overwritten: 1, Created: 1, Removed: 0}, nil},
+		{PolicySkip, []string{"stored one", "archived two"}, Changes{Skipped: 1, Created: 1}, nil},
+		{PolicyOverwrite, []string{"archived one", "archived two"}, Changes{Overwritten: 1, Created: 1}, nil},
+		{PolicyFail, []string{"stored one"}, Changes{Skipped: 1, Created: 1}, ErrConflict},
+	}
+	for _, tc := range tests {
+		t.Run(string(tc.policy), func(t *testing.T) {
+			target := seeded(t, "stored one")
+			res, err := New(target, store, "").Restore(ctx, Request{Name: info.Name, Policy: tc.policy})
+			if !errors.Is(err, tc.wantError) {
+				t.Fatalf("Restore error = %v, want %v", err, tc.wantError)
+			}
+			if res.Tasks != tc.tasks {
+				t.Errorf("Tasks = %+v, want %+v", res.Tasks, tc.tasks)
+			}
+			if len(res.Conflicts) == 0 || res.Conflicts[0] != "task 1" {
+				t.Errorf("Conflicts = %v, want task 1 first", res.Conflicts)
+			}
+			got := titles(t, target)
+			if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("titles = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRestore_ReplaceRemoves(t *testing.T) {
+	ctx := context.Background()
+	m, store := newManager(t, seeded(t, "archived"))
+	info, _ := m.Backup(ctx)
+
+	target := seeded(t, "stored one", "stored two")
+	res, err := New(target, store, "").Restore(ctx, Request{Name: info.Name, Policy: PolicyReplace})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Tasks != (Changes{Overwritten: 1, Removed: 1}) {
+		t.Errorf("Tasks = %+v", res.Tasks)
+	}
+	if got := titles(t, target); len(got) != 1 || got[0] != "archived" {
+		t.Errorf("titles = %v, want [archived]", got)
+	}
+}
+
+func TestRestore_DryRun(t *testing.T) {
+	ctx := context.Background()
+	m, store := newManager(t, seeded(t, "archived"))
+	info, _ := m.Backup(ctx)
+
+	target := seeded(t)
+	res, err := New(target, store, "").Restore(ctx, Request{Name: info.Name, Policy: PolicyFail, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.DryRun || res.Tasks.Created != 1 || strings.Join(res.Conflicts, ",") != "api key 1,webhook 1" {
+		t.Errorf("Result = %+v", res)
+	}
+	if got := titles(t, target); len(got) != 0 {
+		t.Errorf("dry run stored %v", got)
+	}
+}
+
+func TestRestore_Errors(t *testing.T) {
+	ctx := context.Background()
+	m, store := newManager(t, seeded(t))
+
+	if _, err := m.Restore(ctx, Request{Name: "missing.tar.gz"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing archive error = %v, want ErrNotFound", err)
+	}
+	if _, err := m.Restore(ctx, Request{Name: "x", Policy: "merge"}); err == nil {
+		t.Error("unknown policy accepted")
+	}
+	store.Put(ctx, "junk.tar.gz", bytes.NewReader([]byte("not gzip")), 8)
+	if _, err := m.Restore(ctx, Request{Name: "junk.tar.gz"}); !errors.Is(err, ErrInvalidArchive) {
+		t.Errorf("junk archive error = %v, want ErrInvalidArchive", err)
+	}
+}
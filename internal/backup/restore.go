@@ -0,0 +1,202 @@
+package backup
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/light-bringer/cert-tasks/internal/blob"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+// Policy says what a restore does with records that are both stored and in
+// the archive, matched by ID
+type Policy string
+
+const (
+	// PolicyReplace makes the storage hold exactly what the archive does,
+	// removing records the archive lacks
+	PolicyReplace Policy = "replace"
+
+	// PolicySkip adds the archive's new records and keeps the stored
+	// version of the others
+	PolicySkip Policy = "skip"
+
+	// PolicyOverwrite adds the archive's new records and replaces the
+	// stored version of the others
+	PolicyOverwrite Policy = "overwrite"
+
+	// PolicyFail adds the archive's records only if none is stored
+	// already, and otherwise changes nothing
+	PolicyFail Policy = "fail"
+)
+
+// Valid reports whether p is a known policy
+func (p Policy) Valid() bool {
+	switch p {
+	case PolicyReplace, PolicySkip, PolicyOverwrite, PolicyFail:
+		return true
+	}
+	return false
+}
+
+// Request asks for a restore
+type Request struct {
+	// Name is the archive, as returned by Backup
+	Name string `json:"name"`
+
+	// Policy defaults to PolicyFail
+	Policy Policy `json:"policy,omitempty"`
+
+	// DryRun reports what would change without changing it
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// Changes counts what a restore did to one kind of record
+type Changes struct {
+	Created     int `json:"created"`
+	Overwritten int `json:"overwritten"`
+	Skipped     int `json:"skipped"`
+	Removed     int `json:"removed"`
+}
+
+// Result reports a restore. In a dry run nothing is changed and the counts
+// say what would have been.
+type Result struct {
+	Name       string   `json:"name"`
+	Policy     Policy   `json:"policy"`
+	DryRun     bool     `json:"dry_run"`
+	Metadata   Metadata `json:"metadata"`
+	Tasks      Changes  `json:"tasks"`
+	Workspaces Changes  `json:"workspaces"`
+	APIKeys    Changes  `json:"api_keys"`
+	Webhooks   Changes  `json:"webhooks"`
+
+	// Conflicts names the records, such as "task 12", that are both
+	// stored and in the archive
+	Conflicts []string `json:"conflicts"`
+}
+
+// Restore merges the archive req names into the storage under req.Policy.
+// With PolicyFail and conflicting records it returns the result listing
+// them along with ErrConflict, unless it is a dry run. Revisions follow
+// their task: a task kept from the archive keeps the archive's history.
+// IDs handed out before the restore are never handed out again.
+func (m *Manager) Restore(ctx context.Context, req Request) (*Result, error) {
+	if req.Policy == "" {
+		req.Policy = PolicyFail
+	}
+	if !req.Policy.Valid() {
+		return nil, fmt.Errorf("unknown policy %q", req.Policy)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rc, err := m.store.Get(ctx, req.Name)
+	if errors.Is(err, blob.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, req.Name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", req.Name, err)
+	}
+	meta, archived, err := readArchive(rc)
+	rc.Close()
+	if err != nil {
+		return nil, err
+	}
+	stored, err := m.repo.Dump(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading storage: %w", err)
+	}
+
+	if req.Policy == PolicyFail {
+		// Every server has the default workspace, so it is no conflict:
+		// the stored one is kept
+		archived.Workspaces = slices.DeleteFunc(archived.Workspaces, func(ws *models.Workspace) bool {
+			return ws.ID == models.DefaultWorkspace
+		})
+	}
+
+	res := &Result{Name: req.Name, Policy: req.Policy, DryRun: req.DryRun, Metadata: *meta, Conflicts: []string{}}
+	merged := &repository.Dump{LastID: max(stored.LastID, archived.LastID)}
+
+	var fromArchive map[int64]bool
+	merged.Tasks, fromArchive, res.Tasks = merge(stored.Tasks, archived.Tasks, req.Policy,
+		func(t *models.Task) int64 { return t.ID }, "task", &res.Conflicts)
+	merged.Workspaces, _, res.Workspaces = merge(stored.Workspaces, archived.Workspaces, req.Policy,
+		func(ws *models.Workspace) string { return ws.ID }, "workspace", &res.Conflicts)
+	merged.APIKeys, _, res.APIKeys = merge(stored.APIKeys, archived.APIKeys, req.Policy,
+		func(k *models.APIKey) int64 { return k.ID }, "api key", &res.Conflicts)
+	merged.Webhooks, _, res.Webhooks = merge(stored.Webhooks, archived.Webhooks, req.Policy,
+		func(h *models.Webhook) int64 { return h.ID }, "webhook", &res.Conflicts)
+
+	for _, rev := range stored.Revisions {
+		if !fromArchive[rev.TaskID] {
+			merged.Revisions = append(merged.Revisions, rev)
+		}
+	}
+	for _, rev := range archived.Revisions {
+		if fromArchive[rev.TaskID] {
+			merged.Revisions = append(merged.Revisions, rev)
+		}
+	}
+
+	if req.DryRun {
+		return res, nil
+	}
+	if req.Policy == PolicyFail && len(res.Conflicts) > 0 {
+		return res, ErrConflict
+	}
+	if err := m.repo.Replace(ctx, merged); err != nil {
+		return nil, fmt.Errorf("writing storage: %w", err)
+	}
+	return res, nil
+}
+
+// merge combines the stored and archived records under policy, sorted by
+// key. It returns the records kept, which of them came from the archive and
+// what changed, and appends a description of each conflict to conflicts.
+func merge[T any, K cmp.Ordered](stored, archived []T, policy Policy, key func(T) K, kind string, conflicts *[]string) ([]T, map[K]bool, Changes) {
+	var changes Changes
+	kept := make(map[K]T, len(stored)+len(archived))
+	fromArchive := make(map[K]bool, len(archived))
+	inArchive := make(map[K]bool, len(archived))
+	for _, item := range stored {
+		kept[key(item)] = item
+	}
+	for _, item := range archived {
+		k := key(item)
+		inArchive[k] = true
+		if _, ok := kept[k]; !ok {
+			changes.Created++
+		} else {
+			*conflicts = append(*conflicts, fmt.Sprintf("%s %v", kind, k))
+			if policy == PolicySkip || policy == PolicyFail {
+				changes.Skipped++
+				continue
+			}
+			changes.Overwritten++
+		}
+		kept[k] = item
+		fromArchive[k] = true
+	}
+	if policy == PolicyReplace {
+		for _, item := range stored {
+			if k := key(item); !inArchive[k] {
+				delete(kept, k)
+				changes.Removed++
+			}
+		}
+	}
+
+	items := make([]T, 0, len(kept))
+	for _, item := range kept {
+		items = append(items, item)
+	}
+	slices.SortFunc(items, func(a, b T) int { return cmp.Compare(key(a), key(b)) })
+	return items, fromArchive, changes
+}
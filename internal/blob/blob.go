@@ -0,0 +1,205 @@
+// Package blob keeps named objects, such as export files and backup
+// archives, in a local directory or an S3 bucket. The location is a URL:
+// file:///path/to/dir or s3://bucket/prefix. S3 credentials, the region
+// and a custom endpoint (for S3-compatible stores such as MinIO) come from
+// the standard AWS environment variables.
+//
+// A Signer hands out URLs that download one object without credentials
+// until they expire. S3 signs its own; for a directory, Links signs them
+// and checks them when the server serves the download.
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when no object has the name
+var ErrNotFound = errors.New("object not found")
+
+// Store keeps objects by name. Names are made of letters, digits, dots,
+// dashes and underscores.
+type Store interface {
+	// Put stores size bytes read from body under name, replacing any
+	// object already there
+	Put(ctx context.Context, name string, body io.Reader, size int64) error
+
+	// Get returns the object's contents or ErrNotFound. The caller must
+	// close them.
+	Get(ctx context.Context, name string) (io.ReadCloser, error)
+
+	// Delete removes the object; deleting a missing object is not an
+	// error
+	Delete(ctx context.Context, name string) error
+
+	// List returns the objects whose names start with prefix, sorted by
+	// name
+	List(ctx context.Context, prefix string) ([]Object, error)
+}
+
+// Object describes a stored object
+type Object struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// Download says how a signed URL serves its object. Both fields are
+// optional.
+type Download struct {
+	// Filename is offered to the browser in Content-Disposition
+	Filename string
+
+	// ContentType overrides the stored one
+	ContentType string
+}
+
+// Signer is implemented by stores that can hand out URLs downloading one
+// object without credentials until ttl has passed
+type Signer interface {
+	SignedURL(name string, ttl time.Duration, d Download) (string, error)
+}
+
+// Open returns the store at rawURL. HTTP calls to S3 go through client.
+func Open(rawURL string, client *http.Client) (Store, error) {
+	u, err := Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "s3" {
+		return NewS3(u.Host, strings.Trim(u.Path, "/"), S3ConfigFromEnv(), client), nil
+	}
+	return NewDir(u.Path)
+}
+
+// Parse checks that rawURL names a store
+func Parse(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "file":
+		if u.Path == "" {
+			return nil, fmt.Errorf("%q has no directory path", rawURL)
+		}
+	case "s3":
+		if u.Host == "" {
+			return nil, fmt.Errorf("%q has no bucket", rawURL)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported store %q", u.Scheme)
+	}
+	return u, nil
+}
+
+// ValidName reports whether name can name an object
+func ValidName(name string) bool {
+	if name == "" || name[0] == '.' || len(name) > 255 {
+		return false
+	}
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '.', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Dir keeps objects as files in a directory
+type Dir struct {
+	path string
+}
+
+// NewDir returns a store in the directory at path, creating it if missing
+func NewDir(path string) (*Dir, error) {
+	if err := os.MkdirAll(path, 0o700); err != nil {
+		return nil, fmt.Errorf("creating store directory: %w", err)
+	}
+	return &Dir{path: path}, nil
+}
+
+// Put writes the object to a temporary file and renames it into place, so
+// a failed write never leaves a partial object
+func (d *Dir) Put(ctx context.Context, name string, body io.Reader, size int64) error {
+	if !ValidName(name) {
+		return fmt.Errorf("invalid object name %q", name)
+	}
+	tmp, err := os.CreateTemp(d.path, "."+name+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(d.path, name))
+}
+
+// Get opens the object's file
+func (d *Dir) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	if !ValidName(name) {
+		return nil, ErrNotFound
+	}
+	f, err := os.Open(filepath.Join(d.path, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+// Delete removes the object's file
+func (d *Dir) Delete(ctx context.Context, name string) error {
+	if !ValidName(name) {
+		return nil
+	}
+	err := os.Remove(filepath.Join(d.path, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// List reads the directory, leaving out temporary files of writes in
+// progress
+func (d *Dir) List(ctx context.Context, prefix string) ([]Object, error) {
+	entries, err := os.ReadDir(d.path)
+	if err != nil {
+		return nil, err
+	}
+	var objects []Object
+	for _, e := range entries {
+		if !e.Type().IsRegular() || !strings.HasPrefix(e.Name(), prefix) || !ValidName(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			// Removed since it was read
+			continue
+		}
+		objects = append(objects, Object{Name: e.Name(), Size: info.Size(), ModTime: info.ModTime()})
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Name < objects[j].Name })
+	return objects, nil
+}
@@ -0,0 +1,339 @@
+package blob
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// unsignedPayload is sent as the payload hash of uploads, which are
+// streamed rather than hashed up front
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// emptyPayload is the SHA-256 of an empty body
+const emptyPayload = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Config holds what S3 requests are signed with and sent to
+type S3Config struct {
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	// Endpoint, such as http://localhost:9000 for MinIO, replaces AWS.
+	// Buckets are then addressed by path rather than host name.
+	Endpoint string
+}
+
+// S3ConfigFromEnv reads AWS_REGION (default us-east-1),
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN and
+// AWS_ENDPOINT_URL
+func S3ConfigFromEnv() S3Config {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+	return S3Config{
+		Region:          region,
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		Endpoint:        os.Getenv("AWS_ENDPOINT_URL"),
+	}
+}
+
+// S3 keeps objects in an S3 bucket, under a key prefix
+type S3 struct {
+	bucket string
+	prefix string
+	cfg    S3Config
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewS3 returns a store in bucket, naming objects prefix/name
+func NewS3(bucket, prefix string, cfg S3Config, client *http.Client) *S3 {
+	return &S3{bucket: bucket, prefix: prefix, cfg: cfg, client: client, now: time.Now}
+}
+
+// Put uploads the object
+func (s *S3) Put(ctx context.Context, name string, body io.Reader, size int64) error {
+	if !ValidName(name) {
+		return fmt.Errorf("invalid object name %q", name)
+	}
+	req, err := s.request(ctx, http.MethodPut, name, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	resp, err := s.do(req, unsignedPayload)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Get downloads the object
+func (s *S3) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	if !ValidName(name) {
+		return nil, ErrNotFound
+	}
+	req, err := s.request(ctx, http.MethodGet, name, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(req, emptyPayload)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Delete removes the object; S3 answers 204 for missing objects too
+func (s *S3) Delete(ctx context.Context, name string) error {
+	if !ValidName(name) {
+		return nil
+	}
+	req, err := s.request(ctx, http.MethodDelete, name, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req, emptyPayload)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// List lists the bucket's objects under the prefix, a page at a time
+func (s *S3) List(ctx context.Context, prefix string) ([]Object, error) {
+	dir := ""
+	if s.prefix != "" {
+		dir = s.prefix + "/"
+	}
+	keyPrefix := dir + prefix
+	var objects []Object
+	token := ""
+	for {
+		q := url.Values{"list-type": {"2"}, "prefix": {keyPrefix}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.bucketURL()+"?"+canonicalQuery(q), nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := s.do(req, emptyPayload)
+		if err != nil {
+			return nil, err
+		}
+		var page listResult
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("s3 list: %w", err)
+		}
+		for _, c := range page.Contents {
+			objects = append(objects, Object{Name: strings.TrimPrefix(c.Key, dir), Size: c.Size, ModTime: c.LastModified})
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// listResult is a page of a ListObjectsV2 response
+type listResult struct {
+	Contents []struct {
+		Key          string
+		Size         int64
+		LastModified time.Time
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+// MaxURLTTL is the longest S3 accepts for a signed URL
+const MaxURLTTL = 7 * 24 * time.Hour
+
+// SignedURL presigns a GET of the object, valid for ttl (at most
+// MaxURLTTL)
+func (s *S3) SignedURL(name string, ttl time.Duration, d Download) (string, error) {
+	if !ValidName(name) {
+		return "", fmt.Errorf("invalid object name %q", name)
+	}
+	u, err := url.Parse(s.objectURL(name))
+	if err != nil {
+		return "", err
+	}
+	q := url.Values{}
+	if d.Filename != "" {
+		q.Set("response-content-disposition", `attachment; filename="`+d.Filename+`"`)
+	}
+	if d.ContentType != "" {
+		q.Set("response-content-type", d.ContentType)
+	}
+	presign(u, q, s.cfg, s.now().UTC(), min(ttl, MaxURLTTL))
+	return u.String(), nil
+}
+
+// request builds a request for the object's URL
+func (s *S3) request(ctx context.Context, method, name string, body io.Reader) (*http.Request, error) {
+	return http.NewRequestWithContext(ctx, method, s.objectURL(name), body)
+}
+
+// bucketURL is the bucket's URL: by path with a custom endpoint, by host
+// name on AWS
+func (s *S3) bucketURL() string {
+	if s.cfg.Endpoint != "" {
+		return strings.TrimRight(s.cfg.Endpoint, "/") + "/" + s.bucket
+	}
+	return "https://" + s.bucket + ".s3." + s.cfg.Region + ".amazonaws.com/"
+}
+
+// objectURL is the URL of the named object, under the prefix
+func (s *S3) objectURL(name string) string {
+	key := name
+	if s.prefix != "" {
+		key = s.prefix + "/" + name
+	}
+	return strings.TrimRight(s.bucketURL(), "/") + "/" + key
+}
+
+// do signs and sends req, turning error statuses into errors
+func (s *S3) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	sign(req, s.cfg, payloadHash, s.now().UTC())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, ErrNotFound
+		}
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return nil, fmt.Errorf("s3 %s %s: %s: %s", req.Method, req.URL.Path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// sign adds an AWS Signature Version 4 Authorization header to req,
+// covering the host and every header already set
+func sign(req *http.Request, cfg S3Config, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if cfg.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", cfg.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		escapePath(req.URL.Path),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + cfg.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hexSHA256(canonicalRequest)
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		cfg.AccessKeyID, scope, signedHeaders, signature(cfg, date, stringToSign)))
+}
+
+// presign adds the query parameters q and a Signature Version 4 signature
+// to u, granting a GET of it until ttl has passed
+func presign(u *url.URL, q url.Values, cfg S3Config, now time.Time, ttl time.Duration) {
+	amzDate := now.Format("20060102T150405Z")
+	date := amzDate[:8]
+	scope := date + "/" + cfg.Region + "/s3/aws4_request"
+	q.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	q.Set("X-Amz-Credential", cfg.AccessKeyID+"/"+scope)
+	q.Set("X-Amz-Date", amzDate)
+	q.Set("X-Amz-Expires", strconv.FormatInt(int64(ttl/time.Second), 10))
+	q.Set("X-Amz-SignedHeaders", "host")
+	if cfg.SessionToken != "" {
+		q.Set("X-Amz-Security-Token", cfg.SessionToken)
+	}
+
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		escapePath(u.Path),
+		canonicalQuery(q),
+		"host:" + u.Host + "\n",
+		"host",
+		unsignedPayload,
+	}, "\n")
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hexSHA256(canonicalRequest)
+	q.Set("X-Amz-Signature", signature(cfg, date, stringToSign))
+	u.RawQuery = canonicalQuery(q)
+}
+
+// signature signs stringToSign with the key derived for the day
+func signature(cfg S3Config, date, stringToSign string) string {
+	key := hmacSHA256([]byte("AWS4"+cfg.SecretAccessKey), date)
+	key = hmacSHA256(key, cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// escapePath URI-encodes each segment of path as SigV4 requires
+func escapePath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		segments[i] = strings.ReplaceAll(url.PathEscape(seg), "+", "%2B")
+	}
+	return strings.Join(segments, "/")
+}
+
+// canonicalQuery sorts and encodes the query parameters
+func canonicalQuery(q url.Values) string {
+	return strings.ReplaceAll(q.Encode(), "+", "%20")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hexSHA256(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
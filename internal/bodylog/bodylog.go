@@ -0,0 +1,264 @@
+// Package bodylog logs request and response bodies for troubleshooting a
+// client integration. It is off by default and meant to be turned on for
+// a few minutes at a time: bodies are capped in size, and secrets such as
+// API keys, webhook secrets and tokens are redacted from bodies, headers
+// and query strings before anything is logged, as are the personal data
+// fields a redact.Redactor masks.
+package bodylog
+
+import (
+	"bytes"
+	"cmp"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/light-bringer/cert-tasks/internal/logging"
+	"github.com/light-bringer/cert-tasks/internal/redact"
+)
+
+// DefaultMaxBytes is how much of each body is logged by default
+const DefaultMaxBytes = 4 << 10
+
+// DefaultDuration is how long body logging stays on when turned on at
+// runtime without a duration
+const DefaultDuration = 15 * time.Minute
+
+// MaxDuration caps how long body logging can be turned on at runtime
+const MaxDuration = 24 * time.Hour
+
+// redacted replaces every secret value
+const redacted = redact.Mask
+
+// State is whether bodies are logged
+type State struct {
+	Enabled bool `json:"enabled"`
+
+	// Until is when logging turns itself off; without it logging stays
+	// on until turned off
+	Until *time.Time `json:"until,omitempty"`
+
+	// MaxBytes is how much of each body is logged
+	MaxBytes int `json:"max_bytes"`
+}
+
+// Request is the body of PUT /admin/debug/bodies
+type Request struct {
+	Enabled bool `json:"enabled"`
+
+	// Duration turns logging off again after it, e.g. "30m"; it defaults
+	// to DefaultDuration and is at most MaxDuration
+	Duration string `json:"duration,omitempty"`
+}
+
+// Logger logs bodies while it is on; the zero value is off
+type Logger struct {
+	maxBytes int
+	redactor *redact.Redactor
+	now      func() time.Time
+
+	mu      sync.RWMutex
+	enabled bool
+	until   time.Time
+}
+
+// New creates a Logger that is off and logs up to maxBytes of each body,
+// masking the fields redactor does
+func New(maxBytes int, redactor *redact.Redactor) *Logger {
+	return &Logger{maxBytes: maxBytes, redactor: redactor, now: time.Now}
+}
+
+// Set turns body logging on for d, or indefinitely for a zero d, or off,
+// and returns the new state
+func (l *Logger) Set(enabled bool, d time.Duration) State {
+	l.mu.Lock()
+	l.enabled, l.until = enabled, time.Time{}
+	if enabled && d > 0 {
+		l.until = l.now().Add(d).UTC()
+	}
+	l.mu.Unlock()
+	return l.State()
+}
+
+// State returns the current state
+func (l *Logger) State() State {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	s := State{Enabled: l.on(), MaxBytes: l.maxBytes}
+	if s.Enabled && !l.until.IsZero() {
+		until := l.until
+		s.Until = &until
+	}
+	return s
+}
+
+// on reports whether logging is on and has not expired. mu must be held.
+func (l *Logger) on() bool {
+	return l.enabled && (l.until.IsZero() || l.now().Before(l.until))
+}
+
+// Middleware logs the request and response of every exchange while body
+// logging is on, in one record carrying the request's log fields. Only
+// what the handler reads of the request body is logged. WebSocket
+// upgrades are passed through untouched.
+func (l *Logger) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l.mu.RLock()
+		on := l.on()
+		l.mu.RUnlock()
+		if !on || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		reqBody := &capped{max: l.maxBytes, redactor: l.redactor}
+		if r.Body != nil {
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.TeeReader(r.Body, reqBody), r.Body}
+		}
+		respBody := &capped{max: l.maxBytes, redactor: l.redactor}
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		ww.Tee(respBody)
+
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		logging.FromContext(r.Context()).Info("request and response bodies",
+			slog.Group("request",
+				slog.String("query", redactQuery(l.redactor.Query(r.URL.Query()))),
+				slog.Any("headers", redactHeaders(r.Header)),
+				slog.String("body", reqBody.String(r.Header.Get("Content-Type"))),
+				slog.Int64("body_bytes", reqBody.total),
+				slog.Bool("truncated", reqBody.truncated()),
+			),
+			slog.Group("response",
+				slog.Int("status", status),
+				slog.Any("headers", redactHeaders(ww.Header())),
+				slog.String("body", respBody.String(ww.Header().Get("Content-Type"))),
+				slog.Int64("body_bytes", respBody.total),
+				slog.Bool("truncated", respBody.truncated()),
+			),
+		)
+	})
+}
+
+// capped keeps the first max bytes written to it and counts the rest
+type capped struct {
+	max      int
+	redactor *redact.Redactor
+	buf      []byte
+	total    int64
+}
+
+func (c *capped) Write(p []byte) (int, error) {
+	c.total += int64(len(p))
+	if room := c.max - len(c.buf); room > 0 {
+		c.buf = append(c.buf, p[:min(room, len(p))]...)
+	}
+	return len(p), nil
+}
+
+func (c *capped) truncated() bool {
+	return c.total > int64(len(c.buf))
+}
+
+// String returns the kept body with secrets and sensitive fields redacted,
+// or a placeholder for a binary body. Sensitive fields can only be found
+// in JSON, so other bodies, such as CSV exports, are left out while any
+// field is redacted.
+func (c *capped) String(contentType string) string {
+	body := c.buf
+	if c.truncated() {
+		// Do not split the last character
+		for len(body) > 0 && !utf8.Valid(body) && len(c.buf)-len(body) < utf8.UTFMax {
+			body = body[:len(body)-1]
+		}
+	}
+	if !utf8.Valid(body) {
+		return "[binary]"
+	}
+	if len(c.redactor.Fields()) > 0 && len(body) > 0 && !isJSON(contentType, body) {
+		mediaType, _, _ := strings.Cut(contentType, ";")
+		return "[" + cmp.Or(mediaType, "text") + " body redacted]"
+	}
+	return c.redactor.JSON(RedactBody(string(body)))
+}
+
+// isJSON reports whether a body of contentType is JSON or NDJSON, going by
+// its first character when the type is missing
+func isJSON(contentType string, body []byte) bool {
+	if contentType == "" {
+		trimmed := bytes.TrimSpace(body)
+		return len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[')
+	}
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	return strings.HasSuffix(strings.TrimSpace(mediaType), "json")
+}
+
+// secretMember matches a JSON member whose name marks a secret, with a
+// string value, even in a truncated body
+var secretMember = regexp.MustCompile(`(?i)("[a-z0-9_-]*(?:secret|token|password|key|signed_url)"\s*:\s*)"(?:[^"\\]|\\.)*"?`)
+
+// signedURL matches a string holding a URL with a signature parameter,
+// such as a download link, which works for anyone who has it
+var signedURL = regexp.MustCompile(`(?i)"(?:[^"\\]|\\.)*?[?&][a-z0-9_-]*signature=(?:[^"\\]|\\.)*"?`)
+
+// secretParam matches a query parameter named like a secret inside a
+// string value, such as the token in the calendar feed's "path"
+var secretParam = regexp.MustCompile(`(?i)([?&][a-z0-9_.-]*(?:secret|token|key|credential)=)[^&#"\\\s]*`)
+
+// RedactBody replaces the values of JSON members named like secrets, such
+// as "key", "secret", "confirmation_token" and "signed_url", and strings
+// holding signed URLs. In other strings it replaces the values of query
+// parameters named like secrets.
+func RedactBody(body string) string {
+	body = secretMember.ReplaceAllString(body, `$1"`+redacted+`"`)
+	body = signedURL.ReplaceAllString(body, `"`+redacted+`"`)
+	return secretParam.ReplaceAllString(body, `${1}`+redacted)
+}
+
+// secretHeaders carry credentials
+var secretHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+	"X-Api-Key":           true,
+}
+
+// redactHeaders returns h as a flat map with credentials redacted
+func redactHeaders(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for name, values := range h {
+		if secretHeaders[http.CanonicalHeaderKey(name)] {
+			out[name] = redacted
+			continue
+		}
+		out[name] = strings.Join(values, ", ")
+	}
+	return out
+}
+
+// redactQuery encodes q with the values of secret-looking parameters, such
+// as the calendar feed's token, redacted
+func redactQuery(q url.Values) string {
+	for name := range q {
+		lower := strings.ToLower(name)
+		if strings.Contains(lower, "token") || strings.Contains(lower, "key") || strings.Contains(lower, "secret") {
+			q[name] = []string{redacted}
+		}
+	}
+	return q.Encode()
+}
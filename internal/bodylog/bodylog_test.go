@@ -0,0 +1,147 @@
+package bodylog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/logging"
+	"github.com/light-bringer/cert-tasks/internal/redact"
+)
+
+func TestLogger_Set(t *testing.T) {
+	now := time.Date(2026, 1, 2, 15, 0, 0, 0, time.UTC)
+	l := New(DefaultMaxBytes, nil)
+	l.now = func() time.Time { return now }
+
+	if l.State().Enabled {
+		t.Fatal("new Logger is on")
+	}
+
+	state := l.Set(true, 10*time.Minute)
+	if !state.Enabled || state.Until == nil || !state.Until.Equal(now.Add(10*time.Minute)) {
+		t.Fatalf("Set(true, 10m) = %+v", state)
+	}
+
+	// It turns itself off at the deadline
+	now = now.Add(10 * time.Minute)
+	if state := l.State(); state.Enabled || state.Until != nil {
+		t.Errorf("state after the deadline = %+v, want off", state)
+	}
+
+	if state := l.Set(true, 0); !state.Enabled || state.Until != nil {
+		t.Errorf("Set(true, 0) = %+v, want on without a deadline", state)
+	}
+	if state := l.Set(false, time.Hour); state.Enabled {
+		t.Errorf("Set(false) = %+v, want off", state)
+	}
+}
+
+func TestLogger_Middleware(t *testing.T) {
+	var logs bytes.Buffer
+	logger := logging.New(&logs, slog.LevelInfo)
+
+	l := New(64, redact.New(redact.DefaultFields))
+	h := logging.Middleware(logger, nil)(l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"k1","key":"tk_live_abcdef","name":"` + strings.Repeat("n", 100) + `"}`))
+	})))
+
+	send := func() {
+		req := httptest.NewRequest("POST", "/apikeys?token=feed-token&limit=5&q=alice", strings.NewReader(`{"name":"ci","secret":"s3cr\"et","description":"Call Alice"}`))
+		req.Header.Set("X-API-Key", "admin-key")
+		req.Header.Set("Content-Type", "application/json")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	send()
+	if strings.Contains(logs.String(), "request and response bodies") {
+		t.Fatal("bodies were logged while off")
+	}
+
+	l.Set(true, 0)
+	send()
+
+	var record struct {
+		Msg     string `json:"msg"`
+		Request struct {
+			Query     string            `json:"query"`
+			Headers   map[string]string `json:"headers"`
+			Body      string            `json:"body"`
+			BodyBytes int               `json:"body_bytes"`
+			Truncated bool              `json:"truncated"`
+		} `json:"request"`
+		Response struct {
+			Status    int    `json:"status"`
+			Body      string `json:"body"`
+			BodyBytes int    `json:"body_bytes"`
+			Truncated bool   `json:"truncated"`
+		} `json:"response"`
+	}
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		if strings.Contains(line, "request and response bodies") {
+			json.Unmarshal([]byte(line), &record)
+		}
+	}
+	if record.Msg == "" {
+		t.Fatalf("no body record in the log:\n%s", logs.String())
+	}
+
+	for _, secret := range []string{"admin-key", "feed-token", "s3cr", "tk_live", "Alice", "alice"} {
+		if strings.Contains(logs.String(), secret) {
+			t.Errorf("log contains the secret %q:\n%s", secret, logs.String())
+		}
+	}
+	if record.Request.Body != `{"name":"ci","secret":"[REDACTED]","description":"[REDACTED]"}` {
+		t.Errorf("request body = %s", record.Request.Body)
+	}
+	if record.Request.Headers["X-Api-Key"] != redacted || record.Request.Headers["Content-Type"] != "application/json" {
+		t.Errorf("request headers = %v", record.Request.Headers)
+	}
+	if record.Request.Query != "limit=5&q=%5BREDACTED%5D&token=%5BREDACTED%5D" {
+		t.Errorf("query = %q", record.Request.Query)
+	}
+	if record.Response.Status != http.StatusCreated || !record.Response.Truncated || record.Response.BodyBytes <= 64 {
+		t.Errorf("response = %+v, want 201 truncated at 64 bytes", record.Response)
+	}
+	if !strings.HasPrefix(record.Response.Body, `{"id":"k1","key":"[REDACTED]","name":"nnn`) {
+		t.Errorf("response body = %s", record.Response.Body)
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{`{"title":"Write report"}`, `{"title":"Write report"}`},
+		{`{"key": "abc"}`, `{"key": "[REDACTED]"}`},
+		{`{"confirmation_token":"t","count":2}`, `{"confirmation_token":"[REDACTED]","count":2}`},
+		{`{"Password":"a\"b"}`, `{"Password":"[REDACTED]"}`},
+		{`{"api_keys":[{"key":"x"}]}`, `{"api_keys":[{"key":"[REDACTED]"}]}`},
+		// Secrets in the query of a URL by another name
+		{`{"path":"/calendar.ics?token=v1.abc%2Bdef","n":1}`, `{"path":"/calendar.ics?token=[REDACTED]","n":1}`},
+		{`{"url":"https://x.example/a?limit=5&api_key=k&q=1"}`, `{"url":"https://x.example/a?limit=5&api_key=[REDACTED]&q=1"}`},
+		{`{"title":"token=abc"}`, `{"title":"token=abc"}`},
+		// Signed download links work for whoever holds them
+		{`{"download_url":"/jobs/1/download","signed_url":"/blobs/a.zip?expires=1&signature=ab","signed_url_expires_at":"2026-01-02T15:00:00Z"}`,
+			`{"download_url":"/jobs/1/download","signed_url":"[REDACTED]","signed_url_expires_at":"2026-01-02T15:00:00Z"}`},
+		{`{"link":"https://b.s3.amazonaws.com/e.zip?X-Amz-Expires=900&X-Amz-Signature=ab12","id":2}`, `{"link":"[REDACTED]","id":2}`},
+		{`["/blobs/a?signature=ab", "plain"]`, `["[REDACTED]", "plain"]`},
+		// Cut off mid-value, as in a truncated body
+		{`{"secret":"abc`, `{"secret":"[REDACTED]"`},
+	}
+	for _, tt := range tests {
+		if got := RedactBody(tt.body); got != tt.want {
+			t.Errorf("RedactBody(%s) = %s, want %s", tt.body, got, tt.want)
+		}
+	}
+}
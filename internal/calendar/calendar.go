@@ -0,0 +1,200 @@
+// Package calendar renders tasks with due dates as an iCalendar (RFC 5545)
+// feed that calendar apps subscribe to. Calendar apps cannot send API keys,
+// so the feed URL carries a token instead: it names the workspace and user
+// whose tasks the feed lists, signed by the server so it cannot be forged.
+// Tokens do not expire; rotating the secret revokes all of them.
+package calendar
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+)
+
+// ContentType is the media type of a feed
+const ContentType = "text/calendar; charset=utf-8"
+
+// ErrInvalidToken is returned for a malformed or forged feed token
+var ErrInvalidToken = errors.New("invalid calendar token")
+
+// Tokens issues and verifies feed tokens
+type Tokens struct {
+	secret []byte
+}
+
+// NewTokens creates a token issuer signing with secret
+func NewTokens(secret []byte) *Tokens {
+	return &Tokens{secret: secret}
+}
+
+// Issue returns the feed token for the owner's tasks in workspace. An empty
+// owner covers every task in the workspace.
+func (t *Tokens) Issue(workspace, owner string) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(workspace + "\x00" + owner))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(t.sign(payload))
+}
+
+// Verify returns the workspace and owner a token was issued for
+func (t *Tokens) Verify(token string) (workspace, owner string, err error) {
+	payload, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", "", ErrInvalidToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, t.sign(payload)) {
+		return "", "", ErrInvalidToken
+	}
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", "", ErrInvalidToken
+	}
+	workspace, owner, ok = strings.Cut(string(b), "\x00")
+	if !ok {
+		return "", "", ErrInvalidToken
+	}
+	return workspace, owner, nil
+}
+
+func (t *Tokens) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write([]byte("calendar\x00" + payload))
+	return mac.Sum(nil)
+}
+
+// Component selects how tasks appear in a feed
+type Component string
+
+const (
+	// ComponentEvent lists tasks as events at their due time, which every
+	// calendar app shows
+	ComponentEvent Component = "vevent"
+
+	// ComponentTodo lists tasks as to-dos, shown by apps such as Apple
+	// Reminders but ignored by Google Calendar
+	ComponentTodo Component = "vtodo"
+)
+
+// IsValid reports whether c is a known component
+func (c Component) IsValid() bool {
+	return c == ComponentEvent || c == ComponentTodo
+}
+
+// refreshInterval is how often subscribed apps are asked to reload
+const refreshInterval = "PT1H"
+
+// Write renders the tasks that have a due date as a calendar of the given
+// component type. Tasks without one are skipped.
+func Write(w io.Writer, name string, tasks []*models.Task, component Component) error {
+	e := &encoder{}
+	e.line("BEGIN", "VCALENDAR")
+	e.line("VERSION", "2.0")
+	e.line("PRODID", "-//cert-tasks//Tasks//EN")
+	e.line("CALSCALE", "GREGORIAN")
+	e.line("METHOD", "PUBLISH")
+	e.line("X-WR-CALNAME", escape(name))
+	e.line("REFRESH-INTERVAL;VALUE=DURATION", refreshInterval)
+	e.line("X-PUBLISHED-TTL", refreshInterval)
+
+	for _, t := range tasks {
+		if t.DueAt == nil {
+			continue
+		}
+		if component == ComponentTodo {
+			e.line("BEGIN", "VTODO")
+		} else {
+			e.line("BEGIN", "VEVENT")
+		}
+
+		e.line("UID", "task-"+strconv.FormatInt(t.ID, 10)+"@cert-tasks")
+		// The stamp only changes with the task, so unchanged tasks look
+		// unchanged to apps polling the feed
+		e.line("DTSTAMP", formatTime(t.UpdatedAt))
+		e.line("CREATED", formatTime(t.CreatedAt))
+		e.line("LAST-MODIFIED", formatTime(t.UpdatedAt))
+		e.line("SUMMARY", escape(t.Title))
+		if t.Description != "" {
+			e.line("DESCRIPTION", escape(t.Description))
+		}
+
+		if component == ComponentTodo {
+			e.line("DUE", formatTime(*t.DueAt))
+			if t.Status == models.StatusDone {
+				e.line("STATUS", "COMPLETED")
+				completed := t.UpdatedAt
+				if t.CompletedAt != nil {
+					completed = *t.CompletedAt
+				}
+				e.line("COMPLETED", formatTime(completed))
+			} else {
+				e.line("STATUS", "NEEDS-ACTION")
+			}
+			e.line("END", "VTODO")
+		} else {
+			e.line("DTSTART", formatTime(*t.DueAt))
+			e.line("TRANSP", "TRANSPARENT")
+			e.line("END", "VEVENT")
+		}
+	}
+
+	e.line("END", "VCALENDAR")
+	_, err := w.Write(e.buf.Bytes())
+	return err
+}
+
+// encoder builds content lines, folded at 75 octets as RFC 5545 requires
+type encoder struct {
+	buf bytes.Buffer
+}
+
+// maxLineOctets is the longest content line before folding
+const maxLineOctets = 75
+
+func (e *encoder) line(name, value string) {
+	s := name + ":" + value
+	limit := maxLineOctets
+	for len(s) > limit {
+		cut := foldPoint(s, limit)
+		e.buf.WriteString(s[:cut])
+		e.buf.WriteString("\r\n ")
+		s = s[cut:]
+		limit = maxLineOctets - 1 // the leading space counts
+	}
+	e.buf.WriteString(s)
+	e.buf.WriteString("\r\n")
+}
+
+// foldPoint returns the last character boundary in s at or before max
+func foldPoint(s string, max int) int {
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return max
+}
+
+// escape escapes a TEXT value
+func escape(s string) string {
+	return textEscaper.Replace(s)
+}
+
+var textEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+	"\r", "",
+)
+
+// formatTime formats t as a UTC DATE-TIME
+func formatTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
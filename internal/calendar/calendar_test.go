@@ -0,0 +1,91 @@
+package calendar
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+)
+
+func TestTokens(t *testing.T) {
+	tokens := NewTokens([]byte("0123456789abcdef0123456789abcdef"))
+
+	token := tokens.Issue("acme", "alice")
+	workspace, owner, err := tokens.Verify(token)
+	if err != nil || workspace != "acme" || owner != "alice" {
+		t.Fatalf("Verify = %q, %q, %v; want acme, alice", workspace, owner, err)
+	}
+	if tokens.Issue("acme", "bob") == token {
+		t.Error("users share a token")
+	}
+
+	forged := NewTokens([]byte("another secret, another signature")).Issue("acme", "alice")
+	payload, _, _ := strings.Cut(token, ".")
+	_, sig, _ := strings.Cut(tokens.Issue("acme", ""), ".")
+	for _, bad := range []string{"", "garbage", forged, payload + "." + sig, token + "x"} {
+		if _, _, err := tokens.Verify(bad); err != ErrInvalidToken {
+			t.Errorf("Verify(%q) = %v, want ErrInvalidToken", bad, err)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	created := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	due := time.Date(2024, 2, 1, 17, 0, 0, 0, time.FixedZone("CET", 3600))
+	tasks := []*models.Task{
+		{ID: 1, Title: "No due date", CreatedAt: created, UpdatedAt: created},
+		{ID: 2, Title: "Pay rent; utilities, too", Description: "Line one\nLine two", Status: models.StatusTodo,
+			CreatedAt: created, UpdatedAt: created, DueAt: &due},
+		{ID: 3, Title: strings.Repeat("é", 50), Status: models.StatusDone, CreatedAt: created, UpdatedAt: created, DueAt: &due},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, "Tasks", tasks, ComponentTodo); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\nVERSION:2.0\r\n",
+		"UID:task-2@cert-tasks\r\n",
+		"DUE:20240201T160000Z\r\n",
+		`SUMMARY:Pay rent\; utilities\, too` + "\r\n",
+		`DESCRIPTION:Line one\nLine two` + "\r\n",
+		"STATUS:NEEDS-ACTION\r\n",
+		"STATUS:COMPLETED\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("calendar lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "task-1@") {
+		t.Error("task without a due date is listed")
+	}
+	if n := strings.Count(out, "BEGIN:VTODO"); n != 2 {
+		t.Errorf("%d to-dos, want 2", n)
+	}
+
+	// Long lines are folded on character boundaries
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line of %d octets: %q", len(line), line)
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("line splits a character: %q", line)
+		}
+	}
+	unfolded := strings.ReplaceAll(out, "\r\n ", "")
+	if !strings.Contains(unfolded, "SUMMARY:"+strings.Repeat("é", 50)+"\r\n") {
+		t.Errorf("folded summary does not unfold to the title:\n%s", out)
+	}
+
+	buf.Reset()
+	Write(&buf, "Tasks", tasks, ComponentEvent)
+	if out := buf.String(); strings.Count(out, "BEGIN:VEVENT") != 2 || !strings.Contains(out, "DTSTART:20240201T160000Z\r\n") {
+		t.Errorf("events calendar:\n%s", out)
+	}
+}
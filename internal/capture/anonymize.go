@@ -0,0 +1,82 @@
+package capture
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode"
+)
+
+// safeKeys are JSON members whose values are generated by the server or
+// drawn from a fixed vocabulary, so they are kept verbatim. Every other
+// string is masked.
+var safeKeys = map[string]bool{
+	"id":         true,
+	"task_id":    true,
+	"status":     true,
+	"type":       true,
+	"code":       true,
+	"field":      true,
+	"rule":       true,
+	"processor":  true,
+	"message":    true,
+	"created_at": true,
+	"updated_at": true,
+	"due_at":     true,
+	"request_id": true,
+}
+
+// anonymize masks user-supplied strings in a JSON body while keeping its
+// shape, so examples stay realistic without leaking content. It returns nil
+// for empty or non-JSON bodies.
+func anonymize(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return nil
+	}
+	out, err := json.Marshal(mask(v, false))
+	if err != nil {
+		return nil
+	}
+	return out
+}
+
+// mask walks v, masking strings unless keep is set by a safe parent key
+func mask(v any, keep bool) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			v[k] = mask(child, safeKeys[k])
+		}
+		return v
+	case []any:
+		for i, child := range v {
+			v[i] = mask(child, keep)
+		}
+		return v
+	case string:
+		if keep {
+			return v
+		}
+		return maskString(v)
+	default:
+		return v
+	}
+}
+
+// maskString replaces letters with "x" and digits with "0", preserving
+// length, spacing and punctuation
+func maskString(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsLetter(r):
+			return 'x'
+		case unicode.IsDigit(r):
+			return '0'
+		default:
+			return r
+		}
+	}, s)
+}
@@ -0,0 +1,225 @@
+// Package capture samples real request/response pairs, anonymizes them and
+// stores them as OpenAPI examples, so documentation examples come from
+// actual traffic and can be checked against the code by contract tests.
+package capture
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// maxBodyBytes bounds how much of a request or response body is captured
+const maxBodyBytes = 64 << 10
+
+// Config holds capture settings
+type Config struct {
+	// SampleRate is the fraction of requests captured, in (0, 1]
+	SampleRate float64
+
+	// MaxPerResponse caps the examples kept per route, method and status
+	MaxPerResponse int
+}
+
+// DefaultConfig samples 10% of requests and keeps 3 examples per response
+func DefaultConfig() Config {
+	return Config{
+		SampleRate:     0.1,
+		MaxPerResponse: 3,
+	}
+}
+
+// Example is one captured request/response pair
+type Example struct {
+	Method   string          `json:"method"`
+	Route    string          `json:"route"`
+	Status   int             `json:"status"`
+	Request  json.RawMessage `json:"request,omitempty"`
+	Response json.RawMessage `json:"response,omitempty"`
+}
+
+// key identifies the documented response an example belongs to
+type key struct {
+	route  string
+	method string
+	status int
+}
+
+// Recorder samples traffic into examples
+type Recorder struct {
+	cfg    Config
+	sample func() float64
+
+	mu       sync.Mutex
+	examples map[key][]Example
+}
+
+// New creates a Recorder
+func New(cfg Config) *Recorder {
+	return &Recorder{
+		cfg:      cfg,
+		sample:   rand.Float64,
+		examples: make(map[key][]Example),
+	}
+}
+
+// Middleware captures a sample of JSON exchanges. It must run inside the
+// chi router so the matched route pattern is known; requests to unknown
+// routes are never captured.
+func (rec *Recorder) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rec.sample() >= rec.cfg.SampleRate {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var reqBody limitedBuffer
+		if r.Body != nil {
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.TeeReader(r.Body, &reqBody), r.Body}
+		}
+
+		var respBody limitedBuffer
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		ww.Tee(&respBody)
+
+		next.ServeHTTP(ww, r)
+
+		rctx := chi.RouteContext(r.Context())
+		if rctx == nil || rctx.RoutePattern() == "" {
+			return
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if !strings.Contains(ww.Header().Get("Content-Type"), "json") {
+			respBody.Reset()
+		}
+
+		rec.add(Example{
+			Method:   r.Method,
+			Route:    rctx.RoutePattern(),
+			Status:   status,
+			Request:  anonymize(reqBody.Bytes()),
+			Response: anonymize(respBody.Bytes()),
+		})
+	})
+}
+
+// add stores ex unless its response already has enough examples
+func (rec *Recorder) add(ex Example) {
+	k := key{route: ex.Route, method: ex.Method, status: ex.Status}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.examples[k]) < rec.cfg.MaxPerResponse {
+		rec.examples[k] = append(rec.examples[k], ex)
+	}
+}
+
+// Examples returns the captured examples ordered by route, method and status
+func (rec *Recorder) Examples() []Example {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	var out []Example
+	for _, exs := range rec.examples {
+		out = append(out, exs...)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Route != b.Route {
+			return a.Route < b.Route
+		}
+		if a.Method != b.Method {
+			return a.Method < b.Method
+		}
+		return a.Status < b.Status
+	})
+	return out
+}
+
+// WriteFile atomically writes the captured examples to path as an OpenAPI
+// document
+func (rec *Recorder) WriteFile(path string) error {
+	data, err := json.MarshalIndent(NewDocument(rec.Examples()), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Run writes the examples to path every interval and once more when ctx is
+// done
+func (rec *Recorder) Run(ctx context.Context, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := rec.WriteFile(path); err != nil {
+				slog.Error("writing captured examples failed", slog.Any("error", err))
+			}
+			return
+		case <-ticker.C:
+			if err := rec.WriteFile(path); err != nil {
+				slog.Error("writing captured examples failed", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// limitedBuffer keeps at most maxBodyBytes; larger bodies are dropped
+// entirely rather than stored truncated
+type limitedBuffer struct {
+	bytes.Buffer
+	overflow bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.overflow || b.Len()+len(p) > maxBodyBytes {
+		b.overflow = true
+		b.Reset()
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// Bytes returns the captured body, or nil if it overflowed
+func (b *limitedBuffer) Bytes() []byte {
+	if b.overflow {
+		return nil
+	}
+	return b.Buffer.Bytes()
+}
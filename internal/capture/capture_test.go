@@ -0,0 +1,129 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func newRouter(rec *Recorder) http.Handler {
+	r := chi.NewRouter()
+	r.Use(rec.Middleware)
+	r.Post("/tasks", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		body["id"] = 7
+		body["status"] = "todo"
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(body)
+	})
+	r.Delete("/tasks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return r
+}
+
+func TestRecorder_Middleware(t *testing.T) {
+	rec := New(Config{SampleRate: 1, MaxPerResponse: 2})
+	router := newRouter(rec)
+
+	for i := 0; i < 3; i++ {
+		body := `{"title":"Call Alice at 555-0100","description":"Re: invoice #42"}`
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/tasks", bytes.NewBufferString(body)))
+	}
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("DELETE", "/tasks/7", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/unknown", nil))
+
+	examples := rec.Examples()
+	if len(examples) != 3 {
+		t.Fatalf("got %d examples, want 2 creates (capped) and 1 delete", len(examples))
+	}
+
+	del := examples[2]
+	if del.Route != "/tasks/{id}" || del.Method != "DELETE" || del.Status != http.StatusNoContent || del.Request != nil || del.Response != nil {
+		t.Errorf("delete example = %+v", del)
+	}
+
+	create := examples[0]
+	if create.Route != "/tasks" || create.Status != http.StatusCreated {
+		t.Fatalf("create example = %+v", create)
+	}
+	if got := string(create.Request); got != `{"description":"xx: xxxxxxx #00","title":"xxxx xxxxx xx 000-0000"}` {
+		t.Errorf("anonymized request = %s", got)
+	}
+	if got := string(create.Response); got != `{"description":"xx: xxxxxxx #00","id":7,"status":"todo","title":"xxxx xxxxx xx 000-0000"}` {
+		t.Errorf("anonymized response = %s", got)
+	}
+}
+
+func TestRecorder_SampleRate(t *testing.T) {
+	rec := New(Config{SampleRate: 0.5, MaxPerResponse: 10})
+	draws := []float64{0.7, 0.2}
+	rec.sample = func() float64 {
+		d := draws[0]
+		draws = draws[1:]
+		return d
+	}
+	router := newRouter(rec)
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("DELETE", "/tasks/1", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("DELETE", "/tasks/2", nil))
+
+	if got := len(rec.Examples()); got != 1 {
+		t.Errorf("got %d examples, want 1", got)
+	}
+}
+
+func TestDocument_RoundTrip(t *testing.T) {
+	rec := New(Config{SampleRate: 1, MaxPerResponse: 3})
+	router := newRouter(rec)
+	for _, title := range []string{"one", "two"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/tasks", bytes.NewBufferString(`{"title":"`+title+`"}`)))
+	}
+
+	path := filepath.Join(t.TempDir(), "examples.json")
+	if err := rec.WriteFile(path); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	doc, err := LoadDocument(f)
+	if err != nil {
+		t.Fatalf("LoadDocument: %v", err)
+	}
+	examples := doc.Examples()
+	want := rec.Examples()
+	if len(examples) != len(want) {
+		t.Fatalf("got %d examples, want %d", len(examples), len(want))
+	}
+	for i := range want {
+		if compact(t, examples[i].Request) != string(want[i].Request) || compact(t, examples[i].Response) != string(want[i].Response) {
+			t.Errorf("example %d = %+v, want %+v", i, examples[i], want[i])
+		}
+	}
+
+	create := doc.Paths["/tasks"]["post"]
+	if _, ok := create.RequestBody.Content["application/json"].Examples["201-2"]; !ok {
+		t.Error("request examples should be named after their response")
+	}
+}
+
+func compact(t *testing.T, raw json.RawMessage) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
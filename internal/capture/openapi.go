@@ -0,0 +1,183 @@
+package capture
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Document is an OpenAPI 3.1 document holding only the paths, operations
+// and examples that were captured. It is meant to be merged into, or read
+// alongside, the hand-written API description.
+type Document struct {
+	OpenAPI string                          `json:"openapi"`
+	Info    Info                            `json:"info"`
+	Paths   map[string]map[string]Operation `json:"paths"`
+}
+
+// Info is the OpenAPI info object
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// Operation is an OpenAPI operation reduced to its examples
+type Operation struct {
+	RequestBody *Body           `json:"requestBody,omitempty"`
+	Responses   map[string]Body `json:"responses"`
+}
+
+// Body is an OpenAPI request body or response with JSON examples
+type Body struct {
+	Description string             `json:"description,omitempty"`
+	Content     map[string]Content `json:"content,omitempty"`
+}
+
+// Content is an OpenAPI media type object
+type Content struct {
+	Examples map[string]ExampleValue `json:"examples"`
+}
+
+// ExampleValue is an OpenAPI example object
+type ExampleValue struct {
+	Summary string          `json:"summary,omitempty"`
+	Value   json.RawMessage `json:"value"`
+}
+
+// jsonMediaType is the media type examples are filed under
+const jsonMediaType = "application/json"
+
+// NewDocument files examples under their path, operation and status. A
+// request example and the response it produced share the same name, e.g.
+// "201-1", so the pair can be reassembled.
+func NewDocument(examples []Example) *Document {
+	doc := &Document{
+		OpenAPI: "3.1.0",
+		Info:    Info{Title: "cert-tasks captured examples", Version: "1.0.0"},
+		Paths:   make(map[string]map[string]Operation),
+	}
+
+	counts := make(map[key]int)
+	for _, ex := range examples {
+		method := strings.ToLower(ex.Method)
+		if doc.Paths[ex.Route] == nil {
+			doc.Paths[ex.Route] = make(map[string]Operation)
+		}
+		op, ok := doc.Paths[ex.Route][method]
+		if !ok {
+			op = Operation{Responses: make(map[string]Body)}
+		}
+
+		k := key{route: ex.Route, method: ex.Method, status: ex.Status}
+		counts[k]++
+		name := fmt.Sprintf("%d-%d", ex.Status, counts[k])
+		status := strconv.Itoa(ex.Status)
+
+		resp, ok := op.Responses[status]
+		if !ok {
+			resp = Body{Description: http.StatusText(ex.Status)}
+		}
+		if ex.Response != nil {
+			addExample(&resp, name, ex.Response)
+		}
+		op.Responses[status] = resp
+
+		if ex.Request != nil {
+			if op.RequestBody == nil {
+				op.RequestBody = &Body{}
+			}
+			addExample(op.RequestBody, name, ex.Request)
+		}
+
+		doc.Paths[ex.Route][method] = op
+	}
+
+	return doc
+}
+
+func addExample(b *Body, name string, value json.RawMessage) {
+	if b.Content == nil {
+		b.Content = map[string]Content{jsonMediaType: {Examples: make(map[string]ExampleValue)}}
+	}
+	b.Content[jsonMediaType].Examples[name] = ExampleValue{Value: value}
+}
+
+// LoadDocument reads a document written by Recorder.WriteFile
+func LoadDocument(r io.Reader) (*Document, error) {
+	var doc Document
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decoding captured examples: %w", err)
+	}
+	return &doc, nil
+}
+
+// Examples reassembles the request/response pairs in the document, ordered
+// by route, method, status and name
+func (d *Document) Examples() []Example {
+	var out []Example
+	for route, ops := range d.Paths {
+		for method, op := range ops {
+			for status, resp := range op.Responses {
+				code, err := strconv.Atoi(status)
+				if err != nil {
+					continue
+				}
+				for _, name := range responseExampleNames(op, status) {
+					ex := Example{Method: strings.ToUpper(method), Route: route, Status: code}
+					if c, ok := resp.Content[jsonMediaType]; ok {
+						ex.Response = c.Examples[name].Value
+					}
+					if op.RequestBody != nil {
+						if c, ok := op.RequestBody.Content[jsonMediaType]; ok {
+							ex.Request = c.Examples[name].Value
+						}
+					}
+					out = append(out, ex)
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Route != b.Route {
+			return a.Route < b.Route
+		}
+		if a.Method != b.Method {
+			return a.Method < b.Method
+		}
+		return a.Status < b.Status
+	})
+	return out
+}
+
+// responseExampleNames lists the example names recorded for a status,
+// including pairs whose response had no JSON body
+func responseExampleNames(op Operation, status string) []string {
+	names := make(map[string]bool)
+	if c, ok := op.Responses[status].Content[jsonMediaType]; ok {
+		for name := range c.Examples {
+			names[name] = true
+		}
+	}
+	if op.RequestBody != nil {
+		if c, ok := op.RequestBody.Content[jsonMediaType]; ok {
+			for name := range c.Examples {
+				if strings.HasPrefix(name, status+"-") {
+					names[name] = true
+				}
+			}
+		}
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
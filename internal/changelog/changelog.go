@@ -0,0 +1,211 @@
+// Package changelog builds and serves the machine-readable API changelog.
+//
+// Changes are recorded next to the code they affect with directive comments
+// of the form
+//
+//	//api:changelog <version> <kind> <scope> <target>: <description>
+//
+// for example
+//
+//	//api:changelog 0.2.0 added endpoint POST /tasks/{id}/links: Link two tasks
+//
+// Running go generate collects every annotation in the module into
+// changelog.json, which is embedded into the binary and served at
+// /changelog.json.
+package changelog
+
+//go:generate go run ../../cmd/changelog -root ../.. -out changelog.json
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Directive is the comment prefix that marks a changelog annotation
+const Directive = "//api:changelog "
+
+// Kinds of change, in the order they are listed within a release
+var kinds = []string{"added", "changed", "deprecated", "removed"}
+
+// Scopes describe which part of the API a change affects
+var scopes = []string{"endpoint", "field", "parameter", "header", "error"}
+
+//go:embed changelog.json
+var embedded []byte
+
+// Change is a single API-affecting change
+type Change struct {
+	Kind        string `json:"kind"`
+	Scope       string `json:"scope"`
+	Target      string `json:"target"`
+	Description string `json:"description"`
+}
+
+// Release groups the changes shipped in one version
+type Release struct {
+	Version string   `json:"version"`
+	Changes []Change `json:"changes"`
+}
+
+// Changelog lists releases, newest first
+type Changelog struct {
+	Releases []Release `json:"releases"`
+}
+
+// JSON returns the changelog generated at build time
+func JSON() []byte {
+	return embedded
+}
+
+// Marshal encodes the changelog in the format stored in changelog.json
+func (c *Changelog) Marshal() ([]byte, error) {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// Collect scans the non-test Go files under root for changelog annotations
+func Collect(root string) (*Changelog, error) {
+	byVersion := make(map[string][]Change)
+	fset := token.NewFileSet()
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != root && (strings.HasPrefix(name, ".") || name == "testdata" || name == "vendor") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+
+		file, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			return err
+		}
+		for _, group := range file.Comments {
+			for _, comment := range group.List {
+				text, ok := strings.CutPrefix(comment.Text, Directive)
+				if !ok {
+					continue
+				}
+				version, change, err := parseAnnotation(text)
+				if err != nil {
+					return fmt.Errorf("%s: %w", fset.Position(comment.Pos()), err)
+				}
+				byVersion[version] = append(byVersion[version], change)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Changelog{Releases: []Release{}}
+	for version, changes := range byVersion {
+		sort.Slice(changes, func(i, j int) bool {
+			a, b := changes[i], changes[j]
+			if ka, kb := indexOf(kinds, a.Kind), indexOf(kinds, b.Kind); ka != kb {
+				return ka < kb
+			}
+			if sa, sb := indexOf(scopes, a.Scope), indexOf(scopes, b.Scope); sa != sb {
+				return sa < sb
+			}
+			return a.Target < b.Target
+		})
+		c.Releases = append(c.Releases, Release{Version: version, Changes: changes})
+	}
+	sort.Slice(c.Releases, func(i, j int) bool {
+		return compareVersions(c.Releases[i].Version, c.Releases[j].Version) > 0
+	})
+
+	return c, nil
+}
+
+// parseAnnotation parses the text following the directive
+func parseAnnotation(text string) (string, Change, error) {
+	fields := strings.SplitN(text, " ", 4)
+	if len(fields) < 4 {
+		return "", Change{}, fmt.Errorf("changelog annotation %q: want \"<version> <kind> <scope> <target>: <description>\"", text)
+	}
+	version, kind, scope := fields[0], fields[1], fields[2]
+
+	target, description, ok := strings.Cut(fields[3], ": ")
+	if !ok || strings.TrimSpace(target) == "" || strings.TrimSpace(description) == "" {
+		return "", Change{}, fmt.Errorf("changelog annotation %q: missing \"<target>: <description>\"", text)
+	}
+	if _, ok := parseVersion(version); !ok {
+		return "", Change{}, fmt.Errorf("changelog annotation %q: version %q is not MAJOR.MINOR.PATCH", text, version)
+	}
+	if indexOf(kinds, kind) < 0 {
+		return "", Change{}, fmt.Errorf("changelog annotation %q: kind must be one of %s", text, strings.Join(kinds, ", "))
+	}
+	if indexOf(scopes, scope) < 0 {
+		return "", Change{}, fmt.Errorf("changelog annotation %q: scope must be one of %s", text, strings.Join(scopes, ", "))
+	}
+
+	return version, Change{
+		Kind:        kind,
+		Scope:       scope,
+		Target:      strings.TrimSpace(target),
+		Description: strings.TrimSpace(description),
+	}, nil
+}
+
+// parseVersion splits a MAJOR.MINOR.PATCH version into its numbers
+func parseVersion(v string) ([3]int, bool) {
+	var parts [3]int
+	fields := strings.Split(v, ".")
+	if len(fields) != 3 {
+		return parts, false
+	}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
+
+// compareVersions returns -1, 0 or 1 as a is older than, equal to or newer
+// than b. Both versions must already have been validated.
+func compareVersions(a, b string) int {
+	pa, _ := parseVersion(a)
+	pb, _ := parseVersion(b)
+	for i := range pa {
+		if pa[i] != pb[i] {
+			if pa[i] < pb[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
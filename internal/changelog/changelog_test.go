@@ -0,0 +1,108 @@
+package changelog
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, body string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollect(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a/a.go", `package a
+
+// Handler does things
+//
+//api:changelog 0.1.0 added endpoint GET /things: List things
+//api:changelog 0.10.0 removed field Thing.legacy: No longer returned
+func Handler() {}
+`)
+	writeFile(t, dir, "b/b.go", `package b
+
+//api:changelog 0.10.0 added endpoint POST /things: Create a thing
+//api:changelog 0.9.0 changed error not_found: Includes the missing ID
+var x = "//api:changelog 9.9.9 added endpoint GET /ignored: inside a string"
+`)
+	// Test files and hidden directories are not scanned
+	writeFile(t, dir, "b/b_test.go", "package b\n\n//api:changelog 1.0.0 added endpoint GET /test: ignored\n")
+	writeFile(t, dir, ".git/c.go", "package c\n\n//api:changelog 1.0.0 added endpoint GET /hidden: ignored\n")
+
+	c, err := Collect(dir)
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+
+	var versions []string
+	for _, r := range c.Releases {
+		versions = append(versions, r.Version)
+	}
+	if got := strings.Join(versions, ","); got != "0.10.0,0.9.0,0.1.0" {
+		t.Fatalf("versions = %s, want newest first", got)
+	}
+
+	// Within a release, additions come before removals
+	latest := c.Releases[0].Changes
+	if len(latest) != 2 || latest[0].Kind != "added" || latest[1].Kind != "removed" {
+		t.Fatalf("0.10.0 changes = %+v", latest)
+	}
+	if latest[0] != (Change{Kind: "added", Scope: "endpoint", Target: "POST /things", Description: "Create a thing"}) {
+		t.Errorf("change = %+v", latest[0])
+	}
+}
+
+func TestCollect_InvalidAnnotation(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation string
+		wantErr    string
+	}{
+		{"missing description", "0.1.0 added endpoint GET /x", "missing"},
+		{"bad version", "v1 added endpoint GET /x: X", "MAJOR.MINOR.PATCH"},
+		{"bad kind", "0.1.0 fixed endpoint GET /x: X", "kind must be one of"},
+		{"bad scope", "0.1.0 added route GET /x: X", "scope must be one of"},
+		{"too short", "0.1.0 added", "want"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, dir, "x.go", "package x\n\n//api:changelog "+tt.annotation+"\n")
+
+			_, err := Collect(dir)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) || !strings.Contains(err.Error(), "x.go:3") {
+				t.Errorf("error = %q, want position and %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// The embedded changelog must match the annotations in the tree; run
+// go generate ./internal/changelog after adding or editing one.
+func TestEmbeddedUpToDate(t *testing.T) {
+	c, err := Collect("../..")
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	want, err := c.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(JSON(), want) {
+		t.Error("changelog.json is stale; run go generate ./internal/changelog")
+	}
+}
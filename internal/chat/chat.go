@@ -0,0 +1,372 @@
+// Package chat posts task notifications to Slack and Microsoft Teams
+// incoming webhooks. Each Connector names a webhook, the events it is told
+// about and, optionally, a text/template per event for the message. Task
+// changes arrive through Publish, as a repository NotifyFunc; overdue
+// tasks are found by CheckOverdue, run as a scheduled job. Messages are
+// queued in memory and posted by a single worker, at most once: a post the
+// chat service rejects is logged and dropped, as are messages still queued
+// at shutdown.
+package chat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/logging"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+// Connector kinds
+const (
+	Slack = "slack"
+	Teams = "teams"
+)
+
+// EventTaskOverdue is sent once for a task still to do when its due date
+// passes. Unlike the other events it is not a change to the task, so
+// webhooks cannot subscribe to it.
+const EventTaskOverdue models.TaskEventType = "task.overdue"
+
+// DefaultQueueSize bounds the messages waiting to be posted
+const DefaultQueueSize = 1000
+
+// DefaultEvents are the events a connector posts when it names none
+var DefaultEvents = []models.TaskEventType{models.EventTaskCompleted, EventTaskOverdue}
+
+// defaultTemplates are the messages used for events a connector has no
+// template for
+var defaultTemplates = map[models.TaskEventType]string{
+	models.EventTaskCreated:   `New task: {{.Task.Title}}`,
+	models.EventTaskUpdated:   `Task updated: {{.Task.Title}}`,
+	models.EventTaskCompleted: `Task completed: {{.Task.Title}}`,
+	models.EventTaskDeleted:   `Task deleted: {{.Task.Title}}`,
+	EventTaskOverdue:          `Task overdue: {{.Task.Title}} was due {{.Task.DueAt.Format "2 Jan 2006 15:04 MST"}}`,
+}
+
+// metrics counts posted and failed messages for /debug/vars on the admin
+// listener
+var metrics = expvar.NewMap("chat")
+
+// Connector posts messages about some task events to one chat webhook
+type Connector struct {
+	// Name identifies the connector in logs
+	Name string `yaml:"name"`
+
+	// Kind is Slack or Teams
+	Kind string `yaml:"kind"`
+
+	// URL is the incoming webhook messages are posted to
+	URL string `yaml:"url"`
+
+	// Events are the events posted; empty means DefaultEvents
+	Events []models.TaskEventType `yaml:"events"`
+
+	// Workspace limits messages to the tasks of one workspace; empty
+	// means every workspace
+	Workspace string `yaml:"workspace"`
+
+	// Templates replace the default message for an event. They are
+	// text/templates executed with a Message.
+	Templates map[models.TaskEventType]string `yaml:"templates"`
+}
+
+// Message is the data a template is executed with
+type Message struct {
+	Event models.TaskEventType
+	Task  *models.Task
+}
+
+// Validate reports what is wrong with c, if anything
+func (c Connector) Validate() error {
+	_, err := c.compile()
+	return err
+}
+
+// compiled is a connector with its templates parsed
+type compiled struct {
+	Connector
+	templates map[models.TaskEventType]*template.Template
+}
+
+// compile checks c and parses its templates
+func (c Connector) compile() (*compiled, error) {
+	if c.Kind != Slack && c.Kind != Teams {
+		return nil, fmt.Errorf("kind %q is not %q or %q", c.Kind, Slack, Teams)
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("url %q is not an http(s) URL", c.URL)
+	}
+	if len(c.Events) == 0 {
+		c.Events = DefaultEvents
+	}
+	for _, event := range c.Events {
+		if !event.IsValid() && event != EventTaskOverdue {
+			return nil, fmt.Errorf("unknown event %q", event)
+		}
+	}
+
+	cc := &compiled{Connector: c, templates: make(map[models.TaskEventType]*template.Template)}
+	for event, text := range defaultTemplates {
+		if custom, ok := c.Templates[event]; ok {
+			text = custom
+		}
+		tmpl, err := template.New(string(event)).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("template for %s: %w", event, err)
+		}
+		cc.templates[event] = tmpl
+	}
+	for event := range c.Templates {
+		if _, ok := defaultTemplates[event]; !ok {
+			return nil, fmt.Errorf("template for unknown event %q", event)
+		}
+	}
+	return cc, nil
+}
+
+// subscribed reports whether c posts event for task
+func (c *compiled) subscribed(event models.TaskEventType, task *models.Task) bool {
+	if c.Workspace != "" && c.Workspace != task.WorkspaceID {
+		return false
+	}
+	for _, e := range c.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// render executes the template for event and encodes the result as the
+// webhook's JSON payload
+func (c *compiled) render(event models.TaskEventType, task *models.Task) ([]byte, error) {
+	// Slack treats <...> as links and mentions, so task text is escaped
+	// to keep a title from pinging a channel
+	if c.Kind == Slack {
+		escaped := *task
+		escaped.Title = escapeSlack(task.Title)
+		escaped.Description = escapeSlack(task.Description)
+		task = &escaped
+	}
+
+	var text bytes.Buffer
+	if err := c.templates[event].Execute(&text, Message{Event: event, Task: task}); err != nil {
+		return nil, fmt.Errorf("rendering %s message: %w", event, err)
+	}
+	if c.Kind == Slack {
+		return json.Marshal(slackMessage{Text: text.String()})
+	}
+	return json.Marshal(teamsMessage(text.String()))
+}
+
+// escapeSlack escapes the characters Slack's message formatting reserves
+func escapeSlack(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// slackMessage is the payload of a Slack incoming webhook
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+// teamsMessage returns the payload of a Teams incoming webhook: a message
+// with an Adaptive Card showing text
+func teamsMessage(text string) map[string]any {
+	return map[string]any{
+		"type": "message",
+		"attachments": []any{map[string]any{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content": map[string]any{
+				"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+				"type":    "AdaptiveCard",
+				"version": "1.4",
+				"body": []any{map[string]any{
+					"type": "TextBlock",
+					"text": text,
+					"wrap": true,
+				}},
+			},
+		}},
+	}
+}
+
+// post is a rendered message waiting for the worker
+type post struct {
+	connector *compiled
+	event     models.TaskEventType
+	taskID    int64
+	body      []byte
+}
+
+// Notifier renders task events for its connectors and posts them
+type Notifier struct {
+	connectors []*compiled
+	repo       repository.TaskRepository
+	workspaces repository.WorkspaceRepository
+	client     *http.Client
+	now        func() time.Time
+	queue      chan post
+
+	// checked is the end of the last overdue check; tasks that fell due
+	// before it have been reported
+	mu      sync.Mutex
+	checked time.Time
+}
+
+// New creates a Notifier posting to connectors with client, which should
+// be an outbound.Client bounded by the notifier budget. Overdue tasks are
+// read from repo, across all workspaces; only tasks falling due from now
+// on are reported. Due dates are shown in the time zone of the task's
+// workspace, read from workspaces; a nil workspaces shows them in UTC.
+func New(connectors []Connector, repo repository.TaskRepository, workspaces repository.WorkspaceRepository, client *http.Client, queueSize int) (*Notifier, error) {
+	n := &Notifier{
+		repo:       repo,
+		workspaces: workspaces,
+		client:     client,
+		now:        time.Now,
+		queue:      make(chan post, queueSize),
+		checked:    time.Now(),
+	}
+	for i, c := range connectors {
+		cc, err := c.compile()
+		if err != nil {
+			return nil, fmt.Errorf("connector %d: %w", i, err)
+		}
+		if cc.Name == "" {
+			cc.Name = cc.Kind
+		}
+		n.connectors = append(n.connectors, cc)
+	}
+	return n, nil
+}
+
+// Publish queues a message about a task change for every connector
+// subscribed to it. Completing a task is also reported as an update;
+// connectors are told about both only if they ask for both.
+func (n *Notifier) Publish(ctx context.Context, typ models.TaskEventType, task *models.Task) {
+	localized := false
+	for _, c := range n.connectors {
+		if !c.subscribed(typ, task) {
+			continue
+		}
+		if !localized {
+			task = n.localize(ctx, task)
+			localized = true
+		}
+		body, err := c.render(typ, task)
+		if err != nil {
+			logging.FromContext(ctx).Error("rendering chat message failed",
+				slog.String("connector", c.Name), slog.Any("error", err))
+			metrics.Add("failed", 1)
+			continue
+		}
+
+		select {
+		case n.queue <- post{connector: c, event: typ, taskID: task.ID, body: body}:
+		default:
+			logging.FromContext(ctx).Warn("chat queue full, dropping message",
+				slog.String("connector", c.Name), slog.String("event", string(typ)))
+			metrics.Add("dropped", 1)
+		}
+	}
+}
+
+// localize returns a copy of task with its due date in the time zone of
+// its workspace
+func (n *Notifier) localize(ctx context.Context, task *models.Task) *models.Task {
+	if task.DueAt == nil || n.workspaces == nil {
+		return task
+	}
+	id := task.WorkspaceID
+	if id == "" {
+		id = models.DefaultWorkspace
+	}
+	ws, err := n.workspaces.GetWorkspace(ctx, id)
+	if err != nil {
+		return task
+	}
+	c := *task
+	due := task.DueAt.In(ws.Location())
+	c.DueAt = &due
+	return &c
+}
+
+// CheckOverdue reports every task still to do whose due date has passed
+// since the last check
+func (n *Notifier) CheckOverdue(ctx context.Context) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	now := n.now()
+	tasks, err := n.repo.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		if task.Status != models.StatusTodo || task.DueAt == nil {
+			continue
+		}
+		if !task.DueAt.After(n.checked) || task.DueAt.After(now) {
+			continue
+		}
+		n.Publish(ctx, EventTaskOverdue, task)
+	}
+	n.checked = now
+	return nil
+}
+
+// Run posts queued messages in order until ctx is done
+func (n *Notifier) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case p := <-n.queue:
+			if err := n.send(ctx, p); err != nil {
+				metrics.Add("failed", 1)
+				slog.Warn("posting chat message failed",
+					slog.String("connector", p.connector.Name),
+					slog.String("event", string(p.event)),
+					slog.Int64("task_id", p.taskID),
+					slog.Any("error", err),
+				)
+				continue
+			}
+			metrics.Add("posted", 1)
+		}
+	}
+}
+
+// send posts one message
+func (n *Notifier) send(ctx context.Context, p post) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.connector.URL, bytes.NewReader(p.body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "cert-tasks-chat")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+// recorder is a chat webhook keeping the bodies posted to it
+type recorder struct {
+	mu     sync.Mutex
+	bodies []string
+}
+
+func (rec *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.bodies = append(rec.bodies, string(body))
+}
+
+// await waits for n posts and returns them
+func (rec *recorder) await(t *testing.T, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		rec.mu.Lock()
+		bodies := append([]string(nil), rec.bodies...)
+		rec.mu.Unlock()
+		if len(bodies) >= n || time.Now().After(deadline) {
+			if len(bodies) != n {
+				t.Fatalf("got %d posts %q, want %d", len(bodies), bodies, n)
+			}
+			return bodies
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestNotifier_Publish(t *testing.T) {
+	slack, teams := &recorder{}, &recorder{}
+	slackSrv, teamsSrv := httptest.NewServer(slack), httptest.NewServer(teams)
+	defer slackSrv.Close()
+	defer teamsSrv.Close()
+
+	n, err := New([]Connector{
+		{
+			Kind:      Slack,
+			URL:       slackSrv.URL,
+			Workspace: "acme",
+			Templates: map[models.TaskEventType]string{
+				models.EventTaskCompleted: `:white_check_mark: *{{.Task.Title}}* is done ({{.Event}})`,
+			},
+		},
+		{Kind: Teams, URL: teamsSrv.URL, Events: []models.TaskEventType{models.EventTaskCreated}},
+	}, repository.NewMemoryRepository(), nil, http.DefaultClient, 10)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go n.Run(ctx)
+
+	task := &models.Task{ID: 1, WorkspaceID: "acme", Title: "Ship <!channel> & celebrate", Status: models.StatusDone}
+	n.Publish(ctx, models.EventTaskCreated, task)
+	n.Publish(ctx, models.EventTaskUpdated, task)
+	n.Publish(ctx, models.EventTaskCompleted, task)
+	n.Publish(ctx, models.EventTaskCompleted, &models.Task{ID: 2, WorkspaceID: "other", Title: "Elsewhere"})
+
+	var msg slackMessage
+	json.Unmarshal([]byte(slack.await(t, 1)[0]), &msg)
+	if want := ":white_check_mark: *Ship &lt;!channel&gt; &amp; celebrate* is done (task.completed)"; msg.Text != want {
+		t.Errorf("Slack text = %q, want %q", msg.Text, want)
+	}
+
+	var card struct {
+		Type        string `json:"type"`
+		Attachments []struct {
+			ContentType string `json:"contentType"`
+			Content     struct {
+				Body []struct {
+					Text string `json:"text"`
+				} `json:"body"`
+			} `json:"content"`
+		} `json:"attachments"`
+	}
+	json.Unmarshal([]byte(teams.await(t, 1)[0]), &card)
+	if card.Type != "message" || len(card.Attachments) != 1 ||
+		card.Attachments[0].Content.Body[0].Text != "New task: Ship <!channel> & celebrate" {
+		t.Errorf("Teams card = %+v", card)
+	}
+}
+
+func TestNotifier_CheckOverdue(t *testing.T) {
+	hook := &recorder{}
+	srv := httptest.NewServer(hook)
+	defer srv.Close()
+
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	n, _ := New([]Connector{{Kind: Slack, URL: srv.URL}}, repo, repo, http.DefaultClient, 10)
+	start := time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC)
+	n.checked = start
+	now := start
+	n.now = func() time.Time { return now }
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go n.Run(runCtx)
+
+	due := func(d time.Duration) *time.Time {
+		at := start.Add(d)
+		return &at
+	}
+	repo.Create(ctx, &models.Task{Title: "Overdue before start", Status: models.StatusTodo, DueAt: due(-time.Hour)})
+	repo.Create(ctx, &models.Task{Title: "Report", Status: models.StatusTodo, DueAt: due(30 * time.Minute)})
+	repo.Create(ctx, &models.Task{Title: "Done already", Status: models.StatusDone, DueAt: due(30 * time.Minute)})
+	repo.Create(ctx, &models.Task{Title: "Later", Status: models.StatusTodo, DueAt: due(2 * time.Hour)})
+
+	now = start.Add(time.Hour)
+	if err := n.CheckOverdue(ctx); err != nil {
+		t.Fatalf("CheckOverdue() error = %v", err)
+	}
+	// A second check in the same window reports nothing again
+	if err := n.CheckOverdue(ctx); err != nil {
+		t.Fatalf("CheckOverdue() error = %v", err)
+	}
+
+	var msg slackMessage
+	json.Unmarshal([]byte(hook.await(t, 1)[0]), &msg)
+	if msg.Text != "Task overdue: Report was due 15 Jan 2024 09:30 UTC" {
+		t.Errorf("text = %q", msg.Text)
+	}
+}
+
+func TestNotifier_CheckOverdue_WorkspaceTimezone(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no time zone database:", err)
+	}
+	hook := &recorder{}
+	srv := httptest.NewServer(hook)
+	defer srv.Close()
+
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	repo.CreateWorkspace(ctx, &models.Workspace{ID: "berlin", Name: "Berlin", Timezone: "Europe/Berlin"})
+	n, _ := New([]Connector{{Kind: Slack, URL: srv.URL}}, repo, repo, http.DefaultClient, 10)
+	// Clocks in Berlin go forward from 02:00 to 03:00 at 01:00 UTC
+	start := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+	n.checked = start
+	now := start
+	n.now = func() time.Time { return now }
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go n.Run(runCtx)
+
+	wsCtx := repository.WithWorkspace(ctx, "berlin")
+	before := time.Date(2024, 3, 31, 1, 30, 0, 0, berlin)
+	after := time.Date(2024, 3, 31, 3, 30, 0, 0, berlin)
+	repo.Create(wsCtx, &models.Task{Title: "Before", Status: models.StatusTodo, DueAt: &before})
+	repo.Create(wsCtx, &models.Task{Title: "After", Status: models.StatusTodo, DueAt: &after})
+
+	// 03:00 CEST: an hour after 01:30 CET, but before 03:30 CEST
+	now = start.Add(time.Hour)
+	n.CheckOverdue(ctx)
+	now = start.Add(2 * time.Hour)
+	n.CheckOverdue(ctx)
+
+	want := []string{
+		"Task overdue: Before was due 31 Mar 2024 01:30 CET",
+		"Task overdue: After was due 31 Mar 2024 03:30 CEST",
+	}
+	for i, body := range hook.await(t, 2) {
+		var msg slackMessage
+		json.Unmarshal([]byte(body), &msg)
+		if msg.Text != want[i] {
+			t.Errorf("message %d = %q, want %q", i, msg.Text, want[i])
+		}
+	}
+}
+
+func TestConnector_Validate(t *testing.T) {
+	tests := []struct {
+		name string
+		c    Connector
+		want string
+	}{
+		{"valid", Connector{Kind: Teams, URL: "https://example.webhook.office.com/x"}, ""},
+		{"kind", Connector{Kind: "discord", URL: "https://example.com"}, `kind "discord"`},
+		{"url", Connector{Kind: Slack, URL: "hooks.slack.com"}, "not an http(s) URL"},
+		{"event", Connector{Kind: Slack, URL: "https://example.com", Events: []models.TaskEventType{"task.archived"}}, `unknown event "task.archived"`},
+		{"template", Connector{Kind: Slack, URL: "https://example.com", Templates: map[models.TaskEventType]string{
+			models.EventTaskCompleted: "{{.Task.Title",
+		}}, "template for task.completed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.c.Validate()
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+// Package cleanup deletes tasks that have outlived their retention policy,
+// such as tasks done for more than 90 days. A Janitor runs the policies as
+// a scheduled job and on demand at POST /admin/cleanup, and counts what it
+// deletes in the "cleanup" expvar map.
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+// metrics counts runs and deleted tasks for /debug/vars on the admin
+// listener
+var metrics = expvar.NewMap("cleanup")
+
+// Policy deletes the tasks with a status that have not changed for longer
+// than OlderThan
+type Policy struct {
+	Status    models.TaskStatus `yaml:"status"`
+	OlderThan time.Duration     `yaml:"older_than"`
+}
+
+// Validate reports what is wrong with p, if anything
+func (p Policy) Validate() error {
+	if p.Status != models.StatusTodo && p.Status != models.StatusDone {
+		return fmt.Errorf("status %q is not todo or done", p.Status)
+	}
+	if p.OlderThan <= 0 {
+		return fmt.Errorf("older_than %s is not a positive duration", p.OlderThan)
+	}
+	return nil
+}
+
+// ParsePolicies parses a comma-separated list of status:age policies, as
+// in "done:2160h,todo:8760h"
+func ParsePolicies(s string) ([]Policy, error) {
+	var policies []Policy
+	for _, entry := range strings.Split(s, ",") {
+		status, age, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok {
+			return nil, fmt.Errorf("%q is not status:age", entry)
+		}
+		d, err := time.ParseDuration(age)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %q is not a duration", entry, age)
+		}
+		p := Policy{Status: models.TaskStatus(status), OlderThan: d}
+		if err := p.Validate(); err != nil {
+			return nil, fmt.Errorf("%q: %w", entry, err)
+		}
+		policies = append(policies, p)
+	}
+	return policies, nil
+}
+
+// PolicyResult is what one policy deleted in a run
+type PolicyResult struct {
+	Status    models.TaskStatus `json:"status"`
+	OlderThan string            `json:"older_than"`
+	Deleted   int               `json:"deleted"`
+}
+
+// Result reports a run. In a dry run nothing is deleted and Deleted counts
+// the tasks that would have been.
+type Result struct {
+	StartedAt time.Time      `json:"started_at"`
+	DryRun    bool           `json:"dry_run"`
+	Deleted   int            `json:"deleted"`
+	Policies  []PolicyResult `json:"policies"`
+}
+
+// Janitor deletes the tasks its policies select
+type Janitor struct {
+	repo     repository.TaskRepository
+	policies []Policy
+	now      func() time.Time
+
+	// mu keeps scheduled and on-demand runs from overlapping
+	mu sync.Mutex
+}
+
+// New creates a Janitor deleting through repo, which should report
+// deletes like any other so webhooks and subscribers hear of them
+func New(repo repository.TaskRepository, policies []Policy) *Janitor {
+	return &Janitor{repo: repo, policies: policies, now: time.Now}
+}
+
+// Run deletes the tasks every policy selects, across all workspaces,
+// calling beat after each policy. Tasks deleted by someone else during the
+// run are skipped.
+func (j *Janitor) Run(ctx context.Context, beat func()) (Result, error) {
+	result, err := j.run(ctx, false, beat)
+	metrics.Add("runs", 1)
+	metrics.Add("deleted", int64(result.Deleted))
+	if err != nil {
+		metrics.Add("failures", 1)
+	}
+	return result, err
+}
+
+// Preview reports what Run would delete, without deleting anything
+func (j *Janitor) Preview(ctx context.Context) (Result, error) {
+	return j.run(ctx, true, func() {})
+}
+
+// run applies every policy in turn, deleting nothing in a dry run
+func (j *Janitor) run(ctx context.Context, dryRun bool, beat func()) (Result, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := j.now()
+	result := Result{StartedAt: now.UTC(), DryRun: dryRun, Policies: []PolicyResult{}}
+	for _, p := range j.policies {
+		tasks, err := j.repo.GetAll(ctx)
+		if err != nil {
+			return result, err
+		}
+
+		result.Policies = append(result.Policies, PolicyResult{Status: p.Status, OlderThan: p.OlderThan.String()})
+		pr := &result.Policies[len(result.Policies)-1]
+		cutoff := now.Add(-p.OlderThan)
+		for _, task := range tasks {
+			if task.Status != p.Status || !task.UpdatedAt.Before(cutoff) {
+				continue
+			}
+			if !dryRun {
+				err := j.repo.Delete(ctx, task.ID)
+				if errors.Is(err, repository.ErrTaskNotFound) {
+					continue
+				}
+				if err != nil {
+					return result, err
+				}
+			}
+			pr.Deleted++
+			result.Deleted++
+		}
+		beat()
+	}
+
+	if !dryRun && result.Deleted > 0 {
+		slog.InfoContext(ctx, "cleanup deleted tasks", slog.Int("deleted", result.Deleted))
+	}
+	return result, nil
+}
@@ -0,0 +1,67 @@
+package cleanup
+
+import (
+	"context"
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+func TestParsePolicies(t *testing.T) {
+	policies, err := ParsePolicies("done:2160h, todo:8760h")
+	if err != nil || len(policies) != 2 || policies[0] != (Policy{models.StatusDone, 2160 * time.Hour}) || policies[1].Status != models.StatusTodo {
+		t.Errorf("ParsePolicies() = %+v, %v", policies, err)
+	}
+
+	for _, s := range []string{"done", "done:90d", "pending:1h", "done:-1h", "done:0s"} {
+		if _, err := ParsePolicies(s); err == nil {
+			t.Errorf("ParsePolicies(%q) succeeded", s)
+		}
+	}
+}
+
+func TestJanitor_Run(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	repo.CreateWorkspace(ctx, &models.Workspace{ID: "acme", Name: "Acme"})
+
+	old, _ := repo.Create(ctx, &models.Task{Title: "Old report"})
+	repo.Update(ctx, old.ID, &models.Task{Title: "Old report", Status: models.StatusDone})
+	other, _ := repo.Create(repository.WithWorkspace(ctx, "acme"), &models.Task{Title: "Old invoice"})
+	repo.Update(ctx, other.ID, &models.Task{Title: "Old invoice", Status: models.StatusDone})
+	open, _ := repo.Create(ctx, &models.Task{Title: "Still open"})
+
+	janitor := New(repo, []Policy{{Status: models.StatusDone, OlderThan: 90 * 24 * time.Hour}})
+
+	// Nothing is old enough yet
+	if result, err := janitor.Run(ctx, func() {}); err != nil || result.Deleted != 0 {
+		t.Fatalf("Run() = %+v, %v, want nothing deleted", result, err)
+	}
+
+	janitor.now = func() time.Time { return time.Now().Add(91 * 24 * time.Hour) }
+	preview, err := janitor.Preview(ctx)
+	if err != nil || !preview.DryRun || preview.Deleted != 2 {
+		t.Fatalf("Preview() = %+v, %v", preview, err)
+	}
+	if n, _ := repo.Count(ctx, repository.TaskFilter{}); n != 3 {
+		t.Fatalf("Preview() deleted tasks: %d left, want 3", n)
+	}
+
+	runs := metrics.Get("runs").(*expvar.Int).Value()
+	beats := 0
+	result, err := janitor.Run(ctx, func() { beats++ })
+	if err != nil || result.Deleted != 2 || len(result.Policies) != 1 || result.Policies[0].Deleted != 2 || beats != 1 {
+		t.Fatalf("Run() = %+v, %v with %d beats", result, err, beats)
+	}
+	// Every workspace is cleaned; open tasks are kept
+	tasks, _ := repo.GetAll(ctx)
+	if len(tasks) != 1 || tasks[0].ID != open.ID {
+		t.Errorf("tasks after Run() = %+v, want only %q", tasks, open.Title)
+	}
+	if got := metrics.Get("runs").(*expvar.Int).Value(); got != runs+1 {
+		t.Errorf("runs metric = %d, want %d", got, runs+1)
+	}
+}
@@ -0,0 +1,1381 @@
+// Package config loads the server configuration from an optional YAML file
+// overlaid with environment variables, and validates it before startup.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/light-bringer/cert-tasks/internal/accesslog"
+	"github.com/light-bringer/cert-tasks/internal/blob"
+	"github.com/light-bringer/cert-tasks/internal/bodylog"
+	"github.com/light-bringer/cert-tasks/internal/capture"
+	"github.com/light-bringer/cert-tasks/internal/chat"
+	"github.com/light-bringer/cert-tasks/internal/cleanup"
+	"github.com/light-bringer/cert-tasks/internal/demo"
+	"github.com/light-bringer/cert-tasks/internal/encryption"
+	"github.com/light-bringer/cert-tasks/internal/handlers"
+	"github.com/light-bringer/cert-tasks/internal/ids"
+	"github.com/light-bringer/cert-tasks/internal/jobs"
+	"github.com/light-bringer/cert-tasks/internal/maintenance"
+	"github.com/light-bringer/cert-tasks/internal/outbound"
+	"github.com/light-bringer/cert-tasks/internal/ratelimit"
+	"github.com/light-bringer/cert-tasks/internal/recovery"
+	"github.com/light-bringer/cert-tasks/internal/redact"
+	"github.com/light-bringer/cert-tasks/plugin"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the complete server configuration
+type Config struct {
+	Server  Server  `yaml:"server"`
+	Storage Storage `yaml:"storage"`
+	Auth    Auth    `yaml:"auth"`
+	Log     Log     `yaml:"log"`
+	Demo    Demo    `yaml:"demo"`
+	Content Content `yaml:"content"`
+	Capture Capture `yaml:"capture"`
+	Events  Events  `yaml:"events"`
+	Panics  Panics  `yaml:"panics"`
+	Cleanup Cleanup `yaml:"cleanup"`
+	Jobs    Jobs    `yaml:"jobs"`
+	Backup  Backup  `yaml:"backup"`
+	Blob    Blob    `yaml:"blob"`
+	Erasure Erasure `yaml:"erasure"`
+
+	// Webhooks holds where webhook deliveries may connect
+	Webhooks Webhooks `yaml:"webhooks"`
+
+	// Notifications posts task events to Slack and Teams
+	Notifications Notifications `yaml:"notifications"`
+
+	// Outbound bounds calls to external systems such as webhooks
+	Outbound outbound.Budgets `yaml:"outbound"`
+
+	// Plugins enables registered plugins, in order, each with its own
+	// config section
+	Plugins []plugin.Spec `yaml:"plugins"`
+
+	// Personal is set by the --personal flag, not by the file
+	Personal bool `yaml:"-"`
+}
+
+// Server holds listener and HTTP settings
+type Server struct {
+	Addr            string         `yaml:"addr"`
+	ReadTimeout     time.Duration  `yaml:"read_timeout"`
+	WriteTimeout    time.Duration  `yaml:"write_timeout"`
+	IdleTimeout     time.Duration  `yaml:"idle_timeout"`
+	ShutdownTimeout time.Duration  `yaml:"shutdown_timeout"`
+	TrustedProxies  []netip.Prefix `yaml:"trusted_proxies"`
+	AdminAddr       string         `yaml:"admin_addr"`
+	MaxBodyBytes    int64          `yaml:"max_body_bytes"`
+
+	// RequestTimeouts bound how long a request may run, per route group
+	RequestTimeouts RequestTimeouts `yaml:"request_timeouts"`
+
+	// UndoWindow is how long DELETE /tasks/{id} and bulk deletes can be
+	// undone with their X-Undo-Token; zero turns undo off
+	UndoWindow time.Duration `yaml:"undo_window"`
+
+	// ErrorFormat is "json" or "problem+json"
+	ErrorFormat string `yaml:"error_format"`
+
+	// TranslationsDir holds <language>.json message bundles added to, or
+	// overriding, the built-in translations of error messages
+	TranslationsDir string `yaml:"translations_dir"`
+
+	// Docs serves a Swagger UI for /openapi.json at /docs
+	Docs bool `yaml:"docs"`
+
+	// CacheControl is the Cache-Control header of GET /tasks; empty sends
+	// none
+	CacheControl string `yaml:"cache_control"`
+
+	// ReadOnly starts the server in maintenance mode: writes get 503 until
+	// it is turned off at PUT /admin/maintenance. ReadOnlyMessage is shown
+	// at GET /admin/maintenance.
+	ReadOnly        bool   `yaml:"read_only"`
+	ReadOnlyMessage string `yaml:"read_only_message"`
+
+	CORS        CORS        `yaml:"cors"`
+	Compression Compression `yaml:"compression"`
+	TLS         TLS         `yaml:"tls"`
+	RateLimit   RateLimit   `yaml:"rate_limit"`
+}
+
+// RequestTimeouts cancel requests still running after their route group's
+// timeout and answer them with 503. Unlike the write timeout, which closes
+// the connection without a response, the client gets an error body. Zero
+// disables a group's timeout; the write timeout still applies.
+type RequestTimeouts struct {
+	// Tasks covers the task, webhook and calendar routes
+	Tasks time.Duration `yaml:"tasks"`
+
+	// Bulk covers export, import, bulk delete, backup and restore
+	Bulk time.Duration `yaml:"bulk"`
+
+	// Admin covers the other admin-key routes
+	Admin time.Duration `yaml:"admin"`
+}
+
+// Compression gzip- or deflate-encodes responses for clients that send a
+// matching Accept-Encoding
+type Compression struct {
+	Enabled bool `yaml:"enabled"`
+
+	// MinSize is the smallest body, in bytes, worth compressing
+	MinSize int `yaml:"min_size"`
+
+	// ContentTypes lists the media types compressed; "text/*" matches
+	// every text type
+	ContentTypes []string `yaml:"content_types"`
+}
+
+// RateLimit caps requests per client IP on the task API. It is disabled
+// while RPS is zero.
+type RateLimit struct {
+	RPS   float64 `yaml:"rps"`
+	Burst int     `yaml:"burst"`
+
+	// Store is "memory" (per instance) or a redis:// URL shared by all
+	// instances
+	Store string `yaml:"store"`
+}
+
+// Enabled reports whether requests are rate limited
+func (r RateLimit) Enabled() bool {
+	return r.RPS > 0
+}
+
+// Config converts to the ratelimit package's settings
+func (r RateLimit) Config() ratelimit.Config {
+	return ratelimit.Config{RPS: r.RPS, Burst: r.Burst}
+}
+
+// TLS makes the server terminate HTTPS itself, with either a certificate
+// from files or one obtained automatically over ACME. It is disabled while
+// no certificate source is set.
+type TLS struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+
+	// AutocertDomains enables ACME (Let's Encrypt) certificates for these
+	// host names; certificates are stored in AutocertCacheDir
+	AutocertDomains  []string `yaml:"autocert_domains"`
+	AutocertCacheDir string   `yaml:"autocert_cache_dir"`
+	AutocertEmail    string   `yaml:"autocert_email"`
+
+	// RedirectAddr, if set, is a plain HTTP listener that redirects to
+	// HTTPS and answers ACME HTTP-01 challenges
+	RedirectAddr string `yaml:"redirect_addr"`
+}
+
+// Enabled reports whether the server should serve HTTPS
+func (t TLS) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != "" || len(t.AutocertDomains) > 0
+}
+
+// CORS controls cross-origin access from browsers. It is disabled while
+// AllowedOrigins is empty.
+type CORS struct {
+	AllowedOrigins []string      `yaml:"allowed_origins"`
+	AllowedMethods []string      `yaml:"allowed_methods"`
+	AllowedHeaders []string      `yaml:"allowed_headers"`
+	MaxAge         time.Duration `yaml:"max_age"`
+}
+
+// Storage selects the task repository
+type Storage struct {
+	// DSN is "memory://" or "file:///path/to/tasks.json". Empty means
+	// memory, or the personal data directory in personal mode.
+	DSN string `yaml:"dsn"`
+
+	// IDFormat is "sequential", "ulid" or "uuidv7". The latter give tasks
+	// an opaque uid, which /tasks/{id} routes then take instead of the
+	// numeric ID.
+	IDFormat string `yaml:"id_format"`
+
+	// NodeID, when set, makes task IDs Snowflake IDs carrying this node
+	// number, so that replicas sharing a store never mint the same ID.
+	// Unset keeps the per-process counter from 1.
+	NodeID *int `yaml:"node_id"`
+
+	// WriteBehind, when positive, lets file storage batch the saves of
+	// task writes made within this long of each other; a crash loses at
+	// most this much. Zero saves on every write.
+	WriteBehind time.Duration `yaml:"write_behind"`
+
+	// WriteBehindMaxPending caps unsaved task writes; the write reaching
+	// it saves the batch itself, so writers slow to the disk's pace
+	WriteBehindMaxPending int `yaml:"write_behind_max_pending"`
+
+	// Migrate upgrades a snapshot in an older format when the server
+	// starts. When false the server refuses to start instead, so the file
+	// can be backed up first.
+	Migrate bool `yaml:"migrate"`
+
+	// Encryption encrypts the snapshot file at rest
+	Encryption StorageEncryption `yaml:"encryption"`
+}
+
+// StorageEncryption holds the keys that encrypt the snapshot file. Keys are
+// 32 bytes in base64 or hex; without one the snapshot is plaintext.
+type StorageEncryption struct {
+	// Key is the key snapshots are written with. KeyFile reads it from a
+	// file instead, such as a secret a KMS or vault agent mounts.
+	Key     string `yaml:"key"`
+	KeyFile string `yaml:"key_file"`
+
+	// PreviousKeys still decrypt a snapshot written before a rotation; it
+	// is rewritten with Key on startup
+	PreviousKeys []string `yaml:"previous_keys"`
+}
+
+// Enabled reports whether a key is configured
+func (e StorageEncryption) Enabled() bool {
+	return e.Key != "" || e.KeyFile != ""
+}
+
+// Keyring reads the keys, from KeyFile if set
+func (e StorageEncryption) Keyring() (*encryption.Keyring, error) {
+	primary := e.Key
+	if e.KeyFile != "" {
+		data, err := os.ReadFile(e.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading encryption key: %w", err)
+		}
+		primary = string(data)
+	}
+	key, err := encryption.ParseKey(primary)
+	if err != nil {
+		return nil, err
+	}
+	var previous [][]byte
+	for i, s := range e.PreviousKeys {
+		k, err := encryption.ParseKey(s)
+		if err != nil {
+			return nil, fmt.Errorf("previous key %d: %w", i+1, err)
+		}
+		previous = append(previous, k)
+	}
+	return encryption.NewKeyring(key, previous...)
+}
+
+// Log holds logging settings
+type Log struct {
+	// Level is the minimum level logged at startup; it can be changed at
+	// PUT /admin/loglevel
+	Level slog.Level `yaml:"level"`
+
+	// File sends the server log to a file instead of stderr, rotated once
+	// it reaches MaxSizeMB or has been written to for MaxAge (0 turns
+	// either off), keeping MaxBackups older files
+	File       string        `yaml:"file"`
+	MaxSizeMB  int           `yaml:"max_size_mb"`
+	MaxAge     time.Duration `yaml:"max_age"`
+	MaxBackups int           `yaml:"max_backups"`
+
+	// Bodies logs request and response bodies, redacted and capped at
+	// BodyMaxBytes, from startup; it can also be toggled at PUT
+	// /admin/debug/bodies
+	Bodies       bool `yaml:"bodies"`
+	BodyMaxBytes int  `yaml:"body_max_bytes"`
+
+	// Redact names the fields whose values are masked in logs, body
+	// dumps and redacted exports, such as "description". Names match JSON
+	// members, query parameters and log attributes.
+	Redact []string `yaml:"redact"`
+
+	// Access selects where the record of each completed request goes
+	// and how successful requests are sampled under load
+	Access accesslog.Config `yaml:"access"`
+
+	// StartupReport is where the startup self-check report goes as one
+	// JSON document: "stdout", "stderr" or a file path, rewritten on every
+	// start. Empty turns it off.
+	StartupReport string `yaml:"startup_report"`
+}
+
+// Auth holds API key authentication settings
+type Auth struct {
+	Enabled bool `yaml:"enabled"`
+
+	// AdminKey authorizes POST /apikeys and the /admin endpoints, which
+	// are not served without it, whether or not auth is enabled
+	AdminKey string `yaml:"admin_key"`
+
+	JWT JWT `yaml:"jwt"`
+
+	// AuditFile, if set, keeps the audit log across restarts as JSON lines
+	AuditFile string `yaml:"audit_file"`
+
+	// CalendarSecret signs calendar feed tokens. When empty a random secret
+	// is used, and feed URLs stop working on restart.
+	CalendarSecret string `yaml:"calendar_secret"`
+}
+
+// JWT holds settings for accepting bearer JWTs from an identity provider
+type JWT struct {
+	// Secret verifies HMAC-signed tokens
+	Secret string `yaml:"secret"`
+
+	// JWKSURL publishes the keys verifying RSA and ECDSA-signed tokens
+	JWKSURL string `yaml:"jwks_url"`
+
+	Issuer   string `yaml:"issuer"`
+	Audience string `yaml:"audience"`
+}
+
+// Enabled reports whether JWTs are accepted
+func (j JWT) Enabled() bool {
+	return j.Secret != "" || j.JWKSURL != ""
+}
+
+// minJWTSecretLength is the key size HS256 needs to be secure
+const minJWTSecretLength = 32
+
+// minAdminKeyLength keeps the admin key out of reach of guessing
+const minAdminKeyLength = 32
+
+// minSigningSecretLength is the key size HMAC-SHA256 needs to be secure,
+// required of every secret that signs calendar tokens, download links or
+// erasure reports
+const minSigningSecretLength = 32
+
+// Demo holds public sandbox settings
+type Demo struct {
+	Enabled       bool          `yaml:"enabled"`
+	MaxTasks      int           `yaml:"max_tasks"`
+	ResetInterval time.Duration `yaml:"reset_interval"`
+}
+
+// Config converts to the demo package's settings
+func (d Demo) Config() demo.Config {
+	return demo.Config{MaxTasks: d.MaxTasks, ResetInterval: d.ResetInterval}
+}
+
+// Content holds content-processing settings
+type Content struct {
+	PolicyFile string `yaml:"policy_file"`
+}
+
+// Capture holds traffic capture settings
+type Capture struct {
+	File       string  `yaml:"file"`
+	SampleRate float64 `yaml:"sample_rate"`
+}
+
+// Config converts to the capture package's settings
+func (c Capture) Config() capture.Config {
+	cfg := capture.DefaultConfig()
+	cfg.SampleRate = c.SampleRate
+	return cfg
+}
+
+// Events holds the message broker that task changes are published to
+type Events struct {
+	// Driver is "nats" or "kafka"; empty disables publishing
+	Driver string `yaml:"driver"`
+
+	// URL is the NATS server (nats://) or the Kafka REST Proxy (http(s)://)
+	URL string `yaml:"url"`
+
+	// Topic is the NATS subject prefix or the Kafka topic
+	Topic string `yaml:"topic"`
+
+	// Source is the CloudEvents source attribute of every event
+	Source string `yaml:"source"`
+}
+
+// Enabled reports whether task changes are published
+func (e Events) Enabled() bool {
+	return e.Driver != ""
+}
+
+// Panics holds the error tracker recovered handler panics are reported
+// to; they are always logged
+type Panics struct {
+	// SentryDSN enables reporting to Sentry or a compatible service
+	SentryDSN string `yaml:"sentry_dsn"`
+
+	// Environment tags reported panics, e.g. "production"
+	Environment string `yaml:"environment"`
+}
+
+// Enabled reports whether panics are sent to an error tracker
+func (p Panics) Enabled() bool {
+	return p.SentryDSN != ""
+}
+
+// Cleanup holds the retention policies the background janitor deletes
+// old tasks by
+type Cleanup struct {
+	// Interval is how often the policies run
+	Interval time.Duration `yaml:"interval"`
+
+	// Policies select the tasks to delete; without any, nothing is
+	Policies []cleanup.Policy `yaml:"policies"`
+}
+
+// Enabled reports whether the janitor has anything to delete
+func (c Cleanup) Enabled() bool {
+	return len(c.Policies) > 0
+}
+
+// Backup holds where POST /admin/backup writes archives and POST
+// /admin/restore reads them
+type Backup struct {
+	// Destination is a store URL: file:///var/backups/tasks or
+	// s3://bucket/prefix. Empty disables backups.
+	Destination string `yaml:"destination"`
+}
+
+// Enabled reports whether backups have somewhere to go
+func (b Backup) Enabled() bool {
+	return b.Destination != ""
+}
+
+// Blob holds where finished exports are kept and how their download links
+// are signed
+type Blob struct {
+	// URL is a store URL: file:///var/lib/tasks/exports or
+	// s3://bucket/exports. Empty keeps exports in jobs.dir.
+	URL string `yaml:"url"`
+
+	// SigningSecret signs download links to a directory store; S3 signs
+	// its own. It must be at least 32 characters. When empty a random
+	// secret is used, so links stop working on restart.
+	SigningSecret string `yaml:"signing_secret"`
+
+	// LinkTTL is how long a download link works
+	LinkTTL time.Duration `yaml:"link_ttl"`
+}
+
+// Erasure holds how user data erasure reports are signed
+type Erasure struct {
+	// SigningSecret signs erasure reports with HMAC-SHA256 and must be at
+	// least 32 characters. When empty, erasure is not served.
+	SigningSecret string `yaml:"signing_secret"`
+}
+
+// Webhooks holds where webhook deliveries may connect
+type Webhooks struct {
+	// AllowedNetworks are non-public networks deliveries may still reach,
+	// such as an internal receiver's. Loopback, private, link-local and
+	// unspecified addresses are refused otherwise.
+	AllowedNetworks []netip.Prefix `yaml:"allowed_networks"`
+}
+
+// Notifications holds the chat connectors task events are posted to
+type Notifications struct {
+	// OverdueInterval is how often tasks are checked for having fallen
+	// due
+	OverdueInterval time.Duration `yaml:"overdue_interval"`
+
+	// Connectors are the Slack and Teams webhooks messages go to; without
+	// any, nothing is posted
+	Connectors []chat.Connector `yaml:"connectors"`
+}
+
+// Enabled reports whether any connector is configured
+func (n Notifications) Enabled() bool {
+	return len(n.Connectors) > 0
+}
+
+// Jobs holds background job queue settings
+type Jobs struct {
+	// Workers is the number of jobs run concurrently
+	Workers int `yaml:"workers"`
+
+	// MaxAttempts is how often a failing job is tried
+	MaxAttempts int `yaml:"max_attempts"`
+
+	// File persists job records across restarts; empty keeps them in
+	// memory
+	File string `yaml:"file"`
+
+	// Dir holds uploads waiting to be imported and finished exports;
+	// empty uses a temporary directory removed on shutdown
+	Dir string `yaml:"dir"`
+}
+
+// Config converts to the jobs package's settings
+func (j Jobs) Config() jobs.Config {
+	cfg := jobs.DefaultConfig()
+	cfg.Workers = j.Workers
+	cfg.MaxAttempts = j.MaxAttempts
+	return cfg
+}
+
+// Error is a configuration problem with a hint on how to fix it
+type Error struct {
+	Setting string
+	Problem string
+	Hint    string
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("%s: %s (%s)", e.Setting, e.Problem, e.Hint)
+}
+
+// Default returns the built-in configuration. Personal mode listens on
+// localhost only, since it has no authentication.
+func Default(personal bool) *Config {
+	addr := ":8080"
+	if personal {
+		addr = "127.0.0.1:8080"
+	}
+
+	demoDefaults := demo.DefaultConfig()
+	jobsDefaults := jobs.DefaultConfig()
+	return &Config{
+		Server: Server{
+			Addr:            addr,
+			ReadTimeout:     15 * time.Second,
+			WriteTimeout:    15 * time.Second,
+			IdleTimeout:     60 * time.Second,
+			ShutdownTimeout: 10 * time.Second,
+			MaxBodyBytes:    handlers.DefaultMaxBodyBytes,
+			RequestTimeouts: RequestTimeouts{Tasks: 10 * time.Second, Admin: 10 * time.Second},
+			UndoWindow:      handlers.DefaultUndoWindow,
+			ErrorFormat:     "json",
+			CacheControl:    "private, no-cache",
+			CORS: CORS{
+				AllowedMethods: []string{"GET", "POST", "PUT", "DELETE"},
+				AllowedHeaders: []string{"Content-Type", "X-Request-ID", "If-Match", "If-None-Match", "X-Timezone"},
+				MaxAge:         10 * time.Minute,
+			},
+			Compression: Compression{
+				Enabled: true,
+				MinSize: 1024,
+				ContentTypes: []string{
+					"application/json", "application/problem+json", "application/x-ndjson",
+					"application/javascript", "image/svg+xml", "text/*",
+				},
+			},
+			RateLimit: RateLimit{Burst: 20, Store: "memory"},
+		},
+		Storage: Storage{WriteBehindMaxPending: 1000, Migrate: true},
+		Log: Log{
+			Level:         slog.LevelInfo,
+			StartupReport: "stdout",
+			MaxSizeMB:     accesslog.DefaultMaxSizeMB,
+			MaxBackups:    accesslog.DefaultMaxBackups,
+			BodyMaxBytes:  bodylog.DefaultMaxBytes,
+			Redact:        slices.Clone(redact.DefaultFields),
+			Access: accesslog.Config{
+				Sinks:      []string{accesslog.Stdout},
+				MaxSizeMB:  accesslog.DefaultMaxSizeMB,
+				MaxBackups: accesslog.DefaultMaxBackups,
+				SampleRate: 1,
+			},
+		},
+		Demo: Demo{
+			MaxTasks:      demoDefaults.MaxTasks,
+			ResetInterval: demoDefaults.ResetInterval,
+		},
+		Capture:       Capture{SampleRate: capture.DefaultConfig().SampleRate},
+		Events:        Events{Topic: "cert-tasks.events", Source: "/cert-tasks"},
+		Cleanup:       Cleanup{Interval: time.Hour},
+		Jobs:          Jobs{Workers: jobsDefaults.Workers, MaxAttempts: jobsDefaults.MaxAttempts},
+		Blob:          Blob{LinkTTL: 15 * time.Minute},
+		Notifications: Notifications{OverdueInterval: 5 * time.Minute},
+		Outbound:      outbound.DefaultBudgets(),
+		Personal:      personal,
+	}
+}
+
+// Load builds the configuration from the defaults, the YAML file at path
+// (if not empty) and the environment, in that order of precedence. Every
+// problem is collected instead of stopping at the first one; the returned
+// configuration is usable for diagnostics even when there are errors.
+func Load(path string, personal bool) (*Config, []error) {
+	cfg := Default(personal)
+	var errs []error
+
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	errs = append(errs, cfg.applyEnv()...)
+	errs = append(errs, cfg.Validate()...)
+	return cfg, errs
+}
+
+// loadFile overlays the YAML file at path onto cfg
+func (cfg *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return Error{"config file", err.Error(), "check the --config flag or CONFIG_FILE"}
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return Error{"config file " + path, err.Error(), "see config.example.yaml for the format"}
+	}
+	return nil
+}
+
+// applyEnv overlays environment variables onto cfg
+func (cfg *Config) applyEnv() []error {
+	var errs []error
+	invalid := func(setting, problem, hint string) {
+		errs = append(errs, Error{setting, problem, hint})
+	}
+
+	if port := os.Getenv("PORT"); port != "" {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			invalid("PORT", fmt.Sprintf("%q is not a valid port", port), "use a number between 1 and 65535")
+		}
+		cfg.Server.Addr = ":" + port
+	}
+
+	durations := []struct {
+		env string
+		dst *time.Duration
+	}{
+		{"SERVER_READ_TIMEOUT", &cfg.Server.ReadTimeout},
+		{"SERVER_WRITE_TIMEOUT", &cfg.Server.WriteTimeout},
+		{"SERVER_IDLE_TIMEOUT", &cfg.Server.IdleTimeout},
+		{"SERVER_SHUTDOWN_TIMEOUT", &cfg.Server.ShutdownTimeout},
+		{"SERVER_REQUEST_TIMEOUT", &cfg.Server.RequestTimeouts.Tasks},
+		{"SERVER_BULK_REQUEST_TIMEOUT", &cfg.Server.RequestTimeouts.Bulk},
+		{"SERVER_ADMIN_REQUEST_TIMEOUT", &cfg.Server.RequestTimeouts.Admin},
+		{"SERVER_UNDO_WINDOW", &cfg.Server.UndoWindow},
+		{"DEMO_RESET_INTERVAL", &cfg.Demo.ResetInterval},
+		{"LOG_MAX_AGE", &cfg.Log.MaxAge},
+		{"STORAGE_WRITE_BEHIND", &cfg.Storage.WriteBehind},
+		{"OUTBOUND_WEBHOOK_TIMEOUT", &cfg.Outbound.Webhook},
+		{"OUTBOUND_NOTIFIER_TIMEOUT", &cfg.Outbound.Notifier},
+		{"OUTBOUND_BLOB_TIMEOUT", &cfg.Outbound.Blob},
+		{"OUTBOUND_JWKS_TIMEOUT", &cfg.Outbound.JWKS},
+		{"CLEANUP_INTERVAL", &cfg.Cleanup.Interval},
+		{"BLOB_LINK_TTL", &cfg.Blob.LinkTTL},
+		{"NOTIFICATIONS_OVERDUE_INTERVAL", &cfg.Notifications.OverdueInterval},
+	}
+	for _, d := range durations {
+		if v := os.Getenv(d.env); v != "" {
+			parsed, err := time.ParseDuration(v)
+			if err != nil {
+				invalid(d.env, fmt.Sprintf("%q is not a duration", v), "e.g. "+d.env+"=30s")
+				continue
+			}
+			*d.dst = parsed
+		}
+	}
+
+	if v := os.Getenv("TRUSTED_PROXIES"); v != "" {
+		cfg.Server.TrustedProxies = nil
+		for _, entry := range strings.Split(v, ",") {
+			prefix, err := parsePrefix(strings.TrimSpace(entry))
+			if err != nil {
+				invalid("TRUSTED_PROXIES", fmt.Sprintf("%q is not an IP address or CIDR", entry), "e.g. TRUSTED_PROXIES=10.0.0.0/8,127.0.0.1")
+				continue
+			}
+			cfg.Server.TrustedProxies = append(cfg.Server.TrustedProxies, prefix)
+		}
+	}
+
+	if v := os.Getenv("WEBHOOK_ALLOWED_NETWORKS"); v != "" {
+		cfg.Webhooks.AllowedNetworks = nil
+		for _, entry := range strings.Split(v, ",") {
+			prefix, err := parsePrefix(strings.TrimSpace(entry))
+			if err != nil {
+				invalid("WEBHOOK_ALLOWED_NETWORKS", fmt.Sprintf("%q is not an IP address or CIDR", entry), "e.g. WEBHOOK_ALLOWED_NETWORKS=10.20.0.0/16")
+				continue
+			}
+			cfg.Webhooks.AllowedNetworks = append(cfg.Webhooks.AllowedNetworks, prefix)
+		}
+	}
+
+	if v := os.Getenv("CORS_ALLOWED_ORIGINS"); v != "" {
+		cfg.Server.CORS.AllowedOrigins = splitList(v)
+	}
+	if v := os.Getenv("STORAGE_ENCRYPTION_PREVIOUS_KEYS"); v != "" {
+		cfg.Storage.Encryption.PreviousKeys = splitList(v)
+	}
+	if v := os.Getenv("COMPRESSION_CONTENT_TYPES"); v != "" {
+		cfg.Server.Compression.ContentTypes = splitList(v)
+	}
+	if v := os.Getenv("TLS_AUTOCERT_DOMAINS"); v != "" {
+		cfg.Server.TLS.AutocertDomains = splitList(v)
+	}
+	if v := os.Getenv("LOG_ACCESS_SINKS"); v != "" {
+		cfg.Log.Access.Sinks = splitList(v)
+	}
+	if v := os.Getenv("PLUGINS"); v != "" {
+		// Plugins enabled in the file keep their config sections
+		configured := cfg.Plugins
+		cfg.Plugins = nil
+		for _, name := range splitList(v) {
+			spec := plugin.Spec{Name: name}
+			if i := slices.IndexFunc(configured, func(p plugin.Spec) bool { return p.Name == name }); i >= 0 {
+				spec = configured[i]
+			}
+			cfg.Plugins = append(cfg.Plugins, spec)
+		}
+	}
+
+	values := []struct {
+		env string
+		dst *string
+	}{
+		{"ADMIN_ADDR", &cfg.Server.AdminAddr},
+		{"ERROR_FORMAT", &cfg.Server.ErrorFormat},
+		{"TRANSLATIONS_DIR", &cfg.Server.TranslationsDir},
+		{"CACHE_CONTROL", &cfg.Server.CacheControl},
+		{"READ_ONLY_MESSAGE", &cfg.Server.ReadOnlyMessage},
+		{"TLS_CERT_FILE", &cfg.Server.TLS.CertFile},
+		{"TLS_KEY_FILE", &cfg.Server.TLS.KeyFile},
+		{"TLS_AUTOCERT_CACHE_DIR", &cfg.Server.TLS.AutocertCacheDir},
+		{"TLS_AUTOCERT_EMAIL", &cfg.Server.TLS.AutocertEmail},
+		{"TLS_REDIRECT_ADDR", &cfg.Server.TLS.RedirectAddr},
+		{"RATE_LIMIT_STORE", &cfg.Server.RateLimit.Store},
+		{"STORAGE_DSN", &cfg.Storage.DSN},
+		{"STORAGE_ID_FORMAT", &cfg.Storage.IDFormat},
+		{"STORAGE_ENCRYPTION_KEY", &cfg.Storage.Encryption.Key},
+		{"STORAGE_ENCRYPTION_KEY_FILE", &cfg.Storage.Encryption.KeyFile},
+		{"AUTH_ADMIN_KEY", &cfg.Auth.AdminKey},
+		{"AUTH_JWT_SECRET", &cfg.Auth.JWT.Secret},
+		{"AUTH_JWT_JWKS_URL", &cfg.Auth.JWT.JWKSURL},
+		{"AUTH_JWT_ISSUER", &cfg.Auth.JWT.Issuer},
+		{"AUTH_JWT_AUDIENCE", &cfg.Auth.JWT.Audience},
+		{"AUTH_AUDIT_FILE", &cfg.Auth.AuditFile},
+		{"AUTH_CALENDAR_SECRET", &cfg.Auth.CalendarSecret},
+		{"CONTENT_POLICY_FILE", &cfg.Content.PolicyFile},
+		{"CAPTURE_EXAMPLES_FILE", &cfg.Capture.File},
+		{"JOBS_FILE", &cfg.Jobs.File},
+		{"JOBS_DIR", &cfg.Jobs.Dir},
+		{"BACKUP_DESTINATION", &cfg.Backup.Destination},
+		{"BLOB_URL", &cfg.Blob.URL},
+		{"BLOB_SIGNING_SECRET", &cfg.Blob.SigningSecret},
+		{"ERASURE_SIGNING_SECRET", &cfg.Erasure.SigningSecret},
+		{"LOG_FILE", &cfg.Log.File},
+		{"LOG_STARTUP_REPORT", &cfg.Log.StartupReport},
+		{"LOG_ACCESS_FILE", &cfg.Log.Access.File},
+		{"LOG_ACCESS_SYSLOG_ADDR", &cfg.Log.Access.SyslogAddr},
+		{"EVENTS_DRIVER", &cfg.Events.Driver},
+		{"EVENTS_URL", &cfg.Events.URL},
+		{"EVENTS_TOPIC", &cfg.Events.Topic},
+		{"EVENTS_SOURCE", &cfg.Events.Source},
+		{"SENTRY_DSN", &cfg.Panics.SentryDSN},
+		{"SENTRY_ENVIRONMENT", &cfg.Panics.Environment},
+	}
+	for _, s := range values {
+		if v := os.Getenv(s.env); v != "" {
+			*s.dst = v
+		}
+	}
+
+	if v := os.Getenv("CLEANUP_POLICIES"); v != "" {
+		policies, err := cleanup.ParsePolicies(v)
+		if err != nil {
+			invalid("CLEANUP_POLICIES", err.Error(), "e.g. CLEANUP_POLICIES=done:2160h to delete tasks done for 90 days")
+		} else {
+			cfg.Cleanup.Policies = policies
+		}
+	}
+
+	// A webhook URL alone adds a connector with the default events and
+	// messages; the config file can add more
+	for _, c := range []struct{ env, kind string }{
+		{"SLACK_WEBHOOK_URL", chat.Slack},
+		{"TEAMS_WEBHOOK_URL", chat.Teams},
+	} {
+		if v := os.Getenv(c.env); v != "" {
+			cfg.Notifications.Connectors = append(cfg.Notifications.Connectors, chat.Connector{Name: c.kind, Kind: c.kind, URL: v})
+		}
+	}
+
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		if err := cfg.Log.Level.UnmarshalText([]byte(v)); err != nil {
+			invalid("LOG_LEVEL", fmt.Sprintf("unknown level %q", v), `use "debug", "info", "warn" or "error"`)
+		}
+	}
+
+	if v := os.Getenv("LOG_BODIES"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			invalid("LOG_BODIES", fmt.Sprintf("%q is not a boolean", v), `use "true" or "false"`)
+		} else {
+			cfg.Log.Bodies = enabled
+		}
+	}
+
+	if v := os.Getenv("LOG_REDACT"); v != "" {
+		cfg.Log.Redact = nil
+		if v != "none" {
+			cfg.Log.Redact = splitList(v)
+		}
+	}
+
+	if v := os.Getenv("LOG_BODY_MAX_BYTES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			invalid("LOG_BODY_MAX_BYTES", fmt.Sprintf("%q is not a positive integer", v), "e.g. LOG_BODY_MAX_BYTES=4096")
+		} else {
+			cfg.Log.BodyMaxBytes = n
+		}
+	}
+
+	for _, n := range []struct {
+		env string
+		dst *int
+	}{
+		{"LOG_MAX_SIZE_MB", &cfg.Log.MaxSizeMB},
+		{"LOG_MAX_BACKUPS", &cfg.Log.MaxBackups},
+		{"LOG_ACCESS_MAX_SIZE_MB", &cfg.Log.Access.MaxSizeMB},
+		{"LOG_ACCESS_MAX_BACKUPS", &cfg.Log.Access.MaxBackups},
+		{"LOG_ACCESS_SAMPLE_AFTER", &cfg.Log.Access.SampleAfter},
+	} {
+		if v := os.Getenv(n.env); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil {
+				invalid(n.env, fmt.Sprintf("%q is not an integer", v), "e.g. "+n.env+"=10")
+				continue
+			}
+			*n.dst = parsed
+		}
+	}
+
+	if v := os.Getenv("LOG_ACCESS_SAMPLE_RATE"); v != "" {
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			invalid("LOG_ACCESS_SAMPLE_RATE", fmt.Sprintf("%q is not a fraction", v), "use a number in [0, 1], e.g. LOG_ACCESS_SAMPLE_RATE=0.1")
+		} else {
+			cfg.Log.Access.SampleRate = rate
+		}
+	}
+
+	if v := os.Getenv("DOCS_ENABLED"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			invalid("DOCS_ENABLED", fmt.Sprintf("%q is not a boolean", v), `use "true" or "false"`)
+		} else {
+			cfg.Server.Docs = enabled
+		}
+	}
+
+	if v := os.Getenv("READ_ONLY"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			invalid("READ_ONLY", fmt.Sprintf("%q is not a boolean", v), `use "true" or "false"`)
+		} else {
+			cfg.Server.ReadOnly = enabled
+		}
+	}
+
+	if v := os.Getenv("COMPRESSION_ENABLED"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			invalid("COMPRESSION_ENABLED", fmt.Sprintf("%q is not a boolean", v), `use "true" or "false"`)
+		} else {
+			cfg.Server.Compression.Enabled = enabled
+		}
+	}
+
+	if v := os.Getenv("COMPRESSION_MIN_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			invalid("COMPRESSION_MIN_SIZE", fmt.Sprintf("%q is not a non-negative integer", v), "e.g. COMPRESSION_MIN_SIZE=1024")
+		} else {
+			cfg.Server.Compression.MinSize = n
+		}
+	}
+
+	if v := os.Getenv("AUTH_ENABLED"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			invalid("AUTH_ENABLED", fmt.Sprintf("%q is not a boolean", v), `use "true" or "false"`)
+		} else {
+			cfg.Auth.Enabled = enabled
+		}
+	}
+
+	if v := os.Getenv("DEMO_MODE"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			invalid("DEMO_MODE", fmt.Sprintf("%q is not a boolean", v), `use "true" or "false"`)
+		} else {
+			cfg.Demo.Enabled = enabled
+		}
+	}
+
+	if v := os.Getenv("DEMO_MAX_TASKS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			invalid("DEMO_MAX_TASKS", fmt.Sprintf("%q is not a positive integer", v), "e.g. DEMO_MAX_TASKS=100")
+		} else {
+			cfg.Demo.MaxTasks = n
+		}
+	}
+
+	if v := os.Getenv("STORAGE_NODE_ID"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			invalid("STORAGE_NODE_ID", fmt.Sprintf("%q is not an integer", v), fmt.Sprintf("use a number from 0 to %d unique to this replica", ids.MaxNode))
+		} else {
+			cfg.Storage.NodeID = &n
+		}
+	}
+
+	if v := os.Getenv("STORAGE_WRITE_BEHIND_MAX_PENDING"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			invalid("STORAGE_WRITE_BEHIND_MAX_PENDING", fmt.Sprintf("%q is not a positive integer", v), "e.g. STORAGE_WRITE_BEHIND_MAX_PENDING=1000")
+		} else {
+			cfg.Storage.WriteBehindMaxPending = n
+		}
+	}
+
+	if v := os.Getenv("STORAGE_MIGRATE"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			invalid("STORAGE_MIGRATE", fmt.Sprintf("%q is not a boolean", v), `use "true" or "false"`)
+		} else {
+			cfg.Storage.Migrate = enabled
+		}
+	}
+
+	if v := os.Getenv("RATE_LIMIT_RPS"); v != "" {
+		rps, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			invalid("RATE_LIMIT_RPS", fmt.Sprintf("%q is not a number", v), "e.g. RATE_LIMIT_RPS=10")
+		} else {
+			cfg.Server.RateLimit.RPS = rps
+		}
+	}
+
+	if v := os.Getenv("RATE_LIMIT_BURST"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			invalid("RATE_LIMIT_BURST", fmt.Sprintf("%q is not a positive integer", v), "e.g. RATE_LIMIT_BURST=20")
+		} else {
+			cfg.Server.RateLimit.Burst = n
+		}
+	}
+
+	if v := os.Getenv("MAX_BODY_BYTES"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			invalid("MAX_BODY_BYTES", fmt.Sprintf("%q is not a positive integer", v), "e.g. MAX_BODY_BYTES=1048576")
+		} else {
+			cfg.Server.MaxBodyBytes = n
+		}
+	}
+
+	if v := os.Getenv("JOBS_WORKERS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			invalid("JOBS_WORKERS", fmt.Sprintf("%q is not a positive integer", v), "e.g. JOBS_WORKERS=4")
+		} else {
+			cfg.Jobs.Workers = n
+		}
+	}
+
+	if v := os.Getenv("JOBS_MAX_ATTEMPTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			invalid("JOBS_MAX_ATTEMPTS", fmt.Sprintf("%q is not a positive integer", v), "e.g. JOBS_MAX_ATTEMPTS=5")
+		} else {
+			cfg.Jobs.MaxAttempts = n
+		}
+	}
+
+	if v := os.Getenv("CAPTURE_SAMPLE_RATE"); v != "" {
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			invalid("CAPTURE_SAMPLE_RATE", fmt.Sprintf("%q is not a fraction", v), "use a number in (0, 1], e.g. CAPTURE_SAMPLE_RATE=0.05")
+		} else {
+			cfg.Capture.SampleRate = rate
+		}
+	}
+
+	return errs
+}
+
+// Validate checks the merged configuration
+func (cfg *Config) Validate() []error {
+	var errs []error
+	invalid := func(setting, problem, hint string) {
+		errs = append(errs, Error{setting, problem, hint})
+	}
+
+	if _, port, err := net.SplitHostPort(cfg.Server.Addr); err != nil || port == "" {
+		invalid("server.addr", fmt.Sprintf("%q is not a host:port address", cfg.Server.Addr), "e.g. :8080 or 127.0.0.1:8080")
+	}
+	if cfg.Server.AdminAddr != "" {
+		if _, port, err := net.SplitHostPort(cfg.Server.AdminAddr); err != nil || port == "" {
+			invalid("server.admin_addr", fmt.Sprintf("%q is not a host:port address", cfg.Server.AdminAddr), "e.g. ADMIN_ADDR=127.0.0.1:6060")
+		}
+	}
+
+	tls := cfg.Server.TLS
+	switch {
+	case (tls.CertFile == "") != (tls.KeyFile == ""):
+		invalid("server.tls", "cert_file and key_file must be set together", "set both TLS_CERT_FILE and TLS_KEY_FILE")
+	case tls.CertFile != "" && len(tls.AutocertDomains) > 0:
+		invalid("server.tls", "certificate files and autocert domains are mutually exclusive", "use either TLS_CERT_FILE/TLS_KEY_FILE or TLS_AUTOCERT_DOMAINS")
+	case len(tls.AutocertDomains) > 0 && tls.AutocertCacheDir == "":
+		invalid("server.tls.autocert_cache_dir", "autocert needs a cache directory", "e.g. TLS_AUTOCERT_CACHE_DIR=/var/lib/cert-tasks/autocert")
+	}
+	if tls.RedirectAddr != "" {
+		if !tls.Enabled() {
+			invalid("server.tls.redirect_addr", "redirecting to HTTPS requires TLS", "configure a certificate or unset TLS_REDIRECT_ADDR")
+		} else if _, port, err := net.SplitHostPort(tls.RedirectAddr); err != nil || port == "" {
+			invalid("server.tls.redirect_addr", fmt.Sprintf("%q is not a host:port address", tls.RedirectAddr), "e.g. TLS_REDIRECT_ADDR=:80")
+		}
+	}
+
+	timeouts := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"server.read_timeout", cfg.Server.ReadTimeout},
+		{"server.write_timeout", cfg.Server.WriteTimeout},
+		{"server.idle_timeout", cfg.Server.IdleTimeout},
+		{"server.shutdown_timeout", cfg.Server.ShutdownTimeout},
+		{"outbound.webhook", cfg.Outbound.Webhook},
+		{"outbound.notifier", cfg.Outbound.Notifier},
+		{"outbound.blob", cfg.Outbound.Blob},
+		{"outbound.jwks", cfg.Outbound.JWKS},
+	}
+	for _, t := range timeouts {
+		if t.d <= 0 {
+			invalid(t.name, fmt.Sprintf("%s is not a positive duration", t.d), "e.g. 15s")
+		}
+	}
+	requestTimeouts := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"server.request_timeouts.tasks", cfg.Server.RequestTimeouts.Tasks},
+		{"server.request_timeouts.bulk", cfg.Server.RequestTimeouts.Bulk},
+		{"server.request_timeouts.admin", cfg.Server.RequestTimeouts.Admin},
+	}
+	for _, t := range requestTimeouts {
+		if t.d < 0 {
+			invalid(t.name, fmt.Sprintf("%s is negative", t.d), "use a positive duration, or 0 for none")
+		}
+	}
+
+	if cfg.Server.UndoWindow < 0 {
+		invalid("server.undo_window", fmt.Sprintf("%s is negative", cfg.Server.UndoWindow), "use a positive duration, or 0 to turn undo off")
+	}
+
+	switch cfg.Server.ErrorFormat {
+	case "json", "problem+json":
+	default:
+		invalid("server.error_format", fmt.Sprintf("unknown format %q", cfg.Server.ErrorFormat), `use "json" or "problem+json"`)
+	}
+
+	for _, origin := range cfg.Server.CORS.AllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			invalid("server.cors.allowed_origins", fmt.Sprintf("%q is not an origin", origin), `use "*" or scheme://host[:port], e.g. https://app.example.com`)
+		}
+	}
+
+	if c := cfg.Server.Compression; c.Enabled {
+		if c.MinSize < 0 {
+			invalid("server.compression.min_size", fmt.Sprintf("%d is negative", c.MinSize), "e.g. COMPRESSION_MIN_SIZE=1024")
+		}
+		for _, ct := range c.ContentTypes {
+			if _, _, err := mime.ParseMediaType(ct); err != nil || !strings.Contains(ct, "/") {
+				invalid("server.compression.content_types", fmt.Sprintf("%q is not a media type", ct), `e.g. "application/json" or "text/*"`)
+			}
+		}
+	}
+
+	if n := utf8.RuneCountInString(cfg.Server.ReadOnlyMessage); n > maintenance.MaxMessageLength {
+		invalid("server.read_only_message", fmt.Sprintf("%d characters is too long", n), fmt.Sprintf("keep it to %d characters", maintenance.MaxMessageLength))
+	}
+
+	if cfg.Log.BodyMaxBytes < 1 {
+		invalid("log.body_max_bytes", fmt.Sprintf("%d is not a positive integer", cfg.Log.BodyMaxBytes), "e.g. LOG_BODY_MAX_BYTES=4096")
+	}
+
+	for i, field := range cfg.Log.Redact {
+		if !redactField.MatchString(field) {
+			invalid(fmt.Sprintf("log.redact[%d]", i), fmt.Sprintf("%q is not a field name", field), "e.g. LOG_REDACT=description,title")
+		}
+	}
+
+	if cfg.Log.MaxSizeMB < 0 {
+		invalid("log.max_size_mb", fmt.Sprintf("%d is negative", cfg.Log.MaxSizeMB), "use 0 to turn size rotation off, e.g. LOG_MAX_SIZE_MB=100")
+	}
+	if cfg.Log.MaxAge < 0 {
+		invalid("log.max_age", fmt.Sprintf("%s is negative", cfg.Log.MaxAge), "use 0 to turn age rotation off, e.g. LOG_MAX_AGE=24h")
+	}
+	if cfg.Log.MaxBackups < 0 {
+		invalid("log.max_backups", fmt.Sprintf("%d is negative", cfg.Log.MaxBackups), "e.g. LOG_MAX_BACKUPS=5")
+	}
+
+	if err := cfg.Log.Access.Validate(); err != nil {
+		invalid("log.access", err.Error(), "e.g. LOG_ACCESS_SINKS=stdout,file LOG_ACCESS_FILE=/var/log/cert-tasks/access.log")
+	}
+
+	if cfg.Server.MaxBodyBytes < 1 {
+		invalid("server.max_body_bytes", fmt.Sprintf("%d is not a positive integer", cfg.Server.MaxBodyBytes), "e.g. MAX_BODY_BYTES=1048576")
+	}
+
+	if rl := cfg.Server.RateLimit; rl.RPS != 0 {
+		if rl.RPS < 0 {
+			invalid("server.rate_limit.rps", fmt.Sprintf("%g is negative", rl.RPS), "use 0 to disable rate limiting")
+		}
+		if rl.Burst < 1 {
+			invalid("server.rate_limit.burst", fmt.Sprintf("%d is not a positive integer", rl.Burst), "e.g. RATE_LIMIT_BURST=20")
+		}
+		if u, err := url.Parse(rl.Store); rl.Store != "memory" && (err != nil || (u.Scheme != "redis" && u.Scheme != "rediss")) {
+			invalid("server.rate_limit.store", fmt.Sprintf("unsupported store %q", rl.Store), `use "memory" or a redis:// URL`)
+		}
+	}
+
+	if (cfg.Auth.Enabled || cfg.Auth.AdminKey != "") && len(cfg.Auth.AdminKey) < minAdminKeyLength {
+		invalid("auth.admin_key", fmt.Sprintf("must be at least %d characters", minAdminKeyLength), "generate one with: openssl rand -hex 32")
+	}
+	if jwt := cfg.Auth.JWT; jwt.Enabled() {
+		if !cfg.Auth.Enabled {
+			invalid("auth.jwt", "JWT settings have no effect while auth is disabled", "set AUTH_ENABLED=true")
+		}
+		if jwt.Secret != "" && len(jwt.Secret) < minJWTSecretLength {
+			invalid("auth.jwt.secret", fmt.Sprintf("must be at least %d characters", minJWTSecretLength), "use the full signing secret of the identity provider")
+		}
+		if jwt.JWKSURL != "" {
+			if u, err := url.Parse(jwt.JWKSURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+				invalid("auth.jwt.jwks_url", fmt.Sprintf("%q is not an http(s) URL", jwt.JWKSURL), "e.g. AUTH_JWT_JWKS_URL=https://login.example.com/.well-known/jwks.json")
+			}
+		}
+	}
+
+	if cfg.Auth.AuditFile != "" && !cfg.Auth.Enabled {
+		invalid("auth.audit_file", "the audit log records authenticated requests only", "set AUTH_ENABLED=true")
+	}
+
+	if s := cfg.Auth.CalendarSecret; s != "" && len(s) < minSigningSecretLength {
+		invalid("auth.calendar_secret", fmt.Sprintf("must be at least %d characters", minSigningSecretLength), "generate one with: openssl rand -hex 32")
+	}
+
+	if _, _, err := cfg.Storage.Backend(); err != nil {
+		invalid("storage.dsn", err.Error(), `use "memory://" or "file:///path/to/tasks.json"`)
+	}
+	if _, err := ids.ParseFormat(cfg.Storage.IDFormat); err != nil {
+		invalid("storage.id_format", err.Error(), `use "sequential", "ulid" or "uuidv7"`)
+	}
+	if n := cfg.Storage.NodeID; n != nil && (*n < 0 || *n > ids.MaxNode) {
+		invalid("storage.node_id", fmt.Sprintf("%d is outside 0-%d", *n, ids.MaxNode), "give each replica its own number, e.g. STORAGE_NODE_ID=1")
+	}
+	if cfg.Storage.WriteBehind < 0 {
+		invalid("storage.write_behind", fmt.Sprintf("%s is negative", cfg.Storage.WriteBehind), "use 0 to save on every write, or e.g. STORAGE_WRITE_BEHIND=50ms")
+	}
+	if cfg.Storage.WriteBehindMaxPending < 1 {
+		invalid("storage.write_behind_max_pending", fmt.Sprintf("%d is not a positive integer", cfg.Storage.WriteBehindMaxPending), "e.g. STORAGE_WRITE_BEHIND_MAX_PENDING=1000")
+	}
+	if enc := cfg.Storage.Encryption; enc.Enabled() || len(enc.PreviousKeys) > 0 {
+		backend, _, _ := cfg.Storage.Backend()
+		switch {
+		case backend != BackendFile && !cfg.Personal:
+			invalid("storage.encryption", "only file storage is written to disk", `use a "file://" storage.dsn, or remove the key`)
+		case enc.Key != "" && enc.KeyFile != "":
+			invalid("storage.encryption", "key and key_file are mutually exclusive", "set STORAGE_ENCRYPTION_KEY or STORAGE_ENCRYPTION_KEY_FILE")
+		case !enc.Enabled():
+			invalid("storage.encryption.previous_keys", "previous keys need a key to rotate to", "set STORAGE_ENCRYPTION_KEY")
+		}
+		if enc.Key != "" {
+			if _, err := encryption.ParseKey(enc.Key); err != nil {
+				invalid("storage.encryption.key", err.Error(), "generate one with: openssl rand -base64 32")
+			}
+		}
+		for i, k := range enc.PreviousKeys {
+			if _, err := encryption.ParseKey(k); err != nil {
+				invalid(fmt.Sprintf("storage.encryption.previous_keys[%d]", i), err.Error(), "list the keys exactly as they were configured")
+			}
+		}
+	}
+
+	// Demo resets wipe the memory store; file storage would keep the
+	// snapshot and bypass the task limit
+	if backend, _, err := cfg.Storage.Backend(); cfg.Demo.Enabled && (err == nil && backend != BackendMemory || cfg.Personal) {
+		invalid("demo.enabled", "demo mode requires memory storage", "unset STORAGE_DSN or set it to memory://")
+	}
+	if cfg.Demo.MaxTasks < 1 {
+		invalid("demo.max_tasks", fmt.Sprintf("%d is not a positive integer", cfg.Demo.MaxTasks), "e.g. DEMO_MAX_TASKS=100")
+	}
+	if cfg.Demo.ResetInterval <= 0 {
+		invalid("demo.reset_interval", fmt.Sprintf("%s is not a positive duration", cfg.Demo.ResetInterval), "e.g. DEMO_RESET_INTERVAL=1h")
+	}
+
+	if cfg.Capture.SampleRate <= 0 || cfg.Capture.SampleRate > 1 {
+		invalid("capture.sample_rate", fmt.Sprintf("%g is not a fraction", cfg.Capture.SampleRate), "use a number in (0, 1], e.g. CAPTURE_SAMPLE_RATE=0.05")
+	}
+
+	if ev := cfg.Events; ev.Enabled() {
+		u, err := url.Parse(ev.URL)
+		switch {
+		case ev.Driver != "nats" && ev.Driver != "kafka":
+			invalid("events.driver", fmt.Sprintf("unknown driver %q", ev.Driver), `use "nats" or "kafka"`)
+		case ev.Driver == "nats" && (err != nil || (u.Scheme != "nats" && u.Scheme != "tls") || u.Host == ""):
+			invalid("events.url", fmt.Sprintf("%q is not a NATS URL", ev.URL), "e.g. EVENTS_URL=nats://localhost:4222")
+		case ev.Driver == "kafka" && (err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == ""):
+			invalid("events.url", fmt.Sprintf("%q is not a Kafka REST Proxy URL", ev.URL), "e.g. EVENTS_URL=http://localhost:8082")
+		}
+		if ev.Topic == "" {
+			invalid("events.topic", "a topic is required", "e.g. EVENTS_TOPIC=cert-tasks.events")
+		}
+		if ev.Source == "" {
+			invalid("events.source", "a CloudEvents source is required", "e.g. EVENTS_SOURCE=/cert-tasks")
+		}
+	}
+
+	if c := cfg.Cleanup; c.Enabled() {
+		if c.Interval <= 0 {
+			invalid("cleanup.interval", fmt.Sprintf("%s is not a positive duration", c.Interval), "e.g. CLEANUP_INTERVAL=1h")
+		}
+		for i, p := range c.Policies {
+			if err := p.Validate(); err != nil {
+				invalid(fmt.Sprintf("cleanup.policies[%d]", i), err.Error(), "e.g. {status: done, older_than: 2160h}")
+			}
+		}
+	}
+
+	if b := cfg.Backup; b.Enabled() {
+		if _, err := blob.Parse(b.Destination); err != nil {
+			invalid("backup.destination", err.Error(), "e.g. BACKUP_DESTINATION=file:///var/backups/tasks or s3://bucket/tasks")
+		}
+	}
+
+	if b := cfg.Blob; b.URL != "" {
+		if _, err := blob.Parse(b.URL); err != nil {
+			invalid("blob.url", err.Error(), "e.g. BLOB_URL=file:///var/lib/tasks/exports or s3://bucket/exports")
+		}
+	}
+	if s := cfg.Blob.SigningSecret; s != "" && len(s) < minSigningSecretLength {
+		invalid("blob.signing_secret", fmt.Sprintf("must be at least %d characters", minSigningSecretLength), "generate one with: openssl rand -hex 32")
+	}
+	if ttl := cfg.Blob.LinkTTL; ttl <= 0 || ttl > blob.MaxURLTTL {
+		invalid("blob.link_ttl", fmt.Sprintf("%s is not between 0 and %s", ttl, blob.MaxURLTTL), "e.g. BLOB_LINK_TTL=15m")
+	}
+	if s := cfg.Erasure.SigningSecret; s != "" && len(s) < minSigningSecretLength {
+		invalid("erasure.signing_secret", fmt.Sprintf("must be at least %d characters", minSigningSecretLength), "generate one with: openssl rand -hex 32")
+	}
+
+	if n := cfg.Notifications; n.Enabled() {
+		if n.OverdueInterval <= 0 {
+			invalid("notifications.overdue_interval", fmt.Sprintf("%s is not a positive duration", n.OverdueInterval), "e.g. NOTIFICATIONS_OVERDUE_INTERVAL=5m")
+		}
+		for i, c := range n.Connectors {
+			if err := c.Validate(); err != nil {
+				invalid(fmt.Sprintf("notifications.connectors[%d]", i), err.Error(), "e.g. {kind: slack, url: https://hooks.slack.com/services/...}")
+			}
+		}
+	}
+
+	if cfg.Jobs.Workers < 1 {
+		invalid("jobs.workers", fmt.Sprintf("%d is not a positive integer", cfg.Jobs.Workers), "e.g. JOBS_WORKERS=4")
+	}
+	if cfg.Jobs.MaxAttempts < 1 {
+		invalid("jobs.max_attempts", fmt.Sprintf("%d is not a positive integer", cfg.Jobs.MaxAttempts), "e.g. JOBS_MAX_ATTEMPTS=5")
+	}
+
+	if p := cfg.Panics; p.Enabled() {
+		if _, err := recovery.NewSentry(p.SentryDSN, p.Environment, cfg.Outbound.Notifier); err != nil {
+			invalid("panics.sentry_dsn", err.Error(), "copy the DSN from the Sentry project's Client Keys settings")
+		}
+	}
+
+	registered := plugin.Registered()
+	seen := make(map[string]bool)
+	for i, p := range cfg.Plugins {
+		setting := fmt.Sprintf("plugins[%d]", i)
+		switch {
+		case p.Name == "":
+			invalid(setting, "name is empty", "name a registered plugin")
+		case seen[p.Name]:
+			invalid(setting, fmt.Sprintf("plugin %q is enabled twice", p.Name), "list each plugin once")
+		case !slices.Contains(registered, p.Name):
+			hint := "no plugins are built into this binary; import the plugin's package in cmd/api/plugins.go"
+			if len(registered) > 0 {
+				hint = "built-in plugins: " + strings.Join(registered, ", ")
+			}
+			invalid(setting, fmt.Sprintf("plugin %q is not registered", p.Name), hint)
+		}
+		seen[p.Name] = true
+	}
+
+	return errs
+}
+
+// Storage backends selected by Storage.Backend
+const (
+	BackendMemory = "memory"
+	BackendFile   = "file"
+)
+
+// Backend parses the DSN into a backend name and, for file storage, the
+// snapshot path. An empty DSN selects memory.
+func (s Storage) Backend() (backend, path string, err error) {
+	if s.DSN == "" {
+		return BackendMemory, "", nil
+	}
+	u, err := url.Parse(s.DSN)
+	if err != nil {
+		return "", "", fmt.Errorf("%q is not a valid DSN", s.DSN)
+	}
+	switch u.Scheme {
+	case BackendMemory:
+		return BackendMemory, "", nil
+	case BackendFile:
+		if u.Path == "" {
+			return "", "", fmt.Errorf("%q has no file path", s.DSN)
+		}
+		return BackendFile, u.Path, nil
+	default:
+		return "", "", fmt.Errorf("unsupported storage %q", u.Scheme)
+	}
+}
+
+// parsePrefix parses a CIDR, or a single address as a full-length prefix
+func parsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		return netip.ParsePrefix(s)
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// redactField matches the names of fields that can be redacted
+var redactField = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,507 @@
+package config
+
+import (
+	"encoding/base64"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/cleanup"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/plugin"
+)
+
+func TestLoad(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		cfg, errs := Load("", false)
+		if len(errs) != 0 {
+			t.Fatalf("Load() errors = %v", errs)
+		}
+		if cfg.Server.Addr != ":8080" {
+			t.Errorf("Addr = %q, want :8080", cfg.Server.Addr)
+		}
+	})
+
+	t.Run("personal mode listens locally", func(t *testing.T) {
+		cfg, _ := Load("", true)
+		if cfg.Server.Addr != "127.0.0.1:8080" {
+			t.Errorf("Addr = %q, want 127.0.0.1:8080", cfg.Server.Addr)
+		}
+	})
+
+	t.Run("valid overrides", func(t *testing.T) {
+		t.Setenv("PORT", "3000")
+		t.Setenv("ERROR_FORMAT", "problem+json")
+		t.Setenv("DOCS_ENABLED", "1")
+		t.Setenv("DEMO_RESET_INTERVAL", "15m")
+		t.Setenv("LOG_LEVEL", "debug")
+		t.Setenv("LOG_REDACT", "description, owner_id")
+		t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8, 127.0.0.1")
+		t.Setenv("SERVER_WRITE_TIMEOUT", "30s")
+		t.Setenv("STORAGE_DSN", "file:///var/lib/tasks.json")
+		t.Setenv("STORAGE_MIGRATE", "false")
+		t.Setenv("STORAGE_ENCRYPTION_KEY_FILE", "/run/secrets/tasks-key")
+		t.Setenv("STORAGE_ENCRYPTION_PREVIOUS_KEYS", strings.Repeat("ab", 32)+", "+strings.Repeat("cd", 32))
+		t.Setenv("CORS_ALLOWED_ORIGINS", "https://app.example.com, http://localhost:5173")
+		t.Setenv("READ_ONLY", "true")
+		t.Setenv("READ_ONLY_MESSAGE", "Migrating storage")
+		t.Setenv("CACHE_CONTROL", "no-store")
+		t.Setenv("COMPRESSION_ENABLED", "false")
+		t.Setenv("COMPRESSION_MIN_SIZE", "256")
+		t.Setenv("COMPRESSION_CONTENT_TYPES", "application/json, text/csv")
+		t.Setenv("CLEANUP_POLICIES", "done:2160h")
+		t.Setenv("JOBS_FILE", "/var/lib/jobs.json")
+		t.Setenv("JOBS_WORKERS", "8")
+		t.Setenv("JOBS_DIR", "/var/lib/jobs")
+		t.Setenv("BACKUP_DESTINATION", "s3://backups/tasks")
+		t.Setenv("BLOB_URL", "s3://exports")
+		t.Setenv("BLOB_LINK_TTL", "1h")
+		t.Setenv("ERASURE_SIGNING_SECRET", strings.Repeat("e", 32))
+		t.Setenv("WEBHOOK_ALLOWED_NETWORKS", "10.20.0.0/16, fd00::1")
+		t.Setenv("SLACK_WEBHOOK_URL", "https://hooks.slack.com/services/T0/B0/x")
+		t.Setenv("LOG_FILE", "/var/log/tasks/api.log")
+		t.Setenv("LOG_MAX_AGE", "24h")
+		t.Setenv("LOG_STARTUP_REPORT", "/run/tasks/startup.json")
+		t.Setenv("LOG_ACCESS_SINKS", "stdout, file")
+		t.Setenv("LOG_ACCESS_FILE", "/var/log/tasks/access.log")
+		t.Setenv("LOG_ACCESS_SAMPLE_RATE", "0.1")
+		t.Setenv("LOG_ACCESS_SAMPLE_AFTER", "200")
+
+		cfg, errs := Load("", false)
+		if len(errs) != 0 {
+			t.Fatalf("Load() errors = %v", errs)
+		}
+		if cfg.Server.Addr != ":3000" || cfg.Server.ErrorFormat != "problem+json" ||
+			cfg.Demo.ResetInterval != 15*time.Minute || cfg.Log.Level != slog.LevelDebug ||
+			len(cfg.Server.TrustedProxies) != 2 || cfg.Server.WriteTimeout != 30*time.Second ||
+			len(cfg.Server.CORS.AllowedOrigins) != 2 || !cfg.Server.Docs ||
+			!cfg.Server.ReadOnly || cfg.Server.ReadOnlyMessage != "Migrating storage" ||
+			cfg.Server.CacheControl != "no-store" || cfg.Server.Compression.Enabled || cfg.Server.Compression.MinSize != 256 || len(cfg.Server.Compression.ContentTypes) != 2 {
+			t.Errorf("config = %+v", cfg)
+		}
+		if backend, path, _ := cfg.Storage.Backend(); backend != BackendFile || path != "/var/lib/tasks.json" || cfg.Storage.Migrate {
+			t.Errorf("Backend() = %q, %q", backend, path)
+		}
+		if enc := cfg.Storage.Encryption; enc.KeyFile != "/run/secrets/tasks-key" || len(enc.PreviousKeys) != 2 {
+			t.Errorf("Encryption = %+v", enc)
+		}
+		if !cfg.Cleanup.Enabled() || cfg.Cleanup.Policies[0].OlderThan != 2160*time.Hour {
+			t.Errorf("Cleanup = %+v", cfg.Cleanup)
+		}
+		if jobs := cfg.Jobs; jobs.File != "/var/lib/jobs.json" || jobs.Dir != "/var/lib/jobs" || jobs.Config().Workers != 8 {
+			t.Errorf("Jobs = %+v", jobs)
+		}
+		if !cfg.Backup.Enabled() || cfg.Backup.Destination != "s3://backups/tasks" {
+			t.Errorf("Backup = %+v", cfg.Backup)
+		}
+		if b := cfg.Blob; b.URL != "s3://exports" || b.LinkTTL != time.Hour {
+			t.Errorf("Blob = %+v", b)
+		}
+		if cfg.Erasure.SigningSecret != strings.Repeat("e", 32) {
+			t.Errorf("Erasure = %+v", cfg.Erasure)
+		}
+		if w := cfg.Webhooks; len(w.AllowedNetworks) != 2 || w.AllowedNetworks[1].Bits() != 128 {
+			t.Errorf("Webhooks = %+v", w)
+		}
+		if n := cfg.Notifications; !n.Enabled() || n.Connectors[0].Kind != "slack" || n.OverdueInterval != 5*time.Minute {
+			t.Errorf("Notifications = %+v", n)
+		}
+		if l := cfg.Log; l.File != "/var/log/tasks/api.log" || l.MaxAge != 24*time.Hour || l.MaxSizeMB != 100 ||
+			l.StartupReport != "/run/tasks/startup.json" || !slices.Equal(l.Redact, []string{"description", "owner_id"}) {
+			t.Errorf("Log = %+v", l)
+		}
+		if a := cfg.Log.Access; len(a.Sinks) != 2 || a.File != "/var/log/tasks/access.log" ||
+			a.SampleRate != 0.1 || a.SampleAfter != 200 || a.MaxBackups != 5 {
+			t.Errorf("Access = %+v", a)
+		}
+	})
+
+	t.Run("every problem is reported", func(t *testing.T) {
+		t.Setenv("PORT", "99999")
+		t.Setenv("ERROR_FORMAT", "xml")
+		t.Setenv("DEMO_MAX_TASKS", "-1")
+		t.Setenv("LOG_LEVEL", "loud")
+		t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8,proxy.internal")
+		t.Setenv("ADMIN_ADDR", "6060")
+		t.Setenv("STORAGE_DSN", "postgres://db/tasks")
+		t.Setenv("STORAGE_ID_FORMAT", "uuidv4")
+		t.Setenv("STORAGE_NODE_ID", "1024")
+		t.Setenv("STORAGE_WRITE_BEHIND_MAX_PENDING", "0")
+		t.Setenv("CORS_ALLOWED_ORIGINS", "app.example.com")
+		t.Setenv("MAX_BODY_BYTES", "0")
+		t.Setenv("DOCS_ENABLED", "sometimes")
+		t.Setenv("READ_ONLY", "later")
+		t.Setenv("READ_ONLY_MESSAGE", strings.Repeat("x", 501))
+		t.Setenv("COMPRESSION_MIN_SIZE", "small")
+		t.Setenv("COMPRESSION_CONTENT_TYPES", "json")
+		t.Setenv("SERVER_BULK_REQUEST_TIMEOUT", "-1s")
+		t.Setenv("SENTRY_DSN", "https://o0.ingest.sentry.io/0")
+		t.Setenv("LOG_BODY_MAX_BYTES", "0")
+		t.Setenv("CLEANUP_POLICIES", "done:90d")
+		t.Setenv("JOBS_WORKERS", "0")
+		t.Setenv("TEAMS_WEBHOOK_URL", "outlook.office.com/webhook")
+		t.Setenv("LOG_ACCESS_SINKS", "stdout,kafka")
+		t.Setenv("LOG_MAX_BACKUPS", "-1")
+		t.Setenv("LOG_REDACT", "description,owner id")
+		t.Setenv("ERASURE_SIGNING_SECRET", "secret")
+		t.Setenv("WEBHOOK_ALLOWED_NETWORKS", "10.20.0.0/16,hooks.internal")
+
+		_, errs := Load("", false)
+		if len(errs) != 28 {
+			t.Errorf("got %d errors %v, want 28", len(errs), errs)
+		}
+	})
+}
+
+func TestLoad_LogRedactNone(t *testing.T) {
+	t.Setenv("LOG_REDACT", "none")
+	cfg, errs := Load("", false)
+	if len(errs) != 0 || len(cfg.Log.Redact) != 0 {
+		t.Errorf("Redact = %v, errors = %v, want nothing redacted", cfg.Log.Redact, errs)
+	}
+}
+
+func TestValidate_Demo(t *testing.T) {
+	tests := []struct {
+		name     string
+		dsn      string
+		personal bool
+		wantErrs int
+	}{
+		{"memory", "", false, 0},
+		{"explicit memory", "memory://", false, 0},
+		{"file storage", "file:///var/lib/tasks.json", false, 1},
+		{"personal mode", "", true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DEMO_MODE", "true")
+			t.Setenv("STORAGE_DSN", tt.dsn)
+			cfg, errs := Load("", tt.personal)
+			if !cfg.Demo.Enabled || len(errs) != tt.wantErrs {
+				t.Errorf("demo enabled = %v, got %d errors %v, want %d", cfg.Demo.Enabled, len(errs), errs, tt.wantErrs)
+			}
+		})
+	}
+}
+
+func TestValidate_TLS(t *testing.T) {
+	tests := []struct {
+		name     string
+		tls      TLS
+		wantErrs int
+	}{
+		{"disabled", TLS{}, 0},
+		{"certificate files", TLS{CertFile: "cert.pem", KeyFile: "key.pem", RedirectAddr: ":80"}, 0},
+		{"autocert", TLS{AutocertDomains: []string{"tasks.example.com"}, AutocertCacheDir: "/var/cache/autocert"}, 0},
+		{"cert without key", TLS{CertFile: "cert.pem"}, 1},
+		{"files and autocert", TLS{CertFile: "cert.pem", KeyFile: "key.pem", AutocertDomains: []string{"tasks.example.com"}}, 1},
+		{"autocert without cache", TLS{AutocertDomains: []string{"tasks.example.com"}}, 1},
+		{"redirect without TLS", TLS{RedirectAddr: ":80"}, 1},
+		{"bad redirect address", TLS{CertFile: "cert.pem", KeyFile: "key.pem", RedirectAddr: "80"}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default(false)
+			cfg.Server.TLS = tt.tls
+			if errs := cfg.Validate(); len(errs) != tt.wantErrs {
+				t.Errorf("got %d errors %v, want %d", len(errs), errs, tt.wantErrs)
+			}
+		})
+	}
+}
+
+func TestValidate_RateLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		rl       RateLimit
+		wantErrs int
+	}{
+		{"disabled", RateLimit{}, 0},
+		{"memory", RateLimit{RPS: 10, Burst: 20, Store: "memory"}, 0},
+		{"redis", RateLimit{RPS: 10, Burst: 20, Store: "redis://localhost:6379/0"}, 0},
+		{"negative rate", RateLimit{RPS: -1, Burst: 20, Store: "memory"}, 1},
+		{"no burst", RateLimit{RPS: 10, Store: "memory"}, 1},
+		{"unknown store", RateLimit{RPS: 10, Burst: 20, Store: "memcached://localhost"}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default(false)
+			cfg.Server.RateLimit = tt.rl
+			if errs := cfg.Validate(); len(errs) != tt.wantErrs {
+				t.Errorf("got %d errors %v, want %d", len(errs), errs, tt.wantErrs)
+			}
+		})
+	}
+}
+
+func TestValidate_Cleanup(t *testing.T) {
+	tests := []struct {
+		name     string
+		cleanup  Cleanup
+		wantErrs int
+	}{
+		{"no policies", Cleanup{}, 0},
+		{"done after 90 days", Cleanup{Interval: time.Hour, Policies: []cleanup.Policy{{Status: models.StatusDone, OlderThan: 2160 * time.Hour}}}, 0},
+		{"no interval", Cleanup{Policies: []cleanup.Policy{{Status: models.StatusDone, OlderThan: time.Hour}}}, 1},
+		{"unknown status", Cleanup{Interval: time.Hour, Policies: []cleanup.Policy{{Status: "archived", OlderThan: time.Hour}}}, 1},
+		{"no age", Cleanup{Interval: time.Hour, Policies: []cleanup.Policy{{Status: models.StatusDone}}}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default(false)
+			cfg.Cleanup = tt.cleanup
+			if errs := cfg.Validate(); len(errs) != tt.wantErrs {
+				t.Errorf("got %d errors %v, want %d", len(errs), errs, tt.wantErrs)
+			}
+		})
+	}
+}
+
+func TestValidate_Backup(t *testing.T) {
+	for dest, wantErrs := range map[string]int{
+		"":                       0,
+		"file:///var/backups":    0,
+		"s3://bucket/tasks":      0,
+		"/var/backups":           1,
+		"gs://bucket/tasks":      1,
+		"s3:///no-bucket/prefix": 1,
+	} {
+		cfg := Default(false)
+		cfg.Backup.Destination = dest
+		if errs := cfg.Validate(); len(errs) != wantErrs {
+			t.Errorf("%q: got %d errors %v, want %d", dest, len(errs), errs, wantErrs)
+		}
+	}
+}
+
+func TestValidate_Blob(t *testing.T) {
+	tests := []struct {
+		name     string
+		blob     Blob
+		wantErrs int
+	}{
+		{"defaults", Blob{LinkTTL: 15 * time.Minute}, 0},
+		{"s3", Blob{URL: "s3://exports", SigningSecret: strings.Repeat("s", 32), LinkTTL: time.Hour}, 0},
+		{"relative directory", Blob{URL: "exports", LinkTTL: time.Hour}, 1},
+		{"short secret", Blob{SigningSecret: strings.Repeat("s", minSigningSecretLength-1), LinkTTL: time.Hour}, 1},
+		{"no ttl", Blob{}, 1},
+		{"ttl over a week", Blob{LinkTTL: 8 * 24 * time.Hour}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default(false)
+			cfg.Blob = tt.blob
+			if errs := cfg.Validate(); len(errs) != tt.wantErrs {
+				t.Errorf("got %d errors %v, want %d", len(errs), errs, tt.wantErrs)
+			}
+		})
+	}
+}
+
+func TestValidate_Erasure(t *testing.T) {
+	tests := []struct {
+		name     string
+		secret   string
+		wantErrs int
+	}{
+		{"unset", "", 0},
+		{"long enough", strings.Repeat("e", minSigningSecretLength), 0},
+		{"short secret", strings.Repeat("e", minSigningSecretLength-1), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default(false)
+			cfg.Erasure.SigningSecret = tt.secret
+			if errs := cfg.Validate(); len(errs) != tt.wantErrs {
+				t.Errorf("got %d errors %v, want %d", len(errs), errs, tt.wantErrs)
+			}
+		})
+	}
+}
+
+func TestValidate_StorageEncryption(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32)))
+	tests := []struct {
+		name     string
+		dsn      string
+		enc      StorageEncryption
+		wantErrs int
+	}{
+		{"off", "", StorageEncryption{}, 0},
+		{"key", "file:///var/lib/tasks.json", StorageEncryption{Key: key}, 0},
+		{"key file", "file:///var/lib/tasks.json", StorageEncryption{KeyFile: "/run/secrets/tasks-key"}, 0},
+		{"rotation", "file:///var/lib/tasks.json", StorageEncryption{Key: key, PreviousKeys: []string{key}}, 0},
+		{"memory storage", "memory://", StorageEncryption{Key: key}, 1},
+		{"key and key file", "file:///var/lib/tasks.json", StorageEncryption{Key: key, KeyFile: "/run/secrets/tasks-key"}, 1},
+		{"passphrase", "file:///var/lib/tasks.json", StorageEncryption{Key: "hunter2"}, 1},
+		{"previous keys only", "file:///var/lib/tasks.json", StorageEncryption{PreviousKeys: []string{key}}, 1},
+		{"bad previous key", "file:///var/lib/tasks.json", StorageEncryption{Key: key, PreviousKeys: []string{"hunter2"}}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default(false)
+			cfg.Storage.DSN = tt.dsn
+			cfg.Storage.Encryption = tt.enc
+			if errs := cfg.Validate(); len(errs) != tt.wantErrs {
+				t.Errorf("got %d errors %v, want %d", len(errs), errs, tt.wantErrs)
+			}
+		})
+	}
+}
+
+func TestStorageEncryption_Keyring(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key")
+	os.WriteFile(path, []byte(strings.Repeat("ab", 32)+"\n"), 0o600)
+	if _, err := (StorageEncryption{KeyFile: path}).Keyring(); err != nil {
+		t.Errorf("Keyring() from a key file error = %v", err)
+	}
+	if _, err := (StorageEncryption{KeyFile: path + ".missing"}).Keyring(); err == nil {
+		t.Error("Keyring() with a missing key file succeeded")
+	}
+}
+
+func TestValidate_Auth(t *testing.T) {
+	tests := []struct {
+		name     string
+		auth     Auth
+		wantErrs int
+	}{
+		{"disabled", Auth{}, 0},
+		{"enabled", Auth{Enabled: true, AdminKey: strings.Repeat("k", 32)}, 0},
+		{"no admin key", Auth{Enabled: true}, 1},
+		{"short admin key", Auth{Enabled: true, AdminKey: "secret"}, 1},
+		{"admin key without auth", Auth{AdminKey: strings.Repeat("k", 32)}, 0},
+		{"short admin key without auth", Auth{AdminKey: "secret"}, 1},
+		{"jwt secret", Auth{Enabled: true, AdminKey: strings.Repeat("k", 32), JWT: JWT{Secret: strings.Repeat("s", 32)}}, 0},
+		{"jwks", Auth{Enabled: true, AdminKey: strings.Repeat("k", 32), JWT: JWT{JWKSURL: "https://login.example.com/jwks.json"}}, 0},
+		{"jwt without auth", Auth{JWT: JWT{Secret: strings.Repeat("s", 32)}}, 1},
+		{"short jwt secret", Auth{Enabled: true, AdminKey: strings.Repeat("k", 32), JWT: JWT{Secret: "secret"}}, 1},
+		{"bad jwks url", Auth{Enabled: true, AdminKey: strings.Repeat("k", 32), JWT: JWT{JWKSURL: "jwks.json"}}, 1},
+		{"audit file", Auth{Enabled: true, AdminKey: strings.Repeat("k", 32), AuditFile: "audit.jsonl"}, 0},
+		{"audit file without auth", Auth{AuditFile: "audit.jsonl"}, 1},
+		{"calendar secret", Auth{CalendarSecret: strings.Repeat("c", 32)}, 0},
+		{"short calendar secret", Auth{CalendarSecret: "secret"}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default(false)
+			cfg.Auth = tt.auth
+			if errs := cfg.Validate(); len(errs) != tt.wantErrs {
+				t.Errorf("got %d errors %v, want %d", len(errs), errs, tt.wantErrs)
+			}
+		})
+	}
+}
+
+func TestValidate_Events(t *testing.T) {
+	tests := []struct {
+		name     string
+		driver   string
+		url      string
+		wantErrs int
+	}{
+		{"disabled", "", "", 0},
+		{"nats", "nats", "nats://localhost:4222", 0},
+		{"kafka", "kafka", "http://localhost:8082", 0},
+		{"unknown driver", "rabbitmq", "amqp://localhost", 1},
+		{"nats without url", "nats", "", 1},
+		{"kafka with a broker address", "kafka", "localhost:9092", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default(false)
+			cfg.Events.Driver = tt.driver
+			cfg.Events.URL = tt.url
+			if errs := cfg.Validate(); len(errs) != tt.wantErrs {
+				t.Errorf("got %d errors %v, want %d", len(errs), errs, tt.wantErrs)
+			}
+		})
+	}
+}
+
+func TestLoad_Plugins(t *testing.T) {
+	plugin.Register("config-test", func(*plugin.Registrar, plugin.Config) error { return nil })
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(`
+plugins:
+  - name: config-test
+    config:
+      url: https://audit.example.com
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, errs := Load(path, false)
+	if len(errs) != 0 || len(cfg.Plugins) != 1 || cfg.Plugins[0].Config.Kind == 0 {
+		t.Fatalf("Load() = %+v, %v", cfg.Plugins, errs)
+	}
+
+	// PLUGINS picks the plugins; those in the file keep their config
+	t.Setenv("PLUGINS", "config-test,missing,config-test")
+	cfg, errs = Load(path, false)
+	if len(cfg.Plugins) != 3 || cfg.Plugins[0].Config.Kind == 0 || len(errs) != 2 {
+		t.Errorf("Load() with PLUGINS = %+v, errors %v, want an unknown and a duplicate plugin", cfg.Plugins, errs)
+	}
+}
+
+func TestLoad_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("file settings with env overrides", func(t *testing.T) {
+		write(`
+server:
+  addr: ":9000"
+  read_timeout: 5s
+  trusted_proxies: ["10.0.0.0/8"]
+  cors:
+    allowed_origins: ["https://app.example.com"]
+storage:
+  dsn: memory://
+log:
+  level: warn
+`)
+		t.Setenv("PORT", "9100")
+
+		cfg, errs := Load(path, false)
+		if len(errs) != 0 {
+			t.Fatalf("Load() errors = %v", errs)
+		}
+		if cfg.Server.Addr != ":9100" {
+			t.Errorf("Addr = %q, want the PORT override :9100", cfg.Server.Addr)
+		}
+		if cfg.Server.ReadTimeout != 5*time.Second || cfg.Server.WriteTimeout != 15*time.Second {
+			t.Errorf("timeouts = %v/%v, want 5s from the file and the 15s default", cfg.Server.ReadTimeout, cfg.Server.WriteTimeout)
+		}
+		if cfg.Log.Level != slog.LevelWarn || len(cfg.Server.TrustedProxies) != 1 || len(cfg.Server.CORS.AllowedOrigins) != 1 {
+			t.Errorf("config = %+v", cfg)
+		}
+	})
+
+	t.Run("unknown keys are rejected", func(t *testing.T) {
+		write("server:\n  adr: \":9000\"\n")
+		if _, errs := Load(path, false); len(errs) != 1 {
+			t.Errorf("got %d errors %v, want 1", len(errs), errs)
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		if _, errs := Load(filepath.Join(t.TempDir(), "nope.yaml"), false); len(errs) != 1 {
+			t.Errorf("got %d errors %v, want 1", len(errs), errs)
+		}
+	})
+}
@@ -0,0 +1,175 @@
+// Package content implements the pluggable content-processing pipeline run
+// on task titles and descriptions before they are stored.
+package content
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Content is the user-supplied text a pipeline inspects. Processors may
+// rewrite it in place (e.g. masking words).
+type Content struct {
+	Title       string
+	Description string
+}
+
+// field returns a pointer to the named field, or nil for unknown names
+func (c *Content) field(name string) *string {
+	switch name {
+	case "title":
+		return &c.Title
+	case "description":
+		return &c.Description
+	}
+	return nil
+}
+
+// Rejection describes why a processor refused the content
+type Rejection struct {
+	Processor string `json:"processor"`
+	Field     string `json:"field"`
+	Message   string `json:"message"`
+}
+
+// Rejections lists every rejection raised by a pipeline run
+type Rejections []Rejection
+
+// Error implements the error interface
+func (r Rejections) Error() string {
+	msgs := make([]string, len(r))
+	for i, rej := range r {
+		msgs[i] = rej.Message
+	}
+	return "content rejected: " + strings.Join(msgs, "; ")
+}
+
+// Processor inspects and optionally rewrites content
+type Processor interface {
+	// Name identifies the processor in rejections
+	Name() string
+
+	// Process checks c, rewriting it if needed, and returns any rejections
+	Process(c *Content) []Rejection
+}
+
+// Pipeline runs processors in order
+type Pipeline []Processor
+
+// Run applies every processor to c and returns Rejections if any refused it
+func (p Pipeline) Run(c *Content) error {
+	var rejections Rejections
+	for _, proc := range p {
+		rejections = append(rejections, proc.Process(c)...)
+	}
+	if len(rejections) > 0 {
+		return rejections
+	}
+	return nil
+}
+
+// PolicySet maps tenants to pipelines, falling back to a default
+type PolicySet struct {
+	Default Pipeline
+	Tenants map[string]Pipeline
+}
+
+// For returns the pipeline configured for tenant
+func (s *PolicySet) For(tenant string) Pipeline {
+	if s == nil {
+		return nil
+	}
+	if p, ok := s.Tenants[tenant]; ok {
+		return p
+	}
+	return s.Default
+}
+
+// PolicyConfig is the JSON representation of one tenant's policy
+type PolicyConfig struct {
+	Profanity *ProfanityConfig `json:"profanity,omitempty"`
+	MaxLinks  int              `json:"max_links,omitempty"`
+	Regex     []RegexConfig    `json:"regex,omitempty"`
+	HTML      *HTMLConfig      `json:"html,omitempty"`
+}
+
+// ProfanityConfig configures the profanity filter
+type ProfanityConfig struct {
+	Words []string `json:"words"`
+	Mask  bool     `json:"mask"` // mask words instead of rejecting
+}
+
+// HTMLConfig configures sanitization of markup in descriptions
+type HTMLConfig struct {
+	Mode          string `json:"mode"`           // "strip" or "escape"
+	AllowMarkdown bool   `json:"allow_markdown"` // keep the tags Markdown renders to
+}
+
+// RegexConfig configures a custom regex policy
+type RegexConfig struct {
+	Name    string `json:"name"`
+	Field   string `json:"field"`
+	Pattern string `json:"pattern"`
+	Message string `json:"message"`
+}
+
+// PolicySetConfig is the JSON document LoadPolicies reads
+type PolicySetConfig struct {
+	Default PolicyConfig            `json:"default"`
+	Tenants map[string]PolicyConfig `json:"tenants,omitempty"`
+}
+
+// LoadPolicies reads a PolicySetConfig JSON document and builds its pipelines
+func LoadPolicies(r io.Reader) (*PolicySet, error) {
+	var cfg PolicySetConfig
+	if err := json.NewDecoder(r).Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("decoding content policies: %w", err)
+	}
+
+	def, err := cfg.Default.Build()
+	if err != nil {
+		return nil, fmt.Errorf("default policy: %w", err)
+	}
+
+	set := &PolicySet{Default: def, Tenants: make(map[string]Pipeline)}
+	for tenant, pc := range cfg.Tenants {
+		p, err := pc.Build()
+		if err != nil {
+			return nil, fmt.Errorf("policy for tenant %q: %w", tenant, err)
+		}
+		set.Tenants[tenant] = p
+	}
+
+	return set, nil
+}
+
+// Build creates the pipeline described by the config
+func (c PolicyConfig) Build() (Pipeline, error) {
+	var p Pipeline
+
+	// Sanitize first, so later processors see what is stored
+	if c.HTML != nil {
+		s, err := NewHTMLSanitizer(c.HTML.Mode, c.HTML.AllowMarkdown)
+		if err != nil {
+			return nil, err
+		}
+		p = append(p, s)
+	}
+	if c.Profanity != nil && len(c.Profanity.Words) > 0 {
+		p = append(p, NewProfanityFilter(c.Profanity.Words, c.Profanity.Mask))
+	}
+	if c.MaxLinks > 0 {
+		p = append(p, MaxLinks{Max: c.MaxLinks})
+	}
+	for _, rc := range c.Regex {
+		policy, err := NewRegexPolicy(rc.Name, rc.Field, rc.Pattern, rc.Message)
+		if err != nil {
+			return nil, err
+		}
+		p = append(p, policy)
+	}
+
+	return p, nil
+}
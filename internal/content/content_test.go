@@ -0,0 +1,180 @@
+package content
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testPolicies = `{
+  "default": {
+    "profanity": {"words": ["darn"]},
+    "max_links": 1,
+    "regex": [
+      {"name": "no_secrets", "field": "description", "pattern": "(?i)password\\s*=", "message": "description must not contain credentials"}
+    ]
+  },
+  "tenants": {
+    "acme": {"profanity": {"words": ["darn"], "mask": true}}
+  }
+}`
+
+func TestPipeline_Run(t *testing.T) {
+	set, err := LoadPolicies(strings.NewReader(testPolicies))
+	if err != nil {
+		t.Fatalf("LoadPolicies() error = %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		tenant         string
+		content        Content
+		wantProcessors []string
+		wantTitle      string
+	}{
+		{
+			name:      "clean content",
+			content:   Content{Title: "Ship it", Description: "see https://example.com"},
+			wantTitle: "Ship it",
+		},
+		{
+			name:           "profanity rejected",
+			content:        Content{Title: "Darn build"},
+			wantProcessors: []string{"profanity"},
+		},
+		{
+			name:           "every policy is reported",
+			content:        Content{Title: "ok", Description: "http://a http://b password = hunter2"},
+			wantProcessors: []string{"max_links", "no_secrets"},
+		},
+		{
+			name:      "tenant masks instead of rejecting",
+			tenant:    "acme",
+			content:   Content{Title: "Darn build"},
+			wantTitle: "**** build",
+		},
+		{
+			name:      "unknown tenant uses default",
+			tenant:    "other",
+			content:   Content{Title: "fine"},
+			wantTitle: "fine",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.content
+			err := set.For(tt.tenant).Run(&c)
+
+			if len(tt.wantProcessors) == 0 {
+				if err != nil {
+					t.Fatalf("Run() error = %v", err)
+				}
+				if c.Title != tt.wantTitle {
+					t.Errorf("Title = %q, want %q", c.Title, tt.wantTitle)
+				}
+				return
+			}
+
+			var rejections Rejections
+			if !errors.As(err, &rejections) {
+				t.Fatalf("Run() error = %v, want Rejections", err)
+			}
+			if len(rejections) != len(tt.wantProcessors) {
+				t.Fatalf("got %d rejections %+v, want %d", len(rejections), rejections, len(tt.wantProcessors))
+			}
+			for i, name := range tt.wantProcessors {
+				if rejections[i].Processor != name {
+					t.Errorf("rejection[%d].Processor = %q, want %q", i, rejections[i].Processor, name)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadPolicies_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"bad json":    `{`,
+		"bad pattern": `{"default": {"regex": [{"name": "x", "field": "title", "pattern": "("}]}}`,
+		"bad field":   `{"tenants": {"a": {"regex": [{"name": "x", "field": "status", "pattern": "x"}]}}}`,
+	}
+
+	for name, doc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := LoadPolicies(strings.NewReader(doc)); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestPolicySet_NilIsNoop(t *testing.T) {
+	var set *PolicySet
+	c := Content{Title: "anything"}
+	if err := set.For("x").Run(&c); err != nil {
+		t.Errorf("Run() error = %v", err)
+	}
+}
+
+func TestHTMLSanitizer(t *testing.T) {
+	tests := []struct {
+		name          string
+		mode          string
+		allowMarkdown bool
+		in            string
+		want          string
+	}{
+		{"plain text is unchanged", HTMLStrip, false, "Tom & Jerry: a < b, see https://example.com?a=1&b=2", "Tom & Jerry: a < b, see https://example.com?a=1&b=2"},
+		{"strip tags", HTMLStrip, false, `<p onclick="x()">Hello <b>world</b></p>`, "Hello world"},
+		{"strip drops script contents", HTMLStrip, false, `Hi<script>alert("x")</script> there<style>p{}</style>`, "Hi there"},
+		{"strip an unclosed script", HTMLStrip, false, `Hi<script>alert(1)`, "Hi"},
+		{"strip keeps a tag cut off at the end as text", HTMLStrip, false, "a<b then c", "a&lt;b then c"},
+		{"strip comments", HTMLStrip, false, "a<!-- <script> -->b", "ab"},
+		{"strip tags rebuilt by stripping", HTMLStrip, false, "<<b>script>alert(1)<</b>/script>", ""},
+		{"strip event handlers", HTMLStrip, false, `<img src=x onerror=alert(1)>done`, "done"},
+		{"escape tags", HTMLEscape, false, `<b>bold</b> & <img src=x onerror=alert(1)>`, `&lt;b&gt;bold&lt;/b&gt; & &lt;img src=x onerror=alert(1)&gt;`},
+		{"escape keeps script text inert", HTMLEscape, false, `<script>if (a<b) go()</script>`, `&lt;script&gt;if (a&lt;b) go()&lt;/script&gt;`},
+		{"escape is idempotent", HTMLEscape, false, `&lt;b&gt;bold&lt;/b&gt;`, `&lt;b&gt;bold&lt;/b&gt;`},
+		{"markdown keeps formatting tags", HTMLStrip, true, `<strong class="x">Hi</strong><br/><script>x</script>`, "<strong>Hi</strong><br>"},
+		{"markdown keeps safe links", HTMLStrip, true, `<a href="https://example.com/?a=1&amp;b=2" onclick="x()">docs</a>`, `<a href="https://example.com/?a=1&amp;b=2">docs</a>`},
+		{"markdown drops unsafe hrefs", HTMLStrip, true, `<a href="java&#x09;script:alert(1)">x</a>`, "<a>x</a>"},
+		{"markdown escapes other tags", HTMLEscape, true, `<em>ok</em><iframe src=x>`, "<em>ok</em>&lt;iframe src=x&gt;"},
+		{"markdown defuses inline links", HTMLStrip, true, "[click](javascript:alert(1)) [img](DATA:text/html,x) [ok](https://example.com)", "[click](#)) [img](#) [ok](https://example.com)"},
+		{"markdown defuses encoded schemes", HTMLStrip, true, "[x](&#106;avascript:alert(1))", "[x](#))"},
+		{"markdown defuses reference links", HTMLStrip, true, "see [a]\n\n[a]: javascript:alert(1)\n[b]: /tasks/1", "see [a]\n\n[a]: #\n[b]: /tasks/1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewHTMLSanitizer(tt.mode, tt.allowMarkdown)
+			if err != nil {
+				t.Fatalf("NewHTMLSanitizer() error = %v", err)
+			}
+			got := s.Sanitize(tt.in)
+			if got != tt.want {
+				t.Errorf("Sanitize(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			if again := s.Sanitize(got); again != got {
+				t.Errorf("Sanitize is not idempotent: %q became %q", got, again)
+			}
+		})
+	}
+}
+
+func TestLoadPolicies_HTML(t *testing.T) {
+	set, err := LoadPolicies(strings.NewReader(`{"default": {"html": {"mode": "strip"}, "profanity": {"words": ["darn"], "mask": true}}}`))
+	if err != nil {
+		t.Fatalf("LoadPolicies() error = %v", err)
+	}
+	c := Content{Title: "<b>Ship</b>", Description: "<b>darn</b> it"}
+	if err := set.For("").Run(&c); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	// Titles are left alone; descriptions are sanitized before masking
+	if c.Title != "<b>Ship</b>" || c.Description != "**** it" {
+		t.Errorf("content = %+v", c)
+	}
+
+	if _, err := LoadPolicies(strings.NewReader(`{"default": {"html": {"mode": "remove"}}}`)); err == nil {
+		t.Error("unknown html mode was accepted")
+	}
+}
@@ -0,0 +1,181 @@
+package content
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	xhtml "golang.org/x/net/html"
+)
+
+// HTML sanitization modes
+const (
+	HTMLStrip  = "strip"
+	HTMLEscape = "escape"
+)
+
+// HTMLSanitizer rewrites markup in descriptions so a web frontend that
+// renders them as HTML or Markdown cannot be made to run a stored script.
+// It never rejects content.
+type HTMLSanitizer struct {
+	mode          string
+	allowMarkdown bool
+}
+
+// NewHTMLSanitizer removes tags from descriptions (mode HTMLStrip), along
+// with the contents of script and style elements, or escapes them so they
+// show as text (mode HTMLEscape). With allowMarkdown, the formatting tags
+// Markdown renders to are kept, without attributes other than a safe
+// link href, and Markdown links to javascript: and similar URLs are
+// defused.
+func NewHTMLSanitizer(mode string, allowMarkdown bool) (*HTMLSanitizer, error) {
+	if mode != HTMLStrip && mode != HTMLEscape {
+		return nil, fmt.Errorf("html policy: mode must be %q or %q", HTMLStrip, HTMLEscape)
+	}
+	return &HTMLSanitizer{mode: mode, allowMarkdown: allowMarkdown}, nil
+}
+
+// Name implements Processor
+func (s *HTMLSanitizer) Name() string { return "html" }
+
+// Process implements Processor
+func (s *HTMLSanitizer) Process(c *Content) []Rejection {
+	c.Description = s.Sanitize(c.Description)
+	return nil
+}
+
+// maxSanitizePasses bounds the passes Sanitize makes before giving up on
+// keeping any markup
+const maxSanitizePasses = 8
+
+// Sanitize returns text with disallowed markup stripped or escaped. Text
+// outside tags is kept as written, so plain descriptions, including ones
+// with a literal "<" or "&", are unchanged.
+func (s *HTMLSanitizer) Sanitize(text string) string {
+	// Removing a tag can join the text around it into a new one, as in
+	// "<<b>script>", so passes repeat until nothing changes
+	for range maxSanitizePasses {
+		next := s.pass(text)
+		if s.allowMarkdown {
+			next = defuseMarkdownLinks(next)
+		}
+		if next == text {
+			return text
+		}
+		text = next
+	}
+	return strings.ReplaceAll(text, "<", "&lt;")
+}
+
+// rawTextElements hold text that is not markup; stripping their tags
+// would turn a script into visible code, so their contents go too
+var rawTextElements = map[string]bool{
+	"iframe": true, "noembed": true, "noframes": true, "noscript": true, "plaintext": true,
+	"script": true, "style": true, "textarea": true, "title": true, "xmp": true,
+}
+
+// markdownElements are the tags Markdown renders to
+var markdownElements = map[string]bool{
+	"a": true, "b": true, "blockquote": true, "br": true, "code": true, "del": true, "em": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "hr": true,
+	"i": true, "li": true, "ol": true, "p": true, "pre": true, "s": true, "strong": true, "ul": true,
+}
+
+// pass rewrites every tag, comment and doctype in text once
+func (s *HTMLSanitizer) pass(text string) string {
+	var b strings.Builder
+	z := xhtml.NewTokenizer(strings.NewReader(text))
+	skipping := ""
+	for {
+		tt := z.Next()
+		raw := string(z.Raw())
+		if tt == xhtml.ErrorToken {
+			// A tag cut off by the end of the text is left over; it is
+			// escaped rather than dropped, as it is more likely a "<" in
+			// prose than markup. Only "<" is escaped, so a later pass
+			// does not escape the entity again.
+			b.WriteString(strings.ReplaceAll(raw, "<", "&lt;"))
+			return b.String()
+		}
+
+		switch tt {
+		case xhtml.TextToken:
+			if skipping == "" || s.mode == HTMLEscape {
+				b.WriteString(raw)
+			}
+			continue
+		case xhtml.StartTagToken, xhtml.SelfClosingTagToken, xhtml.EndTagToken:
+			name, _ := z.TagName()
+			tag := string(name)
+			if s.allowMarkdown && markdownElements[tag] {
+				b.WriteString(s.markdownTag(z, tt, tag))
+				continue
+			}
+			switch {
+			case tt == xhtml.StartTagToken && rawTextElements[tag]:
+				skipping = tag
+			case tt == xhtml.EndTagToken && tag == skipping:
+				skipping = ""
+			}
+		}
+		if s.mode == HTMLEscape {
+			b.WriteString(html.EscapeString(raw))
+		}
+	}
+}
+
+// markdownTag writes an allowed tag in a canonical form: without
+// attributes, except the href of a link when it is safe
+func (s *HTMLSanitizer) markdownTag(z *xhtml.Tokenizer, tt xhtml.TokenType, tag string) string {
+	if tt == xhtml.EndTagToken {
+		return "</" + tag + ">"
+	}
+	if tag == "a" {
+		for more := true; more; {
+			var key, val []byte
+			key, val, more = z.TagAttr()
+			if string(key) == "href" && safeURL(string(val)) {
+				return `<a href="` + html.EscapeString(string(val)) + `">`
+			}
+		}
+	}
+	return "<" + tag + ">"
+}
+
+// urlScheme matches the scheme of an absolute URL
+var urlScheme = regexp.MustCompile(`^[a-z][a-z0-9+.-]*:`)
+
+// safeURL reports whether u is relative or uses http, https or mailto,
+// after undoing the entity encoding and whitespace browsers ignore
+func safeURL(u string) bool {
+	u = strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, html.UnescapeString(u))
+	scheme := urlScheme.FindString(strings.ToLower(u))
+	return scheme == "" || scheme == "http:" || scheme == "https:" || scheme == "mailto:"
+}
+
+// markdownLinks match the target of an inline link or image, as in
+// [text](target), and of a reference definition, as in [id]: target
+var markdownLinks = []*regexp.Regexp{
+	regexp.MustCompile(`(\]\(\s*<?)([^\s)>]+)`),
+	regexp.MustCompile(`(?m)(^[ \t]*\[[^\]\n]+\]:[ \t]*<?)([^\s>]+)`),
+}
+
+// defuseMarkdownLinks points Markdown links with an unsafe target at "#"
+func defuseMarkdownLinks(text string) string {
+	for _, re := range markdownLinks {
+		text = re.ReplaceAllStringFunc(text, func(m string) string {
+			parts := re.FindStringSubmatch(m)
+			if safeURL(parts[2]) {
+				return m
+			}
+			return parts[1] + "#"
+		})
+	}
+	return text
+}
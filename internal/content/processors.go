@@ -0,0 +1,113 @@
+package content
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ProfanityFilter rejects or masks configured words in titles and descriptions
+type ProfanityFilter struct {
+	pattern *regexp.Regexp
+	mask    bool
+}
+
+// NewProfanityFilter matches words case-insensitively on word boundaries.
+// When mask is true matches are replaced with asterisks instead of
+// rejecting the content.
+func NewProfanityFilter(words []string, mask bool) *ProfanityFilter {
+	quoted := make([]string, len(words))
+	for i, w := range words {
+		quoted[i] = regexp.QuoteMeta(w)
+	}
+	return &ProfanityFilter{
+		pattern: regexp.MustCompile(`(?i)\b(` + strings.Join(quoted, "|") + `)\b`),
+		mask:    mask,
+	}
+}
+
+// Name implements Processor
+func (f *ProfanityFilter) Name() string { return "profanity" }
+
+// Process implements Processor
+func (f *ProfanityFilter) Process(c *Content) []Rejection {
+	var rejections []Rejection
+	for _, name := range []string{"title", "description"} {
+		value := c.field(name)
+		if !f.pattern.MatchString(*value) {
+			continue
+		}
+		if f.mask {
+			*value = f.pattern.ReplaceAllStringFunc(*value, func(m string) string {
+				return strings.Repeat("*", len([]rune(m)))
+			})
+			continue
+		}
+		rejections = append(rejections, Rejection{
+			Processor: f.Name(),
+			Field:     name,
+			Message:   name + " contains disallowed language",
+		})
+	}
+	return rejections
+}
+
+// linkPattern matches http(s) URLs
+var linkPattern = regexp.MustCompile(`(?i)https?://\S+`)
+
+// MaxLinks rejects descriptions containing more than Max links
+type MaxLinks struct {
+	Max int
+}
+
+// Name implements Processor
+func (m MaxLinks) Name() string { return "max_links" }
+
+// Process implements Processor
+func (m MaxLinks) Process(c *Content) []Rejection {
+	if n := len(linkPattern.FindAllStringIndex(c.Description, -1)); n > m.Max {
+		return []Rejection{{
+			Processor: m.Name(),
+			Field:     "description",
+			Message:   fmt.Sprintf("description contains %d links, at most %d allowed", n, m.Max),
+		}}
+	}
+	return nil
+}
+
+// RegexPolicy rejects a field matching a custom pattern
+type RegexPolicy struct {
+	name    string
+	field   string
+	pattern *regexp.Regexp
+	message string
+}
+
+// NewRegexPolicy creates a policy rejecting field values matching pattern
+func NewRegexPolicy(name, field, pattern, message string) (*RegexPolicy, error) {
+	if name == "" {
+		return nil, fmt.Errorf("regex policy requires a name")
+	}
+	if field != "title" && field != "description" {
+		return nil, fmt.Errorf("regex policy %q: field must be 'title' or 'description'", name)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("regex policy %q: %w", name, err)
+	}
+	if message == "" {
+		message = field + " violates policy " + name
+	}
+	return &RegexPolicy{name: name, field: field, pattern: re, message: message}, nil
+}
+
+// Name implements Processor
+func (p *RegexPolicy) Name() string { return p.name }
+
+// Process implements Processor
+func (p *RegexPolicy) Process(c *Content) []Rejection {
+	if p.pattern.MatchString(*c.field(p.field)) {
+		return []Rejection{{Processor: p.name, Field: p.field, Message: p.message}}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+// Package demo implements the public sandbox mode: the dataset is wiped on
+// a fixed interval and every response is watermarked so clients know data
+// is not persistent.
+package demo
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Watermark headers added to every response in demo mode
+//
+//api:changelog 0.2.0 added header X-Demo-Mode: Set to true on every response from a demo deployment
+//api:changelog 0.2.0 added header X-Demo-Reset-At: Time of the next demo data reset
+const (
+	HeaderDemo    = "X-Demo-Mode"
+	HeaderResetAt = "X-Demo-Reset-At"
+)
+
+// Config holds demo mode settings
+type Config struct {
+	// ResetInterval is how often the dataset is wiped
+	ResetInterval time.Duration
+
+	// MaxTasks caps the number of stored tasks
+	MaxTasks int
+}
+
+// DefaultConfig returns hourly resets and a 100 task cap
+func DefaultConfig() Config {
+	return Config{
+		ResetInterval: time.Hour,
+		MaxTasks:      100,
+	}
+}
+
+// Resetter is implemented by repositories that can wipe their data
+type Resetter interface {
+	Reset()
+}
+
+// Mode periodically resets a repository and watermarks responses
+type Mode struct {
+	cfg   Config
+	store Resetter
+
+	mu      sync.RWMutex
+	resetAt time.Time
+}
+
+// New creates a demo mode controller for store
+func New(store Resetter, cfg Config) *Mode {
+	return &Mode{
+		cfg:     cfg,
+		store:   store,
+		resetAt: time.Now().Add(cfg.ResetInterval),
+	}
+}
+
+// Run resets the store every ResetInterval until ctx is cancelled
+func (m *Mode) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.cfg.ResetInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Reset()
+		}
+	}
+}
+
+// Reset wipes the store now and moves the advertised next reset one
+// interval ahead
+func (m *Mode) Reset() {
+	m.store.Reset()
+
+	next := time.Now().Add(m.cfg.ResetInterval)
+	m.mu.Lock()
+	m.resetAt = next
+	m.mu.Unlock()
+
+	slog.Info("demo dataset reset", slog.Time("next_reset", next))
+}
+
+// NextReset returns when the dataset will next be wiped
+func (m *Mode) NextReset() time.Time {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.resetAt
+}
+
+// Middleware watermarks every response as coming from a demo instance
+func (m *Mode) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HeaderDemo, "true")
+		w.Header().Set(HeaderResetAt, m.NextReset().UTC().Format(time.RFC3339))
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,53 @@
+package demo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type countingResetter struct {
+	resets atomic.Int32
+}
+
+func (c *countingResetter) Reset() {
+	c.resets.Add(1)
+}
+
+func TestMode_Run(t *testing.T) {
+	store := &countingResetter{}
+	mode := New(store, Config{ResetInterval: 10 * time.Millisecond, MaxTasks: 1})
+
+	before := mode.NextReset()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 55*time.Millisecond)
+	defer cancel()
+	mode.Run(ctx)
+
+	if store.resets.Load() < 2 {
+		t.Errorf("got %d resets, want at least 2", store.resets.Load())
+	}
+	if !mode.NextReset().After(before) {
+		t.Error("NextReset should move forward after a reset")
+	}
+}
+
+func TestMode_Middleware(t *testing.T) {
+	mode := New(&countingResetter{}, DefaultConfig())
+	handler := mode.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/tasks", nil))
+
+	if rec.Header().Get(HeaderDemo) != "true" {
+		t.Errorf("%s = %q, want true", HeaderDemo, rec.Header().Get(HeaderDemo))
+	}
+	if _, err := time.Parse(time.RFC3339, rec.Header().Get(HeaderResetAt)); err != nil {
+		t.Errorf("%s is not RFC3339: %v", HeaderResetAt, err)
+	}
+}
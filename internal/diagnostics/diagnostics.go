@@ -0,0 +1,68 @@
+// Package diagnostics describes the state of a running server in one
+// document, for operators to attach to bug reports and incident notes.
+package diagnostics
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/maintenance"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+	"github.com/light-bringer/cert-tasks/internal/scheduler"
+	"github.com/light-bringer/cert-tasks/internal/version"
+)
+
+// Report is the body of GET /admin/diagnostics
+type Report struct {
+	Version     version.Info      `json:"version"`
+	StartedAt   time.Time         `json:"started_at"`
+	Uptime      string            `json:"uptime"`
+	Runtime     Runtime           `json:"runtime"`
+	Maintenance maintenance.State `json:"maintenance"`
+
+	// Storage is left out when the backend cannot report statistics
+	Storage *repository.Stats `json:"storage,omitempty"`
+
+	// Jobs is left out when no scheduler runs
+	Jobs []scheduler.Status `json:"jobs,omitempty"`
+}
+
+// Runtime holds Go runtime figures
+type Runtime struct {
+	Goroutines int `json:"goroutines"`
+	GOMAXPROCS int `json:"gomaxprocs"`
+	NumCPU     int `json:"num_cpu"`
+
+	// HeapAllocBytes is memory held by live and not yet collected heap
+	// objects; SysBytes is memory obtained from the OS
+	HeapAllocBytes uint64 `json:"heap_alloc_bytes"`
+	HeapObjects    uint64 `json:"heap_objects"`
+	SysBytes       uint64 `json:"sys_bytes"`
+
+	NumGC      uint32     `json:"num_gc"`
+	LastGC     *time.Time `json:"last_gc,omitempty"`
+	PauseTotal string     `json:"gc_pause_total"`
+}
+
+// ReadRuntime reads the current runtime figures. It stops the world
+// briefly to read memory statistics.
+func ReadRuntime() Runtime {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	rt := Runtime{
+		Goroutines:     runtime.NumGoroutine(),
+		GOMAXPROCS:     runtime.GOMAXPROCS(0),
+		NumCPU:         runtime.NumCPU(),
+		HeapAllocBytes: mem.HeapAlloc,
+		HeapObjects:    mem.HeapObjects,
+		SysBytes:       mem.Sys,
+		NumGC:          mem.NumGC,
+		PauseTotal:     time.Duration(mem.PauseTotalNs).String(),
+	}
+	if mem.LastGC > 0 {
+		last := time.Unix(0, int64(mem.LastGC)).UTC()
+		rt.LastGC = &last
+	}
+	return rt
+}
@@ -0,0 +1,512 @@
+// Package duedate parses the natural-language due dates people type, such
+// as "tomorrow", "next friday 5pm", "in 3 days" or "march 5 at noon", into
+// a time in the requester's time zone. Input that could mean more than one
+// time, like "03/04" or "at 5", is not guessed: Parse returns an Error
+// listing the candidates so the client can ask which was meant.
+package duedate
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultHour is the time of day, the end of the working day, given to a
+// due date without one
+const DefaultHour = 17
+
+// tonightHour is the time of day "tonight" means
+const tonightHour = 20
+
+// Error explains why an input could not be turned into a single time
+type Error struct {
+	Input  string
+	Reason string
+
+	// Candidates are the times an ambiguous input could mean, earliest
+	// first; empty when the input was not understood at all
+	Candidates []time.Time
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("due date %q: %s", e.Input, e.Reason)
+}
+
+// Ambiguous reports whether the input was understood but could mean more
+// than one time
+func (e *Error) Ambiguous() bool {
+	return len(e.Candidates) > 1
+}
+
+// date is a calendar day, resolved to a time once the time of day is known
+type date struct {
+	year  int
+	month time.Month
+	day   int
+}
+
+// clock is a time of day
+type clock struct {
+	hour, minute int
+}
+
+var (
+	weekdays = map[string]time.Weekday{
+		"sunday": time.Sunday, "sun": time.Sunday,
+		"monday": time.Monday, "mon": time.Monday,
+		"tuesday": time.Tuesday, "tue": time.Tuesday, "tues": time.Tuesday,
+		"wednesday": time.Wednesday, "wed": time.Wednesday,
+		"thursday": time.Thursday, "thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday,
+		"friday": time.Friday, "fri": time.Friday,
+		"saturday": time.Saturday, "sat": time.Saturday,
+	}
+	months = map[string]time.Month{
+		"january": time.January, "jan": time.January,
+		"february": time.February, "feb": time.February,
+		"march": time.March, "mar": time.March,
+		"april": time.April, "apr": time.April,
+		"may":  time.May,
+		"june": time.June, "jun": time.June,
+		"july": time.July, "jul": time.July,
+		"august": time.August, "aug": time.August,
+		"september": time.September, "sep": time.September, "sept": time.September,
+		"october": time.October, "oct": time.October,
+		"november": time.November, "nov": time.November,
+		"december": time.December, "dec": time.December,
+	}
+	units = map[string]string{
+		"minute": "minute", "minutes": "minute", "min": "minute", "mins": "minute",
+		"hour": "hour", "hours": "hour", "hr": "hour", "hrs": "hour",
+		"day": "day", "days": "day",
+		"week": "week", "weeks": "week",
+		"month": "month", "months": "month",
+	}
+
+	clockPattern   = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?(am|pm)?$`)
+	dayPattern     = regexp.MustCompile(`^(\d{1,2})(?:st|nd|rd|th)?$`)
+	isoPattern     = regexp.MustCompile(`^(\d{4})-(\d{1,2})-(\d{1,2})$`)
+	numericPattern = regexp.MustCompile(`^(\d{1,2})[/.](\d{1,2})(?:[/.](\d{2}|\d{4}))?$`)
+)
+
+// Parse interprets s relative to now, in now's location. A date without a
+// time of day is due at DefaultHour; a time of day without a date is due
+// today, or tomorrow once that time has passed. Weekdays mean the coming
+// one: "friday" and "this friday" include today, "next friday" does not.
+func Parse(s string, now time.Time) (time.Time, error) {
+	input := strings.TrimSpace(s)
+	if input == "" {
+		return time.Time{}, &Error{Input: s, Reason: "is empty"}
+	}
+	if t, err := time.Parse(time.RFC3339, input); err == nil {
+		return t, nil
+	}
+
+	p := &parser{input: input, now: now}
+	if err := p.parse(strings.Fields(strings.ToLower(strings.ReplaceAll(input, ",", " ")))); err != nil {
+		return time.Time{}, err
+	}
+
+	candidates := p.candidates()
+	if len(candidates) > 1 {
+		return time.Time{}, &Error{Input: input, Reason: "is ambiguous", Candidates: candidates}
+	}
+	return candidates[0], nil
+}
+
+// parser collects the possible dates and times of day an input names
+type parser struct {
+	input string
+	now   time.Time
+
+	dates  []date
+	clocks []clock
+
+	// defaultClock replaces DefaultHour, as for "tonight"
+	defaultClock *clock
+}
+
+func (p *parser) fail(format string, args ...any) error {
+	return &Error{Input: p.input, Reason: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) setDates(dates ...date) error {
+	if p.dates != nil {
+		return p.fail("names more than one date")
+	}
+	p.dates = dates
+	return nil
+}
+
+func (p *parser) setClocks(clocks ...clock) error {
+	if p.clocks != nil {
+		return p.fail("names more than one time of day")
+	}
+	p.clocks = clocks
+	return nil
+}
+
+func (p *parser) today() date {
+	return dateOf(p.now)
+}
+
+func dateOf(t time.Time) date {
+	y, m, d := t.Date()
+	return date{y, m, d}
+}
+
+// add returns d moved by the given number of days
+func (p *parser) add(d date, days int) date {
+	return dateOf(time.Date(d.year, d.month, d.day+days, 12, 0, 0, 0, p.now.Location()))
+}
+
+// weekday returns the coming day named wd, today included if includeToday
+func (p *parser) weekday(wd time.Weekday, includeToday bool) date {
+	days := (int(wd) - int(p.now.Weekday()) + 7) % 7
+	if days == 0 && !includeToday {
+		days = 7
+	}
+	return p.add(p.today(), days)
+}
+
+// upcoming returns month and day in the year given, or this year if the
+// day is still to come and next year otherwise
+func (p *parser) upcoming(month time.Month, day, year int) (date, error) {
+	if month < time.January || month > time.December || day < 1 || day > 31 {
+		return date{}, p.fail("has no month %d day %d", month, day)
+	}
+	explicit := year != 0
+	if !explicit {
+		year = p.now.Year()
+	}
+	d := date{year, month, day}
+	if time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Day() != day {
+		return date{}, p.fail("has no %s %d", month, day)
+	}
+	if !explicit && d.before(p.today()) {
+		d.year++
+	}
+	return d, nil
+}
+
+func (d date) before(o date) bool {
+	if d.year != o.year {
+		return d.year < o.year
+	}
+	if d.month != o.month {
+		return d.month < o.month
+	}
+	return d.day < o.day
+}
+
+func (p *parser) parse(tokens []string) error {
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		next := ""
+		if i+1 < len(tokens) {
+			next = tokens[i+1]
+		}
+
+		switch {
+		case tok == "at" || tok == "on" || tok == "by":
+			continue
+
+		case tok == "today":
+			if err := p.setDates(p.today()); err != nil {
+				return err
+			}
+		case tok == "tonight":
+			if err := p.setDates(p.today()); err != nil {
+				return err
+			}
+			p.defaultClock = &clock{tonightHour, 0}
+		case tok == "tomorrow" || tok == "tmrw":
+			if err := p.setDates(p.add(p.today(), 1)); err != nil {
+				return err
+			}
+		case tok == "noon" || tok == "midday":
+			if err := p.setClocks(clock{12, 0}); err != nil {
+				return err
+			}
+		case tok == "midnight":
+			if err := p.setClocks(clock{0, 0}); err != nil {
+				return err
+			}
+
+		case tok == "in":
+			consumed, err := p.relative(next, tokens[i+1:])
+			if err != nil {
+				return err
+			}
+			i += consumed
+
+		case tok == "this" || tok == "next":
+			if err := p.named(tok, next); err != nil {
+				return err
+			}
+			i++
+
+		case isWeekday(tok):
+			// Said on a Friday, "friday" could be today or a week away
+			wd := weekdays[tok]
+			dates := []date{p.weekday(wd, true)}
+			if p.now.Weekday() == wd {
+				dates = append(dates, p.add(p.today(), 7))
+			}
+			if err := p.setDates(dates...); err != nil {
+				return err
+			}
+
+		case months[tok] != 0:
+			// "march 5", "march 5th", "march 5 2027"
+			m := dayPattern.FindStringSubmatch(next)
+			if m == nil {
+				return p.fail("needs a day after %q", tok)
+			}
+			day, _ := strconv.Atoi(m[1])
+			i++
+			year := 0
+			if i+1 < len(tokens) && len(tokens[i+1]) == 4 {
+				if y, err := strconv.Atoi(tokens[i+1]); err == nil {
+					year = y
+					i++
+				}
+			}
+			d, err := p.upcoming(months[tok], day, year)
+			if err != nil {
+				return err
+			}
+			if err := p.setDates(d); err != nil {
+				return err
+			}
+
+		case dayPattern.MatchString(tok) && months[next] != 0:
+			// "5 march", "5th march 2027"
+			m := dayPattern.FindStringSubmatch(tok)
+			day, _ := strconv.Atoi(m[1])
+			month := months[next]
+			i++
+			year := 0
+			if i+1 < len(tokens) && len(tokens[i+1]) == 4 {
+				if y, err := strconv.Atoi(tokens[i+1]); err == nil {
+					year = y
+					i++
+				}
+			}
+			d, err := p.upcoming(month, day, year)
+			if err != nil {
+				return err
+			}
+			if err := p.setDates(d); err != nil {
+				return err
+			}
+
+		case isoPattern.MatchString(tok):
+			m := isoPattern.FindStringSubmatch(tok)
+			year, _ := strconv.Atoi(m[1])
+			month, _ := strconv.Atoi(m[2])
+			day, _ := strconv.Atoi(m[3])
+			d, err := p.upcoming(time.Month(month), day, year)
+			if err != nil {
+				return err
+			}
+			if err := p.setDates(d); err != nil {
+				return err
+			}
+
+		case numericPattern.MatchString(tok):
+			dates, err := p.numeric(numericPattern.FindStringSubmatch(tok))
+			if err != nil {
+				return err
+			}
+			if err := p.setDates(dates...); err != nil {
+				return err
+			}
+
+		case clockPattern.MatchString(tok):
+			// "5 pm" splits the meridiem off
+			if (next == "am" || next == "pm") && !strings.HasSuffix(tok, "m") {
+				tok += next
+				i++
+			}
+			clocks, err := p.clock(clockPattern.FindStringSubmatch(tok))
+			if err != nil {
+				return err
+			}
+			if err := p.setClocks(clocks...); err != nil {
+				return err
+			}
+
+		default:
+			return p.fail("does not understand %q", tok)
+		}
+	}
+
+	if p.dates == nil && p.clocks == nil {
+		return p.fail("names no date or time")
+	}
+	return nil
+}
+
+func isWeekday(tok string) bool {
+	_, ok := weekdays[tok]
+	return ok
+}
+
+// relative handles "in N units" given the tokens after "in", returning how
+// many it consumed
+func (p *parser) relative(count string, rest []string) (int, error) {
+	if len(rest) < 2 {
+		return 0, p.fail(`needs an amount and unit after "in", as in "in 3 days"`)
+	}
+	n, err := strconv.Atoi(count)
+	if count == "a" || count == "an" {
+		n, err = 1, nil
+	}
+	if err != nil || n < 0 {
+		return 0, p.fail("needs a number instead of %q", count)
+	}
+	unit, ok := units[rest[1]]
+	if !ok {
+		return 0, p.fail("does not know the unit %q", rest[1])
+	}
+
+	var t time.Time
+	switch unit {
+	case "minute":
+		t = p.now.Add(time.Duration(n) * time.Minute)
+	case "hour":
+		t = p.now.Add(time.Duration(n) * time.Hour)
+	case "day":
+		t = p.now.AddDate(0, 0, n)
+	case "week":
+		t = p.now.AddDate(0, 0, 7*n)
+	case "month":
+		t = p.now.AddDate(0, n, 0)
+	}
+	if err := p.setDates(dateOf(t)); err != nil {
+		return 0, err
+	}
+	// Minutes and hours fix the time of day too
+	if unit == "minute" || unit == "hour" {
+		if err := p.setClocks(clock{t.Hour(), t.Minute()}); err != nil {
+			return 0, err
+		}
+	}
+	return 2, nil
+}
+
+// named handles "this" and "next" followed by a weekday, "week" or "month"
+func (p *parser) named(which, what string) error {
+	if isWeekday(what) {
+		wd := weekdays[what]
+		return p.setDates(p.weekday(wd, which == "this"))
+	}
+	switch {
+	case what == "week" && which == "next":
+		// The Monday starting next week
+		return p.setDates(p.weekday(time.Monday, false))
+	case what == "month" && which == "next":
+		first := time.Date(p.now.Year(), p.now.Month()+1, 1, 12, 0, 0, 0, p.now.Location())
+		return p.setDates(dateOf(first))
+	}
+	return p.fail("does not understand %q", which+" "+what)
+}
+
+// numeric handles dates written with numbers only, as 03/04 or 3.4.2027.
+// Whether the day or the month comes first varies by country, so both are
+// candidates unless one number is too large to be a month.
+func (p *parser) numeric(m []string) ([]date, error) {
+	a, _ := strconv.Atoi(m[1])
+	b, _ := strconv.Atoi(m[2])
+	year := 0
+	if m[3] != "" {
+		year, _ = strconv.Atoi(m[3])
+		if year < 100 {
+			year += 2000
+		}
+	}
+
+	var dates []date
+	if a <= 12 {
+		if d, err := p.upcoming(time.Month(a), b, year); err == nil {
+			dates = append(dates, d)
+		}
+	}
+	if b <= 12 && a != b {
+		if d, err := p.upcoming(time.Month(b), a, year); err == nil {
+			dates = append(dates, d)
+		}
+	}
+	if len(dates) == 0 {
+		return nil, p.fail("has no date %s", m[0])
+	}
+	return dates, nil
+}
+
+// clock handles times of day. A bare hour from 1 to 12 could be morning or
+// afternoon; with minutes, as in 5:30, the 24-hour clock is assumed.
+func (p *parser) clock(m []string) ([]clock, error) {
+	hour, _ := strconv.Atoi(m[1])
+	minute := 0
+	if m[2] != "" {
+		minute, _ = strconv.Atoi(m[2])
+	}
+	if minute > 59 {
+		return nil, p.fail("has no time %s", m[0])
+	}
+
+	switch m[3] {
+	case "am", "pm":
+		if hour < 1 || hour > 12 {
+			return nil, p.fail("has no time %s", m[0])
+		}
+		hour %= 12
+		if m[3] == "pm" {
+			hour += 12
+		}
+		return []clock{{hour, minute}}, nil
+	}
+
+	if hour > 23 {
+		return nil, p.fail("has no time %s", m[0])
+	}
+	if m[2] == "" && hour >= 1 && hour < 12 {
+		return []clock{{hour, 0}, {hour + 12, 0}}, nil
+	}
+	return []clock{{hour, minute}}, nil
+}
+
+// candidates combines the dates and times of day into the times the input
+// could mean, earliest first
+func (p *parser) candidates() []time.Time {
+	clocks := p.clocks
+	if clocks == nil {
+		def := clock{DefaultHour, 0}
+		if p.defaultClock != nil {
+			def = *p.defaultClock
+		}
+		clocks = []clock{def}
+	}
+
+	var times []time.Time
+	for _, c := range clocks {
+		if p.dates == nil {
+			// A time of day alone is the next one to come
+			t := time.Date(p.now.Year(), p.now.Month(), p.now.Day(), c.hour, c.minute, 0, 0, p.now.Location())
+			if !t.After(p.now) {
+				d := p.add(p.today(), 1)
+				t = time.Date(d.year, d.month, d.day, c.hour, c.minute, 0, 0, p.now.Location())
+			}
+			times = append(times, t)
+			continue
+		}
+		for _, d := range p.dates {
+			times = append(times, time.Date(d.year, d.month, d.day, c.hour, c.minute, 0, 0, p.now.Location()))
+		}
+	}
+	slices.SortFunc(times, func(a, b time.Time) int { return a.Compare(b) })
+	return slices.CompactFunc(times, time.Time.Equal)
+}
@@ -0,0 +1,146 @@
+package duedate
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	loc := time.FixedZone("CEST", 2*60*60)
+	// A Wednesday morning
+	now := time.Date(2026, time.October, 14, 10, 30, 0, 0, loc)
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, loc)
+	}
+
+	tests := []struct {
+		input string
+		want  time.Time
+	}{
+		{"today", at(time.October, 14, 17, 0)},
+		{"tonight", at(time.October, 14, 20, 0)},
+		{"Tomorrow at noon", at(time.October, 15, 12, 0)},
+		{"next friday 5pm", at(time.October, 16, 17, 0)},
+		{"5 pm next Friday", at(time.October, 16, 17, 0)},
+		{"this wednesday", at(time.October, 14, 17, 0)},
+		{"next wednesday", at(time.October, 21, 17, 0)},
+		{"mon 9:15am", at(time.October, 19, 9, 15)},
+		{"next week", at(time.October, 19, 17, 0)},
+		{"next month", at(time.November, 1, 17, 0)},
+		{"in 3 days", at(time.October, 17, 17, 0)},
+		{"in an hour", at(time.October, 14, 11, 30)},
+		{"in 2 weeks at 08:00", at(time.October, 28, 8, 0)},
+		{"march 5th", time.Date(2027, time.March, 5, 17, 0, 0, 0, loc)},
+		{"5 Dec, 2026 at 9am", at(time.December, 5, 9, 0)},
+		{"2026-11-02 13:45", at(time.November, 2, 13, 45)},
+		{"25/12", at(time.December, 25, 17, 0)},
+		{"12/25/2026 noon", at(time.December, 25, 12, 0)},
+		{"9am", at(time.October, 15, 9, 0)},
+		{"17:00", at(time.October, 14, 17, 0)},
+		{"2026-10-20T08:00:00Z", time.Date(2026, time.October, 20, 8, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.input, now)
+		if err != nil {
+			t.Errorf("Parse(%q) error: %v", tt.input, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("Parse(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParse_Ambiguous(t *testing.T) {
+	loc := time.FixedZone("CEST", 2*60*60)
+	now := time.Date(2026, time.October, 14, 10, 30, 0, 0, loc)
+
+	tests := []struct {
+		input string
+		want  []time.Time
+	}{
+		// Day or month first
+		{"03/11", []time.Time{
+			time.Date(2026, time.November, 3, 17, 0, 0, 0, loc),
+			time.Date(2027, time.March, 11, 17, 0, 0, 0, loc),
+		}},
+		// Morning or afternoon
+		{"tomorrow at 5", []time.Time{
+			time.Date(2026, time.October, 15, 5, 0, 0, 0, loc),
+			time.Date(2026, time.October, 15, 17, 0, 0, 0, loc),
+		}},
+		// Today or a week away
+		{"wednesday", []time.Time{
+			time.Date(2026, time.October, 14, 17, 0, 0, 0, loc),
+			time.Date(2026, time.October, 21, 17, 0, 0, 0, loc),
+		}},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.input, now)
+		var perr *Error
+		if !errors.As(err, &perr) || !perr.Ambiguous() {
+			t.Errorf("Parse(%q) error = %v, want ambiguous", tt.input, err)
+			continue
+		}
+		if len(perr.Candidates) != len(tt.want) {
+			t.Errorf("Parse(%q) candidates = %v, want %v", tt.input, perr.Candidates, tt.want)
+			continue
+		}
+		for i := range tt.want {
+			if !perr.Candidates[i].Equal(tt.want[i]) {
+				t.Errorf("Parse(%q) candidates = %v, want %v", tt.input, perr.Candidates, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	now := time.Date(2026, time.October, 14, 10, 30, 0, 0, time.UTC)
+
+	for _, input := range []string{
+		"",
+		"someday",
+		"at",
+		"tomorrow friday",
+		"5pm noon",
+		"february 30",
+		"13:75",
+		"in 3 fortnights",
+		"13pm",
+		"this month",
+	} {
+		_, err := Parse(input, now)
+		var perr *Error
+		if !errors.As(err, &perr) || perr.Ambiguous() {
+			t.Errorf("Parse(%q) error = %v, want unparseable", input, err)
+		}
+	}
+}
+
+func TestParse_DaylightSaving(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no time zone database:", err)
+	}
+	// The day before clocks in Berlin go back, on 25 October 2026
+	now := time.Date(2026, time.October, 24, 10, 30, 0, 0, berlin)
+
+	tests := []struct {
+		input string
+		want  time.Time
+	}{
+		// Wall-clock times keep their meaning across the change
+		{"tomorrow 9am", time.Date(2026, time.October, 25, 8, 0, 0, 0, time.UTC)},
+		{"in 1 day", time.Date(2026, time.October, 25, 16, 0, 0, 0, time.UTC)},
+		// Elapsed time does not
+		{"in 24 hours", time.Date(2026, time.October, 25, 8, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.input, now)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("Parse(%q) = %v, %v; want %v", tt.input, got, err, tt.want)
+		}
+	}
+}
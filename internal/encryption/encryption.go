@@ -0,0 +1,140 @@
+// Package encryption seals data written to disk with AES-256-GCM, so that
+// a snapshot on a shared host is not readable by whoever can read the
+// file. Sealed data starts with a header naming the key it was sealed
+// with, which lets a Keyring hold the previous keys during a rotation and
+// tells a missing key apart from a corrupted file.
+//
+// Keys are 32 random bytes, given in base64 or hex; generate one with
+// "openssl rand -base64 32".
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KeySize is the length of a key in bytes
+const KeySize = 32
+
+// magic starts all sealed data
+const magic = "CTSEAL1\n"
+
+// headerSize is the length of the magic, key ID and nonce before the
+// ciphertext
+const headerSize = len(magic) + 4 + 12
+
+var (
+	// ErrUnknownKey is returned for data sealed with a key the Keyring
+	// does not hold
+	ErrUnknownKey = errors.New("data was sealed with an unknown key")
+
+	// ErrCorrupt is returned for sealed data that was truncated or
+	// altered
+	ErrCorrupt = errors.New("sealed data is corrupt")
+)
+
+// ParseKey decodes a key given in standard base64 or hex
+func ParseKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if key, err := base64.StdEncoding.DecodeString(s); err == nil && len(key) == KeySize {
+		return key, nil
+	}
+	if key, err := hex.DecodeString(s); err == nil && len(key) == KeySize {
+		return key, nil
+	}
+	return nil, fmt.Errorf("key must be %d bytes in base64 or hex", KeySize)
+}
+
+// Sealed reports whether data was written by Seal
+func Sealed(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(magic))
+}
+
+// Keyring seals with its primary key and opens data sealed with any of its
+// keys
+type Keyring struct {
+	primary uint32
+	aeads   map[uint32]cipher.AEAD
+}
+
+// NewKeyring creates a Keyring sealing with primary. previous keys are
+// only used to open data sealed before a rotation.
+func NewKeyring(primary []byte, previous ...[]byte) (*Keyring, error) {
+	k := &Keyring{aeads: make(map[uint32]cipher.AEAD)}
+	for i, key := range append([][]byte{primary}, previous...) {
+		if len(key) != KeySize {
+			return nil, fmt.Errorf("key %d is %d bytes, want %d", i, len(key), KeySize)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		id := keyID(key)
+		if i == 0 {
+			k.primary = id
+		}
+		k.aeads[id] = aead
+	}
+	return k, nil
+}
+
+// keyID names a key in sealed data without revealing it
+func keyID(key []byte) uint32 {
+	sum := sha256.Sum256(append([]byte("cert-tasks key id\x00"), key...))
+	return binary.BigEndian.Uint32(sum[:4])
+}
+
+// KeyID returns the ID of the primary key, as hex, for logs
+func (k *Keyring) KeyID() string {
+	return fmt.Sprintf("%08x", k.primary)
+}
+
+// Seal encrypts plaintext with the primary key. The header is
+// authenticated along with the data.
+func (k *Keyring) Seal(plaintext []byte) ([]byte, error) {
+	out := make([]byte, headerSize, headerSize+len(plaintext)+16)
+	copy(out, magic)
+	binary.BigEndian.PutUint32(out[len(magic):], k.primary)
+	nonce := out[len(magic)+4 : headerSize]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generating nonce: %w", err)
+	}
+	return k.aeads[k.primary].Seal(out, nonce, plaintext, out[:headerSize]), nil
+}
+
+// Open decrypts data written by Seal
+func (k *Keyring) Open(sealed []byte) ([]byte, error) {
+	if !Sealed(sealed) || len(sealed) < headerSize {
+		return nil, ErrCorrupt
+	}
+	id := binary.BigEndian.Uint32(sealed[len(magic):])
+	aead, ok := k.aeads[id]
+	if !ok {
+		return nil, fmt.Errorf("%w (key ID %08x)", ErrUnknownKey, id)
+	}
+	plaintext, err := aead.Open(nil, sealed[len(magic)+4:headerSize], sealed[headerSize:], sealed[:headerSize])
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	return plaintext, nil
+}
+
+// Rotated reports whether sealed was sealed with a key other than the
+// primary one, and should be sealed again
+func (k *Keyring) Rotated(sealed []byte) bool {
+	return Sealed(sealed) && len(sealed) >= headerSize &&
+		binary.BigEndian.Uint32(sealed[len(magic):]) != k.primary
+}
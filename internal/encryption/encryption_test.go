@@ -0,0 +1,83 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func key(b byte) []byte {
+	return bytes.Repeat([]byte{b}, KeySize)
+}
+
+func TestKeyring(t *testing.T) {
+	old, err := NewKeyring(key(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := old.Seal([]byte(`{"tasks":[]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Sealed(sealed) || bytes.Contains(sealed, []byte("tasks")) {
+		t.Fatalf("sealed = %q, want the header and no plaintext", sealed)
+	}
+	if again, _ := old.Seal([]byte(`{"tasks":[]}`)); bytes.Equal(again, sealed) {
+		t.Error("sealing twice gave the same output; nonces are reused")
+	}
+
+	// A rotated keyring opens what the previous key sealed
+	rotated, err := NewKeyring(key(2), key(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := rotated.Open(sealed); err != nil || string(got) != `{"tasks":[]}` {
+		t.Errorf("Open() = %q, %v", got, err)
+	}
+	if !rotated.Rotated(sealed) || old.Rotated(sealed) {
+		t.Error("Rotated() does not tell the previous key from the primary one")
+	}
+
+	other, _ := NewKeyring(key(3))
+	if _, err := other.Open(sealed); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Open() with another key error = %v, want ErrUnknownKey", err)
+	}
+	tampered := bytes.Clone(sealed)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := old.Open(tampered); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Open(tampered) error = %v, want ErrCorrupt", err)
+	}
+	if _, err := old.Open(sealed[:headerSize-1]); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Open(truncated) error = %v, want ErrCorrupt", err)
+	}
+	if _, err := NewKeyring([]byte("short")); err == nil {
+		t.Error("NewKeyring accepted a short key")
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		wantErr bool
+	}{
+		{"base64", base64.StdEncoding.EncodeToString(key(7)), false},
+		{"hex", hex.EncodeToString(key(7)), false},
+		{"trailing newline", base64.StdEncoding.EncodeToString(key(7)) + "\n", false},
+		{"short", base64.StdEncoding.EncodeToString(key(7)[:16]), true},
+		{"passphrase", "correct horse battery staple", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseKey(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !bytes.Equal(got, key(7)) {
+				t.Errorf("ParseKey() = %x", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+// Package erasure erases a user's personal data on request, as data
+// protection law requires. An Eraser deletes or anonymizes the tasks a
+// user created, deletes their webhooks and anonymizes their audit entries,
+// and returns a Report signed with HMAC-SHA256 that the operator keeps as
+// proof of the erasure.
+//
+// Users are the JWT subjects tasks are owned by; tasks created with API
+// keys belong to no one and are never erased.
+package erasure
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/audit"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+// Modes of erasure
+const (
+	// ModeDelete deletes the user's tasks
+	ModeDelete = "delete"
+
+	// ModeAnonymize keeps the user's tasks in their workspaces without
+	// their owner and description
+	ModeAnonymize = "anonymize"
+)
+
+// ErasedActor replaces the user as the actor of their audit entries
+const ErasedActor = "erased"
+
+// Algorithm is how reports are signed
+const Algorithm = "HMAC-SHA256"
+
+// NotStored are the kinds of personal data the server has no store for,
+// listed in every report, and in user exports, so they answer for them too
+var NotStored = []string{"comments", "attachments"}
+
+var (
+	// ErrInvalidMode is returned for a mode other than ModeDelete and
+	// ModeAnonymize
+	ErrInvalidMode = errors.New(`mode must be "delete" or "anonymize"`)
+
+	// ErrNoUser is returned for an empty user ID
+	ErrNoUser = errors.New("user ID is required")
+)
+
+// Report records what an erasure removed. Task and webhook IDs are listed
+// so the erasure can be checked against backups taken before it.
+type Report struct {
+	UserID      string    `json:"user_id"`
+	Mode        string    `json:"mode"`
+	RequestID   string    `json:"request_id,omitempty"`
+	StartedAt   time.Time `json:"started_at"`
+	CompletedAt time.Time `json:"completed_at"`
+
+	TasksDeleted           []int64 `json:"tasks_deleted"`
+	TasksAnonymized        []int64 `json:"tasks_anonymized"`
+	WebhooksDeleted        []int64 `json:"webhooks_deleted"`
+	AuditEntriesAnonymized int     `json:"audit_entries_anonymized"`
+
+	// NotStored are kinds of personal data this server does not keep, so
+	// there was nothing of them to erase
+	NotStored []string `json:"not_stored"`
+}
+
+// Signed is a Report with its signature: the hex HMAC-SHA256 of the report
+// encoded as compact JSON, exactly as it appears in the response
+type Signed struct {
+	Report    json.RawMessage `json:"report"`
+	Algorithm string          `json:"algorithm"`
+	Signature string          `json:"signature"`
+}
+
+// Option configures an Eraser
+type Option func(*Eraser)
+
+// WithAnonymizer allows ModeAnonymize, which needs a repository that can
+// clear task owners
+func WithAnonymizer(a repository.Anonymizer) Option {
+	return func(e *Eraser) {
+		e.anonymizer = a
+	}
+}
+
+// WithWebhooks deletes the user's webhooks too
+func WithWebhooks(webhooks repository.WebhookRepository) Option {
+	return func(e *Eraser) {
+		e.webhooks = webhooks
+	}
+}
+
+// WithAudit anonymizes the user's audit entries too
+func WithAudit(log *audit.Log) Option {
+	return func(e *Eraser) {
+		e.audit = log
+	}
+}
+
+// Eraser erases users' data and signs reports of it
+type Eraser struct {
+	tasks      repository.TaskRepository
+	anonymizer repository.Anonymizer
+	webhooks   repository.WebhookRepository
+	audit      *audit.Log
+	secret     []byte
+	now        func() time.Time
+
+	// mu keeps erasures from overlapping
+	mu sync.Mutex
+}
+
+// New creates an Eraser deleting tasks through tasks, which should report
+// deletes like any other so webhooks and subscribers hear of them, and
+// signing reports with secret
+func New(tasks repository.TaskRepository, secret []byte, opts ...Option) *Eraser {
+	e := &Eraser{tasks: tasks, secret: secret, now: time.Now}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Erase erases the data of the user with the given ID in every workspace
+// and returns the signed report. ModeAnonymize fails with
+// repository.ErrNotSupported unless the Eraser has an anonymizer. On error
+// part of the data may already be erased; erasing again finishes the job.
+func (e *Eraser) Erase(ctx context.Context, userID, mode, requestID string) (Signed, error) {
+	if userID == "" {
+		return Signed{}, ErrNoUser
+	}
+	if mode != ModeDelete && mode != ModeAnonymize {
+		return Signed{}, ErrInvalidMode
+	}
+	if mode == ModeAnonymize && e.anonymizer == nil {
+		return Signed{}, fmt.Errorf("anonymizing tasks: %w", repository.ErrNotSupported)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	report := Report{
+		UserID:          userID,
+		Mode:            mode,
+		RequestID:       requestID,
+		StartedAt:       e.now().UTC(),
+		TasksDeleted:    []int64{},
+		TasksAnonymized: []int64{},
+		WebhooksDeleted: []int64{},
+		NotStored:       NotStored,
+	}
+	scoped := repository.WithOwner(repository.WithWorkspace(ctx, ""), userID)
+
+	// Webhooks go first so the user's own endpoints hear nothing of the
+	// erasure
+	if e.webhooks != nil {
+		hooks, err := e.webhooks.ListWebhooks(scoped)
+		if err != nil {
+			return Signed{}, fmt.Errorf("listing webhooks: %w", err)
+		}
+		for _, hook := range hooks {
+			err := e.webhooks.DeleteWebhook(scoped, hook.ID)
+			if errors.Is(err, repository.ErrWebhookNotFound) {
+				continue
+			}
+			if err != nil {
+				return Signed{}, fmt.Errorf("deleting webhook %d: %w", hook.ID, err)
+			}
+			report.WebhooksDeleted = append(report.WebhooksDeleted, hook.ID)
+		}
+	}
+
+	switch mode {
+	case ModeDelete:
+		tasks, err := e.tasks.GetAll(scoped)
+		if err != nil {
+			return Signed{}, fmt.Errorf("listing tasks: %w", err)
+		}
+		for _, task := range tasks {
+			err := e.tasks.Delete(scoped, task.ID)
+			if errors.Is(err, repository.ErrTaskNotFound) {
+				continue
+			}
+			if err != nil {
+				return Signed{}, fmt.Errorf("deleting task %d: %w", task.ID, err)
+			}
+			report.TasksDeleted = append(report.TasksDeleted, task.ID)
+		}
+	case ModeAnonymize:
+		ids, err := e.anonymizer.AnonymizeOwner(scoped, userID)
+		if err != nil {
+			return Signed{}, fmt.Errorf("anonymizing tasks: %w", err)
+		}
+		report.TasksAnonymized = append(report.TasksAnonymized, ids...)
+	}
+
+	if e.audit != nil {
+		n, err := e.audit.Anonymize("user:"+userID, ErasedActor)
+		if err != nil {
+			return Signed{}, fmt.Errorf("anonymizing audit entries: %w", err)
+		}
+		report.AuditEntriesAnonymized = n
+	}
+
+	report.CompletedAt = e.now().UTC()
+	return e.Sign(report)
+}
+
+// Sign signs report
+func (e *Eraser) Sign(report Report) (Signed, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(report); err != nil {
+		return Signed{}, err
+	}
+	data := bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	return Signed{Report: data, Algorithm: Algorithm, Signature: hex.EncodeToString(mac(e.secret, data))}, nil
+}
+
+// Verify reports whether s was signed with secret
+func Verify(s Signed, secret []byte) bool {
+	sig, err := hex.DecodeString(s.Signature)
+	return err == nil && s.Algorithm == Algorithm && hmac.Equal(sig, mac(secret, s.Report))
+}
+
+// mac returns the HMAC-SHA256 of data
+func mac(secret, data []byte) []byte {
+	m := hmac.New(sha256.New, secret)
+	m.Write(data)
+	return m.Sum(nil)
+}
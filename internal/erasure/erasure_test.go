@@ -0,0 +1,136 @@
+package erasure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/light-bringer/cert-tasks/internal/audit"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+var secret = []byte(strings.Repeat("s", 32))
+
+// seed stores two tasks and a webhook of alice's, a task of bob's and an
+// audit entry each
+func seed(t *testing.T) (*repository.MemoryRepository, *audit.Log) {
+	t.Helper()
+	ctx := context.Background()
+	alice := repository.WithOwner(ctx, "alice")
+	repo := repository.NewMemoryRepository()
+	repo.Create(alice, &models.Task{Title: "Call back", Description: "Jane, 555-0100"})
+	repo.Create(repository.WithOwner(ctx, "bob"), &models.Task{Title: "Bob's"})
+	repo.Create(alice, &models.Task{Title: "Write report"})
+	if _, err := repo.CreateWebhook(alice, &models.Webhook{URL: "https://alice.example.com/hook"}); err != nil {
+		t.Fatal(err)
+	}
+
+	log := audit.New(10)
+	log.Record(audit.Entry{Actor: "user:alice", Method: "POST", Route: "/tasks", IP: "192.0.2.1"})
+	log.Record(audit.Entry{Actor: "user:bob", Method: "POST", Route: "/tasks", IP: "192.0.2.2"})
+	return repo, log
+}
+
+// decode checks the signature of s and returns its report
+func decode(t *testing.T, s Signed) Report {
+	t.Helper()
+	if !Verify(s, secret) {
+		t.Fatalf("signature of %s does not verify", s.Report)
+	}
+	var r Report
+	if err := json.Unmarshal(s.Report, &r); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestEraser_Delete(t *testing.T) {
+	ctx := context.Background()
+	repo, log := seed(t)
+	eraser := New(repo, secret, WithWebhooks(repo), WithAudit(log))
+
+	signed, err := eraser.Erase(ctx, "alice", ModeDelete, "req-1")
+	if err != nil {
+		t.Fatalf("Erase() error = %v", err)
+	}
+	r := decode(t, signed)
+	if r.UserID != "alice" || r.Mode != ModeDelete || r.RequestID != "req-1" || r.CompletedAt.Before(r.StartedAt) {
+		t.Errorf("report = %+v", r)
+	}
+	if !slices.Equal(r.TasksDeleted, []int64{1, 3}) || len(r.TasksAnonymized) != 0 || !slices.Equal(r.WebhooksDeleted, []int64{1}) {
+		t.Errorf("report = %+v, want tasks 1 and 3 and webhook 1 deleted", r)
+	}
+	if r.AuditEntriesAnonymized != 1 || !slices.Equal(r.NotStored, []string{"comments", "attachments"}) {
+		t.Errorf("report = %+v", r)
+	}
+
+	if tasks, _ := repo.GetAll(ctx); len(tasks) != 1 || tasks[0].OwnerID != "bob" {
+		t.Errorf("tasks left = %+v, want only bob's", tasks)
+	}
+	if hooks, _ := repo.ListWebhooks(ctx); len(hooks) != 0 {
+		t.Errorf("webhooks left = %+v", hooks)
+	}
+	entries, _ := log.Query(audit.Filter{})
+	if entries[1].Actor != ErasedActor || entries[1].IP != "" || entries[0].Actor != "user:bob" {
+		t.Errorf("audit entries = %+v, want only alice's anonymized", entries)
+	}
+
+	// Erasing again finds nothing left
+	signed, _ = eraser.Erase(ctx, "alice", ModeDelete, "")
+	if r := decode(t, signed); len(r.TasksDeleted) != 0 || len(r.WebhooksDeleted) != 0 || r.AuditEntriesAnonymized != 0 {
+		t.Errorf("second report = %+v, want nothing erased", r)
+	}
+}
+
+func TestEraser_Anonymize(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := seed(t)
+
+	if _, err := New(repo, secret).Erase(ctx, "alice", ModeAnonymize, ""); !errors.Is(err, repository.ErrNotSupported) {
+		t.Errorf("without an anonymizer error = %v, want ErrNotSupported", err)
+	}
+
+	signed, err := New(repo, secret, WithAnonymizer(repo)).Erase(ctx, "alice", ModeAnonymize, "")
+	if err != nil {
+		t.Fatalf("Erase() error = %v", err)
+	}
+	if r := decode(t, signed); !slices.Equal(r.TasksAnonymized, []int64{1, 3}) || len(r.TasksDeleted) != 0 {
+		t.Errorf("report = %+v, want tasks 1 and 3 anonymized", r)
+	}
+	tasks, _ := repo.GetAll(ctx)
+	if len(tasks) != 3 || tasks[0].OwnerID != "" || tasks[0].Description != "" || tasks[0].Title != "Call back" {
+		t.Errorf("tasks = %+v, want alice's kept without owner and description", tasks)
+	}
+}
+
+func TestEraser_Errors(t *testing.T) {
+	eraser := New(repository.NewMemoryRepository(), secret)
+	if _, err := eraser.Erase(context.Background(), "", ModeDelete, ""); !errors.Is(err, ErrNoUser) {
+		t.Errorf("empty user error = %v, want ErrNoUser", err)
+	}
+	if _, err := eraser.Erase(context.Background(), "alice", "shred", ""); !errors.Is(err, ErrInvalidMode) {
+		t.Errorf("unknown mode error = %v, want ErrInvalidMode", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	signed, err := New(repository.NewMemoryRepository(), secret).Sign(Report{UserID: "alice", Mode: ModeDelete})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Verify(signed, secret) {
+		t.Error("Verify() = false for an untouched report")
+	}
+	if Verify(signed, []byte("another secret")) {
+		t.Error("Verify() = true with another secret")
+	}
+	tampered := signed
+	tampered.Report = json.RawMessage(strings.Replace(string(signed.Report), "alice", "bob", 1))
+	if Verify(tampered, secret) {
+		t.Error("Verify() = true for an altered report")
+	}
+}
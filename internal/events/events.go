@@ -0,0 +1,144 @@
+// Package events publishes every task change as a CloudEvents 1.0 message
+// to a message broker, NATS or Kafka, for downstream consumers such as
+// analytics or notification services. Events are queued in memory and
+// sent in order by a single worker, at most once: an event the broker
+// rejects is logged and dropped, as are events still queued at shutdown.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/logging"
+	"github.com/light-bringer/cert-tasks/internal/models"
+)
+
+// TypePrefix is prepended to the task event type to form the CloudEvents
+// type, e.g. "io.github.light-bringer.cert-tasks.task.created"
+const TypePrefix = "io.github.light-bringer.cert-tasks."
+
+// ContentType is the media type of an event in structured mode
+const ContentType = "application/cloudevents+json"
+
+// DefaultQueueSize bounds the events waiting to be published
+const DefaultQueueSize = 1000
+
+// CloudEvent is a task change in the CloudEvents 1.0 JSON format
+type CloudEvent struct {
+	SpecVersion     string    `json:"specversion"`
+	ID              string    `json:"id"`
+	Source          string    `json:"source"`
+	Type            string    `json:"type"`
+	Subject         string    `json:"subject"`
+	Time            time.Time `json:"time"`
+	DataContentType string    `json:"datacontenttype"`
+
+	// Workspace is an extension attribute naming the task's workspace
+	Workspace string `json:"workspace"`
+
+	// Data is the task after the change, or before a delete
+	Data *models.Task `json:"data"`
+}
+
+// EventType returns the task event type the CloudEvent carries
+func (e *CloudEvent) EventType() models.TaskEventType {
+	return models.TaskEventType(strings.TrimPrefix(e.Type, TypePrefix))
+}
+
+// Publisher sends an encoded event to a broker
+type Publisher interface {
+	Publish(ctx context.Context, event *CloudEvent, body []byte) error
+	Close() error
+}
+
+// Emitter turns task changes into CloudEvents and hands them to a
+// Publisher
+type Emitter struct {
+	pub    Publisher
+	source string
+	now    func() time.Time
+	queue  chan queued
+}
+
+// queued is an event waiting to be published, encoded when it was queued
+type queued struct {
+	event *CloudEvent
+	body  []byte
+}
+
+// NewEmitter creates an Emitter publishing to pub. source is the
+// CloudEvents source, identifying this deployment.
+func NewEmitter(pub Publisher, source string, queueSize int) *Emitter {
+	return &Emitter{
+		pub:    pub,
+		source: source,
+		now:    time.Now,
+		queue:  make(chan queued, queueSize),
+	}
+}
+
+// Publish queues a task change. It never blocks; when the queue is full
+// the event is dropped with a warning.
+func (e *Emitter) Publish(ctx context.Context, typ models.TaskEventType, task *models.Task) {
+	event := models.NewTaskEvent(typ, task, e.now())
+	ce := &CloudEvent{
+		SpecVersion:     "1.0",
+		ID:              event.ID,
+		Source:          e.source,
+		Type:            TypePrefix + string(typ),
+		Subject:         strconv.FormatInt(task.ID, 10),
+		Time:            event.CreatedAt,
+		DataContentType: "application/json",
+		Workspace:       task.WorkspaceID,
+		Data:            task,
+	}
+
+	// Encode now: the memory store may change the task once it is queued
+	body, err := json.Marshal(ce)
+	if err != nil {
+		logging.FromContext(ctx).Error("encoding event failed", slog.String("event_id", ce.ID), slog.Any("error", err))
+		return
+	}
+
+	select {
+	case e.queue <- queued{event: ce, body: body}:
+	default:
+		logging.FromContext(ctx).Warn("event queue full, dropping event",
+			slog.String("event_id", ce.ID), slog.String("type", ce.Type))
+	}
+}
+
+// Run publishes queued events in order until ctx is done
+func (e *Emitter) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case q := <-e.queue:
+			if err := e.pub.Publish(ctx, q.event, q.body); err != nil {
+				slog.Error("publishing event failed",
+					slog.String("event_id", q.event.ID),
+					slog.String("type", q.event.Type),
+					slog.Any("error", err),
+				)
+			}
+		}
+	}
+}
+
+// New connects to the broker named by driver, "nats" or "kafka". topic is
+// the NATS subject prefix or the Kafka topic.
+func New(driver, url, topic string, kafkaBudget time.Duration) (Publisher, error) {
+	switch driver {
+	case "nats":
+		return NewNATS(url, topic)
+	case "kafka":
+		return NewKafkaREST(url, topic, kafkaBudget), nil
+	}
+	return nil, fmt.Errorf("unknown event driver %q", driver)
+}
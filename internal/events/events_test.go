@@ -0,0 +1,119 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+)
+
+// recordingPublisher keeps every published event
+type recordingPublisher struct {
+	mu     sync.Mutex
+	events []CloudEvent
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, event *CloudEvent, body []byte) error {
+	var decoded CloudEvent
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, decoded)
+	return nil
+}
+
+func (p *recordingPublisher) Close() error { return nil }
+
+func (p *recordingPublisher) published() []CloudEvent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]CloudEvent(nil), p.events...)
+}
+
+func TestEmitter(t *testing.T) {
+	pub := &recordingPublisher{}
+	e := NewEmitter(pub, "/cert-tasks/test", 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Run(ctx)
+
+	task := &models.Task{ID: 42, Title: "Write report", Status: models.StatusTodo, WorkspaceID: "acme"}
+	e.Publish(ctx, models.EventTaskCreated, task)
+	task.Status = models.StatusDone // changed after queueing: must not leak into the first event
+	e.Publish(ctx, models.EventTaskCompleted, task)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(pub.published()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	events := pub.published()
+	if len(events) != 2 {
+		t.Fatalf("published %d events, want 2", len(events))
+	}
+
+	first := events[0]
+	if first.SpecVersion != "1.0" || first.Source != "/cert-tasks/test" || first.Subject != "42" || first.Workspace != "acme" {
+		t.Errorf("event attributes = %+v", first)
+	}
+	if first.Type != TypePrefix+"task.created" || first.EventType() != models.EventTaskCreated {
+		t.Errorf("type = %q, want the prefixed task.created", first.Type)
+	}
+	if first.Data.Status != models.StatusTodo {
+		t.Errorf("first event status = %q, want the state when it was published", first.Data.Status)
+	}
+	if events[1].EventType() != models.EventTaskCompleted || events[1].ID == first.ID {
+		t.Errorf("second event = %+v, want a distinct task.completed", events[1])
+	}
+}
+
+func TestEmitter_QueueFull(t *testing.T) {
+	pub := &recordingPublisher{}
+	e := NewEmitter(pub, "/cert-tasks", 1)
+
+	// Nothing is draining the queue, so the second event is dropped
+	// instead of blocking the caller
+	e.Publish(context.Background(), models.EventTaskCreated, &models.Task{ID: 1})
+	e.Publish(context.Background(), models.EventTaskCreated, &models.Task{ID: 2})
+	if n := len(e.queue); n != 1 {
+		t.Errorf("queued %d events, want 1", n)
+	}
+}
+
+func TestKafkaREST(t *testing.T) {
+	var gotPath, gotType string
+	var got kafkaRecords
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotType = r.URL.Path, r.Header.Get("Content-Type")
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &got)
+		w.WriteHeader(status)
+		io.WriteString(w, `{"error_code":40401,"message":"Topic not found"}`)
+	}))
+	defer srv.Close()
+
+	k := NewKafkaREST(srv.URL+"/", "cert-tasks.events", time.Second)
+	event := &CloudEvent{Subject: "42"}
+	if err := k.Publish(context.Background(), event, []byte(`{"specversion":"1.0"}`)); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if gotPath != "/topics/cert-tasks.events" || gotType != kafkaContentType {
+		t.Errorf("request = %s %s, want the topic with the JSON records type", gotPath, gotType)
+	}
+	if len(got.Records) != 1 || got.Records[0].Key != "42" || string(got.Records[0].Value) != `{"specversion":"1.0"}` {
+		t.Errorf("records = %+v, want one keyed by task ID", got.Records)
+	}
+
+	status = http.StatusNotFound
+	if err := k.Publish(context.Background(), event, []byte(`{}`)); err == nil {
+		t.Error("Publish() to a missing topic succeeded, want an error")
+	}
+}
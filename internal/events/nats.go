@@ -0,0 +1,49 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// natsFlushTimeout bounds sending buffered events on Close
+const natsFlushTimeout = 5 * time.Second
+
+// NATS publishes each event on "<prefix>.<event type>", e.g.
+// "cert-tasks.events.task.created", so consumers can subscribe to
+// "cert-tasks.events.>" or to single event types
+type NATS struct {
+	conn   *nats.Conn
+	prefix string
+}
+
+// NewNATS connects to the NATS server at url. The client reconnects by
+// itself and buffers events while disconnected.
+func NewNATS(url, prefix string) (*NATS, error) {
+	conn, err := nats.Connect(url,
+		nats.Name("cert-tasks"),
+		nats.MaxReconnects(-1),
+		nats.RetryOnFailedConnect(true),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to NATS: %w", err)
+	}
+	return &NATS{conn: conn, prefix: prefix}, nil
+}
+
+// Publish sends the event in structured mode
+func (n *NATS) Publish(ctx context.Context, event *CloudEvent, body []byte) error {
+	msg := nats.NewMsg(n.prefix + "." + string(event.EventType()))
+	msg.Header.Set("Content-Type", ContentType)
+	msg.Data = body
+	return n.conn.PublishMsg(msg)
+}
+
+// Close flushes buffered events and disconnects
+func (n *NATS) Close() error {
+	err := n.conn.FlushTimeout(natsFlushTimeout)
+	n.conn.Close()
+	return err
+}
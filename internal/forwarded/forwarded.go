@@ -0,0 +1,141 @@
+// Package forwarded derives the client address and scheme of requests that
+// arrive through reverse proxies from X-Forwarded-For and
+// X-Forwarded-Proto. The headers are believed only when the peer is one of
+// the trusted proxy networks; from anyone else they are ignored, so a
+// client cannot claim another address to dodge rate limits or disguise
+// itself in the audit log.
+package forwarded
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Header names
+const (
+	ForHeader   = "X-Forwarded-For"
+	ProtoHeader = "X-Forwarded-Proto"
+)
+
+type contextKey struct{}
+
+// info is what Middleware stores in the request context
+type info struct {
+	peer   string
+	scheme string
+}
+
+// Middleware replaces r.RemoteAddr with the client address from
+// X-Forwarded-For and records the scheme from X-Forwarded-Proto when the
+// request comes from a trusted proxy. X-Forwarded-For is read from the
+// right, skipping trusted proxies, so addresses a client prepends itself
+// are never used. Without trusted proxies it only records the scheme of
+// the connection.
+func Middleware(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			i := info{peer: r.RemoteAddr, scheme: "http"}
+			if r.TLS != nil {
+				i.scheme = "https"
+			}
+			if FromTrustedProxy(r.RemoteAddr, trusted) {
+				if client, ok := clientAddr(r, trusted); ok {
+					r = r.Clone(r.Context())
+					r.RemoteAddr = client
+				}
+				if scheme, ok := proto(r); ok {
+					i.scheme = scheme
+				}
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, i)))
+		})
+	}
+}
+
+// Scheme returns "https" or "http": the scheme the client used, as a
+// trusted proxy reported it, else that of the connection
+func Scheme(r *http.Request) string {
+	if i, ok := r.Context().Value(contextKey{}).(info); ok {
+		return i.scheme
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// Peer returns the address of the connection's other end, before
+// Middleware replaced r.RemoteAddr with the client's
+func Peer(r *http.Request) string {
+	if i, ok := r.Context().Value(contextKey{}).(info); ok {
+		return i.peer
+	}
+	return r.RemoteAddr
+}
+
+// FromTrustedProxy reports whether remoteAddr, a host:port as in
+// http.Request.RemoteAddr, is in one of the trusted networks
+func FromTrustedProxy(remoteAddr string, trusted []netip.Prefix) bool {
+	if len(trusted) == 0 {
+		return false
+	}
+	addrPort, err := netip.ParseAddrPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	return contains(trusted, addrPort.Addr().Unmap())
+}
+
+// clientAddr returns the nearest untrusted address in X-Forwarded-For, or
+// the farthest trusted one if every hop is trusted. An entry that is not
+// an IP address ends the walk at the hop before it.
+func clientAddr(r *http.Request, trusted []netip.Prefix) (string, bool) {
+	var hops []string
+	for _, v := range r.Header.Values(ForHeader) {
+		for _, hop := range strings.Split(v, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+
+	var client netip.Addr
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(hops[i])
+		if err != nil {
+			break
+		}
+		client = addr.Unmap()
+		if !contains(trusted, client) {
+			break
+		}
+	}
+	if !client.IsValid() {
+		return "", false
+	}
+	return client.String(), true
+}
+
+// proto returns the scheme in X-Forwarded-Proto. With several proxies the
+// first value, set by the one the client connected to, counts.
+func proto(r *http.Request) (string, bool) {
+	v := r.Header.Get(ProtoHeader)
+	if v == "" {
+		return "", false
+	}
+	first, _, _ := strings.Cut(v, ",")
+	switch scheme := strings.ToLower(strings.TrimSpace(first)); scheme {
+	case "http", "https":
+		return scheme, true
+	}
+	return "", false
+}
+
+func contains(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
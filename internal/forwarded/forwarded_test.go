@@ -0,0 +1,98 @@
+package forwarded
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	trusted := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("::1/128"),
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		tls        bool
+		header     http.Header
+		wantAddr   string
+		wantScheme string
+	}{
+		{
+			name:       "untrusted peer",
+			remoteAddr: "203.0.113.7:5000",
+			header:     http.Header{ForHeader: {"198.51.100.1"}, ProtoHeader: {"https"}},
+			wantAddr:   "203.0.113.7:5000",
+			wantScheme: "http",
+		},
+		{
+			name:       "trusted proxy",
+			remoteAddr: "10.0.0.2:5000",
+			header:     http.Header{ForHeader: {"198.51.100.1"}, ProtoHeader: {"HTTPS"}},
+			wantAddr:   "198.51.100.1",
+			wantScheme: "https",
+		},
+		{
+			name:       "chain of proxies",
+			remoteAddr: "10.0.0.2:5000",
+			header:     http.Header{ForHeader: {"198.51.100.1, 10.1.1.1", "10.0.0.3"}, ProtoHeader: {"https, http"}},
+			wantAddr:   "198.51.100.1",
+			wantScheme: "https",
+		},
+		{
+			name:       "spoofed hop before the client",
+			remoteAddr: "10.0.0.2:5000",
+			header:     http.Header{ForHeader: {"1.2.3.4, 198.51.100.1"}},
+			wantAddr:   "198.51.100.1",
+			wantScheme: "http",
+		},
+		{
+			name:       "only trusted hops",
+			remoteAddr: "10.0.0.2:5000",
+			header:     http.Header{ForHeader: {"10.9.9.9, 10.1.1.1"}},
+			wantAddr:   "10.9.9.9",
+			wantScheme: "http",
+		},
+		{
+			name:       "garbage hop",
+			remoteAddr: "10.0.0.2:5000",
+			header:     http.Header{ForHeader: {"unknown, 10.1.1.1"}},
+			wantAddr:   "10.1.1.1",
+			wantScheme: "http",
+		},
+		{
+			name:       "IPv6 proxy without headers",
+			remoteAddr: "[::1]:5000",
+			tls:        true,
+			header:     http.Header{ProtoHeader: {"gopher"}},
+			wantAddr:   "[::1]:5000",
+			wantScheme: "https",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/tasks", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header = tt.header
+			if tt.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+
+			var gotAddr, gotScheme, gotPeer string
+			Middleware(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotAddr, gotScheme, gotPeer = r.RemoteAddr, Scheme(r), Peer(r)
+			})).ServeHTTP(httptest.NewRecorder(), req)
+
+			if gotAddr != tt.wantAddr || gotScheme != tt.wantScheme {
+				t.Errorf("RemoteAddr, Scheme = %q, %q; want %q, %q", gotAddr, gotScheme, tt.wantAddr, tt.wantScheme)
+			}
+			if gotPeer != tt.remoteAddr {
+				t.Errorf("Peer = %q, want %q", gotPeer, tt.remoteAddr)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/light-bringer/cert-tasks/internal/auth"
+	"github.com/light-bringer/cert-tasks/internal/logging"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+// WithAPIKeys sets where CreateAPIKey stores new keys
+func WithAPIKeys(keys repository.APIKeyRepository) Option {
+	return func(h *TaskHandler) {
+		h.apiKeys = keys
+	}
+}
+
+// CreateAPIKey handles POST /apikeys. The response is the only time the
+// key's secret is returned.
+//
+//api:changelog 0.2.0 added endpoint POST /apikeys: Create a read or read_write API key, optionally bound to a workspace; requires the admin key
+func (h *TaskHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !h.apiKeysEnabled(w, r) {
+		return
+	}
+
+	var req models.CreateAPIKeyRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	if err := h.validator.ValidateAPIKey(&req); err != nil {
+		h.respondWithValidationError(w, r, err)
+		return
+	}
+
+	if req.WorkspaceID != "" && h.workspaces != nil {
+		if _, err := h.workspaces.GetWorkspace(r.Context(), req.WorkspaceID); err != nil {
+			h.respondWithWorkspaceError(w, r, err, "failed to look up workspace")
+			return
+		}
+	}
+
+	key, secret, err := auth.NewKey(req.Name, req.Scope, req.WorkspaceID)
+	if err != nil {
+		h.respondWithRepositoryError(w, r, err, "failed to generate API key")
+		return
+	}
+
+	created, err := h.apiKeys.CreateAPIKey(r.Context(), key)
+	if err != nil {
+		h.respondWithRepositoryError(w, r, err, "failed to create API key")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("api key created",
+		slog.Int64("key_id", created.ID),
+		slog.String("prefix", created.Prefix),
+		slog.String("scope", string(created.Scope)),
+		slog.String("workspace_id", created.WorkspaceID),
+	)
+	respondWithJSON(w, http.StatusCreated, models.CreatedAPIKey{APIKey: *created, Key: secret})
+}
+
+// ListAPIKeys handles GET /admin/apikeys. Secrets are never returned, only
+// their prefixes.
+//
+//api:changelog 0.2.0 added endpoint GET /admin/apikeys: List API keys with their prefixes; requires the admin key
+func (h *TaskHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if !h.apiKeysEnabled(w, r) {
+		return
+	}
+
+	keys, err := h.apiKeys.ListAPIKeys(r.Context())
+	if err != nil {
+		h.respondWithRepositoryError(w, r, err, "failed to list API keys")
+		return
+	}
+
+	setTotalCount(w, len(keys))
+	respondWithJSON(w, http.StatusOK, keys)
+}
+
+// RotateAPIKey handles POST /admin/apikeys/{id}/rotate. The key keeps its
+// ID, name, scope and workspace; the old secret stops working at once and
+// the new one is only returned here.
+//
+//api:changelog 0.2.0 added endpoint POST /admin/apikeys/{id}/rotate: Replace an API key's secret, invalidating the old one; requires the admin key
+func (h *TaskHandler) RotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !h.apiKeysEnabled(w, r) {
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.respondWithError(w, r, http.StatusBadRequest, CodeInvalidID, "invalid API key ID")
+		return
+	}
+
+	secret, prefix, err := auth.NewSecret()
+	if err != nil {
+		h.respondWithRepositoryError(w, r, err, "failed to generate API key")
+		return
+	}
+
+	rotated, err := h.apiKeys.RotateAPIKey(r.Context(), id, prefix, auth.Hash(secret))
+	if errors.Is(err, repository.ErrAPIKeyNotFound) {
+		h.respondWithError(w, r, http.StatusNotFound, CodeNotFound, "API key not found")
+		return
+	}
+	if err != nil {
+		h.respondWithRepositoryError(w, r, err, "failed to rotate API key")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("api key rotated",
+		slog.Int64("key_id", rotated.ID),
+		slog.String("prefix", rotated.Prefix),
+	)
+	respondWithJSON(w, http.StatusOK, models.CreatedAPIKey{APIKey: *rotated, Key: secret})
+}
+
+// apiKeysEnabled writes a 501 and returns false when no API key store is
+// configured
+func (h *TaskHandler) apiKeysEnabled(w http.ResponseWriter, r *http.Request) bool {
+	if h.apiKeys == nil {
+		h.respondWithError(w, r, http.StatusNotImplemented, CodeNotImplemented, "API keys are not enabled")
+		return false
+	}
+	return true
+}
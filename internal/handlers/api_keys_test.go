@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/light-bringer/cert-tasks/internal/auth"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+func TestTaskHandler_CreateAPIKey(t *testing.T) {
+	createKey := func(handler *TaskHandler, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.CreateAPIKey(rec, httptest.NewRequest("POST", "/apikeys", strings.NewReader(body)))
+		return rec
+	}
+
+	t.Run("created", func(t *testing.T) {
+		repo := repository.NewMemoryRepository()
+		handler := NewTaskHandler(repo, WithAPIKeys(repo))
+
+		rec := createKey(handler, `{"name":"ci","scope":"read"}`)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("status = %v, want %v: %s", rec.Code, http.StatusCreated, rec.Body)
+		}
+		if strings.Contains(rec.Body.String(), "hash") {
+			t.Errorf("response exposes the hash: %s", rec.Body)
+		}
+
+		var created models.CreatedAPIKey
+		json.NewDecoder(rec.Body).Decode(&created)
+		if created.ID == 0 || created.Name != "ci" || created.Scope != models.ScopeRead || created.Key == "" {
+			t.Fatalf("created = %+v, want ID, name, scope and secret", created)
+		}
+
+		// Only the hash is stored, and it authenticates the returned secret
+		key, err := auth.New(repo).Authenticate(context.Background(), created.Key)
+		if err != nil || key.ID != created.ID {
+			t.Errorf("Authenticate(secret) = %+v, %v; want key %d", key, err, created.ID)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		repo := repository.NewMemoryRepository()
+		handler := NewTaskHandler(repo, WithAPIKeys(repo))
+
+		rec := createKey(handler, `{"name":"","scope":"admin"}`)
+		if rec.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("status = %v, want %v", rec.Code, http.StatusUnprocessableEntity)
+		}
+		var errResp ErrorResponse
+		json.NewDecoder(rec.Body).Decode(&errResp)
+		if len(errResp.Fields) != 2 {
+			t.Errorf("fields = %+v, want name and scope violations", errResp.Fields)
+		}
+	})
+
+	t.Run("not enabled", func(t *testing.T) {
+		handler := NewTaskHandler(repository.NewMemoryRepository())
+		if rec := createKey(handler, `{"name":"ci","scope":"read"}`); rec.Code != http.StatusNotImplemented {
+			t.Errorf("status = %v, want %v", rec.Code, http.StatusNotImplemented)
+		}
+	})
+}
+
+func TestTaskHandler_RotateAPIKey(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	handler := NewTaskHandler(repo, WithAPIKeys(repo))
+	r := chi.NewRouter()
+	r.Post("/admin/apikeys/{id}/rotate", handler.RotateAPIKey)
+
+	key, oldSecret, _ := auth.NewKey("ci", models.ScopeRead, "")
+	stored, _ := repo.CreateAPIKey(ctx, key)
+
+	rotate := func(id string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("POST", "/admin/apikeys/"+id+"/rotate", nil))
+		return rec
+	}
+
+	rec := rotate("1")
+	var rotated models.CreatedAPIKey
+	json.NewDecoder(rec.Body).Decode(&rotated)
+	if rec.Code != http.StatusOK || rotated.ID != stored.ID || rotated.Name != "ci" || rotated.Key == "" || rotated.Key == oldSecret {
+		t.Fatalf("rotate = %v %+v", rec.Code, rotated)
+	}
+
+	authenticator := auth.New(repo)
+	if _, err := authenticator.Authenticate(ctx, oldSecret); err != auth.ErrInvalidKey {
+		t.Errorf("old secret: error = %v, want ErrInvalidKey", err)
+	}
+	if got, err := authenticator.Authenticate(ctx, rotated.Key); err != nil || got.Scope != models.ScopeRead {
+		t.Errorf("new secret: %+v, %v", got, err)
+	}
+
+	if rec := rotate("2"); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown key: status = %v, want %v", rec.Code, http.StatusNotFound)
+	}
+	if rec := rotate("abc"); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid ID: status = %v, want %v", rec.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/logging"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+// confirmationTTL is how long a bulk delete preview can be confirmed
+const confirmationTTL = 5 * time.Minute
+
+// errMatchesChanged aborts a confirmed bulk delete whose filter no longer
+// matches the previewed tasks
+var errMatchesChanged = errors.New("matching tasks changed since the preview")
+
+// bulkFilter selects the tasks a bulk delete applies to
+type bulkFilter struct {
+	status models.TaskStatus
+	query  string
+}
+
+// pendingDelete is a previewed bulk delete awaiting confirmation
+type pendingDelete struct {
+	tenant  string
+	owner   string
+	filter  bulkFilter
+	ids     []int64
+	expires time.Time
+}
+
+// confirmations holds single-use bulk delete tokens
+type confirmations struct {
+	now func() time.Time
+
+	mu      sync.Mutex
+	pending map[string]pendingDelete
+}
+
+func newConfirmations() *confirmations {
+	return &confirmations{
+		now:     time.Now,
+		pending: make(map[string]pendingDelete),
+	}
+}
+
+// issue stores p under a new random token and returns the token with its
+// expiry. Expired entries are dropped on the way.
+func (c *confirmations) issue(p pendingDelete) (string, time.Time) {
+	var b [16]byte
+	rand.Read(b[:])
+	token := hex.EncodeToString(b[:])
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for t, old := range c.pending {
+		if now.After(old.expires) {
+			delete(c.pending, t)
+		}
+	}
+	p.expires = now.Add(confirmationTTL)
+	c.pending[token] = p
+	return token, p.expires
+}
+
+// redeem removes and returns the pending delete for token. It fails for
+// unknown, expired and already redeemed tokens, so a confirmed request
+// cannot be replayed.
+func (c *confirmations) redeem(token string) (pendingDelete, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p, ok := c.pending[token]
+	delete(c.pending, token)
+	if !ok || c.now().After(p.expires) {
+		return pendingDelete{}, false
+	}
+	return p, true
+}
+
+// DeleteTasks handles DELETE /tasks, a two-step bulk delete. Without
+// ?confirm= it only previews: it reports how many tasks match the filter
+// and returns a short-lived, single-use confirmation token. Repeating the
+// request with ?confirm=<token> deletes the previewed tasks, provided the
+// filter still matches exactly the same set, and returns an X-Undo-Token
+// that puts them back.
+//
+//api:changelog 0.2.0 added endpoint DELETE /tasks: Bulk delete by filter, previewed first and confirmed with a token
+//api:changelog 0.2.0 added parameter DELETE /tasks?status: Delete only tasks with this status
+//api:changelog 0.2.0 added parameter DELETE /tasks?q: Delete only tasks matching this search query
+//api:changelog 0.2.0 added parameter DELETE /tasks?confirm: Confirmation token from the preview; executes the delete
+//api:changelog 0.2.0 added error invalid_confirmation: Bulk delete token is unknown, expired, used, or the matching tasks changed
+func (h *TaskHandler) DeleteTasks(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := bulkFilter{
+		status: models.TaskStatus(q.Get("status")),
+		query:  q.Get("q"),
+	}
+
+	if filter.status == "" && filter.query == "" {
+		h.respondWithError(w, r, http.StatusBadRequest, CodeInvalidQuery, "bulk delete requires a status or q filter")
+		return
+	}
+	if filter.status != "" && filter.status != models.StatusTodo && filter.status != models.StatusDone {
+		h.respondWithError(w, r, http.StatusBadRequest, CodeInvalidQuery, "status must be todo or done")
+		return
+	}
+	if filter.query != "" && !h.repo.Capabilities().FullTextSearch {
+		h.respondWithError(w, r, http.StatusNotImplemented, CodeNotImplemented, "search is not supported by the storage backend")
+		return
+	}
+
+	tenant, owner := tenantFromRequest(r), repository.OwnerFromContext(r.Context())
+	token := q.Get("confirm")
+	if token == "" {
+		ids, err := matchTasks(r.Context(), h.repo, filter)
+		if err != nil {
+			h.respondWithRepositoryError(w, r, err, "failed to match tasks")
+			return
+		}
+		token, expires := h.confirmations.issue(pendingDelete{tenant: tenant, owner: owner, filter: filter, ids: ids})
+		respondWithJSON(w, http.StatusOK, models.BulkDeletePreview{
+			Count:     len(ids),
+			Token:     token,
+			ExpiresAt: expires,
+		})
+		return
+	}
+
+	pending, ok := h.confirmations.redeem(token)
+	if !ok || pending.tenant != tenant || pending.owner != owner || pending.filter != filter {
+		h.respondWithError(w, r, http.StatusConflict, CodeInvalidConfirmation, "confirmation token is invalid or expired; request a new preview")
+		return
+	}
+
+	// Matching and deleting form one transaction, so either every
+	// previewed task goes or none does
+	var deleted []*models.Task
+	err := h.repo.WithTx(r.Context(), func(tx repository.TaskRepository) error {
+		ids, err := matchTasks(r.Context(), tx, filter)
+		if err != nil {
+			return err
+		}
+		if !slices.Equal(pending.ids, ids) {
+			return errMatchesChanged
+		}
+		// Keep the tasks as they were for undo
+		tasks, err := tx.GetByIDs(r.Context(), ids)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := tx.Delete(r.Context(), id); err != nil {
+				return err
+			}
+		}
+		for _, task := range tasks {
+			deleted = append(deleted, copyTask(task))
+		}
+		return nil
+	})
+	if errors.Is(err, errMatchesChanged) {
+		h.respondWithError(w, r, http.StatusConflict, CodeInvalidConfirmation, "matching tasks changed since the preview; request a new preview")
+		return
+	}
+	if err != nil {
+		h.respondWithRepositoryError(w, r, err, "failed to delete tasks")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("bulk delete",
+		slog.String("status", string(filter.status)),
+		slog.String("q", filter.query),
+		slog.Int("deleted", len(deleted)),
+	)
+	h.undo.issue(w, r, deleted)
+	respondWithJSON(w, http.StatusOK, models.BulkDeleteResult{Deleted: len(deleted)})
+}
+
+// matchTasks returns the IDs of the tasks in repo selected by filter,
+// ascending
+func matchTasks(ctx context.Context, repo repository.TaskRepository, filter bulkFilter) ([]int64, error) {
+	var tasks []*models.Task
+	var err error
+	if filter.query != "" {
+		tasks, err = repo.Search(ctx, filter.query)
+	} else {
+		tasks, err = repo.GetAll(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []int64{}
+	for _, t := range tasks {
+		if filter.status == "" || t.Status == filter.status {
+			ids = append(ids, t.ID)
+		}
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+// bulkDelete calls DeleteTasks with the given query string
+func bulkDelete(handler *TaskHandler, query string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler.DeleteTasks(rec, httptest.NewRequest("DELETE", "/tasks?"+query, nil))
+	return rec
+}
+
+// preview runs the first step of a bulk delete and returns the preview
+func preview(t *testing.T, handler *TaskHandler, query string) models.BulkDeletePreview {
+	t.Helper()
+	rec := bulkDelete(handler, query)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("preview status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var p models.BulkDeletePreview
+	if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// seedBulk creates two done tasks and one todo task
+func seedBulk(repo *repository.MemoryRepository) {
+	ctx := context.Background()
+	for _, title := range []string{"Old report", "Old invoice", "Current work"} {
+		task, _ := repo.Create(ctx, &models.Task{Title: title})
+		if title != "Current work" {
+			repo.Update(ctx, task.ID, &models.Task{Title: title, Status: models.StatusDone})
+		}
+	}
+}
+
+func TestTaskHandler_DeleteTasks(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("preview then confirm", func(t *testing.T) {
+		repo := repository.NewMemoryRepository()
+		seedBulk(repo)
+		handler := NewTaskHandler(repo)
+
+		p := preview(t, handler, "status=done")
+		if p.Count != 2 || p.Token == "" {
+			t.Fatalf("preview = %+v, want count 2 and a token", p)
+		}
+		if all, _ := repo.GetAll(ctx); len(all) != 3 {
+			t.Fatalf("preview deleted tasks: %d left, want 3", len(all))
+		}
+
+		rec := bulkDelete(handler, "status=done&confirm="+p.Token)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("confirm status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body)
+		}
+		var result models.BulkDeleteResult
+		json.NewDecoder(rec.Body).Decode(&result)
+		if result.Deleted != 2 {
+			t.Errorf("deleted = %d, want 2", result.Deleted)
+		}
+		if all, _ := repo.GetAll(ctx); len(all) != 1 || all[0].Title != "Current work" {
+			t.Errorf("remaining tasks = %+v", all)
+		}
+
+		// The token is single-use
+		if rec := bulkDelete(handler, "status=done&confirm="+p.Token); rec.Code != http.StatusConflict {
+			t.Errorf("replay status = %v, want %v", rec.Code, http.StatusConflict)
+		}
+	})
+
+	t.Run("search and status filters combine", func(t *testing.T) {
+		repo := repository.NewMemoryRepository()
+		seedBulk(repo)
+		handler := NewTaskHandler(repo)
+
+		if p := preview(t, handler, "status=done&q=invoice"); p.Count != 1 {
+			t.Errorf("count = %d, want 1", p.Count)
+		}
+	})
+
+	t.Run("matching set changed", func(t *testing.T) {
+		repo := repository.NewMemoryRepository()
+		seedBulk(repo)
+		handler := NewTaskHandler(repo)
+
+		p := preview(t, handler, "status=done")
+		repo.Update(ctx, 3, &models.Task{Title: "Current work", Status: models.StatusDone})
+
+		rec := bulkDelete(handler, "status=done&confirm="+p.Token)
+		if rec.Code != http.StatusConflict {
+			t.Fatalf("status = %v, want %v", rec.Code, http.StatusConflict)
+		}
+		if all, _ := repo.GetAll(ctx); len(all) != 3 {
+			t.Errorf("%d tasks left, want 3", len(all))
+		}
+	})
+
+	t.Run("token bound to filter", func(t *testing.T) {
+		repo := repository.NewMemoryRepository()
+		seedBulk(repo)
+		handler := NewTaskHandler(repo)
+
+		p := preview(t, handler, "status=done")
+		if rec := bulkDelete(handler, "status=todo&confirm="+p.Token); rec.Code != http.StatusConflict {
+			t.Errorf("status = %v, want %v", rec.Code, http.StatusConflict)
+		}
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		repo := repository.NewMemoryRepository()
+		seedBulk(repo)
+		handler := NewTaskHandler(repo)
+		now := time.Now()
+		handler.confirmations.now = func() time.Time { return now }
+
+		p := preview(t, handler, "status=done")
+		now = now.Add(confirmationTTL + time.Second)
+
+		rec := bulkDelete(handler, "status=done&confirm="+p.Token)
+		if rec.Code != http.StatusConflict {
+			t.Fatalf("status = %v, want %v", rec.Code, http.StatusConflict)
+		}
+		var errResp ErrorResponse
+		json.NewDecoder(rec.Body).Decode(&errResp)
+		if errResp.Code != CodeInvalidConfirmation {
+			t.Errorf("code = %q, want %q", errResp.Code, CodeInvalidConfirmation)
+		}
+	})
+
+	t.Run("invalid filters", func(t *testing.T) {
+		handler := NewTaskHandler(repository.NewMemoryRepository())
+		for _, query := range []string{"", "status=pending", "confirm=abc"} {
+			if rec := bulkDelete(handler, query); rec.Code != http.StatusBadRequest {
+				t.Errorf("%q: status = %v, want %v", query, rec.Code, http.StatusBadRequest)
+			}
+		}
+	})
+}
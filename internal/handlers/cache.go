@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/logging"
+)
+
+// WithCacheControl sends value as the Cache-Control header of task lists.
+// Without it no Cache-Control header is sent.
+func WithCacheControl(value string) Option {
+	return func(h *TaskHandler) {
+		h.cacheControl = value
+	}
+}
+
+// notModified sets the caching headers of a task list and writes 304 Not
+// Modified if the request's If-Modified-Since shows that no task has
+// changed since. If-None-Match takes precedence, so the date is ignored
+// when it is present. It runs before the tasks are read, so a concurrent
+// write makes Last-Modified early rather than late.
+//
+//api:changelog 0.2.0 added header Last-Modified: GET /tasks reports when a task was last created, changed or deleted
+//api:changelog 0.2.0 added header If-Modified-Since: GET /tasks answers 304 Not Modified when no task has changed since
+//api:changelog 0.2.0 added header Cache-Control: GET /tasks sends the configured CACHE_CONTROL policy
+func (h *TaskHandler) notModified(w http.ResponseWriter, r *http.Request) bool {
+	if h.cacheControl != "" {
+		w.Header().Set("Cache-Control", h.cacheControl)
+	}
+	// Lists differ between workspaces at the same URL
+	w.Header().Add("Vary", WorkspaceHeader)
+
+	modified, err := h.repo.LastModified(r.Context())
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("reading last modified time", slog.Any("error", err))
+		return false
+	}
+	w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+
+	if r.Header.Get("If-None-Match") != "" {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || modified.Truncate(time.Second).After(since) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+func TestTaskHandler_ListTasks_LastModified(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	handler := NewTaskHandler(repo, WithCacheControl("private, no-cache"))
+	repo.Create(ctx, &models.Task{Title: "Task"})
+
+	list := func(header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/tasks", nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		handler.ListTasks(rec, req)
+		return rec
+	}
+
+	rec := list(nil)
+	lastModified := rec.Header().Get("Last-Modified")
+	if rec.Code != http.StatusOK || lastModified == "" || rec.Header().Get("Cache-Control") != "private, no-cache" {
+		t.Fatalf("status %d, headers %v", rec.Code, rec.Header())
+	}
+
+	if rec := list(http.Header{"If-Modified-Since": {lastModified}}); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("If-Modified-Since current: status %d, want 304", rec.Code)
+	}
+	earlier := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	if rec := list(http.Header{"If-Modified-Since": {earlier}}); rec.Code != http.StatusOK {
+		t.Errorf("If-Modified-Since an hour ago: status %d, want 200", rec.Code)
+	}
+	// If-None-Match wins over the date
+	if rec := list(http.Header{"If-Modified-Since": {lastModified}, "If-None-Match": {`"stale"`}}); rec.Code != http.StatusOK {
+		t.Errorf("stale If-None-Match with current date: status %d, want 200", rec.Code)
+	}
+
+	// A change after the date is reported, whatever the clock resolution
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+	repo.Create(ctx, &models.Task{Title: "Another"})
+	if rec := list(http.Header{"If-Modified-Since": {lastModified}}); rec.Code != http.StatusOK {
+		t.Errorf("If-Modified-Since before a create: status %d, want 200", rec.Code)
+	}
+}
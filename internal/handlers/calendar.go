@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"cmp"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/light-bringer/cert-tasks/internal/calendar"
+	"github.com/light-bringer/cert-tasks/internal/logging"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+// WithCalendar enables the iCalendar feed, protected by tokens from tokens
+func WithCalendar(tokens *calendar.Tokens) Option {
+	return func(h *TaskHandler) {
+		h.calendar = tokens
+	}
+}
+
+// CalendarToken handles GET /calendar/token. The token covers the tasks
+// the caller can see: those of the request's workspace, limited to the
+// caller's own tasks for JWT users.
+//
+//api:changelog 0.2.0 added endpoint GET /calendar/token: Token and path of the caller's iCalendar feed
+func (h *TaskHandler) CalendarToken(w http.ResponseWriter, r *http.Request) {
+	if !h.calendarEnabled(w, r) {
+		return
+	}
+
+	ctx := r.Context()
+	workspace := cmp.Or(repository.WorkspaceFromContext(ctx), models.DefaultWorkspace)
+	token := h.calendar.Issue(workspace, repository.OwnerFromContext(ctx))
+	respondWithJSON(w, http.StatusOK, models.CalendarFeed{
+		Token: token,
+		Path:  "/calendar.ics?token=" + url.QueryEscape(token),
+	})
+}
+
+// CalendarFeed handles GET /calendar.ics, the tasks with a due date as an
+// iCalendar feed. It is authenticated by the ?token= from CalendarToken
+// rather than an API key, since calendar apps cannot send headers.
+//
+//api:changelog 0.2.0 added endpoint GET /calendar.ics: iCalendar feed of tasks with due dates, for calendar apps to subscribe to
+//api:changelog 0.2.0 added parameter GET /calendar.ics?token: Feed token from GET /calendar/token
+//api:changelog 0.2.0 added parameter GET /calendar.ics?component: vevent (default) lists tasks as events, vtodo as to-dos
+func (h *TaskHandler) CalendarFeed(w http.ResponseWriter, r *http.Request) {
+	if !h.calendarEnabled(w, r) {
+		return
+	}
+
+	q := r.URL.Query()
+	workspace, owner, err := h.calendar.Verify(q.Get("token"))
+	if err != nil {
+		h.respondWithError(w, r, http.StatusUnauthorized, CodeUnauthorized, "missing or invalid calendar token")
+		return
+	}
+	component := calendar.Component(cmp.Or(q.Get("component"), string(calendar.ComponentEvent)))
+	if !component.IsValid() {
+		h.respondWithError(w, r, http.StatusBadRequest, CodeInvalidQuery, "component must be vevent or vtodo")
+		return
+	}
+
+	ctx := repository.WithOwner(repository.WithWorkspace(r.Context(), workspace), owner)
+	tasks, err := h.repo.GetAll(ctx)
+	if err != nil {
+		h.respondWithRepositoryError(w, r, err, "failed to retrieve tasks")
+		return
+	}
+	slices.SortFunc(tasks, func(a, b *models.Task) int { return cmp.Compare(a.ID, b.ID) })
+
+	w.Header().Set("Content-Type", calendar.ContentType)
+	w.Header().Set("Content-Disposition", `inline; filename="tasks.ics"`)
+	w.WriteHeader(http.StatusOK)
+	if err := calendar.Write(w, "Tasks ("+workspace+")", tasks, component); err != nil {
+		logging.FromContext(r.Context()).Warn("writing calendar failed", slog.Any("error", err))
+	}
+}
+
+// calendarEnabled writes a 501 response and returns false when the
+// calendar feed is not configured
+func (h *TaskHandler) calendarEnabled(w http.ResponseWriter, r *http.Request) bool {
+	if h.calendar == nil {
+		h.respondWithError(w, r, http.StatusNotImplemented, CodeNotImplemented, "calendar feed is not enabled")
+		return false
+	}
+	return true
+}
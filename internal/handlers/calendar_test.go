@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/light-bringer/cert-tasks/internal/calendar"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+func TestTaskHandler_Calendar(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	handler := NewTaskHandler(repo, WithCalendar(calendar.NewTokens([]byte("0123456789abcdef0123456789abcdef"))))
+
+	// Tasks are created through the API, as alice and bob
+	create := func(owner, body string) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/tasks", strings.NewReader(body))
+		handler.CreateTask(rec, req.WithContext(repository.WithOwner(req.Context(), owner)))
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("create status = %v: %s", rec.Code, rec.Body)
+		}
+	}
+	create("alice", `{"title":"Pay rent","due_at":"2024-02-01T17:00:00+01:00"}`)
+	create("alice", `{"title":"Someday"}`)
+	create("bob", `{"title":"Bob's deadline","due_at":"2024-02-02T09:00:00Z"}`)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/calendar/token", nil)
+	handler.CalendarToken(rec, req.WithContext(repository.WithOwner(req.Context(), "alice")))
+	var feed models.CalendarFeed
+	json.NewDecoder(rec.Body).Decode(&feed)
+	if rec.Code != http.StatusOK || feed.Token == "" || !strings.HasPrefix(feed.Path, "/calendar.ics?token=") {
+		t.Fatalf("token response = %v %+v", rec.Code, feed)
+	}
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.CalendarFeed(rec, httptest.NewRequest("GET", path, nil))
+		return rec
+	}
+
+	rec = get(feed.Path)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != calendar.ContentType {
+		t.Fatalf("feed = %v %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "SUMMARY:Pay rent\r\n") || !strings.Contains(body, "DTSTART:20240201T160000Z\r\n") {
+		t.Errorf("feed lacks alice's task:\n%s", body)
+	}
+	if strings.Contains(body, "Someday") || strings.Contains(body, "Bob") {
+		t.Errorf("feed lists tasks without a due date or of another user:\n%s", body)
+	}
+
+	if rec := get(feed.Path + "&component=vtodo"); !strings.Contains(rec.Body.String(), "BEGIN:VTODO") {
+		t.Errorf("vtodo feed:\n%s", rec.Body)
+	}
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/calendar.ics", http.StatusUnauthorized},
+		{"/calendar.ics?token=forged.token", http.StatusUnauthorized},
+		{feed.Path + "&component=vjournal", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if rec := get(tt.path); rec.Code != tt.status {
+			t.Errorf("%s: status = %v, want %v", tt.path, rec.Code, tt.status)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	NewTaskHandler(repo).CalendarFeed(rec, httptest.NewRequest("GET", feed.Path, nil))
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("unconfigured feed status = %v, want %v", rec.Code, http.StatusNotImplemented)
+	}
+
+	// Due dates are kept in UTC and can be cleared by an update
+	tasks, _ := repo.GetAll(repository.WithOwner(context.Background(), "bob"))
+	if len(tasks) != 1 || tasks[0].DueAt == nil || tasks[0].DueAt.Location().String() != "UTC" {
+		t.Fatalf("bob's tasks = %+v", tasks)
+	}
+	updated, _ := repo.Update(context.Background(), tasks[0].ID, &models.Task{Title: "Bob's deadline", Status: models.StatusTodo})
+	if updated == nil || updated.DueAt != nil {
+		t.Errorf("task = %+v after an update without a due date", updated)
+	}
+}
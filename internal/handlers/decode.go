@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultMaxBodyBytes is the request body limit used unless
+// WithMaxBodyBytes sets another
+const DefaultMaxBodyBytes = 1 << 20
+
+// WithMaxBodyBytes limits request bodies to n bytes; larger bodies are
+// rejected with 413 without being read past the limit
+func WithMaxBodyBytes(n int64) Option {
+	return func(h *TaskHandler) {
+		h.maxBodyBytes = n
+	}
+}
+
+// decodeJSON decodes a request body holding exactly one JSON document into
+// v. Bodies over the size limit, unknown fields and trailing data are
+// rejected; on failure the error response has been written and false is
+// returned.
+//
+//api:changelog 0.2.0 added error body_too_large: Request bodies over the configured limit (1 MiB by default) return 413
+//api:changelog 0.2.0 changed error invalid_json: Unknown fields and data after the JSON document are rejected, and the message says what is wrong
+func (h *TaskHandler) decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, h.maxBodyBytes))
+	dec.DisallowUnknownFields()
+
+	var tooLarge *http.MaxBytesError
+	err := dec.Decode(v)
+	if err == nil {
+		// Anything but whitespace after the document is an error
+		if _, err = dec.Token(); errors.Is(err, io.EOF) {
+			return true
+		} else if !errors.As(err, &tooLarge) {
+			err = errTrailingData
+		}
+	}
+
+	if errors.As(err, &tooLarge) {
+		h.respondWithError(w, r, http.StatusRequestEntityTooLarge, CodeBodyTooLarge,
+			fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
+		return false
+	}
+
+	h.respondWithError(w, r, http.StatusBadRequest, CodeInvalidJSON, "invalid JSON payload: "+describeDecodeError(err))
+	return false
+}
+
+// errTrailingData reports data after the first JSON document
+var errTrailingData = errors.New("unexpected data after the JSON document")
+
+// describeDecodeError turns a decoding error into a message for clients
+func describeDecodeError(err error) string {
+	var syntax *json.SyntaxError
+	var typ *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, io.EOF):
+		return "request body is empty"
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "request body ends in the middle of the JSON document"
+	case errors.As(err, &syntax):
+		return fmt.Sprintf("%s at byte %d", syntax.Error(), syntax.Offset)
+	case errors.As(err, &typ) && typ.Field != "":
+		return fmt.Sprintf("field %q cannot be a JSON %s", typ.Field, typ.Value)
+	case errors.As(err, &typ):
+		return "expected a JSON object, got " + typ.Value
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no error type for unknown fields
+		return "unknown field " + strings.TrimPrefix(err.Error(), "json: unknown field ")
+	default:
+		return err.Error()
+	}
+}
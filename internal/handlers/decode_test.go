@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+func TestTaskHandler_DecodeJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantStatus  int
+		wantCode    string
+		wantMessage string
+	}{
+		{"valid", `{"title":"Task","description":"Body"}`, http.StatusCreated, "", ""},
+		{"trailing whitespace", "{\"title\":\"Task\"}\n\n", http.StatusCreated, "", ""},
+		{"empty body", "", http.StatusBadRequest, CodeInvalidJSON, "request body is empty"},
+		{"unknown field", `{"title":"Task","priority":1}`, http.StatusBadRequest, CodeInvalidJSON, `unknown field "priority"`},
+		{"trailing garbage", `{"title":"Task"}garbage`, http.StatusBadRequest, CodeInvalidJSON, "unexpected data after the JSON document"},
+		{"stray brace", `{"title":"Task"}}`, http.StatusBadRequest, CodeInvalidJSON, "unexpected data after the JSON document"},
+		{"second document", `{"title":"Task"}{"title":"Other"}`, http.StatusBadRequest, CodeInvalidJSON, "unexpected data after the JSON document"},
+		{"truncated", `{"title":"Ta`, http.StatusBadRequest, CodeInvalidJSON, "ends in the middle"},
+		{"syntax error", `{"title":}`, http.StatusBadRequest, CodeInvalidJSON, "at byte 10"},
+		{"wrong type", `{"title":42}`, http.StatusBadRequest, CodeInvalidJSON, `field "title" cannot be a JSON number`},
+		{"not an object", `["Task"]`, http.StatusBadRequest, CodeInvalidJSON, "expected a JSON object, got array"},
+		{"too large", `{"title":"` + strings.Repeat("x", 100) + `"}`, http.StatusRequestEntityTooLarge, CodeBodyTooLarge, "exceeds 64 bytes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewTaskHandler(repository.NewMemoryRepository(), WithMaxBodyBytes(64))
+			rec := httptest.NewRecorder()
+			handler.CreateTask(rec, httptest.NewRequest("POST", "/tasks", strings.NewReader(tt.body)))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %v, want %v: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantCode == "" {
+				return
+			}
+			var errResp ErrorResponse
+			json.NewDecoder(rec.Body).Decode(&errResp)
+			if errResp.Code != tt.wantCode {
+				t.Errorf("code = %q, want %q", errResp.Code, tt.wantCode)
+			}
+			if !strings.Contains(errResp.Message, tt.wantMessage) {
+				t.Errorf("message = %q, want it to contain %q", errResp.Message, tt.wantMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+func TestTaskHandler_Due(t *testing.T) {
+	handler := NewTaskHandler(repository.NewMemoryRepository())
+
+	create := func(body, timezone string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/tasks", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if timezone != "" {
+			req.Header.Set(TimezoneHeader, timezone)
+		}
+		rec := httptest.NewRecorder()
+		handler.CreateTask(rec, req)
+		return rec
+	}
+
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skip("no time zone database:", err)
+	}
+	tomorrow := time.Now().In(tokyo).AddDate(0, 0, 1)
+	want := time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 9, 0, 0, 0, tokyo)
+
+	rec := create(`{"title":"Renew cert","due":"tomorrow 9am"}`, "Asia/Tokyo")
+	var task models.Task
+	json.NewDecoder(rec.Body).Decode(&task)
+	if rec.Code != http.StatusCreated || task.DueAt == nil || !task.DueAt.Equal(want) {
+		t.Errorf("create: status %d, due_at %v, want %v", rec.Code, task.DueAt, want)
+	}
+
+	tests := []struct {
+		name, body, timezone string
+		field, code          string
+		candidates           int
+	}{
+		{"ambiguous", `{"title":"t","due":"03/11"}`, "", "due", "ambiguous", 2},
+		{"unparseable", `{"title":"t","due":"someday"}`, "", "due", "format", 0},
+		{"with due_at", `{"title":"t","due":"tomorrow","due_at":"2026-01-01T00:00:00Z"}`, "", "due", "exclusive", 0},
+		{"unknown time zone", `{"title":"t","due":"tomorrow"}`, "Mars/Olympus_Mons", TimezoneHeader, "format", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := create(tt.body, tt.timezone)
+			var errResp ErrorResponse
+			json.NewDecoder(rec.Body).Decode(&errResp)
+			if rec.Code != http.StatusUnprocessableEntity || len(errResp.Fields) != 1 {
+				t.Fatalf("status %d, body %+v", rec.Code, errResp)
+			}
+			f := errResp.Fields[0]
+			if f.Field != tt.field || f.Code != tt.code || len(f.Candidates) != tt.candidates {
+				t.Errorf("field error = %+v, want %s %s with %d candidates", f, tt.field, tt.code, tt.candidates)
+			}
+		})
+	}
+}
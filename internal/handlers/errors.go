@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/light-bringer/cert-tasks/internal/content"
+	"github.com/light-bringer/cert-tasks/internal/i18n"
+	"github.com/light-bringer/cert-tasks/internal/logging"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+	"github.com/light-bringer/cert-tasks/internal/requestid"
+	"github.com/light-bringer/cert-tasks/internal/service"
+	"github.com/light-bringer/cert-tasks/internal/validation"
+)
+
+// Machine-readable error codes returned in ErrorResponse.Code
+const (
+	CodeInvalidJSON         = "invalid_json"
+	CodeInvalidCSV          = "invalid_csv"
+	CodeBodyTooLarge        = "body_too_large"
+	CodeInvalidID           = "invalid_id"
+	CodeInvalidQuery        = "invalid_query"
+	CodeValidationFailed    = "validation_failed"
+	CodeContentRejected     = "content_rejected"
+	CodeNotFound            = "not_found"
+	CodeWorkspaceNotFound   = "workspace_not_found"
+	CodeMethodNotAllowed    = "method_not_allowed"
+	CodeLinkTargetNotFound  = "link_target_not_found"
+	CodeSelfLink            = "self_link"
+	CodeConflict            = "conflict"
+	CodePreconditionFailed  = "precondition_failed"
+	CodeTaskLimitReached    = "task_limit_reached"
+	CodeNotImplemented      = "not_implemented"
+	CodeSearchUnavailable   = "search_unavailable"
+	CodeInvalidConfirmation = "invalid_confirmation"
+	CodeShuttingDown        = "shutting_down"
+	CodeRequestTimeout      = "request_timeout"
+	CodeMaintenance         = "maintenance"
+	CodeRateLimited         = "rate_limited"
+	CodeUnauthorized        = "unauthorized"
+	CodeForbidden           = "forbidden"
+	CodeInternal            = "internal_error"
+)
+
+// ErrorResponse represents an error response
+//
+//api:changelog 0.2.0 changed error ErrorResponse: Errors carry a machine-readable code, message and per-field details instead of a single error string
+//api:changelog 0.2.0 added field ErrorResponse.request_id: ID of the failed request, matching the X-Request-ID header
+type ErrorResponse struct {
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	Fields    []FieldError `json:"fields,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+// FieldError describes a problem with a single request field, so clients
+// can map errors onto form inputs
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+
+	// Candidates lists the values an ambiguous input could mean, so a
+	// client can ask which was meant
+	Candidates []string `json:"candidates,omitempty"`
+
+	// params fill the placeholders of the rule's translated message
+	params map[string]string
+}
+
+// ProblemDetails is an RFC 7807 application/problem+json error body. The
+// code, fields and request_id members are extensions carrying the same
+// information as ErrorResponse.
+type ProblemDetails struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	Fields    []FieldError `json:"fields,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+// problemTypeBase prefixes the error code to form the problem type URI
+const problemTypeBase = "/problems/"
+
+// NotFound handles requests for unknown routes
+func (h *TaskHandler) NotFound(w http.ResponseWriter, r *http.Request) {
+	h.respondWithError(w, r, http.StatusNotFound, CodeNotFound, "resource not found")
+}
+
+// MethodNotAllowed handles requests using a method the route does not support
+func (h *TaskHandler) MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	h.respondWithError(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "method not allowed")
+}
+
+// RateLimited handles requests rejected by the rate limiter, which has
+// already set Retry-After
+//
+//api:changelog 0.2.0 added error rate_limited: Clients over their request rate get 429 with Retry-After
+func (h *TaskHandler) RateLimited(w http.ResponseWriter, r *http.Request) {
+	h.respondWithError(w, r, http.StatusTooManyRequests, CodeRateLimited, "rate limit exceeded; retry after the Retry-After delay")
+}
+
+// Maintenance handles writes rejected while maintenance mode is on
+//
+//api:changelog 0.2.0 added error maintenance: Writes get 503 while the server is in maintenance (read-only) mode, set with READ_ONLY or PUT /admin/maintenance; reads keep working
+func (h *TaskHandler) Maintenance(w http.ResponseWriter, r *http.Request) {
+	h.respondWithError(w, r, http.StatusServiceUnavailable, CodeMaintenance, "the server is in maintenance mode; writes are disabled")
+}
+
+// Unauthorized handles requests without a valid credential; the auth
+// middleware has already set WWW-Authenticate
+//
+//api:changelog 0.2.0 added error unauthorized: Requests without a valid API key get 401 when auth is enabled
+func (h *TaskHandler) Unauthorized(w http.ResponseWriter, r *http.Request) {
+	h.respondWithError(w, r, http.StatusUnauthorized, CodeUnauthorized, "missing or invalid API key")
+}
+
+// Forbidden handles requests the caller's API key is not allowed to make
+//
+//api:changelog 0.2.0 added error forbidden: Read-only API keys get 403 on requests that modify tasks
+func (h *TaskHandler) Forbidden(w http.ResponseWriter, r *http.Request) {
+	h.respondWithError(w, r, http.StatusForbidden, CodeForbidden, "API key scope does not allow this request")
+}
+
+// InternalError handles requests whose handler panicked. The response
+// carries the request ID, under which the panic and its stack are logged.
+//
+//api:changelog 0.2.0 changed error internal_error: A handler panic returns a JSON 500 with the request ID instead of an empty body
+func (h *TaskHandler) InternalError(w http.ResponseWriter, r *http.Request) {
+	h.respondWithError(w, r, http.StatusInternalServerError, CodeInternal, "internal server error; quote the request ID when reporting it")
+}
+
+// Error writes an error response in the handler's configured format, for
+// routes served outside TaskHandler
+func (h *TaskHandler) Error(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	h.respondWithError(w, r, status, code, message)
+}
+
+// respondWithJSON writes a JSON response
+func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if payload != nil {
+		json.NewEncoder(w).Encode(payload)
+	}
+}
+
+// respondWithError writes an error response
+func (h *TaskHandler) respondWithError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	h.writeError(w, r, status, ErrorResponse{Code: code, Message: message})
+}
+
+// respondWithTaskError writes the response for an error from the task
+// service: a 422 for invalid or rejected content, the matching status for
+// a known repository error, otherwise a 500 with message
+func (h *TaskHandler) respondWithTaskError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrInvalid):
+		h.respondWithValidationError(w, r, err)
+	case errors.Is(err, service.ErrContentRejected):
+		h.respondWithContentRejection(w, r, err)
+	case errors.Is(err, repository.ErrTaskNotFound):
+		h.respondWithError(w, r, http.StatusNotFound, CodeNotFound, "task not found")
+	case errors.Is(err, repository.ErrTaskLimitReached):
+		h.respondWithError(w, r, http.StatusForbidden, CodeTaskLimitReached, "task limit reached")
+	case errors.Is(err, repository.ErrWorkspaceNotFound):
+		h.respondWithError(w, r, http.StatusNotFound, CodeWorkspaceNotFound, "workspace not found")
+	case errors.Is(err, repository.ErrLinkTargetNotFound):
+		h.respondWithError(w, r, http.StatusBadRequest, CodeLinkTargetNotFound, "linked task not found")
+	case errors.Is(err, repository.ErrSelfLink):
+		h.respondWithError(w, r, http.StatusBadRequest, CodeSelfLink, "task cannot be linked to itself")
+	case errors.Is(err, repository.ErrLinkExists):
+		h.respondWithError(w, r, http.StatusConflict, CodeConflict, "link already exists")
+	default:
+		h.respondWithRepositoryError(w, r, err, message)
+	}
+}
+
+// respondWithRepositoryError logs an unexpected repository error and writes
+// a 500 response that does not leak its details
+func (h *TaskHandler) respondWithRepositoryError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	logging.FromContext(r.Context()).Error(message, slog.Any("error", err))
+	h.respondWithError(w, r, http.StatusInternalServerError, CodeInternal, message)
+}
+
+// searchRetryAfter is suggested to clients while search is unavailable
+const searchRetryAfter = "30"
+
+// respondWithSearchUnavailable writes a 503 telling the client that search
+// is temporarily degraded while the rest of the API keeps working
+//
+//api:changelog 0.2.0 added error search_unavailable: Search returns 503 with Retry-After while the search subsystem is down
+func (h *TaskHandler) respondWithSearchUnavailable(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Retry-After", searchRetryAfter)
+	h.respondWithError(w, r, http.StatusServiceUnavailable, CodeSearchUnavailable, "search is temporarily unavailable; listing without ?q= still works")
+}
+
+// respondWithValidationError writes a 422 response listing every violated rule
+//
+//api:changelog 0.2.0 changed error validation_failed: Invalid request bodies return 422 instead of 400
+func (h *TaskHandler) respondWithValidationError(w http.ResponseWriter, r *http.Request, err error) {
+	logger := logging.FromContext(r.Context())
+
+	var verrs validation.Errors
+	if !errors.As(err, &verrs) {
+		logger.Info("validation failed", slog.Any("error", err))
+		h.respondWithError(w, r, http.StatusUnprocessableEntity, CodeValidationFailed, err.Error())
+		return
+	}
+	logger.Info("validation failed", slog.Any("violations", []validation.Violation(verrs)))
+
+	fields := make([]FieldError, len(verrs))
+	for i, v := range verrs {
+		fields[i] = FieldError{Field: v.Field, Code: v.Rule, Message: v.Message, Candidates: v.Candidates, params: v.Params}
+	}
+
+	h.writeError(w, r, http.StatusUnprocessableEntity, ErrorResponse{
+		Code:    CodeValidationFailed,
+		Message: "validation failed",
+		Fields:  fields,
+	})
+}
+
+// respondWithContentRejection writes a 422 response listing every content
+// policy that rejected the request
+func (h *TaskHandler) respondWithContentRejection(w http.ResponseWriter, r *http.Request, err error) {
+	logger := logging.FromContext(r.Context())
+
+	var rejections content.Rejections
+	if !errors.As(err, &rejections) {
+		logger.Info("content rejected", slog.Any("error", err))
+		h.respondWithError(w, r, http.StatusUnprocessableEntity, CodeContentRejected, err.Error())
+		return
+	}
+	logger.Info("content rejected", slog.Any("rejections", []content.Rejection(rejections)))
+
+	fields := make([]FieldError, len(rejections))
+	for i, rej := range rejections {
+		fields[i] = FieldError{Field: rej.Field, Code: rej.Processor, Message: rej.Message}
+	}
+
+	h.writeError(w, r, http.StatusUnprocessableEntity, ErrorResponse{
+		Code:    CodeContentRejected,
+		Message: "content rejected by policy",
+		Fields:  fields,
+	})
+}
+
+// writeError writes resp in the error format the handler is configured for
+func (h *TaskHandler) writeError(w http.ResponseWriter, r *http.Request, status int, resp ErrorResponse) {
+	resp.RequestID = requestid.FromRequest(r)
+	h.translate(w, r, &resp)
+
+	if !h.problemDetails {
+		respondWithJSON(w, status, resp)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ProblemDetails{
+		Type:      problemTypeBase + resp.Code,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    resp.Message,
+		Instance:  r.URL.Path,
+		Code:      resp.Code,
+		Fields:    resp.Fields,
+		RequestID: resp.RequestID,
+	})
+}
+
+// translate replaces the messages of resp with those of the language the
+// client asked for in Accept-Language, if the handler has one. Codes are
+// never translated, and a message without a translation stays in English.
+//
+//api:changelog 0.2.0 added header Accept-Language: Error and validation messages are translated into the preferred language with a bundle (de, es and fr built in); codes stay the same
+//api:changelog 0.2.0 added header Content-Language: Set on error responses whose message was translated
+func (h *TaskHandler) translate(w http.ResponseWriter, r *http.Request, resp *ErrorResponse) {
+	w.Header().Add("Vary", "Accept-Language")
+	lang := h.translations.Negotiate(r.Header.Get("Accept-Language"))
+	if lang == i18n.Source {
+		return
+	}
+
+	msg, ok := h.translations.Translate(lang, "error."+resp.Code, nil)
+	if !ok {
+		return
+	}
+	resp.Message = msg
+	w.Header().Set("Content-Language", lang)
+
+	fields := make([]FieldError, len(resp.Fields))
+	for i, f := range resp.Fields {
+		params := map[string]string{"field": f.Field}
+		for name, value := range f.params {
+			params[name] = value
+		}
+		if msg, ok := h.translations.Translate(lang, "rule."+f.Code, params); ok {
+			f.Message = msg
+		}
+		fields[i] = f
+	}
+	resp.Fields = fields
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+func TestTaskHandler_ProblemDetails(t *testing.T) {
+	tests := []struct {
+		name       string
+		serve      func(h *TaskHandler, w http.ResponseWriter, r *http.Request)
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantCode   string
+		wantFields int
+	}{
+		{
+			name:       "invalid JSON",
+			serve:      (*TaskHandler).CreateTask,
+			method:     "POST",
+			path:       "/tasks",
+			body:       `{"title":}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   CodeInvalidJSON,
+		},
+		{
+			name:       "validation failure",
+			serve:      (*TaskHandler).CreateTask,
+			method:     "POST",
+			path:       "/tasks",
+			body:       `{"title":""}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   CodeValidationFailed,
+			wantFields: 1,
+		},
+		{
+			name:       "unknown route",
+			serve:      (*TaskHandler).NotFound,
+			method:     "GET",
+			path:       "/nope",
+			wantStatus: http.StatusNotFound,
+			wantCode:   CodeNotFound,
+		},
+		{
+			name:       "wrong method",
+			serve:      (*TaskHandler).MethodNotAllowed,
+			method:     "PATCH",
+			path:       "/tasks",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantCode:   CodeMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewTaskHandler(repository.NewMemoryRepository(), WithProblemDetails())
+
+			req := httptest.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+			rec := httptest.NewRecorder()
+
+			tt.serve(handler, rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %v, want %v", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/problem+json" {
+				t.Errorf("Content-Type = %q, want application/problem+json", got)
+			}
+
+			var problem ProblemDetails
+			if err := json.NewDecoder(rec.Body).Decode(&problem); err != nil {
+				t.Fatalf("decode error = %v", err)
+			}
+			if problem.Status != tt.wantStatus {
+				t.Errorf("problem.Status = %v, want %v", problem.Status, tt.wantStatus)
+			}
+			if problem.Type != problemTypeBase+tt.wantCode || problem.Code != tt.wantCode {
+				t.Errorf("problem type/code = %q/%q, want code %q", problem.Type, problem.Code, tt.wantCode)
+			}
+			if problem.Title != http.StatusText(tt.wantStatus) {
+				t.Errorf("problem.Title = %q, want %q", problem.Title, http.StatusText(tt.wantStatus))
+			}
+			if problem.Instance != tt.path {
+				t.Errorf("problem.Instance = %q, want %q", problem.Instance, tt.path)
+			}
+			if len(problem.Fields) != tt.wantFields {
+				t.Errorf("got %d fields, want %d", len(problem.Fields), tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestTaskHandler_TranslatedErrors(t *testing.T) {
+	handler := NewTaskHandler(repository.NewMemoryRepository())
+	body := `{"title":"` + strings.Repeat("x", 201) + `"}`
+
+	send := func(acceptLanguage string) (*httptest.ResponseRecorder, ErrorResponse) {
+		req := httptest.NewRequest("POST", "/tasks", strings.NewReader(body))
+		if acceptLanguage != "" {
+			req.Header.Set("Accept-Language", acceptLanguage)
+		}
+		rec := httptest.NewRecorder()
+		handler.CreateTask(rec, req)
+
+		var resp ErrorResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode error = %v", err)
+		}
+		return rec, resp
+	}
+
+	rec, resp := send("de-AT, en;q=0.5")
+	if got := rec.Header().Get("Content-Language"); got != "de" {
+		t.Errorf("Content-Language = %q, want de", got)
+	}
+	if resp.Code != CodeValidationFailed || resp.Message != "Die Validierung ist fehlgeschlagen." {
+		t.Errorf("response = %+v, want the German message with an unchanged code", resp)
+	}
+	if len(resp.Fields) != 1 || resp.Fields[0].Code != "max_length" || resp.Fields[0].Message != "title darf höchstens 200 Zeichen lang sein." {
+		t.Errorf("fields = %+v", resp.Fields)
+	}
+
+	// English, or a language without a bundle, keeps the specific messages
+	for _, lang := range []string{"", "en-GB, de;q=0.8", "ja"} {
+		rec, resp := send(lang)
+		if got := rec.Header().Get("Content-Language"); got != "" {
+			t.Errorf("Accept-Language %q: Content-Language = %q, want none", lang, got)
+		}
+		if resp.Message != "validation failed" || len(resp.Fields) != 1 || resp.Fields[0].Message != "title must be at most 200 characters" {
+			t.Errorf("Accept-Language %q: response = %+v", lang, resp)
+		}
+		if got := rec.Header().Get("Vary"); got != "Accept-Language" {
+			t.Errorf("Accept-Language %q: Vary = %q, want Accept-Language", lang, got)
+		}
+	}
+}
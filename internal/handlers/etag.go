@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+// computeETag returns the strong ETag of a response body
+func computeETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return formatETag(sum[:])
+}
+
+// formatETag returns the strong ETag of a body with the given SHA-256 sum
+func formatETag(sum []byte) string {
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// taskETag returns the ETag of a task, matching the one sent with it by
+// GET /tasks/{id}
+func taskETag(task *models.Task) string {
+	body, _ := json.Marshal(task)
+	return computeETag(body)
+}
+
+// respondWithETag writes a JSON response with a strong ETag computed from
+// the body, or 304 Not Modified without a body when the request's
+// If-None-Match matches it
+//
+//api:changelog 0.2.0 added header ETag: Strong validator on task and task list responses
+//api:changelog 0.2.0 added header If-None-Match: GET /tasks and GET /tasks/{id} answer 304 Not Modified while the ETag still matches
+func respondWithETag(w http.ResponseWriter, r *http.Request, code int, payload any) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		respondWithJSON(w, code, payload)
+		return
+	}
+	if setETag(w, r, computeETag(body)) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(append(body, '\n'))
+}
+
+// setETag sets the ETag header and writes 304 Not Modified if the request's
+// If-None-Match matches it, reporting whether it did
+func setETag(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	if r.Method == http.MethodGet && etagListMatches(r.Header.Get("If-None-Match"), etag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// precondition is the If-Match precondition of a write to a task
+type precondition struct {
+	header string
+	loc    *time.Location
+	etag   string // the task's ETag, once checked
+}
+
+// ifMatch returns the request's If-Match precondition, or nil without one,
+// having written an error if the request's zone is invalid
+//
+//api:changelog 0.2.0 added header If-Match: PUT and DELETE /tasks/{id} go ahead only while the task's ETag still matches
+//api:changelog 0.2.0 added error precondition_failed: Writes with an If-Match header get 412 when the task has changed since the ETag was read, or no longer exists
+func (h *TaskHandler) ifMatch(w http.ResponseWriter, r *http.Request) (*precondition, bool) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return nil, true
+	}
+
+	// The ETag was read with due_at shown in the requester's zone
+	loc, ok := h.location(w, r)
+	if !ok {
+		return nil, false
+	}
+	return &precondition{header: header, loc: loc}, true
+}
+
+// context returns ctx carrying the precondition to the repository, which
+// checks it against the stored task under the same lock as the write, so
+// two clients sending the same ETag cannot both succeed
+func (p *precondition) context(ctx context.Context) context.Context {
+	if p == nil {
+		return ctx
+	}
+	return repository.WithPrecondition(ctx, func(task *models.Task) bool {
+		p.etag = taskETag(localizeTask(task, p.loc))
+		return etagListMatches(p.header, p.etag, false)
+	})
+}
+
+// respondIfFailed writes 412 and returns true when err means the
+// precondition failed: the task has changed or, as RFC 9110 has it for
+// If-Match, does not exist
+func (h *TaskHandler) respondIfFailed(w http.ResponseWriter, r *http.Request, p *precondition, err error) bool {
+	if p == nil || !errors.Is(err, repository.ErrPreconditionFailed) && !errors.Is(err, repository.ErrTaskNotFound) {
+		return false
+	}
+	if p.etag == "" {
+		h.respondWithError(w, r, http.StatusPreconditionFailed, CodePreconditionFailed, "task no longer exists")
+		return true
+	}
+	w.Header().Set("ETag", p.etag)
+	h.respondWithError(w, r, http.StatusPreconditionFailed, CodePreconditionFailed, "task has changed since it was read; fetch it again and retry")
+	return true
+}
+
+// etagListMatches reports whether a list of entity tags, as sent in
+// If-Match and If-None-Match, contains etag or "*". If-None-Match uses the
+// weak comparison, which ignores a W/ prefix; If-Match needs a strong match.
+func etagListMatches(header, etag string, weak bool) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
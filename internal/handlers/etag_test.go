@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+func TestTaskHandler_ConditionalRequests(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	handler := NewTaskHandler(repo)
+	repo.Create(ctx, &models.Task{Title: "Task"})
+
+	serve := func(method, path string, header http.Header, body string, h http.HandlerFunc) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", "1")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		return rec
+	}
+
+	// GET returns an ETag, and 304 while it matches
+	rec := serve("GET", "/tasks/1", nil, "", handler.GetTask)
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || len(etag) < 3 || etag[0] != '"' {
+		t.Fatalf("GET: status %d, ETag %q", rec.Code, etag)
+	}
+	rec = serve("GET", "/tasks/1", http.Header{"If-None-Match": {`"other", W/` + etag}}, "", handler.GetTask)
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("GET If-None-Match: status %d, body %q, want 304 without body", rec.Code, rec.Body)
+	}
+	rec = serve("GET", "/tasks", http.Header{"If-None-Match": {etag}}, "", handler.ListTasks)
+	listETag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || listETag == "" || listETag == etag {
+		t.Errorf("GET /tasks: status %d, ETag %q", rec.Code, listETag)
+	}
+	rec = serve("GET", "/tasks", http.Header{"If-None-Match": {listETag}}, "", handler.ListTasks)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("GET /tasks If-None-Match: status %d, want 304", rec.Code)
+	}
+
+	// A matching If-Match lets the update through and returns the new ETag
+	update := `{"title":"Renamed","status":"todo"}`
+	rec = serve("PUT", "/tasks/1", http.Header{"If-Match": {etag}}, update, handler.UpdateTask)
+	newETag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || newETag == etag {
+		t.Fatalf("PUT If-Match: status %d, ETag %q", rec.Code, newETag)
+	}
+	if got := serve("GET", "/tasks/1", nil, "", handler.GetTask).Header().Get("ETag"); got != newETag {
+		t.Errorf("GET after PUT: ETag %q, want %q", got, newETag)
+	}
+
+	// The old ETag is now stale, and weak tags never satisfy If-Match
+	for _, ifMatch := range []string{etag, "W/" + newETag} {
+		rec = serve("PUT", "/tasks/1", http.Header{"If-Match": {ifMatch}}, update, handler.UpdateTask)
+		if rec.Code != http.StatusPreconditionFailed || !bytes.Contains(rec.Body.Bytes(), []byte(CodePreconditionFailed)) {
+			t.Errorf("PUT If-Match %s: status %d, body %s", ifMatch, rec.Code, rec.Body)
+		}
+		if rec.Header().Get("ETag") != newETag {
+			t.Errorf("412 ETag = %q, want current %q", rec.Header().Get("ETag"), newETag)
+		}
+	}
+	rec = serve("DELETE", "/tasks/1", http.Header{"If-Match": {etag}}, "", handler.DeleteTask)
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("DELETE stale If-Match: status %d, want 412", rec.Code)
+	}
+	if _, err := repo.GetByID(ctx, 1); err != nil {
+		t.Fatalf("task deleted despite failed precondition: %v", err)
+	}
+
+	rec = serve("DELETE", "/tasks/1", http.Header{"If-Match": {"*"}}, "", handler.DeleteTask)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("DELETE If-Match *: status %d, want 204", rec.Code)
+	}
+
+	// If-Match on a missing task fails, even with *
+	for _, ifMatch := range []string{newETag, "*"} {
+		rec = serve("PUT", "/tasks/1", http.Header{"If-Match": {ifMatch}}, update, handler.UpdateTask)
+		if rec.Code != http.StatusPreconditionFailed {
+			t.Errorf("PUT missing task If-Match %s: status %d, want 412", ifMatch, rec.Code)
+		}
+		rec = serve("DELETE", "/tasks/1", http.Header{"If-Match": {ifMatch}}, "", handler.DeleteTask)
+		if rec.Code != http.StatusPreconditionFailed {
+			t.Errorf("DELETE missing task If-Match %s: status %d, want 412", ifMatch, rec.Code)
+		}
+	}
+	rec = serve("DELETE", "/tasks/1", nil, "", handler.DeleteTask)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("DELETE missing task: status %d, want 404", rec.Code)
+	}
+}
+
+func TestTaskHandler_ConcurrentIfMatch(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	handler := NewTaskHandler(repo)
+	task, _ := repo.Create(context.Background(), &models.Task{Title: "Task", Status: models.StatusTodo})
+	etag := taskETag(localizeTask(task, time.UTC))
+
+	// Every writer read the same ETag, so only one may succeed
+	const writers = 16
+	codes := make(chan int, writers)
+	var wg sync.WaitGroup
+	for i := range writers {
+		wg.Go(func() {
+			req := httptest.NewRequest("PUT", "/tasks/1", bytes.NewBufferString(`{"title":"Writer `+strconv.Itoa(i)+`","status":"todo"}`))
+			req.Header.Set("If-Match", etag)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", "1")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			rec := httptest.NewRecorder()
+			handler.UpdateTask(rec, req)
+			codes <- rec.Code
+		})
+	}
+	wg.Wait()
+	close(codes)
+
+	ok := 0
+	for code := range codes {
+		switch code {
+		case http.StatusOK:
+			ok++
+		case http.StatusPreconditionFailed:
+		default:
+			t.Errorf("status %d, want 200 or 412", code)
+		}
+	}
+	if ok != 1 {
+		t.Errorf("%d writers succeeded with the same ETag, want 1", ok)
+	}
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+)
+
+// maxExpandDepth caps how deeply ?expand= may nest, as in links.links.links
+const maxExpandDepth = 3
+
+// parseExpand reads the ?expand= parameter of a task response and returns
+// how many levels of links to embed, writing a 400 response if it names
+// an unknown relation or nests too deeply. Links are the only relation:
+// expand=links embeds each linked task, and expand=links.links also the
+// tasks those link to.
+//
+//api:changelog 0.2.0 added parameter GET /tasks?expand: Related resources to embed; links embeds linked tasks, links.links nests up to three levels
+//api:changelog 0.2.0 added parameter GET /tasks/{id}?expand: Related resources to embed; links embeds linked tasks, links.links nests up to three levels
+func (h *TaskHandler) parseExpand(w http.ResponseWriter, r *http.Request) (int, bool) {
+	depth, err := parseExpandDepth(r.URL.Query().Get("expand"))
+	if err != nil {
+		h.respondWithError(w, r, http.StatusBadRequest, CodeInvalidQuery, "expand: "+err.Error())
+		return 0, false
+	}
+	return depth, true
+}
+
+// parseExpandDepth parses a comma-separated list of dotted relation paths
+func parseExpandDepth(list string) (int, error) {
+	if strings.TrimSpace(list) == "" {
+		return 0, nil
+	}
+	depth := 0
+	for _, path := range strings.Split(list, ",") {
+		path = strings.TrimSpace(path)
+		relations := strings.Split(path, ".")
+		for _, relation := range relations {
+			if relation != "links" {
+				return 0, fmt.Errorf("unknown relation %q; valid relations are links", relation)
+			}
+		}
+		if len(relations) > maxExpandDepth {
+			return 0, fmt.Errorf("%q nests deeper than %d levels", path, maxExpandDepth)
+		}
+		depth = max(depth, len(relations))
+	}
+	return depth, nil
+}
+
+// expandLinks returns copies of tasks with their linked tasks embedded to
+// the given depth. Each level is loaded with a single GetByIDs call, however
+// many tasks it spans. Links to tasks the caller cannot see are left as
+// they are. The stored tasks are never modified.
+func (h *TaskHandler) expandLinks(ctx context.Context, tasks []*models.Task, depth int) ([]*models.Task, error) {
+	if depth == 0 {
+		return tasks, nil
+	}
+
+	expanded := make([]*models.Task, len(tasks))
+	for i, task := range tasks {
+		expanded[i] = copyTask(task)
+	}
+
+	level := expanded
+	for range depth {
+		var ids []int64
+		for _, task := range level {
+			for _, link := range task.Links {
+				ids = append(ids, link.TaskID)
+			}
+		}
+		if len(ids) == 0 {
+			break
+		}
+		slices.Sort(ids)
+		linked, err := h.repo.GetByIDs(ctx, slices.Compact(ids))
+		if err != nil {
+			return nil, err
+		}
+		byID := make(map[int64]*models.Task, len(linked))
+		for _, task := range linked {
+			byID[task.ID] = task
+		}
+
+		var next []*models.Task
+		for _, task := range level {
+			for i, link := range task.Links {
+				if target, ok := byID[link.TaskID]; ok {
+					task.Links[i].Task = copyTask(target)
+					next = append(next, task.Links[i].Task)
+				}
+			}
+		}
+		level = next
+	}
+	return expanded, nil
+}
+
+// copyTask copies a task and its links, so that changes to the copy, such
+// as filled-in links, do not reach the original
+func copyTask(task *models.Task) *models.Task {
+	c := *task
+	c.Links = slices.Clone(task.Links)
+	return &c
+}
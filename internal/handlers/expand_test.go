@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+// countingRepository counts GetByIDs calls
+type countingRepository struct {
+	repository.TaskRepository
+	getByIDs int
+}
+
+func (r *countingRepository) GetByIDs(ctx context.Context, ids []int64) ([]*models.Task, error) {
+	r.getByIDs++
+	return r.TaskRepository.GetByIDs(ctx, ids)
+}
+
+func TestTaskHandler_Expand(t *testing.T) {
+	ctx := context.Background()
+	mem := repository.NewMemoryRepository()
+	repo := &countingRepository{TaskRepository: mem}
+	handler := NewTaskHandler(repo)
+	for _, title := range []string{"a", "b", "c", "d"} {
+		mem.Create(ctx, &models.Task{Title: title})
+	}
+	// 1 -> 2 -> 3, 4 -> 2, and 3 -> 1 closes a cycle
+	mem.AddLink(ctx, 1, models.TaskLink{Type: models.LinkRelatesTo, TaskID: 2})
+	mem.AddLink(ctx, 2, models.TaskLink{Type: models.LinkCausedBy, TaskID: 3})
+	mem.AddLink(ctx, 4, models.TaskLink{Type: models.LinkDuplicateOf, TaskID: 2})
+	mem.AddLink(ctx, 3, models.TaskLink{Type: models.LinkRelatesTo, TaskID: 1})
+
+	serve := func(target string, h http.HandlerFunc) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", "1")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		return rec
+	}
+
+	rec := serve("/tasks?expand=links", handler.ListTasks)
+	var list []models.Task
+	json.NewDecoder(rec.Body).Decode(&list)
+	if rec.Code != http.StatusOK || len(list) != 4 {
+		t.Fatalf("list: status %d, %d tasks", rec.Code, len(list))
+	}
+	if linked := list[3].Links[0].Task; linked == nil || linked.Title != "b" || linked.Links[0].Task != nil {
+		t.Errorf("task 4 link = %+v, want task 2 without its own links expanded", list[3].Links[0])
+	}
+	if repo.getByIDs != 1 {
+		t.Errorf("one level over 4 tasks took %d GetByIDs calls, want 1", repo.getByIDs)
+	}
+
+	repo.getByIDs = 0
+	rec = serve("/tasks/1?expand=links.links.links", handler.GetTask)
+	var task models.Task
+	json.NewDecoder(rec.Body).Decode(&task)
+	third := task.Links[0].Task.Links[0].Task.Links[0].Task
+	if rec.Code != http.StatusOK || third == nil || third.ID != 1 || third.Links[0].Task != nil {
+		t.Errorf("get: status %d, body %+v", rec.Code, task)
+	}
+	if repo.getByIDs != 3 {
+		t.Errorf("three levels took %d GetByIDs calls, want 3", repo.getByIDs)
+	}
+
+	// Stored tasks are left as they were
+	stored, _ := mem.GetByID(ctx, 1)
+	if stored.Links[0].Task != nil {
+		t.Error("expansion modified the stored task")
+	}
+	if rec := serve("/tasks/1", handler.GetTask); strings.Contains(rec.Body.String(), `"task":`) {
+		t.Errorf("without expand: body %s", rec.Body)
+	}
+
+	for _, target := range []string{"/tasks?expand=comments", "/tasks/1?expand=links.links.links.links", "/tasks?expand=links.owner"} {
+		rec := serve(target, handler.GetTask)
+		var errResp ErrorResponse
+		json.NewDecoder(rec.Body).Decode(&errResp)
+		if rec.Code != http.StatusBadRequest || errResp.Code != CodeInvalidQuery {
+			t.Errorf("%s: status %d, code %q", target, rec.Code, errResp.Code)
+		}
+	}
+}
@@ -0,0 +1,603 @@
+package handlers
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/content"
+	"github.com/light-bringer/cert-tasks/internal/logging"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/redact"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+	"github.com/light-bringer/cert-tasks/internal/service"
+	"github.com/light-bringer/cert-tasks/internal/validation"
+)
+
+// exportFormat describes a file format tasks can be exported in
+type exportFormat struct {
+	contentType string
+	filename    string
+	encoder     func(w io.Writer) taskEncoder
+}
+
+// exportFormats maps ?format= values to export formats
+var exportFormats = map[string]exportFormat{
+	"csv":    {"text/csv; charset=utf-8", "tasks.csv", newCSVEncoder},
+	"ndjson": {"application/x-ndjson", "tasks.ndjson", newNDJSONEncoder},
+}
+
+// taskEncoder writes exported tasks in one format
+type taskEncoder interface {
+	Encode(t *models.Task) error
+	Flush() error
+}
+
+// exportColumns are the columns written by the CSV export, in order
+var exportColumns = []string{"id", "workspace_id", "title", "description", "status", "due_at", "created_at", "updated_at", "estimate"}
+
+// exportBatchSize is the number of tasks fetched per page while exporting
+const exportBatchSize = 500
+
+// codeFieldCount reports an import row with the wrong number of fields
+const codeFieldCount = "field_count"
+
+// formulaPrefixes start cell values that spreadsheets evaluate as formulas
+const formulaPrefixes = "=+-@\t\r"
+
+// ExportTasks handles GET /tasks/export, streaming tasks as CSV or, for
+// backups, as newline-delimited JSON with every task field. The same status
+// and q filters as bulk delete select a subset. With ?async=true the export
+// runs as a job instead; see startExport.
+//
+//api:changelog 0.2.0 added endpoint GET /tasks/export: Download tasks as CSV
+//api:changelog 0.2.0 added parameter GET /tasks/export?format: Export format, csv (default) or ndjson
+//api:changelog 0.2.0 added parameter GET /tasks/export?status: Export only tasks with this status
+//api:changelog 0.2.0 added parameter GET /tasks/export?q: Export only tasks matching this search query
+//api:changelog 0.2.0 added parameter GET /tasks/export?async: Run the export in the background and return 202 with a job to poll at GET /jobs/{id}
+//api:changelog 0.2.0 added parameter GET /tasks/export?redact: Mask the personal data fields configured in log.redact, such as descriptions, for sharing the export
+func (h *TaskHandler) ExportTasks(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	formatName := cmp.Or(q.Get("format"), "csv")
+	format, ok := exportFormats[formatName]
+	if !ok {
+		h.respondWithError(w, r, http.StatusBadRequest, CodeInvalidQuery, "format must be csv or ndjson")
+		return
+	}
+	filter := bulkFilter{
+		status: models.TaskStatus(q.Get("status")),
+		query:  q.Get("q"),
+	}
+	if filter.status != "" && filter.status != models.StatusTodo && filter.status != models.StatusDone {
+		h.respondWithError(w, r, http.StatusBadRequest, CodeInvalidQuery, "status must be todo or done")
+		return
+	}
+	if filter.query != "" && !h.repo.Capabilities().FullTextSearch {
+		h.respondWithError(w, r, http.StatusNotImplemented, CodeNotImplemented, "search is not supported by the storage backend")
+		return
+	}
+	redacted := false
+	if v := q.Get("redact"); v != "" {
+		var err error
+		if redacted, err = strconv.ParseBool(v); err != nil {
+			h.respondWithError(w, r, http.StatusBadRequest, CodeInvalidQuery, "redact must be true or false")
+			return
+		}
+	}
+	async, ok := h.asyncQuery(w, r)
+	if !ok {
+		return
+	}
+	if async {
+		h.startExport(w, r, formatName, filter, redacted)
+		return
+	}
+
+	next := h.exportPages(r.Context(), filter)
+
+	// Fetch the first page before writing anything, so a failing store
+	// still gets a proper error response
+	tasks, err := next()
+	if err != nil {
+		h.respondWithRepositoryError(w, r, err, "failed to export tasks")
+		return
+	}
+
+	w.Header().Set("Content-Type", format.contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+format.filename+`"`)
+	w.WriteHeader(http.StatusOK)
+
+	exported, err := writeExport(h.exportEncoder(format, w, redacted), filter, tasks, next, func(int) {
+		http.NewResponseController(w).Flush()
+	})
+	if err != nil {
+		// The status line is gone; abort the response so the client
+		// sees a broken download rather than a truncated file
+		logging.FromContext(r.Context()).Error("failed to export tasks", slog.Any("error", err))
+		panic(http.ErrAbortHandler)
+	}
+
+	logging.FromContext(r.Context()).Info("tasks exported", slog.Int("tasks", exported))
+}
+
+// writeExport encodes tasks, then every later page from next, skipping
+// tasks the filter's status excludes. It calls flushed with the count so
+// far after each page and returns the total, or the error that stopped
+// paging.
+func writeExport(enc taskEncoder, filter bulkFilter, tasks []*models.Task, next func() ([]*models.Task, error), flushed func(exported int)) (int, error) {
+	exported := 0
+	for len(tasks) > 0 {
+		for _, t := range tasks {
+			if filter.status != "" && t.Status != filter.status {
+				continue
+			}
+			enc.Encode(t)
+			exported++
+		}
+		enc.Flush()
+		flushed(exported)
+
+		var err error
+		if tasks, err = next(); err != nil {
+			return exported, err
+		}
+	}
+	return exported, nil
+}
+
+// exportPages returns a function yielding the tasks to export page by page,
+// and an empty page once they are exhausted. Stores with cursors are read
+// in batches; searches and other stores are read in one go.
+func (h *TaskHandler) exportPages(ctx context.Context, filter bulkFilter) func() ([]*models.Task, error) {
+	if filter.query == "" && h.repo.Capabilities().Cursors {
+		var after int64
+		return func() ([]*models.Task, error) {
+			tasks, err := h.repo.List(ctx, repository.ListOptions{AfterID: after, Limit: exportBatchSize})
+			if len(tasks) > 0 {
+				after = tasks[len(tasks)-1].ID
+			}
+			return tasks, err
+		}
+	}
+
+	done := false
+	return func() ([]*models.Task, error) {
+		if done {
+			return nil, nil
+		}
+		done = true
+		if filter.query != "" {
+			return h.repo.Search(ctx, filter.query)
+		}
+		return h.repo.GetAll(ctx)
+	}
+}
+
+// exportEncoder returns the encoder writing format to w, masking the
+// sensitive fields of each task if redacted is set
+func (h *TaskHandler) exportEncoder(format exportFormat, w io.Writer, redacted bool) taskEncoder {
+	enc := format.encoder(w)
+	if redacted {
+		enc = redactingEncoder{taskEncoder: enc, redactor: h.redactor}
+	}
+	return enc
+}
+
+// redactingEncoder masks the sensitive fields of tasks before encoding them
+type redactingEncoder struct {
+	taskEncoder
+	redactor *redact.Redactor
+}
+
+// Encode writes t with its sensitive fields masked
+func (e redactingEncoder) Encode(t *models.Task) error {
+	return e.taskEncoder.Encode(e.redactor.Task(t))
+}
+
+// WithRedactor sets the fields masked in exports with ?redact=true,
+// instead of redact.DefaultFields
+func WithRedactor(redactor *redact.Redactor) Option {
+	return func(h *TaskHandler) {
+		h.redactor = redactor
+	}
+}
+
+// csvEncoder writes tasks as CSV records under a header row
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func newCSVEncoder(w io.Writer) taskEncoder {
+	cw := csv.NewWriter(w)
+	cw.Write(exportColumns)
+	return csvEncoder{w: cw}
+}
+
+// Encode writes t as a record
+func (e csvEncoder) Encode(t *models.Task) error {
+	return e.w.Write(exportRecord(t))
+}
+
+// Flush writes buffered records
+func (e csvEncoder) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// exportRecord formats t as a CSV record in exportColumns order
+func exportRecord(t *models.Task) []string {
+	return []string{
+		strconv.FormatInt(t.ID, 10),
+		t.WorkspaceID,
+		escapeFormula(t.Title),
+		escapeFormula(t.Description),
+		string(t.Status),
+		formatDueAt(t.DueAt),
+		t.CreatedAt.UTC().Format(time.RFC3339),
+		t.UpdatedAt.UTC().Format(time.RFC3339),
+		formatEstimate(t.Estimate),
+	}
+}
+
+// formatEstimate formats an optional estimate for export
+func formatEstimate(estimate int) string {
+	if estimate == 0 {
+		return ""
+	}
+	return strconv.Itoa(estimate)
+}
+
+// formatDueAt formats an optional due date for export
+func formatDueAt(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// escapeFormula prefixes values a spreadsheet would evaluate as a formula
+// with a quote, so an exported file cannot run code when opened
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune(formulaPrefixes, rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// unescapeFormula reverses escapeFormula, so exported files import cleanly
+func unescapeFormula(s string) string {
+	if len(s) > 1 && s[0] == '\'' && strings.ContainsRune(formulaPrefixes, rune(s[1])) {
+		return s[1:]
+	}
+	return s
+}
+
+// importRow is a parsed row awaiting validation. errs holds problems
+// found while parsing it.
+type importRow struct {
+	line int
+	req  models.UpdateTaskRequest
+	errs []models.ImportRowError
+}
+
+// ImportTasks handles POST /tasks/import. The body is a file in the format
+// named by ?format=, sent as-is or as the "file" field of a multipart form.
+// Valid rows are created and invalid ones reported without stopping the
+// import; with ?dry_run=true every row is checked but nothing is created.
+// With ?async=true the file is stored and imported by a job; see
+// startImport.
+//
+// A CSV file's header row names the columns: title is required,
+// description and status are optional, and the other columns written by
+// the export are ignored. NDJSON files restore an NDJSON export; see
+// importNDJSON.
+//
+//api:changelog 0.2.0 added endpoint POST /tasks/import: Create tasks from a CSV upload, with a per-row error report
+//api:changelog 0.2.0 added parameter POST /tasks/import?dry_run: Validate the file without creating tasks
+//api:changelog 0.2.0 added parameter POST /tasks/import?format: Import format, csv (default) or ndjson to restore a backup
+//api:changelog 0.2.0 added parameter POST /tasks/import?async: Import in the background and return 202 with a job to poll at GET /jobs/{id}
+//api:changelog 0.2.0 added error invalid_csv: The uploaded file is not CSV or its header row is unusable
+func (h *TaskHandler) ImportTasks(w http.ResponseWriter, r *http.Request) {
+	dryRun := false
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			h.respondWithError(w, r, http.StatusBadRequest, CodeInvalidQuery, "dry_run must be true or false")
+			return
+		}
+		dryRun = b
+	}
+	format := cmp.Or(r.URL.Query().Get("format"), "csv")
+	if format != "csv" && format != "ndjson" {
+		h.respondWithError(w, r, http.StatusBadRequest, CodeInvalidQuery, "format must be csv or ndjson")
+		return
+	}
+
+	async, ok := h.asyncQuery(w, r)
+	if !ok {
+		return
+	}
+
+	// CSV files are parsed whole, so only NDJSON files may exceed the
+	// maximum body size
+	if format == "csv" {
+		r.Body = http.MaxBytesReader(w, r.Body, h.maxBodyBytes)
+	}
+	body, err := importBody(r)
+	if err != nil {
+		code := CodeInvalidCSV
+		if format == "ndjson" {
+			code = CodeInvalidJSON
+		}
+		h.respondWithError(w, r, http.StatusBadRequest, code, err.Error())
+		return
+	}
+
+	if async {
+		h.startImport(w, r, body, format, dryRun)
+		return
+	}
+
+	ctx := h.taskContext(r)
+	if format == "ndjson" {
+		h.respondWithImport(w, r, h.importNDJSON(ctx, body, dryRun, func(int, int) {}))
+		return
+	}
+
+	result, err := h.importCSV(ctx, body, dryRun, func(int, int) {})
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.respondWithError(w, r, http.StatusRequestEntityTooLarge, CodeBodyTooLarge,
+				fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
+			return
+		}
+		h.respondWithError(w, r, http.StatusBadRequest, CodeInvalidCSV, err.Error())
+		return
+	}
+	h.respondWithImport(w, r, result)
+}
+
+// importCSV imports the CSV file in body, calling progress with the rows
+// done and the total after each row. The whole file is parsed first, so a
+// malformed one creates nothing and returns an error.
+func (h *TaskHandler) importCSV(ctx context.Context, body io.Reader, dryRun bool, progress func(done, total int)) (models.ImportResult, error) {
+	rows, err := readImport(body)
+	if err != nil {
+		return models.ImportResult{}, err
+	}
+
+	result := models.ImportResult{DryRun: dryRun, Rows: len(rows), Errors: []models.ImportRowError{}}
+	for i, row := range rows {
+		if len(row.errs) > 0 {
+			addRow(&result, 0, row.errs)
+		} else {
+			id, errs := h.importRow(ctx, row, dryRun)
+			addRow(&result, id, errs)
+		}
+		progress(i+1, len(rows))
+	}
+	return result, nil
+}
+
+// asyncQuery parses ?async=, writing a 400 and returning false when it is
+// not a boolean
+func (h *TaskHandler) asyncQuery(w http.ResponseWriter, r *http.Request) (bool, bool) {
+	v := r.URL.Query().Get("async")
+	if v == "" {
+		return false, true
+	}
+	async, err := strconv.ParseBool(v)
+	if err != nil {
+		h.respondWithError(w, r, http.StatusBadRequest, CodeInvalidQuery, "async must be true or false")
+		return false, false
+	}
+	return async, true
+}
+
+// addRow records the outcome of importing one row in result
+func addRow(result *models.ImportResult, id int64, errs []models.ImportRowError) {
+	if len(errs) > 0 {
+		result.Failed++
+		result.Errors = append(result.Errors, errs...)
+		return
+	}
+	result.Imported++
+	if id != 0 {
+		result.TaskIDs = append(result.TaskIDs, id)
+	}
+}
+
+// respondWithImport logs and writes an import report
+func (h *TaskHandler) respondWithImport(w http.ResponseWriter, r *http.Request, result models.ImportResult) {
+	logging.FromContext(r.Context()).Info("tasks imported",
+		slog.Bool("dry_run", result.DryRun),
+		slog.Int("rows", result.Rows),
+		slog.Int("imported", result.Imported),
+		slog.Int("failed", result.Failed),
+	)
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+// importBody returns the uploaded file: the "file" part of a multipart
+// form, or the request body itself
+func importBody(r *http.Request) (io.Reader, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return r.Body, nil
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, fmt.Errorf("invalid multipart body: %v", err)
+	}
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			return nil, errors.New(`multipart body has no "file" field`)
+		}
+		if part.FormName() == "file" {
+			return part, nil
+		}
+	}
+}
+
+// readImport parses the CSV file into rows. Rows with the wrong number of
+// fields are kept and reported; any other syntax error fails the import.
+func readImport(body io.Reader) ([]importRow, error) {
+	// Skip the UTF-8 byte order mark some spreadsheets write
+	br := bufio.NewReader(body)
+	if bom, _ := br.Peek(3); string(bom) == "\xef\xbb\xbf" {
+		br.Discard(3)
+	}
+	cr := csv.NewReader(br)
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("CSV file is empty")
+	}
+	if err != nil {
+		return nil, describeCSVError(err)
+	}
+	cols, err := importColumns(header)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []importRow{}
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil && !errors.Is(err, csv.ErrFieldCount) {
+			return nil, describeCSVError(err)
+		}
+		line, _ := cr.FieldPos(0)
+
+		row := importRow{line: line}
+		if err != nil {
+			row.errs = []models.ImportRowError{{Row: line, Code: codeFieldCount, Message: "row has the wrong number of fields"}}
+			rows = append(rows, row)
+			continue
+		}
+
+		row.req = models.UpdateTaskRequest{
+			Title:       unescapeFormula(record[cols["title"]]),
+			Description: field(record, cols, "description"),
+			Status:      models.TaskStatus(field(record, cols, "status")),
+		}
+		if row.req.Status == "" {
+			row.req.Status = models.StatusTodo
+		}
+		if v := field(record, cols, "due_at"); v != "" {
+			due, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				row.errs = []models.ImportRowError{{Row: line, Field: "due_at", Code: validation.RuleFormat, Message: "due_at must be an RFC 3339 date-time"}}
+			}
+			row.req.DueAt = &due
+		}
+		if v := field(record, cols, "estimate"); v != "" {
+			estimate, err := strconv.Atoi(v)
+			if err != nil {
+				row.errs = append(row.errs, models.ImportRowError{Row: line, Field: "estimate", Code: validation.RuleFormat, Message: "estimate must be a whole number"})
+			}
+			row.req.Estimate = estimate
+		}
+		rows = append(rows, row)
+	}
+}
+
+// describeCSVError turns a CSV parsing error into a message for clients,
+// passing body size errors through
+func describeCSVError(err error) error {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return fmt.Errorf("invalid CSV on line %d: %v", parseErr.Line, parseErr.Err)
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return err
+	}
+	return fmt.Errorf("invalid CSV: %v", err)
+}
+
+// importColumns maps the column names in header to their index. Names are
+// matched case-insensitively.
+func importColumns(header []string) (map[string]int, error) {
+	cols := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "title", "description", "status", "due_at", "estimate":
+		case "id", "workspace_id", "owner_id", "created_at", "updated_at":
+			continue
+		default:
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		if _, dup := cols[name]; dup {
+			return nil, fmt.Errorf("duplicate column %q", name)
+		}
+		cols[name] = i
+	}
+	if _, ok := cols["title"]; !ok {
+		return nil, errors.New(`header row must include a "title" column`)
+	}
+	return cols, nil
+}
+
+// field returns the named optional column of record, or "" when absent
+func field(record []string, cols map[string]int, name string) string {
+	i, ok := cols[name]
+	if !ok {
+		return ""
+	}
+	return unescapeFormula(record[i])
+}
+
+// importRow validates row and, outside a dry run, creates its task. It
+// returns the new task's ID, or the row's errors.
+func (h *TaskHandler) importRow(ctx context.Context, row importRow, dryRun bool) (int64, []models.ImportRowError) {
+	created, err := h.tasks.Import(ctx, row.req, dryRun)
+	var verrs validation.Errors
+	var rejections content.Rejections
+	switch {
+	case err == nil && created == nil:
+		return 0, nil
+	case err == nil:
+		return created.ID, nil
+	case errors.As(err, &verrs):
+		errs := make([]models.ImportRowError, len(verrs))
+		for i, v := range verrs {
+			errs[i] = models.ImportRowError{Row: row.line, Field: v.Field, Code: v.Rule, Message: v.Message}
+		}
+		return 0, errs
+	case errors.As(err, &rejections):
+		errs := make([]models.ImportRowError, len(rejections))
+		for i, rej := range rejections {
+			errs[i] = models.ImportRowError{Row: row.line, Field: rej.Field, Code: rej.Processor, Message: rej.Message}
+		}
+		return 0, errs
+	case errors.Is(err, service.ErrInvalid):
+		return 0, []models.ImportRowError{{Row: row.line, Code: CodeValidationFailed, Message: err.Error()}}
+	case errors.Is(err, service.ErrContentRejected):
+		return 0, []models.ImportRowError{{Row: row.line, Code: CodeContentRejected, Message: err.Error()}}
+	case errors.Is(err, repository.ErrTaskLimitReached):
+		return 0, []models.ImportRowError{{Row: row.line, Code: CodeTaskLimitReached, Message: "task limit reached"}}
+	case errors.Is(err, repository.ErrWorkspaceNotFound):
+		return 0, []models.ImportRowError{{Row: row.line, Code: CodeWorkspaceNotFound, Message: "workspace not found"}}
+	default:
+		logging.FromContext(ctx).Error("failed to import task", slog.Int("row", row.line), slog.Any("error", err))
+		return 0, []models.ImportRowError{{Row: row.line, Code: CodeInternal, Message: "failed to create task"}}
+	}
+}
@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+// importCSV calls ImportTasks with body and the given query string
+func importCSV(handler *TaskHandler, query, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/tasks/import?"+query, strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/csv")
+	handler.ImportTasks(rec, req)
+	return rec
+}
+
+// decodeImport decodes an import report, failing unless the status is 200
+func decodeImport(t *testing.T, rec *httptest.ResponseRecorder) models.ImportResult {
+	t.Helper()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("import status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var result models.ImportResult
+	if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestTaskHandler_ExportTasks(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	seedBulk(repo)
+	repo.Create(context.Background(), &models.Task{Title: "=HYPERLINK(\"http://evil\")", Description: "a, \"quoted\"\nline"})
+	handler := NewTaskHandler(repo)
+
+	export := func(query string) (*httptest.ResponseRecorder, [][]string) {
+		rec := httptest.NewRecorder()
+		handler.ExportTasks(rec, httptest.NewRequest("GET", "/tasks/export?"+query, nil))
+		records, _ := csv.NewReader(bytes.NewReader(rec.Body.Bytes())).ReadAll()
+		return rec, records
+	}
+
+	rec, records := export("format=csv")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/csv; charset=utf-8" {
+		t.Errorf("Content-Type = %q, want text/csv", ct)
+	}
+	if len(records) != 5 || strings.Join(records[0], ",") != strings.Join(exportColumns, ",") {
+		t.Fatalf("records = %q, want a header and 4 tasks", records)
+	}
+	last := records[4]
+	if last[2] != "'=HYPERLINK(\"http://evil\")" {
+		t.Errorf("title = %q, want the formula escaped", last[2])
+	}
+	if last[3] != "a, \"quoted\"\nline" {
+		t.Errorf("description = %q, want it round-tripped", last[3])
+	}
+
+	if _, records := export("status=done"); len(records) != 3 {
+		t.Errorf("status=done exported %d rows, want 2 tasks", len(records)-1)
+	}
+	if _, records := export("q=old"); len(records) != 3 {
+		t.Errorf("q=old exported %d rows, want 2 tasks", len(records)-1)
+	}
+	if _, records := export("redact=true"); records[4][2] != "'=HYPERLINK(\"http://evil\")" || records[4][3] != "[REDACTED]" {
+		t.Errorf("redact=true exported %q, want the description masked", records[4])
+	}
+	for _, query := range []string{"format=xlsx", "status=doing", "redact=maybe"} {
+		if rec, _ := export(query); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %v, want %v", query, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestTaskHandler_ImportTasks(t *testing.T) {
+	ctx := context.Background()
+	file := "\ufeffTitle,description,status,id\n" +
+		"Write report,Quarterly,todo,17\n" +
+		"'=SUM(A1),,done,\n" +
+		",No title,,\n" +
+		"Bad status,,doing,\n" +
+		"Too,many,fields,here,now\n"
+
+	t.Run("dry run", func(t *testing.T) {
+		repo := repository.NewMemoryRepository()
+		result := decodeImport(t, importCSV(NewTaskHandler(repo), "dry_run=true", file))
+
+		if !result.DryRun || result.Rows != 5 || result.Imported != 2 || result.Failed != 3 {
+			t.Errorf("result = %+v, want 5 rows, 2 importable, 3 failed", result)
+		}
+		if all, _ := repo.GetAll(ctx); len(all) != 0 {
+			t.Errorf("dry run created %d tasks", len(all))
+		}
+
+		want := []models.ImportRowError{
+			{Row: 4, Field: "title", Code: "required"},
+			{Row: 5, Field: "status", Code: "one_of"},
+			{Row: 6, Code: codeFieldCount},
+		}
+		if len(result.Errors) != len(want) {
+			t.Fatalf("errors = %+v, want %d", result.Errors, len(want))
+		}
+		for i, w := range want {
+			got := result.Errors[i]
+			if got.Row != w.Row || got.Field != w.Field || got.Code != w.Code {
+				t.Errorf("errors[%d] = %+v, want row %d field %q code %q", i, got, w.Row, w.Field, w.Code)
+			}
+		}
+	})
+
+	t.Run("import", func(t *testing.T) {
+		repo := repository.NewMemoryRepository()
+		result := decodeImport(t, importCSV(NewTaskHandler(repo), "", file))
+
+		if result.DryRun || result.Imported != 2 || len(result.TaskIDs) != 2 {
+			t.Fatalf("result = %+v, want 2 imported", result)
+		}
+		all, _ := repo.List(ctx, repository.ListOptions{})
+		if len(all) != 2 {
+			t.Fatalf("created %d tasks, want 2", len(all))
+		}
+		if all[0].Title != "Write report" || all[0].Description != "Quarterly" || all[0].ID == 17 {
+			t.Errorf("first task = %+v", all[0])
+		}
+		if all[1].Title != "=SUM(A1)" || all[1].Status != models.StatusDone {
+			t.Errorf("second task = %+v, want the formula unescaped and done", all[1])
+		}
+	})
+
+	t.Run("multipart", func(t *testing.T) {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		fw, _ := mw.CreateFormFile("file", "tasks.csv")
+		fw.Write([]byte("title\nFrom a form\n"))
+		mw.Close()
+
+		repo := repository.NewMemoryRepository()
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/tasks/import", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		NewTaskHandler(repo).ImportTasks(rec, req)
+
+		if result := decodeImport(t, rec); result.Imported != 1 {
+			t.Errorf("result = %+v, want 1 imported", result)
+		}
+	})
+
+	t.Run("rejected files", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			query  string
+			body   string
+			status int
+			code   string
+		}{
+			{"empty", "", "", http.StatusBadRequest, CodeInvalidCSV},
+			{"no title column", "", "description\nx\n", http.StatusBadRequest, CodeInvalidCSV},
+			{"unknown column", "", "title,priority\nx,1\n", http.StatusBadRequest, CodeInvalidCSV},
+			{"malformed", "", "title\n\"unterminated\n", http.StatusBadRequest, CodeInvalidCSV},
+			{"bad dry_run", "dry_run=maybe", "title\nx\n", http.StatusBadRequest, CodeInvalidQuery},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				repo := repository.NewMemoryRepository()
+				rec := importCSV(NewTaskHandler(repo), tt.query, tt.body)
+				if rec.Code != tt.status {
+					t.Fatalf("status = %v, want %v: %s", rec.Code, tt.status, rec.Body)
+				}
+				var resp ErrorResponse
+				json.NewDecoder(rec.Body).Decode(&resp)
+				if resp.Code != tt.code {
+					t.Errorf("code = %q, want %q", resp.Code, tt.code)
+				}
+				if all, _ := repo.GetAll(context.Background()); len(all) != 0 {
+					t.Errorf("rejected file created %d tasks", len(all))
+				}
+			})
+		}
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		handler := NewTaskHandler(repository.NewMemoryRepository(), WithMaxBodyBytes(32))
+		rec := importCSV(handler, "", "title\n"+strings.Repeat("a task\n", 20))
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("status = %v, want %v", rec.Code, http.StatusRequestEntityTooLarge)
+		}
+	})
+}
+
+func TestTaskHandler_NDJSONBackup(t *testing.T) {
+	ctx := context.Background()
+	source := repository.NewMemoryRepository()
+	seedBulk(source)
+	all, _ := source.List(ctx, repository.ListOptions{})
+	source.AddLink(ctx, all[0].ID, models.TaskLink{Type: models.LinkRelatesTo, TaskID: all[2].ID})
+
+	rec := httptest.NewRecorder()
+	NewTaskHandler(source).ExportTasks(rec, httptest.NewRequest("GET", "/tasks/export?format=ndjson", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Fatalf("Content-Type = %q, want application/x-ndjson", ct)
+	}
+	backup := rec.Body.String()
+	if lines := strings.Count(backup, "\n"); lines != 3 {
+		t.Fatalf("backup has %d lines, want 3:\n%s", lines, backup)
+	}
+
+	t.Run("restore", func(t *testing.T) {
+		target := repository.NewMemoryRepository()
+		target.Create(ctx, &models.Task{Title: "Already here"})
+		rec := importCSV(NewTaskHandler(target), "format=ndjson", backup)
+		result := decodeImport(t, rec)
+		if result.Imported != 3 || len(result.Errors) != 0 {
+			t.Fatalf("result = %+v, want 3 imported", result)
+		}
+
+		restored, _ := target.List(ctx, repository.ListOptions{})
+		if len(restored) != 4 {
+			t.Fatalf("restored %d tasks, want 4", len(restored))
+		}
+		first, last := restored[1], restored[3]
+		if first.Title != all[0].Title || first.Status != models.StatusDone {
+			t.Errorf("first restored task = %+v, want %q done", first, all[0].Title)
+		}
+		if len(first.Links) != 1 || first.Links[0].TaskID != last.ID {
+			t.Errorf("links = %+v, want one to the new ID %d", first.Links, last.ID)
+		}
+	})
+
+	t.Run("dry run with bad lines", func(t *testing.T) {
+		target := repository.NewMemoryRepository()
+		file := `{"id":1,"title":"Kept","links":[{"type":"relates_to","task_id":9}]}` + "\n" +
+			"\n" +
+			`{"title":""}` + "\n" +
+			`{"title":"x","priority":1}` + "\n"
+		result := decodeImport(t, importCSV(NewTaskHandler(target), "format=ndjson&dry_run=true", file))
+
+		if result.Rows != 3 || result.Imported != 1 || result.Failed != 2 {
+			t.Errorf("result = %+v, want 3 rows, 1 importable, 2 failed", result)
+		}
+		codes := []string{}
+		for _, e := range result.Errors {
+			codes = append(codes, fmt.Sprintf("%d:%s", e.Row, e.Code))
+		}
+		if got := strings.Join(codes, ","); got != "3:required,4:invalid_json,1:link_target_not_found" {
+			t.Errorf("errors = %s", got)
+		}
+		if tasks, _ := target.GetAll(ctx); len(tasks) != 0 {
+			t.Errorf("dry run created %d tasks", len(tasks))
+		}
+	})
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/light-bringer/cert-tasks/internal/logging"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/projection"
+)
+
+// taskFields are the task fields ?fields= can select
+var taskFields = projection.NewSchema(models.Task{})
+
+// parseFields reads the ?fields= selection of a task response, writing a
+// 400 response if it names an unknown field
+//
+//api:changelog 0.2.0 added parameter GET /tasks?fields: Comma-separated task fields to return, e.g. id,title,status; other fields are left out
+//api:changelog 0.2.0 added parameter GET /tasks/{id}?fields: Comma-separated task fields to return, e.g. id,title,status; other fields are left out
+func (h *TaskHandler) parseFields(w http.ResponseWriter, r *http.Request) (projection.Fields, bool) {
+	fields, err := taskFields.Parse(r.URL.Query().Get("fields"))
+	if err != nil {
+		h.respondWithError(w, r, http.StatusBadRequest, CodeInvalidQuery, "fields: "+err.Error())
+		return projection.Fields{}, false
+	}
+	return fields, true
+}
+
+// respondWithFields writes a task or task list trimmed to fields, with an
+// ETag of the trimmed body
+func (h *TaskHandler) respondWithFields(w http.ResponseWriter, r *http.Request, fields projection.Fields, payload any) {
+	projected, err := taskFields.Apply(fields, payload)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("projecting response", slog.Any("error", err))
+		h.respondWithError(w, r, http.StatusInternalServerError, CodeInternal, "failed to encode response")
+		return
+	}
+	respondWithETag(w, r, http.StatusOK, projected)
+}
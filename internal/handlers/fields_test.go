@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+func TestTaskHandler_Fields(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	handler := NewTaskHandler(repo)
+	repo.Create(ctx, &models.Task{Title: "Renew cert", Description: "Long text"})
+	repo.Create(ctx, &models.Task{Title: "Rotate keys"})
+
+	serve := func(target string, h http.HandlerFunc) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", "1")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		return rec
+	}
+
+	rec := serve("/tasks?fields=id,title&limit=1", handler.ListTasks)
+	var list []map[string]any
+	json.NewDecoder(rec.Body).Decode(&list)
+	if rec.Code != http.StatusOK || len(list) != 1 || len(list[0]) != 2 || list[0]["title"] != "Renew cert" {
+		t.Errorf("list: status %d, body %v", rec.Code, list)
+	}
+	if rec.Header().Get("X-Next-Cursor") != "1" {
+		t.Errorf("X-Next-Cursor = %q, want 1", rec.Header().Get("X-Next-Cursor"))
+	}
+
+	rec = serve("/tasks?q=rotate&fields=status", handler.ListTasks)
+	if rec.Code != http.StatusOK || rec.Body.String() != `[{"status":"todo"}]`+"\n" {
+		t.Errorf("search: status %d, body %s", rec.Code, rec.Body)
+	}
+
+	rec = serve("/tasks/1?fields=description", handler.GetTask)
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"description":"Long text"}`+"\n" {
+		t.Errorf("get: status %d, body %s", rec.Code, rec.Body)
+	}
+	// The trimmed body has its own ETag
+	if full := serve("/tasks/1", handler.GetTask); full.Header().Get("ETag") == rec.Header().Get("ETag") {
+		t.Error("trimmed and full task share an ETag")
+	}
+
+	for _, target := range []string{"/tasks?fields=id,owner", "/tasks/1?fields=Title"} {
+		rec := serve(target, handler.GetTask)
+		var errResp ErrorResponse
+		json.NewDecoder(rec.Body).Decode(&errResp)
+		if rec.Code != http.StatusBadRequest || errResp.Code != CodeInvalidQuery {
+			t.Errorf("%s: status %d, code %q", target, rec.Code, errResp.Code)
+		}
+	}
+}
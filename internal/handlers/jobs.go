@@ -0,0 +1,504 @@
+package handlers
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/light-bringer/cert-tasks/internal/blob"
+	"github.com/light-bringer/cert-tasks/internal/jobs"
+	"github.com/light-bringer/cert-tasks/internal/logging"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+	"github.com/light-bringer/cert-tasks/internal/service"
+)
+
+// Job kinds run on the queue for ?async=true imports and exports
+const (
+	jobKindImport = "tasks.import"
+	jobKindExport = "tasks.export"
+)
+
+// ExportRetention is how long the file of a finished export can be
+// downloaded
+const ExportRetention = 24 * time.Hour
+
+// taskJobs runs imports and exports on a job queue, keeping uploads in dir
+// and finished exports in a blob store
+type taskJobs struct {
+	queue   *jobs.Queue
+	dir     string
+	exports blob.Store
+}
+
+// WithJobs runs imports and exports asked for with ?async=true on queue,
+// keeping uploads in dir and finished exports in the exports store, and
+// enables the /jobs/{id} endpoints and user exports. Jobs are served only
+// to the workspace and owner that started them.
+func WithJobs(queue *jobs.Queue, dir string, exports blob.Store) Option {
+	return func(h *TaskHandler) {
+		h.jobs = &taskJobs{queue: queue, dir: dir, exports: exports}
+		queue.Register(jobKindImport, h.runImport)
+		queue.Register(jobKindExport, h.runExport)
+		queue.Register(jobKindUserExport, h.runUserExport)
+	}
+}
+
+// downloadLinks signs URLs downloading finished exports without
+// credentials
+type downloadLinks struct {
+	signer blob.Signer
+	ttl    time.Duration
+}
+
+// WithDownloadLinks adds a signed_url valid for ttl to finished exports.
+// When signer is a *blob.Links, GET /blobs/{name} serves its links.
+func WithDownloadLinks(signer blob.Signer, ttl time.Duration) Option {
+	return func(h *TaskHandler) {
+		h.links = &downloadLinks{signer: signer, ttl: ttl}
+	}
+}
+
+// jobScope is who started a job: its repository scope and the tenant
+// selecting its content policies
+type jobScope struct {
+	Workspace string `json:"workspace,omitempty"`
+	Owner     string `json:"owner,omitempty"`
+	Tenant    string `json:"tenant,omitempty"`
+}
+
+// scopeOf returns the scope r acts in
+func scopeOf(r *http.Request) jobScope {
+	return jobScope{
+		Workspace: repository.WorkspaceFromContext(r.Context()),
+		Owner:     repository.OwnerFromContext(r.Context()),
+		Tenant:    tenantFromRequest(r),
+	}
+}
+
+// context scopes repository calls made with ctx like the request that
+// started the job
+func (s jobScope) context(ctx context.Context) context.Context {
+	if s.Workspace != "" {
+		ctx = repository.WithWorkspace(ctx, s.Workspace)
+	}
+	if s.Owner != "" {
+		ctx = repository.WithOwner(ctx, s.Owner)
+	}
+	return ctx
+}
+
+// importJob is the payload of an import job
+type importJob struct {
+	jobScope
+	Format string `json:"format"`
+	DryRun bool   `json:"dry_run,omitempty"`
+	File   string `json:"file"`
+}
+
+// exportJob is the payload of an export job
+type exportJob struct {
+	jobScope
+	Format string            `json:"format"`
+	Status models.TaskStatus `json:"status,omitempty"`
+	Query  string            `json:"q,omitempty"`
+	Redact bool              `json:"redact,omitempty"`
+}
+
+// startImport stores the uploaded file and queues a job importing it,
+// responding 202 with the job
+func (h *TaskHandler) startImport(w http.ResponseWriter, r *http.Request, body io.Reader, format string, dryRun bool) {
+	if !h.jobsEnabled(w, r) {
+		return
+	}
+
+	f, err := os.CreateTemp(h.jobs.dir, "import-*."+format)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to store upload", slog.Any("error", err))
+		h.respondWithError(w, r, http.StatusInternalServerError, CodeInternal, "failed to store upload")
+		return
+	}
+	_, err = io.Copy(f, body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.respondWithError(w, r, http.StatusRequestEntityTooLarge, CodeBodyTooLarge,
+				fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
+			return
+		}
+		logging.FromContext(r.Context()).Error("failed to store upload", slog.Any("error", err))
+		h.respondWithError(w, r, http.StatusInternalServerError, CodeInternal, "failed to store upload")
+		return
+	}
+
+	job, err := h.jobs.queue.Enqueue(jobKindImport, importJob{jobScope: scopeOf(r), Format: format, DryRun: dryRun, File: f.Name()})
+	if err != nil {
+		os.Remove(f.Name())
+		logging.FromContext(r.Context()).Error("failed to queue import", slog.Any("error", err))
+		h.respondWithError(w, r, http.StatusInternalServerError, CodeInternal, "failed to queue import")
+		return
+	}
+	logging.FromContext(r.Context()).Info("import queued", slog.Int64("job_id", job.ID), slog.String("format", format))
+	h.respondWithJob(w, http.StatusAccepted, job)
+}
+
+// runImport imports a stored upload and keeps the per-row report as the
+// job's result. Rows are created as they are read, so an import is not
+// interrupted by shutdown, and a malformed file fails the job without a
+// retry.
+func (h *TaskHandler) runImport(ctx context.Context, job jobs.Job) error {
+	var p importJob
+	if err := json.Unmarshal(job.Payload, &p); err != nil {
+		return jobs.Permanent(fmt.Errorf("decoding import job: %w", err))
+	}
+	f, err := os.Open(p.File)
+	if err != nil {
+		return jobs.Permanent(fmt.Errorf("upload is no longer available: %w", err))
+	}
+	defer os.Remove(p.File)
+	defer f.Close()
+
+	ctx = service.WithTenant(p.context(context.WithoutCancel(ctx)), p.Tenant)
+	progress := func(done, total int) { h.jobs.queue.Report(job.ID, done, total) }
+
+	var result models.ImportResult
+	if p.Format == "ndjson" {
+		result = h.importNDJSON(ctx, f, p.DryRun, progress)
+	} else if result, err = h.importCSV(ctx, f, p.DryRun, progress); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	logging.FromContext(ctx).Info("tasks imported",
+		slog.Int64("job_id", job.ID),
+		slog.Bool("dry_run", result.DryRun),
+		slog.Int("rows", result.Rows),
+		slog.Int("imported", result.Imported),
+		slog.Int("failed", result.Failed),
+	)
+	return h.jobs.queue.SetResult(job.ID, result)
+}
+
+// startExport queues a job exporting the tasks filter selects, responding
+// 202 with the job
+func (h *TaskHandler) startExport(w http.ResponseWriter, r *http.Request, format string, filter bulkFilter, redacted bool) {
+	if !h.jobsEnabled(w, r) {
+		return
+	}
+
+	job, err := h.jobs.queue.Enqueue(jobKindExport, exportJob{jobScope: scopeOf(r), Format: format, Status: filter.status, Query: filter.query, Redact: redacted})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to queue export", slog.Any("error", err))
+		h.respondWithError(w, r, http.StatusInternalServerError, CodeInternal, "failed to queue export")
+		return
+	}
+	logging.FromContext(r.Context()).Info("export queued", slog.Int64("job_id", job.ID), slog.String("format", format))
+	h.respondWithJob(w, http.StatusAccepted, job)
+}
+
+// runExport writes the export to a temporary file in the jobs directory
+// and stores it once complete. Exports are read-only, so a failed one is
+// simply run again.
+func (h *TaskHandler) runExport(ctx context.Context, job jobs.Job) error {
+	var p exportJob
+	if err := json.Unmarshal(job.Payload, &p); err != nil {
+		return jobs.Permanent(fmt.Errorf("decoding export job: %w", err))
+	}
+	format, ok := exportFormats[p.Format]
+	if !ok {
+		return jobs.Permanent(fmt.Errorf("unknown export format %q", p.Format))
+	}
+	h.jobs.pruneExports(ctx)
+
+	ctx = p.context(ctx)
+	filter := bulkFilter{status: p.Status, query: p.Query}
+	total := 0
+	if filter.query == "" {
+		n, err := h.repo.Count(ctx, repository.TaskFilter{Status: filter.status})
+		if err != nil {
+			return err
+		}
+		total = n
+	}
+
+	f, err := os.CreateTemp(h.jobs.dir, "export-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	next := h.exportPages(ctx, filter)
+	tasks, err := next()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	defer f.Close()
+	w := &errWriter{w: f}
+	exported, err := writeExport(h.exportEncoder(format, w, p.Redact), filter, tasks, next, func(exported int) {
+		h.jobs.queue.Report(job.ID, exported, max(total, exported))
+	})
+	if err == nil {
+		err = w.err
+	}
+	if err != nil {
+		return err
+	}
+	if err := h.jobs.store(ctx, exportName(job.ID, p.Format), f); err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).Info("tasks exported", slog.Int64("job_id", job.ID), slog.Int("tasks", exported))
+	return nil
+}
+
+// store puts the export written to f, a temporary file, in the exports
+// store under name
+func (j *taskJobs) store(ctx context.Context, name string, f *os.File) error {
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := j.exports.Put(ctx, name, f, size); err != nil {
+		return fmt.Errorf("storing export: %w", err)
+	}
+	return nil
+}
+
+// errWriter remembers the first error writing to w, since export encoders
+// do not report them
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) Write(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	n, err := e.w.Write(p)
+	e.err = err
+	return n, err
+}
+
+// exportName is the name the finished export of job id is stored under
+func exportName(id int64, format string) string {
+	return fmt.Sprintf("export-%d.%s", id, format)
+}
+
+// pruneExports removes finished exports older than ExportRetention
+func (j *taskJobs) pruneExports(ctx context.Context) {
+	exports, err := j.exports.List(ctx, "export-")
+	if err != nil {
+		logging.FromContext(ctx).Warn("listing exports failed", slog.Any("error", err))
+		return
+	}
+	cutoff := time.Now().Add(-ExportRetention)
+	for _, e := range exports {
+		if e.ModTime.Before(cutoff) {
+			j.exports.Delete(ctx, e.Name)
+		}
+	}
+}
+
+// GetJob handles GET /jobs/{id}, reporting the progress of an asynchronous
+// import or export and, once finished, its outcome
+//
+//api:changelog 0.2.0 added endpoint GET /jobs/{id}: Progress of an import or export started with ?async=true, with the import report or a download link once finished
+func (h *TaskHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.findJob(w, r)
+	if !ok {
+		return
+	}
+	tj := taskJob(job)
+	var p exportJob
+	if tj.DownloadURL != "" && json.Unmarshal(job.Payload, &p) == nil {
+		format := exportFormats[p.Format]
+		h.signDownload(r, &tj, exportName(job.ID, p.Format), blob.Download{Filename: format.filename, ContentType: format.contentType})
+	}
+	respondWithJSON(w, http.StatusOK, tj)
+}
+
+// signDownload adds a signed URL of the export stored under name to tj,
+// when download links are enabled. The job is still served without one if
+// signing fails.
+func (h *TaskHandler) signDownload(r *http.Request, tj *models.TaskJob, name string, d blob.Download) {
+	if h.links == nil {
+		return
+	}
+	expires := time.Now().Add(h.links.ttl).UTC().Truncate(time.Second)
+	signed, err := h.links.signer.SignedURL(name, h.links.ttl, d)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("signing download link failed", slog.Any("error", err))
+		return
+	}
+	tj.SignedURL = signed
+	tj.SignedURLExpiresAt = &expires
+}
+
+// DownloadJob handles GET /jobs/{id}/download, serving the file of a
+// finished export for ExportRetention
+//
+//api:changelog 0.2.0 added endpoint GET /jobs/{id}/download: Download the file of a finished asynchronous export
+func (h *TaskHandler) DownloadJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.findJob(w, r)
+	if !ok {
+		return
+	}
+	var p exportJob
+	if job.Kind != jobKindExport || json.Unmarshal(job.Payload, &p) != nil {
+		h.respondWithError(w, r, http.StatusNotFound, CodeNotFound, "job has no download")
+		return
+	}
+	format := exportFormats[p.Format]
+	h.serveExport(w, r, job, exportName(job.ID, p.Format), blob.Download{Filename: format.filename, ContentType: format.contentType})
+}
+
+// serveExport writes the file of a finished export job, stored under name
+func (h *TaskHandler) serveExport(w http.ResponseWriter, r *http.Request, job jobs.Job, name string, d blob.Download) {
+	if job.State != jobs.StateSucceeded {
+		h.respondWithError(w, r, http.StatusConflict, CodeConflict, "export is not finished")
+		return
+	}
+
+	f, err := h.jobs.exports.Get(r.Context(), name)
+	if errors.Is(err, blob.ErrNotFound) {
+		h.respondWithError(w, r, http.StatusNotFound, CodeNotFound, "export has expired")
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to read export", slog.Any("error", err))
+		h.respondWithError(w, r, http.StatusInternalServerError, CodeInternal, "failed to read export")
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", d.ContentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+d.Filename+`"`)
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, f)
+}
+
+// DownloadBlob handles GET /blobs/{name}, a download link signed by the
+// server for a blob store that cannot sign its own. The signature stands
+// in for credentials, so the link can be handed to a browser.
+//
+//api:changelog 0.2.0 added endpoint GET /blobs/{name}: Download a file, such as a finished export, through a signed link without credentials
+func (h *TaskHandler) DownloadBlob(w http.ResponseWriter, r *http.Request) {
+	var links *blob.Links
+	if h.links != nil {
+		links, _ = h.links.signer.(*blob.Links)
+	}
+	if links == nil {
+		h.respondWithError(w, r, http.StatusNotImplemented, CodeNotImplemented, "download links are not served by this server")
+		return
+	}
+
+	f, d, err := links.Open(r.Context(), chi.URLParam(r, "name"), r.URL.Query())
+	switch {
+	case errors.Is(err, blob.ErrInvalidLink):
+		h.respondWithError(w, r, http.StatusForbidden, CodeForbidden, "invalid or expired download link")
+		return
+	case errors.Is(err, blob.ErrNotFound):
+		h.respondWithError(w, r, http.StatusNotFound, CodeNotFound, "file has expired")
+		return
+	case err != nil:
+		logging.FromContext(r.Context()).Error("failed to read file", slog.Any("error", err))
+		h.respondWithError(w, r, http.StatusInternalServerError, CodeInternal, "failed to read file")
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", cmp.Or(d.ContentType, "application/octet-stream"))
+	if d.Filename != "" {
+		w.Header().Set("Content-Disposition", `attachment; filename="`+d.Filename+`"`)
+	}
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, f)
+}
+
+// findJob returns the import or export job named by the id parameter,
+// writing a 404 when it does not exist or was started by another
+// workspace or owner
+func (h *TaskHandler) findJob(w http.ResponseWriter, r *http.Request) (jobs.Job, bool) {
+	if !h.jobsEnabled(w, r) {
+		return jobs.Job{}, false
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id < 1 {
+		h.respondWithError(w, r, http.StatusBadRequest, CodeInvalidID, "invalid job ID")
+		return jobs.Job{}, false
+	}
+
+	job, ok := h.jobs.queue.Get(id)
+	var scope jobScope
+	if !ok || (job.Kind != jobKindImport && job.Kind != jobKindExport) ||
+		json.Unmarshal(job.Payload, &scope) != nil || scope != scopeOf(r) {
+		h.respondWithError(w, r, http.StatusNotFound, CodeNotFound, "job not found")
+		return jobs.Job{}, false
+	}
+	return job, true
+}
+
+// jobsEnabled writes a 501 and returns false when no job queue is
+// configured
+func (h *TaskHandler) jobsEnabled(w http.ResponseWriter, r *http.Request) bool {
+	if h.jobs == nil {
+		h.respondWithError(w, r, http.StatusNotImplemented, CodeNotImplemented, "background jobs are not enabled")
+		return false
+	}
+	return true
+}
+
+// respondWithJob writes job with a Location header pointing at it
+func (h *TaskHandler) respondWithJob(w http.ResponseWriter, status int, job jobs.Job) {
+	w.Header().Set("Location", fmt.Sprintf("/jobs/%d", job.ID))
+	respondWithJSON(w, status, taskJob(job))
+}
+
+// taskJob converts a queue record to its API form
+func taskJob(job jobs.Job) models.TaskJob {
+	tj := models.TaskJob{
+		ID:        job.ID,
+		Type:      models.JobExport,
+		State:     models.JobState(job.State),
+		Progress:  models.JobProgress{Done: job.Progress.Done, Total: job.Progress.Total},
+		Error:     job.LastError,
+		CreatedAt: job.CreatedAt,
+		UpdatedAt: job.UpdatedAt,
+	}
+	if !job.FinishedAt.IsZero() {
+		finished := job.FinishedAt
+		tj.FinishedAt = &finished
+	}
+
+	switch {
+	case job.Kind == jobKindImport:
+		tj.Type = models.JobImport
+		if len(job.Result) > 0 {
+			var result models.ImportResult
+			if json.Unmarshal(job.Result, &result) == nil {
+				tj.Result = &result
+			}
+		}
+	case job.State == jobs.StateSucceeded:
+		tj.DownloadURL = fmt.Sprintf("/jobs/%d/download", job.ID)
+	}
+	return tj
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/light-bringer/cert-tasks/internal/blob"
+	"github.com/light-bringer/cert-tasks/internal/jobs"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+// jobRouter serves the import, export and job endpoints of handler
+func jobRouter(handler *TaskHandler) http.Handler {
+	r := chi.NewRouter()
+	r.Post("/tasks/import", handler.ImportTasks)
+	r.Get("/tasks/export", handler.ExportTasks)
+	r.Get("/jobs/{id}", handler.GetJob)
+	r.Get("/jobs/{id}/download", handler.DownloadJob)
+	r.Get("/blobs/{name}", handler.DownloadBlob)
+	return r
+}
+
+// exportStore returns a blob store in a temporary directory
+func exportStore(t *testing.T) *blob.Dir {
+	t.Helper()
+	store, err := blob.NewDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+// startJob sends req, expecting 202 with a job and a Location pointing at it
+func startJob(t *testing.T, router http.Handler, req *http.Request) models.TaskJob {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	var job models.TaskJob
+	json.NewDecoder(rec.Body).Decode(&job)
+	if rec.Code != http.StatusAccepted || rec.Header().Get("Location") != "/jobs/"+itoa(job.ID) {
+		t.Fatalf("start = %v with Location %q: %+v", rec.Code, rec.Header().Get("Location"), job)
+	}
+	return job
+}
+
+// awaitJob polls GET /jobs/{id} until the job has finished
+func awaitJob(t *testing.T, router http.Handler, id int64) models.TaskJob {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", "/jobs/"+itoa(id), nil))
+		var job models.TaskJob
+		json.NewDecoder(rec.Body).Decode(&job)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET /jobs/%d = %v", id, rec.Code)
+		}
+		if job.State == models.JobSucceeded || job.State == models.JobFailed {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %d still %s", id, job.State)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// runQueue runs queue until the test ends
+func runQueue(t *testing.T, queue *jobs.Queue) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		queue.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+// itoa formats a job ID for a URL
+func itoa(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
+
+func TestTaskHandler_AsyncImport(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	queue := jobs.New(jobs.DefaultConfig())
+	handler := NewTaskHandler(repo, WithJobs(queue, t.TempDir(), exportStore(t)))
+	router := jobRouter(handler)
+	runQueue(t, queue)
+
+	t.Run("csv", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/tasks/import?async=true", strings.NewReader("title,status\nWrite report,todo\n,done\nShip it,done\n"))
+		job := startJob(t, router, req)
+		if job.Type != models.JobImport {
+			t.Errorf("Type = %q, want import", job.Type)
+		}
+
+		job = awaitJob(t, router, job.ID)
+		if job.State != models.JobSucceeded || job.Progress != (models.JobProgress{Done: 3, Total: 3}) ||
+			job.Result == nil || job.Result.Imported != 2 || job.Result.Failed != 1 || job.Result.Errors[0].Row != 3 {
+			t.Fatalf("job = %+v, result %+v", job, job.Result)
+		}
+		if all, _ := repo.GetAll(context.Background()); len(all) != 2 {
+			t.Errorf("%d tasks after import, want 2", len(all))
+		}
+	})
+
+	t.Run("malformed file", func(t *testing.T) {
+		job := startJob(t, router, httptest.NewRequest("POST", "/tasks/import?async=true", strings.NewReader("name\nx\n")))
+		job = awaitJob(t, router, job.ID)
+		if job.State != models.JobFailed || !strings.Contains(job.Error, `unknown column "name"`) {
+			t.Errorf("job = %+v, want failed with the CSV error", job)
+		}
+	})
+
+	t.Run("other workspace", func(t *testing.T) {
+		job := startJob(t, router, httptest.NewRequest("POST", "/tasks/import?async=true&dry_run=true", strings.NewReader("title\nx\n")))
+
+		req := httptest.NewRequest("GET", "/jobs/"+itoa(job.ID), nil)
+		req.Header.Set(TenantHeader, "acme")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("GET from another workspace = %v, want %v", rec.Code, http.StatusNotFound)
+		}
+	})
+}
+
+func TestTaskHandler_AsyncExport(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	seedBulk(repo)
+	queue := jobs.New(jobs.DefaultConfig())
+	store := exportStore(t)
+	handler := NewTaskHandler(repo, WithJobs(queue, t.TempDir(), store),
+		WithDownloadLinks(blob.NewLinks(store, []byte("secret"), "/blobs/"), time.Minute))
+	router := jobRouter(handler)
+
+	job := startJob(t, router, httptest.NewRequest("GET", "/tasks/export?async=true&format=ndjson&status=done", nil))
+
+	// Nothing to download until the export has run
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/jobs/"+itoa(job.ID)+"/download", nil))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("early download = %v, want %v", rec.Code, http.StatusConflict)
+	}
+
+	runQueue(t, queue)
+	job = awaitJob(t, router, job.ID)
+	if job.State != models.JobSucceeded || job.Progress.Done != 2 || job.DownloadURL != "/jobs/"+itoa(job.ID)+"/download" {
+		t.Fatalf("job = %+v", job)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", job.DownloadURL, nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("download = %v %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if lines := strings.Count(rec.Body.String(), "\n"); lines != 2 {
+		t.Errorf("download has %d tasks, want 2 done", lines)
+	}
+
+	// The signed URL downloads the same file without the job lookup
+	if job.SignedURL == "" || job.SignedURLExpiresAt == nil || job.SignedURLExpiresAt.Before(time.Now()) {
+		t.Fatalf("job = %+v, want a signed URL", job)
+	}
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", job.SignedURL, nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/x-ndjson" ||
+		rec.Header().Get("Content-Disposition") != `attachment; filename="tasks.ndjson"` {
+		t.Fatalf("signed download = %v %v", rec.Code, rec.Header())
+	}
+	if lines := strings.Count(rec.Body.String(), "\n"); lines != 2 {
+		t.Errorf("signed download has %d tasks, want 2 done", lines)
+	}
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", strings.Replace(job.SignedURL, "expires=", "expires=9", 1), nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("altered signed download = %v, want %v", rec.Code, http.StatusForbidden)
+	}
+}
+
+func TestTaskHandler_JobsDisabled(t *testing.T) {
+	router := jobRouter(NewTaskHandler(repository.NewMemoryRepository()))
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/tasks/export?async=true", nil),
+		httptest.NewRequest("POST", "/tasks/import?async=true", strings.NewReader("title\nx\n")),
+		httptest.NewRequest("GET", "/jobs/1", nil),
+		httptest.NewRequest("GET", "/blobs/export-1.csv", nil),
+	} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNotImplemented {
+			t.Errorf("%s %s = %v, want %v", req.Method, req.URL, rec.Code, http.StatusNotImplemented)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/light-bringer/cert-tasks/internal/logging"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+// ndjsonEncoder writes tasks as newline-delimited JSON, one task per line
+type ndjsonEncoder struct {
+	enc *json.Encoder
+}
+
+func newNDJSONEncoder(w io.Writer) taskEncoder {
+	return ndjsonEncoder{enc: json.NewEncoder(w)}
+}
+
+// Encode writes t as one line
+func (e ndjsonEncoder) Encode(t *models.Task) error {
+	return e.enc.Encode(t)
+}
+
+// Flush does nothing; every task is written as it is encoded
+func (e ndjsonEncoder) Flush() error {
+	return nil
+}
+
+// restoredLinks are the links of an imported task, still pointing at IDs
+// from the backup
+type restoredLinks struct {
+	line  int
+	oldID int64
+	links []models.TaskLink
+}
+
+// importNDJSON restores tasks from an NDJSON export, one task per line,
+// calling progress with the lines done after each; the total is not known
+// in advance. The file is read and imported line by line, so a backup of
+// any size can be restored; only each line is limited to the maximum body
+// size. Tasks get new IDs and timestamps in the workspace ctx is scoped
+// to, and their links are re-created once every line is imported,
+// pointing at the new IDs.
+func (h *TaskHandler) importNDJSON(ctx context.Context, body io.Reader, dryRun bool, progress func(done, total int)) models.ImportResult {
+	sc := bufio.NewScanner(body)
+	sc.Buffer(nil, int(h.maxBodyBytes))
+
+	result := models.ImportResult{DryRun: dryRun, Errors: []models.ImportRowError{}}
+	ids := make(map[int64]int64) // backup ID -> new ID, or 0 in a dry run
+	var links []restoredLinks
+
+	line := 0
+	for sc.Scan() {
+		line++
+		b := bytes.TrimSpace(sc.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+		result.Rows++
+
+		var task models.Task
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&task); err != nil {
+			addRow(&result, 0, []models.ImportRowError{{Row: line, Code: CodeInvalidJSON, Message: describeDecodeError(err)}})
+			progress(result.Rows, 0)
+			continue
+		}
+
+		row := importRow{line: line, req: models.UpdateTaskRequest{
+			Title:       task.Title,
+			Description: task.Description,
+			Status:      task.Status,
+			DueAt:       task.DueAt,
+			Estimate:    task.Estimate,
+		}}
+		if row.req.Status == "" {
+			row.req.Status = models.StatusTodo
+		}
+		id, errs := h.importRow(ctx, row, dryRun)
+		addRow(&result, id, errs)
+		progress(result.Rows, 0)
+		if len(errs) > 0 {
+			continue
+		}
+
+		if task.ID != 0 {
+			ids[task.ID] = id
+		}
+		if len(task.Links) > 0 {
+			links = append(links, restoredLinks{line: line, oldID: task.ID, links: task.Links})
+		}
+	}
+	if err := sc.Err(); err != nil {
+		// The rest of the file cannot be read; report what was imported
+		msg := fmt.Sprintf("invalid NDJSON: %v", err)
+		if errors.Is(err, bufio.ErrTooLong) {
+			msg = fmt.Sprintf("line exceeds %d bytes; the rest of the file was not imported", h.maxBodyBytes)
+		}
+		result.Errors = append(result.Errors, models.ImportRowError{Row: line + 1, Code: CodeInvalidJSON, Message: msg})
+	}
+
+	for _, l := range links {
+		result.Errors = append(result.Errors, h.restoreLinks(ctx, l, ids, dryRun)...)
+	}
+	return result
+}
+
+// restoreLinks re-creates the links of an imported task against the new
+// task IDs and returns an error for each link that could not be restored
+func (h *TaskHandler) restoreLinks(ctx context.Context, l restoredLinks, ids map[int64]int64, dryRun bool) []models.ImportRowError {
+	var errs []models.ImportRowError
+	for _, link := range l.links {
+		target, ok := ids[link.TaskID]
+		if !ok {
+			errs = append(errs, models.ImportRowError{
+				Row: l.line, Field: "links", Code: CodeLinkTargetNotFound,
+				Message: fmt.Sprintf("linked task %d was not imported", link.TaskID),
+			})
+			continue
+		}
+		if dryRun {
+			continue
+		}
+
+		_, err := h.repo.AddLink(ctx, ids[l.oldID], models.TaskLink{Type: link.Type, TaskID: target})
+		switch {
+		case err == nil, errors.Is(err, repository.ErrLinkExists):
+		case errors.Is(err, repository.ErrSelfLink):
+			errs = append(errs, models.ImportRowError{Row: l.line, Field: "links", Code: CodeSelfLink, Message: "task cannot be linked to itself"})
+		default:
+			logging.FromContext(ctx).Error("failed to restore link", slog.Int("row", l.line), slog.Any("error", err))
+			errs = append(errs, models.ImportRowError{Row: l.line, Field: "links", Code: CodeInternal, Message: "failed to restore link"})
+		}
+	}
+	return errs
+}
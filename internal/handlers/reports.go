@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/reports"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+// reportRange is the workspace and buckets a report request asks for
+type reportRange struct {
+	workspace string
+	from, to  time.Time
+	bucket    string
+	bounds    []time.Time
+}
+
+// Burndown handles GET /reports/burndown
+//
+//api:changelog 0.2.0 added endpoint GET /reports/burndown: Open and done task counts at the end of each day or week, for burndown charts
+func (h *TaskHandler) Burndown(w http.ResponseWriter, r *http.Request) {
+	r, rg, ok := h.parseReport(w, r)
+	if !ok {
+		return
+	}
+	tasks, err := h.repo.GetAll(r.Context())
+	if err != nil {
+		h.respondWithRepositoryError(w, r, err, "failed to retrieve tasks")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, models.BurndownReport{
+		WorkspaceID:  rg.workspace,
+		From:         rg.from,
+		To:           rg.to,
+		Bucket:       rg.bucket,
+		EstimateUnit: h.estimateUnit(r.Context(), rg.workspace),
+		Points:       reports.Burndown(tasks, rg.bounds),
+	})
+}
+
+// Throughput handles GET /reports/throughput
+//
+//api:changelog 0.2.0 added endpoint GET /reports/throughput: Tasks created and completed in each day or week
+func (h *TaskHandler) Throughput(w http.ResponseWriter, r *http.Request) {
+	r, rg, ok := h.parseReport(w, r)
+	if !ok {
+		return
+	}
+	tasks, err := h.repo.GetAll(r.Context())
+	if err != nil {
+		h.respondWithRepositoryError(w, r, err, "failed to retrieve tasks")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, models.ThroughputReport{
+		WorkspaceID:  rg.workspace,
+		From:         rg.from,
+		To:           rg.to,
+		Bucket:       rg.bucket,
+		EstimateUnit: h.estimateUnit(r.Context(), rg.workspace),
+		Buckets:      reports.Throughput(tasks, rg.bounds),
+	})
+}
+
+// Workload handles GET /reports/workload. Tasks have no assignees or
+// tags, so open tasks are grouped by owner.
+//
+//api:changelog 0.2.0 added endpoint GET /reports/workload: Open tasks and estimated effort, in total and by owner
+func (h *TaskHandler) Workload(w http.ResponseWriter, r *http.Request) {
+	r, workspace, ok := h.reportWorkspace(w, r)
+	if !ok {
+		return
+	}
+	tasks, err := h.repo.GetAll(r.Context())
+	if err != nil {
+		h.respondWithRepositoryError(w, r, err, "failed to retrieve tasks")
+		return
+	}
+
+	report := reports.Workload(tasks)
+	report.WorkspaceID = workspace
+	report.EstimateUnit = h.estimateUnit(r.Context(), workspace)
+	respondWithJSON(w, http.StatusOK, report)
+}
+
+// parseReport reads the query of a bucketed report: the workspace, as
+// for reportWorkspace, and the buckets. ?from= and ?to= take dates, read
+// in the request's time zone, or RFC 3339 times; a date as ?to= includes
+// that day. They default to the last 30 days, or 12 weeks with
+// ?bucket=week, up to now. ?bucket= is day or week, day by default.
+func (h *TaskHandler) parseReport(w http.ResponseWriter, r *http.Request) (*http.Request, reportRange, bool) {
+	q := r.URL.Query()
+	rg := reportRange{bucket: models.BucketDay}
+	r, workspace, ok := h.reportWorkspace(w, r)
+	if !ok {
+		return r, rg, false
+	}
+	rg.workspace = workspace
+
+	loc, ok := h.location(w, r)
+	if !ok {
+		return r, rg, false
+	}
+	if v := q.Get("bucket"); v != "" {
+		rg.bucket = v
+	}
+
+	rg.to = time.Now().In(loc)
+	if v := q.Get("to"); v != "" {
+		to, date, ok := parseReportTime(v, loc)
+		if !ok {
+			h.respondWithError(w, r, http.StatusBadRequest, CodeInvalidQuery, "to must be a date, e.g. 2026-01-02, or an RFC 3339 time")
+			return r, rg, false
+		}
+		if date {
+			to = to.AddDate(0, 0, 1)
+		}
+		rg.to = to
+	}
+	rg.from = rg.to.AddDate(0, 0, -30)
+	if rg.bucket == models.BucketWeek {
+		rg.from = rg.to.AddDate(0, 0, -12*7)
+	}
+	if v := q.Get("from"); v != "" {
+		from, _, ok := parseReportTime(v, loc)
+		if !ok {
+			h.respondWithError(w, r, http.StatusBadRequest, CodeInvalidQuery, "from must be a date, e.g. 2026-01-02, or an RFC 3339 time")
+			return r, rg, false
+		}
+		rg.from = from
+	}
+
+	bounds, err := reports.Bounds(rg.from, rg.to, rg.bucket)
+	if err != nil {
+		h.respondWithError(w, r, http.StatusBadRequest, CodeInvalidQuery, err.Error())
+		return r, rg, false
+	}
+	rg.bounds = bounds
+	return r, rg, true
+}
+
+// reportWorkspace returns the workspace a report is for. ?project= names
+// it like X-Workspace-ID and the request is returned scoped to it;
+// without it, the request's own workspace is reported.
+func (h *TaskHandler) reportWorkspace(w http.ResponseWriter, r *http.Request) (*http.Request, string, bool) {
+	project := r.URL.Query().Get("project")
+	if project == "" {
+		workspace := repository.WorkspaceFromContext(r.Context())
+		if workspace == "" {
+			workspace = models.DefaultWorkspace
+		}
+		return r, workspace, true
+	}
+
+	requested := r.Header.Get(WorkspaceHeader)
+	if requested == "" {
+		requested = r.Header.Get(TenantHeader)
+	}
+	if requested != "" && requested != project {
+		h.respondWithError(w, r, http.StatusBadRequest, CodeInvalidQuery, "project and "+WorkspaceHeader+" name different workspaces")
+		return r, "", false
+	}
+	workspace, ok := h.resolveWorkspace(w, r, project)
+	if !ok {
+		return r, "", false
+	}
+	return r.WithContext(repository.WithWorkspace(r.Context(), workspace)), workspace, true
+}
+
+// estimateUnit returns the unit of a workspace's estimates, points when
+// there is no workspace store
+func (h *TaskHandler) estimateUnit(ctx context.Context, workspace string) string {
+	if h.workspaces != nil {
+		if ws, err := h.workspaces.GetWorkspace(ctx, workspace); err == nil {
+			return ws.Unit()
+		}
+	}
+	return models.EstimateUnitPoints
+}
+
+// parseReportTime parses v as a date at midnight in loc, reporting that it
+// was a date, or as an RFC 3339 time shown in loc
+func parseReportTime(v string, loc *time.Location) (time.Time, bool, bool) {
+	if t, err := time.ParseInLocation(time.DateOnly, v, loc); err == nil {
+		return t, true, true
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, false, false
+	}
+	return t.In(loc), false, true
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+func TestTaskHandler_Reports(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	handler := NewTaskHandler(repo, WithWorkspaces(repo))
+	if _, err := repo.CreateWorkspace(ctx, &models.Workspace{ID: "acme", Name: "Acme"}); err != nil {
+		t.Fatal(err)
+	}
+
+	day := func(d, h int) time.Time { return time.Date(2026, 10, d, h, 0, 0, 0, time.UTC) }
+	completed := day(3, 12)
+	repo.Undelete(ctx, []*models.Task{
+		{ID: 1, WorkspaceID: "acme", Title: "Plan", Status: models.StatusDone, CreatedAt: day(1, 9), CompletedAt: &completed},
+		{ID: 2, WorkspaceID: "acme", Title: "Build", Status: models.StatusTodo, CreatedAt: day(2, 9)},
+		{ID: 3, WorkspaceID: models.DefaultWorkspace, Title: "Elsewhere", Status: models.StatusTodo, CreatedAt: day(2, 9)},
+	})
+
+	serve := func(target string, header http.Header, h http.HandlerFunc) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		for name, values := range header {
+			for _, v := range values {
+				req.Header.Add(name, v)
+			}
+		}
+		req = req.WithContext(repository.WithWorkspace(req.Context(), models.DefaultWorkspace))
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		return rec
+	}
+
+	rec := serve("/reports/burndown?project=acme&from=2026-10-02&to=2026-10-03", nil, handler.Burndown)
+	var burndown models.BurndownReport
+	json.NewDecoder(rec.Body).Decode(&burndown)
+	want := []models.BurndownPoint{
+		{At: day(3, 0), Open: 2, Done: 0},
+		{At: day(4, 0), Open: 1, Done: 1},
+	}
+	if rec.Code != http.StatusOK || burndown.WorkspaceID != "acme" || burndown.EstimateUnit != models.EstimateUnitPoints || len(burndown.Points) != len(want) {
+		t.Fatalf("burndown: status %d, report %+v", rec.Code, burndown)
+	}
+	for i := range want {
+		if got := burndown.Points[i]; !got.At.Equal(want[i].At) || got.Open != want[i].Open || got.Done != want[i].Done {
+			t.Errorf("point %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+
+	// Without ?project= the request's workspace is reported
+	rec = serve("/reports/throughput?bucket=week&from=2026-09-28T00:00:00Z&to=2026-10-05T00:00:00Z", nil, handler.Throughput)
+	var throughput models.ThroughputReport
+	json.NewDecoder(rec.Body).Decode(&throughput)
+	if rec.Code != http.StatusOK || throughput.WorkspaceID != models.DefaultWorkspace || len(throughput.Buckets) != 1 ||
+		throughput.Buckets[0].Created != 1 || throughput.Buckets[0].Completed != 0 {
+		t.Errorf("throughput: status %d, report %+v", rec.Code, throughput)
+	}
+
+	rec = serve("/reports/workload?project=acme", nil, handler.Workload)
+	var workload models.WorkloadReport
+	json.NewDecoder(rec.Body).Decode(&workload)
+	if rec.Code != http.StatusOK || workload.WorkspaceID != "acme" || workload.Open != 1 || len(workload.Owners) != 1 {
+		t.Errorf("workload: status %d, report %+v", rec.Code, workload)
+	}
+	if rec := serve("/reports/workload?project=nope", nil, handler.Workload); rec.Code != http.StatusNotFound {
+		t.Errorf("workload of unknown project: status %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		target string
+		header http.Header
+		want   int
+	}{
+		{"unknown project", "/reports/burndown?project=nope", nil, http.StatusNotFound},
+		{"project and header differ", "/reports/burndown?project=acme", http.Header{WorkspaceHeader: {"other"}}, http.StatusBadRequest},
+		{"bucket", "/reports/burndown?bucket=month", nil, http.StatusBadRequest},
+		{"from", "/reports/throughput?from=yesterday", nil, http.StatusBadRequest},
+		{"reversed", "/reports/throughput?from=2026-10-05&to=2026-10-01", nil, http.StatusBadRequest},
+		{"too long", "/reports/throughput?from=2020-01-01&to=2026-01-01", nil, http.StatusBadRequest},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serve(tt.target, tt.header, handler.Burndown); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d; body %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/light-bringer/cert-tasks/internal/logging"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+// WithRevisions sets where the previous versions of tasks are read from,
+// enabling the /tasks/{id}/revisions endpoints
+func WithRevisions(revisions repository.RevisionRepository) Option {
+	return func(h *TaskHandler) {
+		h.revisions = revisions
+	}
+}
+
+// ListRevisions handles GET /tasks/{id}/revisions
+//
+//api:changelog 0.2.0 added endpoint GET /tasks/{id}/revisions: List the previous versions of a task, oldest first; the last 50 are kept
+func (h *TaskHandler) ListRevisions(w http.ResponseWriter, r *http.Request) {
+	if !h.revisionsEnabled(w, r) {
+		return
+	}
+	id, ok := h.taskID(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := h.revisions.ListRevisions(r.Context(), id)
+	if err != nil {
+		h.respondWithRevisionError(w, r, err, "failed to list revisions")
+		return
+	}
+
+	setTotalCount(w, len(revisions))
+	respondWithJSON(w, http.StatusOK, revisions)
+}
+
+// RestoreRevision handles POST /tasks/{id}/revisions/{n}/restore. It
+// updates the task with the revision's fields, so the version it replaces
+// becomes a revision in turn and the restore can itself be undone. Like
+// PUT, it honours If-Match.
+//
+//api:changelog 0.2.0 added endpoint POST /tasks/{id}/revisions/{n}/restore: Restore the title, description, status, due date and estimate of a previous version
+func (h *TaskHandler) RestoreRevision(w http.ResponseWriter, r *http.Request) {
+	if !h.revisionsEnabled(w, r) {
+		return
+	}
+	id, ok := h.taskID(w, r)
+	if !ok {
+		return
+	}
+	n, err := strconv.Atoi(chi.URLParam(r, "n"))
+	if err != nil || n < 1 {
+		h.respondWithError(w, r, http.StatusBadRequest, CodeInvalidID, "invalid revision number")
+		return
+	}
+
+	loc, ok := h.location(w, r)
+	if !ok {
+		return
+	}
+
+	match, ok := h.ifMatch(w, r)
+	if !ok {
+		return
+	}
+
+	rev, err := h.revisions.GetRevision(r.Context(), id, n)
+	if err != nil {
+		if !h.respondIfFailed(w, r, match, err) {
+			h.respondWithRevisionError(w, r, err, "failed to retrieve revision")
+		}
+		return
+	}
+
+	updated, err := h.repo.Update(match.context(r.Context()), id, &models.Task{
+		Title:       rev.Title,
+		Description: rev.Description,
+		Status:      rev.Status,
+		DueAt:       rev.DueAt,
+		Estimate:    rev.Estimate,
+	})
+	if err != nil {
+		if !h.respondIfFailed(w, r, match, err) {
+			h.respondWithRevisionError(w, r, err, "failed to restore revision")
+		}
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("revision restored", slog.Int64("task_id", id), slog.Int("revision", n))
+	respondWithETag(w, r, http.StatusOK, localizeTask(updated, loc))
+}
+
+// revisionsEnabled writes a 501 and returns false when no revision store
+// is configured
+func (h *TaskHandler) revisionsEnabled(w http.ResponseWriter, r *http.Request) bool {
+	if h.revisions == nil {
+		h.respondWithError(w, r, http.StatusNotImplemented, CodeNotImplemented, "revision history is not enabled")
+		return false
+	}
+	return true
+}
+
+// respondWithRevisionError maps ErrTaskNotFound and ErrRevisionNotFound to
+// 404 and anything else to 500
+func (h *TaskHandler) respondWithRevisionError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	switch {
+	case errors.Is(err, repository.ErrTaskNotFound):
+		h.respondWithError(w, r, http.StatusNotFound, CodeNotFound, "task not found")
+	case errors.Is(err, repository.ErrRevisionNotFound):
+		h.respondWithError(w, r, http.StatusNotFound, CodeNotFound, "revision not found")
+	default:
+		h.respondWithRepositoryError(w, r, err, message)
+	}
+}
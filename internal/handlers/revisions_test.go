@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+func TestTaskHandler_Revisions(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	handler := NewTaskHandler(repo, WithRevisions(repo))
+
+	r := chi.NewRouter()
+	r.Get("/tasks/{id}", handler.GetTask)
+	r.Put("/tasks/{id}", handler.UpdateTask)
+	r.Get("/tasks/{id}/revisions", handler.ListRevisions)
+	r.Post("/tasks/{id}/revisions/{n}/restore", handler.RestoreRevision)
+	do := func(method, path, body string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		for i := 0; i < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	task, _ := repo.Create(context.Background(), &models.Task{Title: "Write report", Description: "draft"})
+	if rec := do("PUT", "/tasks/1", `{"title":"Wrte rprt","description":"","status":"todo"}`); rec.Code != http.StatusOK {
+		t.Fatalf("update status = %v", rec.Code)
+	}
+
+	rec := do("GET", "/tasks/1/revisions", "")
+	var revisions []models.TaskRevision
+	json.NewDecoder(rec.Body).Decode(&revisions)
+	if rec.Code != http.StatusOK || len(revisions) != 1 || revisions[0].Title != "Write report" || revisions[0].TaskID != task.ID {
+		t.Fatalf("revisions = %v %+v", rec.Code, revisions)
+	}
+
+	stale := do("GET", "/tasks/1", "").Header().Get("ETag")
+	rec = do("POST", "/tasks/1/revisions/1/restore", "")
+	var restored models.Task
+	json.NewDecoder(rec.Body).Decode(&restored)
+	if rec.Code != http.StatusOK || restored.Title != "Write report" || restored.Description != "draft" || rec.Header().Get("ETag") == "" {
+		t.Fatalf("restore = %v %+v", rec.Code, restored)
+	}
+
+	// The restore can be undone: the bad edit is now revision 2
+	rec = do("GET", "/tasks/1/revisions", "")
+	json.NewDecoder(rec.Body).Decode(&revisions)
+	if len(revisions) != 2 || revisions[1].Title != "Wrte rprt" {
+		t.Errorf("revisions after restore = %+v", revisions)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		header     []string
+		wantStatus int
+		wantCode   string
+	}{
+		{"unknown revision", "/tasks/1/revisions/9/restore", nil, http.StatusNotFound, CodeNotFound},
+		{"unknown task", "/tasks/42/revisions/1/restore", nil, http.StatusNotFound, CodeNotFound},
+		{"invalid number", "/tasks/1/revisions/zero/restore", nil, http.StatusBadRequest, CodeInvalidID},
+		{"stale If-Match", "/tasks/1/revisions/2/restore", []string{"If-Match", stale}, http.StatusPreconditionFailed, CodePreconditionFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do("POST", tt.path, "", tt.header...)
+			var resp ErrorResponse
+			json.NewDecoder(rec.Body).Decode(&resp)
+			if rec.Code != tt.wantStatus || resp.Code != tt.wantCode {
+				t.Errorf("restore = %v %q, want %v %q", rec.Code, resp.Code, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+
+	disabled := NewTaskHandler(repo)
+	rec = httptest.NewRecorder()
+	disabled.ListRevisions(rec, httptest.NewRequest("GET", "/tasks/1/revisions", nil))
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("without a revision store status = %v, want %v", rec.Code, http.StatusNotImplemented)
+	}
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/light-bringer/cert-tasks/internal/logging"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/projection"
+)
+
+// streamMinTasks is the list length from which responses are encoded one
+// task at a time rather than in a single buffer
+const streamMinTasks = 1000
+
+// streamFlushTasks is how many streamed tasks are written between flushes
+const streamFlushTasks = 250
+
+// respondWithList writes a task list trimmed to fields, like
+// respondWithFields. Long lists are streamed instead: a first pass feeds
+// the encoded tasks to the ETag hash without keeping them, and a second
+// writes them out in flushed chunks, so memory no longer grows with the
+// size of the body. Both paths send the same bytes and ETag.
+func (h *TaskHandler) respondWithList(w http.ResponseWriter, r *http.Request, fields projection.Fields, tasks []*models.Task) {
+	if len(tasks) < streamMinTasks {
+		h.respondWithFields(w, r, fields, tasks)
+		return
+	}
+
+	encode := func(task *models.Task) ([]byte, error) {
+		projected, err := taskFields.Apply(fields, task)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(projected)
+	}
+
+	hash := sha256.New()
+	if err := writeTaskArray(hash, tasks, encode, nil); err != nil {
+		logging.FromContext(r.Context()).Error("encoding task list", slog.Any("error", err))
+		h.respondWithError(w, r, http.StatusInternalServerError, CodeInternal, "failed to encode response")
+		return
+	}
+	if setETag(w, r, formatETag(hash.Sum(nil))) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	bw := bufio.NewWriter(w)
+	flush := func() {
+		bw.Flush()
+		http.NewResponseController(w).Flush()
+	}
+	if err := writeTaskArray(bw, tasks, encode, flush); err != nil {
+		// The status line is gone; abort so the client sees a broken
+		// response rather than a truncated list
+		logging.FromContext(r.Context()).Error("streaming task list", slog.Any("error", err))
+		panic(http.ErrAbortHandler)
+	}
+	bw.Flush()
+}
+
+// writeTaskArray writes tasks as a JSON array followed by a newline,
+// byte for byte as json.Marshal and respondWithETag would, calling flush
+// every streamFlushTasks tasks if it is set
+func writeTaskArray(w io.Writer, tasks []*models.Task, encode func(*models.Task) ([]byte, error), flush func()) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	for i, task := range tasks {
+		if i > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		body, err := encode(task)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(body); err != nil {
+			return err
+		}
+		if flush != nil && (i+1)%streamFlushTasks == 0 {
+			flush()
+		}
+	}
+	_, err := io.WriteString(w, "]\n")
+	return err
+}
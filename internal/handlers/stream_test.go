@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+func TestTaskHandler_StreamsLongLists(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	for i := range streamMinTasks + 10 {
+		repo.Create(ctx, &models.Task{Title: fmt.Sprintf("Task <%d>", i), Description: "a & b"})
+	}
+	handler := NewTaskHandler(repo)
+
+	list := func(target, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		handler.ListTasks(rec, req)
+		return rec
+	}
+
+	// The stream matches the buffered encoding byte for byte
+	tasks, _ := repo.List(ctx, repository.ListOptions{})
+	want, _ := json.Marshal(tasks)
+	want = append(want, '\n')
+	rec := list("/tasks", "")
+	if rec.Code != http.StatusOK || rec.Body.String() != string(want) {
+		t.Fatalf("status %d, body differs from json.Marshal (%d vs %d bytes)", rec.Code, rec.Body.Len(), len(want))
+	}
+	if !rec.Flushed {
+		t.Error("long list was not flushed while streaming")
+	}
+	if etag := rec.Header().Get("ETag"); etag != computeETag(want) {
+		t.Errorf("ETag = %s, want %s", etag, computeETag(want))
+	}
+	if rec := list("/tasks", rec.Header().Get("ETag")); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("If-None-Match: status %d, %d bytes", rec.Code, rec.Body.Len())
+	}
+
+	rec = list("/tasks?fields=id,status", "")
+	var trimmed []map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &trimmed); err != nil || len(trimmed) != len(tasks) || len(trimmed[0]) != 2 {
+		t.Errorf("?fields=id,status: %d tasks, first %v, error %v", len(trimmed), trimmed[0], err)
+	}
+
+	// Short lists are buffered as before
+	if rec := list("/tasks?limit=10", ""); rec.Flushed || rec.Code != http.StatusOK {
+		t.Errorf("short list: status %d, flushed %v", rec.Code, rec.Flushed)
+	}
+}
@@ -1,164 +1,528 @@
 package handlers
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/light-bringer/cert-tasks/internal/calendar"
+	"github.com/light-bringer/cert-tasks/internal/content"
+	"github.com/light-bringer/cert-tasks/internal/health"
+	"github.com/light-bringer/cert-tasks/internal/i18n"
+	"github.com/light-bringer/cert-tasks/internal/ids"
+	"github.com/light-bringer/cert-tasks/internal/logging"
 	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/projection"
+	"github.com/light-bringer/cert-tasks/internal/redact"
 	"github.com/light-bringer/cert-tasks/internal/repository"
+	"github.com/light-bringer/cert-tasks/internal/service"
+	"github.com/light-bringer/cert-tasks/internal/validation"
 )
 
 // TaskHandler handles HTTP requests for tasks
 type TaskHandler struct {
-	repo repository.TaskRepository
+	repo           repository.TaskRepository
+	tasks          *service.Tasks
+	validator      *validation.Validator
+	policies       *content.PolicySet
+	problemDetails bool
+	health         *health.Registry
+	confirmations  *confirmations
+	undo           *undoLog
+	jobs           *taskJobs
+	links          *downloadLinks
+	redactor       *redact.Redactor
+	maxBodyBytes   int64
+	apiKeys        repository.APIKeyRepository
+	workspaces     repository.WorkspaceRepository
+	webhooks       repository.WebhookRepository
+	revisions      repository.RevisionRepository
+	deliveries     DeliveryLog
+	calendar       *calendar.Tokens
+	cacheControl   string
+	idFormat       ids.Format
+	translations   *i18n.Catalog
 }
 
-// NewTaskHandler creates a new task handler
-func NewTaskHandler(repo repository.TaskRepository) *TaskHandler {
-	return &TaskHandler{repo: repo}
+// Option configures a TaskHandler
+type Option func(*TaskHandler)
+
+// WithValidator sets the validator used for request bodies
+func WithValidator(v *validation.Validator) Option {
+	return func(h *TaskHandler) {
+		h.validator = v
+	}
+}
+
+// WithContentPolicies runs the tenant's content pipeline on every create
+// and update, rejecting or rewriting titles and descriptions
+func WithContentPolicies(policies *content.PolicySet) Option {
+	return func(h *TaskHandler) {
+		h.policies = policies
+	}
 }
 
-// ErrorResponse represents an error response
-type ErrorResponse struct {
-	Error string `json:"error"`
+// WithTasks sets the task service the handler changes tasks through,
+// instead of one of its own built from the handler's validator and content
+// policies
+func WithTasks(tasks *service.Tasks) Option {
+	return func(h *TaskHandler) {
+		h.tasks = tasks
+	}
+}
+
+// WithProblemDetails makes the handler emit errors as RFC 7807
+// application/problem+json documents instead of the default ErrorResponse
+//
+//api:changelog 0.2.0 added error application/problem+json: RFC 7807 error documents when ERROR_FORMAT=problem+json
+func WithProblemDetails() Option {
+	return func(h *TaskHandler) {
+		h.problemDetails = true
+	}
+}
+
+// WithTranslations sets the catalog error messages are translated from,
+// replacing the built-in bundles
+func WithTranslations(c *i18n.Catalog) Option {
+	return func(h *TaskHandler) {
+		h.translations = c
+	}
+}
+
+// WithHealth lets the handler degrade features whose dependency the
+// registry reports as down, and report failures it observes back to it
+func WithHealth(reg *health.Registry) Option {
+	return func(h *TaskHandler) {
+		h.health = reg
+	}
+}
+
+// NewTaskHandler creates a new task handler
+func NewTaskHandler(repo repository.TaskRepository, opts ...Option) *TaskHandler {
+	h := &TaskHandler{
+		repo:          repo,
+		validator:     validation.Default(),
+		confirmations: newConfirmations(),
+		undo:          newUndoLog(DefaultUndoWindow),
+		maxBodyBytes:  DefaultMaxBodyBytes,
+		idFormat:      ids.Sequential,
+		translations:  i18n.Default(),
+		redactor:      redact.New(redact.DefaultFields),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.tasks == nil {
+		h.tasks = service.NewTasks(repo, service.WithValidator(h.validator), service.WithContentPolicies(h.policies))
+	}
+	return h
 }
 
 // CreateTask handles POST /tasks
+//
+//api:changelog 0.1.0 added endpoint POST /tasks: Create a task
 func (h *TaskHandler) CreateTask(w http.ResponseWriter, r *http.Request) {
 	var req models.CreateTaskRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondWithError(w, http.StatusBadRequest, "invalid JSON payload")
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	var loc *time.Location
+	created, err := h.tasks.Create(h.taskContext(r), req, h.zone(w, r, &loc))
+	if err != nil {
+		h.respondWithTaskError(w, r, err, "failed to create task")
+		return
+	}
+
+	respondWithETag(w, r, http.StatusCreated, localizeTask(created, loc))
+}
+
+// ListTasks handles GET /tasks, optionally filtered by a ?q= search query.
+// Results are ordered by ID and can be paginated with ?limit= plus either
+// ?offset= or the ?after= cursor returned in the X-Next-Cursor header.
+//
+//api:changelog 0.1.0 added endpoint GET /tasks: List all tasks
+//api:changelog 0.2.0 added parameter GET /tasks?q: Case-insensitive search over title and description
+//api:changelog 0.2.0 added parameter GET /tasks?limit: Page size, between 1 and 1000
+//api:changelog 0.2.0 added parameter GET /tasks?offset: Number of tasks to skip
+//api:changelog 0.2.0 added parameter GET /tasks?after: Return tasks with IDs greater than this cursor
+//api:changelog 0.2.0 added header X-Next-Cursor: Cursor for the next page, set when a page is full
+func (h *TaskHandler) ListTasks(w http.ResponseWriter, r *http.Request) {
+	h.listTasks(w, r, repository.TaskFilter{})
+}
+
+// ListTasksByStatus handles GET /tasks/status/{status}: ListTasks for the
+// tasks with one status, filtered by the repository
+//
+//api:changelog 0.2.0 added endpoint GET /tasks/status/{status}: List the tasks with a status, todo or done, paginated like GET /tasks
+func (h *TaskHandler) ListTasksByStatus(w http.ResponseWriter, r *http.Request) {
+	status := models.TaskStatus(chi.URLParam(r, "status"))
+	if status != models.StatusTodo && status != models.StatusDone {
+		h.respondWithError(w, r, http.StatusNotFound, CodeNotFound, "status must be todo or done")
+		return
+	}
+	h.listTasks(w, r, repository.TaskFilter{Status: status})
+}
+
+// ListTodoTasks handles GET /tasks/todo, short for /tasks/status/todo
+//
+//api:changelog 0.2.0 added endpoint GET /tasks/todo: List the tasks still to do
+func (h *TaskHandler) ListTodoTasks(w http.ResponseWriter, r *http.Request) {
+	h.listTasks(w, r, repository.TaskFilter{Status: models.StatusTodo})
+}
+
+// ListDoneTasks handles GET /tasks/done, short for /tasks/status/done
+//
+//api:changelog 0.2.0 added endpoint GET /tasks/done: List the completed tasks
+func (h *TaskHandler) ListDoneTasks(w http.ResponseWriter, r *http.Request) {
+	h.listTasks(w, r, repository.TaskFilter{Status: models.StatusDone})
+}
+
+// TotalCountHeader carries how many items a list request matches across
+// all pages
+//
+//api:changelog 0.2.0 added header X-Total-Count: Number of items a list matches across all pages, on every list endpoint
+const TotalCountHeader = "X-Total-Count"
+
+// setTotalCount sets the X-Total-Count header to n
+func setTotalCount(w http.ResponseWriter, n int) {
+	w.Header().Set(TotalCountHeader, strconv.Itoa(n))
+}
+
+// TotalEstimateHeader carries the total estimate of the tasks a list
+// request matches across all pages, in the workspace's estimate unit
+//
+//api:changelog 0.2.0 added header X-Total-Estimate: Total estimate of the tasks a task list matches across all pages, in the workspace's estimate_unit
+const TotalEstimateHeader = "X-Total-Estimate"
+
+// listTasks serves the task list routes, listing the tasks filter selects
+func (h *TaskHandler) listTasks(w http.ResponseWriter, r *http.Request, filter repository.TaskFilter) {
+	fields, ok := h.parseFields(w, r)
+	if !ok {
+		return
+	}
+	depth, ok := h.parseExpand(w, r)
+	if !ok {
+		return
+	}
+	loc, ok := h.location(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query().Get("q")
+	if query != "" {
+		if filter != (repository.TaskFilter{}) {
+			h.respondWithError(w, r, http.StatusBadRequest, CodeInvalidQuery, "q is only supported on GET /tasks")
+			return
+		}
+		h.searchTasks(w, r, query, fields, depth, loc)
+		return
+	}
+
+	opts, err := parseListOptions(r)
+	if err != nil {
+		h.respondWithError(w, r, http.StatusBadRequest, CodeInvalidQuery, err.Error())
 		return
 	}
+	opts.Filter = filter
 
-	if err := req.Validate(); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+	if opts.AfterID > 0 && !h.repo.Capabilities().Cursors {
+		h.respondWithError(w, r, http.StatusNotImplemented, CodeNotImplemented, "cursor pagination is not supported by the storage backend")
 		return
 	}
 
-	task := &models.Task{
-		Title:       req.Title,
-		Description: req.Description,
+	if h.notModified(w, r) {
+		return
 	}
 
-	created, err := h.repo.Create(task)
+	tasks, err := h.repo.List(r.Context(), opts)
+	if err != nil {
+		h.respondWithRepositoryError(w, r, err, "failed to retrieve tasks")
+		return
+	}
+	total, err := h.repo.Count(r.Context(), filter)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "failed to create task")
+		h.respondWithRepositoryError(w, r, err, "failed to count tasks")
 		return
 	}
+	setTotalCount(w, total)
+	estimate, err := h.repo.EstimateTotal(r.Context(), filter)
+	if err != nil {
+		h.respondWithRepositoryError(w, r, err, "failed to add up estimates")
+		return
+	}
+	w.Header().Set(TotalEstimateHeader, strconv.Itoa(estimate))
 
-	respondWithJSON(w, http.StatusCreated, created)
+	if opts.Limit > 0 && len(tasks) == opts.Limit {
+		w.Header().Set("X-Next-Cursor", strconv.FormatInt(tasks[len(tasks)-1].ID, 10))
+	}
+
+	tasks, err = h.expandLinks(r.Context(), tasks, depth)
+	if err != nil {
+		h.respondWithRepositoryError(w, r, err, "failed to expand links")
+		return
+	}
+
+	h.respondWithList(w, r, fields, localize(tasks, loc))
 }
 
-// ListTasks handles GET /tasks
-func (h *TaskHandler) ListTasks(w http.ResponseWriter, r *http.Request) {
-	tasks, err := h.repo.GetAll()
+// maxPageSize caps the ?limit= query parameter
+const maxPageSize = 1000
+
+// parseListOptions reads the pagination query parameters of a list request
+func parseListOptions(r *http.Request) (repository.ListOptions, error) {
+	var opts repository.ListOptions
+	q := r.URL.Query()
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			return opts, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+		}
+		opts.Limit = limit
+	}
+
+	if v := q.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return opts, errors.New("offset must be a non-negative integer")
+		}
+		opts.Offset = offset
+	}
+
+	if v := q.Get("after"); v != "" {
+		after, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || after < 0 {
+			return opts, errors.New("after must be a valid task ID")
+		}
+		if opts.Offset > 0 {
+			return opts, errors.New("offset and after cannot be combined")
+		}
+		opts.AfterID = after
+	}
+
+	return opts, nil
+}
+
+// searchTasks serves a ListTasks request carrying a search query
+func (h *TaskHandler) searchTasks(w http.ResponseWriter, r *http.Request, query string, fields projection.Fields, depth int, loc *time.Location) {
+	if !h.repo.Capabilities().FullTextSearch {
+		h.respondWithError(w, r, http.StatusNotImplemented, CodeNotImplemented, "search is not supported by the storage backend")
+		return
+	}
+
+	// Listing without ?q= keeps working while search is down
+	if !h.health.Available(health.Search) {
+		h.respondWithSearchUnavailable(w, r)
+		return
+	}
+
+	if h.notModified(w, r) {
+		return
+	}
+
+	tasks, err := h.repo.Search(r.Context(), query)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotSupported):
+			h.respondWithError(w, r, http.StatusNotImplemented, CodeNotImplemented, "search is not supported by the storage backend")
+		case errors.Is(err, repository.ErrUnavailable):
+			logging.FromContext(r.Context()).Warn("search unavailable", slog.Any("error", err))
+			h.health.MarkDown(health.Search, err)
+			h.respondWithSearchUnavailable(w, r)
+		default:
+			h.respondWithRepositoryError(w, r, err, "failed to search tasks")
+		}
+		return
+	}
+
+	tasks, err = h.expandLinks(r.Context(), tasks, depth)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "failed to retrieve tasks")
+		h.respondWithRepositoryError(w, r, err, "failed to expand links")
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, tasks)
+	setTotalCount(w, len(tasks))
+	h.respondWithList(w, r, fields, localize(tasks, loc))
 }
 
 // GetTask handles GET /tasks/{id}
+//
+//api:changelog 0.1.0 added endpoint GET /tasks/{id}: Get a task
 func (h *TaskHandler) GetTask(w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
+	id, ok := h.taskID(w, r)
+	if !ok {
+		return
+	}
+
+	fields, ok := h.parseFields(w, r)
+	if !ok {
+		return
+	}
+	depth, ok := h.parseExpand(w, r)
+	if !ok {
+		return
+	}
+	loc, ok := h.location(w, r)
+	if !ok {
+		return
+	}
+
+	task, err := h.tasks.Get(r.Context(), id)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "invalid task ID")
+		h.respondWithTaskError(w, r, err, "failed to retrieve task")
 		return
 	}
 
-	task, err := h.repo.GetByID(id)
+	expanded, err := h.expandLinks(r.Context(), []*models.Task{task}, depth)
 	if err != nil {
-		if errors.Is(err, repository.ErrTaskNotFound) {
-			respondWithError(w, http.StatusNotFound, "task not found")
-			return
-		}
-		respondWithError(w, http.StatusInternalServerError, "failed to retrieve task")
+		h.respondWithRepositoryError(w, r, err, "failed to expand links")
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, task)
+	h.respondWithFields(w, r, fields, localizeTask(expanded[0], loc))
 }
 
 // UpdateTask handles PUT /tasks/{id}
+//
+//api:changelog 0.1.0 added endpoint PUT /tasks/{id}: Update a task
 func (h *TaskHandler) UpdateTask(w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "invalid task ID")
+	id, ok := h.taskID(w, r)
+	if !ok {
 		return
 	}
 
 	var req models.UpdateTaskRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondWithError(w, http.StatusBadRequest, "invalid JSON payload")
+	if !h.decodeJSON(w, r, &req) {
 		return
 	}
 
-	if err := req.Validate(); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+	match, ok := h.ifMatch(w, r)
+	if !ok {
 		return
 	}
 
-	task := &models.Task{
-		Title:       req.Title,
-		Description: req.Description,
-		Status:      req.Status,
+	var loc *time.Location
+	updated, err := h.tasks.Update(match.context(h.taskContext(r)), id, req, h.zone(w, r, &loc))
+	if err != nil {
+		if !h.respondIfFailed(w, r, match, err) {
+			h.respondWithTaskError(w, r, err, "failed to update task")
+		}
+		return
 	}
 
-	updated, err := h.repo.Update(id, task)
+	respondWithETag(w, r, http.StatusOK, localizeTask(updated, loc))
+}
+
+// CompleteTask handles POST /tasks/{id}/complete
+//
+//api:changelog 0.2.0 added endpoint POST /tasks/{id}/complete: Mark a task done without sending the whole task
+func (h *TaskHandler) CompleteTask(w http.ResponseWriter, r *http.Request) {
+	h.setStatus(w, r, models.StatusDone)
+}
+
+// ReopenTask handles POST /tasks/{id}/reopen
+//
+//api:changelog 0.2.0 added endpoint POST /tasks/{id}/reopen: Mark a task todo again without sending the whole task
+func (h *TaskHandler) ReopenTask(w http.ResponseWriter, r *http.Request) {
+	h.setStatus(w, r, models.StatusTodo)
+}
+
+// setStatus changes only the status of the task, so clients need not send
+// the rest of it back as PUT requires. Like PUT, it honours If-Match. A
+// task already in the status is returned unchanged.
+func (h *TaskHandler) setStatus(w http.ResponseWriter, r *http.Request, status models.TaskStatus) {
+	id, ok := h.taskID(w, r)
+	if !ok {
+		return
+	}
+	loc, ok := h.location(w, r)
+	if !ok {
+		return
+	}
+
+	match, ok := h.ifMatch(w, r)
+	if !ok {
+		return
+	}
+
+	updated, err := h.tasks.SetStatus(match.context(r.Context()), id, status)
 	if err != nil {
-		if errors.Is(err, repository.ErrTaskNotFound) {
-			respondWithError(w, http.StatusNotFound, "task not found")
-			return
+		if !h.respondIfFailed(w, r, match, err) {
+			h.respondWithTaskError(w, r, err, "failed to update task")
 		}
-		respondWithError(w, http.StatusInternalServerError, "failed to update task")
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, updated)
+	respondWithETag(w, r, http.StatusOK, localizeTask(updated, loc))
+}
+
+// taskContext returns the context to change tasks in for r, selecting the
+// content policies of the requesting tenant
+func (h *TaskHandler) taskContext(r *http.Request) context.Context {
+	return service.WithTenant(r.Context(), tenantFromRequest(r))
 }
 
 // DeleteTask handles DELETE /tasks/{id}
+//
+//api:changelog 0.1.0 added endpoint DELETE /tasks/{id}: Delete a task
 func (h *TaskHandler) DeleteTask(w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "invalid task ID")
+	id, ok := h.taskID(w, r)
+	if !ok {
+		return
+	}
+
+	match, ok := h.ifMatch(w, r)
+	if !ok {
 		return
 	}
 
-	err = h.repo.Delete(id)
+	// Keep the task as it was for undo
+	deleted, err := h.tasks.Delete(match.context(r.Context()), id)
 	if err != nil {
-		if errors.Is(err, repository.ErrTaskNotFound) {
-			respondWithError(w, http.StatusNotFound, "task not found")
-			return
+		if !h.respondIfFailed(w, r, match, err) {
+			h.respondWithTaskError(w, r, err, "failed to delete task")
 		}
-		respondWithError(w, http.StatusInternalServerError, "failed to delete task")
 		return
 	}
 
+	h.undo.issue(w, r, []*models.Task{deleted})
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// respondWithJSON writes a JSON response
-func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
-	if payload != nil {
-		json.NewEncoder(w).Encode(payload)
+// CreateLink handles POST /tasks/{id}/links
+//
+//api:changelog 0.2.0 added endpoint POST /tasks/{id}/links: Add a typed link to another task
+func (h *TaskHandler) CreateLink(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.taskID(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.CreateLinkRequest
+
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	loc, ok := h.location(w, r)
+	if !ok {
+		return
+	}
+
+	updated, err := h.tasks.Link(r.Context(), id, req)
+	if err != nil {
+		h.respondWithTaskError(w, r, err, "failed to link tasks")
+		return
 	}
-}
 
-// respondWithError writes an error response
-func respondWithError(w http.ResponseWriter, code int, message string) {
-	respondWithJSON(w, code, ErrorResponse{Error: message})
+	respondWithETag(w, r, http.StatusCreated, localizeTask(updated, loc))
 }
@@ -4,13 +4,22 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/light-bringer/cert-tasks/internal/content"
+	"github.com/light-bringer/cert-tasks/internal/health"
+	"github.com/light-bringer/cert-tasks/internal/logging"
 	"github.com/light-bringer/cert-tasks/internal/models"
 	"github.com/light-bringer/cert-tasks/internal/repository"
+	"github.com/light-bringer/cert-tasks/internal/validation"
 )
 
 func TestTaskHandler_CreateTask(t *testing.T) {
@@ -18,7 +27,8 @@ func TestTaskHandler_CreateTask(t *testing.T) {
 		name       string
 		body       string
 		wantStatus int
-		wantError  string
+		wantCode   string
+		wantField  string
 	}{
 		{
 			name:       "valid task",
@@ -33,20 +43,22 @@ func TestTaskHandler_CreateTask(t *testing.T) {
 		{
 			name:       "missing title",
 			body:       `{"description":"Test Description"}`,
-			wantStatus: http.StatusBadRequest,
-			wantError:  "title is required",
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   CodeValidationFailed,
+			wantField:  "title",
 		},
 		{
 			name:       "empty title",
 			body:       `{"title":"  ","description":"Test Description"}`,
-			wantStatus: http.StatusBadRequest,
-			wantError:  "title is required",
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   CodeValidationFailed,
+			wantField:  "title",
 		},
 		{
 			name:       "invalid JSON",
 			body:       `{"title":}`,
 			wantStatus: http.StatusBadRequest,
-			wantError:  "invalid JSON",
+			wantCode:   CodeInvalidJSON,
 		},
 	}
 
@@ -64,11 +76,17 @@ func TestTaskHandler_CreateTask(t *testing.T) {
 				t.Errorf("status = %v, want %v", rec.Code, tt.wantStatus)
 			}
 
-			if tt.wantError != "" {
+			if tt.wantCode != "" {
 				var errResp ErrorResponse
 				json.NewDecoder(rec.Body).Decode(&errResp)
-				if errResp.Error == "" {
-					t.Error("expected error response")
+				if errResp.Code != tt.wantCode {
+					t.Errorf("code = %q, want %q", errResp.Code, tt.wantCode)
+				}
+				if errResp.Message == "" {
+					t.Error("expected error message")
+				}
+				if tt.wantField != "" && (len(errResp.Fields) != 1 || errResp.Fields[0].Field != tt.wantField) {
+					t.Errorf("fields = %+v, want one error for %q", errResp.Fields, tt.wantField)
 				}
 			}
 
@@ -86,13 +104,59 @@ func TestTaskHandler_CreateTask(t *testing.T) {
 	}
 }
 
+func TestTaskHandler_CreateTask_ContentPolicies(t *testing.T) {
+	policies := &content.PolicySet{
+		Default: content.Pipeline{content.MaxLinks{Max: 0}},
+		Tenants: map[string]content.Pipeline{"acme": nil},
+	}
+
+	tests := []struct {
+		name       string
+		tenant     string
+		wantStatus int
+	}{
+		{name: "default policy rejects", wantStatus: http.StatusUnprocessableEntity},
+		{name: "tenant policy allows", tenant: "acme", wantStatus: http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewTaskHandler(repository.NewMemoryRepository(), WithContentPolicies(policies))
+
+			body := `{"title":"Read this","description":"https://example.com"}`
+			req := httptest.NewRequest("POST", "/tasks", bytes.NewBufferString(body))
+			req.Header.Set(TenantHeader, tt.tenant)
+			rec := httptest.NewRecorder()
+
+			handler.CreateTask(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %v, want %v", rec.Code, tt.wantStatus)
+			}
+
+			if tt.wantStatus == http.StatusUnprocessableEntity {
+				var errResp ErrorResponse
+				json.NewDecoder(rec.Body).Decode(&errResp)
+				if errResp.Code != CodeContentRejected {
+					t.Errorf("code = %q, want %q", errResp.Code, CodeContentRejected)
+				}
+				if len(errResp.Fields) != 1 || errResp.Fields[0].Code != "max_links" {
+					t.Errorf("fields = %+v, want one max_links rejection", errResp.Fields)
+				}
+			}
+		})
+	}
+}
+
 func TestTaskHandler_ListTasks(t *testing.T) {
+	ctx := context.Background()
+
 	repo := repository.NewMemoryRepository()
 	handler := NewTaskHandler(repo)
 
 	// Create some tasks
-	repo.Create(&models.Task{Title: "Task 1"})
-	repo.Create(&models.Task{Title: "Task 2"})
+	repo.Create(ctx, &models.Task{Title: "Task 1"})
+	repo.Create(ctx, &models.Task{Title: "Task 2"})
 
 	req := httptest.NewRequest("GET", "/tasks", nil)
 	rec := httptest.NewRecorder()
@@ -111,11 +175,196 @@ func TestTaskHandler_ListTasks(t *testing.T) {
 	}
 }
 
+func TestTaskHandler_ListTasks_Pagination(t *testing.T) {
+	ctx := context.Background()
+
+	repo := repository.NewMemoryRepository()
+	handler := NewTaskHandler(repo)
+
+	for i := 0; i < 5; i++ {
+		repo.Create(ctx, &models.Task{Title: "Task"})
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantIDs    []int64
+		wantCursor string
+	}{
+		{name: "first page", query: "?limit=2", wantStatus: http.StatusOK, wantIDs: []int64{1, 2}, wantCursor: "2"},
+		{name: "cursor page", query: "?limit=2&after=2", wantStatus: http.StatusOK, wantIDs: []int64{3, 4}, wantCursor: "4"},
+		{name: "last page", query: "?limit=2&after=4", wantStatus: http.StatusOK, wantIDs: []int64{5}},
+		{name: "offset page", query: "?limit=2&offset=3", wantStatus: http.StatusOK, wantIDs: []int64{4, 5}, wantCursor: "5"},
+		{name: "invalid limit", query: "?limit=0", wantStatus: http.StatusBadRequest},
+		{name: "invalid offset", query: "?offset=-1", wantStatus: http.StatusBadRequest},
+		{name: "offset with cursor", query: "?offset=1&after=2", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/tasks"+tt.query, nil)
+			rec := httptest.NewRecorder()
+
+			handler.ListTasks(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %v, want %v", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var tasks []*models.Task
+			json.NewDecoder(rec.Body).Decode(&tasks)
+			if len(tasks) != len(tt.wantIDs) {
+				t.Fatalf("got %d tasks, want %d", len(tasks), len(tt.wantIDs))
+			}
+			for i, task := range tasks {
+				if task.ID != tt.wantIDs[i] {
+					t.Errorf("tasks[%d].ID = %v, want %v", i, task.ID, tt.wantIDs[i])
+				}
+			}
+			if got := rec.Header().Get("X-Next-Cursor"); got != tt.wantCursor {
+				t.Errorf("X-Next-Cursor = %q, want %q", got, tt.wantCursor)
+			}
+			// The total counts every page
+			if got := rec.Header().Get(TotalCountHeader); got != "5" {
+				t.Errorf("X-Total-Count = %q, want 5", got)
+			}
+		})
+	}
+}
+
+func TestTaskHandler_ListTasksByStatus(t *testing.T) {
+	ctx := context.Background()
+
+	repo := repository.NewMemoryRepository()
+	handler := NewTaskHandler(repo)
+	for i, status := range []models.TaskStatus{models.StatusTodo, models.StatusDone, models.StatusTodo, models.StatusDone, models.StatusTodo} {
+		repo.Create(ctx, &models.Task{Title: fmt.Sprintf("Task %d", i+1), Status: status, Estimate: i + 1})
+	}
+
+	serve := func(h http.HandlerFunc, status, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/tasks/status/"+status+query, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("status", status)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		return rec
+	}
+	ids := func(rec *httptest.ResponseRecorder) []int64 {
+		var tasks []*models.Task
+		json.NewDecoder(rec.Body).Decode(&tasks)
+		var ids []int64
+		for _, task := range tasks {
+			ids = append(ids, task.ID)
+		}
+		return ids
+	}
+
+	tests := []struct {
+		name       string
+		rec        *httptest.ResponseRecorder
+		wantIDs    []int64
+		wantTotal  string
+		wantCursor string
+		// wantEstimate adds up the estimates of every page
+		wantEstimate string
+	}{
+		{"todo", serve(handler.ListTodoTasks, "", ""), []int64{1, 3, 5}, "3", "", "9"},
+		{"done", serve(handler.ListDoneTasks, "", ""), []int64{2, 4}, "2", "", "6"},
+		{"by status", serve(handler.ListTasksByStatus, "done", ""), []int64{2, 4}, "2", "", "6"},
+		{"first page", serve(handler.ListTasksByStatus, "todo", "?limit=2"), []int64{1, 3}, "3", "3", "9"},
+		{"cursor page", serve(handler.ListTasksByStatus, "todo", "?limit=2&after=3"), []int64{5}, "3", "", "9"},
+		{"offset page", serve(handler.ListTodoTasks, "", "?offset=1"), []int64{3, 5}, "3", "", "9"},
+	}
+	for _, tt := range tests {
+		if tt.rec.Code != http.StatusOK {
+			t.Errorf("%s: status = %v, want 200", tt.name, tt.rec.Code)
+			continue
+		}
+		if got := tt.rec.Header().Get(TotalCountHeader); got != tt.wantTotal {
+			t.Errorf("%s: X-Total-Count = %q, want %q", tt.name, got, tt.wantTotal)
+		}
+		if got := tt.rec.Header().Get(TotalEstimateHeader); got != tt.wantEstimate {
+			t.Errorf("%s: X-Total-Estimate = %q, want %q", tt.name, got, tt.wantEstimate)
+		}
+		if got := tt.rec.Header().Get("X-Next-Cursor"); got != tt.wantCursor {
+			t.Errorf("%s: X-Next-Cursor = %q, want %q", tt.name, got, tt.wantCursor)
+		}
+		if got := ids(tt.rec); !slices.Equal(got, tt.wantIDs) {
+			t.Errorf("%s: IDs = %v, want %v", tt.name, got, tt.wantIDs)
+		}
+	}
+
+	if rec := serve(handler.ListTasksByStatus, "doing", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown status: status = %v, want 404", rec.Code)
+	}
+	if rec := serve(handler.ListTodoTasks, "", "?q=task"); rec.Code != http.StatusBadRequest {
+		t.Errorf("search on a status route: status = %v, want 400", rec.Code)
+	}
+}
+
+// noSearchRepository wraps a repository whose backend lacks full-text search
+type noSearchRepository struct {
+	*repository.MemoryRepository
+}
+
+func (r noSearchRepository) Capabilities() repository.Capabilities {
+	return repository.Capabilities{}
+}
+
+func TestTaskHandler_ListTasks_Search(t *testing.T) {
+	ctx := context.Background()
+
+	repo := repository.NewMemoryRepository()
+	repo.Create(ctx, &models.Task{Title: "Write docs"})
+	repo.Create(ctx, &models.Task{Title: "Review code", Description: "Check the DOCS folder"})
+	repo.Create(ctx, &models.Task{Title: "Deploy"})
+
+	t.Run("matching tasks", func(t *testing.T) {
+		handler := NewTaskHandler(repo)
+		req := httptest.NewRequest("GET", "/tasks?q=docs", nil)
+		rec := httptest.NewRecorder()
+
+		handler.ListTasks(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %v, want %v", rec.Code, http.StatusOK)
+		}
+
+		var tasks []*models.Task
+		json.NewDecoder(rec.Body).Decode(&tasks)
+		if len(tasks) != 2 {
+			t.Errorf("got %d tasks, want 2", len(tasks))
+		}
+		if got := rec.Header().Get(TotalCountHeader); got != "2" {
+			t.Errorf("X-Total-Count = %q, want 2", got)
+		}
+	})
+
+	t.Run("backend without search", func(t *testing.T) {
+		handler := NewTaskHandler(noSearchRepository{repo})
+		req := httptest.NewRequest("GET", "/tasks?q=docs", nil)
+		rec := httptest.NewRecorder()
+
+		handler.ListTasks(rec, req)
+
+		if rec.Code != http.StatusNotImplemented {
+			t.Errorf("status = %v, want %v", rec.Code, http.StatusNotImplemented)
+		}
+	})
+}
+
 func TestTaskHandler_GetTask(t *testing.T) {
+	ctx := context.Background()
+
 	repo := repository.NewMemoryRepository()
 	handler := NewTaskHandler(repo)
 
-	created, _ := repo.Create(&models.Task{Title: "Test Task"})
+	created, _ := repo.Create(ctx, &models.Task{Title: "Test Task"})
 
 	tests := []struct {
 		name       string
@@ -167,10 +416,12 @@ func TestTaskHandler_GetTask(t *testing.T) {
 }
 
 func TestTaskHandler_UpdateTask(t *testing.T) {
+	ctx := context.Background()
+
 	repo := repository.NewMemoryRepository()
 	handler := NewTaskHandler(repo)
 
-	created, _ := repo.Create(&models.Task{Title: "Original Title"})
+	created, _ := repo.Create(ctx, &models.Task{Title: "Original Title"})
 
 	tests := []struct {
 		name       string
@@ -189,13 +440,13 @@ func TestTaskHandler_UpdateTask(t *testing.T) {
 			name:       "invalid status",
 			id:         "1",
 			body:       `{"title":"Updated Title","status":"invalid"}`,
-			wantStatus: http.StatusBadRequest,
+			wantStatus: http.StatusUnprocessableEntity,
 		},
 		{
 			name:       "missing title",
 			id:         "1",
 			body:       `{"description":"Updated Desc","status":"done"}`,
-			wantStatus: http.StatusBadRequest,
+			wantStatus: http.StatusUnprocessableEntity,
 		},
 		{
 			name:       "non-existent task",
@@ -238,11 +489,63 @@ func TestTaskHandler_UpdateTask(t *testing.T) {
 	}
 }
 
+func TestTaskHandler_CompleteAndReopen(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	handler := NewTaskHandler(repo)
+	repo.Create(ctx, &models.Task{Title: "Water plants", Description: "Balcony too"})
+
+	serve := func(id string, header http.Header, h http.HandlerFunc) (*httptest.ResponseRecorder, models.Task) {
+		req := httptest.NewRequest("POST", "/tasks/"+id, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		var task models.Task
+		json.Unmarshal(rec.Body.Bytes(), &task)
+		return rec, task
+	}
+
+	rec, task := serve("1", nil, handler.CompleteTask)
+	if rec.Code != http.StatusOK || task.Status != models.StatusDone || task.CompletedAt == nil || task.Description != "Balcony too" {
+		t.Fatalf("complete: status %d, task %+v", rec.Code, task)
+	}
+	etag := rec.Header().Get("ETag")
+
+	// Completing a done task changes nothing, so the ETag still matches
+	rec, again := serve("1", http.Header{"If-Match": {etag}}, handler.CompleteTask)
+	if rec.Code != http.StatusOK || !again.CompletedAt.Equal(*task.CompletedAt) || rec.Header().Get("ETag") != etag {
+		t.Errorf("complete again: status %d, task %+v", rec.Code, again)
+	}
+
+	rec, task = serve("1", http.Header{"If-Match": {`"stale"`}}, handler.ReopenTask)
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("reopen with stale If-Match: status %d, want 412", rec.Code)
+	}
+	rec, task = serve("1", http.Header{"If-Match": {etag}}, handler.ReopenTask)
+	if rec.Code != http.StatusOK || task.Status != models.StatusTodo || task.CompletedAt != nil {
+		t.Errorf("reopen: status %d, task %+v", rec.Code, task)
+	}
+
+	if rec, _ := serve("999", nil, handler.CompleteTask); rec.Code != http.StatusNotFound {
+		t.Errorf("complete missing task: status %d, want 404", rec.Code)
+	}
+	if rec, _ := serve("abc", nil, handler.ReopenTask); rec.Code != http.StatusBadRequest {
+		t.Errorf("reopen invalid ID: status %d, want 400", rec.Code)
+	}
+}
+
 func TestTaskHandler_DeleteTask(t *testing.T) {
+	ctx := context.Background()
+
 	repo := repository.NewMemoryRepository()
 	handler := NewTaskHandler(repo)
 
-	repo.Create(&models.Task{Title: "Test Task"})
+	repo.Create(ctx, &models.Task{Title: "Test Task"})
 
 	tests := []struct {
 		name       string
@@ -284,3 +587,235 @@ func TestTaskHandler_DeleteTask(t *testing.T) {
 		})
 	}
 }
+
+func TestTaskHandler_CreateLink(t *testing.T) {
+	ctx := context.Background()
+
+	repo := repository.NewMemoryRepository()
+	handler := NewTaskHandler(repo)
+
+	repo.Create(ctx, &models.Task{Title: "Task 1"})
+	repo.Create(ctx, &models.Task{Title: "Task 2"})
+
+	tests := []struct {
+		name       string
+		id         string
+		body       string
+		wantStatus int
+	}{
+		{
+			name:       "valid link",
+			id:         "1",
+			body:       `{"type":"duplicate_of","task_id":2}`,
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "duplicate link",
+			id:         "1",
+			body:       `{"type":"duplicate_of","task_id":2}`,
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "invalid type",
+			id:         "1",
+			body:       `{"type":"blocks","task_id":2}`,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "self link",
+			id:         "1",
+			body:       `{"type":"relates_to","task_id":1}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "missing target",
+			id:         "1",
+			body:       `{"type":"relates_to","task_id":999}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "non-existent task",
+			id:         "999",
+			body:       `{"type":"relates_to","task_id":1}`,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "invalid ID",
+			id:         "abc",
+			body:       `{"type":"relates_to","task_id":1}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/tasks/"+tt.id+"/links", bytes.NewBufferString(tt.body))
+			rec := httptest.NewRecorder()
+
+			// Create chi context with URL param
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.id)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			handler.CreateLink(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %v, want %v", rec.Code, tt.wantStatus)
+			}
+
+			if tt.wantStatus == http.StatusCreated {
+				var task models.Task
+				json.NewDecoder(rec.Body).Decode(&task)
+				if len(task.Links) != 1 {
+					t.Fatalf("got %d links, want 1", len(task.Links))
+				}
+				if task.Links[0].TaskID != 2 || task.Links[0].Type != models.LinkDuplicateOf {
+					t.Errorf("link = %+v, want duplicate_of 2", task.Links[0])
+				}
+			}
+		})
+	}
+}
+
+// failingRepository wraps a repository whose backend is unavailable
+type failingRepository struct {
+	*repository.MemoryRepository
+}
+
+func (r failingRepository) List(ctx context.Context, opts repository.ListOptions) ([]*models.Task, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestTaskHandler_Logging(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := logging.NewContext(context.Background(), logging.New(&buf, slog.LevelInfo))
+
+	t.Run("repository errors", func(t *testing.T) {
+		buf.Reset()
+		handler := NewTaskHandler(failingRepository{repository.NewMemoryRepository()})
+		rec := httptest.NewRecorder()
+
+		handler.ListTasks(rec, httptest.NewRequest("GET", "/tasks", nil).WithContext(ctx))
+
+		if rec.Code != http.StatusInternalServerError {
+			t.Fatalf("status = %v, want %v", rec.Code, http.StatusInternalServerError)
+		}
+		if strings.Contains(rec.Body.String(), "connection refused") {
+			t.Error("response leaks the repository error")
+		}
+
+		var record map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+			t.Fatalf("log output is not JSON: %v", err)
+		}
+		if record["level"] != "ERROR" || record["error"] != "connection refused" {
+			t.Errorf("log record = %v", record)
+		}
+	})
+
+	t.Run("validation failures", func(t *testing.T) {
+		buf.Reset()
+		handler := NewTaskHandler(repository.NewMemoryRepository())
+		req := httptest.NewRequest("POST", "/tasks", bytes.NewBufferString(`{"title":""}`)).WithContext(ctx)
+
+		handler.CreateTask(httptest.NewRecorder(), req)
+
+		var record struct {
+			Msg        string                 `json:"msg"`
+			Violations []validation.Violation `json:"violations"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+			t.Fatalf("log output is not JSON: %v", err)
+		}
+		if record.Msg != "validation failed" || len(record.Violations) != 1 || record.Violations[0].Field != "title" {
+			t.Errorf("log record = %+v", record)
+		}
+	})
+}
+
+// unavailableSearchRepository wraps a repository whose search index is down
+type unavailableSearchRepository struct {
+	*repository.MemoryRepository
+}
+
+func (r unavailableSearchRepository) Search(ctx context.Context, query string) ([]*models.Task, error) {
+	return nil, fmt.Errorf("querying index: %w", repository.ErrUnavailable)
+}
+
+func TestTaskHandler_SearchUnavailable(t *testing.T) {
+	reg := health.NewRegistry()
+	reg.Register(health.Search, false, nil)
+	handler := NewTaskHandler(unavailableSearchRepository{repository.NewMemoryRepository()}, WithHealth(reg))
+
+	rec := httptest.NewRecorder()
+	handler.ListTasks(rec, httptest.NewRequest("GET", "/tasks?q=docs", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %v, want %v", rec.Code, http.StatusServiceUnavailable)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After header")
+	}
+	var errResp ErrorResponse
+	json.NewDecoder(rec.Body).Decode(&errResp)
+	if errResp.Code != CodeSearchUnavailable {
+		t.Errorf("code = %q, want %q", errResp.Code, CodeSearchUnavailable)
+	}
+	if reg.Available(health.Search) {
+		t.Error("the failure should be reported to the health registry")
+	}
+
+	// Plain listing does not depend on search
+	rec = httptest.NewRecorder()
+	handler.ListTasks(rec, httptest.NewRequest("GET", "/tasks", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("list status = %v, want %v", rec.Code, http.StatusOK)
+	}
+}
+
+// BenchmarkTaskHandler measures the handlers without the router or
+// network, on a store of 5k tasks
+func BenchmarkTaskHandler(b *testing.B) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	for i := range 5000 {
+		repo.Create(ctx, &models.Task{Title: fmt.Sprintf("Task %d", i), Description: "benchmark"})
+	}
+	handler := NewTaskHandler(repo)
+	withID := func(req *http.Request, id string) *http.Request {
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id)
+		return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	}
+
+	b.Run("GetTask", func(b *testing.B) {
+		req := withID(httptest.NewRequest("GET", "/tasks/2500", nil), "2500")
+		for b.Loop() {
+			handler.GetTask(httptest.NewRecorder(), req)
+		}
+	})
+	b.Run("ListTasks/page", func(b *testing.B) {
+		req := httptest.NewRequest("GET", "/tasks?limit=50&after=2500", nil)
+		for b.Loop() {
+			handler.ListTasks(httptest.NewRecorder(), req)
+		}
+	})
+	b.Run("ListTasks/all", func(b *testing.B) {
+		req := httptest.NewRequest("GET", "/tasks", nil)
+		for b.Loop() {
+			handler.ListTasks(httptest.NewRecorder(), req)
+		}
+	})
+	b.Run("CreateTask", func(b *testing.B) {
+		body := []byte(`{"title":"Benchmark","description":"created in a loop"}`)
+		for b.Loop() {
+			handler.CreateTask(httptest.NewRecorder(), httptest.NewRequest("POST", "/tasks", bytes.NewReader(body)))
+		}
+	})
+	b.Run("UpdateTask", func(b *testing.B) {
+		body := []byte(`{"title":"Benchmark","description":"updated in a loop","status":"done"}`)
+		for b.Loop() {
+			handler.UpdateTask(httptest.NewRecorder(), withID(httptest.NewRequest("PUT", "/tasks/42", bytes.NewReader(body)), "42"))
+		}
+	})
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/light-bringer/cert-tasks/internal/ids"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+// WithIDFormat addresses tasks in /tasks/{id} routes by their opaque
+// identifier in format, such as a ULID, instead of their numeric ID, so
+// that task URLs cannot be guessed. The repository must assign them, see
+// repository.WithIDFormat.
+func WithIDFormat(format ids.Format) Option {
+	return func(h *TaskHandler) {
+		h.idFormat = format
+	}
+}
+
+// taskID reads the task ID from the {id} route parameter and writes an
+// error response if it is invalid or, for an opaque identifier, unknown
+//
+//api:changelog 0.2.0 changed parameter /tasks/{id}: Takes the task's uid instead of its numeric ID when STORAGE_ID_FORMAT is ulid or uuidv7
+func (h *TaskHandler) taskID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	param := chi.URLParam(r, "id")
+	if !h.idFormat.Opaque() {
+		id, err := strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			h.respondWithError(w, r, http.StatusBadRequest, CodeInvalidID, "invalid task ID")
+			return 0, false
+		}
+		return id, true
+	}
+
+	if !h.idFormat.Valid(param) {
+		h.respondWithError(w, r, http.StatusBadRequest, CodeInvalidID, "invalid task ID: expected a "+string(h.idFormat))
+		return 0, false
+	}
+	task, err := h.repo.GetByUID(r.Context(), param)
+	if err != nil {
+		if errors.Is(err, repository.ErrTaskNotFound) {
+			h.respondWithError(w, r, http.StatusNotFound, CodeNotFound, "task not found")
+			return 0, false
+		}
+		h.respondWithRepositoryError(w, r, err, "failed to retrieve task")
+		return 0, false
+	}
+	return task.ID, true
+}
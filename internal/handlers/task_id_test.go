@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/light-bringer/cert-tasks/internal/ids"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+func TestTaskHandler_OpaqueIDs(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository(repository.WithIDFormat(ids.ULID))
+	handler := NewTaskHandler(repo, WithIDFormat(ids.ULID))
+	task, _ := repo.Create(ctx, &models.Task{Title: "Opaque"})
+	if !ids.ULID.Valid(task.UID) {
+		t.Fatalf("created task uid = %q", task.UID)
+	}
+
+	serve := func(method, id, body string, h http.HandlerFunc) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/tasks/"+id, strings.NewReader(body))
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		return rec
+	}
+
+	rec := serve("GET", task.UID, "", handler.GetTask)
+	var got models.Task
+	json.NewDecoder(rec.Body).Decode(&got)
+	if rec.Code != http.StatusOK || got.ID != task.ID || got.UID != task.UID {
+		t.Fatalf("GET by uid: status %d, task %+v", rec.Code, got)
+	}
+
+	// Numeric IDs would make URLs guessable again
+	if rec := serve("GET", "1", "", handler.GetTask); rec.Code != http.StatusBadRequest {
+		t.Errorf("GET by numeric ID: status %d, want 400", rec.Code)
+	}
+	if rec := serve("GET", ids.NewULID(task.CreatedAt), "", handler.GetTask); rec.Code != http.StatusNotFound {
+		t.Errorf("GET unknown uid: status %d, want 404", rec.Code)
+	}
+
+	rec = serve("PUT", task.UID, `{"title":"Renamed","description":"","status":"done"}`, handler.UpdateTask)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT by uid: status %d, body %s", rec.Code, rec.Body)
+	}
+	if rec := serve("DELETE", task.UID, "", handler.DeleteTask); rec.Code != http.StatusNoContent {
+		t.Errorf("DELETE by uid: status %d", rec.Code)
+	}
+	if _, err := repo.GetByUID(ctx, task.UID); err != repository.ErrTaskNotFound {
+		t.Errorf("GetByUID after delete: %v", err)
+	}
+}
@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+// TenantHeader selected the tenant for content policies before
+// workspaces; it is now an alias for WorkspaceHeader
+//
+//api:changelog 0.2.0 deprecated header X-Tenant-ID: Use X-Workspace-ID; X-Tenant-ID is read only when X-Workspace-ID is absent
+const TenantHeader = "X-Tenant-ID"
+
+// tenantFromRequest returns the workspace a request acts in, which selects
+// its content policies, or "" for the default policies
+func tenantFromRequest(r *http.Request) string {
+	if workspace := repository.WorkspaceFromContext(r.Context()); workspace != "" {
+		return workspace
+	}
+	return r.Header.Get(TenantHeader)
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/auth"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+	"github.com/light-bringer/cert-tasks/internal/service"
+	"github.com/light-bringer/cert-tasks/internal/validation"
+)
+
+// TimezoneHeader names the IANA time zone, such as Europe/Berlin, that a
+// request's due dates are read and shown in
+const TimezoneHeader = "X-Timezone"
+
+// location returns the time zone a request's due dates are read and shown
+// in: the X-Timezone header, else the zoneinfo claim of the user's JWT,
+// else the workspace's time zone, else UTC. An unknown X-Timezone gets a
+// 422 response.
+//
+//api:changelog 0.2.0 added header X-Timezone: IANA time zone, e.g. Europe/Berlin, that due dates are read in from the due field and shown in as due_at; overrides the JWT zoneinfo claim and the workspace's timezone
+func (h *TaskHandler) location(w http.ResponseWriter, r *http.Request) (*time.Location, bool) {
+	// Responses differ by zone at the same URL
+	w.Header().Add("Vary", TimezoneHeader)
+
+	loc, err := h.resolveLocation(r)
+	if err != nil {
+		h.respondWithValidationError(w, r, err)
+		return nil, false
+	}
+	return loc, true
+}
+
+// zone returns the time zone of a request as location does, for the task
+// service to resolve once the request body is valid. *loc holds the zone
+// once it is resolved.
+func (h *TaskHandler) zone(w http.ResponseWriter, r *http.Request, loc **time.Location) service.Zone {
+	return func() (*time.Location, error) {
+		w.Header().Add("Vary", TimezoneHeader)
+		var err error
+		*loc, err = h.resolveLocation(r)
+		return *loc, err
+	}
+}
+
+// resolveLocation returns the time zone of a request, or validation.Errors
+// for an unknown X-Timezone
+func (h *TaskHandler) resolveLocation(r *http.Request) (*time.Location, error) {
+	if name := r.Header.Get(TimezoneHeader); name != "" {
+		loc, err := validation.LoadTimezone(name)
+		if err != nil {
+			return nil, validation.Errors{{
+				Field:   TimezoneHeader,
+				Rule:    validation.RuleFormat,
+				Message: TimezoneHeader + " must be an IANA time zone such as Europe/Berlin",
+			}}
+		}
+		return loc, nil
+	}
+
+	// The identity provider's zone is not ours to reject
+	if name, ok := auth.TimezoneFromContext(r.Context()); ok {
+		if loc, err := validation.LoadTimezone(name); err == nil {
+			return loc, nil
+		}
+	}
+
+	if h.workspaces != nil {
+		id := repository.WorkspaceFromContext(r.Context())
+		if id == "" {
+			id = models.DefaultWorkspace
+		}
+		if ws, err := h.workspaces.GetWorkspace(r.Context(), id); err == nil {
+			return ws.Location(), nil
+		}
+	}
+	return time.UTC, nil
+}
+
+// localize returns tasks with due dates, including those of expanded
+// links, shown in loc. Tasks are copied before they are changed, since
+// they may be the repository's own.
+func localize(tasks []*models.Task, loc *time.Location) []*models.Task {
+	if loc == time.UTC {
+		return tasks
+	}
+	localized := make([]*models.Task, len(tasks))
+	for i, task := range tasks {
+		localized[i] = localizeTask(task, loc)
+	}
+	return localized
+}
+
+// localizeTask is localize for one task
+func localizeTask(task *models.Task, loc *time.Location) *models.Task {
+	if loc == time.UTC || task == nil {
+		return task
+	}
+	c := copyTask(task)
+	if c.DueAt != nil {
+		due := c.DueAt.In(loc)
+		c.DueAt = &due
+	}
+	for i, link := range c.Links {
+		c.Links[i].Task = localizeTask(link.Task, loc)
+	}
+	return c
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+func TestTaskHandler_Timezone(t *testing.T) {
+	if _, err := time.LoadLocation("Asia/Tokyo"); err != nil {
+		t.Skip("no time zone database:", err)
+	}
+	repo := repository.NewMemoryRepository()
+	handler := NewTaskHandler(repo, WithWorkspaces(repo))
+
+	serve := func(method, target, timezone, body string, header http.Header, h http.HandlerFunc) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		if timezone != "" {
+			req.Header.Set(TimezoneHeader, timezone)
+		}
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", "1")
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+		req = req.WithContext(repository.WithWorkspace(ctx, "tokyo"))
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		return rec
+	}
+
+	rec := serve("POST", "/workspaces", "", `{"id":"tokyo","name":"Tokyo","timezone":"Mars/Base"}`, nil, handler.CreateWorkspace)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("unknown workspace timezone: status %d, want 422", rec.Code)
+	}
+	rec = serve("POST", "/workspaces", "", `{"id":"tokyo","name":"Tokyo","timezone":"Asia/Tokyo"}`, nil, handler.CreateWorkspace)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create workspace: status %d, body %s", rec.Code, rec.Body)
+	}
+
+	// The workspace's zone reads and shows due dates
+	rec = serve("POST", "/tasks", "", `{"title":"Renew cert","due":"2026-11-02 09:00"}`, nil, handler.CreateTask)
+	var task models.Task
+	json.NewDecoder(rec.Body).Decode(&task)
+	if rec.Code != http.StatusCreated || task.DueAt == nil || task.DueAt.Format(time.RFC3339) != "2026-11-02T09:00:00+09:00" {
+		t.Fatalf("create: status %d, due_at %v", rec.Code, task.DueAt)
+	}
+
+	// X-Timezone overrides it, showing the same instant
+	rec = serve("GET", "/tasks/1", "Europe/London", "", nil, handler.GetTask)
+	json.NewDecoder(rec.Body).Decode(&task)
+	if task.DueAt.Format(time.RFC3339) != "2026-11-02T00:00:00Z" {
+		t.Errorf("get with X-Timezone: due_at %v", task.DueAt)
+	}
+	if vary := rec.Header().Values("Vary"); !strings.Contains(strings.Join(vary, ","), TimezoneHeader) {
+		t.Errorf("Vary = %v, want %s", vary, TimezoneHeader)
+	}
+
+	rec = serve("GET", "/tasks", "", "", nil, handler.ListTasks)
+	var list []models.Task
+	json.NewDecoder(rec.Body).Decode(&list)
+	if len(list) != 1 || list[0].DueAt.Format(time.RFC3339) != "2026-11-02T09:00:00+09:00" {
+		t.Errorf("list: %+v", list)
+	}
+
+	// An ETag read in one zone is the precondition for writes in that zone
+	etag := serve("GET", "/tasks/1", "America/New_York", "", nil, handler.GetTask).Header().Get("ETag")
+	update := `{"title":"Renew cert","status":"done","due":"2026-11-02 09:00"}`
+	if rec := serve("PUT", "/tasks/1", "", update, http.Header{"If-Match": {etag}}, handler.UpdateTask); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("If-Match from another zone: status %d, want 412", rec.Code)
+	}
+	if rec := serve("PUT", "/tasks/1", "America/New_York", `{"title":"Renew cert","status":"done","due":"2026-11-01 19:00"}`, http.Header{"If-Match": {etag}}, handler.UpdateTask); rec.Code != http.StatusOK {
+		t.Errorf("If-Match from the same zone: status %d, body %s", rec.Code, rec.Body)
+	}
+
+	if rec := serve("GET", "/tasks/1", "Local", "", nil, handler.GetTask); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("X-Timezone Local: status %d, want 422", rec.Code)
+	}
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/light-bringer/cert-tasks/internal/logging"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+// DefaultUndoWindow is how long deletes can be undone by default
+const DefaultUndoWindow = time.Minute
+
+// UndoTokenHeader carries the token that undoes a delete
+//
+//api:changelog 0.2.0 added header X-Undo-Token: Returned by DELETE /tasks/{id} and confirmed bulk deletes; POST /undo/{token} puts the tasks back within the undo window
+const UndoTokenHeader = "X-Undo-Token"
+
+// WithUndoWindow sets how long deletes can be undone; zero turns undo off
+func WithUndoWindow(d time.Duration) Option {
+	return func(h *TaskHandler) {
+		h.undo.window = d
+	}
+}
+
+// pendingUndo holds the tasks a delete removed, as they were
+type pendingUndo struct {
+	tenant  string
+	owner   string
+	tasks   []*models.Task
+	expires time.Time
+}
+
+// undoLog holds single-use undo tokens. Deleted tasks are kept in memory
+// until their token is used or expires.
+type undoLog struct {
+	window time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	pending map[string]pendingUndo
+}
+
+func newUndoLog(window time.Duration) *undoLog {
+	return &undoLog{
+		window:  window,
+		now:     time.Now,
+		pending: make(map[string]pendingUndo),
+	}
+}
+
+// issue stores the deleted tasks under a new random token and sets it as
+// the X-Undo-Token header. It does nothing while undo is off. Expired
+// entries are dropped on the way.
+func (u *undoLog) issue(w http.ResponseWriter, r *http.Request, tasks []*models.Task) {
+	if u.window <= 0 || len(tasks) == 0 {
+		return
+	}
+	var b [16]byte
+	rand.Read(b[:])
+	token := hex.EncodeToString(b[:])
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	now := u.now()
+	for t, old := range u.pending {
+		if now.After(old.expires) {
+			delete(u.pending, t)
+		}
+	}
+	u.pending[token] = pendingUndo{
+		tenant:  tenantFromRequest(r),
+		owner:   repository.OwnerFromContext(r.Context()),
+		tasks:   tasks,
+		expires: now.Add(u.window),
+	}
+	w.Header().Set(UndoTokenHeader, token)
+}
+
+// redeem removes and returns the pending undo for token, failing for
+// unknown, expired and already redeemed tokens. A token issued to another
+// workspace or owner also fails but stays usable by its own, so guessing
+// at it cannot throw the undo away.
+func (u *undoLog) redeem(token, tenant, owner string) (pendingUndo, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	p, ok := u.pending[token]
+	if !ok || p.tenant != tenant || p.owner != owner {
+		return pendingUndo{}, false
+	}
+	delete(u.pending, token)
+	if u.now().After(p.expires) {
+		return pendingUndo{}, false
+	}
+	return p, true
+}
+
+// Undo handles POST /undo/{token}. It puts back the tasks removed by the
+// delete that returned the token, with their IDs, provided it is used
+// within the undo window by the same workspace and owner. Links other
+// tasks had to them are not restored.
+//
+//api:changelog 0.2.0 added endpoint POST /undo/{token}: Put back the tasks removed by a delete that returned X-Undo-Token
+func (h *TaskHandler) Undo(w http.ResponseWriter, r *http.Request) {
+	pending, ok := h.undo.redeem(chi.URLParam(r, "token"), tenantFromRequest(r), repository.OwnerFromContext(r.Context()))
+	if !ok {
+		h.respondWithError(w, r, http.StatusNotFound, CodeNotFound, "undo token is unknown, expired or already used")
+		return
+	}
+
+	restored, err := h.repo.Undelete(r.Context(), pending.tasks)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrTaskExists):
+			h.respondWithError(w, r, http.StatusConflict, CodeConflict, "a deleted task has been recreated")
+		case errors.Is(err, repository.ErrWorkspaceNotFound):
+			h.respondWithError(w, r, http.StatusNotFound, CodeWorkspaceNotFound, "workspace not found")
+		case errors.Is(err, repository.ErrTaskLimitReached):
+			h.respondWithError(w, r, http.StatusForbidden, CodeTaskLimitReached, "task limit reached")
+		default:
+			h.respondWithRepositoryError(w, r, err, "failed to undo delete")
+		}
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("delete undone", slog.Int("restored", len(restored)))
+	respondWithJSON(w, http.StatusOK, models.UndoResult{Restored: len(restored), Tasks: restored})
+}
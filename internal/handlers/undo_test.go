@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+// undo calls Undo through a router, so the token reaches it as a URL
+// parameter
+func undo(handler *TaskHandler, token string, header ...string) *httptest.ResponseRecorder {
+	r := chi.NewRouter()
+	r.Post("/undo/{token}", handler.Undo)
+	req := httptest.NewRequest("POST", "/undo/"+token, nil)
+	for i := 0; i < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestTaskHandler_Undo(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("single delete", func(t *testing.T) {
+		repo := repository.NewMemoryRepository()
+		handler := NewTaskHandler(repo)
+		task, _ := repo.Create(ctx, &models.Task{Title: "Write report", Description: "draft"})
+
+		r := chi.NewRouter()
+		r.Delete("/tasks/{id}", handler.DeleteTask)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("DELETE", "/tasks/1", nil))
+		token := rec.Header().Get(UndoTokenHeader)
+		if rec.Code != http.StatusNoContent || token == "" {
+			t.Fatalf("delete = %v with token %q", rec.Code, token)
+		}
+
+		rec = undo(handler, token)
+		var result models.UndoResult
+		json.NewDecoder(rec.Body).Decode(&result)
+		if rec.Code != http.StatusOK || result.Restored != 1 || result.Tasks[0].ID != task.ID {
+			t.Fatalf("undo = %v %+v", rec.Code, result)
+		}
+		if got, err := repo.GetByID(ctx, task.ID); err != nil || got.Description != "draft" {
+			t.Errorf("restored task = %+v, %v", got, err)
+		}
+
+		// Tokens are single-use
+		if rec := undo(handler, token); rec.Code != http.StatusNotFound {
+			t.Errorf("reused token status = %v, want %v", rec.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("bulk delete", func(t *testing.T) {
+		repo := repository.NewMemoryRepository()
+		seedBulk(repo)
+		handler := NewTaskHandler(repo)
+
+		p := preview(t, handler, "status=done")
+		rec := bulkDelete(handler, "status=done&confirm="+p.Token)
+		token := rec.Header().Get(UndoTokenHeader)
+		if rec.Code != http.StatusOK || token == "" {
+			t.Fatalf("confirm = %v with token %q", rec.Code, token)
+		}
+
+		if rec := undo(handler, token); rec.Code != http.StatusOK {
+			t.Fatalf("undo status = %v: %s", rec.Code, rec.Body)
+		}
+		if all, _ := repo.GetAll(ctx); len(all) != 3 {
+			t.Errorf("%d tasks after undo, want 3", len(all))
+		}
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		repo := repository.NewMemoryRepository()
+		seedBulk(repo)
+		handler := NewTaskHandler(repo)
+		now := time.Now()
+		handler.undo.now = func() time.Time { return now }
+
+		p := preview(t, handler, "status=done")
+		token := bulkDelete(handler, "status=done&confirm="+p.Token).Header().Get(UndoTokenHeader)
+		now = now.Add(DefaultUndoWindow + time.Second)
+
+		rec := undo(handler, token)
+		var errResp ErrorResponse
+		json.NewDecoder(rec.Body).Decode(&errResp)
+		if rec.Code != http.StatusNotFound || errResp.Code != CodeNotFound {
+			t.Errorf("undo = %v %q, want %v %q", rec.Code, errResp.Code, http.StatusNotFound, CodeNotFound)
+		}
+	})
+
+	t.Run("other workspace", func(t *testing.T) {
+		repo := repository.NewMemoryRepository()
+		seedBulk(repo)
+		handler := NewTaskHandler(repo)
+
+		p := preview(t, handler, "status=done")
+		token := bulkDelete(handler, "status=done&confirm="+p.Token).Header().Get(UndoTokenHeader)
+		if rec := undo(handler, token, TenantHeader, "acme"); rec.Code != http.StatusNotFound {
+			t.Errorf("undo from another workspace status = %v, want %v", rec.Code, http.StatusNotFound)
+		}
+		if rec := undo(handler, token); rec.Code != http.StatusOK {
+			t.Errorf("undo from its own workspace after another tried = %v, want %v", rec.Code, http.StatusOK)
+		}
+	})
+
+	t.Run("turned off", func(t *testing.T) {
+		repo := repository.NewMemoryRepository()
+		seedBulk(repo)
+		handler := NewTaskHandler(repo, WithUndoWindow(0))
+
+		p := preview(t, handler, "status=done")
+		if rec := bulkDelete(handler, "status=done&confirm="+p.Token); rec.Header().Get(UndoTokenHeader) != "" {
+			t.Errorf("undo token sent while undo is off")
+		}
+	})
+}
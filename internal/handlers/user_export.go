@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"archive/zip"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/light-bringer/cert-tasks/internal/blob"
+	"github.com/light-bringer/cert-tasks/internal/erasure"
+	"github.com/light-bringer/cert-tasks/internal/jobs"
+	"github.com/light-bringer/cert-tasks/internal/logging"
+	"github.com/light-bringer/cert-tasks/internal/models"
+)
+
+// jobKindUserExport is the job kind exporting a user's data
+const jobKindUserExport = "users.export"
+
+// userExportVersion is the format_version of user exports' manifest.json,
+// raised when a file changes incompatibly
+const userExportVersion = 1
+
+// userExportDownload is how a finished user export is downloaded
+var userExportDownload = blob.Download{Filename: "user-data.zip", ContentType: "application/zip"}
+
+// userExportJob is the payload of a user export job
+type userExportJob struct {
+	UserID string `json:"user_id"`
+}
+
+// userExportManifest is manifest.json, the first file of a user export
+type userExportManifest struct {
+	FormatVersion int              `json:"format_version"`
+	UserID        string           `json:"user_id"`
+	GeneratedAt   time.Time        `json:"generated_at"`
+	Files         []userExportFile `json:"files"`
+
+	// NotStored are kinds of personal data this server does not keep, so
+	// the export has no file for them
+	NotStored []string `json:"not_stored"`
+}
+
+// userExportFile describes one file of a user export
+type userExportFile struct {
+	Name        string `json:"name"`
+	Format      string `json:"format"`
+	Records     int    `json:"records"`
+	Description string `json:"description"`
+}
+
+// ExportUser handles GET /users/{id}/export, queuing a job that collects
+// the data of the user with the given ID in every workspace into a zip
+// file, and responds 202 with the job, polled at
+// GET /users/{id}/exports/{job}. The user ID is not logged.
+//
+//api:changelog 0.2.0 added endpoint GET /users/{id}/export: Export a user's tasks, task revisions and webhooks as a zip of JSON and CSV files in the background, for data portability
+func (h *TaskHandler) ExportUser(w http.ResponseWriter, r *http.Request) {
+	if !h.jobsEnabled(w, r) {
+		return
+	}
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	job, err := h.jobs.queue.Enqueue(jobKindUserExport, userExportJob{UserID: userID})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to queue user export", slog.Any("error", err))
+		h.respondWithError(w, r, http.StatusInternalServerError, CodeInternal, "failed to queue user export")
+		return
+	}
+	logging.FromContext(r.Context()).Info("user export queued", slog.Int64("job_id", job.ID))
+	w.Header().Set("Location", userExportPath(userID, job.ID))
+	respondWithJSON(w, http.StatusAccepted, userExport(job, userID))
+}
+
+// GetUserExport handles GET /users/{id}/exports/{job}, reporting the
+// progress of a user export and, once finished, its download links
+//
+//api:changelog 0.2.0 added endpoint GET /users/{id}/exports/{job}: Progress of a user export, with a download link once finished
+func (h *TaskHandler) GetUserExport(w http.ResponseWriter, r *http.Request) {
+	job, userID, ok := h.findUserExport(w, r)
+	if !ok {
+		return
+	}
+	tj := userExport(job, userID)
+	if tj.DownloadURL != "" {
+		h.signDownload(r, &tj, exportName(job.ID, "zip"), userExportDownload)
+	}
+	respondWithJSON(w, http.StatusOK, tj)
+}
+
+// DownloadUserExport handles GET /users/{id}/exports/{job}/download,
+// serving the zip of a finished user export for ExportRetention
+//
+//api:changelog 0.2.0 added endpoint GET /users/{id}/exports/{job}/download: Download the zip of a finished user export
+func (h *TaskHandler) DownloadUserExport(w http.ResponseWriter, r *http.Request) {
+	job, _, ok := h.findUserExport(w, r)
+	if !ok {
+		return
+	}
+	h.serveExport(w, r, job, exportName(job.ID, "zip"), userExportDownload)
+}
+
+// runUserExport writes the user's data to a zip in the jobs directory and
+// stores it once complete. The user's tasks are read in every workspace;
+// tasks created with API keys belong to no one and are never exported.
+func (h *TaskHandler) runUserExport(ctx context.Context, job jobs.Job) error {
+	var p userExportJob
+	if err := json.Unmarshal(job.Payload, &p); err != nil {
+		return jobs.Permanent(fmt.Errorf("decoding user export job: %w", err))
+	}
+	if p.UserID == "" {
+		return jobs.Permanent(errors.New("user export job has no user"))
+	}
+	h.jobs.pruneExports(ctx)
+
+	ctx = jobScope{Owner: p.UserID}.context(ctx)
+	tasks := []*models.Task{}
+	next := h.exportPages(ctx, bulkFilter{})
+	for {
+		page, err := next()
+		if err != nil {
+			return fmt.Errorf("listing tasks: %w", err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		tasks = append(tasks, page...)
+	}
+	slices.SortFunc(tasks, func(a, b *models.Task) int { return cmp.Compare(a.ID, b.ID) })
+
+	revisions := []*models.TaskRevision{}
+	if h.revisions != nil {
+		for _, task := range tasks {
+			revs, err := h.revisions.ListRevisions(ctx, task.ID)
+			if err != nil {
+				return fmt.Errorf("listing revisions of task %d: %w", task.ID, err)
+			}
+			revisions = append(revisions, revs...)
+		}
+	}
+	webhooks := []*models.Webhook{}
+	if h.webhooks != nil {
+		var err error
+		if webhooks, err = h.webhooks.ListWebhooks(ctx); err != nil {
+			return fmt.Errorf("listing webhooks: %w", err)
+		}
+	}
+
+	manifest := userExportManifest{
+		FormatVersion: userExportVersion,
+		UserID:        p.UserID,
+		GeneratedAt:   time.Now().UTC(),
+		Files: []userExportFile{
+			{"tasks.json", "json", len(tasks), "The user's tasks with every field, as an array"},
+			{"tasks.csv", "csv", len(tasks), "The user's tasks in the columns of GET /tasks/export"},
+			{"revisions.json", "json", len(revisions), "Previous versions of the user's tasks, as an array, oldest first per task"},
+			{"webhooks.json", "json", len(webhooks), "The user's webhooks without their secrets, as an array"},
+		},
+		NotStored: erasure.NotStored,
+	}
+	files := []struct {
+		name  string
+		write func(io.Writer) error
+	}{
+		{"manifest.json", writeJSON(manifest)},
+		{"tasks.json", writeJSON(tasks)},
+		{"tasks.csv", func(w io.Writer) error {
+			enc := newCSVEncoder(w)
+			for _, task := range tasks {
+				if err := enc.Encode(task); err != nil {
+					return err
+				}
+			}
+			return enc.Flush()
+		}},
+		{"revisions.json", writeJSON(revisions)},
+		{"webhooks.json", writeJSON(webhooks)},
+	}
+
+	f, err := os.CreateTemp(h.jobs.dir, "export-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for i, file := range files {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: manifest.GeneratedAt})
+		if err != nil {
+			return err
+		}
+		if err := file.write(w); err != nil {
+			return fmt.Errorf("writing %s: %w", file.name, err)
+		}
+		h.jobs.queue.Report(job.ID, i+1, len(files))
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	if err := h.jobs.store(ctx, exportName(job.ID, "zip"), f); err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).Info("user data exported",
+		slog.Int64("job_id", job.ID),
+		slog.Int("tasks", len(tasks)),
+		slog.Int("revisions", len(revisions)),
+		slog.Int("webhooks", len(webhooks)),
+	)
+	return nil
+}
+
+// writeJSON returns a function writing v as indented JSON
+func writeJSON(v any) func(io.Writer) error {
+	return func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+}
+
+// userID returns the unescaped id parameter, writing a 400 when it is
+// malformed. Subjects such as "auth0|1234" arrive escaped.
+func (h *TaskHandler) userID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userID, err := url.PathUnescape(chi.URLParam(r, "id"))
+	if err != nil || userID == "" {
+		h.respondWithError(w, r, http.StatusBadRequest, CodeInvalidID, "invalid user ID")
+		return "", false
+	}
+	return userID, true
+}
+
+// findUserExport returns the user export job named by the job parameter
+// and its user, writing a 404 when it does not exist or exports another
+// user's data
+func (h *TaskHandler) findUserExport(w http.ResponseWriter, r *http.Request) (jobs.Job, string, bool) {
+	if !h.jobsEnabled(w, r) {
+		return jobs.Job{}, "", false
+	}
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return jobs.Job{}, "", false
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "job"), 10, 64)
+	if err != nil || id < 1 {
+		h.respondWithError(w, r, http.StatusBadRequest, CodeInvalidID, "invalid job ID")
+		return jobs.Job{}, "", false
+	}
+
+	job, ok := h.jobs.queue.Get(id)
+	var p userExportJob
+	if !ok || job.Kind != jobKindUserExport || json.Unmarshal(job.Payload, &p) != nil || p.UserID != userID {
+		h.respondWithError(w, r, http.StatusNotFound, CodeNotFound, "export not found")
+		return jobs.Job{}, "", false
+	}
+	return job, userID, true
+}
+
+// userExportPath is where the user export job id of userID is polled
+func userExportPath(userID string, id int64) string {
+	return fmt.Sprintf("/users/%s/exports/%d", url.PathEscape(userID), id)
+}
+
+// userExport converts a user export job to its API form, downloaded under
+// the user's path rather than /jobs
+func userExport(job jobs.Job, userID string) models.TaskJob {
+	tj := taskJob(job)
+	if tj.DownloadURL != "" {
+		tj.DownloadURL = userExportPath(userID, job.ID) + "/download"
+	}
+	return tj
+}
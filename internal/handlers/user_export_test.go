@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/light-bringer/cert-tasks/internal/jobs"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+func TestTaskHandler_UserExport(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	repo.CreateWorkspace(context.Background(), &models.Workspace{ID: "home", Name: "Home"})
+	jane := repository.WithOwner(context.Background(), "auth0|jane")
+	first, _ := repo.Create(jane, &models.Task{Title: "Call the bank", Description: "About the loan", Status: models.StatusTodo})
+	repo.Update(jane, first.ID, &models.Task{Title: "Call the bank", Description: "About the mortgage", Status: models.StatusDone})
+	repo.Create(repository.WithWorkspace(jane, "home"), &models.Task{Title: "Water plants", Status: models.StatusTodo})
+	repo.Create(repository.WithOwner(context.Background(), "auth0|john"), &models.Task{Title: "Not Jane's", Status: models.StatusTodo})
+	repo.Create(context.Background(), &models.Task{Title: "Nobody's", Status: models.StatusTodo})
+	repo.CreateWebhook(jane, &models.Webhook{URL: "https://jane.example/hook", Secret: "hook-secret"})
+
+	queue := jobs.New(jobs.DefaultConfig())
+	handler := NewTaskHandler(repo,
+		WithJobs(queue, t.TempDir(), exportStore(t)),
+		WithRevisions(repo),
+		WithWebhooks(repo, nil),
+	)
+	router := chi.NewRouter()
+	router.Get("/users/{id}/export", handler.ExportUser)
+	router.Get("/users/{id}/exports/{job}", handler.GetUserExport)
+	router.Get("/users/{id}/exports/{job}/download", handler.DownloadUserExport)
+	router.Get("/jobs/{id}", handler.GetJob)
+	runQueue(t, queue)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/users/auth0%7Cjane/export", nil))
+	var job models.TaskJob
+	json.NewDecoder(rec.Body).Decode(&job)
+	location := "/users/auth0%7Cjane/exports/" + itoa(job.ID)
+	if rec.Code != http.StatusAccepted || rec.Header().Get("Location") != location {
+		t.Fatalf("start = %v with Location %q, want 202 with %q", rec.Code, rec.Header().Get("Location"), location)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for job.State != models.JobSucceeded {
+		if job.State == models.JobFailed || time.Now().After(deadline) {
+			t.Fatalf("job = %+v", job)
+		}
+		time.Sleep(10 * time.Millisecond)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", location, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s = %v", location, rec.Code)
+		}
+		job = models.TaskJob{}
+		json.NewDecoder(rec.Body).Decode(&job)
+	}
+	if job.DownloadURL != location+"/download" || job.Progress != (models.JobProgress{Done: 5, Total: 5}) {
+		t.Fatalf("job = %+v, want a download URL under %s and 5 of 5 files", job, location)
+	}
+
+	t.Run("zip", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", job.DownloadURL, nil))
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/zip" ||
+			!strings.Contains(rec.Header().Get("Content-Disposition"), "user-data.zip") {
+			t.Fatalf("download = %v, headers %v", rec.Code, rec.Header())
+		}
+		zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files := map[string][]byte{}
+		var names []string
+		for _, f := range zr.File {
+			rc, _ := f.Open()
+			files[f.Name], _ = io.ReadAll(rc)
+			rc.Close()
+			names = append(names, f.Name)
+		}
+		if got := strings.Join(names, ","); got != "manifest.json,tasks.json,tasks.csv,revisions.json,webhooks.json" {
+			t.Fatalf("files = %s", got)
+		}
+
+		var manifest userExportManifest
+		json.Unmarshal(files["manifest.json"], &manifest)
+		if manifest.FormatVersion != 1 || manifest.UserID != "auth0|jane" || len(manifest.Files) != 4 ||
+			manifest.Files[0].Records != 2 || manifest.Files[2].Records != 1 || manifest.Files[3].Records != 1 ||
+			strings.Join(manifest.NotStored, ",") != "comments,attachments" {
+			t.Errorf("manifest = %s", files["manifest.json"])
+		}
+
+		var tasks []models.Task
+		json.Unmarshal(files["tasks.json"], &tasks)
+		if len(tasks) != 2 || tasks[0].Description != "About the mortgage" || tasks[1].WorkspaceID != "home" {
+			t.Errorf("tasks.json = %s, want both of Jane's tasks in every workspace", files["tasks.json"])
+		}
+		records, err := csv.NewReader(bytes.NewReader(files["tasks.csv"])).ReadAll()
+		if err != nil || len(records) != 3 || records[0][0] != "id" || records[2][2] != "Water plants" {
+			t.Errorf("tasks.csv = %q (%v)", records, err)
+		}
+		var revisions []models.TaskRevision
+		json.Unmarshal(files["revisions.json"], &revisions)
+		if len(revisions) != 1 || revisions[0].Description != "About the loan" {
+			t.Errorf("revisions.json = %s", files["revisions.json"])
+		}
+		if !strings.Contains(string(files["webhooks.json"]), "jane.example") || strings.Contains(string(files["webhooks.json"]), "hook-secret") {
+			t.Errorf("webhooks.json = %s, want the webhook without its secret", files["webhooks.json"])
+		}
+	})
+
+	t.Run("another user's export", func(t *testing.T) {
+		for _, path := range []string{"/users/auth0%7Cjohn/exports/" + itoa(job.ID), "/users/auth0%7Cjohn/exports/" + itoa(job.ID) + "/download"} {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+			if rec.Code != http.StatusNotFound {
+				t.Errorf("GET %s = %v, want 404", path, rec.Code)
+			}
+		}
+	})
+
+	t.Run("not a task job", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", "/jobs/"+itoa(job.ID), nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("GET /jobs/%d = %v, want 404", job.ID, rec.Code)
+		}
+	})
+
+	t.Run("invalid job ID", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", "/users/auth0%7Cjane/exports/abc", nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("status = %v, want 400", rec.Code)
+		}
+	})
+}
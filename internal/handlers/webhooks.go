@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/light-bringer/cert-tasks/internal/logging"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+	"github.com/light-bringer/cert-tasks/internal/webhook"
+)
+
+// DeliveryLog reports past delivery attempts of a webhook
+type DeliveryLog interface {
+	Deliveries(webhookID int64) []models.WebhookDelivery
+}
+
+// WithWebhooks sets where webhooks are stored and where their delivery
+// attempts are read from
+func WithWebhooks(hooks repository.WebhookRepository, deliveries DeliveryLog) Option {
+	return func(h *TaskHandler) {
+		h.webhooks = hooks
+		h.deliveries = deliveries
+	}
+}
+
+// CreateWebhook handles POST /webhooks. The webhook belongs to the
+// request's workspace; the response is the only time its signing secret
+// is returned.
+//
+//api:changelog 0.2.0 added endpoint POST /webhooks: Register a callback URL for task.created, task.updated, task.completed and task.deleted events
+func (h *TaskHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	if !h.webhooksEnabled(w, r) {
+		return
+	}
+
+	var req models.CreateWebhookRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	if err := h.validator.ValidateWebhook(&req); err != nil {
+		h.respondWithValidationError(w, r, err)
+		return
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		h.respondWithRepositoryError(w, r, err, "failed to generate webhook secret")
+		return
+	}
+
+	slices.Sort(req.Events)
+	created, err := h.webhooks.CreateWebhook(r.Context(), &models.Webhook{
+		URL:    req.URL,
+		Events: slices.Compact(req.Events),
+		Secret: secret,
+	})
+	if err != nil {
+		h.respondWithWorkspaceError(w, r, err, "failed to create webhook")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("webhook created",
+		slog.Int64("webhook_id", created.ID),
+		slog.String("workspace_id", created.WorkspaceID),
+	)
+	respondWithJSON(w, http.StatusCreated, models.CreatedWebhook{Webhook: *created, Secret: secret})
+}
+
+// ListWebhooks handles GET /webhooks
+//
+//api:changelog 0.2.0 added endpoint GET /webhooks: List the webhooks of the request's workspace
+func (h *TaskHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	if !h.webhooksEnabled(w, r) {
+		return
+	}
+
+	hooks, err := h.webhooks.ListWebhooks(r.Context())
+	if err != nil {
+		h.respondWithRepositoryError(w, r, err, "failed to list webhooks")
+		return
+	}
+
+	setTotalCount(w, len(hooks))
+	respondWithJSON(w, http.StatusOK, hooks)
+}
+
+// DeleteWebhook handles DELETE /webhooks/{id}
+//
+//api:changelog 0.2.0 added endpoint DELETE /webhooks/{id}: Stop delivering events to a webhook
+func (h *TaskHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.webhookID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.webhooks.DeleteWebhook(r.Context(), id); err != nil {
+		h.respondWithWebhookError(w, r, err, "failed to delete webhook")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListDeliveries handles GET /webhooks/{id}/deliveries, the most recent
+// delivery attempts first
+//
+//api:changelog 0.2.0 added endpoint GET /webhooks/{id}/deliveries: Recent delivery attempts of a webhook with status, error and next retry
+func (h *TaskHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.webhookID(w, r)
+	if !ok {
+		return
+	}
+
+	// Only the webhook's own workspace may read its deliveries
+	if _, err := h.webhooks.GetWebhook(r.Context(), id); err != nil {
+		h.respondWithWebhookError(w, r, err, "failed to retrieve webhook")
+		return
+	}
+
+	deliveries := h.deliveries.Deliveries(id)
+	setTotalCount(w, len(deliveries))
+	respondWithJSON(w, http.StatusOK, deliveries)
+}
+
+// webhookID parses the {id} URL parameter, writing an error response and
+// returning false if webhooks are disabled or the ID is malformed
+func (h *TaskHandler) webhookID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	if !h.webhooksEnabled(w, r) {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.respondWithError(w, r, http.StatusBadRequest, CodeInvalidID, "invalid webhook ID")
+		return 0, false
+	}
+	return id, true
+}
+
+// webhooksEnabled writes a 501 and returns false when no webhook store is
+// configured
+func (h *TaskHandler) webhooksEnabled(w http.ResponseWriter, r *http.Request) bool {
+	if h.webhooks == nil {
+		h.respondWithError(w, r, http.StatusNotImplemented, CodeNotImplemented, "webhooks are not enabled")
+		return false
+	}
+	return true
+}
+
+// respondWithWebhookError maps ErrWebhookNotFound to 404 and anything else
+// to 500
+func (h *TaskHandler) respondWithWebhookError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	if errors.Is(err, repository.ErrWebhookNotFound) {
+		h.respondWithError(w, r, http.StatusNotFound, CodeNotFound, "webhook not found")
+		return
+	}
+	h.respondWithRepositoryError(w, r, err, message)
+}
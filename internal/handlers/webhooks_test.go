@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+// staticDeliveries returns the same attempt for every webhook
+type staticDeliveries struct{}
+
+func (staticDeliveries) Deliveries(id int64) []models.WebhookDelivery {
+	return []models.WebhookDelivery{{WebhookID: id, Status: models.DeliverySucceeded}}
+}
+
+func TestTaskHandler_Webhooks(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	repo.CreateWorkspace(context.Background(), &models.Workspace{ID: "acme", Name: "Acme"})
+	handler := NewTaskHandler(repo, WithWorkspaces(repo), WithWebhooks(repo, staticDeliveries{}))
+
+	r := chi.NewRouter()
+	r.Use(handler.ResolveWorkspace)
+	r.Post("/webhooks", handler.CreateWebhook)
+	r.Get("/webhooks", handler.ListWebhooks)
+	r.Delete("/webhooks/{id}", handler.DeleteWebhook)
+	r.Get("/webhooks/{id}/deliveries", handler.ListDeliveries)
+	do := func(method, path, workspace, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(WorkspaceHeader, workspace)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do("POST", "/webhooks", "acme", `{"url":"https://example.com/hook","events":["task.updated","task.created","task.updated"]}`)
+	var created models.CreatedWebhook
+	json.NewDecoder(rec.Body).Decode(&created)
+	if rec.Code != http.StatusCreated || !strings.HasPrefix(created.Secret, "whsec_") || created.WorkspaceID != "acme" {
+		t.Fatalf("create = %v %+v, want 201 with a secret in acme", rec.Code, created)
+	}
+	if len(created.Events) != 2 {
+		t.Errorf("events = %v, want duplicates removed", created.Events)
+	}
+	if rec := do("POST", "/webhooks", "acme", `{"url":"not a url","events":["task.created"]}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("invalid status = %v, want %v", rec.Code, http.StatusUnprocessableEntity)
+	}
+
+	rec = do("GET", "/webhooks", "acme", "")
+	if strings.Contains(rec.Body.String(), created.Secret) {
+		t.Errorf("list leaks the signing secret: %s", rec.Body)
+	}
+	if rec := do("GET", "/webhooks", "default", ""); strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("default workspace list = %s, want []", rec.Body)
+	}
+
+	if rec := do("GET", "/webhooks/1/deliveries", "default", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("deliveries from another workspace = %v, want %v", rec.Code, http.StatusNotFound)
+	}
+	if rec := do("GET", "/webhooks/1/deliveries", "acme", ""); rec.Code != http.StatusOK {
+		t.Errorf("deliveries status = %v, want %v", rec.Code, http.StatusOK)
+	}
+
+	if rec := do("DELETE", "/webhooks/1", "acme", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("delete status = %v, want %v", rec.Code, http.StatusNoContent)
+	}
+	if rec := do("DELETE", "/webhooks/1", "acme", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("second delete status = %v, want %v", rec.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/light-bringer/cert-tasks/internal/auth"
+	"github.com/light-bringer/cert-tasks/internal/logging"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+// WorkspaceHeader selects the workspace a request acts in, for credentials
+// not bound to one
+//
+//api:changelog 0.2.0 added header X-Workspace-ID: Workspace a task request acts in; defaults to "default"
+const WorkspaceHeader = "X-Workspace-ID"
+
+// WithWorkspaces sets the workspace store used by ResolveWorkspace and the
+// /workspaces endpoints
+func WithWorkspaces(workspaces repository.WorkspaceRepository) Option {
+	return func(h *TaskHandler) {
+		h.workspaces = workspaces
+	}
+}
+
+// ResolveWorkspace scopes task requests to one workspace. A credential
+// bound to a workspace always acts in it, and naming another is
+// forbidden; otherwise X-Workspace-ID, then the deprecated X-Tenant-ID,
+// selects it, falling back to the default workspace. It must run after
+// authentication.
+//
+//api:changelog 0.2.0 added error workspace_not_found: Task requests naming an unknown workspace get 404
+func (h *TaskHandler) ResolveWorkspace(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested := r.Header.Get(WorkspaceHeader)
+		if requested == "" {
+			requested = r.Header.Get(TenantHeader)
+		}
+
+		workspace, ok := h.resolveWorkspace(w, r, requested)
+		if !ok {
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(repository.WithWorkspace(r.Context(), workspace)))
+	})
+}
+
+// resolveWorkspace returns the workspace a request acts in when it asks
+// for requested, which may be empty, writing a 403 or 404 response and
+// returning false if it may not
+func (h *TaskHandler) resolveWorkspace(w http.ResponseWriter, r *http.Request, requested string) (string, bool) {
+	workspace := requested
+	if bound, ok := auth.WorkspaceFromContext(r.Context()); ok {
+		if requested != "" && requested != bound {
+			h.respondWithError(w, r, http.StatusForbidden, CodeForbidden, "credential is not valid for workspace "+requested)
+			return "", false
+		}
+		workspace = bound
+	}
+	if workspace == "" {
+		workspace = models.DefaultWorkspace
+	}
+
+	if h.workspaces != nil {
+		if _, err := h.workspaces.GetWorkspace(r.Context(), workspace); err != nil {
+			if errors.Is(err, repository.ErrWorkspaceNotFound) {
+				h.respondWithError(w, r, http.StatusNotFound, CodeWorkspaceNotFound, "workspace not found")
+				return "", false
+			}
+			h.respondWithRepositoryError(w, r, err, "failed to resolve workspace")
+			return "", false
+		}
+	}
+	return workspace, true
+}
+
+// CreateWorkspace handles POST /workspaces
+//
+//api:changelog 0.2.0 added endpoint POST /workspaces: Create a workspace; requires the admin key
+func (h *TaskHandler) CreateWorkspace(w http.ResponseWriter, r *http.Request) {
+	if !h.workspacesEnabled(w, r) {
+		return
+	}
+
+	var req models.CreateWorkspaceRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	if err := h.validator.ValidateCreateWorkspace(&req); err != nil {
+		h.respondWithValidationError(w, r, err)
+		return
+	}
+
+	created, err := h.workspaces.CreateWorkspace(r.Context(), &models.Workspace{ID: req.ID, Name: req.Name, Timezone: req.Timezone, EstimateUnit: req.EstimateUnit})
+	if err != nil {
+		if errors.Is(err, repository.ErrWorkspaceExists) {
+			h.respondWithError(w, r, http.StatusConflict, CodeConflict, "workspace already exists")
+			return
+		}
+		h.respondWithRepositoryError(w, r, err, "failed to create workspace")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("workspace created", slog.String("workspace_id", created.ID))
+	respondWithJSON(w, http.StatusCreated, created)
+}
+
+// ListWorkspaces handles GET /workspaces
+//
+//api:changelog 0.2.0 added endpoint GET /workspaces: List workspaces; requires the admin key
+func (h *TaskHandler) ListWorkspaces(w http.ResponseWriter, r *http.Request) {
+	if !h.workspacesEnabled(w, r) {
+		return
+	}
+
+	workspaces, err := h.workspaces.ListWorkspaces(r.Context())
+	if err != nil {
+		h.respondWithRepositoryError(w, r, err, "failed to list workspaces")
+		return
+	}
+
+	setTotalCount(w, len(workspaces))
+	respondWithJSON(w, http.StatusOK, workspaces)
+}
+
+// GetWorkspace handles GET /workspaces/{id}, with a summary of the
+// workspace's tasks and their estimates
+//
+//api:changelog 0.2.0 added endpoint GET /workspaces/{id}: Get a workspace; requires the admin key
+func (h *TaskHandler) GetWorkspace(w http.ResponseWriter, r *http.Request) {
+	if !h.workspacesEnabled(w, r) {
+		return
+	}
+
+	workspace, err := h.workspaces.GetWorkspace(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondWithWorkspaceError(w, r, err, "failed to retrieve workspace")
+		return
+	}
+
+	summary, err := h.summarizeWorkspace(repository.WithWorkspace(r.Context(), workspace.ID))
+	if err != nil {
+		h.respondWithRepositoryError(w, r, err, "failed to summarize workspace")
+		return
+	}
+	workspace.Summary = summary
+
+	respondWithJSON(w, http.StatusOK, workspace)
+}
+
+// UpdateWorkspace handles PUT /workspaces/{id}
+//
+//api:changelog 0.2.0 added endpoint PUT /workspaces/{id}: Rename a workspace; requires the admin key
+func (h *TaskHandler) UpdateWorkspace(w http.ResponseWriter, r *http.Request) {
+	if !h.workspacesEnabled(w, r) {
+		return
+	}
+
+	var req models.UpdateWorkspaceRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	if err := h.validator.ValidateUpdateWorkspace(&req); err != nil {
+		h.respondWithValidationError(w, r, err)
+		return
+	}
+
+	updated, err := h.workspaces.UpdateWorkspace(r.Context(), chi.URLParam(r, "id"), &models.Workspace{Name: req.Name, Timezone: req.Timezone, EstimateUnit: req.EstimateUnit})
+	if err != nil {
+		h.respondWithWorkspaceError(w, r, err, "failed to update workspace")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, updated)
+}
+
+// DeleteWorkspace handles DELETE /workspaces/{id}. Only empty workspaces
+// can be deleted, and never the default one.
+//
+//api:changelog 0.2.0 added endpoint DELETE /workspaces/{id}: Delete an empty workspace; requires the admin key
+func (h *TaskHandler) DeleteWorkspace(w http.ResponseWriter, r *http.Request) {
+	if !h.workspacesEnabled(w, r) {
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	if err := h.workspaces.DeleteWorkspace(r.Context(), id); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrWorkspaceNotEmpty):
+			h.respondWithError(w, r, http.StatusConflict, CodeConflict, "workspace still has tasks; delete them first")
+		case errors.Is(err, repository.ErrDefaultWorkspace):
+			h.respondWithError(w, r, http.StatusConflict, CodeConflict, "the default workspace cannot be deleted")
+		default:
+			h.respondWithWorkspaceError(w, r, err, "failed to delete workspace")
+		}
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("workspace deleted", slog.String("workspace_id", id))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// summarizeWorkspace counts the tasks of the workspace ctx is scoped to
+// and adds up their estimates
+func (h *TaskHandler) summarizeWorkspace(ctx context.Context) (*models.WorkspaceSummary, error) {
+	var summary models.WorkspaceSummary
+	var err error
+	all, open := repository.TaskFilter{}, repository.TaskFilter{Status: models.StatusTodo}
+	if summary.Tasks, err = h.repo.Count(ctx, all); err != nil {
+		return nil, err
+	}
+	if summary.Estimate, err = h.repo.EstimateTotal(ctx, all); err != nil {
+		return nil, err
+	}
+	if summary.Open, err = h.repo.Count(ctx, open); err != nil {
+		return nil, err
+	}
+	if summary.OpenEstimate, err = h.repo.EstimateTotal(ctx, open); err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// workspacesEnabled writes a 501 and returns false when no workspace store
+// is configured
+func (h *TaskHandler) workspacesEnabled(w http.ResponseWriter, r *http.Request) bool {
+	if h.workspaces == nil {
+		h.respondWithError(w, r, http.StatusNotImplemented, CodeNotImplemented, "workspaces are not enabled")
+		return false
+	}
+	return true
+}
+
+// respondWithWorkspaceError maps ErrWorkspaceNotFound to 404 and anything
+// else to 500
+func (h *TaskHandler) respondWithWorkspaceError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	if errors.Is(err, repository.ErrWorkspaceNotFound) {
+		h.respondWithError(w, r, http.StatusNotFound, CodeWorkspaceNotFound, "workspace not found")
+		return
+	}
+	h.respondWithRepositoryError(w, r, err, message)
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+func TestTaskHandler_Workspaces(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	handler := NewTaskHandler(repo, WithWorkspaces(repo))
+
+	r := chi.NewRouter()
+	r.Post("/workspaces", handler.CreateWorkspace)
+	r.Get("/workspaces", handler.ListWorkspaces)
+	r.Get("/workspaces/{id}", handler.GetWorkspace)
+	r.Put("/workspaces/{id}", handler.UpdateWorkspace)
+	r.Delete("/workspaces/{id}", handler.DeleteWorkspace)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	if rec := do("POST", "/workspaces", `{"id":"acme","name":"Acme"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("create status = %v, want %v: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	if rec := do("POST", "/workspaces", `{"id":"acme","name":"Acme"}`); rec.Code != http.StatusConflict {
+		t.Errorf("duplicate status = %v, want %v", rec.Code, http.StatusConflict)
+	}
+	if rec := do("POST", "/workspaces", `{"id":"Not Valid","name":""}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("invalid status = %v, want %v", rec.Code, http.StatusUnprocessableEntity)
+	}
+
+	rec := do("PUT", "/workspaces/acme", `{"name":"Acme Corp","estimate_unit":"minutes"}`)
+	var ws models.Workspace
+	json.NewDecoder(rec.Body).Decode(&ws)
+	if rec.Code != http.StatusOK || ws.Name != "Acme Corp" || ws.Unit() != models.EstimateUnitMinutes {
+		t.Errorf("update = %v %+v, want 200 Acme Corp in minutes", rec.Code, ws)
+	}
+	if rec := do("PUT", "/workspaces/acme", `{"name":"Acme Corp","estimate_unit":"hours"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("unknown estimate unit status = %v, want %v", rec.Code, http.StatusUnprocessableEntity)
+	}
+
+	rec = do("GET", "/workspaces", "")
+	var list []models.Workspace
+	json.NewDecoder(rec.Body).Decode(&list)
+	if len(list) != 2 || list[0].ID != "acme" || list[1].ID != models.DefaultWorkspace {
+		t.Errorf("list = %+v, want acme and default", list)
+	}
+
+	acme := repository.WithWorkspace(context.Background(), "acme")
+	repo.Create(acme, &models.Task{Title: "Busy", Estimate: 30})
+	repo.Create(acme, &models.Task{Title: "Done", Status: models.StatusDone, Estimate: 15})
+	rec = do("GET", "/workspaces/acme", "")
+	ws = models.Workspace{}
+	json.NewDecoder(rec.Body).Decode(&ws)
+	want := models.WorkspaceSummary{Tasks: 2, Open: 1, Estimate: 45, OpenEstimate: 30}
+	if rec.Code != http.StatusOK || ws.Summary == nil || *ws.Summary != want {
+		t.Errorf("get = %v %+v, want summary %+v", rec.Code, ws.Summary, want)
+	}
+
+	if rec := do("DELETE", "/workspaces/acme", ""); rec.Code != http.StatusConflict {
+		t.Errorf("delete non-empty status = %v, want %v", rec.Code, http.StatusConflict)
+	}
+	if rec := do("DELETE", "/workspaces/default", ""); rec.Code != http.StatusConflict {
+		t.Errorf("delete default status = %v, want %v", rec.Code, http.StatusConflict)
+	}
+	if rec := do("GET", "/workspaces/missing", ""); rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), CodeWorkspaceNotFound) {
+		t.Errorf("get missing = %v %s, want 404 %s", rec.Code, rec.Body, CodeWorkspaceNotFound)
+	}
+}
+
+func TestTaskHandler_ResolveWorkspace(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	repo.CreateWorkspace(context.Background(), &models.Workspace{ID: "acme", Name: "Acme"})
+	handler := NewTaskHandler(repo, WithWorkspaces(repo))
+
+	var got string
+	h := handler.ResolveWorkspace(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = repository.WorkspaceFromContext(r.Context())
+	}))
+
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		wantStatus int
+		want       string
+	}{
+		{"default", nil, http.StatusOK, models.DefaultWorkspace},
+		{"header", map[string]string{WorkspaceHeader: "acme"}, http.StatusOK, "acme"},
+		{"tenant alias", map[string]string{TenantHeader: "acme"}, http.StatusOK, "acme"},
+		{"header wins", map[string]string{WorkspaceHeader: "default", TenantHeader: "acme"}, http.StatusOK, models.DefaultWorkspace},
+		{"unknown", map[string]string{WorkspaceHeader: "globex"}, http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = ""
+			req := httptest.NewRequest("GET", "/tasks", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus || got != tt.want {
+				t.Errorf("status %v, workspace %q; want %v, %q", rec.Code, got, tt.wantStatus, tt.want)
+			}
+		})
+	}
+}
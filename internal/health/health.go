@@ -0,0 +1,200 @@
+// Package health tracks the availability of the server's dependencies so
+// features backed by a failing non-critical subsystem can degrade instead
+// of failing whole requests.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Overall statuses reported by the registry
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded"
+	StatusDown     = "down"
+)
+
+// Names of the dependencies registered by the server
+const (
+	// Storage is the task repository; it is critical
+	Storage = "storage"
+
+	// Search is the repository's full-text search; when it is down only
+	// ?q= queries fail
+	Search = "search"
+)
+
+// checkTimeout bounds each dependency check
+const checkTimeout = 5 * time.Second
+
+// Check probes a dependency, returning an error if it is unavailable
+type Check func(ctx context.Context) error
+
+// dependency is a registered subsystem and its last known state
+type dependency struct {
+	critical  bool
+	check     Check
+	err       error
+	checkedAt time.Time
+}
+
+// Registry holds the dependencies the server relies on
+type Registry struct {
+	mu   sync.RWMutex
+	deps map[string]*dependency
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{deps: make(map[string]*dependency)}
+}
+
+// Register adds a dependency. Critical dependencies make the whole service
+// report down when they fail; non-critical ones only degrade it. check may
+// be nil for dependencies that are only reported on passively.
+func (reg *Registry) Register(name string, critical bool, check Check) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.deps[name] = &dependency{critical: critical, check: check}
+}
+
+// Available reports whether name is usable. Unknown dependencies are
+// assumed to be available.
+func (reg *Registry) Available(name string) bool {
+	if reg == nil {
+		return true
+	}
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	dep, ok := reg.deps[name]
+	return !ok || dep.err == nil
+}
+
+// MarkDown records that a caller saw name fail. The dependency stays down
+// until its next successful check or MarkUp.
+func (reg *Registry) MarkDown(name string, err error) {
+	reg.set(name, err)
+}
+
+// MarkUp records that name is working again
+func (reg *Registry) MarkUp(name string) {
+	reg.set(name, nil)
+}
+
+func (reg *Registry) set(name string, err error) {
+	if reg == nil {
+		return
+	}
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	dep, ok := reg.deps[name]
+	if !ok {
+		return
+	}
+	if (dep.err == nil) != (err == nil) {
+		if err != nil {
+			slog.Warn("dependency down", slog.String("dependency", name), slog.Any("error", err))
+		} else {
+			slog.Info("dependency recovered", slog.String("dependency", name))
+		}
+	}
+	dep.err = err
+	dep.checkedAt = time.Now()
+}
+
+// CheckAll runs every registered check once
+func (reg *Registry) CheckAll(ctx context.Context) {
+	reg.mu.RLock()
+	checks := make(map[string]Check, len(reg.deps))
+	for name, dep := range reg.deps {
+		if dep.check != nil {
+			checks[name] = dep.check
+		}
+	}
+	reg.mu.RUnlock()
+
+	for name, check := range checks {
+		checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+		reg.set(name, check(checkCtx))
+		cancel()
+	}
+}
+
+// Run checks every dependency immediately and then every interval until
+// ctx is cancelled
+func (reg *Registry) Run(ctx context.Context, interval time.Duration) {
+	reg.CheckAll(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reg.CheckAll(ctx)
+		}
+	}
+}
+
+// DependencyStatus describes one dependency in a Report
+type DependencyStatus struct {
+	Name      string    `json:"name"`
+	Critical  bool      `json:"critical"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at,omitzero"`
+}
+
+// Report is the overall health of the service
+type Report struct {
+	Status       string             `json:"status"`
+	Dependencies []DependencyStatus `json:"dependencies"`
+}
+
+// Report summarizes the state of every dependency
+func (reg *Registry) Report() Report {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	report := Report{Status: StatusOK, Dependencies: []DependencyStatus{}}
+	for name, dep := range reg.deps {
+		ds := DependencyStatus{Name: name, Critical: dep.critical, Status: StatusOK, CheckedAt: dep.checkedAt}
+		if dep.err != nil {
+			ds.Status = StatusDown
+			ds.Error = dep.err.Error()
+			switch {
+			case dep.critical:
+				report.Status = StatusDown
+			case report.Status == StatusOK:
+				report.Status = StatusDegraded
+			}
+		}
+		report.Dependencies = append(report.Dependencies, ds)
+	}
+	sort.Slice(report.Dependencies, func(i, j int) bool {
+		return report.Dependencies[i].Name < report.Dependencies[j].Name
+	})
+	return report
+}
+
+// ServeHTTP serves the report, with 503 when a critical dependency is down
+// so load balancers stop routing to the instance
+func (reg *Registry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	report := reg.Report()
+	status := http.StatusOK
+	if report.Status == StatusDown {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,92 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	searchErr := errors.New("index unreachable")
+	searchUp := true
+
+	reg := NewRegistry()
+	reg.Register(Storage, true, func(context.Context) error { return nil })
+	reg.Register(Search, false, func(context.Context) error {
+		if searchUp {
+			return nil
+		}
+		return searchErr
+	})
+
+	reg.CheckAll(context.Background())
+	if got := reg.Report().Status; got != StatusOK {
+		t.Fatalf("status = %q, want %q", got, StatusOK)
+	}
+
+	// A non-critical failure degrades the service
+	searchUp = false
+	reg.CheckAll(context.Background())
+	if reg.Available(Search) {
+		t.Error("search should be unavailable")
+	}
+	report := reg.Report()
+	if report.Status != StatusDegraded {
+		t.Errorf("status = %q, want %q", report.Status, StatusDegraded)
+	}
+	if dep := report.Dependencies[0]; dep.Name != Search || dep.Status != StatusDown || dep.Error != searchErr.Error() {
+		t.Errorf("search status = %+v", dep)
+	}
+
+	// The next successful check brings it back
+	searchUp = true
+	reg.CheckAll(context.Background())
+	if !reg.Available(Search) {
+		t.Error("search should have recovered")
+	}
+
+	// A critical failure reported by a caller takes the service down
+	reg.MarkDown(Storage, errors.New("disk full"))
+	if got := reg.Report().Status; got != StatusDown {
+		t.Errorf("status = %q, want %q", got, StatusDown)
+	}
+	reg.MarkUp(Storage)
+	if got := reg.Report().Status; got != StatusOK {
+		t.Errorf("status = %q, want %q", got, StatusOK)
+	}
+}
+
+func TestRegistry_UnknownAndNil(t *testing.T) {
+	if !NewRegistry().Available("cache") {
+		t.Error("unknown dependencies should be treated as available")
+	}
+
+	var reg *Registry
+	if !reg.Available(Search) {
+		t.Error("a nil registry should report everything available")
+	}
+	reg.MarkDown(Search, errors.New("ignored")) // must not panic
+}
+
+func TestRegistry_ServeHTTP(t *testing.T) {
+	reg := NewRegistry()
+	reg.Register(Storage, true, nil)
+	reg.MarkDown(Storage, errors.New("connection refused"))
+
+	rec := httptest.NewRecorder()
+	reg.ServeHTTP(rec, httptest.NewRequest("GET", "/health", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %v, want %v", rec.Code, http.StatusServiceUnavailable)
+	}
+	var report Report
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Status != StatusDown || len(report.Dependencies) != 1 {
+		t.Errorf("report = %+v", report)
+	}
+}
@@ -0,0 +1,172 @@
+// Package i18n translates error and validation messages into the language
+// a client asks for with Accept-Language. Messages are looked up by key:
+// "error.<code>" for an error response's message and "rule.<rule>" for a
+// field violation, so the machine-readable codes themselves never change.
+// English is the source language: its messages come from the code, are
+// more specific than a translation keyed by code can be, and are never
+// replaced.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Source is the language messages are written in
+const Source = "en"
+
+//go:embed locales/*.json
+var locales embed.FS
+
+// tagPattern matches the language tags bundles are named after, such as
+// "de" or "pt-br"
+var tagPattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+
+// Catalog holds a bundle of translated messages per language
+type Catalog struct {
+	bundles map[string]map[string]string
+}
+
+// Default returns a catalog of the built-in bundles
+func Default() *Catalog {
+	c := &Catalog{bundles: make(map[string]map[string]string)}
+	if err := c.load(locales, "locales"); err != nil {
+		panic(err) // the embedded bundles are checked by tests
+	}
+	return c
+}
+
+// Load returns the built-in bundles plus those in dir, one <tag>.json file
+// per language holding an object of keys and messages. A file for a
+// built-in language replaces its messages key by key.
+func Load(dir string) (*Catalog, error) {
+	c := Default()
+	if err := c.load(os.DirFS(dir), "."); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// load reads the bundles in dir of fsys
+func (c *Catalog) load(fsys fs.FS, dir string) error {
+	paths, err := fs.Glob(fsys, path.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, file := range paths {
+		tag := strings.ToLower(strings.TrimSuffix(path.Base(file), ".json"))
+		if !tagPattern.MatchString(tag) {
+			return fmt.Errorf("%s: the file name must be a language tag, e.g. de.json", file)
+		}
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		if c.bundles[tag] == nil {
+			c.bundles[tag] = make(map[string]string, len(messages))
+		}
+		for key, msg := range messages {
+			if msg == "" {
+				return fmt.Errorf("%s: %q has an empty message", file, key)
+			}
+			c.bundles[tag][key] = msg
+		}
+	}
+	return nil
+}
+
+// Languages returns the languages with a bundle, sorted
+func (c *Catalog) Languages() []string {
+	tags := make([]string, 0, len(c.bundles))
+	for tag := range c.bundles {
+		tags = append(tags, tag)
+	}
+	slices.Sort(tags)
+	return tags
+}
+
+// Negotiate picks the language to answer an Accept-Language header in:
+// the most preferred language with a bundle, matching "de-AT" to "de" if
+// there is no "de-at" bundle, or Source when the client prefers English,
+// accepts anything, or asks for nothing the catalog has.
+func (c *Catalog) Negotiate(acceptLanguage string) string {
+	type choice struct {
+		tag string
+		q   float64
+	}
+	var choices []choice
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > 0 {
+			choices = append(choices, choice{tag, q})
+		}
+	}
+	slices.SortStableFunc(choices, func(a, b choice) int {
+		switch {
+		case a.q > b.q:
+			return -1
+		case a.q < b.q:
+			return 1
+		}
+		return 0
+	})
+
+	for _, ch := range choices {
+		if ch.tag == "*" {
+			return Source
+		}
+		primary, _, _ := strings.Cut(ch.tag, "-")
+		if primary == Source {
+			return Source
+		}
+		if c.bundles[ch.tag] != nil {
+			return ch.tag
+		}
+		if c.bundles[primary] != nil {
+			return primary
+		}
+	}
+	return Source
+}
+
+// Translate returns the message for key in lang, with each {name} in it
+// replaced by params[name], or false if lang is Source or its bundle has
+// no such message
+func (c *Catalog) Translate(lang, key string, params map[string]string) (string, bool) {
+	msg, ok := c.bundles[lang][key]
+	if !ok || lang == Source {
+		return "", false
+	}
+	if len(params) == 0 {
+		return msg, true
+	}
+	pairs := make([]string, 0, 2*len(params))
+	for name, value := range params {
+		pairs = append(pairs, "{"+name+"}", value)
+	}
+	return strings.NewReplacer(pairs...).Replace(msg), true
+}
@@ -0,0 +1,97 @@
+package i18n
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestDefault_BundlesAgree(t *testing.T) {
+	c := Default()
+	if got := c.Languages(); !slices.Equal(got, []string{"de", "es", "fr"}) {
+		t.Fatalf("Languages() = %v", got)
+	}
+
+	want := slices.Sorted(maps.Keys(c.bundles["de"]))
+	for _, lang := range c.Languages() {
+		if got := slices.Sorted(maps.Keys(c.bundles[lang])); !slices.Equal(got, want) {
+			t.Errorf("%s has keys %v, want the same keys as de: %v", lang, got, want)
+		}
+	}
+}
+
+func TestCatalog_Negotiate(t *testing.T) {
+	c := Default()
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", Source},
+		{"de", "de"},
+		{"de-AT", "de"},
+		{"FR-ca, de;q=0.9", "fr"},
+		{"en-GB, de;q=0.8", Source},
+		{"ja, es;q=0.5", "es"},
+		{"de;q=0.3, fr;q=0.7", "fr"},
+		{"de;q=0, fr;q=0.1", "fr"},
+		{"*", Source},
+		{"ja, zh", Source},
+		{"de;q=abc", Source},
+	}
+	for _, tt := range tests {
+		if got := c.Negotiate(tt.header); got != tt.want {
+			t.Errorf("Negotiate(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestCatalog_Translate(t *testing.T) {
+	c := Default()
+
+	got, ok := c.Translate("fr", "rule.max_length", map[string]string{"field": "title", "max": "200"})
+	if !ok || got != "title ne doit pas dépasser 200 caractères." {
+		t.Errorf("Translate(fr, rule.max_length) = %q, %v", got, ok)
+	}
+	if _, ok := c.Translate("fr", "rule.unknown", nil); ok {
+		t.Error("Translate of an unknown key succeeded")
+	}
+	if _, ok := c.Translate(Source, "error.not_found", nil); ok {
+		t.Error("Translate into the source language succeeded")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "de.json"), []byte(`{"error.not_found": "Gibt es nicht."}`), 0o644)
+	os.WriteFile(filepath.Join(dir, "pt-br.json"), []byte(`{"error.not_found": "Recurso não encontrado."}`), 0o644)
+
+	c, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got, _ := c.Translate("de", "error.not_found", nil); got != "Gibt es nicht." {
+		t.Errorf("overridden message = %q", got)
+	}
+	if got, _ := c.Translate("de", "error.conflict", nil); got == "" {
+		t.Error("built-in messages of an overridden bundle were dropped")
+	}
+	if got := c.Negotiate("pt-BR"); got != "pt-br" {
+		t.Errorf("Negotiate(pt-BR) = %q, want the added bundle", got)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	for name, body := range map[string]string{
+		"german.json": `{}`,
+		"de.json":     `{"error.not_found": ""}`,
+		"fr.json":     `["not an object"]`,
+	} {
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644)
+		if _, err := Load(dir); err == nil {
+			t.Errorf("Load with %s = %s succeeded, want an error", name, body)
+		}
+	}
+}
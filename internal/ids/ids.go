@@ -0,0 +1,122 @@
+// Package ids generates task identifiers. ULIDs and UUIDv7s are opaque:
+// both start with a millisecond timestamp, so they sort by creation time,
+// and carry enough randomness that they cannot be guessed and that
+// instances can create them without coordinating. Snowflake IDs are
+// numeric and stay unique across replicas by embedding a node number.
+package ids
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Format selects the identifier given to new tasks
+type Format string
+
+const (
+	// Sequential gives tasks no opaque identifier; they are addressed by
+	// their numeric ID
+	Sequential Format = "sequential"
+
+	// ULID identifiers are 26 Crockford base32 characters
+	ULID Format = "ulid"
+
+	// UUIDv7 identifiers are RFC 9562 version 7 UUIDs
+	UUIDv7 Format = "uuidv7"
+)
+
+// ParseFormat reads a format name; empty means Sequential
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case "", Sequential:
+		return Sequential, nil
+	case ULID, UUIDv7:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown ID format %q", s)
+}
+
+// Opaque reports whether tasks get an opaque identifier
+func (f Format) Opaque() bool {
+	return f == ULID || f == UUIDv7
+}
+
+// New returns a new identifier created at t, or "" for Sequential
+func (f Format) New(t time.Time) string {
+	switch f {
+	case ULID:
+		return NewULID(t)
+	case UUIDv7:
+		return NewUUIDv7(t)
+	}
+	return ""
+}
+
+// Valid reports whether s has the shape of an identifier in this format
+func (f Format) Valid(s string) bool {
+	switch f {
+	case ULID:
+		if len(s) != 26 || s[0] > '7' {
+			return false
+		}
+		for i := range len(s) {
+			if strings.IndexByte(crockford, s[i]) < 0 {
+				return false
+			}
+		}
+		return true
+	case UUIDv7:
+		if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+			return false
+		}
+		_, err := hex.DecodeString(strings.ReplaceAll(s, "-", ""))
+		return err == nil
+	}
+	return false
+}
+
+// crockford is the ULID alphabet, which leaves out I, L, O and U
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewULID returns a ULID: a 48-bit millisecond timestamp followed by 80
+// random bits, in Crockford base32
+func NewULID(t time.Time) string {
+	var b [16]byte
+	stamp(&b, t)
+	rand.Read(b[6:])
+
+	// 128 bits make 26 characters of 5 bits, the first holding only 3
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// NewUUIDv7 returns a version 7 UUID: a 48-bit millisecond timestamp, the
+// version and variant bits and 74 random bits
+func NewUUIDv7(t time.Time) string {
+	var b [16]byte
+	stamp(&b, t)
+	rand.Read(b[6:])
+	b[6] = b[6]&0x0f | 0x70
+	b[8] = b[8]&0x3f | 0x80
+
+	h := hex.EncodeToString(b[:])
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// stamp writes t's Unix milliseconds into the first 6 bytes of b
+func stamp(b *[16]byte, t time.Time) {
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(t.UnixMilli()))
+	copy(b[:6], ms[2:])
+}
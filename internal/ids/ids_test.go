@@ -0,0 +1,76 @@
+package ids
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseFormat(t *testing.T) {
+	for in, want := range map[string]Format{"": Sequential, "sequential": Sequential, "ULID": ULID, "uuidv7": UUIDv7} {
+		if got, err := ParseFormat(in); err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseFormat("uuidv4"); err == nil {
+		t.Error("ParseFormat(uuidv4) succeeded")
+	}
+}
+
+func TestFormat_New(t *testing.T) {
+	base := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	for _, f := range []Format{ULID, UUIDv7} {
+		t.Run(string(f), func(t *testing.T) {
+			seen := make(map[string]bool)
+			var made []string
+			for i := range 100 {
+				id := f.New(base.Add(time.Duration(i) * time.Millisecond))
+				if !f.Valid(id) {
+					t.Fatalf("New() = %q, not valid", id)
+				}
+				if seen[id] {
+					t.Fatalf("New() repeated %q", id)
+				}
+				seen[id] = true
+				made = append(made, id)
+			}
+			if !slices.IsSorted(made) {
+				t.Errorf("identifiers do not sort by creation time: %v", made)
+			}
+		})
+	}
+
+	if id := NewUUIDv7(base); id[14] != '7' || !slices.Contains([]byte("89ab"), id[19]) {
+		t.Errorf("NewUUIDv7() = %q, want version 7 and RFC variant", id)
+	}
+	// 2026-01-02T03:04:05Z is 0x019B7CA98C88 ms since the epoch
+	if id := NewULID(base); id[:10] != "01KDYAK348" {
+		t.Errorf("NewULID() = %q, want timestamp 01KDYAK348", id)
+	}
+	if Sequential.New(base) != "" || Sequential.Opaque() {
+		t.Error("Sequential makes identifiers")
+	}
+}
+
+func TestFormat_Valid(t *testing.T) {
+	tests := []struct {
+		f    Format
+		s    string
+		want bool
+	}{
+		{ULID, "01ARZ3NDEKTSV4RRFFQ69G5FAV", true},
+		{ULID, "01ARZ3NDEKTSV4RRFFQ69G5FA", false},
+		{ULID, "01ARZ3NDEKTSV4RRFFQ69G5FAU", false},
+		{ULID, "81ARZ3NDEKTSV4RRFFQ69G5FAV", false},
+		{UUIDv7, "01890a5d-ac96-774b-bcce-b302099a8057", true},
+		{UUIDv7, "01890a5dac96774bbcceb302099a8057", false},
+		{UUIDv7, "01890a5d-ac96-774b-bcce-b302099a805g", false},
+		{Sequential, "42", false},
+	}
+	for _, tt := range tests {
+		if got := tt.f.Valid(tt.s); got != tt.want {
+			t.Errorf("%s.Valid(%q) = %v, want %v", tt.f, tt.s, got, tt.want)
+		}
+	}
+}
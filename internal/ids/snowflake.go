@@ -0,0 +1,85 @@
+package ids
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Snowflake layout: 41 bits of milliseconds since Epoch, then the node and
+// a per-millisecond sequence. The sign bit stays clear, so IDs are
+// positive int64s that sort by creation time.
+const (
+	nodeBits     = 10
+	sequenceBits = 12
+
+	// MaxNode is the highest node number a Snowflake accepts
+	MaxNode = 1<<nodeBits - 1
+
+	maxSequence = 1<<sequenceBits - 1
+)
+
+// Epoch is the zero time of Snowflake IDs, which last about 69 years from
+// it
+var Epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Snowflake mints int64 IDs that embed its node number, so replicas with
+// different nodes never mint the same ID without coordinating
+type Snowflake struct {
+	node int64
+	now  func() time.Time
+
+	mu     sync.Mutex
+	lastMs int64
+	seq    int64
+}
+
+// NewSnowflake returns a generator for node, which must be unique among
+// the replicas sharing a store and at most MaxNode
+func NewSnowflake(node int) (*Snowflake, error) {
+	if node < 0 || node > MaxNode {
+		return nil, fmt.Errorf("node %d is outside 0-%d", node, MaxNode)
+	}
+	return &Snowflake{node: int64(node), now: time.Now}, nil
+}
+
+// Next returns a new ID, greater than every ID this generator returned or
+// observed before. When the clock goes back, or more than 4096 IDs are
+// minted in a millisecond, it borrows from the next millisecond rather
+// than waiting.
+func (s *Snowflake) Next() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ms := s.now().Sub(Epoch).Milliseconds()
+	switch {
+	case ms > s.lastMs:
+		s.lastMs, s.seq = ms, 0
+	case s.seq < maxSequence:
+		s.seq++
+	default:
+		s.lastMs, s.seq = s.lastMs+1, 0
+	}
+	return s.lastMs<<(nodeBits+sequenceBits) | s.node<<sequenceBits | s.seq
+}
+
+// Observe makes later IDs greater than id, which another node may have
+// minted, so that IDs keep sorting by creation after loading stored tasks
+func (s *Snowflake) Observe(id int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ms := id >> (nodeBits + sequenceBits); ms >= s.lastMs {
+		s.lastMs, s.seq = ms, maxSequence
+	}
+}
+
+// SnowflakeTime returns when a Snowflake ID was minted
+func SnowflakeTime(id int64) time.Time {
+	return Epoch.Add(time.Duration(id>>(nodeBits+sequenceBits)) * time.Millisecond)
+}
+
+// SnowflakeNode returns the node that minted a Snowflake ID
+func SnowflakeNode(id int64) int {
+	return int(id >> sequenceBits & MaxNode)
+}
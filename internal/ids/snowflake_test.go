@@ -0,0 +1,65 @@
+package ids
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSnowflake_Next(t *testing.T) {
+	clock := Epoch.Add(time.Hour)
+	a, _ := NewSnowflake(1)
+	b, _ := NewSnowflake(2)
+	a.now = func() time.Time { return clock }
+	b.now = a.now
+
+	seen := make(map[int64]bool)
+	var last int64
+	// More than a millisecond's worth of sequence, then the clock going back
+	for i := range 5000 {
+		if i == 4500 {
+			clock = clock.Add(-time.Second)
+		}
+		for _, s := range []*Snowflake{a, b} {
+			id := s.Next()
+			if seen[id] {
+				t.Fatalf("ID %d minted twice", id)
+			}
+			seen[id] = true
+		}
+		if id := a.Next(); id <= last {
+			t.Fatalf("Next() = %d after %d", id, last)
+		} else {
+			last = id
+		}
+	}
+
+	id := b.Next()
+	if SnowflakeNode(id) != 2 {
+		t.Errorf("SnowflakeNode(%d) = %d, want 2", id, SnowflakeNode(id))
+	}
+	if got := SnowflakeTime(id); got.Before(Epoch.Add(time.Hour)) || got.After(Epoch.Add(time.Hour+time.Second)) {
+		t.Errorf("SnowflakeTime(%d) = %v", id, got)
+	}
+}
+
+func TestSnowflake_Observe(t *testing.T) {
+	a, _ := NewSnowflake(1)
+	b, _ := NewSnowflake(MaxNode)
+	ahead := b.Next()
+
+	a.now = func() time.Time { return SnowflakeTime(ahead) }
+	a.Observe(ahead)
+	if id := a.Next(); id <= ahead {
+		t.Errorf("Next() = %d, not after observed %d", id, ahead)
+	}
+
+	// Sequential IDs from before the switch are left behind
+	prev := a.Next()
+	a.Observe(42)
+	if id := a.Next(); id <= prev {
+		t.Errorf("Next() = %d after observing 42, want more than %d", id, prev)
+	}
+	if _, err := NewSnowflake(MaxNode + 1); err == nil {
+		t.Error("NewSnowflake accepted a node beyond MaxNode")
+	}
+}
@@ -0,0 +1,493 @@
+// Package jobs runs one-off background work, such as imports, on a pool of
+// workers. Each job is a record with a kind, selecting the Handler that
+// runs it, and a JSON payload. Failed runs are retried with exponential
+// backoff until the job's attempts run out. A Queue opened on a file keeps
+// its records there, so pending jobs survive a restart and jobs that were
+// running when the process stopped are run again.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrUnknownKind is returned when enqueuing a kind no handler is
+// registered for
+var ErrUnknownKind = errors.New("unknown job kind")
+
+// permanentError marks a failure that retrying cannot fix
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so the job fails at once instead of being retried,
+// as for a malformed upload
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+// metrics counts job outcomes for /debug/vars on the admin listener
+var metrics = expvar.NewMap("jobs")
+
+// State is where a job is in its lifecycle
+type State string
+
+// Job states
+const (
+	StatePending   State = "pending"
+	StateRunning   State = "running"
+	StateSucceeded State = "succeeded"
+	StateFailed    State = "failed"
+)
+
+// Job is a unit of background work and the record of how it went
+type Job struct {
+	ID      int64           `json:"id"`
+	Kind    string          `json:"kind"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+	State   State           `json:"state"`
+
+	// Attempts counts the runs so far, including the current one
+	Attempts    int `json:"attempts"`
+	MaxAttempts int `json:"max_attempts"`
+
+	// Progress is what the handler last reported with Report
+	Progress Progress `json:"progress"`
+
+	// Result is what the handler stored with SetResult
+	Result json.RawMessage `json:"result,omitempty"`
+
+	// RunAt is when a pending job is next due
+	RunAt      time.Time `json:"run_at"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+	LastError  string    `json:"last_error,omitempty"`
+}
+
+// Progress counts the items a job has processed. Total is zero while
+// unknown.
+type Progress struct {
+	Done  int `json:"done"`
+	Total int `json:"total,omitempty"`
+}
+
+// Handler runs one attempt of a job. It should return promptly once ctx is
+// cancelled; a job interrupted by shutdown is run again, without counting
+// the attempt, the next time the queue runs.
+type Handler func(ctx context.Context, job Job) error
+
+// Config tunes the queue
+type Config struct {
+	// Workers is the number of jobs run concurrently
+	Workers int
+
+	// MaxAttempts is how often a job is tried before it fails for good
+	MaxAttempts int
+
+	// InitialBackoff is the wait before the first retry; each further
+	// retry waits twice as long, up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// Retain is the number of finished jobs kept; older ones are dropped
+	Retain int
+}
+
+// DefaultConfig runs 4 jobs at a time and tries each 5 times over about
+// 15 seconds
+func DefaultConfig() Config {
+	return Config{
+		Workers:        4,
+		MaxAttempts:    5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+		Retain:         1000,
+	}
+}
+
+// Status is the state of the queue, as served by the jobs admin API
+type Status struct {
+	Workers int           `json:"workers"`
+	Counts  map[State]int `json:"counts"`
+	Jobs    []Job         `json:"jobs"`
+}
+
+// Queue stores jobs and runs them
+type Queue struct {
+	cfg  Config
+	path string
+	now  func() time.Time
+
+	// wake is signalled when a job may have become due
+	wake chan struct{}
+
+	mu       sync.Mutex
+	handlers map[string]Handler
+	jobs     []*Job // oldest first
+	lastID   int64
+}
+
+// New creates a Queue keeping its records in memory only
+func New(cfg Config) *Queue {
+	return &Queue{
+		cfg:      cfg,
+		now:      time.Now,
+		wake:     make(chan struct{}, 1),
+		handlers: make(map[string]Handler),
+	}
+}
+
+// Open creates a Queue persisting its records to the file at path, loading
+// any it already holds. Jobs recorded as running were cut short by a stop
+// and are pending again.
+func Open(path string, cfg Config) (*Queue, error) {
+	q := New(cfg)
+	q.path = path
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("opening job queue: %w", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &q.jobs); err != nil {
+			return nil, fmt.Errorf("reading job queue: %w", err)
+		}
+	}
+	for _, job := range q.jobs {
+		if job.State == StateRunning {
+			job.State = StatePending
+			job.Attempts--
+		}
+		q.lastID = max(q.lastID, job.ID)
+	}
+	return q, nil
+}
+
+// Register sets the handler for jobs of kind. It must be called before
+// jobs of that kind are enqueued.
+func (q *Queue) Register(kind string, h Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[kind] = h
+}
+
+// Enqueue records a job of kind with payload marshalled to JSON, due
+// immediately
+func (q *Queue) Enqueue(kind string, payload any) (Job, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return Job{}, fmt.Errorf("encoding job payload: %w", err)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.handlers[kind]; !ok {
+		return Job{}, fmt.Errorf("%w %q", ErrUnknownKind, kind)
+	}
+
+	now := q.now()
+	q.lastID++
+	job := &Job{
+		ID:          q.lastID,
+		Kind:        kind,
+		Payload:     body,
+		State:       StatePending,
+		MaxAttempts: q.cfg.MaxAttempts,
+		RunAt:       now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	q.jobs = append(q.jobs, job)
+	if err := q.save(); err != nil {
+		q.jobs = q.jobs[:len(q.jobs)-1]
+		return Job{}, err
+	}
+	metrics.Add("enqueued", 1)
+	q.signal()
+	return *job, nil
+}
+
+// Get returns the job with id
+func (q *Queue) Get(id int64) (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if job := q.find(id); job != nil {
+		return *job, true
+	}
+	return Job{}, false
+}
+
+// Report records the progress of the running job with id. It is kept in
+// memory and saved with the job's next state change.
+func (q *Queue) Report(id int64, done, total int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if job := q.find(id); job != nil {
+		job.Progress = Progress{Done: done, Total: total}
+		job.UpdatedAt = q.now()
+	}
+}
+
+// SetResult stores v, marshalled to JSON, as the result of the job with id
+func (q *Queue) SetResult(id int64, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encoding job result: %w", err)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if job := q.find(id); job != nil {
+		job.Result = body
+	}
+	return nil
+}
+
+// find returns the job with id, or nil. q.mu must be held.
+func (q *Queue) find(id int64) *Job {
+	for _, job := range q.jobs {
+		if job.ID == id {
+			return job
+		}
+	}
+	return nil
+}
+
+// Status counts the jobs in each state and lists those in state, or all of
+// them if state is empty, newest first
+func (q *Queue) Status(state State) Status {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	status := Status{
+		Workers: q.cfg.Workers,
+		Counts:  map[State]int{StatePending: 0, StateRunning: 0, StateSucceeded: 0, StateFailed: 0},
+		Jobs:    []Job{},
+	}
+	for _, job := range slices.Backward(q.jobs) {
+		status.Counts[job.State]++
+		if state == "" || job.State == state {
+			status.Jobs = append(status.Jobs, *job)
+		}
+	}
+	return status
+}
+
+// Run starts the workers and blocks until ctx is done and every running
+// job has returned
+func (q *Queue) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for range q.cfg.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+// work runs due jobs one at a time, sleeping until the next is due or a
+// new one is enqueued
+func (q *Queue) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, handler, next := q.claim()
+		if job != nil {
+			q.run(ctx, job, handler)
+			continue
+		}
+
+		var due <-chan time.Time
+		var timer *time.Timer
+		if !next.IsZero() {
+			timer = time.NewTimer(next.Sub(q.now()))
+			due = timer.C
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		case <-due:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// claim marks the oldest due job as running and returns it with its
+// handler. With none due, it returns when the next pending job is due, or
+// the zero time if there are none.
+func (q *Queue) claim() (*Job, Handler, time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now()
+	var next time.Time
+	for _, job := range q.jobs {
+		if job.State != StatePending {
+			continue
+		}
+		if job.RunAt.After(now) {
+			if next.IsZero() || job.RunAt.Before(next) {
+				next = job.RunAt
+			}
+			continue
+		}
+
+		job.State = StateRunning
+		job.Attempts++
+		job.UpdatedAt = now
+		q.persist()
+		// Another worker may be able to take the next due job
+		q.signal()
+		return job, q.handlers[job.Kind], time.Time{}
+	}
+	return nil, nil, next
+}
+
+// run makes one attempt at job and records the outcome, scheduling a
+// retry after a failure while attempts remain
+func (q *Queue) run(ctx context.Context, job *Job, handler Handler) {
+	q.mu.Lock()
+	snapshot := *job
+	q.mu.Unlock()
+
+	var err error
+	if handler == nil {
+		err = fmt.Errorf("%w %q", ErrUnknownKind, job.Kind)
+	} else {
+		err = call(ctx, handler, snapshot)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := q.now()
+	job.UpdatedAt = now
+	switch {
+	case err != nil && ctx.Err() != nil:
+		// Cut short by shutdown; the attempt does not count
+		job.State = StatePending
+		job.Attempts--
+	case err == nil:
+		job.State = StateSucceeded
+		job.FinishedAt = now
+		job.LastError = ""
+		metrics.Add("succeeded", 1)
+	case job.Attempts >= job.MaxAttempts || errors.Is(err, ErrUnknownKind) || errors.As(err, new(permanentError)):
+		job.State = StateFailed
+		job.FinishedAt = now
+		job.LastError = err.Error()
+		metrics.Add("failed", 1)
+		slog.Error("background job failed", slog.Int64("job_id", job.ID), slog.String("kind", job.Kind),
+			slog.Int("attempts", job.Attempts), slog.Any("error", err))
+	default:
+		job.State = StatePending
+		job.RunAt = now.Add(q.backoff(job.Attempts))
+		job.LastError = err.Error()
+		metrics.Add("retries", 1)
+		slog.Warn("background job will be retried", slog.Int64("job_id", job.ID), slog.String("kind", job.Kind),
+			slog.Int("attempt", job.Attempts), slog.Time("run_at", job.RunAt), slog.Any("error", err))
+	}
+	q.prune()
+	q.persist()
+}
+
+// call runs handler, turning a panic into an error so one bad job cannot
+// stop a worker
+func call(ctx context.Context, handler Handler, job Job) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("panic: %v", v)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// backoff is the wait after the attempt-th failed attempt
+func (q *Queue) backoff(attempt int) time.Duration {
+	d := q.cfg.InitialBackoff
+	for i := 1; i < attempt && d < q.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, q.cfg.MaxBackoff)
+}
+
+// prune drops the oldest finished jobs beyond cfg.Retain
+func (q *Queue) prune() {
+	finished := 0
+	for _, job := range q.jobs {
+		if job.State == StateSucceeded || job.State == StateFailed {
+			finished++
+		}
+	}
+	excess := finished - q.cfg.Retain
+	if excess <= 0 {
+		return
+	}
+	q.jobs = slices.DeleteFunc(q.jobs, func(job *Job) bool {
+		if excess > 0 && (job.State == StateSucceeded || job.State == StateFailed) {
+			excess--
+			return true
+		}
+		return false
+	})
+}
+
+// signal wakes one idle worker without blocking
+func (q *Queue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// persist saves the records, logging a failure; the in-memory state stays
+// authoritative until the next save succeeds
+func (q *Queue) persist() {
+	if err := q.save(); err != nil {
+		slog.Error("saving job queue failed", slog.Any("error", err))
+	}
+}
+
+// save atomically rewrites the queue file, if there is one
+func (q *Queue) save() error {
+	if q.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(q.jobs)
+	if err != nil {
+		return fmt.Errorf("saving job queue: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(q.path), filepath.Base(q.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("saving job queue: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("saving job queue: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("saving job queue: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), q.path); err != nil {
+		return fmt.Errorf("saving job queue: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// step claims and runs the next due job, reporting whether there was one
+func step(t *testing.T, q *Queue) bool {
+	t.Helper()
+	job, handler, _ := q.claim()
+	if job == nil {
+		return false
+	}
+	q.run(context.Background(), job, handler)
+	return true
+}
+
+func TestQueue_Retries(t *testing.T) {
+	now := time.Now()
+	cfg := DefaultConfig()
+	cfg.MaxAttempts = 3
+	q := New(cfg)
+	q.now = func() time.Time { return now }
+
+	calls := 0
+	q.Register("flaky", func(ctx context.Context, job Job) error {
+		calls++
+		if calls < 3 {
+			return errors.New("upstream unavailable")
+		}
+		return nil
+	})
+	job, err := q.Enqueue("flaky", map[string]int{"n": 1})
+	if err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+
+	if !step(t, q) {
+		t.Fatal("no job due after enqueue")
+	}
+	got, _ := q.Get(job.ID)
+	if got.State != StatePending || got.Attempts != 1 || !got.RunAt.Equal(now.Add(time.Second)) || got.LastError == "" {
+		t.Fatalf("after first failure = %+v, want pending and due in 1s", got)
+	}
+	if step(t, q) {
+		t.Fatal("job ran again before its backoff")
+	}
+
+	now = now.Add(time.Second)
+	step(t, q)
+	if got, _ := q.Get(job.ID); !got.RunAt.Equal(now.Add(2 * time.Second)) {
+		t.Fatalf("second retry due at %v, want backoff doubled to 2s", got.RunAt)
+	}
+
+	now = now.Add(2 * time.Second)
+	step(t, q)
+	got, _ = q.Get(job.ID)
+	if got.State != StateSucceeded || got.Attempts != 3 || got.LastError != "" || got.FinishedAt.IsZero() {
+		t.Errorf("after third attempt = %+v, want succeeded", got)
+	}
+}
+
+func TestQueue_Fails(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxAttempts = 2
+	cfg.InitialBackoff = 0
+	q := New(cfg)
+
+	q.Register("broken", func(ctx context.Context, job Job) error {
+		panic("nil map")
+	})
+	job, _ := q.Enqueue("broken", nil)
+	for step(t, q) {
+	}
+
+	got, _ := q.Get(job.ID)
+	if got.State != StateFailed || got.Attempts != 2 || got.LastError != "panic: nil map" {
+		t.Errorf("job = %+v, want failed after 2 attempts", got)
+	}
+
+	q.Register("malformed", func(ctx context.Context, job Job) error {
+		q.Report(job.ID, 3, 10)
+		q.SetResult(job.ID, map[string]int{"line": 3})
+		return Permanent(errors.New("invalid CSV on line 3"))
+	})
+	job, _ = q.Enqueue("malformed", nil)
+	step(t, q)
+	got, _ = q.Get(job.ID)
+	if got.State != StateFailed || got.Attempts != 1 || got.Progress != (Progress{Done: 3, Total: 10}) || string(got.Result) != `{"line":3}` {
+		t.Errorf("job = %+v, want failed without a retry, with its progress and result", got)
+	}
+
+	if _, err := q.Enqueue("missing", nil); !errors.Is(err, ErrUnknownKind) {
+		t.Errorf("Enqueue(unknown kind) error = %v, want ErrUnknownKind", err)
+	}
+}
+
+func TestQueue_Status(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Retain = 2
+	q := New(cfg)
+	q.Register("noop", func(ctx context.Context, job Job) error { return nil })
+
+	for range 4 {
+		q.Enqueue("noop", nil)
+	}
+	step(t, q)
+	step(t, q)
+	step(t, q)
+
+	status := q.Status("")
+	if status.Counts[StateSucceeded] != 2 || status.Counts[StatePending] != 1 || len(status.Jobs) != 3 {
+		t.Fatalf("Status() = %+v, want the oldest finished job pruned", status)
+	}
+	if status.Jobs[0].ID != 4 {
+		t.Errorf("first job = %d, want newest first", status.Jobs[0].ID)
+	}
+	if pending := q.Status(StatePending); len(pending.Jobs) != 1 || pending.Jobs[0].ID != 4 {
+		t.Errorf("Status(pending) jobs = %+v", pending.Jobs)
+	}
+}
+
+func TestQueue_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	q, err := Open(path, DefaultConfig())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	q.Register("import", func(ctx context.Context, job Job) error { return nil })
+	q.Enqueue("import", "a")
+	q.Enqueue("import", "b")
+
+	// Leave the first job running, as if the process stopped mid-run
+	q.claim()
+
+	reopened, err := Open(path, DefaultConfig())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	status := reopened.Status(StatePending)
+	if len(status.Jobs) != 2 || status.Jobs[1].Attempts != 0 {
+		t.Fatalf("reopened pending jobs = %+v, want both, the interrupted one without its attempt", status.Jobs)
+	}
+
+	reopened.Register("import", func(ctx context.Context, job Job) error { return nil })
+	job, _ := reopened.Enqueue("import", "c")
+	if job.ID != 3 {
+		t.Errorf("new job ID = %d, want 3", job.ID)
+	}
+}
+
+func TestQueue_Run(t *testing.T) {
+	q := New(DefaultConfig())
+	done := make(chan string, 1)
+	q.Register("echo", func(ctx context.Context, job Job) error {
+		done <- string(job.Payload)
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		q.Run(ctx)
+		close(stopped)
+	}()
+
+	q.Enqueue("echo", "hello")
+	select {
+	case payload := <-done:
+		if payload != `"hello"` {
+			t.Errorf("payload = %s", payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("job did not run")
+	}
+
+	cancel()
+	<-stopped
+}
@@ -0,0 +1,170 @@
+// Package latency tracks requests against per-route latency budgets and
+// reports which routes breach their budgets most often.
+package latency
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// MaxWindow is how far back breaches are kept
+const MaxWindow = 7 * 24 * time.Hour
+
+// bucketCount is the number of hourly buckets covering MaxWindow
+const bucketCount = int(MaxWindow / time.Hour)
+
+// Stats summarizes one route over a reporting window
+type Stats struct {
+	Route      string  `json:"route"`
+	BudgetMs   float64 `json:"budget_ms"`
+	Requests   int     `json:"requests"`
+	Breaches   int     `json:"breaches"`
+	BreachRate float64 `json:"breach_rate"`
+	WorstMs    float64 `json:"worst_ms"`
+}
+
+// Report ranks routes by budget breaches, worst first
+type Report struct {
+	WindowHours int     `json:"window_hours"`
+	Routes      []Stats `json:"routes"`
+}
+
+// counts accumulates observations for one route within an hour
+type counts struct {
+	requests int
+	breaches int
+	worst    time.Duration
+}
+
+// bucket holds one hour of observations
+type bucket struct {
+	hour   int64
+	routes map[string]*counts
+}
+
+// Tracker records request durations against budgets in hourly buckets
+type Tracker struct {
+	budgets map[string]time.Duration
+	now     func() time.Time
+
+	mu      sync.Mutex
+	buckets [bucketCount]bucket
+}
+
+// NewTracker creates a Tracker for routes keyed "METHOD /pattern". Routes
+// without a budget are not tracked.
+func NewTracker(budgets map[string]time.Duration) *Tracker {
+	return &Tracker{
+		budgets: budgets,
+		now:     time.Now,
+	}
+}
+
+// Observe records one request to route that took elapsed
+func (t *Tracker) Observe(route string, elapsed time.Duration) {
+	budget, ok := t.budgets[route]
+	if !ok {
+		return
+	}
+
+	hour := t.now().Unix() / 3600
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b := &t.buckets[hour%int64(bucketCount)]
+	if b.hour != hour || b.routes == nil {
+		*b = bucket{hour: hour, routes: make(map[string]*counts)}
+	}
+	c := b.routes[route]
+	if c == nil {
+		c = &counts{}
+		b.routes[route] = c
+	}
+
+	c.requests++
+	if elapsed > budget {
+		c.breaches++
+	}
+	if elapsed > c.worst {
+		c.worst = elapsed
+	}
+}
+
+// Report summarizes the last window, rounded up to whole hours and capped
+// at MaxWindow. Routes are ordered by breaches, then breach rate.
+func (t *Tracker) Report(window time.Duration) Report {
+	hours := int((window + time.Hour - 1) / time.Hour)
+	if hours > bucketCount {
+		hours = bucketCount
+	}
+	if hours < 1 {
+		hours = 1
+	}
+	oldest := t.now().Unix()/3600 - int64(hours) + 1
+
+	totals := make(map[string]*counts)
+	t.mu.Lock()
+	for _, b := range t.buckets {
+		if b.routes == nil || b.hour < oldest {
+			continue
+		}
+		for route, c := range b.routes {
+			total := totals[route]
+			if total == nil {
+				total = &counts{}
+				totals[route] = total
+			}
+			total.requests += c.requests
+			total.breaches += c.breaches
+			if c.worst > total.worst {
+				total.worst = c.worst
+			}
+		}
+	}
+	t.mu.Unlock()
+
+	report := Report{WindowHours: hours, Routes: []Stats{}}
+	for route, c := range totals {
+		report.Routes = append(report.Routes, Stats{
+			Route:      route,
+			BudgetMs:   ms(t.budgets[route]),
+			Requests:   c.requests,
+			Breaches:   c.breaches,
+			BreachRate: float64(c.breaches) / float64(c.requests),
+			WorstMs:    ms(c.worst),
+		})
+	}
+	sort.Slice(report.Routes, func(i, j int) bool {
+		a, b := report.Routes[i], report.Routes[j]
+		if a.Breaches != b.Breaches {
+			return a.Breaches > b.Breaches
+		}
+		if a.BreachRate != b.BreachRate {
+			return a.BreachRate > b.BreachRate
+		}
+		return a.Route < b.Route
+	})
+	return report
+}
+
+// Middleware times every request and records it under its chi route
+// pattern. It must run inside the chi router.
+func (t *Tracker) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			t.Observe(r.Method+" "+rctx.RoutePattern(), time.Since(start))
+		}
+	})
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,72 @@
+package latency
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestTracker_Report(t *testing.T) {
+	now := time.Date(2025, 12, 24, 10, 30, 0, 0, time.UTC)
+	tracker := NewTracker(map[string]time.Duration{
+		"GET /tasks":      100 * time.Millisecond,
+		"GET /tasks/{id}": 50 * time.Millisecond,
+	})
+	tracker.now = func() time.Time { return now }
+
+	// Two hours ago: one slow list request
+	now = now.Add(-2 * time.Hour)
+	tracker.Observe("GET /tasks", 300*time.Millisecond)
+
+	// This hour: item lookups breach twice, list once
+	now = now.Add(2 * time.Hour)
+	tracker.Observe("GET /tasks", 20*time.Millisecond)
+	tracker.Observe("GET /tasks", 120*time.Millisecond)
+	tracker.Observe("GET /tasks/{id}", 60*time.Millisecond)
+	tracker.Observe("GET /tasks/{id}", 70*time.Millisecond)
+	tracker.Observe("DELETE /tasks/{id}", time.Second) // no budget, ignored
+
+	report := tracker.Report(time.Hour)
+	if report.WindowHours != 1 || len(report.Routes) != 2 {
+		t.Fatalf("report = %+v", report)
+	}
+	if got := report.Routes[0]; got.Route != "GET /tasks/{id}" || got.Breaches != 2 || got.BreachRate != 1 || got.WorstMs != 70 || got.BudgetMs != 50 {
+		t.Errorf("worst route = %+v", got)
+	}
+	if got := report.Routes[1]; got.Route != "GET /tasks" || got.Requests != 2 || got.Breaches != 1 {
+		t.Errorf("second route = %+v", got)
+	}
+
+	// Widening the window includes the older breach; ties rank by rate
+	report = tracker.Report(3 * time.Hour)
+	if got := report.Routes[1]; got.Route != "GET /tasks" || got.Requests != 3 || got.Breaches != 2 || got.WorstMs != 300 {
+		t.Errorf("3h list route = %+v", got)
+	}
+
+	// A bucket reused a week later starts from zero
+	now = now.Add(MaxWindow)
+	tracker.Observe("GET /tasks", time.Millisecond)
+	report = tracker.Report(MaxWindow)
+	if len(report.Routes) != 1 || report.Routes[0].Requests != 1 || report.Routes[0].Breaches != 0 {
+		t.Errorf("after a week = %+v", report.Routes)
+	}
+}
+
+func TestTracker_Middleware(t *testing.T) {
+	tracker := NewTracker(map[string]time.Duration{"GET /tasks/{id}": 0})
+
+	r := chi.NewRouter()
+	r.Use(tracker.Middleware)
+	r.Get("/tasks/{id}", func(w http.ResponseWriter, r *http.Request) {})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/tasks/1", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/tasks/2", nil))
+
+	report := tracker.Report(time.Hour)
+	if len(report.Routes) != 1 || report.Routes[0].Requests != 2 {
+		t.Errorf("report = %+v", report.Routes)
+	}
+}
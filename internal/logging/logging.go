@@ -0,0 +1,87 @@
+// Package logging provides the structured JSON logger used across the
+// server and a request-scoped logger carried in the request context.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// New returns a logger writing JSON records at or above level to w. Pass
+// a *slog.LevelVar to change the level while the server runs.
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level}))
+}
+
+// LevelState is the body of PUT /admin/loglevel and of its responses
+type LevelState struct {
+	// Level is the minimum level logged: debug, info, warn or error
+	Level string `json:"level"`
+}
+
+// StateOf returns the state of level
+func StateOf(level slog.Leveler) LevelState {
+	return LevelState{Level: strings.ToLower(level.Level().String())}
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying logger
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx, or the default logger if
+// there is none
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// AccessLog records completed requests, as an *accesslog.Logger does
+type AccessLog interface {
+	Log(r *http.Request, status, bytes int, duration time.Duration)
+}
+
+// Middleware injects a logger annotated with the request ID, method and
+// path into every request's context and logs each completed request to
+// access, or through that logger if access is nil. It must run after the
+// request ID has been assigned.
+func Middleware(logger *slog.Logger, access AccessLog) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			reqLogger := logger.With(
+				slog.String("request_id", middleware.GetReqID(r.Context())),
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+			)
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(NewContext(r.Context(), reqLogger)))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if access != nil {
+				access.Log(r, status, ww.BytesWritten(), time.Since(start))
+				return
+			}
+			reqLogger.Info("request completed",
+				slog.Int("status", status),
+				slog.Int("bytes", ww.BytesWritten()),
+				slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+				slog.String("remote_addr", r.RemoteAddr),
+			)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+func TestFromContext_DefaultsToSlogDefault(t *testing.T) {
+	if FromContext(context.Background()) != slog.Default() {
+		t.Error("expected the default logger when none is in the context")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo)
+
+	handler := middleware.RequestID(Middleware(logger, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		FromContext(r.Context()).Warn("inside handler", slog.String("field", "title"))
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("short and stout"))
+	})))
+
+	req := httptest.NewRequest(http.MethodPost, "/tasks", nil)
+	req.Header.Set(middleware.RequestIDHeader, "req-123")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	dec := json.NewDecoder(&buf)
+	var records []map[string]any
+	for dec.More() {
+		var rec map[string]any
+		if err := dec.Decode(&rec); err != nil {
+			t.Fatalf("log output is not JSON: %v", err)
+		}
+		records = append(records, rec)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d log records, want 2", len(records))
+	}
+
+	// Both the handler's record and the access log carry request fields
+	for _, rec := range records {
+		if rec["request_id"] != "req-123" || rec["method"] != "POST" || rec["path"] != "/tasks" {
+			t.Errorf("record missing request fields: %v", rec)
+		}
+	}
+	if records[0]["msg"] != "inside handler" || records[0]["field"] != "title" {
+		t.Errorf("handler record = %v", records[0])
+	}
+	if records[1]["msg"] != "request completed" || records[1]["status"] != float64(http.StatusTeapot) || records[1]["bytes"] != float64(15) {
+		t.Errorf("access record = %v", records[1])
+	}
+}
@@ -0,0 +1,102 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// RotatingFile is an append-only log file that is renamed to a backup and
+// replaced once a write would take it past its maximum size, or once it
+// has been written to for its maximum age. Backups are path.1, the
+// newest, to path.N.
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+	now        func() time.Time
+
+	mu     sync.Mutex
+	f      *os.File
+	size   int64
+	opened time.Time
+}
+
+// OpenRotating opens path for appending, creating it and its directory if
+// needed. A zero maxSize or maxAge turns that kind of rotation off; a zero
+// maxBackups keeps no backups.
+func OpenRotating(path string, maxSize int64, maxAge time.Duration, maxBackups int) (*RotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	r := &RotatingFile{path: path, maxSize: maxSize, maxAge: maxAge, maxBackups: maxBackups, now: time.Now}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size, r.opened = f, info.Size(), r.now()
+	return nil
+}
+
+// Write appends p, one record, rotating first if it would not fit or the
+// file is too old
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tooBig := r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize
+	tooOld := r.maxAge > 0 && r.size > 0 && r.now().Sub(r.opened) >= r.maxAge
+	if tooBig || tooOld {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the backups up by one, dropping the oldest, and starts a
+// new file
+func (r *RotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	if r.maxBackups == 0 {
+		os.Remove(r.path)
+	} else {
+		for i := r.maxBackups - 1; i >= 1; i-- {
+			os.Rename(r.backup(i), r.backup(i+1))
+		}
+		if err := os.Rename(r.path, r.backup(1)); err != nil {
+			return err
+		}
+	}
+	return r.open()
+}
+
+func (r *RotatingFile) backup(n int) string {
+	return fmt.Sprintf("%s.%d", r.path, n)
+}
+
+// Close closes the current file
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Close()
+}
@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRotatingFile_Size(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "access.log")
+	f, err := OpenRotating(path, 10, 0, 2)
+	if err != nil {
+		t.Fatalf("OpenRotating() error = %v", err)
+	}
+	defer f.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	// The oldest record fell off the end of the two backups
+	for name, want := range map[string]string{
+		path:        "fourth\n",
+		path + ".1": "third\n",
+		path + ".2": "second\n",
+	} {
+		got, err := os.ReadFile(name)
+		if err != nil || string(got) != want {
+			t.Errorf("%s = %q, %v; want %q", filepath.Base(name), got, err, want)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("a third backup was kept")
+	}
+}
+
+func TestRotatingFile_Age(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api.log")
+	f, err := OpenRotating(path, 0, 24*time.Hour, 1)
+	if err != nil {
+		t.Fatalf("OpenRotating() error = %v", err)
+	}
+	defer f.Close()
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	f.now = func() time.Time { return now }
+	f.opened = now
+
+	f.Write([]byte("monday\n"))
+	now = now.Add(23 * time.Hour)
+	f.Write([]byte("still monday\n"))
+	now = now.Add(time.Hour)
+	f.Write([]byte("tuesday\n"))
+
+	for name, want := range map[string]string{
+		path:        "tuesday\n",
+		path + ".1": "monday\nstill monday\n",
+	} {
+		got, err := os.ReadFile(name)
+		if err != nil || string(got) != want {
+			t.Errorf("%s = %q, %v; want %q", filepath.Base(name), got, err, want)
+		}
+	}
+}
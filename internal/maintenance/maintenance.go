@@ -0,0 +1,110 @@
+// Package maintenance implements maintenance mode: while it is on, requests
+// that would change data are turned away with 503, so that storage can be
+// compacted, backed up or migrated without writes racing the work. Reads
+// keep working.
+package maintenance
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// MaxMessageLength caps State.Message, in characters
+const MaxMessageLength = 500
+
+// State is whether maintenance mode is on, and why
+type State struct {
+	Enabled bool `json:"enabled"`
+
+	// Message tells clients what is happening, e.g. "storage migration
+	// until 14:00 UTC"
+	Message string `json:"message,omitempty"`
+
+	// Since is when maintenance mode was turned on
+	Since *time.Time `json:"since,omitempty"`
+}
+
+// Request is the body of PUT /admin/maintenance
+type Request struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message,omitempty"`
+}
+
+// Mode holds the maintenance state; the zero value is off
+type Mode struct {
+	mu    sync.RWMutex
+	state State
+
+	// changes counts calls to Set, so that Hold can tell whether the
+	// state it set is still in place
+	changes uint64
+}
+
+// Set turns maintenance mode on with message, or off, and returns the new
+// state. Turning it on again keeps the original start time.
+func (m *Mode) Set(enabled bool, message string) State {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.changes++
+	switch {
+	case !enabled:
+		m.state = State{}
+	case m.state.Enabled:
+		m.state.Message = message
+	default:
+		now := time.Now().UTC()
+		m.state = State{Enabled: true, Message: message, Since: &now}
+	}
+	return m.state
+}
+
+// Hold turns maintenance mode on with message for the duration of a task
+// and returns the function that ends it. Ending it restores the state
+// from before: it leaves maintenance mode on if it already was, or if it
+// was set again in the meantime, such as by an operator.
+func (m *Mode) Hold(message string) (release func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.state.Enabled {
+		return func() {}
+	}
+	now := time.Now().UTC()
+	m.state = State{Enabled: true, Message: message, Since: &now}
+	m.changes++
+	held := m.changes
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if m.changes == held {
+			m.state = State{}
+		}
+	}
+}
+
+// State returns the current state
+func (m *Mode) State() State {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.state
+}
+
+// Middleware passes requests that only read to next, and, while
+// maintenance mode is on, every other request to rejected
+func (m *Mode) Middleware(rejected http.HandlerFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+			default:
+				if m.State().Enabled {
+					rejected(w, r)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package maintenance
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMode_Set(t *testing.T) {
+	var m Mode
+	if m.State().Enabled {
+		t.Fatal("zero Mode is enabled")
+	}
+
+	on := m.Set(true, "backup")
+	if !on.Enabled || on.Since == nil {
+		t.Fatalf("Set(true) = %+v", on)
+	}
+	// Updating the message keeps the start time
+	again := m.Set(true, "backup, then migration")
+	if again.Message != "backup, then migration" || !again.Since.Equal(*on.Since) {
+		t.Errorf("second Set(true) = %+v, want message updated and since %v", again, on.Since)
+	}
+
+	if off := m.Set(false, "ignored"); off != (State{}) {
+		t.Errorf("Set(false) = %+v, want zero state", off)
+	}
+}
+
+func TestMode_Hold(t *testing.T) {
+	var m Mode
+	release := m.Hold("restore")
+	if s := m.State(); !s.Enabled || s.Message != "restore" {
+		t.Fatalf("state while held = %+v", s)
+	}
+	release()
+	if m.State().Enabled {
+		t.Error("still on after release")
+	}
+
+	// Maintenance mode that was already on stays on
+	m.Set(true, "migration")
+	m.Hold("restore")()
+	if s := m.State(); !s.Enabled || s.Message != "migration" {
+		t.Errorf("state after releasing a hold on enabled mode = %+v", s)
+	}
+
+	// A change made during the hold outlives it
+	m.Set(false, "")
+	release = m.Hold("restore")
+	m.Set(true, "extended by an operator")
+	release()
+	if s := m.State(); !s.Enabled || s.Message != "extended by an operator" {
+		t.Errorf("state after releasing an overridden hold = %+v", s)
+	}
+}
+
+func TestMode_Middleware(t *testing.T) {
+	var m Mode
+	rejected := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusServiceUnavailable) }
+	handler := m.Middleware(rejected)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	status := func(method string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, "/tasks", nil))
+		return rec.Code
+	}
+
+	if got := status("POST"); got != http.StatusOK {
+		t.Errorf("POST while off = %v, want 200", got)
+	}
+	m.Set(true, "")
+	for method, want := range map[string]int{
+		"GET": http.StatusOK, "HEAD": http.StatusOK, "OPTIONS": http.StatusOK,
+		"POST": http.StatusServiceUnavailable, "PUT": http.StatusServiceUnavailable, "DELETE": http.StatusServiceUnavailable,
+	} {
+		if got := status(method); got != want {
+			t.Errorf("%s while on = %v, want %v", method, got, want)
+		}
+	}
+}
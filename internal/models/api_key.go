@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// APIKeyScope limits what requests an API key may make
+type APIKeyScope string
+
+const (
+	ScopeRead      APIKeyScope = "read"
+	ScopeReadWrite APIKeyScope = "read_write"
+)
+
+// IsValid reports whether the scope is one of the supported scopes
+func (s APIKeyScope) IsValid() bool {
+	return s == ScopeRead || s == ScopeReadWrite
+}
+
+// APIKey is a credential for the task API. Only a hash of the secret is
+// stored; the secret itself is shown once, when the key is created.
+//
+//api:changelog 0.2.0 added field APIKey: API key metadata; the secret is never returned after creation
+type APIKey struct {
+	ID     int64       `json:"id"`
+	Name   string      `json:"name"`
+	Scope  APIKeyScope `json:"scope"`
+	Prefix string      `json:"prefix"`
+
+	// WorkspaceID, when set, confines the key to one workspace
+	WorkspaceID string `json:"workspace_id,omitempty"`
+
+	Hash      string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateAPIKeyRequest represents the request body for creating an API key
+type CreateAPIKeyRequest struct {
+	Name        string      `json:"name"`
+	Scope       APIKeyScope `json:"scope"`
+	WorkspaceID string      `json:"workspace_id"`
+}
+
+// CreatedAPIKey is returned once when a key is created, with its secret
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
@@ -0,0 +1,10 @@
+package models
+
+// CalendarFeed is returned by GET /calendar/token: the token protecting the
+// caller's calendar feed, and the feed path with the token filled in
+//
+//api:changelog 0.2.0 added field CalendarFeed: Calendar feed token and subscription path
+type CalendarFeed struct {
+	Token string `json:"token"`
+	Path  string `json:"path"`
+}
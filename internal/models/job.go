@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// JobState is where an asynchronous import or export is
+type JobState string
+
+// Job states
+const (
+	JobPending   JobState = "pending"
+	JobRunning   JobState = "running"
+	JobSucceeded JobState = "succeeded"
+	JobFailed    JobState = "failed"
+)
+
+// Job types
+const (
+	JobImport = "import"
+	JobExport = "export"
+)
+
+// TaskJob reports an import or export run in the background. Progress
+// counts rows or tasks processed so far; Total is zero when not known in
+// advance. A finished import has its per-row report in Result, and a
+// finished export a DownloadURL. With download links enabled it also has a
+// SignedURL, which downloads the file without credentials until
+// SignedURLExpiresAt.
+//
+//api:changelog 0.2.0 added field TaskJob: Status of an asynchronous import or export, polled at GET /jobs/{id}
+//api:changelog 0.2.0 added field TaskJob.signed_url: Link downloading a finished export without credentials, for handing to a browser
+//api:changelog 0.2.0 added field TaskJob.signed_url_expires_at: When signed_url stops working; poll the job again for a fresh one
+type TaskJob struct {
+	ID                 int64         `json:"id"`
+	Type               string        `json:"type"`
+	State              JobState      `json:"state"`
+	Progress           JobProgress   `json:"progress"`
+	Result             *ImportResult `json:"result,omitempty"`
+	DownloadURL        string        `json:"download_url,omitempty"`
+	SignedURL          string        `json:"signed_url,omitempty"`
+	SignedURLExpiresAt *time.Time    `json:"signed_url_expires_at,omitempty"`
+	Error              string        `json:"error,omitempty"`
+	CreatedAt          time.Time     `json:"created_at"`
+	UpdatedAt          time.Time     `json:"updated_at"`
+	FinishedAt         *time.Time    `json:"finished_at,omitempty"`
+}
+
+// JobProgress counts the items a job has processed
+type JobProgress struct {
+	Done  int `json:"done"`
+	Total int `json:"total,omitempty"`
+}
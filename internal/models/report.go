@@ -0,0 +1,84 @@
+package models
+
+import "time"
+
+// Report bucket sizes
+const (
+	BucketDay  = "day"
+	BucketWeek = "week"
+)
+
+// BurndownReport counts a workspace's open and done tasks at the end of
+// each bucket from From to To
+//
+//api:changelog 0.2.0 added field BurndownReport: Open and done task counts at the end of each day or week, from GET /reports/burndown
+//api:changelog 0.2.0 added field BurndownReport.estimate_unit: Unit of the report's estimate totals, the workspace's estimate_unit
+type BurndownReport struct {
+	WorkspaceID  string          `json:"workspace_id"`
+	From         time.Time       `json:"from"`
+	To           time.Time       `json:"to"`
+	Bucket       string          `json:"bucket"`
+	EstimateUnit string          `json:"estimate_unit"`
+	Points       []BurndownPoint `json:"points"`
+}
+
+// BurndownPoint is the state of the tasks at the end of one bucket, with
+// the total estimates of the open and done tasks
+//
+//api:changelog 0.2.0 added field BurndownPoint.open_estimate: Total estimate of the tasks open at the end of the bucket
+//api:changelog 0.2.0 added field BurndownPoint.done_estimate: Total estimate of the tasks done by the end of the bucket
+type BurndownPoint struct {
+	At           time.Time `json:"at"`
+	Open         int       `json:"open"`
+	Done         int       `json:"done"`
+	OpenEstimate int       `json:"open_estimate"`
+	DoneEstimate int       `json:"done_estimate"`
+}
+
+// ThroughputReport counts the tasks a workspace opened and completed in
+// each bucket from From to To
+//
+//api:changelog 0.2.0 added field ThroughputReport: Tasks created and completed in each day or week, from GET /reports/throughput
+//api:changelog 0.2.0 added field ThroughputReport.estimate_unit: Unit of the report's estimate totals, the workspace's estimate_unit
+type ThroughputReport struct {
+	WorkspaceID  string             `json:"workspace_id"`
+	From         time.Time          `json:"from"`
+	To           time.Time          `json:"to"`
+	Bucket       string             `json:"bucket"`
+	EstimateUnit string             `json:"estimate_unit"`
+	Buckets      []ThroughputBucket `json:"buckets"`
+}
+
+// ThroughputBucket counts the tasks created and completed from Start until
+// End, with their total estimates
+//
+//api:changelog 0.2.0 added field ThroughputBucket.completed_estimate: Total estimate of the tasks completed in the bucket
+type ThroughputBucket struct {
+	Start             time.Time `json:"start"`
+	End               time.Time `json:"end"`
+	Created           int       `json:"created"`
+	Completed         int       `json:"completed"`
+	CreatedEstimate   int       `json:"created_estimate"`
+	CompletedEstimate int       `json:"completed_estimate"`
+}
+
+// WorkloadReport counts a workspace's open tasks and their estimated
+// effort, in total and by owner
+//
+//api:changelog 0.2.0 added field WorkloadReport: Open tasks and estimated effort by owner, from GET /reports/workload
+//api:changelog 0.2.0 added field WorkloadReport.estimate_unit: Unit of the report's estimates, the workspace's estimate_unit
+type WorkloadReport struct {
+	WorkspaceID  string `json:"workspace_id"`
+	EstimateUnit string `json:"estimate_unit"`
+	WorkloadGroup
+	Owners []WorkloadGroup `json:"owners"`
+}
+
+// WorkloadGroup counts open tasks and adds up their estimates. Unestimated
+// counts the tasks without an estimate.
+type WorkloadGroup struct {
+	OwnerID     string `json:"owner_id,omitempty"`
+	Open        int    `json:"open"`
+	Estimate    int    `json:"estimate"`
+	Unestimated int    `json:"unestimated"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// TaskRevision is a version of a task that an update replaced. Revisions
+// are numbered per task from 1, oldest first.
+//
+//api:changelog 0.2.0 added field TaskRevision: A previous version of a task, kept when it is updated
+type TaskRevision struct {
+	TaskID      int64      `json:"task_id"`
+	Number      int        `json:"number"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Status      TaskStatus `json:"status"`
+	DueAt       *time.Time `json:"due_at,omitempty"`
+	Estimate    int        `json:"estimate,omitempty"`
+
+	// UpdatedAt is when this version was written
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// ReplacedAt is when the update that replaced it was made
+	ReplacedAt time.Time `json:"replaced_at"`
+}
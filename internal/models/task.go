@@ -1,10 +1,6 @@
 package models
 
-import (
-	"errors"
-	"strings"
-	"time"
-)
+import "time"
 
 // TaskStatus represents the status of a task
 type TaskStatus string
@@ -14,44 +10,133 @@ const (
 	StatusDone TaskStatus = "done"
 )
 
+// LinkType represents the kind of relation between two tasks
+type LinkType string
+
+const (
+	LinkRelatesTo   LinkType = "relates_to"
+	LinkDuplicateOf LinkType = "duplicate_of"
+	LinkCausedBy    LinkType = "caused_by"
+)
+
+// IsValid reports whether the link type is one of the supported relations
+func (t LinkType) IsValid() bool {
+	switch t {
+	case LinkRelatesTo, LinkDuplicateOf, LinkCausedBy:
+		return true
+	}
+	return false
+}
+
+// TaskLink represents a typed relation from one task to another
+//
+//api:changelog 0.2.0 added field TaskLink.task: The linked task, embedded with ?expand=links
+type TaskLink struct {
+	Type   LinkType `json:"type"`
+	TaskID int64    `json:"task_id"`
+
+	// Task is the linked task, embedded only in responses to ?expand=links
+	Task *Task `json:"task,omitempty"`
+}
+
 // Task represents a task entity
+//
+//api:changelog 0.2.0 added field Task.links: Typed links to other tasks, omitted when empty
+//api:changelog 0.2.0 added field Task.owner_id: Subject of the JWT that created the task, omitted for tasks created with API keys
+//api:changelog 0.2.0 added field Task.workspace_id: Workspace the task belongs to
+//api:changelog 0.2.0 added field Task.due_at: Optional due date, omitted when unset
+//api:changelog 0.2.0 added field Task.uid: Opaque ULID or UUIDv7 identifier, when STORAGE_ID_FORMAT selects one
+//api:changelog 0.2.0 added field Task.completed_at: When the task was last marked done, omitted while it is not done
+//api:changelog 0.2.0 added field Task.estimate: Optional estimated effort, omitted when unset
 type Task struct {
 	ID          int64      `json:"id"`
+	UID         string     `json:"uid,omitempty"`
+	WorkspaceID string     `json:"workspace_id"`
+	OwnerID     string     `json:"owner_id,omitempty"`
 	Title       string     `json:"title"`
 	Description string     `json:"description"`
 	Status      TaskStatus `json:"status"`
 	CreatedAt   time.Time  `json:"created_at"`
 	UpdatedAt   time.Time  `json:"updated_at"`
+	DueAt       *time.Time `json:"due_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	Estimate    int        `json:"estimate,omitempty"`
+	Links       []TaskLink `json:"links,omitempty"`
 }
 
-// CreateTaskRequest represents the request body for creating a task
+// CreateTaskRequest represents the request body for creating a task. Due
+// is a natural-language alternative to DueAt, such as "next friday 5pm",
+// that the server turns into DueAt. Estimate is the estimated effort; zero
+// means none.
+//
+//api:changelog 0.2.0 added field CreateTaskRequest.due: Natural-language due date, such as "tomorrow" or "next friday 5pm", read in the X-Timezone time zone; ambiguous dates are rejected with their candidates
+//api:changelog 0.2.0 added field CreateTaskRequest.estimate: Optional estimated effort, a non-negative whole number
 type CreateTaskRequest struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
-}
-
-// Validate validates the create task request
-func (r *CreateTaskRequest) Validate() error {
-	if strings.TrimSpace(r.Title) == "" {
-		return errors.New("title is required and cannot be empty")
-	}
-	return nil
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	DueAt       *time.Time `json:"due_at,omitempty"`
+	Due         string     `json:"due,omitempty"`
+	Estimate    int        `json:"estimate,omitempty"`
 }
 
 // UpdateTaskRequest represents the request body for updating a task
+//
+//api:changelog 0.2.0 added field UpdateTaskRequest.due: Natural-language due date, as in CreateTaskRequest.due
+//api:changelog 0.2.0 added field UpdateTaskRequest.estimate: Estimated effort, as in CreateTaskRequest.estimate; omitting it clears the estimate
 type UpdateTaskRequest struct {
 	Title       string     `json:"title"`
 	Description string     `json:"description"`
 	Status      TaskStatus `json:"status"`
+	DueAt       *time.Time `json:"due_at,omitempty"`
+	Due         string     `json:"due,omitempty"`
+	Estimate    int        `json:"estimate,omitempty"`
 }
 
-// Validate validates the update task request
-func (r *UpdateTaskRequest) Validate() error {
-	if strings.TrimSpace(r.Title) == "" {
-		return errors.New("title is required and cannot be empty")
-	}
-	if r.Status != StatusTodo && r.Status != StatusDone {
-		return errors.New("status must be either 'todo' or 'done'")
-	}
-	return nil
+// CreateLinkRequest represents the request body for linking two tasks
+type CreateLinkRequest struct {
+	Type   LinkType `json:"type"`
+	TaskID int64    `json:"task_id"`
+}
+
+// BulkDeletePreview is returned by the first step of a bulk delete: how
+// many tasks match, and the token that confirms deleting exactly them
+//
+//api:changelog 0.2.0 added field BulkDeletePreview: Preview count and confirmation token for DELETE /tasks
+type BulkDeletePreview struct {
+	Count     int       `json:"count"`
+	Token     string    `json:"confirmation_token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// BulkDeleteResult is returned once a confirmed bulk delete has run
+type BulkDeleteResult struct {
+	Deleted int `json:"deleted"`
+}
+
+// UndoResult is returned once POST /undo/{token} has put tasks back
+type UndoResult struct {
+	Restored int     `json:"restored"`
+	Tasks    []*Task `json:"tasks"`
+}
+
+// ImportResult reports the outcome of a CSV import. In a dry run nothing
+// is created and Imported counts the rows that would have been.
+//
+//api:changelog 0.2.0 added field ImportResult: Per-row report of POST /tasks/import
+type ImportResult struct {
+	DryRun   bool             `json:"dry_run"`
+	Rows     int              `json:"rows"`
+	Imported int              `json:"imported"`
+	Failed   int              `json:"failed"`
+	TaskIDs  []int64          `json:"task_ids,omitempty"`
+	Errors   []ImportRowError `json:"errors"`
+}
+
+// ImportRowError describes why one CSV row was not imported. Row is the
+// line number in the uploaded file, counting the header as line 1.
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field,omitempty"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
 }
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// TaskEventType names a change to a task that webhooks can subscribe to
+type TaskEventType string
+
+const (
+	EventTaskCreated   TaskEventType = "task.created"
+	EventTaskUpdated   TaskEventType = "task.updated"
+	EventTaskCompleted TaskEventType = "task.completed"
+	EventTaskDeleted   TaskEventType = "task.deleted"
+)
+
+// IsValid reports whether the event type is one of the supported events
+func (t TaskEventType) IsValid() bool {
+	switch t {
+	case EventTaskCreated, EventTaskUpdated, EventTaskCompleted, EventTaskDeleted:
+		return true
+	}
+	return false
+}
+
+// TaskEvent is the JSON body delivered to webhooks
+//
+//api:changelog 0.2.0 added field TaskEvent: Webhook payload carrying the event type and the task as it was after the change
+type TaskEvent struct {
+	ID          string        `json:"id"`
+	Type        TaskEventType `json:"type"`
+	CreatedAt   time.Time     `json:"created_at"`
+	WorkspaceID string        `json:"workspace_id"`
+	Task        *Task         `json:"task"`
+}
+
+// NewTaskEvent returns an event of type typ for task at time at, with a
+// random ID that receivers can use to drop duplicates
+func NewTaskEvent(typ TaskEventType, task *Task, at time.Time) *TaskEvent {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return &TaskEvent{
+		ID:          "evt_" + hex.EncodeToString(b),
+		Type:        typ,
+		CreatedAt:   at,
+		WorkspaceID: task.WorkspaceID,
+		Task:        task,
+	}
+}
+
+// Webhook is a callback URL subscribed to task events in one workspace.
+// Payloads are signed with Secret, which is shown once, when the webhook
+// is created.
+//
+//api:changelog 0.2.0 added field Webhook: Callback URL subscribed to task events; the signing secret is never returned after creation
+type Webhook struct {
+	ID          int64           `json:"id"`
+	URL         string          `json:"url"`
+	Events      []TaskEventType `json:"events"`
+	WorkspaceID string          `json:"workspace_id"`
+
+	// OwnerID, set for webhooks created with a JWT, limits deliveries to
+	// the owner's tasks
+	OwnerID string `json:"owner_id,omitempty"`
+
+	Secret    string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Subscribed reports whether the webhook wants events of type t
+func (w *Webhook) Subscribed(t TaskEventType) bool {
+	for _, e := range w.Events {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateWebhookRequest represents the request body for registering a webhook
+type CreateWebhookRequest struct {
+	URL    string          `json:"url"`
+	Events []TaskEventType `json:"events"`
+}
+
+// CreatedWebhook is returned once when a webhook is created, with its
+// signing secret
+type CreatedWebhook struct {
+	Webhook
+	Secret string `json:"secret"`
+}
+
+// DeliveryStatus is the outcome of one delivery attempt
+type DeliveryStatus string
+
+const (
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	DeliveryRetrying  DeliveryStatus = "retrying"
+	DeliveryFailed    DeliveryStatus = "failed"
+)
+
+// WebhookDelivery records one attempt to deliver an event to a webhook
+//
+//api:changelog 0.2.0 added field WebhookDelivery: One delivery attempt with its response status or error and the next retry time
+type WebhookDelivery struct {
+	WebhookID      int64          `json:"webhook_id"`
+	EventID        string         `json:"event_id"`
+	Event          TaskEventType  `json:"event"`
+	Attempt        int            `json:"attempt"`
+	Status         DeliveryStatus `json:"status"`
+	ResponseStatus int            `json:"response_status,omitempty"`
+	Error          string         `json:"error,omitempty"`
+	DurationMs     float64        `json:"duration_ms"`
+	AttemptedAt    time.Time      `json:"attempted_at"`
+	NextAttemptAt  *time.Time     `json:"next_attempt_at,omitempty"`
+}
@@ -0,0 +1,76 @@
+package models
+
+import "time"
+
+// DefaultWorkspace holds tasks of requests that name no workspace, and
+// every task created before workspaces existed
+const DefaultWorkspace = "default"
+
+// Units of task estimates, set per workspace
+const (
+	EstimateUnitPoints  = "points"
+	EstimateUnitMinutes = "minutes"
+)
+
+// Workspace is an isolated set of tasks
+//
+//api:changelog 0.2.0 added field Workspace: Isolated set of tasks, managed by admins under /workspaces
+//api:changelog 0.2.0 added field Workspace.timezone: IANA time zone due dates in the workspace are read and shown in when a request names none
+//api:changelog 0.2.0 added field Workspace.estimate_unit: Unit of the workspace's task estimates, points (the default) or minutes
+//api:changelog 0.2.0 added field Workspace.summary: Task counts and estimate totals, on GET /workspaces/{id}
+type Workspace struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Timezone     string    `json:"timezone,omitempty"`
+	EstimateUnit string    `json:"estimate_unit,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+
+	// Summary is only set by GET /workspaces/{id}
+	Summary *WorkspaceSummary `json:"summary,omitempty"`
+}
+
+// WorkspaceSummary counts a workspace's tasks and adds up their estimates
+type WorkspaceSummary struct {
+	Tasks        int `json:"tasks"`
+	Open         int `json:"open"`
+	Estimate     int `json:"estimate"`
+	OpenEstimate int `json:"open_estimate"`
+}
+
+// Unit returns the unit of the workspace's estimates, points if it has
+// none
+func (w *Workspace) Unit() string {
+	if w.EstimateUnit == "" {
+		return EstimateUnitPoints
+	}
+	return w.EstimateUnit
+}
+
+// Location returns the workspace's time zone, or UTC if it has none
+func (w *Workspace) Location() *time.Location {
+	if w.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(w.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// CreateWorkspaceRequest represents the request body for creating a workspace
+type CreateWorkspaceRequest struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Timezone     string `json:"timezone,omitempty"`
+	EstimateUnit string `json:"estimate_unit,omitempty"`
+}
+
+// UpdateWorkspaceRequest represents the request body for updating a
+// workspace; a missing timezone or estimate_unit clears it
+type UpdateWorkspaceRequest struct {
+	Name         string `json:"name"`
+	Timezone     string `json:"timezone,omitempty"`
+	EstimateUnit string `json:"estimate_unit,omitempty"`
+}
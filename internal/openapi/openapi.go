@@ -0,0 +1,378 @@
+// Package openapi builds the OpenAPI 3.1 description of the API. Paths,
+// summaries and query parameters come from the changelog annotations that
+// already document every endpoint; request and response bodies are
+// declared per operation and described by reflecting on the Go types, or by
+// the hand-written JSON Schemas where those exist.
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/light-bringer/cert-tasks/internal/changelog"
+)
+
+// Version is the OpenAPI version of the generated document
+const Version = "3.1.0"
+
+// Operation describes the bodies and security of one endpoint
+type Operation struct {
+	Method string
+	Path   string
+	Tag    string
+
+	// Request is a value of the request body type, or nil without a body
+	Request any
+
+	// RequestContentType defaults to application/json
+	RequestContentType string
+
+	Responses []Response
+
+	// PathParams gives the type of path parameters that are not strings
+	PathParams map[string]any
+
+	// Public operations need no credential; Admin ones need the admin key
+	Public bool
+	Admin  bool
+}
+
+// Response is a documented response of an operation
+type Response struct {
+	Status      int
+	Description string
+
+	// Body is a value of the body type, or nil without a body
+	Body any
+
+	// ContentType defaults to application/json
+	ContentType string
+}
+
+// OneOf is a body that is one of several types, such as a preview or a
+// result depending on the query
+type OneOf []any
+
+// Document is an OpenAPI document
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []map[string][]string `json:"security"`
+}
+
+// Info is the document's metadata
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// PathItem maps lowercase HTTP methods to operations
+type PathItem map[string]*OperationObject
+
+// OperationObject is an operation in the document
+type OperationObject struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary"`
+	Tags        []string              `json:"tags,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Reply      `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter is a path or query parameter
+type Parameter struct {
+	Name        string `json:"name"`
+	In          string `json:"in"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	Deprecated  bool   `json:"deprecated,omitempty"`
+	Schema      Schema `json:"schema"`
+}
+
+// RequestBody is an operation's request body
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Reply is a response in the document
+type Reply struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds the schema of a body
+type MediaType struct {
+	Schema Schema `json:"schema"`
+}
+
+// Components holds the shared schemas and security schemes
+type Components struct {
+	Schemas         map[string]Schema         `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+// SecurityScheme describes a credential
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme,omitempty"`
+	In          string `json:"in,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Schema is a JSON Schema
+type Schema map[string]any
+
+// Security requirements
+var (
+	apiKeySecurity = []map[string][]string{{"bearerAuth": {}}, {"apiKeyHeader": {}}}
+	adminSecurity  = []map[string][]string{{"adminKey": {}}}
+	publicSecurity = []map[string][]string{{}}
+)
+
+// Spec is the input the document is built from
+type Spec struct {
+	// Version is the API version in the document's info
+	Version string
+
+	Changelog  *changelog.Changelog
+	Operations []Operation
+
+	// Schemas holds hand-written JSON Schemas, used instead of reflection
+	// for the types they are titled after
+	Schemas fs.FS
+
+	// ErrorBody is a value of the error body type every operation may
+	// return
+	ErrorBody any
+}
+
+// Build assembles the document from the changelog and the declared
+// operations. Every endpoint in the changelog must be declared, and every
+// declared operation must be in the changelog.
+func Build(spec Spec) (*Document, error) {
+	endpoints, params := changes(spec.Changelog)
+
+	gen, err := newGenerator(spec.Schemas)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    Info{Title: "cert-tasks API", Version: spec.Version},
+		Paths:   make(map[string]PathItem),
+		Components: Components{
+			SecuritySchemes: map[string]SecurityScheme{
+				"bearerAuth":   {Type: "http", Scheme: "bearer", Description: "API key or JWT"},
+				"apiKeyHeader": {Type: "apiKey", In: "header", Name: "X-API-Key"},
+				"adminKey":     {Type: "http", Scheme: "bearer", Description: "The admin key"},
+			},
+		},
+		Security: apiKeySecurity,
+	}
+
+	var errs []error
+	declared := make(map[string]bool)
+	for _, op := range spec.Operations {
+		key := op.Method + " " + op.Path
+		declared[key] = true
+		ep, ok := endpoints[key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s is declared but has no changelog entry", key))
+			continue
+		}
+
+		obj := &OperationObject{
+			OperationID: operationID(op.Method, op.Path),
+			Summary:     ep.summary,
+			Deprecated:  ep.deprecated,
+			Responses:   make(map[string]Reply),
+		}
+		if op.Tag != "" {
+			obj.Tags = []string{op.Tag}
+		}
+		switch {
+		case op.Public:
+			obj.Security = publicSecurity
+		case op.Admin:
+			obj.Security = adminSecurity
+		}
+
+		for _, name := range pathParams(op.Path) {
+			var typ any = ""
+			if t, ok := op.PathParams[name]; ok {
+				typ = t
+			}
+			obj.Parameters = append(obj.Parameters, Parameter{
+				Name: name, In: "path", Required: true, Schema: gen.schema(typ),
+			})
+		}
+		for _, p := range params[key] {
+			obj.Parameters = append(obj.Parameters, Parameter{
+				Name: p.name, In: "query", Description: p.description, Deprecated: p.deprecated,
+				Schema: Schema{"type": "string"},
+			})
+		}
+
+		if op.Request != nil {
+			obj.RequestBody = &RequestBody{
+				Required: true,
+				Content:  map[string]MediaType{contentType(op.RequestContentType): {Schema: gen.schema(op.Request)}},
+			}
+		}
+		for _, resp := range op.Responses {
+			reply := Reply{Description: resp.Description}
+			if reply.Description == "" {
+				reply.Description = http.StatusText(resp.Status)
+			}
+			if resp.Body != nil {
+				reply.Content = map[string]MediaType{contentType(resp.ContentType): {Schema: gen.schema(resp.Body)}}
+			}
+			obj.Responses[fmt.Sprint(resp.Status)] = reply
+		}
+		if spec.ErrorBody != nil {
+			obj.Responses["default"] = Reply{
+				Description: "Error",
+				Content:     map[string]MediaType{"application/json": {Schema: gen.schema(spec.ErrorBody)}},
+			}
+		}
+
+		item := doc.Paths[op.Path]
+		if item == nil {
+			item = make(PathItem)
+			doc.Paths[op.Path] = item
+		}
+		item[strings.ToLower(op.Method)] = obj
+	}
+
+	for key := range endpoints {
+		if !declared[key] {
+			errs = append(errs, fmt.Errorf("%s is in the changelog but not declared", key))
+		}
+	}
+	if len(errs) > 0 {
+		slices.SortFunc(errs, func(a, b error) int { return strings.Compare(a.Error(), b.Error()) })
+		return nil, errors.Join(errs...)
+	}
+
+	doc.Components.Schemas = gen.components
+	return doc, nil
+}
+
+// JSON encodes the document
+func (d *Document) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// endpoint is an endpoint's state after every changelog release
+type endpoint struct {
+	summary    string
+	deprecated bool
+}
+
+// param is a query parameter's state after every changelog release
+type param struct {
+	name        string
+	description string
+	deprecated  bool
+}
+
+// changes replays the changelog from the oldest release and returns the
+// endpoints that were not removed, keyed by "METHOD /path", and their query
+// parameters
+func changes(cl *changelog.Changelog) (map[string]endpoint, map[string][]param) {
+	endpoints := make(map[string]endpoint)
+	params := make(map[string][]param)
+
+	for i := len(cl.Releases) - 1; i >= 0; i-- {
+		for _, c := range cl.Releases[i].Changes {
+			switch c.Scope {
+			case "endpoint":
+				ep, seen := endpoints[c.Target]
+				switch c.Kind {
+				case "added":
+					if !seen {
+						ep.summary = c.Description
+					}
+				case "deprecated":
+					ep.deprecated = true
+				case "removed":
+					delete(endpoints, c.Target)
+					continue
+				}
+				endpoints[c.Target] = ep
+
+			case "parameter":
+				key, name, ok := strings.Cut(c.Target, "?")
+				if !ok {
+					continue
+				}
+				list := params[key]
+				idx := slices.IndexFunc(list, func(p param) bool { return p.name == name })
+				switch {
+				case c.Kind == "removed" && idx >= 0:
+					params[key] = slices.Delete(list, idx, idx+1)
+				case c.Kind == "deprecated" && idx >= 0:
+					list[idx].deprecated = true
+				case c.Kind == "added" && idx < 0:
+					params[key] = append(list, param{name: name, description: c.Description})
+				}
+			}
+		}
+	}
+	for key := range params {
+		slices.SortFunc(params[key], func(a, b param) int { return strings.Compare(a.name, b.name) })
+	}
+	return endpoints, params
+}
+
+// pathParamPattern matches a path parameter such as {id}
+var pathParamPattern = regexp.MustCompile(`\{([^}]+)\}`)
+
+// pathParams returns the names of the path parameters in path, in order
+func pathParams(path string) []string {
+	var names []string
+	for _, m := range pathParamPattern.FindAllStringSubmatch(path, -1) {
+		names = append(names, m[1])
+	}
+	return names
+}
+
+// operationID derives an ID such as "getTasksId" from method and path
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == '{' || r == '}' || r == '.' || r == '-' || r == '_'
+	}) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+// contentType defaults an empty media type to JSON
+func contentType(ct string) string {
+	if ct == "" {
+		return "application/json"
+	}
+	return ct
+}
+
+// DocsPage is the Swagger UI page rendering /openapi.json. The page is
+// embedded; the Swagger UI scripts are loaded from a CDN by the browser.
+//
+//go:embed docs.html
+var DocsPage []byte
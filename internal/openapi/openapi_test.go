@@ -0,0 +1,148 @@
+package openapi
+
+import (
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/changelog"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/schemas"
+)
+
+var testChangelog = &changelog.Changelog{Releases: []changelog.Release{
+	{Version: "0.2.0", Changes: []changelog.Change{
+		{Kind: "added", Scope: "endpoint", Target: "GET /items/{id}", Description: "Get an item"},
+		{Kind: "added", Scope: "parameter", Target: "GET /items?q", Description: "Search query"},
+		{Kind: "deprecated", Scope: "endpoint", Target: "GET /items", Description: "Use search"},
+		{Kind: "removed", Scope: "endpoint", Target: "DELETE /items", Description: "Gone"},
+	}},
+	{Version: "0.1.0", Changes: []changelog.Change{
+		{Kind: "added", Scope: "endpoint", Target: "GET /items", Description: "List items"},
+		{Kind: "added", Scope: "endpoint", Target: "DELETE /items", Description: "Delete items"},
+	}},
+}}
+
+type base struct {
+	ID int64 `json:"id"`
+}
+
+type Widget struct {
+	base
+	Name    string     `json:"name"`
+	Note    string     `json:"note,omitempty"`
+	DueAt   *time.Time `json:"due_at"`
+	Tags    []string   `json:"tags"`
+	Related *Widget    `json:"related,omitempty"`
+}
+
+func TestBuild(t *testing.T) {
+	doc, err := Build(Spec{
+		Version:   "1.2.3",
+		Changelog: testChangelog,
+		Operations: []Operation{
+			{Method: "GET", Path: "/items", Responses: []Response{{Status: 200, Body: []Widget{}}}},
+			{Method: "GET", Path: "/items/{id}", Public: true, PathParams: map[string]any{"id": int64(0)},
+				Responses: []Response{{Status: 200, Body: Widget{}}}},
+		},
+		ErrorBody: struct {
+			Message string `json:"message"`
+		}{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Info.Version != "1.2.3" {
+		t.Errorf("version = %q, want 1.2.3", doc.Info.Version)
+	}
+	if _, ok := doc.Paths["/items"]["delete"]; ok {
+		t.Error("removed endpoint is documented")
+	}
+
+	list := doc.Paths["/items"]["get"]
+	if list.Summary != "List items" || !list.Deprecated {
+		t.Errorf("GET /items: summary = %q, deprecated = %v", list.Summary, list.Deprecated)
+	}
+	if len(list.Parameters) != 1 || list.Parameters[0].Name != "q" || list.Parameters[0].In != "query" {
+		t.Errorf("GET /items: parameters = %+v, want ?q", list.Parameters)
+	}
+	if _, ok := list.Responses["default"]; !ok {
+		t.Error("GET /items has no error response")
+	}
+
+	get := doc.Paths["/items/{id}"]["get"]
+	if get.OperationID != "getItemsId" {
+		t.Errorf("operationId = %q, want getItemsId", get.OperationID)
+	}
+	if len(get.Security) != 1 || len(get.Security[0]) != 0 {
+		t.Errorf("public operation security = %v, want [{}]", get.Security)
+	}
+	if p := get.Parameters[0]; p.Name != "id" || !p.Required || p.Schema["type"] != "integer" {
+		t.Errorf("path parameter = %+v", p)
+	}
+
+	s := doc.Components.Schemas["OpenapiWidget"]
+	props := s["properties"].(map[string]any)
+	if got := slices.Sorted(maps.Keys(props)); !slices.Equal(got, []string{"due_at", "id", "name", "note", "related", "tags"}) {
+		t.Errorf("properties = %v", got)
+	}
+	if got := s["required"].([]string); !slices.Equal(got, []string{"id", "name", "tags"}) {
+		t.Errorf("required = %v, want [id name tags]", got)
+	}
+	if ref := props["related"].(Schema)["$ref"]; ref != "#/components/schemas/OpenapiWidget" {
+		t.Errorf("related = %v, want a reference to OpenapiWidget", ref)
+	}
+	if due := props["due_at"].(Schema); due["format"] != "date-time" {
+		t.Errorf("due_at = %v, want a date-time", due)
+	}
+}
+
+func TestBuild_OutOfSync(t *testing.T) {
+	_, err := Build(Spec{
+		Changelog: testChangelog,
+		Operations: []Operation{
+			{Method: "GET", Path: "/items"},
+			{Method: "POST", Path: "/items"},
+		},
+	})
+	if err == nil {
+		t.Fatal("Build succeeded with undocumented and undeclared endpoints")
+	}
+	for _, want := range []string{
+		"POST /items is declared but has no changelog entry",
+		"GET /items/{id} is in the changelog but not declared",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+// TestSchemas_MatchModels fails when a hand-written schema, which replaces
+// reflection for its type, no longer lists the type's JSON members
+func TestSchemas_MatchModels(t *testing.T) {
+	gen, err := newGenerator(schemas.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []any{models.Task{}, models.CreateTaskRequest{}, models.UpdateTaskRequest{}} {
+		typ := reflect.TypeOf(v)
+		name := typ.Name()
+		t.Run(name, func(t *testing.T) {
+			file, ok := gen.files[name]
+			if !ok {
+				t.Fatalf("no schema file titled %s", name)
+			}
+			want := slices.Sorted(maps.Keys(file["properties"].(map[string]any)))
+			got := slices.Sorted(maps.Keys(gen.structSchema(typ)["properties"].(map[string]any)))
+			if !slices.Equal(got, want) {
+				t.Errorf("model members = %v, schema properties = %v", got, want)
+			}
+		})
+	}
+}
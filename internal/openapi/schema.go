@@ -0,0 +1,173 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	timeType       = reflect.TypeFor[time.Time]()
+	rawMessageType = reflect.TypeFor[json.RawMessage]()
+)
+
+// generator describes Go types as JSON Schemas, collecting named structs
+// into the document's components
+type generator struct {
+	components map[string]Schema
+
+	// files holds the hand-written schemas, keyed by title
+	files map[string]Schema
+}
+
+// newGenerator loads the hand-written JSON Schemas in schemas, which take
+// precedence over reflection for the types they are titled after
+func newGenerator(schemas fs.FS) (*generator, error) {
+	g := &generator{
+		components: make(map[string]Schema),
+		files:      make(map[string]Schema),
+	}
+	if schemas == nil {
+		return g, nil
+	}
+
+	names, err := fs.Glob(schemas, "*.json")
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		data, err := fs.ReadFile(schemas, name)
+		if err != nil {
+			return nil, err
+		}
+		var s Schema
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, fmt.Errorf("schema %s: %w", name, err)
+		}
+		title, _ := s["title"].(string)
+		if title == "" {
+			return nil, fmt.Errorf("schema %s has no title", name)
+		}
+		// The document has its own dialect and base URI
+		delete(s, "$schema")
+		delete(s, "$id")
+		g.files[title] = s
+	}
+	return g, nil
+}
+
+// schema describes the type of v
+func (g *generator) schema(v any) Schema {
+	if alts, ok := v.(OneOf); ok {
+		var schemas []Schema
+		for _, alt := range alts {
+			schemas = append(schemas, g.schema(alt))
+		}
+		return Schema{"oneOf": schemas}
+	}
+	return g.typeSchema(reflect.TypeOf(v))
+}
+
+func (g *generator) typeSchema(t reflect.Type) Schema {
+	switch t {
+	case timeType:
+		return Schema{"type": "string", "format": "date-time"}
+	case rawMessageType:
+		return Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.typeSchema(t.Elem())
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Schema{"type": "string", "contentEncoding": "base64"}
+		}
+		return Schema{"type": "array", "items": g.typeSchema(t.Elem())}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": g.typeSchema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name := componentName(t)
+		if _, ok := g.components[name]; !ok {
+			if s, ok := g.files[name]; ok {
+				g.components[name] = s
+			} else {
+				g.components[name] = Schema{} // reserves the name while recursing
+				g.components[name] = g.structSchema(t)
+			}
+		}
+		return Schema{"$ref": "#/components/schemas/" + name}
+	}
+	return Schema{}
+}
+
+// structSchema describes a struct by its JSON encoding. Fields tagged
+// omitempty and pointer fields are optional.
+func (g *generator) structSchema(t reflect.Type) Schema {
+	props := make(map[string]any)
+	var required []string
+	g.addFields(t, props, &required)
+
+	s := Schema{"type": "object", "properties": props}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+func (g *generator) addFields(t reflect.Type, props map[string]any, required *[]string) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		// Embedded structs without a name are flattened, as encoding/json
+		// does, even when their type is unexported
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			g.addFields(f.Type, props, required)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		props[name] = g.typeSchema(f.Type)
+		if !strings.Contains(opts, "omitempty") && !strings.Contains(opts, "omitzero") && f.Type.Kind() != reflect.Pointer {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// componentName names the schema of a struct type. Types from the models
+// and handlers packages, and types named after their package, keep their
+// name; others are prefixed with their package, so latency.Report and
+// health.Report do not collide.
+func componentName(t reflect.Type) string {
+	pkg := path.Base(t.PkgPath())
+	if pkg == "models" || pkg == "handlers" || strings.EqualFold(pkg, t.Name()) {
+		return t.Name()
+	}
+	return strings.ToUpper(pkg[:1]) + pkg[1:] + t.Name()
+}
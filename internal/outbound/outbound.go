@@ -0,0 +1,176 @@
+// Package outbound bounds calls the server makes to external systems.
+// Every call derives its deadline from the context of the request that
+// caused it, capped by a per-integration budget, so no outbound call can
+// outlive its originating request or hang on a slow dependency.
+package outbound
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned, wrapped, when a PublicClient is asked to
+// connect to an address that is not public
+var ErrForbiddenAddress = errors.New("address not allowed")
+
+// Budgets holds the maximum duration of one outbound call per kind of
+// integration
+type Budgets struct {
+	Webhook  time.Duration `yaml:"webhook"`
+	Notifier time.Duration `yaml:"notifier"`
+	Blob     time.Duration `yaml:"blob"`
+	JWKS     time.Duration `yaml:"jwks"`
+}
+
+// DefaultBudgets returns conservative budgets for each integration
+func DefaultBudgets() Budgets {
+	return Budgets{
+		Webhook:  5 * time.Second,
+		Notifier: 5 * time.Second,
+		Blob:     30 * time.Second,
+		JWKS:     5 * time.Second,
+	}
+}
+
+// WithBudget returns a context that ends at the earlier of parent's
+// deadline and budget from now. It is cancelled with parent.
+func WithBudget(parent context.Context, budget time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(parent, budget)
+}
+
+// Client returns an HTTP client whose every request is bounded by budget
+// and by the request's own context. Callers must build requests with
+// http.NewRequestWithContext from the originating request's context.
+func Client(budget time.Duration) *http.Client {
+	return &http.Client{Transport: &transport{base: http.DefaultTransport, budget: budget}}
+}
+
+// PublicClient returns a Client for URLs chosen by users, such as webhooks,
+// that only connects to public addresses. The address is checked after DNS
+// resolution, so a name resolving to an address in nonPublic, such as a
+// loopback, private, shared or multicast one, is refused too, unless it is
+// in one of the allowed prefixes. Redirects are not followed and proxies
+// from the environment are not used, since either would connect somewhere
+// unchecked.
+func PublicClient(budget time.Duration, allowed []netip.Prefix) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   publicOnly(allowed),
+	}
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.Proxy = nil
+	base.DialContext = dialer.DialContext
+	return &http.Client{
+		Transport: &transport{base: base, budget: budget},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// publicOnly returns a net.Dialer Control function refusing to connect to
+// addresses that are not public, unless allowed contains them
+func publicOnly(allowed []netip.Prefix) func(network, address string, c syscall.RawConn) error {
+	return func(_, address string, _ syscall.RawConn) error {
+		ap, err := netip.ParseAddrPort(address)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrForbiddenAddress, address)
+		}
+		return CheckAddress(ap.Addr(), allowed)
+	}
+}
+
+// CheckAddress returns ErrForbiddenAddress, wrapped, if a PublicClient
+// with the allowed prefixes would refuse to connect to addr
+func CheckAddress(addr netip.Addr, allowed []netip.Prefix) error {
+	// A prefix never contains an address with a zone
+	addr = addr.Unmap().WithZone("")
+	if public(addr) || slices.ContainsFunc(allowed, func(p netip.Prefix) bool { return p.Contains(addr) }) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s is not a public address", ErrForbiddenAddress, addr)
+}
+
+// nonPublic are the prefixes a PublicClient refuses: the IANA special
+// purpose ranges that are not globally reachable, and multicast. Cloud
+// metadata services live in some of them, such as 169.254.169.254 and
+// 100.100.100.200.
+var nonPublic = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "this network"
+	netip.MustParsePrefix("10.0.0.0/8"),      // private
+	netip.MustParsePrefix("100.64.0.0/10"),   // shared address space (carrier-grade NAT)
+	netip.MustParsePrefix("127.0.0.0/8"),     // loopback
+	netip.MustParsePrefix("169.254.0.0/16"),  // link-local
+	netip.MustParsePrefix("172.16.0.0/12"),   // private
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // documentation
+	netip.MustParsePrefix("192.168.0.0/16"),  // private
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // documentation
+	netip.MustParsePrefix("224.0.0.0/4"),     // multicast
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved, and broadcast
+	netip.MustParsePrefix("::/127"),          // unspecified and loopback
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // local-use NAT64
+	netip.MustParsePrefix("100::/64"),        // discard
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+	netip.MustParsePrefix("2002::/16"),       // 6to4, which embeds any IPv4 address
+	netip.MustParsePrefix("fc00::/7"),        // unique local
+	netip.MustParsePrefix("fe80::/10"),       // link-local
+	netip.MustParsePrefix("fec0::/10"),       // site-local
+	netip.MustParsePrefix("ff00::/8"),        // multicast
+}
+
+// nat64 is the well-known NAT64 prefix. Its addresses reach the IPv4
+// address in their last 32 bits, which is checked instead.
+var nat64 = netip.MustParsePrefix("64:ff9b::/96")
+
+// public reports whether addr may be reached by a PublicClient without
+// being allowed explicitly
+func public(addr netip.Addr) bool {
+	if nat64.Contains(addr) {
+		b := addr.As16()
+		return public(netip.AddrFrom4([4]byte(b[12:])))
+	}
+	return !slices.ContainsFunc(nonPublic, func(p netip.Prefix) bool { return p.Contains(addr) })
+}
+
+// transport applies the budget to each round trip
+type transport struct {
+	base   http.RoundTripper
+	budget time.Duration
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := WithBudget(req.Context(), t.budget)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// The budget covers reading the body too; release it once closed
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelBody cancels the round trip's context when the body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,213 @@
+package outbound
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowServer answers after delay, or when the client goes away
+func slowServer(t *testing.T, delay time.Duration) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+			io.WriteString(w, "ok")
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// call performs a GET through Client(budget) with ctx and reports how long
+// it took
+func call(t *testing.T, ctx context.Context, budget time.Duration, url string) (time.Duration, error) {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	resp, err := Client(budget).Do(req)
+	if err == nil {
+		_, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	return time.Since(start), err
+}
+
+func TestClient_NoCallOutlivesItsRequest(t *testing.T) {
+	srv := slowServer(t, 5*time.Second)
+
+	tests := []struct {
+		name    string
+		budget  time.Duration
+		ctx     func() (context.Context, context.CancelFunc)
+		wantErr error
+	}{
+		{
+			name:   "budget exceeded",
+			budget: 50 * time.Millisecond,
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithCancel(context.Background())
+			},
+			wantErr: context.DeadlineExceeded,
+		},
+		{
+			name:   "request deadline shorter than budget",
+			budget: time.Minute,
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 50*time.Millisecond)
+			},
+			wantErr: context.DeadlineExceeded,
+		},
+		{
+			name:   "request cancelled",
+			budget: time.Minute,
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(50*time.Millisecond, cancel)
+				return ctx, cancel
+			},
+			wantErr: context.Canceled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := tt.ctx()
+			defer cancel()
+
+			elapsed, err := call(t, ctx, tt.budget, srv.URL)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+			if elapsed > time.Second {
+				t.Errorf("call took %v, want it to end with its request", elapsed)
+			}
+		})
+	}
+}
+
+func TestClient_BudgetCoversBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		select {
+		case <-time.After(5 * time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+
+	elapsed, err := call(t, context.Background(), 50*time.Millisecond, srv.URL)
+	if err == nil || elapsed > time.Second {
+		t.Errorf("reading a stalled body took %v with error %v, want the budget to stop it", elapsed, err)
+	}
+}
+
+func TestClient_WithinBudget(t *testing.T) {
+	srv := slowServer(t, 0)
+	if _, err := call(t, context.Background(), time.Second, srv.URL); err != nil {
+		t.Errorf("call within budget failed: %v", err)
+	}
+}
+
+func TestPublicClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+	port := srv.URL[strings.LastIndex(srv.URL, ":"):]
+
+	get := func(client *http.Client, url string) (int, error) {
+		req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	// The test server listens on loopback, which names resolve to as well
+	for _, url := range []string{srv.URL, "http://localhost" + port} {
+		if _, err := get(PublicClient(time.Second, nil), url); !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("GET %s error = %v, want ErrForbiddenAddress", url, err)
+		}
+	}
+
+	allowed := PublicClient(time.Second, []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")})
+	if code, err := get(allowed, srv.URL); err != nil || code != http.StatusOK {
+		t.Errorf("GET allowed address = %d, %v, want 200", code, err)
+	}
+	if code, err := get(allowed, srv.URL+"/redirect"); err != nil || code != http.StatusFound {
+		t.Errorf("GET redirect = %d, %v, want the 302 itself", code, err)
+	}
+}
+
+func TestPublicOnly(t *testing.T) {
+	check := publicOnly([]netip.Prefix{netip.MustParsePrefix("10.1.0.0/16")})
+	tests := []struct {
+		address string
+		allowed bool
+	}{
+		{"93.184.216.34:443", true},
+		{"[2606:2800:220:1::1]:443", true},
+		{"10.1.2.3:80", true},
+		{"10.2.0.1:80", false},
+		{"127.0.0.1:80", false},
+		{"[::1]:80", false},
+		{"192.168.1.1:80", false},
+		{"172.16.0.1:80", false},
+		{"169.254.169.254:80", false},
+		{"[fe80::1]:80", false},
+		{"[fc00::1]:80", false},
+		{"0.0.0.0:80", false},
+		{"[::]:80", false},
+		{"[::ffff:127.0.0.1]:80", false},
+		{"[fe80::1%eth0]:80", false},
+		{"0.1.2.3:80", false},
+		{"100.64.0.1:80", false},
+		{"100.100.100.200:80", false},
+		{"100.128.0.1:80", true},
+		{"192.0.0.170:80", false},
+		{"192.0.2.1:80", false},
+		{"198.18.0.1:80", false},
+		{"198.19.255.255:80", false},
+		{"198.51.100.1:80", false},
+		{"203.0.113.1:80", false},
+		{"224.0.0.1:80", false},
+		{"233.252.0.1:80", false},
+		{"240.0.0.1:80", false},
+		{"255.255.255.255:80", false},
+		{"[64:ff9b::a9fe:a9fe]:80", false},
+		{"[64:ff9b::a00:1]:80", false},
+		{"[64:ff9b::5db8:d822]:443", true},
+		{"[64:ff9b:1::1]:80", false},
+		{"[100::1]:80", false},
+		{"[2001:db8::1]:80", false},
+		{"[2002:a00:1::1]:80", false},
+		{"[fec0::1]:80", false},
+		{"[ff02::1]:80", false},
+		{"[ff0e::1]:80", false},
+	}
+	for _, tt := range tests {
+		err := check("tcp", tt.address, nil)
+		if tt.allowed && err != nil || !tt.allowed && !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("%s: error = %v, want allowed %v", tt.address, err, tt.allowed)
+		}
+	}
+}
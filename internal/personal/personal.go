@@ -0,0 +1,166 @@
+// Package personal implements personal mode: a single-user instance that
+// keeps its data in the user's home directory and backs it up
+// automatically.
+package personal
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Config holds personal mode settings
+type Config struct {
+	// DataDir holds the snapshot file and the backups directory
+	DataDir string
+
+	// BackupInterval is how often a backup is taken
+	BackupInterval time.Duration
+
+	// KeepBackups is the number of most recent backups retained
+	KeepBackups int
+}
+
+// DefaultConfig stores data in ~/.cert-tasks, backing up daily and keeping
+// a week of backups
+func DefaultConfig() (Config, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return Config{}, fmt.Errorf("locating home directory: %w", err)
+	}
+
+	return Config{
+		DataDir:        filepath.Join(home, ".cert-tasks"),
+		BackupInterval: 24 * time.Hour,
+		KeepBackups:    7,
+	}, nil
+}
+
+// SnapshotPath returns the location of the task snapshot file
+func (c Config) SnapshotPath() string {
+	return filepath.Join(c.DataDir, "tasks.json")
+}
+
+// BackupDir returns the directory backups are written to
+func (c Config) BackupDir() string {
+	return filepath.Join(c.DataDir, "backups")
+}
+
+// Prepare creates the data and backup directories
+func (c Config) Prepare() error {
+	if err := os.MkdirAll(c.BackupDir(), 0o700); err != nil {
+		return fmt.Errorf("creating data directory: %w", err)
+	}
+	return nil
+}
+
+// backupPrefix and backupSuffix frame the timestamp in backup file names
+const (
+	backupPrefix = "tasks-"
+	backupSuffix = ".json"
+)
+
+// Backups writes timestamped snapshots and prunes old ones
+type Backups struct {
+	dir   string
+	keep  int
+	write func(f *os.File) error
+	now   func() time.Time
+}
+
+// NewBackups creates a backup writer; write must serialize the dataset
+func NewBackups(cfg Config, write func(f *os.File) error) *Backups {
+	return &Backups{
+		dir:   cfg.BackupDir(),
+		keep:  cfg.KeepBackups,
+		write: write,
+		now:   time.Now,
+	}
+}
+
+// Run takes a backup immediately and then every interval until ctx is done
+func (b *Backups) Run(ctx context.Context, interval time.Duration) {
+	if _, err := b.Backup(); err != nil {
+		slog.Error("personal mode backup failed", slog.Any("error", err))
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := b.Backup(); err != nil {
+				slog.Error("personal mode backup failed", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// Backup writes a new backup file, prunes old ones, and returns its path
+func (b *Backups) Backup() (string, error) {
+	name := backupPrefix + b.now().UTC().Format("20060102T150405.000000000Z") + backupSuffix
+	path := filepath.Join(b.dir, name)
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("creating backup: %w", err)
+	}
+	if err := b.write(f); err != nil {
+		f.Close()
+		os.Remove(path)
+		return "", fmt.Errorf("writing backup: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("writing backup: %w", err)
+	}
+
+	return path, b.prune()
+}
+
+// List returns backup file paths, oldest first
+func (b *Backups) List() ([]string, error) {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), backupPrefix) && strings.HasSuffix(e.Name(), backupSuffix) {
+			backups = append(backups, filepath.Join(b.dir, e.Name()))
+		}
+	}
+	// Timestamps are fixed-width, so lexical order is chronological
+	sort.Strings(backups)
+
+	return backups, nil
+}
+
+// prune removes all but the newest keep backups
+func (b *Backups) prune() error {
+	if b.keep <= 0 {
+		return nil
+	}
+
+	backups, err := b.List()
+	if err != nil {
+		return err
+	}
+
+	for len(backups) > b.keep {
+		if err := os.Remove(backups[0]); err != nil {
+			return fmt.Errorf("pruning backups: %w", err)
+		}
+		backups = backups[1:]
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package personal
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestBackups_BackupAndPrune(t *testing.T) {
+	cfg := Config{DataDir: t.TempDir(), KeepBackups: 2}
+	if err := cfg.Prepare(); err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+
+	b := NewBackups(cfg, func(f *os.File) error {
+		_, err := f.WriteString(`{"tasks":[]}`)
+		return err
+	})
+
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	b.now = func() time.Time {
+		clock = clock.Add(time.Hour)
+		return clock
+	}
+
+	var paths []string
+	for i := 0; i < 4; i++ {
+		path, err := b.Backup()
+		if err != nil {
+			t.Fatalf("Backup() error = %v", err)
+		}
+		paths = append(paths, path)
+	}
+
+	backups, err := b.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("got %d backups, want 2", len(backups))
+	}
+	if backups[0] != paths[2] || backups[1] != paths[3] {
+		t.Errorf("backups = %v, want newest two %v", backups, paths[2:])
+	}
+
+	data, _ := os.ReadFile(backups[1])
+	if string(data) != `{"tasks":[]}` {
+		t.Errorf("backup content = %q", data)
+	}
+}
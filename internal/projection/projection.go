@@ -0,0 +1,122 @@
+// Package projection trims JSON responses to the fields a client asks for
+// with ?fields=, so that clients on slow links can fetch slim payloads. A
+// Schema lists the selectable fields of one type, read from its json tags;
+// Fields parsed against it project values of that type, or slices of them.
+package projection
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Schema is the set of selectable fields of a struct type, in declaration
+// order
+type Schema struct {
+	names []string
+	index map[string]int
+}
+
+// NewSchema reads the JSON field names of v's struct type. Fields without
+// a json tag name or tagged "-" cannot be selected.
+func NewSchema(v any) *Schema {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	s := &Schema{index: make(map[string]int)}
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" || !t.Field(i).IsExported() {
+			continue
+		}
+		s.index[name] = len(s.names)
+		s.names = append(s.names, name)
+	}
+	return s
+}
+
+// Fields is a selection of fields. The zero value selects everything.
+type Fields struct {
+	selected []bool // by schema index; nil selects everything
+}
+
+// Parse reads a comma-separated list of field names such as
+// "id,title,status". An empty list selects every field; unknown names are
+// an error listing the valid ones.
+func (s *Schema) Parse(list string) (Fields, error) {
+	if strings.TrimSpace(list) == "" {
+		return Fields{}, nil
+	}
+	selected := make([]bool, len(s.names))
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		i, ok := s.index[name]
+		if !ok {
+			return Fields{}, fmt.Errorf("unknown field %q; valid fields are %s", name, strings.Join(s.names, ", "))
+		}
+		selected[i] = true
+	}
+	return Fields{selected: selected}, nil
+}
+
+// All reports whether every field is selected, so nothing needs trimming
+func (f Fields) All() bool {
+	return f.selected == nil
+}
+
+// Apply returns v, or each element of v if it is a slice, as a JSON
+// object holding only the selected fields, in declaration order. Selected
+// fields that v omits when empty stay omitted. v must have the schema's
+// type.
+func (s *Schema) Apply(f Fields, v any) (any, error) {
+	if f.All() {
+		return v, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return s.project(f, v)
+	}
+	out := make([]json.RawMessage, rv.Len())
+	for i := range out {
+		projected, err := s.project(f, rv.Index(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		out[i] = projected
+	}
+	return out, nil
+}
+
+// project encodes one value with the selected fields only
+func (s *Schema) project(f Fields, v any) (json.RawMessage, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(body, &all); err != nil {
+		return nil, fmt.Errorf("projecting %T: %w", v, err)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, name := range s.names {
+		value, ok := all[name]
+		if !f.selected[i] || !ok {
+			continue
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(name)
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
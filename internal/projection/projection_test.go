@@ -0,0 +1,68 @@
+package projection
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type item struct {
+	ID      int64    `json:"id"`
+	Name    string   `json:"name"`
+	Note    string   `json:"note,omitempty"`
+	Tags    []string `json:"tags"`
+	Secret  string   `json:"-"`
+	private string
+}
+
+func TestSchema_Parse(t *testing.T) {
+	s := NewSchema(&item{})
+
+	if f, err := s.Parse(""); err != nil || !f.All() {
+		t.Errorf(`Parse("") = %v, %v; want every field`, f, err)
+	}
+	if f, err := s.Parse(" name , id,name"); err != nil || f.All() {
+		t.Errorf("Parse = %v, %v", f, err)
+	}
+	for _, list := range []string{"id,secret", "Secret", "private", "id,"} {
+		_, err := s.Parse(list)
+		if err == nil || !strings.Contains(err.Error(), "id, name, note, tags") {
+			t.Errorf("Parse(%q) error = %v, want unknown field listing the valid ones", list, err)
+		}
+	}
+}
+
+func TestSchema_Apply(t *testing.T) {
+	s := NewSchema(item{})
+	encode := func(fields string, v any) string {
+		t.Helper()
+		f, err := s.Parse(fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		projected, err := s.Apply(f, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := json.Marshal(projected)
+		return string(body)
+	}
+
+	one := item{ID: 1, Name: "a", Tags: []string{"x"}, Secret: "s"}
+	if got, want := encode("tags,id", one), `{"id":1,"tags":["x"]}`; got != want {
+		t.Errorf("single = %s, want %s", got, want)
+	}
+	if got, want := encode("note", &one), `{}`; got != want {
+		t.Errorf("omitted field = %s, want %s", got, want)
+	}
+	list := []*item{&one, {ID: 2, Name: "b"}}
+	if got, want := encode("id,name", list), `[{"id":1,"name":"a"},{"id":2,"name":"b"}]`; got != want {
+		t.Errorf("list = %s, want %s", got, want)
+	}
+	if got, want := encode("id", []item{}), `[]`; got != want {
+		t.Errorf("empty list = %s, want %s", got, want)
+	}
+	if got := encode("", one); !strings.Contains(got, `"name":"a"`) || strings.Contains(got, `"s"`) {
+		t.Errorf("every field = %s", got)
+	}
+}
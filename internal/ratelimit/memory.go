@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often full, idle buckets are dropped
+const sweepInterval = time.Minute
+
+// bucket is one client's tokens as of last
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// MemoryStore keeps buckets in process memory
+type MemoryStore struct {
+	now func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Take implements Store
+func (s *MemoryStore) Take(ctx context.Context, key string, rate float64, burst int) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.lastSweep) > sweepInterval {
+		s.sweep(now, rate, burst)
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), last: now}
+		s.buckets[key] = b
+	}
+	b.tokens = min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return newResult(allowed, b.tokens, rate), nil
+}
+
+// sweep drops buckets that have refilled completely, since a new bucket
+// is equivalent; s.mu must be held
+func (s *MemoryStore) sweep(now time.Time, rate float64, burst int) {
+	for key, b := range s.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*rate >= float64(burst) {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
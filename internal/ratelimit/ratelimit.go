@@ -0,0 +1,121 @@
+// Package ratelimit limits request rates per client with token buckets.
+// Buckets live in a pluggable Store: in memory for a single instance, or
+// in Redis so replicas behind a load balancer share one budget per client.
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/auth"
+	"github.com/light-bringer/cert-tasks/internal/logging"
+)
+
+// Rate limit headers set on every limited response
+//
+//api:changelog 0.2.0 added header X-RateLimit-Limit: Burst size of the client's token bucket
+//api:changelog 0.2.0 added header X-RateLimit-Remaining: Requests the client can still make without waiting
+const (
+	HeaderLimit     = "X-RateLimit-Limit"
+	HeaderRemaining = "X-RateLimit-Remaining"
+)
+
+// Result is the outcome of taking a token
+type Result struct {
+	Allowed   bool
+	Remaining int
+
+	// RetryAfter is how long until a token is available; zero if allowed
+	RetryAfter time.Duration
+}
+
+// Store holds token buckets
+type Store interface {
+	// Take removes a token from key's bucket, which refills at rate
+	// tokens per second up to burst
+	Take(ctx context.Context, key string, rate float64, burst int) (Result, error)
+}
+
+// newResult builds a Result from the tokens left after a take
+func newResult(allowed bool, tokens, rate float64) Result {
+	res := Result{Allowed: allowed, Remaining: int(math.Floor(tokens))}
+	if !allowed {
+		res.RetryAfter = time.Duration((1 - tokens) / rate * float64(time.Second))
+	}
+	return res
+}
+
+// Config holds limiter settings
+type Config struct {
+	// RPS is the sustained requests per second allowed per client
+	RPS float64
+
+	// Burst is how many requests a client may make at once
+	Burst int
+}
+
+// Limiter applies a rate limit per client
+type Limiter struct {
+	store Store
+	cfg   Config
+}
+
+// New creates a limiter backed by store
+func New(store Store, cfg Config) *Limiter {
+	return &Limiter{store: store, cfg: cfg}
+}
+
+// Middleware rejects clients over their rate by calling limited, after
+// setting Retry-After. Authenticated clients are keyed by API key or JWT
+// subject, others by IP address. If the store fails, requests are let through rather than
+// failing the API.
+func (l *Limiter) Middleware(limited http.HandlerFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res, err := l.store.Take(r.Context(), clientKey(r), l.cfg.RPS, l.cfg.Burst)
+			if err != nil {
+				logging.FromContext(r.Context()).Warn("rate limit store unavailable", slog.Any("error", err))
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set(HeaderLimit, strconv.Itoa(l.cfg.Burst))
+			w.Header().Set(HeaderRemaining, strconv.Itoa(res.Remaining))
+			if !res.Allowed {
+				seconds := int(math.Ceil(res.RetryAfter.Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+				limited(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientKey identifies the client a request counts against
+func clientKey(r *http.Request) string {
+	if key, ok := auth.FromContext(r.Context()); ok {
+		return "key:" + strconv.FormatInt(key.ID, 10)
+	}
+	if subject, ok := auth.SubjectFromContext(r.Context()); ok {
+		return "user:" + subject
+	}
+	return "ip:" + clientIP(r)
+}
+
+// clientIP returns the client address of r without its port; behind a
+// trusted proxy forwarded.Middleware has already taken it from
+// X-Forwarded-For
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
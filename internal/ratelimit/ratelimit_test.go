@@ -0,0 +1,165 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/light-bringer/cert-tasks/internal/auth"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+// testStores returns every Store implementation plus a function that
+// advances its clock
+func testStores(t *testing.T) map[string]struct {
+	store   Store
+	advance func(time.Duration)
+} {
+	t.Helper()
+
+	now := time.Now()
+	mem := NewMemoryStore()
+	mem.now = func() time.Time { return now }
+
+	mr := miniredis.RunT(t)
+	redisNow := time.Now()
+	mr.SetTime(redisNow)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return map[string]struct {
+		store   Store
+		advance func(time.Duration)
+	}{
+		"memory": {mem, func(d time.Duration) { now = now.Add(d) }},
+		"redis": {NewRedisStore(client, "ratelimit:"), func(d time.Duration) {
+			redisNow = redisNow.Add(d)
+			mr.SetTime(redisNow)
+		}},
+	}
+}
+
+func TestStores_TokenBucket(t *testing.T) {
+	ctx := context.Background()
+
+	for name, tt := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			// Burst of 2, refilling one token per second
+			for i, wantRemaining := range []int{1, 0} {
+				res, err := tt.store.Take(ctx, "client", 1, 2)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !res.Allowed || res.Remaining != wantRemaining {
+					t.Fatalf("take %d = %+v, want allowed with %d remaining", i+1, res, wantRemaining)
+				}
+			}
+
+			res, _ := tt.store.Take(ctx, "client", 1, 2)
+			if res.Allowed || res.RetryAfter <= 0 || res.RetryAfter > time.Second {
+				t.Fatalf("take over burst = %+v, want denied with RetryAfter in (0, 1s]", res)
+			}
+
+			// Other clients have their own bucket
+			if res, _ := tt.store.Take(ctx, "other", 1, 2); !res.Allowed {
+				t.Errorf("other client denied: %+v", res)
+			}
+
+			tt.advance(time.Second)
+			if res, _ := tt.store.Take(ctx, "client", 1, 2); !res.Allowed {
+				t.Errorf("take after refill = %+v, want allowed", res)
+			}
+		})
+	}
+}
+
+func TestMemoryStore_Sweep(t *testing.T) {
+	now := time.Now()
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+
+	s.Take(context.Background(), "idle", 1, 2)
+	now = now.Add(2 * sweepInterval)
+	s.Take(context.Background(), "active", 1, 2)
+
+	if _, ok := s.buckets["idle"]; ok {
+		t.Error("refilled bucket was not swept")
+	}
+}
+
+// failingStore always fails, like an unreachable Redis
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, float64, int) (Result, error) {
+	return Result{}, errors.New("connection refused")
+}
+
+func TestLimiter_Middleware(t *testing.T) {
+	limited := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	h := New(NewMemoryStore(), Config{RPS: 1, Burst: 1}).Middleware(limited)(ok)
+	request := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/tasks", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := request("10.0.0.1:1234"); rec.Code != http.StatusOK || rec.Header().Get(HeaderRemaining) != "0" {
+		t.Errorf("first request = %v remaining %q, want 200 with 0 remaining", rec.Code, rec.Header().Get(HeaderRemaining))
+	}
+
+	// A new connection from the same IP shares the bucket
+	rec := request("10.0.0.1:5678")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("second request = %v Retry-After %q, want 429 with Retry-After 1", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	if rec := request("10.0.0.2:1234"); rec.Code != http.StatusOK {
+		t.Errorf("other client = %v, want 200", rec.Code)
+	}
+
+	// A failing store lets requests through
+	h = New(failingStore{}, Config{RPS: 1, Burst: 1}).Middleware(limited)(ok)
+	for range 3 {
+		if rec := request("10.0.0.3:1234"); rec.Code != http.StatusOK {
+			t.Errorf("with failing store = %v, want 200", rec.Code)
+		}
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	key, secret, _ := auth.NewKey("ci", models.ScopeRead, "")
+	created, _ := repo.CreateAPIKey(context.Background(), key)
+
+	var got string
+	h := auth.New(repo).Middleware(nil, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = clientKey(r)
+	}))
+
+	req := httptest.NewRequest("GET", "/tasks", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	if k := clientKey(req); k != "ip:192.0.2.1" {
+		t.Errorf("anonymous key = %q, want ip:192.0.2.1", k)
+	}
+
+	req.Header.Set(auth.HeaderAPIKey, secret)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if want := "key:" + strconv.FormatInt(created.ID, 10); got != want {
+		t.Errorf("authenticated key = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// takeScript refills and takes from a bucket atomically, using the Redis
+// server clock so replicas with skewed clocks agree. Tokens are returned
+// as a string because Lua numbers are truncated to integers in replies.
+var takeScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) + tonumber(t[2]) / 1000000
+
+local b = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(b[1]) or burst
+local ts = tonumber(b[2]) or now
+tokens = math.min(burst, tokens + (now - ts) * rate)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000))
+return {allowed, tostring(tokens)}
+`)
+
+// RedisStore keeps buckets in Redis, shared by every instance using the
+// same server and prefix
+type RedisStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisStore creates a store using client; keys are namespaced with
+// prefix
+func NewRedisStore(client redis.UniversalClient, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+// Take implements Store
+func (s *RedisStore) Take(ctx context.Context, key string, rate float64, burst int) (Result, error) {
+	reply, err := takeScript.Run(ctx, s.client, []string{s.prefix + key},
+		strconv.FormatFloat(rate, 'f', -1, 64), burst).Slice()
+	if err != nil {
+		return Result{}, err
+	}
+
+	allowed, _ := reply[0].(int64)
+	tokens, err := strconv.ParseFloat(reply[1].(string), 64)
+	if err != nil {
+		return Result{}, err
+	}
+	return newResult(allowed == 1, tokens, rate), nil
+}
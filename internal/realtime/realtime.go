@@ -0,0 +1,431 @@
+// Package realtime serves the WebSocket API at /ws. A connection receives
+// the task events of its workspace, narrowed by subscription filters, and
+// can create, update and delete tasks over the same socket. Mutations are
+// replayed as HTTP requests through the server's own router with the
+// credentials of the upgrade request, so they get exactly the
+// authorization, validation, rate limiting and auditing of the REST API.
+package realtime
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/logging"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+	"golang.org/x/net/websocket"
+)
+
+// Message types. Clients send subscribe, create, update, delete, ping and
+// pong; the server sends subscribed, event, result, ping, pong and error.
+const (
+	TypeSubscribe  = "subscribe"
+	TypeSubscribed = "subscribed"
+	TypeCreate     = "create"
+	TypeUpdate     = "update"
+	TypeDelete     = "delete"
+	TypeEvent      = "event"
+	TypeResult     = "result"
+	TypePing       = "ping"
+	TypePong       = "pong"
+	TypeError      = "error"
+)
+
+// ClientMessage is a message sent by a client
+type ClientMessage struct {
+	Type string `json:"type"`
+
+	// Ref is echoed in the result of a mutation so clients can match them
+	Ref string `json:"ref,omitempty"`
+
+	// ID is the task to update or delete
+	ID int64 `json:"id,omitempty"`
+
+	// Task is the body of a create or update, as for POST /tasks and
+	// PUT /tasks/{id}
+	Task json.RawMessage `json:"task,omitempty"`
+
+	// Events and TaskIDs replace the connection's filters on subscribe;
+	// empty means all
+	Events  []models.TaskEventType `json:"events,omitempty"`
+	TaskIDs []int64                `json:"task_ids,omitempty"`
+}
+
+// ServerMessage is a message sent to a client
+type ServerMessage struct {
+	Type string `json:"type"`
+	Ref  string `json:"ref,omitempty"`
+
+	// Status and Body are the HTTP status and response body of a mutation
+	Status int             `json:"status,omitempty"`
+	Body   json.RawMessage `json:"body,omitempty"`
+
+	Event *models.TaskEvent `json:"event,omitempty"`
+
+	// Events and TaskIDs confirm the filters after a subscribe
+	Events  []models.TaskEventType `json:"events,omitempty"`
+	TaskIDs []int64                `json:"task_ids,omitempty"`
+
+	Message string `json:"message,omitempty"`
+}
+
+// Config tunes connections
+type Config struct {
+	// PingInterval is how often the server sends a ping. A connection
+	// that sends nothing for PingInterval plus PongTimeout is closed.
+	PingInterval time.Duration
+	PongTimeout  time.Duration
+
+	// WriteTimeout bounds sending one message
+	WriteTimeout time.Duration
+
+	// SendBuffer is the number of messages queued per connection; a
+	// client that falls further behind is disconnected
+	SendBuffer int
+
+	// MaxMessageBytes bounds a client message
+	MaxMessageBytes int
+
+	// AllowedOrigins lists the browser origins, besides the server's own,
+	// that may connect; "*" allows any
+	AllowedOrigins []string
+}
+
+// DefaultConfig pings every 30 seconds and allows 1 MiB messages
+func DefaultConfig() Config {
+	return Config{
+		PingInterval:    30 * time.Second,
+		PongTimeout:     10 * time.Second,
+		WriteTimeout:    10 * time.Second,
+		SendBuffer:      256,
+		MaxMessageBytes: 1 << 20,
+	}
+}
+
+// Hub tracks the open connections and fans task events out to them
+type Hub struct {
+	cfg Config
+
+	mu      sync.Mutex
+	clients map[*client]struct{}
+	closed  bool
+}
+
+// NewHub creates a Hub
+func NewHub(cfg Config) *Hub {
+	return &Hub{cfg: cfg, clients: make(map[*client]struct{})}
+}
+
+// client is one connection
+type client struct {
+	ws   *websocket.Conn
+	send chan []byte
+
+	// workspace and owner are the scope of the upgrade request; events
+	// outside it are never sent
+	workspace string
+	owner     string
+
+	mu      sync.Mutex
+	events  []models.TaskEventType
+	taskIDs []int64
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// Publish sends an event to every connection whose scope and filters
+// match the task. It never blocks; connections too slow to keep up are
+// closed.
+func (h *Hub) Publish(ctx context.Context, typ models.TaskEventType, task *models.Task) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var msg []byte
+	for c := range h.clients {
+		if !c.wants(typ, task) {
+			continue
+		}
+		if msg == nil {
+			msg, _ = json.Marshal(ServerMessage{Type: TypeEvent, Event: models.NewTaskEvent(typ, task, time.Now())})
+		}
+		if !c.enqueue(msg) {
+			logging.FromContext(ctx).Warn("websocket client too slow, disconnecting",
+				slog.String("remote_addr", c.ws.Request().RemoteAddr))
+		}
+	}
+}
+
+// Close disconnects every client and refuses new connections
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for c := range h.clients {
+		c.close()
+	}
+}
+
+// Handler returns the /ws endpoint. It must run after authentication and
+// workspace resolution; mutations are served by mutations, normally the
+// server's router.
+func (h *Hub) Handler(mutations http.Handler) http.Handler {
+	return websocket.Server{
+		Handshake: h.checkOrigin,
+		Handler: func(ws *websocket.Conn) {
+			h.serve(ws, mutations)
+		},
+	}
+}
+
+// AccessToken moves an ?access_token= query parameter into the
+// Authorization header, for browsers, which cannot set headers on a
+// WebSocket handshake. It must run before authentication.
+//
+//api:changelog 0.2.0 added parameter GET /ws?access_token: API key or JWT for clients that cannot set the Authorization header
+func AccessToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if token := q.Get("access_token"); token != "" {
+			if r.Header.Get("Authorization") == "" {
+				r.Header.Set("Authorization", "Bearer "+token)
+			}
+			q.Del("access_token")
+			r.URL.RawQuery = q.Encode()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkOrigin accepts clients without an Origin header, which are not
+// browsers, and browsers on the server's own origin or an allowed one
+func (h *Hub) checkOrigin(cfg *websocket.Config, r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return fmt.Errorf("invalid origin %q", origin)
+	}
+	if u.Host == r.Host || slices.Contains(h.cfg.AllowedOrigins, "*") || slices.Contains(h.cfg.AllowedOrigins, origin) {
+		cfg.Origin = u
+		return nil
+	}
+	return fmt.Errorf("origin %q not allowed", origin)
+}
+
+// serve runs one connection until it is closed
+func (h *Hub) serve(ws *websocket.Conn, mutations http.Handler) {
+	r := ws.Request()
+	ws.MaxPayloadBytes = h.cfg.MaxMessageBytes
+
+	c := &client{
+		ws:        ws,
+		send:      make(chan []byte, h.cfg.SendBuffer),
+		workspace: repository.WorkspaceFromContext(r.Context()),
+		owner:     repository.OwnerFromContext(r.Context()),
+		done:      make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		ws.Close()
+		return
+	}
+	h.clients[c] = struct{}{}
+	h.mu.Unlock()
+
+	defer func() {
+		h.mu.Lock()
+		delete(h.clients, c)
+		h.mu.Unlock()
+		c.close()
+	}()
+
+	go h.write(c)
+
+	for {
+		ws.SetReadDeadline(time.Now().Add(h.cfg.PingInterval + h.cfg.PongTimeout))
+		var data []byte
+		if err := websocket.Message.Receive(ws, &data); err != nil {
+			return
+		}
+
+		var msg ClientMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			c.reply(ServerMessage{Type: TypeError, Message: "message must be a JSON object"})
+			continue
+		}
+		h.handle(c, &msg, r, mutations)
+	}
+}
+
+// write sends queued messages and pings until the connection is closed.
+// It is the only goroutine writing to the socket, including its close.
+func (h *Hub) write(c *client) {
+	ticker := time.NewTicker(h.cfg.PingInterval)
+	defer ticker.Stop()
+	ping, _ := json.Marshal(ServerMessage{Type: TypePing})
+
+	defer func() {
+		c.ws.SetWriteDeadline(time.Now().Add(h.cfg.WriteTimeout))
+		c.ws.Close()
+	}()
+
+	for {
+		var msg []byte
+		select {
+		case <-c.done:
+			return
+		case msg = <-c.send:
+		case <-ticker.C:
+			msg = ping
+		}
+
+		c.ws.SetWriteDeadline(time.Now().Add(h.cfg.WriteTimeout))
+		if err := websocket.Message.Send(c.ws, string(msg)); err != nil {
+			c.close()
+			return
+		}
+	}
+}
+
+// handle acts on one client message
+func (h *Hub) handle(c *client, msg *ClientMessage, upgrade *http.Request, mutations http.Handler) {
+	switch msg.Type {
+	case TypeSubscribe:
+		for _, e := range msg.Events {
+			if !e.IsValid() {
+				c.reply(ServerMessage{Type: TypeError, Ref: msg.Ref, Message: fmt.Sprintf("unknown event %q", e)})
+				return
+			}
+		}
+		c.mu.Lock()
+		c.events = msg.Events
+		c.taskIDs = msg.TaskIDs
+		c.mu.Unlock()
+		c.reply(ServerMessage{Type: TypeSubscribed, Ref: msg.Ref, Events: msg.Events, TaskIDs: msg.TaskIDs})
+
+	case TypeCreate, TypeUpdate, TypeDelete:
+		status, body := mutate(msg, upgrade, mutations)
+		c.reply(ServerMessage{Type: TypeResult, Ref: msg.Ref, Status: status, Body: body})
+
+	case TypePing:
+		c.reply(ServerMessage{Type: TypePong, Ref: msg.Ref})
+
+	case TypePong:
+		// Receiving it has already extended the read deadline
+
+	default:
+		c.reply(ServerMessage{Type: TypeError, Ref: msg.Ref, Message: fmt.Sprintf("unknown message type %q", msg.Type)})
+	}
+}
+
+// mutate serves a create, update or delete as the equivalent REST request
+// and returns its status and body
+func mutate(msg *ClientMessage, upgrade *http.Request, mutations http.Handler) (int, json.RawMessage) {
+	method, path := http.MethodPost, "/tasks"
+	switch msg.Type {
+	case TypeUpdate:
+		method, path = http.MethodPut, "/tasks/"+strconv.FormatInt(msg.ID, 10)
+	case TypeDelete:
+		method, path = http.MethodDelete, "/tasks/"+strconv.FormatInt(msg.ID, 10)
+	}
+
+	req, _ := http.NewRequestWithContext(context.Background(), method, path, bytes.NewReader(msg.Task))
+	req.Header = upgrade.Header.Clone()
+	for _, name := range []string{"Connection", "Upgrade", "Sec-Websocket-Key", "Sec-Websocket-Version",
+		"Sec-Websocket-Extensions", "Sec-Websocket-Protocol", "X-Request-Id"} {
+		req.Header.Del(name)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = upgrade.RemoteAddr
+	req.Host = upgrade.Host
+
+	rec := &recorder{header: make(http.Header)}
+	mutations.ServeHTTP(rec, req)
+
+	status := rec.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	body := bytes.TrimSpace(rec.body.Bytes())
+	if len(body) == 0 || !json.Valid(body) {
+		return status, nil
+	}
+	return status, body
+}
+
+// recorder captures the response of a replayed mutation
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *recorder) Header() http.Header { return r.header }
+
+func (r *recorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.body.Write(p)
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+// wants reports whether the connection should receive the event
+func (c *client) wants(typ models.TaskEventType, task *models.Task) bool {
+	if (c.workspace != "" && task.WorkspaceID != c.workspace) || (c.owner != "" && task.OwnerID != c.owner) {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return (len(c.events) == 0 || slices.Contains(c.events, typ)) &&
+		(len(c.taskIDs) == 0 || slices.Contains(c.taskIDs, task.ID))
+}
+
+// reply queues a message for the client
+func (c *client) reply(msg ServerMessage) {
+	data, _ := json.Marshal(msg)
+	c.enqueue(data)
+}
+
+// enqueue queues data without blocking, closing the connection and
+// returning false when its buffer is full
+func (c *client) enqueue(data []byte) bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+	}
+	select {
+	case c.send <- data:
+		return true
+	default:
+		c.close()
+		return false
+	}
+}
+
+// close tells the writer to close the socket, which ends the read loop
+func (c *client) close() {
+	c.closeOnce.Do(func() { close(c.done) })
+}
@@ -0,0 +1,189 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+	"golang.org/x/net/websocket"
+)
+
+// newTestServer serves hub at /ws in the workspace named by the
+// X-Workspace-ID header, replaying mutations to mutations
+func newTestServer(t *testing.T, hub *Hub, mutations http.Handler) *httptest.Server {
+	t.Helper()
+	ws := hub.Handler(mutations)
+	srv := httptest.NewServer(AccessToken(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := repository.WithWorkspace(r.Context(), r.Header.Get("X-Workspace-ID"))
+		ws.ServeHTTP(w, r.WithContext(ctx))
+	})))
+	t.Cleanup(func() {
+		hub.Close()
+		srv.Close()
+	})
+	return srv
+}
+
+// dial connects to srv's /ws in workspace with the given query
+func dial(t *testing.T, srv *httptest.Server, workspace, query string) *websocket.Conn {
+	t.Helper()
+	cfg, err := websocket.NewConfig(strings.Replace(srv.URL, "http", "ws", 1)+"/ws"+query, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Header.Set("X-Workspace-ID", workspace)
+	conn, err := websocket.DialConfig(cfg)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// send writes msg to conn
+func send(t *testing.T, conn *websocket.Conn, msg ClientMessage) {
+	t.Helper()
+	if err := websocket.JSON.Send(conn, msg); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+}
+
+// receive reads the next message other than a ping
+func receive(t *testing.T, conn *websocket.Conn) ServerMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		var msg ServerMessage
+		if err := websocket.JSON.Receive(conn, &msg); err != nil {
+			t.Fatalf("receive: %v", err)
+		}
+		if msg.Type != TypePing {
+			return msg
+		}
+	}
+}
+
+func TestHub_Events(t *testing.T) {
+	hub := NewHub(DefaultConfig())
+	srv := newTestServer(t, hub, http.NotFoundHandler())
+
+	acme := dial(t, srv, "acme", "")
+	filtered := dial(t, srv, "acme", "")
+	send(t, filtered, ClientMessage{Type: TypeSubscribe, Events: []models.TaskEventType{models.EventTaskCompleted}, TaskIDs: []int64{2}})
+	if msg := receive(t, filtered); msg.Type != TypeSubscribed || len(msg.TaskIDs) != 1 {
+		t.Fatalf("subscribe reply = %+v, want subscribed", msg)
+	}
+	// Round trip so both connections are registered before publishing
+	send(t, acme, ClientMessage{Type: TypePing, Ref: "p"})
+	if msg := receive(t, acme); msg.Type != TypePong || msg.Ref != "p" {
+		t.Fatalf("ping reply = %+v, want pong", msg)
+	}
+
+	ctx := context.Background()
+	hub.Publish(ctx, models.EventTaskCreated, &models.Task{ID: 1, WorkspaceID: "default"})
+	hub.Publish(ctx, models.EventTaskCreated, &models.Task{ID: 2, WorkspaceID: "acme"})
+	hub.Publish(ctx, models.EventTaskCompleted, &models.Task{ID: 1, WorkspaceID: "acme"})
+	hub.Publish(ctx, models.EventTaskCompleted, &models.Task{ID: 2, WorkspaceID: "acme"})
+
+	var got []int64
+	for range 3 {
+		msg := receive(t, acme)
+		if msg.Type != TypeEvent || msg.Event.WorkspaceID != "acme" {
+			t.Fatalf("message = %+v, want an acme event", msg)
+		}
+		got = append(got, msg.Event.Task.ID)
+	}
+	if got[0] != 2 || got[1] != 1 || got[2] != 2 {
+		t.Errorf("acme task IDs = %v, want [2 1 2]", got)
+	}
+
+	msg := receive(t, filtered)
+	if msg.Event == nil || msg.Event.Type != models.EventTaskCompleted || msg.Event.Task.ID != 2 {
+		t.Errorf("filtered connection got %+v, want only task.completed for task 2", msg)
+	}
+}
+
+func TestHub_Mutations(t *testing.T) {
+	var gotMethod, gotPath, gotAuth, gotBody string
+	mutations := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotMethod, gotPath, gotAuth, gotBody = r.Method, r.URL.Path, r.Header.Get("Authorization"), string(body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(models.Task{ID: 7, Title: "Write report"})
+	})
+	srv := newTestServer(t, NewHub(DefaultConfig()), mutations)
+	conn := dial(t, srv, "default", "?access_token=tsk_secret")
+
+	send(t, conn, ClientMessage{Type: TypeCreate, Ref: "c1", Task: json.RawMessage(`{"title":"Write report"}`)})
+	msg := receive(t, conn)
+	if msg.Type != TypeResult || msg.Ref != "c1" || msg.Status != http.StatusCreated {
+		t.Fatalf("result = %+v, want 201 for c1", msg)
+	}
+	var task models.Task
+	if err := json.Unmarshal(msg.Body, &task); err != nil || task.ID != 7 {
+		t.Errorf("result body = %s, want the created task", msg.Body)
+	}
+	if gotMethod != http.MethodPost || gotPath != "/tasks" || gotBody != `{"title":"Write report"}` {
+		t.Errorf("replayed %s %s %s, want POST /tasks with the task", gotMethod, gotPath, gotBody)
+	}
+	if gotAuth != "Bearer tsk_secret" {
+		t.Errorf("replayed Authorization = %q, want the access token", gotAuth)
+	}
+
+	send(t, conn, ClientMessage{Type: TypeDelete, Ref: "d1", ID: 7})
+	receive(t, conn)
+	if gotMethod != http.MethodDelete || gotPath != "/tasks/7" {
+		t.Errorf("replayed %s %s, want DELETE /tasks/7", gotMethod, gotPath)
+	}
+
+	send(t, conn, ClientMessage{Type: "explode"})
+	if msg := receive(t, conn); msg.Type != TypeError {
+		t.Errorf("unknown type reply = %+v, want an error", msg)
+	}
+	send(t, conn, ClientMessage{Type: TypeSubscribe, Events: []models.TaskEventType{"task.exploded"}})
+	if msg := receive(t, conn); msg.Type != TypeError {
+		t.Errorf("invalid subscribe reply = %+v, want an error", msg)
+	}
+}
+
+func TestHub_Heartbeat(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.PingInterval = 20 * time.Millisecond
+	cfg.PongTimeout = 20 * time.Millisecond
+	srv := newTestServer(t, NewHub(cfg), http.NotFoundHandler())
+	conn := dial(t, srv, "default", "")
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	var msg ServerMessage
+	if err := websocket.JSON.Receive(conn, &msg); err != nil || msg.Type != TypePing {
+		t.Fatalf("first message = %+v, %v; want a ping", msg, err)
+	}
+
+	// Without any reply the server gives up on the connection
+	for {
+		if err := websocket.JSON.Receive(conn, &msg); err != nil {
+			if strings.Contains(err.Error(), "timeout") {
+				t.Fatal("connection still open after missing pongs")
+			}
+			break
+		}
+	}
+}
+
+func TestHub_CheckOrigin(t *testing.T) {
+	srv := newTestServer(t, NewHub(DefaultConfig()), http.NotFoundHandler())
+
+	cfg, _ := websocket.NewConfig(strings.Replace(srv.URL, "http", "ws", 1)+"/ws", "https://evil.example.com")
+	if conn, err := websocket.DialConfig(cfg); err == nil {
+		conn.Close()
+		t.Error("dial from a foreign origin succeeded, want it refused")
+	}
+}
@@ -0,0 +1,141 @@
+// Package recovery turns handler panics into 500 responses. The panic
+// value and stack are logged as structured fields with the request ID the
+// client receives, so a failure a user reports can be found, and are
+// optionally sent to an error tracker such as Sentry.
+package recovery
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/light-bringer/cert-tasks/internal/logging"
+	"github.com/light-bringer/cert-tasks/internal/requestid"
+)
+
+// maxFrames caps the stack frames recorded for a panic
+const maxFrames = 64
+
+// Panic describes a recovered panic and the request it happened in
+type Panic struct {
+	Value     string
+	Stack     []Frame
+	Time      time.Time
+	RequestID string
+	Method    string
+	Path      string
+
+	// Route is the matched route pattern, e.g. "/tasks/{id}"
+	Route string
+}
+
+// Frame is a stack frame, innermost first in Panic.Stack
+type Frame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+// Reporter sends panics to an error tracker. Report is called in its own
+// goroutine after the response is written, with a context that outlives
+// the request.
+type Reporter interface {
+	Report(ctx context.Context, p *Panic) error
+}
+
+// Middleware recovers panics in later handlers: it logs the panic and
+// stack, hands them to reporter if it is not nil, and answers with
+// respond, which should write a 500 carrying the request ID.
+// http.ErrAbortHandler is passed on, as net/http uses it to abort a
+// response on purpose.
+func Middleware(reporter Reporter, respond http.HandlerFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+
+				p := &Panic{
+					Value:     fmt.Sprint(v),
+					Stack:     stack(),
+					Time:      time.Now().UTC(),
+					RequestID: requestid.FromRequest(r),
+					Method:    r.Method,
+					Path:      r.URL.Path,
+					Route:     routePattern(r),
+				}
+				logger := logging.FromContext(r.Context())
+				logger.Error("panic recovered",
+					slog.String("panic", p.Value),
+					slog.String("route", p.Route),
+					slog.Any("stack", p.Stack))
+
+				// A WebSocket connection has been hijacked; there is
+				// nothing to respond on
+				if r.Header.Get("Upgrade") == "" {
+					respond(w, r)
+				}
+
+				if reporter != nil {
+					ctx := context.WithoutCancel(r.Context())
+					go func() {
+						if err := reporter.Report(ctx, p); err != nil {
+							logger.Warn("reporting panic failed", slog.Any("error", err))
+						}
+					}()
+				}
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// stack returns the frames of the panicking goroutine from where it
+// panicked outwards. It must be called from the deferred function.
+func stack() []Frame {
+	pcs := make([]uintptr, maxFrames+16)
+	n := runtime.Callers(1, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	// Skip this function, the deferred one and the runtime's panic
+	// machinery, up to the frame that panicked
+	var all []Frame
+	panicAt := -1
+	for {
+		f, more := frames.Next()
+		if f.Function == "runtime.gopanic" {
+			panicAt = len(all)
+		}
+		all = append(all, Frame{Function: f.Function, File: f.File, Line: f.Line})
+		if !more {
+			break
+		}
+	}
+	out := all[panicAt+1:]
+	for len(out) > 0 && strings.HasPrefix(out[0].Function, "runtime.") {
+		out = out[1:] // e.g. runtime.panicmem for a nil dereference
+	}
+	if len(out) > maxFrames {
+		out = out[:maxFrames]
+	}
+	return out
+}
+
+// routePattern returns the route chi matched so far, or "" outside chi
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		return rctx.RoutePattern()
+	}
+	return ""
+}
@@ -0,0 +1,169 @@
+package recovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/light-bringer/cert-tasks/internal/logging"
+	"github.com/light-bringer/cert-tasks/internal/requestid"
+)
+
+// channelReporter hands reported panics to the test
+type channelReporter chan *Panic
+
+func (c channelReporter) Report(ctx context.Context, p *Panic) error {
+	c <- p
+	return nil
+}
+
+func respond500(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write([]byte(`{"code":"internal_error","request_id":"` + requestid.FromRequest(r) + `"}`))
+}
+
+// lookUp dereferences a nil map entry, so the stack has a frame of its own
+func lookUp(tasks map[int]*struct{ Title string }) string {
+	return tasks[1].Title
+}
+
+func TestMiddleware(t *testing.T) {
+	var logs bytes.Buffer
+	reports := make(channelReporter, 1)
+
+	r := chi.NewRouter()
+	r.Use(requestid.Middleware(nil))
+	r.Use(logging.Middleware(logging.New(&logs, slog.LevelInfo), nil))
+	r.Use(Middleware(reports, respond500))
+	r.Get("/tasks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		lookUp(nil)
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/tasks/1", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %v, want %v", rec.Code, http.StatusInternalServerError)
+	}
+	id := rec.Header().Get(requestid.Header)
+	if id == "" || !strings.Contains(rec.Body.String(), id) {
+		t.Errorf("body %s does not carry the request ID %q", rec.Body, id)
+	}
+
+	// The panic is logged with its stack, innermost frame first
+	var logged struct {
+		Msg       string  `json:"msg"`
+		RequestID string  `json:"request_id"`
+		Panic     string  `json:"panic"`
+		Route     string  `json:"route"`
+		Stack     []Frame `json:"stack"`
+	}
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		if strings.Contains(line, "panic recovered") {
+			json.Unmarshal([]byte(line), &logged)
+		}
+	}
+	if logged.Msg == "" {
+		t.Fatalf("no panic record in the log:\n%s", logs.String())
+	}
+	if logged.RequestID != id || logged.Route != "/tasks/{id}" || !strings.Contains(logged.Panic, "nil pointer") {
+		t.Errorf("panic record = %+v", logged)
+	}
+	if len(logged.Stack) == 0 || !strings.HasSuffix(logged.Stack[0].Function, "recovery.lookUp") {
+		t.Errorf("stack starts at %+v, want lookUp", logged.Stack)
+	}
+
+	select {
+	case p := <-reports:
+		if p.RequestID != id || p.Method != "GET" || p.Path != "/tasks/1" || p.Route != "/tasks/{id}" {
+			t.Errorf("reported %+v", p)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the panic was not reported")
+	}
+}
+
+func TestMiddleware_AbortHandler(t *testing.T) {
+	h := Middleware(nil, respond500)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler passed on", p)
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/tasks", nil))
+}
+
+func TestSentry(t *testing.T) {
+	type received struct {
+		path, auth string
+		lines      []string
+	}
+	got := make(chan received, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- received{r.URL.Path, r.Header.Get("X-Sentry-Auth"), strings.Split(strings.TrimSpace(string(body)), "\n")}
+	}))
+	defer srv.Close()
+
+	dsn := strings.Replace(srv.URL, "://", "://public@", 1) + "/sentry/42"
+	s, err := NewSentry(dsn, "staging", time.Second)
+	if err != nil {
+		t.Fatalf("NewSentry: %v", err)
+	}
+	err = s.Report(context.Background(), &Panic{
+		Value:     "boom",
+		RequestID: "req-1",
+		Method:    "GET",
+		Path:      "/tasks/1",
+		Route:     "/tasks/{id}",
+		Time:      time.Now(),
+		Stack: []Frame{
+			{Function: "github.com/light-bringer/cert-tasks/internal/handlers.(*TaskHandler).GetTask", File: "task_handler.go", Line: 10},
+			{Function: "net/http.HandlerFunc.ServeHTTP", File: "server.go", Line: 20},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Report: %v", err)
+	}
+
+	r := <-got
+	if r.path != "/sentry/api/42/envelope/" {
+		t.Errorf("path = %q, want /sentry/api/42/envelope/", r.path)
+	}
+	if !strings.Contains(r.auth, "sentry_key=public") {
+		t.Errorf("X-Sentry-Auth = %q, want the DSN's key", r.auth)
+	}
+	if len(r.lines) != 3 {
+		t.Fatalf("envelope has %d lines, want 3", len(r.lines))
+	}
+	var event sentryEvent
+	if err := json.Unmarshal([]byte(r.lines[2]), &event); err != nil {
+		t.Fatalf("event: %v", err)
+	}
+	if event.Environment != "staging" || event.Tags["request_id"] != "req-1" || event.Transaction != "GET /tasks/{id}" {
+		t.Errorf("event = %+v", event)
+	}
+	frames := event.Exception.Values[0].Stacktrace.Frames
+	if len(frames) != 2 || frames[0].InApp || !frames[1].InApp {
+		t.Errorf("frames = %+v, want outermost first with the handler in-app", frames)
+	}
+}
+
+func TestNewSentry_InvalidDSN(t *testing.T) {
+	for _, dsn := range []string{"not a url", "https://o0.ingest.sentry.io/0", "https://key@o0.ingest.sentry.io", "ftp://key@host/1"} {
+		if _, err := NewSentry(dsn, "", time.Second); err == nil {
+			t.Errorf("NewSentry(%q) succeeded, want an error", dsn)
+		}
+	}
+}
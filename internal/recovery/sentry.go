@@ -0,0 +1,157 @@
+package recovery
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/outbound"
+	"github.com/light-bringer/cert-tasks/internal/version"
+)
+
+// Sentry reports panics to Sentry, or a compatible service such as
+// GlitchTip, as error events sent to the envelope endpoint
+type Sentry struct {
+	client      *http.Client
+	dsn         string
+	endpoint    string
+	auth        string
+	environment string
+}
+
+// NewSentry reports to the project named by dsn, of the form
+// https://<public key>@<host>/<project ID>. environment, if set, tags
+// events, e.g. "production". Each report is bounded by budget.
+func NewSentry(dsn, environment string, budget time.Duration) (*Sentry, error) {
+	u, err := url.Parse(dsn)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || u.User == nil || u.User.Username() == "" {
+		return nil, fmt.Errorf("%q is not a Sentry DSN, e.g. https://key@o0.ingest.sentry.io/0", dsn)
+	}
+	// The project ID is the last path segment; anything before it
+	// prefixes the API path
+	prefix, project := "", strings.Trim(u.Path, "/")
+	if i := strings.LastIndexByte(project, '/'); i >= 0 {
+		prefix, project = "/"+project[:i], project[i+1:]
+	}
+	if project == "" {
+		return nil, fmt.Errorf("Sentry DSN %q does not end in a project ID", dsn)
+	}
+
+	return &Sentry{
+		client:   outbound.Client(budget),
+		dsn:      dsn,
+		endpoint: fmt.Sprintf("%s://%s%s/api/%s/envelope/", u.Scheme, u.Host, prefix, project),
+		auth: fmt.Sprintf("Sentry sentry_version=7, sentry_client=cert-tasks/%s, sentry_key=%s",
+			version.Version, u.User.Username()),
+		environment: environment,
+	}, nil
+}
+
+// sentryEvent is the subset of the Sentry event payload the server fills
+type sentryEvent struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   time.Time         `json:"timestamp"`
+	Platform    string            `json:"platform"`
+	Level       string            `json:"level"`
+	Release     string            `json:"release,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	Transaction string            `json:"transaction,omitempty"`
+	Tags        map[string]string `json:"tags"`
+	Request     sentryRequest     `json:"request"`
+	Exception   struct {
+		Values []sentryException `json:"values"`
+	} `json:"exception"`
+}
+
+type sentryRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+}
+
+type sentryException struct {
+	Type       string `json:"type"`
+	Value      string `json:"value"`
+	Stacktrace struct {
+		Frames []sentryFrame `json:"frames"`
+	} `json:"stacktrace"`
+}
+
+type sentryFrame struct {
+	Function string `json:"function"`
+	AbsPath  string `json:"abs_path"`
+	Lineno   int    `json:"lineno"`
+	InApp    bool   `json:"in_app"`
+}
+
+// modulePrefix marks the server's own frames as in-app, so Sentry groups
+// panics by them rather than by library frames
+const modulePrefix = "github.com/light-bringer/cert-tasks/"
+
+// Report sends p as an error event
+func (s *Sentry) Report(ctx context.Context, p *Panic) error {
+	var id [16]byte
+	rand.Read(id[:])
+
+	event := sentryEvent{
+		EventID:     hex.EncodeToString(id[:]),
+		Timestamp:   p.Time,
+		Platform:    "go",
+		Level:       "error",
+		Release:     version.Version,
+		Environment: s.environment,
+		Transaction: p.Method + " " + p.Route,
+		Tags:        map[string]string{"request_id": p.RequestID},
+		Request:     sentryRequest{Method: p.Method, URL: p.Path},
+	}
+	exc := sentryException{Type: "panic", Value: p.Value}
+	// Sentry lists frames outermost first
+	for i := len(p.Stack) - 1; i >= 0; i-- {
+		f := p.Stack[i]
+		exc.Stacktrace.Frames = append(exc.Stacktrace.Frames, sentryFrame{
+			Function: f.Function,
+			AbsPath:  f.File,
+			Lineno:   f.Line,
+			InApp:    strings.HasPrefix(f.Function, modulePrefix),
+		})
+	}
+	event.Exception.Values = []sentryException{exc}
+
+	// An envelope is newline-separated JSON: its header, then each item's
+	// header and payload
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	enc.Encode(map[string]any{"event_id": event.EventID, "dsn": s.dsn, "sent_at": time.Now().UTC()})
+	enc.Encode(map[string]any{"type": "event", "length": len(payload)})
+	body.Write(payload)
+	body.WriteByte('\n')
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", s.auth)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending to Sentry: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("sending to Sentry: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+// Package redact masks personal data before it leaves the server in a
+// log record, a debug body dump or a redacted export. The sensitive fields
+// are configured by name (log.redact): a name matches JSON members, query
+// parameters and log attributes alike, so "description" masks a task's
+// description wherever it would be written.
+//
+// The server wraps its request logger in Handler, so a handler cannot log
+// a sensitive attribute unmasked, however it logs it.
+package redact
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+)
+
+// Mask replaces every sensitive value
+const Mask = "[REDACTED]"
+
+// DefaultFields are masked unless configured otherwise
+var DefaultFields = []string{"description"}
+
+// searchQuery is the query parameter searching task titles and
+// descriptions, which is masked along with either
+const searchQuery = "q"
+
+// Redactor masks the values of the fields it was created with. A nil
+// Redactor masks nothing.
+type Redactor struct {
+	fields map[string]bool
+	member *regexp.Regexp
+}
+
+// New creates a Redactor masking fields
+func New(fields []string) *Redactor {
+	r := &Redactor{fields: make(map[string]bool)}
+	var quoted []string
+	for _, f := range fields {
+		if f = strings.TrimSpace(f); f == "" || r.fields[f] {
+			continue
+		}
+		r.fields[f] = true
+		quoted = append(quoted, regexp.QuoteMeta(f))
+	}
+	if r.fields["title"] || r.fields["description"] {
+		r.fields[searchQuery] = true
+	}
+	if len(quoted) > 0 {
+		slices.Sort(quoted)
+		r.member = regexp.MustCompile(`("(?:` + strings.Join(quoted, "|") + `)"\s*:\s*)"(?:[^"\\]|\\.)*"?`)
+	}
+	return r
+}
+
+// Fields returns the masked fields, sorted
+func (r *Redactor) Fields() []string {
+	if r == nil {
+		return nil
+	}
+	out := make([]string, 0, len(r.fields))
+	for f := range r.fields {
+		out = append(out, f)
+	}
+	slices.Sort(out)
+	return out
+}
+
+// Sensitive reports whether the value of name is masked
+func (r *Redactor) Sensitive(name string) bool {
+	return r != nil && r.fields[name]
+}
+
+// JSON masks the string values of sensitive members in a JSON body, which
+// may be truncated
+func (r *Redactor) JSON(body string) string {
+	if r == nil || r.member == nil {
+		return body
+	}
+	return r.member.ReplaceAllString(body, `$1"`+Mask+`"`)
+}
+
+// Query masks the values of sensitive parameters in q, in place
+func (r *Redactor) Query(q url.Values) url.Values {
+	for name := range q {
+		if r.Sensitive(name) {
+			q[name] = []string{Mask}
+		}
+	}
+	return q
+}
+
+// Task returns a copy of t with its sensitive fields masked, or t itself
+// if none are
+func (r *Redactor) Task(t *models.Task) *models.Task {
+	if !r.Sensitive("title") && !r.Sensitive("description") && !r.Sensitive("owner_id") {
+		return t
+	}
+	masked := *t
+	if r.Sensitive("title") && masked.Title != "" {
+		masked.Title = Mask
+	}
+	if r.Sensitive("description") && masked.Description != "" {
+		masked.Description = Mask
+	}
+	if r.Sensitive("owner_id") && masked.OwnerID != "" {
+		masked.OwnerID = Mask
+	}
+	return &masked
+}
+
+// Attr masks a as a log attribute: the whole value if its key is
+// sensitive, otherwise the sensitive members within a group or a value
+// logged with slog.Any
+func (r *Redactor) Attr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	if r.Sensitive(a.Key) {
+		return slog.String(a.Key, Mask)
+	}
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		attrs := a.Value.Group()
+		masked := make([]slog.Attr, len(attrs))
+		for i, child := range attrs {
+			masked[i] = r.Attr(child)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(masked...)}
+	case slog.KindAny:
+		if _, isErr := a.Value.Any().(error); isErr || r == nil {
+			return a
+		}
+		// Structs and maps are masked through their JSON form
+		data, err := json.Marshal(a.Value.Any())
+		if err != nil || !r.contains(data) {
+			return a
+		}
+		var v any
+		if err := json.Unmarshal(data, &v); err != nil {
+			return a
+		}
+		return slog.Any(a.Key, r.mask(v))
+	}
+	return a
+}
+
+// contains reports whether data, encoded JSON, may have a sensitive member
+func (r *Redactor) contains(data []byte) bool {
+	for f := range r.fields {
+		if strings.Contains(string(data), `"`+f+`"`) {
+			return true
+		}
+	}
+	return false
+}
+
+// mask masks the sensitive members in decoded JSON
+func (r *Redactor) mask(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			if r.Sensitive(k) {
+				v[k] = Mask
+			} else {
+				v[k] = r.mask(child)
+			}
+		}
+	case []any:
+		for i, child := range v {
+			v[i] = r.mask(child)
+		}
+	}
+	return v
+}
+
+// handler masks the attributes of every record before passing it on
+type handler struct {
+	next slog.Handler
+	r    *Redactor
+}
+
+// Handler wraps next so that sensitive attributes are masked
+func Handler(next slog.Handler, r *Redactor) slog.Handler {
+	if r == nil || len(r.fields) == 0 {
+		return next
+	}
+	return &handler{next: next, r: r}
+}
+
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *handler) Handle(ctx context.Context, record slog.Record) error {
+	masked := slog.NewRecord(record.Time, record.Level, record.Message, record.PC)
+	record.Attrs(func(a slog.Attr) bool {
+		masked.AddAttrs(h.r.Attr(a))
+		return true
+	})
+	return h.next.Handle(ctx, masked)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	masked := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		masked[i] = h.r.Attr(a)
+	}
+	return &handler{next: h.next.WithAttrs(masked), r: h.r}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{next: h.next.WithGroup(name), r: h.r}
+}
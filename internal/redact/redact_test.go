@@ -0,0 +1,95 @@
+package redact
+
+import (
+	"bytes"
+	"log/slog"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+)
+
+func TestRedactor_JSON(t *testing.T) {
+	r := New([]string{"description", "owner_id"})
+	tests := []struct {
+		body string
+		want string
+	}{
+		{`{"title":"Call","description":"Jane, 555-0100"}`, `{"title":"Call","description":"[REDACTED]"}`},
+		{`[{"owner_id": "jane@example.com"}]`, `[{"owner_id": "[REDACTED]"}]`},
+		{`{"description":"say \"hi\"","id":1}`, `{"description":"[REDACTED]","id":1}`},
+		{`{"descriptions":"kept"}`, `{"descriptions":"kept"}`},
+		// Cut off mid-value, as in a truncated body
+		{`{"description":"Jane`, `{"description":"[REDACTED]"`},
+	}
+	for _, tt := range tests {
+		if got := r.JSON(tt.body); got != tt.want {
+			t.Errorf("JSON(%s) = %s, want %s", tt.body, got, tt.want)
+		}
+	}
+	if got := New(nil).JSON(tests[0].body); got != tests[0].body {
+		t.Errorf("JSON() without fields = %s", got)
+	}
+}
+
+func TestRedactor_Query(t *testing.T) {
+	q := url.Values{"q": {"jane"}, "status": {"todo"}, "description": {"x"}}
+	New(DefaultFields).Query(q)
+	if q.Get("q") != Mask || q.Get("description") != Mask || q.Get("status") != "todo" {
+		t.Errorf("Query() = %v, want q and description masked", q)
+	}
+
+	// Searches cover titles and descriptions only
+	q = url.Values{"q": {"jane"}}
+	New([]string{"owner_id"}).Query(q)
+	if q.Get("q") != "jane" {
+		t.Errorf("Query() masked q without a searched field: %v", q)
+	}
+}
+
+func TestRedactor_Task(t *testing.T) {
+	task := &models.Task{ID: 1, Title: "Call Jane", Description: "555-0100", OwnerID: "jane"}
+	got := New([]string{"title", "owner_id"}).Task(task)
+	if got.Title != Mask || got.OwnerID != Mask || got.Description != "555-0100" || got.ID != 1 {
+		t.Errorf("Task() = %+v", got)
+	}
+	if task.Title != "Call Jane" {
+		t.Error("Task() changed the original")
+	}
+	if New(nil).Task(task) != task {
+		t.Error("Task() without fields copied the task")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	var buf bytes.Buffer
+	r := New(DefaultFields)
+	logger := slog.New(Handler(slog.NewJSONHandler(&buf, nil), r)).With(slog.String("q", "jane"))
+
+	type violation struct {
+		Field       string `json:"field"`
+		Description string `json:"description"`
+	}
+	logger.Info("task rejected",
+		slog.String("description", "Jane, 555-0100"),
+		slog.Group("request", slog.String("description", "Jane again"), slog.Int("id", 7)),
+		slog.Any("violations", []violation{{Field: "title", Description: "Jane's number"}}),
+		slog.String("title", "Call back"),
+	)
+	out := buf.String()
+	if strings.Contains(out, "Jane") || strings.Contains(out, "jane") {
+		t.Errorf("log = %s, want the description and query masked", out)
+	}
+	for _, kept := range []string{`"id":7`, `"field":"title"`, `"title":"Call back"`} {
+		if !strings.Contains(out, kept) {
+			t.Errorf("log = %s, want %s kept", out, kept)
+		}
+	}
+
+	// Nothing to mask leaves the handler unwrapped
+	inner := slog.NewJSONHandler(&buf, nil)
+	if Handler(inner, New(nil)) != slog.Handler(inner) {
+		t.Error("Handler() wrapped a handler with nothing to mask")
+	}
+}
@@ -0,0 +1,175 @@
+// Package reports aggregates tasks for the report endpoints: into day or
+// week buckets for the burndown and throughput reports, and by owner for
+// the workload report. The bucketed reports work from the tasks as they
+// are now: created_at and completed_at place each task in time, so deleted
+// tasks are not counted and a reopened task counts as open from its
+// creation until it is completed again.
+package reports
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+)
+
+// MaxBuckets caps the buckets of one report, a year of days
+const MaxBuckets = 366
+
+// Bounds returns the boundaries of the buckets covering from to to: the
+// start of each bucket, then the end of the last. Days start at midnight
+// and weeks on Monday, in the time zone of from, so the first bucket may
+// start before from and the last end after to.
+func Bounds(from, to time.Time, bucket string) ([]time.Time, error) {
+	if !to.After(from) {
+		return nil, errors.New("to must be after from")
+	}
+
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+	days := 1
+	switch bucket {
+	case models.BucketDay:
+	case models.BucketWeek:
+		days = 7
+		// Weeks start on Monday
+		start = start.AddDate(0, 0, -(int(start.Weekday())+6)%7)
+	default:
+		return nil, fmt.Errorf("bucket must be %q or %q", models.BucketDay, models.BucketWeek)
+	}
+
+	bounds := []time.Time{start}
+	for bounds[len(bounds)-1].Before(to) {
+		if len(bounds) > MaxBuckets {
+			return nil, fmt.Errorf("from and to span more than %d buckets", MaxBuckets)
+		}
+		// AddDate keeps days starting at midnight across DST changes
+		bounds = append(bounds, bounds[len(bounds)-1].AddDate(0, 0, days))
+	}
+	return bounds, nil
+}
+
+// Burndown counts the open and done tasks, and adds up their estimates,
+// at the end of each bucket
+func Burndown(tasks []*models.Task, bounds []time.Time) []models.BurndownPoint {
+	n := len(bounds) - 1
+	created := make([]tally, n+1)
+	completed := make([]tally, n+1)
+	for _, task := range tasks {
+		created[ending(bounds, task.CreatedAt)].add(task)
+		if at, ok := completedAt(task); ok {
+			completed[ending(bounds, at)].add(task)
+		}
+	}
+
+	points := make([]models.BurndownPoint, n)
+	var total, done tally
+	for i := range points {
+		total.tasks += created[i].tasks
+		total.estimate += created[i].estimate
+		done.tasks += completed[i].tasks
+		done.estimate += completed[i].estimate
+		points[i] = models.BurndownPoint{
+			At:           bounds[i+1],
+			Open:         total.tasks - done.tasks,
+			Done:         done.tasks,
+			OpenEstimate: total.estimate - done.estimate,
+			DoneEstimate: done.estimate,
+		}
+	}
+	return points
+}
+
+// tally counts tasks and adds up their estimates
+type tally struct {
+	tasks, estimate int
+}
+
+func (t *tally) add(task *models.Task) {
+	t.tasks++
+	t.estimate += task.Estimate
+}
+
+// Throughput counts the tasks created and completed in each bucket, and
+// adds up their estimates
+func Throughput(tasks []*models.Task, bounds []time.Time) []models.ThroughputBucket {
+	n := len(bounds) - 1
+	buckets := make([]models.ThroughputBucket, n)
+	for i := range buckets {
+		buckets[i] = models.ThroughputBucket{Start: bounds[i], End: bounds[i+1]}
+	}
+	for _, task := range tasks {
+		if i := ending(bounds, task.CreatedAt); i < n && !task.CreatedAt.Before(bounds[0]) {
+			buckets[i].Created++
+			buckets[i].CreatedEstimate += task.Estimate
+		}
+		if at, ok := completedAt(task); ok {
+			if i := ending(bounds, at); i < n && !at.Before(bounds[0]) {
+				buckets[i].Completed++
+				buckets[i].CompletedEstimate += task.Estimate
+			}
+		}
+	}
+	return buckets
+}
+
+// Workload counts the open tasks and adds up their estimates, in total and
+// by owner. Owners are ordered by estimated effort, then by open tasks,
+// most first; tasks without an owner are grouped under an empty OwnerID.
+func Workload(tasks []*models.Task) models.WorkloadReport {
+	var report models.WorkloadReport
+	byOwner := make(map[string]*models.WorkloadGroup)
+	for _, task := range tasks {
+		if task.Status == models.StatusDone {
+			continue
+		}
+		group, ok := byOwner[task.OwnerID]
+		if !ok {
+			group = &models.WorkloadGroup{OwnerID: task.OwnerID}
+			byOwner[task.OwnerID] = group
+		}
+		for _, g := range []*models.WorkloadGroup{&report.WorkloadGroup, group} {
+			g.Open++
+			g.Estimate += task.Estimate
+			if task.Estimate == 0 {
+				g.Unestimated++
+			}
+		}
+	}
+
+	report.Owners = make([]models.WorkloadGroup, 0, len(byOwner))
+	for _, group := range byOwner {
+		report.Owners = append(report.Owners, *group)
+	}
+	slices.SortFunc(report.Owners, func(a, b models.WorkloadGroup) int {
+		return cmp.Or(
+			cmp.Compare(b.Estimate, a.Estimate),
+			cmp.Compare(b.Open, a.Open),
+			strings.Compare(a.OwnerID, b.OwnerID),
+		)
+	})
+	return report
+}
+
+// ending returns the index of the first bucket that ends after t, or the
+// number of buckets if none does
+func ending(bounds []time.Time, t time.Time) int {
+	return sort.Search(len(bounds)-1, func(i int) bool { return bounds[i+1].After(t) })
+}
+
+// completedAt returns when a done task was completed. Tasks done before
+// completion times were kept count as completed when last updated, as in
+// the calendar feed.
+func completedAt(task *models.Task) (time.Time, bool) {
+	switch {
+	case task.Status != models.StatusDone:
+		return time.Time{}, false
+	case task.CompletedAt == nil:
+		return task.UpdatedAt, true
+	}
+	return *task.CompletedAt, true
+}
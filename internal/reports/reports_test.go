@@ -0,0 +1,112 @@
+package reports
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+)
+
+func TestBounds(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no time zone database")
+	}
+	// Thursday afternoon to the next Tuesday, over the end of summer time
+	from := time.Date(2026, 10, 22, 15, 0, 0, 0, berlin)
+	to := time.Date(2026, 10, 27, 9, 0, 0, 0, berlin)
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 0, 0, 0, 0, berlin) }
+
+	days, err := Bounds(from, to, models.BucketDay)
+	if err != nil {
+		t.Fatalf("Bounds(day) error = %v", err)
+	}
+	want := []time.Time{day(22), day(23), day(24), day(25), day(26), day(27), day(28)}
+	if !slices.EqualFunc(days, want, time.Time.Equal) {
+		t.Errorf("Bounds(day) = %v, want %v", days, want)
+	}
+
+	weeks, err := Bounds(from, to, models.BucketWeek)
+	if err != nil {
+		t.Fatalf("Bounds(week) error = %v", err)
+	}
+	want = []time.Time{day(19), day(26), time.Date(2026, 11, 2, 0, 0, 0, 0, berlin)}
+	if !slices.EqualFunc(weeks, want, time.Time.Equal) {
+		t.Errorf("Bounds(week) = %v, want %v", weeks, want)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		from, to time.Time
+		bucket   string
+	}{
+		{"reversed", to, from, models.BucketDay},
+		{"bucket", from, to, "month"},
+		{"too long", from, from.AddDate(2, 0, 0), models.BucketDay},
+	} {
+		if _, err := Bounds(tt.from, tt.to, tt.bucket); err == nil {
+			t.Errorf("Bounds(%s) succeeded", tt.name)
+		}
+	}
+}
+
+func TestBurndownAndThroughput(t *testing.T) {
+	day := func(d, h int) time.Time { return time.Date(2026, 10, d, h, 0, 0, 0, time.UTC) }
+	completed := func(d, h int) *time.Time { t := day(d, h); return &t }
+	tasks := []*models.Task{
+		// Before the range: open at its start, done on the 2nd
+		{Status: models.StatusDone, CreatedAt: day(1, 9), CompletedAt: completed(2, 10), Estimate: 1},
+		{Status: models.StatusTodo, CreatedAt: day(2, 9), Estimate: 2},
+		{Status: models.StatusDone, CreatedAt: day(3, 9), CompletedAt: completed(3, 17), Estimate: 4},
+		// Done before completion times were kept: its update counts
+		{Status: models.StatusDone, CreatedAt: day(2, 12), UpdatedAt: day(4, 8)},
+		// After the range
+		{Status: models.StatusTodo, CreatedAt: day(9, 9)},
+	}
+	bounds := []time.Time{day(2, 0), day(3, 0), day(4, 0), day(5, 0)}
+
+	points := Burndown(tasks, bounds)
+	wantPoints := []models.BurndownPoint{
+		{At: day(3, 0), Open: 2, Done: 1, OpenEstimate: 2, DoneEstimate: 1},
+		{At: day(4, 0), Open: 2, Done: 2, OpenEstimate: 2, DoneEstimate: 5},
+		{At: day(5, 0), Open: 1, Done: 3, OpenEstimate: 2, DoneEstimate: 5},
+	}
+	if !slices.Equal(points, wantPoints) {
+		t.Errorf("Burndown() = %+v, want %+v", points, wantPoints)
+	}
+
+	buckets := Throughput(tasks, bounds)
+	wantBuckets := []models.ThroughputBucket{
+		{Start: day(2, 0), End: day(3, 0), Created: 2, Completed: 1, CreatedEstimate: 2, CompletedEstimate: 1},
+		{Start: day(3, 0), End: day(4, 0), Created: 1, Completed: 1, CreatedEstimate: 4, CompletedEstimate: 4},
+		{Start: day(4, 0), End: day(5, 0), Created: 0, Completed: 1},
+	}
+	if !slices.Equal(buckets, wantBuckets) {
+		t.Errorf("Throughput() = %+v, want %+v", buckets, wantBuckets)
+	}
+}
+
+func TestWorkload(t *testing.T) {
+	tasks := []*models.Task{
+		{OwnerID: "ann", Status: models.StatusTodo, Estimate: 3},
+		{OwnerID: "ann", Status: models.StatusTodo},
+		{OwnerID: "ann", Status: models.StatusDone, Estimate: 8},
+		{OwnerID: "bob", Status: models.StatusTodo, Estimate: 5},
+		{Status: models.StatusTodo},
+	}
+
+	report := Workload(tasks)
+	want := models.WorkloadGroup{Open: 4, Estimate: 8, Unestimated: 2}
+	if report.WorkloadGroup != want {
+		t.Errorf("Workload() totals = %+v, want %+v", report.WorkloadGroup, want)
+	}
+	wantOwners := []models.WorkloadGroup{
+		{OwnerID: "bob", Open: 1, Estimate: 5},
+		{OwnerID: "ann", Open: 2, Estimate: 3, Unestimated: 1},
+		{OwnerID: "", Open: 1, Unestimated: 1},
+	}
+	if !slices.Equal(report.Owners, wantOwners) {
+		t.Errorf("Workload() owners = %+v, want %+v", report.Owners, wantOwners)
+	}
+}
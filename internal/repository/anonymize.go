@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// Anonymizer is implemented by repositories that can detach tasks from the
+// person who created them, for erasing a user's data while the work stays
+// in its workspace
+type Anonymizer interface {
+	// AnonymizeOwner clears the owner and description of every task in
+	// scope that owner created, drops their revisions, which hold earlier
+	// descriptions, and returns the IDs of the tasks changed. An empty
+	// owner changes nothing.
+	AnonymizeOwner(ctx context.Context, owner string) ([]int64, error)
+}
+
+// AnonymizeOwner clears the owner and description of owner's tasks. Hooks
+// are not called: the tasks keep their place and status, and nobody else
+// is told about the change.
+func (r *MemoryRepository) AnonymizeOwner(ctx context.Context, owner string) ([]int64, error) {
+	if owner == "" {
+		return nil, nil
+	}
+	s := scopeFrom(ctx)
+	s.owner = owner
+	now := time.Now()
+	var ids []int64
+	for _, sh := range r.shards {
+		sh.mu.Lock()
+		for id, task := range sh.tasks {
+			if !s.allows(task) {
+				continue
+			}
+			c := copyTask(task)
+			c.OwnerID = ""
+			c.Description = ""
+			c.UpdatedAt = now
+			sh.tasks[id] = c
+			delete(sh.revisions, id)
+			ids = append(ids, id)
+		}
+		sh.mu.Unlock()
+	}
+	if len(ids) > 0 {
+		r.touch(now)
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// AnonymizeOwner clears the owner and description of owner's tasks and
+// persists the snapshot, or queues it with write-behind
+func (r *FileRepository) AnonymizeOwner(ctx context.Context, owner string) ([]int64, error) {
+	ids, err := r.MemoryRepository.AnonymizeOwner(ctx, owner)
+	if err != nil || len(ids) == 0 {
+		return ids, err
+	}
+	return ids, r.saveTasks()
+}
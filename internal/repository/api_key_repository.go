@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+)
+
+// ErrAPIKeyNotFound is returned when no API key has the given hash
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// APIKeyRepository stores API keys by the hash of their secret
+type APIKeyRepository interface {
+	// CreateAPIKey stores a key and returns it with generated ID
+	CreateAPIKey(ctx context.Context, key *models.APIKey) (*models.APIKey, error)
+
+	// GetAPIKeyByHash returns the key whose secret hashes to hash, or
+	// ErrAPIKeyNotFound
+	GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error)
+
+	// ListAPIKeys returns every key ordered by ID
+	ListAPIKeys(ctx context.Context) ([]*models.APIKey, error)
+
+	// RotateAPIKey replaces the secret of the key with the given ID, given
+	// as its prefix and hash, or returns ErrAPIKeyNotFound. The old secret
+	// stops working at once.
+	RotateAPIKey(ctx context.Context, id int64, prefix, hash string) (*models.APIKey, error)
+}
+
+// apiKeys holds the API keys of a MemoryRepository. It is kept apart from
+// the tasks so that Reset leaves credentials in place.
+type apiKeys struct {
+	byHash map[string]*models.APIKey
+	lastID int64
+}
+
+// CreateAPIKey stores a key with generated ID and creation time
+func (r *MemoryRepository) CreateAPIKey(ctx context.Context, key *models.APIKey) (*models.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.keys.byHash == nil {
+		r.keys.byHash = make(map[string]*models.APIKey)
+	}
+	r.keys.lastID++
+
+	stored := *key
+	stored.ID = r.keys.lastID
+	stored.CreatedAt = time.Now()
+	r.keys.byHash[stored.Hash] = &stored
+
+	created := stored
+	return &created, nil
+}
+
+// GetAPIKeyByHash returns the key with the given secret hash
+func (r *MemoryRepository) GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, ok := r.keys.byHash[hash]
+	if !ok {
+		return nil, ErrAPIKeyNotFound
+	}
+	found := *key
+	return &found, nil
+}
+
+// ListAPIKeys returns copies of every key ordered by ID
+func (r *MemoryRepository) ListAPIKeys(ctx context.Context) ([]*models.APIKey, error) {
+	return r.APIKeySnapshot(), nil
+}
+
+// RotateAPIKey replaces a key's secret, keeping its ID, name, scope and
+// workspace
+func (r *MemoryRepository) RotateAPIKey(ctx context.Context, id int64, prefix, hash string) (*models.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for oldHash, key := range r.keys.byHash {
+		if key.ID != id {
+			continue
+		}
+		delete(r.keys.byHash, oldHash)
+		key.Prefix = prefix
+		key.Hash = hash
+		r.keys.byHash[hash] = key
+
+		rotated := *key
+		return &rotated, nil
+	}
+	return nil, ErrAPIKeyNotFound
+}
+
+// APIKeySnapshot returns copies of every API key in ID order, for
+// persisting the repository
+func (r *MemoryRepository) APIKeySnapshot() []*models.APIKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	keys := make([]*models.APIKey, 0, len(r.keys.byHash))
+	for _, key := range r.keys.byHash {
+		k := *key
+		keys = append(keys, &k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys
+}
+
+// RestoreAPIKeys replaces the stored API keys
+func (r *MemoryRepository) RestoreAPIKeys(keys []*models.APIKey) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.keys = apiKeys{byHash: make(map[string]*models.APIKey, len(keys))}
+	for _, key := range keys {
+		r.keys.byHash[key.Hash] = key
+		r.keys.lastID = max(r.keys.lastID, key.ID)
+	}
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync/atomic"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+)
+
+// Dump is the whole contents of a repository, read at one instant, with
+// the API key hashes and webhook secrets that their JSON forms leave out
+type Dump struct {
+	LastID     int64
+	Tasks      []*models.Task
+	Revisions  []*models.TaskRevision
+	Workspaces []*models.Workspace
+	APIKeys    []*models.APIKey
+	Webhooks   []*models.Webhook
+}
+
+// Dumper is implemented by backends whose whole contents can be read and
+// replaced at once, as backups need. Replace bypasses hooks: no events are
+// sent for what it changes.
+type Dumper interface {
+	// Dump returns a consistent copy of everything stored
+	Dump(ctx context.Context) (*Dump, error)
+
+	// Replace replaces everything stored with d
+	Replace(ctx context.Context, d *Dump) error
+}
+
+// Dump returns a copy of everything stored. Writes wait while it is taken,
+// so the copy is consistent.
+func (r *MemoryRepository) Dump(ctx context.Context) (*Dump, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	r.rlockAll()
+	defer r.runlockAll()
+
+	d := &Dump{
+		LastID:     atomic.LoadInt64(&r.nextID),
+		Tasks:      make([]*models.Task, 0, len(r.order)),
+		Workspaces: r.workspaceSnapshot(),
+		APIKeys:    make([]*models.APIKey, 0, len(r.keys.byHash)),
+		Webhooks:   make([]*models.Webhook, 0, len(r.hooks.byID)),
+	}
+	for _, id := range r.order {
+		sh := r.shardFor(id)
+		d.Tasks = append(d.Tasks, copyTask(sh.tasks[id]))
+		for _, rev := range sh.revisions[id] {
+			d.Revisions = append(d.Revisions, copyRevision(rev))
+		}
+	}
+	for _, key := range r.keys.byHash {
+		k := *key
+		d.APIKeys = append(d.APIKeys, &k)
+	}
+	sort.Slice(d.APIKeys, func(i, j int) bool { return d.APIKeys[i].ID < d.APIKeys[j].ID })
+	for _, hook := range r.hooks.byID {
+		d.Webhooks = append(d.Webhooks, copyWebhook(hook))
+	}
+	sort.Slice(d.Webhooks, func(i, j int) bool { return d.Webhooks[i].ID < d.Webhooks[j].ID })
+	return d, nil
+}
+
+// Replace replaces everything stored with d, which it takes ownership of
+func (r *MemoryRepository) Replace(ctx context.Context, d *Dump) error {
+	r.Restore(d.Tasks, d.LastID)
+	r.RestoreRevisions(d.Revisions)
+	r.RestoreWorkspaces(d.Workspaces)
+	r.RestoreAPIKeys(d.APIKeys)
+	r.RestoreWebhooks(d.Webhooks)
+	return nil
+}
+
+// Replace replaces everything stored with d and saves the snapshot
+func (r *FileRepository) Replace(ctx context.Context, d *Dump) error {
+	if err := r.MemoryRepository.Replace(ctx, d); err != nil {
+		return err
+	}
+	return r.save()
+}
@@ -0,0 +1,450 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/light-bringer/cert-tasks/internal/encryption"
+	"github.com/light-bringer/cert-tasks/internal/models"
+)
+
+// SnapshotVersion is the format version of the snapshots this release
+// writes. Snapshots from before versioning are version 0; older versions
+// are read and upgraded in memory, and Migrate rewrites the file.
+const SnapshotVersion = 1
+
+// ErrSnapshotTooNew is returned for a snapshot written by a newer release,
+// which this one cannot read without losing data
+var ErrSnapshotTooNew = errors.New("snapshot format is newer than this release supports")
+
+// ErrSnapshotEncrypted is returned for an encrypted snapshot opened without
+// a key
+var ErrSnapshotEncrypted = errors.New("snapshot is encrypted and no key is configured")
+
+// snapshot is the on-disk format of a FileRepository
+type snapshot struct {
+	Version int            `json:"version"`
+	LastID  int64          `json:"last_id"`
+	Tasks   []*models.Task `json:"tasks"`
+	APIKeys []storedAPIKey `json:"api_keys,omitempty"`
+
+	Workspaces []*models.Workspace `json:"workspaces,omitempty"`
+	Webhooks   []storedWebhook     `json:"webhooks,omitempty"`
+
+	Revisions []*models.TaskRevision `json:"revisions,omitempty"`
+}
+
+// storedAPIKey is an API key in a snapshot, including the secret hash that
+// models.APIKey leaves out of its JSON form
+type storedAPIKey struct {
+	*models.APIKey
+	Hash string `json:"hash"`
+}
+
+// storedWebhook is a webhook in a snapshot, including the signing secret
+// that models.Webhook leaves out of its JSON form
+type storedWebhook struct {
+	*models.Webhook
+	Secret string `json:"secret"`
+}
+
+// FileRepository is a MemoryRepository persisted to a JSON snapshot file.
+// Every successful write rewrites the snapshot atomically (write to a
+// temporary file, then rename), so a crash never leaves a torn file. With
+// a keyring the snapshot is encrypted.
+type FileRepository struct {
+	*MemoryRepository
+	path string
+	mu   sync.Mutex // serializes snapshot writes
+
+	// fileVersion is the format version of the file on disk
+	fileVersion int
+
+	// keys, when set, encrypt the snapshot. unsealed is set while the
+	// file on disk is not sealed with the primary key.
+	keys     *encryption.Keyring
+	unsealed bool
+
+	// writeBehind, when set, batches the saves of task writes
+	writeBehind *writeBehind
+}
+
+// NewFileRepository opens the snapshot at path, creating it if missing
+func NewFileRepository(path string, opts ...MemoryOption) (*FileRepository, error) {
+	return NewEncryptedFileRepository(path, nil, opts...)
+}
+
+// NewEncryptedFileRepository opens the snapshot at path like
+// NewFileRepository, encrypting it with keys. A plaintext snapshot, or one
+// sealed with a previous key, is rewritten sealed with the primary key
+// straight away, unless it needs migrating first; Migrate seals it then.
+func NewEncryptedFileRepository(path string, keys *encryption.Keyring, opts ...MemoryOption) (*FileRepository, error) {
+	r := &FileRepository{
+		MemoryRepository: NewMemoryRepository(opts...),
+		path:             path,
+		keys:             keys,
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, r.save()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening snapshot: %w", err)
+	}
+	defer f.Close()
+
+	if err := r.Load(f); err != nil {
+		return nil, fmt.Errorf("loading snapshot %s: %w", path, err)
+	}
+	if r.unsealed && r.fileVersion == SnapshotVersion {
+		if err := r.save(); err != nil {
+			return nil, fmt.Errorf("encrypting snapshot %s: %w", path, err)
+		}
+	}
+
+	return r, nil
+}
+
+// ReadSnapshot loads the snapshot at path without creating, re-sealing or
+// migrating it, for inspecting a snapshot a server may have open. Nothing
+// should be written through the returned repository.
+func ReadSnapshot(path string, keys *encryption.Keyring) (*FileRepository, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening snapshot: %w", err)
+	}
+	defer f.Close()
+
+	r := &FileRepository{MemoryRepository: NewMemoryRepository(), path: path, keys: keys}
+	if err := r.Load(f); err != nil {
+		return nil, fmt.Errorf("loading snapshot %s: %w", path, err)
+	}
+	return r, nil
+}
+
+// Encrypted reports whether the snapshot is encrypted
+func (r *FileRepository) Encrypted() bool {
+	return r.keys != nil
+}
+
+// Path returns the snapshot file location
+func (r *FileRepository) Path() string {
+	return r.path
+}
+
+// Load replaces the repository contents with a snapshot read from src,
+// decrypting it if it is sealed
+func (r *FileRepository) Load(src io.Reader) error {
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return err
+	}
+	unsealed := r.keys != nil
+	if encryption.Sealed(data) {
+		if r.keys == nil {
+			return ErrSnapshotEncrypted
+		}
+		unsealed = r.keys.Rotated(data)
+		if data, err = r.keys.Open(data); err != nil {
+			return err
+		}
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return err
+	}
+	if snap.Version > SnapshotVersion {
+		return fmt.Errorf("%w: version %d, up to %d supported", ErrSnapshotTooNew, snap.Version, SnapshotVersion)
+	}
+	r.mu.Lock()
+	r.fileVersion = snap.Version
+	r.unsealed = unsealed
+	r.mu.Unlock()
+	r.MemoryRepository.Restore(snap.Tasks, snap.LastID)
+
+	keys := make([]*models.APIKey, len(snap.APIKeys))
+	for i, stored := range snap.APIKeys {
+		stored.APIKey.Hash = stored.Hash
+		keys[i] = stored.APIKey
+	}
+	r.MemoryRepository.RestoreAPIKeys(keys)
+	r.MemoryRepository.RestoreWorkspaces(snap.Workspaces)
+
+	hooks := make([]*models.Webhook, len(snap.Webhooks))
+	for i, stored := range snap.Webhooks {
+		stored.Webhook.Secret = stored.Secret
+		hooks[i] = stored.Webhook
+	}
+	r.MemoryRepository.RestoreWebhooks(hooks)
+	r.MemoryRepository.RestoreRevisions(snap.Revisions)
+	return nil
+}
+
+// WriteSnapshot writes the current contents in snapshot format to dst,
+// sealed if the repository is encrypted
+func (r *FileRepository) WriteSnapshot(dst io.Writer) error {
+	tasks, lastID := r.MemoryRepository.Snapshot()
+	snap := snapshot{
+		Version:    SnapshotVersion,
+		LastID:     lastID,
+		Tasks:      tasks,
+		Workspaces: r.MemoryRepository.WorkspaceSnapshot(),
+		Revisions:  r.MemoryRepository.RevisionSnapshot(),
+	}
+	for _, key := range r.MemoryRepository.APIKeySnapshot() {
+		snap.APIKeys = append(snap.APIKeys, storedAPIKey{APIKey: key, Hash: key.Hash})
+	}
+	for _, hook := range r.MemoryRepository.WebhookSnapshot() {
+		snap.Webhooks = append(snap.Webhooks, storedWebhook{Webhook: hook, Secret: hook.Secret})
+	}
+
+	if r.keys == nil {
+		enc := json.NewEncoder(dst)
+		enc.SetIndent("", "  ")
+		return enc.Encode(snap)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(snap); err != nil {
+		return err
+	}
+	sealed, err := r.keys.Seal(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = dst.Write(sealed)
+	return err
+}
+
+// FileVersion returns the format version of the snapshot file, which is
+// below SnapshotVersion until Migrate or the first write rewrites it
+func (r *FileRepository) FileVersion() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.fileVersion
+}
+
+// Migrate rewrites the snapshot file in the current format
+func (r *FileRepository) Migrate() error {
+	return r.save()
+}
+
+// Create creates a task and persists the snapshot, or queues it with
+// write-behind
+func (r *FileRepository) Create(ctx context.Context, task *models.Task) (*models.Task, error) {
+	created, err := r.MemoryRepository.Create(ctx, task)
+	if err != nil {
+		return nil, err
+	}
+	return created, r.saveTasks()
+}
+
+// Update updates a task and persists the snapshot, or queues it with
+// write-behind
+func (r *FileRepository) Update(ctx context.Context, id int64, task *models.Task) (*models.Task, error) {
+	updated, err := r.MemoryRepository.Update(ctx, id, task)
+	if err != nil {
+		return nil, err
+	}
+	return updated, r.saveTasks()
+}
+
+// SetStatus changes the status of a task and persists the snapshot, or
+// queues it with write-behind
+func (r *FileRepository) SetStatus(ctx context.Context, id int64, status models.TaskStatus) (*models.Task, error) {
+	updated, err := r.MemoryRepository.SetStatus(ctx, id, status)
+	if err != nil {
+		return nil, err
+	}
+	return updated, r.saveTasks()
+}
+
+// Delete deletes a task and persists the snapshot, or queues it with
+// write-behind
+func (r *FileRepository) Delete(ctx context.Context, id int64) error {
+	if err := r.MemoryRepository.Delete(ctx, id); err != nil {
+		return err
+	}
+	return r.saveTasks()
+}
+
+// Undelete puts deleted tasks back and persists the snapshot, or queues it
+// with write-behind
+func (r *FileRepository) Undelete(ctx context.Context, tasks []*models.Task) ([]*models.Task, error) {
+	restored, err := r.MemoryRepository.Undelete(ctx, tasks)
+	if err != nil {
+		return nil, err
+	}
+	return restored, r.saveTasks()
+}
+
+// WithTx runs fn as a transaction and persists the snapshot once, after it
+// commits
+func (r *FileRepository) WithTx(ctx context.Context, fn func(tx TaskRepository) error) error {
+	if err := r.MemoryRepository.WithTx(ctx, fn); err != nil {
+		return err
+	}
+	return r.save()
+}
+
+// AddLink links two tasks and persists the snapshot, or queues it with
+// write-behind
+func (r *FileRepository) AddLink(ctx context.Context, id int64, link models.TaskLink) (*models.Task, error) {
+	updated, err := r.MemoryRepository.AddLink(ctx, id, link)
+	if err != nil {
+		return nil, err
+	}
+	return updated, r.saveTasks()
+}
+
+// CreateAPIKey stores an API key and persists the snapshot
+func (r *FileRepository) CreateAPIKey(ctx context.Context, key *models.APIKey) (*models.APIKey, error) {
+	created, err := r.MemoryRepository.CreateAPIKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return created, r.save()
+}
+
+// RotateAPIKey replaces an API key's secret and persists the snapshot
+func (r *FileRepository) RotateAPIKey(ctx context.Context, id int64, prefix, hash string) (*models.APIKey, error) {
+	rotated, err := r.MemoryRepository.RotateAPIKey(ctx, id, prefix, hash)
+	if err != nil {
+		return nil, err
+	}
+	return rotated, r.save()
+}
+
+// CreateWebhook stores a webhook and persists the snapshot
+func (r *FileRepository) CreateWebhook(ctx context.Context, hook *models.Webhook) (*models.Webhook, error) {
+	created, err := r.MemoryRepository.CreateWebhook(ctx, hook)
+	if err != nil {
+		return nil, err
+	}
+	return created, r.save()
+}
+
+// DeleteWebhook deletes a webhook and persists the snapshot
+func (r *FileRepository) DeleteWebhook(ctx context.Context, id int64) error {
+	if err := r.MemoryRepository.DeleteWebhook(ctx, id); err != nil {
+		return err
+	}
+	return r.save()
+}
+
+// CreateWorkspace stores a workspace and persists the snapshot
+func (r *FileRepository) CreateWorkspace(ctx context.Context, ws *models.Workspace) (*models.Workspace, error) {
+	created, err := r.MemoryRepository.CreateWorkspace(ctx, ws)
+	if err != nil {
+		return nil, err
+	}
+	return created, r.save()
+}
+
+// UpdateWorkspace renames a workspace and persists the snapshot
+func (r *FileRepository) UpdateWorkspace(ctx context.Context, id string, ws *models.Workspace) (*models.Workspace, error) {
+	updated, err := r.MemoryRepository.UpdateWorkspace(ctx, id, ws)
+	if err != nil {
+		return nil, err
+	}
+	return updated, r.save()
+}
+
+// DeleteWorkspace deletes a workspace and persists the snapshot
+func (r *FileRepository) DeleteWorkspace(ctx context.Context, id string) error {
+	if err := r.MemoryRepository.DeleteWorkspace(ctx, id); err != nil {
+		return err
+	}
+	return r.save()
+}
+
+// Stats counts the stored records and reports the snapshot file size
+func (r *FileRepository) Stats(ctx context.Context) (Stats, error) {
+	stats, err := r.MemoryRepository.Stats(ctx)
+	if err != nil {
+		return Stats{}, err
+	}
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return Stats{}, fmt.Errorf("reading snapshot size: %w", err)
+	}
+	stats.SnapshotBytes = info.Size()
+	return stats, nil
+}
+
+// Compact compacts the in-memory data, removes temporary files left by
+// interrupted snapshot writes and rewrites the snapshot
+func (r *FileRepository) Compact(ctx context.Context) (CompactResult, error) {
+	result, err := r.MemoryRepository.Compact(ctx)
+	if err != nil {
+		return CompactResult{}, err
+	}
+
+	// Hold the write lock so no temporary file of a save in progress is
+	// mistaken for a stale one
+	r.mu.Lock()
+	stale, _ := filepath.Glob(filepath.Join(filepath.Dir(r.path), filepath.Base(r.path)+".tmp-*"))
+	for _, name := range stale {
+		if err := os.Remove(name); err == nil {
+			result.StaleFiles++
+		}
+	}
+	r.mu.Unlock()
+
+	if err := r.save(); err != nil {
+		return result, err
+	}
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return result, fmt.Errorf("reading snapshot size: %w", err)
+	}
+	result.SnapshotBytes = info.Size()
+	return result, nil
+}
+
+// Reset removes every task and persists the empty snapshot
+func (r *FileRepository) Reset() {
+	r.MemoryRepository.Reset()
+	r.save()
+}
+
+// save atomically rewrites the snapshot file
+func (r *FileRepository) save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("saving snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := r.WriteSnapshot(tmp); err != nil {
+		tmp.Close()
+		return fmt.Errorf("saving snapshot: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("saving snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("saving snapshot: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), r.path); err != nil {
+		return fmt.Errorf("saving snapshot: %w", err)
+	}
+	r.fileVersion = SnapshotVersion
+	r.unsealed = false
+	return nil
+}
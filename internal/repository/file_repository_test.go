@@ -0,0 +1,391 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/encryption"
+	"github.com/light-bringer/cert-tasks/internal/models"
+)
+
+func TestFileRepository_PersistsAcrossRestarts(t *testing.T) {
+	ctx := context.Background()
+
+	path := filepath.Join(t.TempDir(), "tasks.json")
+
+	repo, err := NewFileRepository(path)
+	if err != nil {
+		t.Fatalf("NewFileRepository() error = %v", err)
+	}
+
+	task1, _ := repo.Create(ctx, &models.Task{Title: "Task 1"})
+	task2, _ := repo.Create(ctx, &models.Task{Title: "Task 2"})
+	task3, _ := repo.Create(ctx, &models.Task{Title: "Task 3"})
+	repo.Update(ctx, task1.ID, &models.Task{Title: "Task 1 updated", Status: models.StatusDone})
+	repo.AddLink(ctx, task1.ID, models.TaskLink{Type: models.LinkRelatesTo, TaskID: task2.ID})
+	repo.Delete(ctx, task3.ID)
+
+	reopened, err := NewFileRepository(path)
+	if err != nil {
+		t.Fatalf("reopening error = %v", err)
+	}
+
+	tasks, _ := reopened.List(ctx, ListOptions{})
+	if len(tasks) != 2 {
+		t.Fatalf("got %d tasks, want 2", len(tasks))
+	}
+	if revs, _ := reopened.ListRevisions(ctx, task1.ID); len(revs) != 1 || revs[0].Title != "Task 1" {
+		t.Errorf("revisions = %+v, want the original Task 1", revs)
+	}
+	if tasks[0].Title != "Task 1 updated" || tasks[0].Status != models.StatusDone {
+		t.Errorf("task 1 = %+v, want updated title and done status", tasks[0])
+	}
+	if len(tasks[0].Links) != 1 || tasks[0].Links[0].TaskID != task2.ID {
+		t.Errorf("task 1 links = %+v, want link to task 2", tasks[0].Links)
+	}
+
+	// IDs of deleted tasks must not be reused after a restart
+	created, _ := reopened.Create(ctx, &models.Task{Title: "Task 4"})
+	if created.ID != task3.ID+1 {
+		t.Errorf("ID = %v, want %v", created.ID, task3.ID+1)
+	}
+}
+
+func TestFileRepository_PersistsAPIKeys(t *testing.T) {
+	ctx := context.Background()
+
+	path := filepath.Join(t.TempDir(), "tasks.json")
+
+	repo, err := NewFileRepository(path)
+	if err != nil {
+		t.Fatalf("NewFileRepository() error = %v", err)
+	}
+	repo.CreateAPIKey(ctx, &models.APIKey{Name: "ci", Scope: models.ScopeRead, Hash: "hash-1"})
+
+	// Keys survive demo-style resets and restarts
+	repo.Reset()
+	reopened, err := NewFileRepository(path)
+	if err != nil {
+		t.Fatalf("reopening error = %v", err)
+	}
+
+	key, err := reopened.GetAPIKeyByHash(ctx, "hash-1")
+	if err != nil {
+		t.Fatalf("GetAPIKeyByHash() error = %v", err)
+	}
+	if key.Name != "ci" || key.Scope != models.ScopeRead || key.ID != 1 {
+		t.Errorf("key = %+v, want ci/read with ID 1", key)
+	}
+	if _, err := reopened.GetAPIKeyByHash(ctx, "hash-2"); err != ErrAPIKeyNotFound {
+		t.Errorf("unknown hash error = %v, want ErrAPIKeyNotFound", err)
+	}
+
+	created, _ := reopened.CreateAPIKey(ctx, &models.APIKey{Name: "deploy", Scope: models.ScopeReadWrite, Hash: "hash-2"})
+	if created.ID != 2 {
+		t.Errorf("ID = %v, want 2", created.ID)
+	}
+}
+
+func TestFileRepository_PersistsWorkspacesAndWebhooks(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "tasks.json")
+
+	repo, err := NewFileRepository(path)
+	if err != nil {
+		t.Fatalf("NewFileRepository() error = %v", err)
+	}
+	repo.CreateWorkspace(ctx, &models.Workspace{ID: "acme", Name: "Acme"})
+	repo.Create(WithWorkspace(ctx, "acme"), &models.Task{Title: "Acme task"})
+	repo.CreateWebhook(WithWorkspace(ctx, "acme"), &models.Webhook{URL: "https://example.com/hook", Secret: "whsec_1"})
+
+	reopened, err := NewFileRepository(path)
+	if err != nil {
+		t.Fatalf("reopening error = %v", err)
+	}
+	if ws, err := reopened.GetWorkspace(ctx, "acme"); err != nil || ws.Name != "Acme" {
+		t.Errorf("GetWorkspace(acme) = %+v, %v; want Acme", ws, err)
+	}
+	if all, _ := reopened.GetAll(WithWorkspace(ctx, "acme")); len(all) != 1 {
+		t.Errorf("acme tasks after reopening = %d, want 1", len(all))
+	}
+	if hook, err := reopened.GetWebhook(ctx, 1); err != nil || hook.Secret != "whsec_1" || hook.WorkspaceID != "acme" {
+		t.Errorf("GetWebhook(1) = %+v, %v; want the acme webhook with its secret", hook, err)
+	}
+	if _, err := reopened.GetWorkspace(ctx, models.DefaultWorkspace); err != nil {
+		t.Errorf("GetWorkspace(default) error = %v", err)
+	}
+}
+
+func TestFileRepository_LegacyTasksJoinDefaultWorkspace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	os.WriteFile(path, []byte(`{"last_id":1,"tasks":[{"id":1,"title":"Old","status":"todo"}]}`), 0o600)
+
+	repo, err := NewFileRepository(path)
+	if err != nil {
+		t.Fatalf("NewFileRepository() error = %v", err)
+	}
+	task, err := repo.GetByID(WithWorkspace(context.Background(), models.DefaultWorkspace), 1)
+	if err != nil || task.WorkspaceID != models.DefaultWorkspace {
+		t.Errorf("GetByID() = %+v, %v; want the task in the default workspace", task, err)
+	}
+}
+
+func TestFileRepository_Migrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	os.WriteFile(path, []byte(`{"last_id":1,"tasks":[{"id":1,"title":"Old","status":"todo"}]}`), 0o600)
+
+	repo, err := NewFileRepository(path)
+	if err != nil {
+		t.Fatalf("NewFileRepository() error = %v", err)
+	}
+	if v := repo.FileVersion(); v != 0 {
+		t.Errorf("FileVersion() = %d, want 0 for an unversioned snapshot", v)
+	}
+	if err := repo.Migrate(); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	if v := repo.FileVersion(); v != SnapshotVersion {
+		t.Errorf("FileVersion() after Migrate = %d, want %d", v, SnapshotVersion)
+	}
+	reopened, err := NewFileRepository(path)
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	if v := reopened.FileVersion(); v != SnapshotVersion {
+		t.Errorf("FileVersion() after reopening = %d, want %d", v, SnapshotVersion)
+	}
+
+	os.WriteFile(path, []byte(`{"version":99,"tasks":[]}`), 0o600)
+	if _, err := NewFileRepository(path); !errors.Is(err, ErrSnapshotTooNew) {
+		t.Errorf("NewFileRepository(version 99) error = %v, want ErrSnapshotTooNew", err)
+	}
+}
+
+func TestReadSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	if _, err := ReadSnapshot(path, nil); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ReadSnapshot(missing) error = %v, want ErrNotExist", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Error("ReadSnapshot created the missing snapshot")
+	}
+
+	old := []byte(`{"last_id":1,"tasks":[{"id":1,"title":"Old","status":"todo"}]}`)
+	os.WriteFile(path, old, 0o600)
+	key, _ := encryption.NewKeyring(bytes.Repeat([]byte{1}, encryption.KeySize))
+	repo, err := ReadSnapshot(path, key)
+	if err != nil {
+		t.Fatalf("ReadSnapshot() error = %v", err)
+	}
+	if v := repo.FileVersion(); v != 0 {
+		t.Errorf("FileVersion() = %d, want 0", v)
+	}
+	if tasks, _ := repo.List(context.Background(), ListOptions{}); len(tasks) != 1 {
+		t.Errorf("got %d tasks, want 1", len(tasks))
+	}
+	if data, _ := os.ReadFile(path); !bytes.Equal(data, old) {
+		t.Errorf("snapshot = %s, want it neither migrated nor sealed", data)
+	}
+}
+
+func TestFileRepository_Encrypted(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	os.WriteFile(path, []byte(`{"version":1,"last_id":1,"tasks":[{"id":1,"title":"Plaintext","status":"todo"}]}`), 0o600)
+
+	oldKey, _ := encryption.NewKeyring(bytes.Repeat([]byte{1}, encryption.KeySize))
+	repo, err := NewEncryptedFileRepository(path, oldKey)
+	if err != nil {
+		t.Fatalf("NewEncryptedFileRepository() error = %v", err)
+	}
+	repo.Create(ctx, &models.Task{Title: "Secret"})
+
+	// The plaintext snapshot was sealed on opening
+	data, _ := os.ReadFile(path)
+	if !encryption.Sealed(data) || bytes.Contains(data, []byte("Plaintext")) || bytes.Contains(data, []byte("Secret")) {
+		t.Fatalf("snapshot = %q, want it sealed", data)
+	}
+	if _, err := NewFileRepository(path); !errors.Is(err, ErrSnapshotEncrypted) {
+		t.Errorf("NewFileRepository() without a key error = %v, want ErrSnapshotEncrypted", err)
+	}
+
+	// A rotated keyring reads it and seals it again with the new key
+	newKey, _ := encryption.NewKeyring(bytes.Repeat([]byte{2}, encryption.KeySize), bytes.Repeat([]byte{1}, encryption.KeySize))
+	reopened, err := NewEncryptedFileRepository(path, newKey)
+	if err != nil {
+		t.Fatalf("reopening with a rotated key: %v", err)
+	}
+	if tasks, _ := reopened.List(ctx, ListOptions{}); len(tasks) != 2 || tasks[1].Title != "Secret" {
+		t.Errorf("tasks = %+v, want both tasks", tasks)
+	}
+	if data, _ := os.ReadFile(path); newKey.Rotated(data) {
+		t.Error("snapshot still sealed with the previous key")
+	}
+	if _, err := NewEncryptedFileRepository(path, oldKey); !errors.Is(err, encryption.ErrUnknownKey) {
+		t.Errorf("reopening with the previous key alone error = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestFileRepository_CorruptSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	os.WriteFile(path, []byte("{not json"), 0o600)
+
+	if _, err := NewFileRepository(path); err == nil {
+		t.Error("expected error for corrupt snapshot")
+	}
+}
+
+func TestFileRepository_Compact(t *testing.T) {
+	ctx := context.Background()
+
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	repo, err := NewFileRepository(path)
+	if err != nil {
+		t.Fatalf("NewFileRepository() error = %v", err)
+	}
+	repo.Create(ctx, &models.Task{Title: "Kept"})
+	key, _ := repo.CreateAPIKey(ctx, &models.APIKey{Name: "ci", Scope: models.ScopeRead, Hash: "old"})
+
+	// A snapshot write interrupted by a crash
+	if err := os.WriteFile(path+".tmp-123", []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := repo.Compact(ctx)
+	if err != nil {
+		t.Fatalf("Compact() error = %v", err)
+	}
+	if result.StaleFiles != 1 || result.SnapshotBytes == 0 {
+		t.Errorf("Compact() = %+v, want 1 stale file and the snapshot size", result)
+	}
+	if _, err := os.Stat(path + ".tmp-123"); !os.IsNotExist(err) {
+		t.Errorf("stale file still present: %v", err)
+	}
+	if stats, _ := repo.Stats(ctx); stats.Tasks != 1 || stats.SnapshotBytes != result.SnapshotBytes {
+		t.Errorf("Stats() = %+v", stats)
+	}
+
+	// Rotations are persisted
+	repo.RotateAPIKey(ctx, key.ID, "ctk_new", "new")
+	reopened, err := NewFileRepository(path)
+	if err != nil {
+		t.Fatalf("reopening error = %v", err)
+	}
+	if _, err := reopened.GetAPIKeyByHash(ctx, "new"); err != nil {
+		t.Errorf("rotated key not persisted: %v", err)
+	}
+}
+
+func TestFileRepository_WithTx(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	repo, err := NewFileRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = repo.WithTx(ctx, func(tx TaskRepository) error {
+		a, _ := tx.Create(ctx, &models.Task{Title: "a"})
+		b, _ := tx.Create(ctx, &models.Task{Title: "b"})
+		_, err := tx.AddLink(ctx, a.ID, models.TaskLink{Type: models.LinkCausedBy, TaskID: b.ID})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WithTx error = %v", err)
+	}
+
+	reopened, err := NewFileRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks, _ := reopened.List(ctx, ListOptions{})
+	if len(tasks) != 2 || len(tasks[0].Links) != 1 {
+		t.Errorf("reopened tasks = %+v, want both tasks and the link", tasks)
+	}
+}
+
+func TestFileRepository_WriteBehind(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	repo, err := NewFileRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := func() int {
+		t.Helper()
+		reopened, err := NewFileRepository(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, _ := reopened.Count(ctx, TaskFilter{})
+		return n
+	}
+
+	repo.EnableWriteBehind(time.Hour, 3)
+	repo.Create(ctx, &models.Task{Title: "Task 1"})
+	repo.Create(ctx, &models.Task{Title: "Task 2"})
+	if n := saved(); n != 0 {
+		t.Fatalf("%d tasks saved before the batch was due", n)
+	}
+
+	// The write that fills the batch saves it
+	repo.Create(ctx, &models.Task{Title: "Task 3"})
+	if n := saved(); n != 3 {
+		t.Fatalf("%d tasks saved after a full batch, want 3", n)
+	}
+
+	repo.Delete(ctx, 1)
+	if err := repo.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if n := saved(); n != 2 {
+		t.Fatalf("%d tasks saved after Flush, want 2", n)
+	}
+
+	// A due batch is saved in the background
+	repo.EnableWriteBehind(10*time.Millisecond, 100)
+	repo.Create(ctx, &models.Task{Title: "Task 4"})
+	deadline := time.Now().Add(time.Second)
+	for saved() != 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("queued write not saved within a second")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// BenchmarkFileRepository_Create compares saving on every create with
+// batching the saves
+func BenchmarkFileRepository_Create(b *testing.B) {
+	for _, writeBehind := range []time.Duration{0, 50 * time.Millisecond} {
+		b.Run(fmt.Sprintf("write_behind=%s", writeBehind), func(b *testing.B) {
+			ctx := context.Background()
+			repo, err := NewFileRepository(filepath.Join(b.TempDir(), "tasks.json"))
+			if err != nil {
+				b.Fatal(err)
+			}
+			if writeBehind > 0 {
+				repo.EnableWriteBehind(writeBehind, 1000)
+			}
+			task := &models.Task{Title: "Ingested"}
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := repo.Create(ctx, task); err != nil {
+						b.Error(err)
+					}
+				}
+			})
+			if err := repo.Flush(); err != nil {
+				b.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+)
+
+// Hooks are called around each change a HookedRepository makes, whatever
+// the backend. Before hooks run first and can reject the change by
+// returning an error, which the caller gets instead. After hooks run once
+// the change is stored and must not block. Embed NopHooks to implement
+// only some of them.
+type Hooks interface {
+	// BeforeCreate is called with each task about to be created or
+	// undeleted
+	BeforeCreate(ctx context.Context, task *models.Task) error
+
+	// AfterCreate is called with each task created or undeleted
+	AfterCreate(ctx context.Context, task *models.Task)
+
+	// BeforeUpdate is called with a task as it is and as it is about to
+	// be. For a link, change is the task with the link added.
+	BeforeUpdate(ctx context.Context, before, change *models.Task) error
+
+	// AfterUpdate is called with a task as it was and as it is now
+	AfterUpdate(ctx context.Context, before, after *models.Task)
+
+	// BeforeDelete is called with a task about to be deleted
+	BeforeDelete(ctx context.Context, task *models.Task) error
+
+	// AfterDelete is called with a deleted task as it was
+	AfterDelete(ctx context.Context, task *models.Task)
+}
+
+// NopHooks implements Hooks by doing nothing
+type NopHooks struct{}
+
+func (NopHooks) BeforeCreate(context.Context, *models.Task) error               { return nil }
+func (NopHooks) AfterCreate(context.Context, *models.Task)                      {}
+func (NopHooks) BeforeUpdate(context.Context, *models.Task, *models.Task) error { return nil }
+func (NopHooks) AfterUpdate(context.Context, *models.Task, *models.Task)        {}
+func (NopHooks) BeforeDelete(context.Context, *models.Task) error               { return nil }
+func (NopHooks) AfterDelete(context.Context, *models.Task)                      {}
+
+// HookedRepository decorates a TaskRepository and calls each of its Hooks
+// in turn around every change. Changes that change nothing, such as
+// setting a task's status to the one it has, call no hooks.
+type HookedRepository struct {
+	TaskRepository
+	hooks []Hooks
+}
+
+// NewHookedRepository wraps repo so that changes call hooks
+func NewHookedRepository(repo TaskRepository, hooks ...Hooks) *HookedRepository {
+	return &HookedRepository{TaskRepository: repo, hooks: hooks}
+}
+
+// Create creates a task unless a BeforeCreate hook rejects it
+func (r *HookedRepository) Create(ctx context.Context, task *models.Task) (*models.Task, error) {
+	for _, h := range r.hooks {
+		if err := h.BeforeCreate(ctx, task); err != nil {
+			return nil, err
+		}
+	}
+	created, err := r.TaskRepository.Create(ctx, task)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range r.hooks {
+		h.AfterCreate(ctx, created)
+	}
+	return created, nil
+}
+
+// Update updates a task unless a BeforeUpdate hook rejects it
+func (r *HookedRepository) Update(ctx context.Context, id int64, task *models.Task) (*models.Task, error) {
+	before, err := r.snapshot(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.beforeUpdate(ctx, before, task); err != nil {
+		return nil, err
+	}
+	updated, err := r.TaskRepository.Update(ctx, id, task)
+	if err != nil {
+		return nil, err
+	}
+	r.afterUpdate(ctx, before, updated)
+	return updated, nil
+}
+
+// SetStatus changes the status of a task unless a BeforeUpdate hook
+// rejects it
+func (r *HookedRepository) SetStatus(ctx context.Context, id int64, status models.TaskStatus) (*models.Task, error) {
+	before, err := r.snapshot(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if before.Status == status {
+		return r.TaskRepository.SetStatus(ctx, id, status)
+	}
+	change := *before
+	change.Status = status
+	if err := r.beforeUpdate(ctx, before, &change); err != nil {
+		return nil, err
+	}
+	updated, err := r.TaskRepository.SetStatus(ctx, id, status)
+	if err != nil {
+		return nil, err
+	}
+	r.afterUpdate(ctx, before, updated)
+	return updated, nil
+}
+
+// AddLink links two tasks unless a BeforeUpdate hook rejects it
+func (r *HookedRepository) AddLink(ctx context.Context, id int64, link models.TaskLink) (*models.Task, error) {
+	before, err := r.snapshot(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	change := *before
+	change.Links = append(change.Links[:len(change.Links):len(change.Links)], link)
+	if err := r.beforeUpdate(ctx, before, &change); err != nil {
+		return nil, err
+	}
+	updated, err := r.TaskRepository.AddLink(ctx, id, link)
+	if err != nil {
+		return nil, err
+	}
+	r.afterUpdate(ctx, before, updated)
+	return updated, nil
+}
+
+// Delete deletes a task unless a BeforeDelete hook rejects it
+func (r *HookedRepository) Delete(ctx context.Context, id int64) error {
+	before, err := r.snapshot(ctx, id)
+	if err != nil {
+		return err
+	}
+	for _, h := range r.hooks {
+		if err := h.BeforeDelete(ctx, before); err != nil {
+			return err
+		}
+	}
+	if err := r.TaskRepository.Delete(ctx, id); err != nil {
+		return err
+	}
+	for _, h := range r.hooks {
+		h.AfterDelete(ctx, before)
+	}
+	return nil
+}
+
+// Undelete puts deleted tasks back unless a BeforeCreate hook rejects any
+// of them, calling the create hooks for each
+func (r *HookedRepository) Undelete(ctx context.Context, tasks []*models.Task) ([]*models.Task, error) {
+	for _, task := range tasks {
+		for _, h := range r.hooks {
+			if err := h.BeforeCreate(ctx, task); err != nil {
+				return nil, err
+			}
+		}
+	}
+	restored, err := r.TaskRepository.Undelete(ctx, tasks)
+	if err != nil {
+		return nil, err
+	}
+	for _, task := range restored {
+		for _, h := range r.hooks {
+			h.AfterCreate(ctx, task)
+		}
+	}
+	return restored, nil
+}
+
+// WithTx runs fn as a transaction. Before hooks run as changes are made
+// through tx; after hooks run once it commits, and not at all if it fails.
+func (r *HookedRepository) WithTx(ctx context.Context, fn func(tx TaskRepository) error) error {
+	deferred := &deferredHooks{hooks: r.hooks}
+	err := r.TaskRepository.WithTx(ctx, func(tx TaskRepository) error {
+		return fn(NewHookedRepository(tx, deferred))
+	})
+	if err != nil {
+		return err
+	}
+	for _, after := range deferred.after {
+		after()
+	}
+	return nil
+}
+
+// snapshot returns a copy of a task as it is before a change; the memory
+// store changes tasks in place
+func (r *HookedRepository) snapshot(ctx context.Context, id int64) (*models.Task, error) {
+	task, err := r.TaskRepository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	before := *task
+	return &before, nil
+}
+
+func (r *HookedRepository) beforeUpdate(ctx context.Context, before, change *models.Task) error {
+	for _, h := range r.hooks {
+		if err := h.BeforeUpdate(ctx, before, change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *HookedRepository) afterUpdate(ctx context.Context, before, after *models.Task) {
+	for _, h := range r.hooks {
+		h.AfterUpdate(ctx, before, after)
+	}
+}
+
+// deferredHooks runs the before hooks of a transaction as they come and
+// keeps its after hooks until it commits
+type deferredHooks struct {
+	hooks []Hooks
+	after []func()
+}
+
+func (d *deferredHooks) BeforeCreate(ctx context.Context, task *models.Task) error {
+	for _, h := range d.hooks {
+		if err := h.BeforeCreate(ctx, task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *deferredHooks) AfterCreate(ctx context.Context, task *models.Task) {
+	d.after = append(d.after, func() {
+		for _, h := range d.hooks {
+			h.AfterCreate(ctx, task)
+		}
+	})
+}
+
+func (d *deferredHooks) BeforeUpdate(ctx context.Context, before, change *models.Task) error {
+	for _, h := range d.hooks {
+		if err := h.BeforeUpdate(ctx, before, change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *deferredHooks) AfterUpdate(ctx context.Context, before, after *models.Task) {
+	d.after = append(d.after, func() {
+		for _, h := range d.hooks {
+			h.AfterUpdate(ctx, before, after)
+		}
+	})
+}
+
+func (d *deferredHooks) BeforeDelete(ctx context.Context, task *models.Task) error {
+	for _, h := range d.hooks {
+		if err := h.BeforeDelete(ctx, task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *deferredHooks) AfterDelete(ctx context.Context, task *models.Task) {
+	d.after = append(d.after, func() {
+		for _, h := range d.hooks {
+			h.AfterDelete(ctx, task)
+		}
+	})
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+)
+
+// recordingHooks records each hook call and rejects tasks titled "reject"
+type recordingHooks struct {
+	NopHooks
+	calls []string
+}
+
+var errRejected = errors.New("rejected")
+
+func (h *recordingHooks) BeforeCreate(ctx context.Context, task *models.Task) error {
+	h.calls = append(h.calls, "before create "+task.Title)
+	if task.Title == "reject" {
+		return errRejected
+	}
+	return nil
+}
+
+func (h *recordingHooks) AfterCreate(ctx context.Context, task *models.Task) {
+	h.calls = append(h.calls, "after create "+task.Title)
+}
+
+func (h *recordingHooks) BeforeUpdate(ctx context.Context, before, change *models.Task) error {
+	h.calls = append(h.calls, "before update "+before.Title+" -> "+change.Title+" "+string(change.Status))
+	if change.Title == "reject" {
+		return errRejected
+	}
+	return nil
+}
+
+func (h *recordingHooks) AfterUpdate(ctx context.Context, before, after *models.Task) {
+	h.calls = append(h.calls, "after update "+string(before.Status)+" -> "+string(after.Status))
+}
+
+func (h *recordingHooks) AfterDelete(ctx context.Context, task *models.Task) {
+	h.calls = append(h.calls, "after delete "+task.Title)
+}
+
+func TestHookedRepository(t *testing.T) {
+	ctx := context.Background()
+	hooks := &recordingHooks{}
+	repo := NewHookedRepository(NewMemoryRepository(), hooks)
+
+	if _, err := repo.Create(ctx, &models.Task{Title: "reject"}); !errors.Is(err, errRejected) {
+		t.Errorf("rejected Create error = %v, want %v", err, errRejected)
+	}
+	task, _ := repo.Create(ctx, &models.Task{Title: "plan", Status: models.StatusTodo})
+	if _, err := repo.Update(ctx, task.ID, &models.Task{Title: "reject", Status: models.StatusTodo}); !errors.Is(err, errRejected) {
+		t.Errorf("rejected Update error = %v, want %v", err, errRejected)
+	}
+	repo.SetStatus(ctx, task.ID, models.StatusDone)
+	repo.SetStatus(ctx, task.ID, models.StatusDone) // unchanged: no hooks
+	repo.Delete(ctx, task.ID)
+
+	want := []string{
+		"before create reject",
+		"before create plan", "after create plan",
+		"before update plan -> reject todo",
+		"before update plan -> plan done", "after update todo -> done",
+		"after delete plan",
+	}
+	if !slices.Equal(hooks.calls, want) {
+		t.Errorf("calls = %q, want %q", hooks.calls, want)
+	}
+	if n, _ := repo.Count(ctx, TaskFilter{}); n != 0 {
+		t.Errorf("Count = %d after the rejected create, want 0", n)
+	}
+}
+
+func TestHookedRepository_WithTx(t *testing.T) {
+	ctx := context.Background()
+	hooks := &recordingHooks{}
+	repo := NewHookedRepository(NewMemoryRepository(), hooks)
+
+	err := repo.WithTx(ctx, func(tx TaskRepository) error {
+		if _, err := tx.Create(ctx, &models.Task{Title: "kept"}); err != nil {
+			return err
+		}
+		if !slices.Equal(hooks.calls, []string{"before create kept"}) {
+			t.Errorf("calls before commit = %q, want only the before hook", hooks.calls)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"before create kept", "after create kept"}; !slices.Equal(hooks.calls, want) {
+		t.Errorf("calls = %q, want %q", hooks.calls, want)
+	}
+
+	hooks.calls = nil
+	repo.WithTx(ctx, func(tx TaskRepository) error {
+		tx.Create(ctx, &models.Task{Title: "dropped"})
+		return errRejected
+	})
+	if want := []string{"before create dropped"}; !slices.Equal(hooks.calls, want) {
+		t.Errorf("calls of a rolled back transaction = %q, want %q", hooks.calls, want)
+	}
+}
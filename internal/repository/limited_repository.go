@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"sync"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+)
+
+// LimitedRepository decorates a TaskRepository and rejects creates once the
+// number of stored tasks reaches a maximum
+type LimitedRepository struct {
+	TaskRepository
+	mu       sync.Mutex
+	maxTasks int
+}
+
+// NewLimitedRepository wraps repo so that at most maxTasks tasks are stored
+func NewLimitedRepository(repo TaskRepository, maxTasks int) *LimitedRepository {
+	return &LimitedRepository{
+		TaskRepository: repo,
+		maxTasks:       maxTasks,
+	}
+}
+
+// Create creates a task unless the repository is full
+func (r *LimitedRepository) Create(ctx context.Context, task *models.Task) (*models.Task, error) {
+	// Serialize creates so concurrent requests cannot overshoot the limit
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n, err := r.TaskRepository.Count(ctx, TaskFilter{})
+	if err != nil {
+		return nil, err
+	}
+	if n >= r.maxTasks {
+		return nil, ErrTaskLimitReached
+	}
+
+	return r.TaskRepository.Create(ctx, task)
+}
+
+// Undelete puts deleted tasks back unless that would exceed the limit
+func (r *LimitedRepository) Undelete(ctx context.Context, tasks []*models.Task) ([]*models.Task, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n, err := r.TaskRepository.Count(ctx, TaskFilter{})
+	if err != nil {
+		return nil, err
+	}
+	if n+len(tasks) > r.maxTasks {
+		return nil, ErrTaskLimitReached
+	}
+
+	return r.TaskRepository.Undelete(ctx, tasks)
+}
+
+// WithTx runs fn as a transaction, with the limit applying to creates
+// made through tx
+func (r *LimitedRepository) WithTx(ctx context.Context, fn func(tx TaskRepository) error) error {
+	return r.TaskRepository.WithTx(ctx, func(tx TaskRepository) error {
+		return fn(NewLimitedRepository(tx, r.maxTasks))
+	})
+}
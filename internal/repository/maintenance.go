@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+)
+
+// Maintainer is implemented by repositories that report what they hold
+// and can compact their storage, for the admin API
+type Maintainer interface {
+	// Stats counts the stored records
+	Stats(ctx context.Context) (Stats, error)
+
+	// Compact reclaims space left by deleted records and removes data
+	// nothing refers to any more
+	Compact(ctx context.Context) (CompactResult, error)
+}
+
+// Stats counts the records in a repository
+type Stats struct {
+	Tasks      int   `json:"tasks"`
+	Workspaces int   `json:"workspaces"`
+	APIKeys    int   `json:"api_keys"`
+	Webhooks   int   `json:"webhooks"`
+	LastID     int64 `json:"last_id"`
+
+	// SnapshotBytes is the size of the snapshot file, for file storage
+	SnapshotBytes int64 `json:"snapshot_bytes,omitempty"`
+
+	// LeadTime is how long done tasks took from creation to completion,
+	// and CycleTime how long from when they were last opened, by creation
+	// or by reopening, to completion. Both are omitted until a task with a
+	// completion time is done.
+	LeadTime  *DurationStats `json:"lead_time,omitempty"`
+	CycleTime *DurationStats `json:"cycle_time,omitempty"`
+}
+
+// DurationStats aggregates durations, in seconds
+type DurationStats struct {
+	Count int     `json:"count"`
+	Avg   float64 `json:"avg_seconds"`
+	P50   float64 `json:"p50_seconds"`
+	P90   float64 `json:"p90_seconds"`
+}
+
+// CompactResult reports what a compaction removed
+type CompactResult struct {
+	// DanglingLinks counts links to tasks that no longer exist
+	DanglingLinks int `json:"dangling_links"`
+
+	// StaleFiles counts temporary snapshot files left by interrupted
+	// writes, for file storage
+	StaleFiles int `json:"stale_files"`
+
+	// SnapshotBytes is the snapshot size after compaction, for file
+	// storage
+	SnapshotBytes int64 `json:"snapshot_bytes,omitempty"`
+}
+
+// Stats counts the stored tasks, workspaces, API keys and webhooks
+func (r *MemoryRepository) Stats(ctx context.Context) (Stats, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var lead, cycle []time.Duration
+	for _, sh := range r.shards {
+		sh.mu.RLock()
+		for id, task := range sh.tasks {
+			if task.Status != models.StatusDone || task.CompletedAt == nil {
+				continue
+			}
+			lead = append(lead, task.CompletedAt.Sub(task.CreatedAt))
+			cycle = append(cycle, task.CompletedAt.Sub(openedAt(task, sh.revisions[id])))
+		}
+		sh.mu.RUnlock()
+	}
+
+	return Stats{
+		Tasks:      r.len(),
+		Workspaces: len(r.workspaces),
+		APIKeys:    len(r.keys.byHash),
+		Webhooks:   len(r.hooks.byID),
+		LastID:     atomic.LoadInt64(&r.nextID),
+		LeadTime:   durationStats(lead),
+		CycleTime:  durationStats(cycle),
+	}, nil
+}
+
+// openedAt returns when task was last opened: when an update moved it from
+// done back to todo, or else when it was created. Only the kept revisions
+// are looked at, so a reopening older than those counts as creation.
+func openedAt(task *models.Task, revisions []*models.TaskRevision) time.Time {
+	opened := task.CreatedAt
+	for i, rev := range revisions {
+		next := task.Status
+		if i+1 < len(revisions) {
+			next = revisions[i+1].Status
+		}
+		if rev.Status == models.StatusDone && next != models.StatusDone {
+			opened = rev.ReplacedAt
+		}
+	}
+	return opened
+}
+
+// durationStats returns the count, mean and nearest-rank percentiles of
+// durations, or nil if there are none. It sorts durations.
+func durationStats(durations []time.Duration) *DurationStats {
+	if len(durations) == 0 {
+		return nil
+	}
+	slices.Sort(durations)
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+	percentile := func(p int) float64 {
+		rank := (p*len(durations) + 99) / 100
+		return durations[max(rank, 1)-1].Seconds()
+	}
+	return &DurationStats{
+		Count: len(durations),
+		Avg:   (total / time.Duration(len(durations))).Seconds(),
+		P50:   percentile(50),
+		P90:   percentile(90),
+	}
+}
+
+// Compact copies the tasks into freshly sized maps, as Go maps never
+// shrink after deletes, and drops links whose target is gone. Restored
+// snapshots and imports can carry such links; Delete never leaves them.
+func (r *MemoryRepository) Compact(ctx context.Context) (CompactResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lockAll()
+	defer r.unlockAll()
+
+	var result CompactResult
+	tasks := make(map[int64]*models.Task, len(r.order))
+	for _, sh := range r.shards {
+		compacted := make(map[int64]*models.Task, len(sh.tasks))
+		for id, task := range sh.tasks {
+			compacted[id] = task
+			tasks[id] = task
+		}
+		sh.tasks = compacted
+		sh.revisions = maps.Clone(sh.revisions)
+	}
+	for id, task := range tasks {
+		if len(task.Links) == 0 {
+			continue
+		}
+		links := make([]models.TaskLink, 0, len(task.Links))
+		for _, link := range task.Links {
+			if _, ok := tasks[link.TaskID]; ok {
+				links = append(links, link)
+			}
+		}
+		if len(links) == len(task.Links) {
+			continue
+		}
+		result.DanglingLinks += len(task.Links) - len(links)
+		c := copyTask(task)
+		c.Links = links
+		r.shardFor(id).tasks[id] = c
+	}
+	if result.DanglingLinks > 0 {
+		r.modified.Store(time.Now().UnixNano())
+	}
+	r.order = append(make([]int64, 0, len(r.order)), r.order...)
+
+	byHash := make(map[string]*models.APIKey, len(r.keys.byHash))
+	for hash, key := range r.keys.byHash {
+		byHash[hash] = key
+	}
+	r.keys.byHash = byHash
+
+	byID := make(map[int64]*models.Webhook, len(r.hooks.byID))
+	for id, hook := range r.hooks.byID {
+		byID[id] = hook
+	}
+	r.hooks.byID = byID
+
+	return result, nil
+}
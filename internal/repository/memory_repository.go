@@ -1,109 +1,810 @@
 package repository
 
 import (
+	"context"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/light-bringer/cert-tasks/internal/ids"
 	"github.com/light-bringer/cert-tasks/internal/models"
 )
 
-// MemoryRepository is an in-memory implementation of TaskRepository
+// defaultShards is how many shards NewMemoryRepository spreads tasks over
+const defaultShards = 64
+
+// MemoryRepository is an in-memory implementation of TaskRepository.
+// Tasks are spread over shards by ID, each with its own lock, so calls on
+// different tasks do not wait for each other.
+//
+// Stored tasks are never changed in place: writes store a changed copy,
+// and every task handed out is a copy, so callers may keep or encode what
+// they get while other calls change the task.
+//
+// Locks are taken in the order mu, orderMu, shards by index. Calls on
+// tasks take only the locks of the shards they touch, and orderMu when
+// they add or remove a task; mu guards API keys, webhooks and workspaces.
+// Calls that replace or need a consistent view of every task (Reset,
+// Restore, Snapshot, Compact, WithTx) take orderMu and every shard lock.
 type MemoryRepository struct {
 	mu     sync.RWMutex
-	tasks  map[int64]*models.Task
+	shards []*shard // fixed at creation; whole-store calls swap their maps
 	nextID int64
+	keys   apiKeys
+	hooks  webhooks
+
+	// orderMu guards order and uids, and is held while a create takes its
+	// ID, so that order stays sorted. order is never changed in place, so
+	// a slice of it taken under orderMu can be read after it is released.
+	orderMu sync.Mutex
+	order   []int64          // task IDs in ascending order, for pagination
+	uids    map[string]int64 // task IDs by opaque identifier
+
+	// idFormat selects the opaque identifier given to new tasks
+	idFormat ids.Format
+
+	// snowflake, when set, mints task IDs in place of the nextID counter
+	snowflake *ids.Snowflake
+
+	// workspaces are kept across Reset, like keys. They are changed with
+	// both mu and orderMu held, so either is enough for reading them.
+	workspaces map[string]*models.Workspace
+
+	// modified is when a task was last created, changed or deleted, in
+	// Unix nanoseconds
+	modified atomic.Int64
+}
+
+// shard holds the tasks whose IDs map to it, and their revisions
+type shard struct {
+	mu        sync.RWMutex
+	tasks     map[int64]*models.Task
+	revisions map[int64][]*models.TaskRevision
+}
+
+// MemoryOption configures a MemoryRepository
+type MemoryOption func(*MemoryRepository)
+
+// WithIDFormat gives every task an opaque identifier in format, such as a
+// ULID, alongside its numeric ID. Restored tasks without one get one too.
+func WithIDFormat(format ids.Format) MemoryOption {
+	return func(r *MemoryRepository) {
+		r.idFormat = format
+	}
+}
+
+// WithSnowflake mints task IDs with s instead of counting from 1, so that
+// replicas with different nodes never create the same ID
+func WithSnowflake(s *ids.Snowflake) MemoryOption {
+	return func(r *MemoryRepository) {
+		r.snowflake = s
+	}
 }
 
 // NewMemoryRepository creates a new in-memory repository
-func NewMemoryRepository() *MemoryRepository {
-	return &MemoryRepository{
-		tasks:  make(map[int64]*models.Task),
-		nextID: 0,
+func NewMemoryRepository(opts ...MemoryOption) *MemoryRepository {
+	return newMemoryRepository(defaultShards, opts...)
+}
+
+// newMemoryRepository creates an empty repository with n shards
+func newMemoryRepository(n int, opts ...MemoryOption) *MemoryRepository {
+	r := &MemoryRepository{
+		shards:     newShards(n),
+		nextID:     0,
+		uids:       make(map[string]int64),
+		idFormat:   ids.Sequential,
+		workspaces: map[string]*models.Workspace{models.DefaultWorkspace: defaultWorkspace()},
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	r.modified.Store(time.Now().UnixNano())
+	return r
 }
 
-// Create creates a new task with generated ID and timestamps
-func (r *MemoryRepository) Create(task *models.Task) (*models.Task, error) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+// newShards returns n empty shards
+func newShards(n int) []*shard {
+	shards := make([]*shard, n)
+	for i := range shards {
+		shards[i] = &shard{
+			tasks:     make(map[int64]*models.Task),
+			revisions: make(map[int64][]*models.TaskRevision),
+		}
+	}
+	return shards
+}
+
+// shardFor returns the shard holding the task with the given ID
+func (r *MemoryRepository) shardFor(id int64) *shard {
+	return r.shards[uint64(id)%uint64(len(r.shards))]
+}
+
+// get returns the stored task with the given ID, or nil, which the caller
+// must not change
+func (r *MemoryRepository) get(id int64) *models.Task {
+	sh := r.shardFor(id)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	return sh.tasks[id]
+}
+
+// each calls fn for every task until it returns false, one shard at a
+// time
+func (r *MemoryRepository) each(fn func(task *models.Task) bool) {
+	for _, sh := range r.shards {
+		sh.mu.RLock()
+		for _, task := range sh.tasks {
+			if !fn(task) {
+				sh.mu.RUnlock()
+				return
+			}
+		}
+		sh.mu.RUnlock()
+	}
+}
+
+// len returns the number of tasks
+func (r *MemoryRepository) len() int {
+	n := 0
+	for _, sh := range r.shards {
+		sh.mu.RLock()
+		n += len(sh.tasks)
+		sh.mu.RUnlock()
+	}
+	return n
+}
+
+// lockAll takes orderMu and every shard lock for writing, so no other call
+// touches a task until unlockAll
+func (r *MemoryRepository) lockAll() {
+	r.orderMu.Lock()
+	for _, sh := range r.shards {
+		sh.mu.Lock()
+	}
+}
+
+// unlockAll releases the locks taken by lockAll
+func (r *MemoryRepository) unlockAll() {
+	for _, sh := range r.shards {
+		sh.mu.Unlock()
+	}
+	r.orderMu.Unlock()
+}
+
+// rlockAll takes orderMu and every shard lock for reading, so tasks can be
+// read but not created, changed or deleted until runlockAll
+func (r *MemoryRepository) rlockAll() {
+	r.orderMu.Lock()
+	for _, sh := range r.shards {
+		sh.mu.RLock()
+	}
+}
+
+// runlockAll releases the locks taken by rlockAll
+func (r *MemoryRepository) runlockAll() {
+	for _, sh := range r.shards {
+		sh.mu.RUnlock()
+	}
+	r.orderMu.Unlock()
+}
+
+// clear empties every shard; every shard lock must be held
+func (r *MemoryRepository) clear() {
+	for _, sh := range r.shards {
+		sh.tasks = make(map[int64]*models.Task)
+		sh.revisions = make(map[int64][]*models.TaskRevision)
+	}
+}
+
+// touch records a change at t. Concurrent writers may finish out of
+// order, so the latest time wins.
+func (r *MemoryRepository) touch(t time.Time) {
+	for {
+		old := r.modified.Load()
+		if t.UnixNano() <= old || r.modified.CompareAndSwap(old, t.UnixNano()) {
+			return
+		}
+	}
+}
+
+// Reset removes every task and restarts ID generation from 1
+func (r *MemoryRepository) Reset() {
+	r.lockAll()
+	defer r.unlockAll()
+
+	r.clear()
+	r.order = nil
+	r.uids = make(map[string]int64)
+	r.modified.Store(time.Now().UnixNano())
+	atomic.StoreInt64(&r.nextID, 0)
+}
+
+// Snapshot returns copies of every task in ID order together with the last
+// ID handed out, for persisting the repository
+func (r *MemoryRepository) Snapshot() ([]*models.Task, int64) {
+	r.rlockAll()
+	defer r.runlockAll()
+
+	tasks := make([]*models.Task, 0, len(r.order))
+	for _, id := range r.order {
+		tasks = append(tasks, copyTask(r.shardFor(id).tasks[id]))
+	}
 
-	// Generate new ID using atomic operation
-	id := atomic.AddInt64(&r.nextID, 1)
+	return tasks, atomic.LoadInt64(&r.nextID)
+}
+
+// Restore replaces the repository contents with tasks, continuing ID
+// generation after lastID or the highest task ID, whichever is larger
+func (r *MemoryRepository) Restore(tasks []*models.Task, lastID int64) {
+	r.lockAll()
+	defer r.unlockAll()
+
+	r.clear()
+	r.order = make([]int64, 0, len(tasks))
+	r.uids = make(map[string]int64, len(tasks))
+	for _, task := range tasks {
+		if task.WorkspaceID == "" {
+			// Saved before workspaces existed
+			task.WorkspaceID = models.DefaultWorkspace
+		}
+		if task.UID == "" {
+			// Saved before opaque identifiers were switched on
+			task.UID = r.idFormat.New(task.CreatedAt)
+		}
+		if task.UID != "" {
+			r.uids[task.UID] = task.ID
+		}
+		r.shardFor(task.ID).tasks[task.ID] = task
+		r.order = append(r.order, task.ID)
+		lastID = max(lastID, task.ID)
+	}
+	sort.Slice(r.order, func(i, j int) bool { return r.order[i] < r.order[j] })
+	r.modified.Store(time.Now().UnixNano())
+
+	atomic.StoreInt64(&r.nextID, lastID)
+	if r.snowflake != nil {
+		r.snowflake.Observe(lastID)
+	}
+}
+
+// Create creates a new task with generated ID and timestamps
+func (r *MemoryRepository) Create(ctx context.Context, task *models.Task) (*models.Task, error) {
+	workspace := WorkspaceFromContext(ctx)
+	if workspace == "" {
+		workspace = models.DefaultWorkspace
+	}
 
 	now := time.Now()
 	newTask := &models.Task{
-		ID:          id,
+		UID:         r.idFormat.New(now),
+		OwnerID:     OwnerFromContext(ctx),
+		WorkspaceID: workspace,
 		Title:       task.Title,
 		Description: task.Description,
 		Status:      task.Status,
 		CreatedAt:   now,
 		UpdatedAt:   now,
+		DueAt:       copyTime(task.DueAt),
+		Estimate:    task.Estimate,
 	}
 
 	// Set default status if not provided
 	if newTask.Status == "" {
 		newTask.Status = models.StatusTodo
 	}
+	setCompleted(newTask, newTask.Status, now)
+
+	// Taking the ID and appending it under orderMu keeps order sorted;
+	// the task is stored first, so List never meets an ID without one.
+	// orderMu also keeps the workspace from being deleted meanwhile.
+	r.orderMu.Lock()
+	defer r.orderMu.Unlock()
+
+	if _, exists := r.workspaces[workspace]; !exists {
+		return nil, ErrWorkspaceNotFound
+	}
+	newTask.ID = r.newID()
+	sh := r.shardFor(newTask.ID)
+	sh.mu.Lock()
+	sh.tasks[newTask.ID] = newTask
+	sh.mu.Unlock()
+
+	r.order = append(r.order, newTask.ID)
+	if newTask.UID != "" {
+		r.uids[newTask.UID] = newTask.ID
+	}
+	r.touch(now)
+	return copyTask(newTask), nil
+}
 
-	r.tasks[id] = newTask
-	return newTask, nil
+// newID returns the ID for a new task; r.orderMu must be held
+func (r *MemoryRepository) newID() int64 {
+	if r.snowflake == nil {
+		return atomic.AddInt64(&r.nextID, 1)
+	}
+	id := r.snowflake.Next()
+	atomic.StoreInt64(&r.nextID, id)
+	return id
 }
 
 // GetAll returns all tasks
-func (r *MemoryRepository) GetAll() ([]*models.Task, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+func (r *MemoryRepository) GetAll(ctx context.Context) ([]*models.Task, error) {
+	sc := scopeFrom(ctx)
+	tasks := make([]*models.Task, 0, r.len())
+	r.each(func(task *models.Task) bool {
+		if sc.allows(task) {
+			tasks = append(tasks, copyTask(task))
+		}
+		return true
+	})
+
+	return tasks, nil
+}
+
+// List returns a page of tasks ordered by ID
+func (r *MemoryRepository) List(ctx context.Context, opts ListOptions) ([]*models.Task, error) {
+	// order is never changed in place, so the slice taken here stays valid
+	r.orderMu.Lock()
+	order := r.order[:len(r.order):len(r.order)]
+	r.orderMu.Unlock()
+
+	start := 0
+	if opts.AfterID > 0 {
+		start = sort.Search(len(order), func(i int) bool {
+			return order[i] > opts.AfterID
+		})
+	}
+
+	if sc := scopeFrom(ctx); !sc.unrestricted() || opts.Filter != (TaskFilter{}) {
+		return r.listFiltered(sc, order, start, opts), nil
+	}
+
+	if opts.AfterID == 0 && opts.Offset > 0 {
+		start = min(opts.Offset, len(order))
+	}
+
+	end := len(order)
+	if opts.Limit > 0 {
+		end = min(start+opts.Limit, end)
+	}
+
+	tasks := make([]*models.Task, 0, end-start)
+	for _, id := range order[start:end] {
+		// A task deleted since order was read is left out
+		if task := r.get(id); task != nil {
+			tasks = append(tasks, copyTask(task))
+		}
+	}
+
+	return tasks, nil
+}
+
+// listFiltered returns a page of the tasks in sc that opts.Filter
+// selects, scanning order from start and stopping once the page is full
+func (r *MemoryRepository) listFiltered(sc scope, order []int64, start int, opts ListOptions) []*models.Task {
+	skip := 0
+	if opts.AfterID == 0 {
+		skip = opts.Offset
+	}
 
-	tasks := make([]*models.Task, 0, len(r.tasks))
-	for _, task := range r.tasks {
-		tasks = append(tasks, task)
+	tasks := make([]*models.Task, 0)
+	for _, id := range order[start:] {
+		task := r.get(id)
+		if task == nil || !sc.allows(task) || !opts.Filter.matches(task) {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		tasks = append(tasks, copyTask(task))
+		if opts.Limit > 0 && len(tasks) == opts.Limit {
+			break
+		}
 	}
+	return tasks
+}
+
+// Search returns tasks whose title or description contain the query,
+// ignoring case
+func (r *MemoryRepository) Search(ctx context.Context, query string) ([]*models.Task, error) {
+	sc := scopeFrom(ctx)
+	query = strings.ToLower(query)
+	tasks := make([]*models.Task, 0)
+	r.each(func(task *models.Task) bool {
+		if sc.allows(task) && (strings.Contains(strings.ToLower(task.Title), query) ||
+			strings.Contains(strings.ToLower(task.Description), query)) {
+			tasks = append(tasks, copyTask(task))
+		}
+		return true
+	})
 
 	return tasks, nil
 }
 
+// Capabilities reports the features supported by the in-memory backend
+func (r *MemoryRepository) Capabilities() Capabilities {
+	return Capabilities{
+		FullTextSearch: true,
+		Cursors:        true,
+	}
+}
+
+// LastModified returns when a task was last created, changed or deleted,
+// or when the repository was created or restored
+func (r *MemoryRepository) LastModified(ctx context.Context) (time.Time, error) {
+	return time.Unix(0, r.modified.Load()), nil
+}
+
 // GetByID returns a task by ID
-func (r *MemoryRepository) GetByID(id int64) (*models.Task, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+func (r *MemoryRepository) GetByID(ctx context.Context, id int64) (*models.Task, error) {
+	task := r.get(id)
+	if task == nil || !scopeFrom(ctx).allows(task) {
+		return nil, ErrTaskNotFound
+	}
+
+	return copyTask(task), nil
+}
 
-	task, exists := r.tasks[id]
+// GetByUID returns a task by its opaque identifier
+func (r *MemoryRepository) GetByUID(ctx context.Context, uid string) (*models.Task, error) {
+	r.orderMu.Lock()
+	id, exists := r.uids[uid]
+	r.orderMu.Unlock()
 	if !exists {
 		return nil, ErrTaskNotFound
 	}
 
-	return task, nil
+	task := r.get(id)
+	if task == nil || !scopeFrom(ctx).allows(task) {
+		return nil, ErrTaskNotFound
+	}
+
+	return copyTask(task), nil
 }
 
-// Update updates an existing task
-func (r *MemoryRepository) Update(id int64, task *models.Task) (*models.Task, error) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+// GetByIDs returns the tasks with the given IDs, in ID order
+func (r *MemoryRepository) GetByIDs(ctx context.Context, ids []int64) ([]*models.Task, error) {
+	sc := scopeFrom(ctx)
+	tasks := make([]*models.Task, 0, len(ids))
+	for _, id := range ids {
+		if task := r.get(id); task != nil && sc.allows(task) {
+			tasks = append(tasks, copyTask(task))
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].ID < tasks[j].ID })
+	return slices.CompactFunc(tasks, func(a, b *models.Task) bool { return a.ID == b.ID }), nil
+}
 
-	existing, exists := r.tasks[id]
-	if !exists {
+// Exists reports whether a task with the given ID exists
+func (r *MemoryRepository) Exists(ctx context.Context, id int64) (bool, error) {
+	task := r.get(id)
+	return task != nil && scopeFrom(ctx).allows(task), nil
+}
+
+// Count returns how many tasks filter selects
+func (r *MemoryRepository) Count(ctx context.Context, filter TaskFilter) (int, error) {
+	sc := scopeFrom(ctx)
+	if sc.unrestricted() && filter == (TaskFilter{}) {
+		return r.len(), nil
+	}
+	n := 0
+	r.each(func(task *models.Task) bool {
+		if sc.allows(task) && filter.matches(task) {
+			n++
+		}
+		return true
+	})
+	return n, nil
+}
+
+// EstimateTotal adds up the estimates of the tasks filter selects
+func (r *MemoryRepository) EstimateTotal(ctx context.Context, filter TaskFilter) (int, error) {
+	sc := scopeFrom(ctx)
+	total := 0
+	r.each(func(task *models.Task) bool {
+		if sc.allows(task) && filter.matches(task) {
+			total += task.Estimate
+		}
+		return true
+	})
+	return total, nil
+}
+
+// Update updates an existing task, keeping the version it replaces as a
+// revision unless nothing changed
+func (r *MemoryRepository) Update(ctx context.Context, id int64, task *models.Task) (*models.Task, error) {
+	sh := r.shardFor(id)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	existing, exists := sh.tasks[id]
+	if !exists || !scopeFrom(ctx).allows(existing) {
+		return nil, ErrTaskNotFound
+	}
+	if err := checkPrecondition(ctx, existing); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if !sameContent(existing, task) {
+		sh.addRevision(existing, now)
+	}
+
+	updated := copyTask(existing)
+	setCompleted(updated, task.Status, now)
+	updated.Title = task.Title
+	updated.Description = task.Description
+	updated.Status = task.Status
+	updated.DueAt = copyTime(task.DueAt)
+	updated.Estimate = task.Estimate
+	updated.UpdatedAt = now
+	sh.tasks[id] = updated
+	r.touch(now)
+
+	return copyTask(updated), nil
+}
+
+// SetStatus changes the status of a task, keeping a revision of it as
+// Update does
+func (r *MemoryRepository) SetStatus(ctx context.Context, id int64, status models.TaskStatus) (*models.Task, error) {
+	sh := r.shardFor(id)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	existing, exists := sh.tasks[id]
+	if !exists || !scopeFrom(ctx).allows(existing) {
 		return nil, ErrTaskNotFound
 	}
+	if err := checkPrecondition(ctx, existing); err != nil {
+		return nil, err
+	}
+	if existing.Status == status {
+		return copyTask(existing), nil
+	}
 
-	// Update fields
-	existing.Title = task.Title
-	existing.Description = task.Description
-	existing.Status = task.Status
-	existing.UpdatedAt = time.Now()
+	now := time.Now()
+	sh.addRevision(existing, now)
+	updated := copyTask(existing)
+	setCompleted(updated, status, now)
+	updated.Status = status
+	updated.UpdatedAt = now
+	sh.tasks[id] = updated
+	r.touch(now)
 
-	return existing, nil
+	return copyTask(updated), nil
 }
 
-// Delete deletes a task by ID
-func (r *MemoryRepository) Delete(id int64) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+// Delete deletes a task by ID and drops the links other tasks had to it,
+// locking one shard at a time
+func (r *MemoryRepository) Delete(ctx context.Context, id int64) error {
+	r.orderMu.Lock()
+	defer r.orderMu.Unlock()
 
-	if _, exists := r.tasks[id]; !exists {
+	sh := r.shardFor(id)
+	sh.mu.Lock()
+	task, exists := sh.tasks[id]
+	if !exists || !scopeFrom(ctx).allows(task) {
+		sh.mu.Unlock()
 		return ErrTaskNotFound
 	}
+	if err := checkPrecondition(ctx, task); err != nil {
+		sh.mu.Unlock()
+		return err
+	}
+	delete(sh.tasks, id)
+	delete(sh.revisions, id)
+	sh.mu.Unlock()
+
+	// Lists may still be reading the old order, so it is copied rather
+	// than shifted
+	delete(r.uids, task.UID)
+	i := sort.Search(len(r.order), func(i int) bool { return r.order[i] >= id })
+	r.order = slices.Concat(r.order[:i], r.order[i+1:])
+
+	// Drop links from other tasks that pointed at the deleted one. AddLink
+	// locks the target's shard, so none is added once the task is gone.
+	for _, sh := range r.shards {
+		sh.mu.Lock()
+		for taskID, task := range sh.tasks {
+			if !slices.ContainsFunc(task.Links, func(link models.TaskLink) bool { return link.TaskID == id }) {
+				continue
+			}
+			c := copyTask(task)
+			c.Links = slices.DeleteFunc(c.Links, func(link models.TaskLink) bool { return link.TaskID == id })
+			sh.tasks[taskID] = c
+		}
+		sh.mu.Unlock()
+	}
+	r.touch(time.Now())
+
+	return nil
+}
+
+// Undelete puts deleted tasks back with their IDs and opaque identifiers
+func (r *MemoryRepository) Undelete(ctx context.Context, tasks []*models.Task) ([]*models.Task, error) {
+	// Holding orderMu keeps tasks from being created or deleted meanwhile
+	r.orderMu.Lock()
+	defer r.orderMu.Unlock()
+
+	sc := scopeFrom(ctx)
+	ids := make(map[int64]bool, len(tasks))
+	for _, task := range tasks {
+		if !sc.allows(task) {
+			return nil, ErrTaskNotFound
+		}
+		if _, exists := r.workspaces[task.WorkspaceID]; !exists {
+			return nil, ErrWorkspaceNotFound
+		}
+		if r.get(task.ID) != nil || ids[task.ID] {
+			return nil, ErrTaskExists
+		}
+		ids[task.ID] = true
+	}
+
+	restored := make([]*models.Task, 0, len(tasks))
+	order := slices.Clone(r.order)
+	for _, task := range tasks {
+		c := *task
+		c.DueAt = copyTime(task.DueAt)
+		c.CompletedAt = copyTime(task.CompletedAt)
+		c.Links = nil
+		for _, link := range task.Links {
+			if ids[link.TaskID] || r.get(link.TaskID) != nil {
+				c.Links = append(c.Links, link)
+			}
+		}
+		sh := r.shardFor(c.ID)
+		sh.mu.Lock()
+		sh.tasks[c.ID] = &c
+		sh.mu.Unlock()
+		if c.UID != "" {
+			r.uids[c.UID] = c.ID
+		}
+		i := sort.Search(len(order), func(i int) bool { return order[i] >= c.ID })
+		order = slices.Insert(order, i, c.ID)
+		restored = append(restored, copyTask(&c))
+	}
+	r.order = order
+	r.touch(time.Now())
+	return restored, nil
+}
+
+// AddLink adds a typed link from one task to another
+func (r *MemoryRepository) AddLink(ctx context.Context, id int64, link models.TaskLink) (*models.Task, error) {
+	// Locking the target's shard too keeps it from being deleted before
+	// the link is stored
+	unlock := r.lockPair(id, link.TaskID)
+	defer unlock()
+
+	sc := scopeFrom(ctx)
+	existing, exists := r.shardFor(id).tasks[id]
+	if !exists || !sc.allows(existing) {
+		return nil, ErrTaskNotFound
+	}
+
+	if link.TaskID == id {
+		return nil, ErrSelfLink
+	}
+	if target := r.shardFor(link.TaskID).tasks[link.TaskID]; target == nil || !sc.allows(target) {
+		return nil, ErrLinkTargetNotFound
+	}
+
+	for _, l := range existing.Links {
+		if l == link {
+			return nil, ErrLinkExists
+		}
+	}
+
+	updated := copyTask(existing)
+	updated.Links = append(updated.Links, link)
+	updated.UpdatedAt = time.Now()
+	r.shardFor(id).tasks[id] = updated
+	r.touch(updated.UpdatedAt)
+
+	return copyTask(updated), nil
+}
+
+// lockPair locks the shards of two tasks for writing, lower index first so
+// that two calls locking the same pair cannot deadlock, and returns the
+// function unlocking them
+func (r *MemoryRepository) lockPair(a, b int64) func() {
+	i := uint64(a) % uint64(len(r.shards))
+	j := uint64(b) % uint64(len(r.shards))
+	if i > j {
+		i, j = j, i
+	}
+	r.shards[i].mu.Lock()
+	if i == j {
+		return r.shards[i].mu.Unlock
+	}
+	r.shards[j].mu.Lock()
+	return func() {
+		r.shards[j].mu.Unlock()
+		r.shards[i].mu.Unlock()
+	}
+}
+
+// WithTx runs fn against a copy of the tasks while holding every shard
+// lock, so other calls wait for it and never see a partial change, and
+// moves the copy in if fn succeeds
+func (r *MemoryRepository) WithTx(ctx context.Context, fn func(tx TaskRepository) error) error {
+	r.lockAll()
+	defer r.unlockAll()
+
+	// Workspaces are only read by task calls, and stay put while orderMu
+	// is held, so the copy can share them
+	tx := newMemoryRepository(len(r.shards), WithIDFormat(r.idFormat), WithSnowflake(r.snowflake))
+	tx.workspaces = r.workspaces
+	tx.order = slices.Clone(r.order)
+	tx.uids = maps.Clone(r.uids)
+	tx.nextID = atomic.LoadInt64(&r.nextID)
+	tx.modified.Store(r.modified.Load())
+	for i, sh := range r.shards {
+		for id, task := range sh.tasks {
+			tx.shards[i].tasks[id] = copyTask(task)
+		}
+		for id, revs := range sh.revisions {
+			tx.shards[i].revisions[id] = slices.Clone(revs)
+		}
+	}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
 
-	delete(r.tasks, id)
+	tx.lockAll()
+	defer tx.unlockAll()
+	for i, sh := range r.shards {
+		sh.tasks, sh.revisions = tx.shards[i].tasks, tx.shards[i].revisions
+	}
+	r.order, r.uids = tx.order, tx.uids
+	r.modified.Store(tx.modified.Load())
+	atomic.StoreInt64(&r.nextID, atomic.LoadInt64(&tx.nextID))
 	return nil
 }
+
+// sameContent reports whether an update to task would leave its fields as
+// they are
+func sameContent(existing, task *models.Task) bool {
+	sameDue := existing.DueAt == nil && task.DueAt == nil ||
+		existing.DueAt != nil && task.DueAt != nil && existing.DueAt.Equal(*task.DueAt)
+	return sameDue && existing.Title == task.Title && existing.Description == task.Description && existing.Status == task.Status &&
+		existing.Estimate == task.Estimate
+}
+
+// setCompleted stamps CompletedAt when task moves to done and clears it
+// when it moves to any other status. A task that stays done keeps its time.
+func setCompleted(task *models.Task, status models.TaskStatus, now time.Time) {
+	switch {
+	case status != models.StatusDone:
+		task.CompletedAt = nil
+	case task.CompletedAt == nil || task.Status != models.StatusDone:
+		completed := now.UTC()
+		task.CompletedAt = &completed
+	}
+}
+
+// copyTask returns a copy of task that shares no due date, completion time
+// or links with it
+func copyTask(task *models.Task) *models.Task {
+	c := *task
+	c.DueAt = copyTime(task.DueAt)
+	c.CompletedAt = copyTime(task.CompletedAt)
+	c.Links = slices.Clone(task.Links)
+	return &c
+}
+
+// copyTime returns a copy of t, so stored tasks do not share a due date
+// with the caller
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := t.UTC()
+	return &c
+}
@@ -1,13 +1,24 @@
 package repository
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"runtime"
+	"slices"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/light-bringer/cert-tasks/internal/ids"
 	"github.com/light-bringer/cert-tasks/internal/models"
 )
 
 func TestMemoryRepository_Create(t *testing.T) {
+	ctx := context.Background()
+
 	repo := NewMemoryRepository()
 
 	task := &models.Task{
@@ -15,7 +26,7 @@ func TestMemoryRepository_Create(t *testing.T) {
 		Description: "Test Description",
 	}
 
-	created, err := repo.Create(task)
+	created, err := repo.Create(ctx, task)
 	if err != nil {
 		t.Fatalf("Create() error = %v", err)
 	}
@@ -46,16 +57,18 @@ func TestMemoryRepository_Create(t *testing.T) {
 }
 
 func TestMemoryRepository_GetAll(t *testing.T) {
+	ctx := context.Background()
+
 	repo := NewMemoryRepository()
 
 	// Create multiple tasks
 	task1 := &models.Task{Title: "Task 1"}
 	task2 := &models.Task{Title: "Task 2"}
 
-	repo.Create(task1)
-	repo.Create(task2)
+	repo.Create(ctx, task1)
+	repo.Create(ctx, task2)
 
-	tasks, err := repo.GetAll()
+	tasks, err := repo.GetAll(ctx)
 	if err != nil {
 		t.Fatalf("GetAll() error = %v", err)
 	}
@@ -66,13 +79,15 @@ func TestMemoryRepository_GetAll(t *testing.T) {
 }
 
 func TestMemoryRepository_GetByID(t *testing.T) {
+	ctx := context.Background()
+
 	repo := NewMemoryRepository()
 
 	task := &models.Task{Title: "Test Task"}
-	created, _ := repo.Create(task)
+	created, _ := repo.Create(ctx, task)
 
 	t.Run("existing task", func(t *testing.T) {
-		found, err := repo.GetByID(created.ID)
+		found, err := repo.GetByID(ctx, created.ID)
 		if err != nil {
 			t.Fatalf("GetByID() error = %v", err)
 		}
@@ -87,18 +102,178 @@ func TestMemoryRepository_GetByID(t *testing.T) {
 	})
 
 	t.Run("non-existent task", func(t *testing.T) {
-		_, err := repo.GetByID(999)
+		_, err := repo.GetByID(ctx, 999)
 		if err != ErrTaskNotFound {
 			t.Errorf("Expected ErrTaskNotFound, got %v", err)
 		}
 	})
 }
 
+func TestMemoryRepository_GetByIDs(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	for _, title := range []string{"a", "b", "c"} {
+		repo.Create(WithOwner(ctx, title), &models.Task{Title: title})
+	}
+
+	tasks, err := repo.GetByIDs(ctx, []int64{3, 99, 1, 3})
+	if err != nil || len(tasks) != 2 || tasks[0].ID != 1 || tasks[1].ID != 3 {
+		t.Errorf("GetByIDs = %v, %v; want tasks 1 and 3", tasks, err)
+	}
+
+	// Tasks outside the caller's scope are skipped like missing ones
+	tasks, _ = repo.GetByIDs(WithOwner(ctx, "b"), []int64{1, 2, 3})
+	if len(tasks) != 1 || tasks[0].ID != 2 {
+		t.Errorf("scoped GetByIDs = %v, want task 2", tasks)
+	}
+}
+
+func TestMemoryRepository_ExistsAndCount(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	alice := WithOwner(ctx, "alice")
+	repo.Create(alice, &models.Task{Title: "a", Status: models.StatusTodo})
+	repo.Create(alice, &models.Task{Title: "b", Status: models.StatusDone})
+	repo.Create(WithOwner(ctx, "bob"), &models.Task{Title: "c", Status: models.StatusDone})
+
+	for _, tt := range []struct {
+		ctx  context.Context
+		id   int64
+		want bool
+	}{
+		{ctx, 3, true},
+		{ctx, 4, false},
+		{alice, 1, true},
+		{alice, 3, false},
+	} {
+		if got, err := repo.Exists(tt.ctx, tt.id); err != nil || got != tt.want {
+			t.Errorf("Exists(%s, %d) = %v, %v; want %v", OwnerFromContext(tt.ctx), tt.id, got, err, tt.want)
+		}
+	}
+
+	for _, tt := range []struct {
+		ctx    context.Context
+		filter TaskFilter
+		want   int
+	}{
+		{ctx, TaskFilter{}, 3},
+		{ctx, TaskFilter{Status: models.StatusDone}, 2},
+		{alice, TaskFilter{}, 2},
+		{alice, TaskFilter{Status: models.StatusDone}, 1},
+	} {
+		if got, err := repo.Count(tt.ctx, tt.filter); err != nil || got != tt.want {
+			t.Errorf("Count(%s, %+v) = %d, %v; want %d", OwnerFromContext(tt.ctx), tt.filter, got, err, tt.want)
+		}
+	}
+
+	// List applies the same filter before paging
+	done := TaskFilter{Status: models.StatusDone}
+	for _, tt := range []struct {
+		ctx  context.Context
+		opts ListOptions
+		want []int64
+	}{
+		{ctx, ListOptions{Filter: done}, []int64{2, 3}},
+		{ctx, ListOptions{Filter: done, Offset: 1}, []int64{3}},
+		{ctx, ListOptions{Filter: done, AfterID: 1, Limit: 1}, []int64{2}},
+		{alice, ListOptions{Filter: done}, []int64{2}},
+		{alice, ListOptions{Filter: TaskFilter{Status: models.StatusTodo}}, []int64{1}},
+	} {
+		tasks, err := repo.List(tt.ctx, tt.opts)
+		var got []int64
+		for _, task := range tasks {
+			got = append(got, task.ID)
+		}
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("List(%s, %+v) = %v, %v; want %v", OwnerFromContext(tt.ctx), tt.opts, got, err, tt.want)
+		}
+	}
+}
+
+func TestMemoryRepository_GetByUID(t *testing.T) {
+	ctx := context.Background()
+
+	plain := NewMemoryRepository()
+	if task, _ := plain.Create(ctx, &models.Task{Title: "Plain"}); task.UID != "" {
+		t.Errorf("sequential repository gave uid %q", task.UID)
+	}
+
+	repo := NewMemoryRepository(WithIDFormat(ids.UUIDv7))
+	task, _ := repo.Create(ctx, &models.Task{Title: "Opaque"})
+	if !ids.UUIDv7.Valid(task.UID) {
+		t.Fatalf("Create() uid = %q", task.UID)
+	}
+	if got, err := repo.GetByUID(ctx, task.UID); err != nil || got.ID != task.ID {
+		t.Errorf("GetByUID() = %+v, %v", got, err)
+	}
+	if _, err := repo.GetByUID(WithOwner(ctx, "someone"), task.UID); err != ErrTaskNotFound {
+		t.Errorf("GetByUID() for another owner: %v", err)
+	}
+
+	// Tasks saved before the format was switched on get a uid on restore
+	saved, lastID := plain.Snapshot()
+	repo.Restore(saved, lastID)
+	restored, _ := repo.GetByID(ctx, 1)
+	if !ids.UUIDv7.Valid(restored.UID) {
+		t.Fatalf("restored uid = %q", restored.UID)
+	}
+	if got, err := repo.GetByUID(ctx, restored.UID); err != nil || got.ID != 1 {
+		t.Errorf("GetByUID() after Restore = %+v, %v", got, err)
+	}
+	if _, err := repo.GetByUID(ctx, task.UID); err != ErrTaskNotFound {
+		t.Errorf("GetByUID() of a task replaced by Restore: %v", err)
+	}
+
+	repo.Delete(ctx, 1)
+	if _, err := repo.GetByUID(ctx, restored.UID); err != ErrTaskNotFound {
+		t.Errorf("GetByUID() after Delete: %v", err)
+	}
+}
+
+func TestMemoryRepository_Snowflake(t *testing.T) {
+	ctx := context.Background()
+	replica := func(node int) *MemoryRepository {
+		s, err := ids.NewSnowflake(node)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return NewMemoryRepository(WithSnowflake(s))
+	}
+	a, b := replica(1), replica(2)
+
+	minted := make(map[int64]bool)
+	for range 100 {
+		for _, repo := range []*MemoryRepository{a, b} {
+			task, _ := repo.Create(ctx, &models.Task{Title: "Task"})
+			if minted[task.ID] {
+				t.Fatalf("ID %d minted by both replicas", task.ID)
+			}
+			minted[task.ID] = true
+		}
+	}
+	if tasks, _ := a.List(ctx, ListOptions{Limit: 2}); ids.SnowflakeNode(tasks[0].ID) != 1 || tasks[0].ID >= tasks[1].ID {
+		t.Errorf("replica 1 listed %d, %d", tasks[0].ID, tasks[1].ID)
+	}
+
+	// Loading the other replica's tasks keeps new IDs after theirs
+	saved, lastID := b.Snapshot()
+	a.Restore(saved, lastID)
+	task, _ := a.Create(ctx, &models.Task{Title: "After restore"})
+	if task.ID <= lastID || ids.SnowflakeNode(task.ID) != 1 {
+		t.Errorf("Create() after Restore: ID %d, last restored %d", task.ID, lastID)
+	}
+	if stats, _ := a.Stats(ctx); stats.LastID != task.ID {
+		t.Errorf("Stats().LastID = %d, want %d", stats.LastID, task.ID)
+	}
+}
+
 func TestMemoryRepository_Update(t *testing.T) {
+	ctx := context.Background()
+
 	repo := NewMemoryRepository()
 
 	task := &models.Task{Title: "Original Title"}
-	created, _ := repo.Create(task)
+	created, _ := repo.Create(ctx, task)
 
 	t.Run("existing task", func(t *testing.T) {
 		updateData := &models.Task{
@@ -107,7 +282,7 @@ func TestMemoryRepository_Update(t *testing.T) {
 			Status:      models.StatusDone,
 		}
 
-		updated, err := repo.Update(created.ID, updateData)
+		updated, err := repo.Update(ctx, created.ID, updateData)
 		if err != nil {
 			t.Fatalf("Update() error = %v", err)
 		}
@@ -131,41 +306,160 @@ func TestMemoryRepository_Update(t *testing.T) {
 
 	t.Run("non-existent task", func(t *testing.T) {
 		updateData := &models.Task{Title: "Test"}
-		_, err := repo.Update(999, updateData)
+		_, err := repo.Update(ctx, 999, updateData)
 		if err != ErrTaskNotFound {
 			t.Errorf("Expected ErrTaskNotFound, got %v", err)
 		}
 	})
 }
 
+func TestMemoryRepository_SetStatus(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	due := time.Date(2026, 10, 20, 17, 0, 0, 0, time.UTC)
+	created, _ := repo.Create(ctx, &models.Task{Title: "Ship it", Description: "Tag the release", DueAt: &due})
+
+	done, err := repo.SetStatus(ctx, created.ID, models.StatusDone)
+	if err != nil {
+		t.Fatalf("SetStatus() error = %v", err)
+	}
+	if done.Status != models.StatusDone || done.Title != "Ship it" || done.Description != "Tag the release" || !done.DueAt.Equal(due) {
+		t.Errorf("SetStatus(done) = %+v, want only the status changed", done)
+	}
+	if done.CompletedAt == nil {
+		t.Fatal("CompletedAt is nil after completing")
+	}
+	completedAt := *done.CompletedAt
+
+	// Completing again, or through Update, keeps the time
+	again, _ := repo.SetStatus(ctx, created.ID, models.StatusDone)
+	updated, _ := repo.Update(ctx, created.ID, &models.Task{Title: "Ship it now", Status: models.StatusDone})
+	if !again.CompletedAt.Equal(completedAt) || !updated.CompletedAt.Equal(completedAt) {
+		t.Errorf("CompletedAt = %v, %v; want %v", again.CompletedAt, updated.CompletedAt, completedAt)
+	}
+	revisions, _ := repo.ListRevisions(ctx, created.ID)
+	if len(revisions) != 2 {
+		t.Errorf("revisions = %d, want 2", len(revisions))
+	}
+
+	reopened, err := repo.SetStatus(ctx, created.ID, models.StatusTodo)
+	if err != nil || reopened.Status != models.StatusTodo || reopened.CompletedAt != nil {
+		t.Errorf("SetStatus(todo) = %+v, %v", reopened, err)
+	}
+
+	if _, err := repo.SetStatus(ctx, 999, models.StatusDone); err != ErrTaskNotFound {
+		t.Errorf("SetStatus(999) error = %v, want ErrTaskNotFound", err)
+	}
+}
+
 func TestMemoryRepository_Delete(t *testing.T) {
+	ctx := context.Background()
+
 	repo := NewMemoryRepository()
 
 	task := &models.Task{Title: "Test Task"}
-	created, _ := repo.Create(task)
+	created, _ := repo.Create(ctx, task)
 
 	t.Run("existing task", func(t *testing.T) {
-		err := repo.Delete(created.ID)
+		err := repo.Delete(ctx, created.ID)
 		if err != nil {
 			t.Fatalf("Delete() error = %v", err)
 		}
 
 		// Verify task is deleted
-		_, err = repo.GetByID(created.ID)
+		_, err = repo.GetByID(ctx, created.ID)
 		if err != ErrTaskNotFound {
 			t.Error("Task should be deleted")
 		}
 	})
 
 	t.Run("non-existent task", func(t *testing.T) {
-		err := repo.Delete(999)
+		err := repo.Delete(ctx, 999)
 		if err != ErrTaskNotFound {
 			t.Errorf("Expected ErrTaskNotFound, got %v", err)
 		}
 	})
 }
 
+func TestMemoryRepository_Precondition(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	created, _ := repo.Create(ctx, &models.Task{Title: "Task", Status: models.StatusTodo})
+
+	fail := WithPrecondition(ctx, func(current *models.Task) bool {
+		current.Title = "Changed by the check"
+		return false
+	})
+	if _, err := repo.Update(fail, created.ID, &models.Task{Title: "Renamed", Status: models.StatusTodo}); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("Update() error = %v, want ErrPreconditionFailed", err)
+	}
+	if _, err := repo.SetStatus(fail, created.ID, models.StatusTodo); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("SetStatus() to the same status error = %v, want ErrPreconditionFailed", err)
+	}
+	if err := repo.Delete(fail, created.ID); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("Delete() error = %v, want ErrPreconditionFailed", err)
+	}
+	if got, err := repo.GetByID(ctx, created.ID); err != nil || got.Title != "Task" {
+		t.Fatalf("task after failed preconditions = %+v, %v, want it unchanged", got, err)
+	}
+
+	var seen string
+	pass := WithPrecondition(ctx, func(current *models.Task) bool {
+		seen = current.Title
+		return true
+	})
+	if _, err := repo.Update(pass, created.ID, &models.Task{Title: "Renamed", Status: models.StatusTodo}); err != nil || seen != "Task" {
+		t.Errorf("Update() error = %v, check saw %q, want it to see the stored task", err, seen)
+	}
+	if err := repo.Delete(pass, created.ID); err != nil {
+		t.Errorf("Delete() error = %v", err)
+	}
+	if _, err := repo.Update(pass, created.ID, &models.Task{Title: "Gone"}); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("Update() of a missing task error = %v, want ErrTaskNotFound", err)
+	}
+}
+
+func TestMemoryRepository_Undelete(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository(WithIDFormat(ids.ULID))
+
+	a, _ := repo.Create(ctx, &models.Task{Title: "A"})
+	b, _ := repo.Create(ctx, &models.Task{Title: "B"})
+	c, _ := repo.Create(ctx, &models.Task{Title: "C"})
+	repo.AddLink(ctx, a.ID, models.TaskLink{Type: models.LinkRelatesTo, TaskID: b.ID})
+	a, _ = repo.AddLink(ctx, a.ID, models.TaskLink{Type: models.LinkRelatesTo, TaskID: c.ID})
+	deleted := *a
+	repo.Delete(ctx, a.ID)
+	repo.Delete(ctx, c.ID)
+
+	if _, err := repo.Undelete(WithWorkspace(ctx, "acme"), []*models.Task{&deleted}); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("Undelete() from another workspace error = %v, want ErrTaskNotFound", err)
+	}
+
+	restored, err := repo.Undelete(ctx, []*models.Task{&deleted})
+	if err != nil {
+		t.Fatalf("Undelete() error = %v", err)
+	}
+	// The link to the task that is still deleted is dropped
+	if len(restored) != 1 || restored[0].ID != a.ID || len(restored[0].Links) != 1 || restored[0].Links[0].TaskID != b.ID {
+		t.Fatalf("restored = %+v", restored)
+	}
+	if got, err := repo.GetByUID(ctx, a.UID); err != nil || got.ID != a.ID {
+		t.Errorf("GetByUID() after undelete = %+v, %v", got, err)
+	}
+	tasks, _ := repo.List(ctx, ListOptions{})
+	if len(tasks) != 2 || tasks[0].ID != a.ID || tasks[1].ID != b.ID {
+		t.Errorf("List() after undelete = %+v, want A and B in order", tasks)
+	}
+
+	if _, err := repo.Undelete(ctx, []*models.Task{&deleted}); !errors.Is(err, ErrTaskExists) {
+		t.Errorf("second Undelete() error = %v, want ErrTaskExists", err)
+	}
+}
+
 func TestMemoryRepository_ConcurrentAccess(t *testing.T) {
+	ctx := context.Background()
+
 	repo := NewMemoryRepository()
 	var wg sync.WaitGroup
 
@@ -175,13 +469,13 @@ func TestMemoryRepository_ConcurrentAccess(t *testing.T) {
 		go func(index int) {
 			defer wg.Done()
 			task := &models.Task{Title: "Concurrent Task"}
-			repo.Create(task)
+			repo.Create(ctx, task)
 		}(i)
 	}
 
 	wg.Wait()
 
-	tasks, _ := repo.GetAll()
+	tasks, _ := repo.GetAll(ctx)
 	if len(tasks) != 10 {
 		t.Errorf("Expected 10 tasks, got %d", len(tasks))
 	}
@@ -195,3 +489,718 @@ func TestMemoryRepository_ConcurrentAccess(t *testing.T) {
 		ids[task.ID] = true
 	}
 }
+
+func TestMemoryRepository_ConcurrentMixed(t *testing.T) {
+	ctx := context.Background()
+	repo := newMemoryRepository(4)
+	for range 20 {
+		repo.Create(ctx, &models.Task{Title: "Seed"})
+	}
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 50 {
+				id := int64(j%20 + 1)
+				switch (i + j) % 4 {
+				case 0:
+					repo.Create(ctx, &models.Task{Title: "New"})
+				case 1:
+					repo.Update(ctx, id, &models.Task{Title: "Updated", Status: models.StatusDone})
+				case 2:
+					repo.AddLink(ctx, id, models.TaskLink{Type: models.LinkRelatesTo, TaskID: id%20 + 1})
+				case 3:
+					repo.List(ctx, ListOptions{AfterID: id, Limit: 5})
+				}
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for id := int64(1); id <= 10; id++ {
+			repo.Delete(ctx, id)
+		}
+	}()
+	wg.Wait()
+
+	// Pages must still come back in ascending ID order with no gaps
+	tasks, _ := repo.List(ctx, ListOptions{})
+	if n, _ := repo.Count(ctx, TaskFilter{}); len(tasks) != n {
+		t.Fatalf("List() returned %d tasks, Count() = %d", len(tasks), n)
+	}
+	for i := 1; i < len(tasks); i++ {
+		if tasks[i].ID <= tasks[i-1].ID {
+			t.Fatalf("List() out of order: %d after %d", tasks[i].ID, tasks[i-1].ID)
+		}
+	}
+}
+
+// TestMemoryRepository_ReturnsCopies encodes tasks handed out while they
+// are updated, as handlers do; under -race it fails if the repository
+// shares the tasks it stores
+func TestMemoryRepository_ReturnsCopies(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	task, _ := repo.Create(ctx, &models.Task{Title: "Original"})
+
+	task.Title = "Changed by the caller"
+	if got, _ := repo.GetByID(ctx, task.ID); got.Title != "Original" {
+		t.Fatalf("stored title = %q after changing a returned task", got.Title)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := range 100 {
+			due := time.Now()
+			repo.Update(ctx, task.ID, &models.Task{Title: fmt.Sprint("Update ", i), Status: models.StatusDone, DueAt: &due})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range 100 {
+			tasks, _ := repo.List(ctx, ListOptions{})
+			json.Marshal(tasks)
+		}
+	}()
+	wg.Wait()
+}
+
+func TestMemoryRepository_ConcurrentDeletes(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	const n = 200
+	for range n {
+		repo.Create(ctx, &models.Task{Title: "Task"})
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for id := int64(2); id <= n; id += 2 {
+			repo.Delete(ctx, id)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for id := int64(1); id < n; id++ {
+			repo.AddLink(ctx, id, models.TaskLink{Type: models.LinkRelatesTo, TaskID: id + 1})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range 50 {
+			if _, err := repo.List(ctx, ListOptions{Limit: 20, Filter: TaskFilter{Status: models.StatusTodo}}); err != nil {
+				t.Error(err)
+			}
+			repo.List(ctx, ListOptions{})
+		}
+	}()
+	wg.Wait()
+
+	tasks, _ := repo.List(ctx, ListOptions{})
+	if len(tasks) != n/2 {
+		t.Fatalf("len = %d, want %d", len(tasks), n/2)
+	}
+	for _, task := range tasks {
+		for _, link := range task.Links {
+			if link.TaskID%2 == 0 {
+				t.Errorf("task %d links to deleted task %d", task.ID, link.TaskID)
+			}
+		}
+	}
+}
+
+// BenchmarkMemoryRepository measures single operations on a store of 10k
+// tasks, to catch regressions in the repository before release
+func BenchmarkMemoryRepository(b *testing.B) {
+	const n = 10000
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	for i := range n {
+		repo.Create(ctx, &models.Task{Title: fmt.Sprintf("Task %d", i), Description: "benchmark"})
+	}
+	ids := []int64{1, 500, 2500, 5000, 7500, 9999}
+
+	b.Run("Create", func(b *testing.B) {
+		repo := NewMemoryRepository()
+		for b.Loop() {
+			repo.Create(ctx, &models.Task{Title: "Task"})
+		}
+	})
+	b.Run("GetByID", func(b *testing.B) {
+		for i := 0; b.Loop(); i++ {
+			repo.GetByID(ctx, int64(i%n+1))
+		}
+	})
+	b.Run("GetByIDs", func(b *testing.B) {
+		for b.Loop() {
+			repo.GetByIDs(ctx, ids)
+		}
+	})
+	b.Run("Update", func(b *testing.B) {
+		update := &models.Task{Title: "Updated", Status: models.StatusDone}
+		for i := 0; b.Loop(); i++ {
+			repo.Update(ctx, int64(i%n+1), update)
+		}
+	})
+	b.Run("List/offset", func(b *testing.B) {
+		for b.Loop() {
+			repo.List(ctx, ListOptions{Offset: n / 2, Limit: 50})
+		}
+	})
+	b.Run("List/after", func(b *testing.B) {
+		for b.Loop() {
+			repo.List(ctx, ListOptions{AfterID: n / 2, Limit: 50})
+		}
+	})
+	b.Run("List/scoped", func(b *testing.B) {
+		scoped := WithOwner(ctx, "nobody")
+		for b.Loop() {
+			repo.List(scoped, ListOptions{Limit: 50})
+		}
+	})
+	b.Run("Search", func(b *testing.B) {
+		for b.Loop() {
+			repo.Search(ctx, "task 42")
+		}
+	})
+	b.Run("Count", func(b *testing.B) {
+		for b.Loop() {
+			repo.Count(ctx, TaskFilter{Status: models.StatusDone})
+		}
+	})
+}
+
+// BenchmarkMemoryRepository_Parallel compares one lock against the default
+// shards with many goroutines reading and updating random tasks. Run it on
+// a machine with several cores, e.g. with -cpu 1,8,32.
+// BenchmarkMemoryRepository_Parallel runs a read/write mix on one shard
+// and on the default count, with at least 8 procs and 64 goroutines each,
+// so contention on the locks shows even on a small machine
+func BenchmarkMemoryRepository_Parallel(b *testing.B) {
+	const n = 10000
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(max(8, runtime.GOMAXPROCS(0))))
+	for _, shards := range []int{1, defaultShards} {
+		for _, writes := range []int{20, 80} {
+			b.Run(fmt.Sprintf("shards=%d/writes=%d%%", shards, writes), func(b *testing.B) {
+				ctx := context.Background()
+				repo := newMemoryRepository(shards)
+				for range n {
+					repo.Create(ctx, &models.Task{Title: "Task"})
+				}
+				update := &models.Task{Title: "Updated", Status: models.StatusDone}
+
+				b.SetParallelism(64)
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					rng := rand.New(rand.NewPCG(rand.Uint64(), 0))
+					for pb.Next() {
+						id := rng.Int64N(n) + 1
+						if rng.IntN(100) < writes {
+							repo.Update(ctx, id, update)
+						} else {
+							repo.GetByID(ctx, id)
+						}
+					}
+				})
+			})
+		}
+	}
+}
+
+func TestMemoryRepository_AddLink(t *testing.T) {
+	ctx := context.Background()
+
+	repo := NewMemoryRepository()
+
+	task1, _ := repo.Create(ctx, &models.Task{Title: "Task 1"})
+	task2, _ := repo.Create(ctx, &models.Task{Title: "Task 2"})
+
+	link := models.TaskLink{Type: models.LinkRelatesTo, TaskID: task2.ID}
+
+	t.Run("valid link", func(t *testing.T) {
+		updated, err := repo.AddLink(ctx, task1.ID, link)
+		if err != nil {
+			t.Fatalf("AddLink() error = %v", err)
+		}
+		if len(updated.Links) != 1 || updated.Links[0] != link {
+			t.Errorf("Links = %+v, want [%+v]", updated.Links, link)
+		}
+	})
+
+	t.Run("duplicate link", func(t *testing.T) {
+		_, err := repo.AddLink(ctx, task1.ID, link)
+		if err != ErrLinkExists {
+			t.Errorf("Expected ErrLinkExists, got %v", err)
+		}
+	})
+
+	t.Run("self link", func(t *testing.T) {
+		_, err := repo.AddLink(ctx, task1.ID, models.TaskLink{Type: models.LinkRelatesTo, TaskID: task1.ID})
+		if err != ErrSelfLink {
+			t.Errorf("Expected ErrSelfLink, got %v", err)
+		}
+	})
+
+	t.Run("missing target", func(t *testing.T) {
+		_, err := repo.AddLink(ctx, task1.ID, models.TaskLink{Type: models.LinkRelatesTo, TaskID: 999})
+		if err != ErrLinkTargetNotFound {
+			t.Errorf("Expected ErrLinkTargetNotFound, got %v", err)
+		}
+	})
+
+	t.Run("non-existent task", func(t *testing.T) {
+		_, err := repo.AddLink(ctx, 999, link)
+		if err != ErrTaskNotFound {
+			t.Errorf("Expected ErrTaskNotFound, got %v", err)
+		}
+	})
+
+	t.Run("delete removes links", func(t *testing.T) {
+		if err := repo.Delete(ctx, task2.ID); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		found, _ := repo.GetByID(ctx, task1.ID)
+		if len(found.Links) != 0 {
+			t.Errorf("Links = %+v, want none", found.Links)
+		}
+	})
+}
+
+func TestMemoryRepository_Reset(t *testing.T) {
+	ctx := context.Background()
+
+	repo := NewMemoryRepository()
+	repo.Create(ctx, &models.Task{Title: "Task 1"})
+	repo.Create(ctx, &models.Task{Title: "Task 2"})
+
+	repo.Reset()
+
+	tasks, _ := repo.GetAll(ctx)
+	if len(tasks) != 0 {
+		t.Errorf("got %d tasks after Reset, want 0", len(tasks))
+	}
+
+	created, _ := repo.Create(ctx, &models.Task{Title: "Task 3"})
+	if created.ID != 1 {
+		t.Errorf("ID = %v, want IDs to restart at 1", created.ID)
+	}
+}
+
+func TestMemoryRepository_OwnerScoping(t *testing.T) {
+	repo := NewMemoryRepository()
+	alice := WithOwner(context.Background(), "alice")
+	bob := WithOwner(context.Background(), "bob")
+
+	a1, _ := repo.Create(alice, &models.Task{Title: "Alice 1"})
+	b1, _ := repo.Create(bob, &models.Task{Title: "Bob 1"})
+	a2, _ := repo.Create(alice, &models.Task{Title: "Alice 2"})
+	repo.Create(context.Background(), &models.Task{Title: "Unowned"})
+
+	if a1.OwnerID != "alice" || b1.OwnerID != "bob" {
+		t.Fatalf("owners = %q, %q; want alice, bob", a1.OwnerID, b1.OwnerID)
+	}
+
+	t.Run("reads", func(t *testing.T) {
+		if all, _ := repo.GetAll(alice); len(all) != 2 {
+			t.Errorf("GetAll = %d tasks, want 2", len(all))
+		}
+		if found, _ := repo.Search(alice, "1"); len(found) != 1 || found[0].ID != a1.ID {
+			t.Errorf("Search = %+v, want only Alice 1", found)
+		}
+		if _, err := repo.GetByID(alice, b1.ID); err != ErrTaskNotFound {
+			t.Errorf("GetByID(other owner) error = %v, want ErrTaskNotFound", err)
+		}
+		if all, _ := repo.GetAll(context.Background()); len(all) != 4 {
+			t.Errorf("unscoped GetAll = %d tasks, want 4", len(all))
+		}
+	})
+
+	t.Run("pages", func(t *testing.T) {
+		page, _ := repo.List(alice, ListOptions{Limit: 1})
+		if len(page) != 1 || page[0].ID != a1.ID {
+			t.Fatalf("first page = %+v, want Alice 1", page)
+		}
+		page, _ = repo.List(alice, ListOptions{AfterID: page[0].ID, Limit: 1})
+		if len(page) != 1 || page[0].ID != a2.ID {
+			t.Errorf("cursor page = %+v, want Alice 2", page)
+		}
+		page, _ = repo.List(alice, ListOptions{Offset: 1})
+		if len(page) != 1 || page[0].ID != a2.ID {
+			t.Errorf("offset page = %+v, want Alice 2", page)
+		}
+	})
+
+	t.Run("writes", func(t *testing.T) {
+		if _, err := repo.Update(alice, b1.ID, &models.Task{Title: "Hijacked", Status: models.StatusDone}); err != ErrTaskNotFound {
+			t.Errorf("Update(other owner) error = %v, want ErrTaskNotFound", err)
+		}
+		if err := repo.Delete(alice, b1.ID); err != ErrTaskNotFound {
+			t.Errorf("Delete(other owner) error = %v, want ErrTaskNotFound", err)
+		}
+		if _, err := repo.AddLink(alice, a1.ID, models.TaskLink{Type: models.LinkRelatesTo, TaskID: b1.ID}); err != ErrLinkTargetNotFound {
+			t.Errorf("AddLink(to other owner) error = %v, want ErrLinkTargetNotFound", err)
+		}
+		if got, _ := repo.GetByID(bob, b1.ID); got.Title != "Bob 1" {
+			t.Errorf("Bob's task = %+v, want unchanged", got)
+		}
+	})
+}
+
+func TestMemoryRepository_WorkspaceIsolation(t *testing.T) {
+	repo := NewMemoryRepository()
+	ctx := context.Background()
+	acme := WithWorkspace(ctx, "acme")
+
+	if _, err := repo.Create(acme, &models.Task{Title: "Too early"}); err != ErrWorkspaceNotFound {
+		t.Fatalf("Create(unknown workspace) error = %v, want ErrWorkspaceNotFound", err)
+	}
+	repo.CreateWorkspace(ctx, &models.Workspace{ID: "acme", Name: "Acme"})
+	if _, err := repo.CreateWorkspace(ctx, &models.Workspace{ID: "acme", Name: "Again"}); err != ErrWorkspaceExists {
+		t.Errorf("CreateWorkspace(duplicate) error = %v, want ErrWorkspaceExists", err)
+	}
+
+	def, _ := repo.Create(ctx, &models.Task{Title: "Default"})
+	a1, _ := repo.Create(acme, &models.Task{Title: "Acme"})
+	if def.WorkspaceID != models.DefaultWorkspace || a1.WorkspaceID != "acme" {
+		t.Fatalf("workspaces = %q, %q; want default, acme", def.WorkspaceID, a1.WorkspaceID)
+	}
+
+	defaultCtx := WithWorkspace(ctx, models.DefaultWorkspace)
+	if all, _ := repo.GetAll(acme); len(all) != 1 || all[0].ID != a1.ID {
+		t.Errorf("GetAll(acme) = %+v, want only the acme task", all)
+	}
+	if page, _ := repo.List(defaultCtx, ListOptions{}); len(page) != 1 || page[0].ID != def.ID {
+		t.Errorf("List(default) = %+v, want only the default task", page)
+	}
+	if _, err := repo.GetByID(acme, def.ID); err != ErrTaskNotFound {
+		t.Errorf("GetByID(other workspace) error = %v, want ErrTaskNotFound", err)
+	}
+	if err := repo.Delete(acme, def.ID); err != ErrTaskNotFound {
+		t.Errorf("Delete(other workspace) error = %v, want ErrTaskNotFound", err)
+	}
+	if _, err := repo.AddLink(acme, a1.ID, models.TaskLink{Type: models.LinkRelatesTo, TaskID: def.ID}); err != ErrLinkTargetNotFound {
+		t.Errorf("AddLink(across workspaces) error = %v, want ErrLinkTargetNotFound", err)
+	}
+
+	if err := repo.DeleteWorkspace(ctx, "acme"); err != ErrWorkspaceNotEmpty {
+		t.Errorf("DeleteWorkspace(non-empty) error = %v, want ErrWorkspaceNotEmpty", err)
+	}
+	if err := repo.DeleteWorkspace(ctx, models.DefaultWorkspace); err != ErrDefaultWorkspace {
+		t.Errorf("DeleteWorkspace(default) error = %v, want ErrDefaultWorkspace", err)
+	}
+	repo.Delete(acme, a1.ID)
+	if err := repo.DeleteWorkspace(ctx, "acme"); err != nil {
+		t.Errorf("DeleteWorkspace(empty) error = %v", err)
+	}
+	if list, _ := repo.ListWorkspaces(ctx); len(list) != 1 || list[0].ID != models.DefaultWorkspace {
+		t.Errorf("ListWorkspaces() = %+v, want only default", list)
+	}
+}
+
+func TestMemoryRepository_WebhookScoping(t *testing.T) {
+	repo := NewMemoryRepository()
+	ctx := context.Background()
+	repo.CreateWorkspace(ctx, &models.Workspace{ID: "acme", Name: "Acme"})
+	acme := WithWorkspace(ctx, "acme")
+
+	hook, err := repo.CreateWebhook(acme, &models.Webhook{URL: "https://example.com/hook", Events: []models.TaskEventType{models.EventTaskCreated}})
+	if err != nil || hook.WorkspaceID != "acme" {
+		t.Fatalf("CreateWebhook() = %+v, %v; want a webhook in acme", hook, err)
+	}
+	defaultCtx := WithWorkspace(ctx, models.DefaultWorkspace)
+	if _, err := repo.GetWebhook(defaultCtx, hook.ID); err != ErrWebhookNotFound {
+		t.Errorf("GetWebhook(other workspace) error = %v, want ErrWebhookNotFound", err)
+	}
+	if err := repo.DeleteWebhook(defaultCtx, hook.ID); err != ErrWebhookNotFound {
+		t.Errorf("DeleteWebhook(other workspace) error = %v, want ErrWebhookNotFound", err)
+	}
+	if list, _ := repo.ListWebhooks(acme); len(list) != 1 {
+		t.Errorf("ListWebhooks(acme) = %d webhooks, want 1", len(list))
+	}
+
+	// Deleting the workspace takes its webhooks along
+	repo.DeleteWorkspace(ctx, "acme")
+	if list, _ := repo.ListWebhooks(ctx); len(list) != 0 {
+		t.Errorf("webhooks after deleting the workspace = %+v, want none", list)
+	}
+}
+
+func TestMemoryRepository_LastModified(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+
+	last, _ := repo.LastModified(ctx)
+	if last.IsZero() {
+		t.Fatal("LastModified is zero for a new repository")
+	}
+	advanced := func(op string) {
+		t.Helper()
+		now, err := repo.LastModified(ctx)
+		if err != nil || !now.After(last) {
+			t.Errorf("after %s: LastModified = %v, %v; want after %v", op, now, err, last)
+		}
+		last = now
+	}
+
+	time.Sleep(time.Millisecond)
+	task, _ := repo.Create(ctx, &models.Task{Title: "a"})
+	advanced("Create")
+	if !last.Equal(task.UpdatedAt) {
+		t.Errorf("LastModified = %v, want the task's UpdatedAt %v", last, task.UpdatedAt)
+	}
+
+	time.Sleep(time.Millisecond)
+	repo.Update(ctx, task.ID, &models.Task{Title: "b", Status: models.StatusDone})
+	advanced("Update")
+
+	time.Sleep(time.Millisecond)
+	repo.GetByID(ctx, task.ID)
+	repo.List(ctx, ListOptions{})
+	if now, _ := repo.LastModified(ctx); !now.Equal(last) {
+		t.Errorf("reads changed LastModified to %v", now)
+	}
+
+	repo.Delete(ctx, task.ID)
+	advanced("Delete")
+}
+
+func TestMemoryRepository_WithTx(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	keep, _ := repo.Create(ctx, &models.Task{Title: "keep"})
+
+	errAbort := errors.New("abort")
+	err := repo.WithTx(ctx, func(tx TaskRepository) error {
+		tx.Create(ctx, &models.Task{Title: "dropped"})
+		tx.Update(ctx, keep.ID, &models.Task{Title: "renamed", Status: models.StatusDone})
+		tx.Delete(ctx, keep.ID)
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("WithTx error = %v, want errAbort", err)
+	}
+	if tasks, _ := repo.GetAll(ctx); len(tasks) != 1 || tasks[0].Title != "keep" || tasks[0].Status != models.StatusTodo {
+		t.Fatalf("after rollback: tasks = %+v, want only the untouched task", tasks)
+	}
+
+	// Other calls wait for the transaction and see it whole
+	inTx, proceed := make(chan struct{}), make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- repo.WithTx(ctx, func(tx TaskRepository) error {
+			tx.Create(ctx, &models.Task{Title: "first"})
+			close(inTx)
+			<-proceed
+			_, err := tx.Create(ctx, &models.Task{Title: "second"})
+			return err
+		})
+	}()
+	<-inTx
+	counted := make(chan int)
+	go func() {
+		n, _ := repo.Count(ctx, TaskFilter{})
+		counted <- n
+	}()
+	close(proceed)
+	if err := <-done; err != nil {
+		t.Fatalf("WithTx error = %v", err)
+	}
+	if n := <-counted; n != 3 {
+		t.Errorf("Count during the transaction = %d, want 3 once it committed", n)
+	}
+
+	// IDs continue from the committed transaction
+	created, _ := repo.Create(ctx, &models.Task{Title: "fourth"})
+	if created.ID != 4 {
+		t.Errorf("ID after commit = %d, want 4", created.ID)
+	}
+}
+
+func TestLimitedRepository_Create(t *testing.T) {
+	ctx := context.Background()
+
+	repo := NewLimitedRepository(NewMemoryRepository(), 2)
+
+	for i := 0; i < 2; i++ {
+		if _, err := repo.Create(ctx, &models.Task{Title: "Task"}); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	if _, err := repo.Create(ctx, &models.Task{Title: "One too many"}); err != ErrTaskLimitReached {
+		t.Errorf("Expected ErrTaskLimitReached, got %v", err)
+	}
+
+	first, _ := repo.GetByID(ctx, 1)
+	deleted := *first
+	repo.Delete(ctx, 1)
+	if _, err := repo.Create(ctx, &models.Task{Title: "Fits again"}); err != nil {
+		t.Errorf("Create() after delete error = %v", err)
+	}
+	if _, err := repo.Undelete(ctx, []*models.Task{&deleted}); err != ErrTaskLimitReached {
+		t.Errorf("Undelete() over the limit error = %v, want ErrTaskLimitReached", err)
+	}
+}
+
+func TestMemoryRepository_Compact(t *testing.T) {
+	ctx := context.Background()
+
+	repo := NewMemoryRepository()
+	repo.Restore([]*models.Task{
+		{ID: 1, Title: "a", Links: []models.TaskLink{{Type: models.LinkRelatesTo, TaskID: 2}, {Type: models.LinkCausedBy, TaskID: 7}}},
+		{ID: 2, Title: "b"},
+	}, 0)
+
+	result, err := repo.Compact(ctx)
+	if err != nil || result.DanglingLinks != 1 {
+		t.Fatalf("Compact() = %+v, %v; want 1 dangling link", result, err)
+	}
+	task, _ := repo.GetByID(ctx, 1)
+	if len(task.Links) != 1 || task.Links[0].TaskID != 2 {
+		t.Errorf("links = %+v, want only the link to task 2", task.Links)
+	}
+
+	stats, _ := repo.Stats(ctx)
+	if stats != (Stats{Tasks: 2, Workspaces: 1, LastID: 2}) {
+		t.Errorf("Stats() = %+v", stats)
+	}
+	if created, _ := repo.Create(ctx, &models.Task{Title: "c"}); created.ID != 3 {
+		t.Errorf("ID after Compact = %v, want 3", created.ID)
+	}
+}
+
+func TestMemoryRepository_StatsCycleTime(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+
+	if stats, _ := repo.Stats(ctx); stats.LeadTime != nil || stats.CycleTime != nil {
+		t.Errorf("Stats() with no done tasks = %+v", stats)
+	}
+
+	start := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	var tasks []*models.Task
+	for i, hours := range []int{1, 2, 10} {
+		completed := start.Add(time.Duration(hours) * time.Hour)
+		tasks = append(tasks, &models.Task{ID: int64(i + 1), WorkspaceID: models.DefaultWorkspace, Title: "done", Status: models.StatusDone, CreatedAt: start, CompletedAt: &completed})
+	}
+	// Neither a todo task nor a done one without a completion time counts
+	tasks = append(tasks,
+		&models.Task{ID: 4, WorkspaceID: models.DefaultWorkspace, Title: "todo", Status: models.StatusTodo, CreatedAt: start},
+		&models.Task{ID: 5, WorkspaceID: models.DefaultWorkspace, Title: "old", Status: models.StatusDone, CreatedAt: start},
+	)
+	if _, err := repo.Undelete(ctx, tasks); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, _ := repo.Stats(ctx)
+	want := DurationStats{Count: 3, Avg: 13 * 3600 / 3, P50: 2 * 3600, P90: 10 * 3600}
+	if stats.LeadTime == nil || *stats.LeadTime != want {
+		t.Errorf("LeadTime = %+v, want %+v", stats.LeadTime, want)
+	}
+	if stats.CycleTime == nil || *stats.CycleTime != want {
+		t.Errorf("CycleTime = %+v, want %+v", stats.CycleTime, want)
+	}
+}
+
+func TestOpenedAt(t *testing.T) {
+	created := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	at := func(hours int) time.Time { return created.Add(time.Duration(hours) * time.Hour) }
+	task := &models.Task{Status: models.StatusDone, CreatedAt: created}
+
+	tests := []struct {
+		name      string
+		revisions []*models.TaskRevision
+		want      time.Time
+	}{
+		{"never reopened", nil, created},
+		{"edited while todo", []*models.TaskRevision{
+			{Status: models.StatusTodo, ReplacedAt: at(1)},
+		}, created},
+		{"reopened", []*models.TaskRevision{
+			{Status: models.StatusTodo, ReplacedAt: at(1)},
+			{Status: models.StatusDone, ReplacedAt: at(2)},
+			{Status: models.StatusTodo, ReplacedAt: at(3)},
+		}, at(2)},
+		{"edited while done", []*models.TaskRevision{
+			{Status: models.StatusTodo, ReplacedAt: at(1)},
+			{Status: models.StatusDone, ReplacedAt: at(2)},
+		}, created},
+		{"reopened twice", []*models.TaskRevision{
+			{Status: models.StatusDone, ReplacedAt: at(1)},
+			{Status: models.StatusTodo, ReplacedAt: at(2)},
+			{Status: models.StatusDone, ReplacedAt: at(4)},
+			{Status: models.StatusTodo, ReplacedAt: at(5)},
+		}, at(4)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := openedAt(task, tt.revisions); !got.Equal(tt.want) {
+				t.Errorf("openedAt() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryRepository_RotateAPIKey(t *testing.T) {
+	ctx := context.Background()
+
+	repo := NewMemoryRepository()
+	key, _ := repo.CreateAPIKey(ctx, &models.APIKey{Name: "ci", Scope: models.ScopeRead, Prefix: "ctk_old", Hash: "old"})
+
+	rotated, err := repo.RotateAPIKey(ctx, key.ID, "ctk_new", "new")
+	if err != nil || rotated.ID != key.ID || rotated.Name != "ci" || rotated.Prefix != "ctk_new" {
+		t.Fatalf("RotateAPIKey() = %+v, %v", rotated, err)
+	}
+	if _, err := repo.GetAPIKeyByHash(ctx, "old"); err != ErrAPIKeyNotFound {
+		t.Errorf("old hash error = %v, want ErrAPIKeyNotFound", err)
+	}
+	if found, err := repo.GetAPIKeyByHash(ctx, "new"); err != nil || found.ID != key.ID {
+		t.Errorf("GetAPIKeyByHash(new) = %+v, %v", found, err)
+	}
+	if _, err := repo.RotateAPIKey(ctx, 99, "ctk_x", "x"); err != ErrAPIKeyNotFound {
+		t.Errorf("unknown ID error = %v, want ErrAPIKeyNotFound", err)
+	}
+	if keys, _ := repo.ListAPIKeys(ctx); len(keys) != 1 || keys[0].Hash != "new" {
+		t.Errorf("ListAPIKeys() = %+v", keys)
+	}
+}
+
+func TestMemoryRepository_AnonymizeOwner(t *testing.T) {
+	ctx := context.Background()
+	alice := WithOwner(ctx, "alice")
+
+	repo := NewMemoryRepository()
+	first, _ := repo.Create(alice, &models.Task{Title: "Call back", Description: "Jane, 555-0100", Status: models.StatusTodo})
+	repo.Update(alice, first.ID, &models.Task{Title: "Call back", Description: "Jane, 555-0199", Status: models.StatusTodo})
+	repo.Create(WithOwner(ctx, "bob"), &models.Task{Title: "Bob's", Description: "kept"})
+	second, _ := repo.Create(alice, &models.Task{Title: "Write report"})
+
+	if ids, err := repo.AnonymizeOwner(ctx, ""); err != nil || len(ids) != 0 {
+		t.Fatalf("AnonymizeOwner(\"\") = %v, %v, want nothing changed", ids, err)
+	}
+	ids, err := repo.AnonymizeOwner(ctx, "alice")
+	if err != nil || !slices.Equal(ids, []int64{first.ID, second.ID}) {
+		t.Fatalf("AnonymizeOwner() = %v, %v, want tasks %d and %d", ids, err, first.ID, second.ID)
+	}
+
+	task, _ := repo.GetByID(ctx, first.ID)
+	if task.OwnerID != "" || task.Description != "" || task.Title != "Call back" || task.Status != models.StatusTodo {
+		t.Errorf("anonymized task = %+v, want only the owner and description cleared", task)
+	}
+	if revs, _ := repo.ListRevisions(ctx, first.ID); len(revs) != 0 {
+		t.Errorf("revisions = %+v, want them dropped", revs)
+	}
+	if tasks, _ := repo.GetAll(alice); len(tasks) != 0 {
+		t.Errorf("alice still owns %d tasks", len(tasks))
+	}
+	if tasks, _ := repo.GetAll(WithOwner(ctx, "bob")); len(tasks) != 1 || tasks[0].Description != "kept" {
+		t.Errorf("bob's tasks = %+v, want them untouched", tasks)
+	}
+}
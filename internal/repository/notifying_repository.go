@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+)
+
+// NotifyFunc is told about each change to a task, with the task as it is
+// after the change, or as it was before a delete
+type NotifyFunc func(ctx context.Context, event models.TaskEventType, task *models.Task)
+
+// NotifyHooks returns Hooks that report every successful change to each
+// of notify in turn: task.created for creates and undeletes, task.updated
+// for updates and links, and task.completed as well when an update moves
+// a task to done, and task.deleted. Notifications are sent after the
+// change is stored, so they must not block.
+func NotifyHooks(notify ...NotifyFunc) Hooks {
+	return notifyHooks{notify: notify}
+}
+
+// NewNotifyingRepository wraps repo so that changes are reported to notify
+func NewNotifyingRepository(repo TaskRepository, notify ...NotifyFunc) *HookedRepository {
+	return NewHookedRepository(repo, NotifyHooks(notify...))
+}
+
+type notifyHooks struct {
+	NopHooks
+	notify []NotifyFunc
+}
+
+// emit reports a change to every NotifyFunc
+func (n notifyHooks) emit(ctx context.Context, event models.TaskEventType, task *models.Task) {
+	for _, notify := range n.notify {
+		notify(ctx, event, task)
+	}
+}
+
+func (n notifyHooks) AfterCreate(ctx context.Context, task *models.Task) {
+	n.emit(ctx, models.EventTaskCreated, task)
+}
+
+func (n notifyHooks) AfterUpdate(ctx context.Context, before, after *models.Task) {
+	n.emit(ctx, models.EventTaskUpdated, after)
+	if after.Status == models.StatusDone && before.Status != models.StatusDone {
+		n.emit(ctx, models.EventTaskCompleted, after)
+	}
+}
+
+func (n notifyHooks) AfterDelete(ctx context.Context, task *models.Task) {
+	n.emit(ctx, models.EventTaskDeleted, task)
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+)
+
+func TestNotifyingRepository(t *testing.T) {
+	ctx := context.Background()
+	var events []models.TaskEventType
+	repo := NewNotifyingRepository(NewMemoryRepository(), func(ctx context.Context, event models.TaskEventType, task *models.Task) {
+		events = append(events, event)
+	})
+
+	task, _ := repo.Create(ctx, &models.Task{Title: "Write report"})
+	other, _ := repo.Create(ctx, &models.Task{Title: "Review"})
+	repo.Update(ctx, task.ID, &models.Task{Title: "Write report", Status: models.StatusTodo})
+	repo.Update(ctx, task.ID, &models.Task{Title: "Write report", Status: models.StatusDone})
+	repo.Update(ctx, task.ID, &models.Task{Title: "Write the report", Status: models.StatusDone})
+	repo.AddLink(ctx, task.ID, models.TaskLink{Type: models.LinkRelatesTo, TaskID: other.ID})
+	repo.SetStatus(ctx, other.ID, models.StatusDone)
+	repo.SetStatus(ctx, other.ID, models.StatusDone) // unchanged: no event
+	repo.SetStatus(ctx, other.ID, models.StatusTodo)
+	repo.Delete(ctx, task.ID)
+	repo.Delete(ctx, task.ID) // already gone: no event
+	repo.Undelete(ctx, []*models.Task{task})
+
+	want := []models.TaskEventType{
+		models.EventTaskCreated, models.EventTaskCreated,
+		models.EventTaskUpdated,
+		models.EventTaskUpdated, models.EventTaskCompleted,
+		models.EventTaskUpdated,
+		models.EventTaskUpdated,
+		models.EventTaskUpdated, models.EventTaskCompleted,
+		models.EventTaskUpdated,
+		models.EventTaskDeleted,
+		models.EventTaskCreated,
+	}
+	if !slices.Equal(events, want) {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+}
+
+func TestNotifyingRepository_WithTx(t *testing.T) {
+	ctx := context.Background()
+	var events []models.TaskEventType
+	repo := NewNotifyingRepository(NewMemoryRepository(), func(ctx context.Context, event models.TaskEventType, task *models.Task) {
+		events = append(events, event)
+	})
+
+	errAbort := errors.New("abort")
+	repo.WithTx(ctx, func(tx TaskRepository) error {
+		tx.Create(ctx, &models.Task{Title: "dropped"})
+		return errAbort
+	})
+	if len(events) != 0 {
+		t.Errorf("rolled back transaction reported %v", events)
+	}
+
+	repo.WithTx(ctx, func(tx TaskRepository) error {
+		task, _ := tx.Create(ctx, &models.Task{Title: "kept"})
+		if len(events) != 0 {
+			t.Error("change reported before commit")
+		}
+		_, err := tx.Update(ctx, task.ID, &models.Task{Title: "kept", Status: models.StatusDone})
+		return err
+	})
+	want := []models.TaskEventType{models.EventTaskCreated, models.EventTaskUpdated, models.EventTaskCompleted}
+	if !slices.Equal(events, want) {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+}
@@ -0,0 +1,203 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+)
+
+// paginationBackends lists every TaskRepository implementation the
+// pagination harness runs against
+var paginationBackends = map[string]func() TaskRepository{
+	"memory": func() TaskRepository { return NewMemoryRepository() },
+}
+
+// seedTasks fills repo with n synthetic tasks and returns their IDs
+func seedTasks(t testing.TB, repo TaskRepository, n int) []int64 {
+	ctx := context.Background()
+
+	t.Helper()
+
+	ids := make([]int64, 0, n)
+	for i := 0; i < n; i++ {
+		status := models.StatusTodo
+		if i%3 == 0 {
+			status = models.StatusDone
+		}
+		task, err := repo.Create(ctx, &models.Task{
+			Title:       fmt.Sprintf("Synthetic task %d", i),
+			Description: fmt.Sprintf("Seeded by the pagination harness (%d)", i),
+			Status:      status,
+		})
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		ids = append(ids, task.ID)
+	}
+
+	return ids
+}
+
+// scanProgress is how far a paginated scan has got, shared with the
+// writers churning the repository under it
+type scanProgress struct {
+	last          atomic.Int64 // the last ID the scan has returned
+	deletedBehind atomic.Int64 // tasks deleted at or before last
+}
+
+// churn creates, updates and deletes tasks until stop is closed, simulating
+// concurrent writers during a paginated scan. It deletes seeded tasks the
+// scan has already passed, which shifts every later offset.
+func churn(repo TaskRepository, seeded []int64, progress *scanProgress, stop <-chan struct{}, wg *sync.WaitGroup, writes *atomic.Int64) {
+	ctx := context.Background()
+
+	defer wg.Done()
+
+	for i := 0; ; i++ {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		created, err := repo.Create(ctx, &models.Task{Title: "Churn task"})
+		if err != nil {
+			continue
+		}
+		repo.Update(ctx, seeded[i%len(seeded)], &models.Task{
+			Title:  fmt.Sprintf("Updated during scan %d", i),
+			Status: models.StatusDone,
+		})
+		if victim := seeded[rand.IntN(len(seeded))]; victim <= progress.last.Load() {
+			if repo.Delete(ctx, victim) == nil {
+				progress.deletedBehind.Add(1)
+			}
+		}
+		if i%2 == 0 && repo.Delete(ctx, created.ID) == nil && created.ID <= progress.last.Load() {
+			progress.deletedBehind.Add(1)
+		}
+		writes.Add(1)
+	}
+}
+
+// collectPages pages through repo with the given page size, either with the
+// keyset cursor or with offsets, and returns every ID seen in order
+func collectPages(t *testing.T, repo TaskRepository, pageSize int, useCursor bool, progress *scanProgress) []int64 {
+	ctx := context.Background()
+
+	t.Helper()
+
+	var seen []int64
+	opts := ListOptions{Limit: pageSize}
+	for {
+		page, err := repo.List(ctx, opts)
+		if err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+		for _, task := range page {
+			seen = append(seen, task.ID)
+		}
+		if len(page) < pageSize {
+			return seen
+		}
+		progress.last.Store(page[len(page)-1].ID)
+		if useCursor {
+			opts.AfterID = page[len(page)-1].ID
+		} else {
+			opts.Offset += len(page)
+		}
+
+		// Let the writers in between pages, even on one CPU
+		runtime.Gosched()
+	}
+}
+
+// paginationTasks is the number of tasks the harness seeds, raised with
+// TASKS_PAGINATION_TASKS for a run at scale, such as 100000
+func paginationTasks(t *testing.T) int {
+	v := os.Getenv("TASKS_PAGINATION_TASKS")
+	if v == "" {
+		return 2_000
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		t.Fatalf("TASKS_PAGINATION_TASKS = %q, want a positive number", v)
+	}
+	return n
+}
+
+// TestPagination_Harness seeds a dataset and pages through it while
+// concurrent writers create, update and delete tasks, including ones
+// behind the scan. Pages must stay in order without duplicates. The cursor
+// must return every seeded task; offsets, which shift when a task behind
+// them is deleted, may skip at most one task per such delete.
+func TestPagination_Harness(t *testing.T) {
+	n := paginationTasks(t)
+
+	for name, newRepo := range paginationBackends {
+		for _, mode := range []string{"cursor", "offset"} {
+			t.Run(name+"/"+mode, func(t *testing.T) {
+				repo := newRepo()
+				if mode == "cursor" && !repo.Capabilities().Cursors {
+					t.Skip("backend does not support cursors")
+				}
+
+				seeded := seedTasks(t, repo, n)
+
+				stop := make(chan struct{})
+				var wg sync.WaitGroup
+				var writes atomic.Int64
+				var progress scanProgress
+				for i := 0; i < 4; i++ {
+					wg.Add(1)
+					go churn(repo, seeded, &progress, stop, &wg, &writes)
+				}
+
+				seen := collectPages(t, repo, 250, mode == "cursor", &progress)
+
+				close(stop)
+				wg.Wait()
+
+				counts := make(map[int64]int, len(seen))
+				for i, id := range seen {
+					counts[id]++
+					if i > 0 && id <= seen[i-1] {
+						t.Fatalf("page order broken at %d: %d after %d", i, id, seen[i-1])
+					}
+				}
+
+				for id, c := range counts {
+					if c > 1 {
+						t.Errorf("task %d returned %d times", id, c)
+					}
+				}
+
+				// Seeded tasks are only deleted once the scan has passed
+				// them, so each one it did not return is a gap
+				gaps := 0
+				for _, id := range seeded {
+					if counts[id] == 0 {
+						gaps++
+					}
+				}
+				deleted := int(progress.deletedBehind.Load())
+				if mode == "cursor" && gaps > 0 {
+					t.Errorf("cursor scan skipped %d tasks", gaps)
+				}
+				if mode == "offset" && gaps > deleted {
+					t.Errorf("offset scan skipped %d tasks with %d deleted behind it", gaps, deleted)
+				}
+
+				t.Logf("scanned %d tasks with %d concurrent writes, %d deletes behind the scan and %d gaps", len(seen), writes.Load(), deleted, gaps)
+			})
+		}
+	}
+}
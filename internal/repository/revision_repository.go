@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+)
+
+// ErrRevisionNotFound is returned when a task has no revision with the
+// requested number
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// MaxRevisions is how many revisions are kept per task; older ones are
+// dropped, and the numbers of the rest do not change
+const MaxRevisions = 50
+
+// RevisionRepository reads the previous versions of tasks, which Update
+// keeps. Revisions are scoped like their task and go when it is deleted.
+type RevisionRepository interface {
+	// ListRevisions returns the kept revisions of a task, oldest first, or
+	// ErrTaskNotFound
+	ListRevisions(ctx context.Context, id int64) ([]*models.TaskRevision, error)
+
+	// GetRevision returns revision n of a task, or ErrTaskNotFound or
+	// ErrRevisionNotFound
+	GetRevision(ctx context.Context, id int64, n int) (*models.TaskRevision, error)
+}
+
+// addRevision keeps task as it is before an update at now; the shard
+// holding it must be locked for writing
+func (sh *shard) addRevision(task *models.Task, now time.Time) {
+	revs := sh.revisions[task.ID]
+	n := 1
+	if len(revs) > 0 {
+		n = revs[len(revs)-1].Number + 1
+	}
+	if len(revs) >= MaxRevisions {
+		revs = slices.Delete(revs, 0, len(revs)-MaxRevisions+1)
+	}
+	sh.revisions[task.ID] = append(revs, &models.TaskRevision{
+		TaskID:      task.ID,
+		Number:      n,
+		Title:       task.Title,
+		Description: task.Description,
+		Status:      task.Status,
+		DueAt:       copyTime(task.DueAt),
+		Estimate:    task.Estimate,
+		UpdatedAt:   task.UpdatedAt,
+		ReplacedAt:  now,
+	})
+}
+
+// ListRevisions returns copies of the kept revisions of a task in scope
+func (r *MemoryRepository) ListRevisions(ctx context.Context, id int64) ([]*models.TaskRevision, error) {
+	sh := r.shardFor(id)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+
+	task, exists := sh.tasks[id]
+	if !exists || !scopeFrom(ctx).allows(task) {
+		return nil, ErrTaskNotFound
+	}
+	revs := make([]*models.TaskRevision, len(sh.revisions[id]))
+	for i, rev := range sh.revisions[id] {
+		revs[i] = copyRevision(rev)
+	}
+	return revs, nil
+}
+
+// GetRevision returns a copy of revision n of a task in scope
+func (r *MemoryRepository) GetRevision(ctx context.Context, id int64, n int) (*models.TaskRevision, error) {
+	revs, err := r.ListRevisions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	i := sort.Search(len(revs), func(i int) bool { return revs[i].Number >= n })
+	if i == len(revs) || revs[i].Number != n {
+		return nil, ErrRevisionNotFound
+	}
+	return revs[i], nil
+}
+
+// RevisionSnapshot returns copies of every kept revision, by task ID and
+// then number, for persisting the repository
+func (r *MemoryRepository) RevisionSnapshot() []*models.TaskRevision {
+	r.rlockAll()
+	defer r.runlockAll()
+
+	var revs []*models.TaskRevision
+	for _, id := range r.order {
+		for _, rev := range r.shardFor(id).revisions[id] {
+			revs = append(revs, copyRevision(rev))
+		}
+	}
+	return revs
+}
+
+// RestoreRevisions replaces the kept revisions, dropping those of tasks
+// that do not exist; call it after Restore
+func (r *MemoryRepository) RestoreRevisions(revs []*models.TaskRevision) {
+	r.lockAll()
+	defer r.unlockAll()
+
+	for _, sh := range r.shards {
+		sh.revisions = make(map[int64][]*models.TaskRevision)
+	}
+	for _, rev := range revs {
+		sh := r.shardFor(rev.TaskID)
+		if _, ok := sh.tasks[rev.TaskID]; ok {
+			sh.revisions[rev.TaskID] = append(sh.revisions[rev.TaskID], rev)
+		}
+	}
+	for _, sh := range r.shards {
+		for id, revs := range sh.revisions {
+			slices.SortFunc(revs, func(a, b *models.TaskRevision) int { return a.Number - b.Number })
+			sh.revisions[id] = revs[max(0, len(revs)-MaxRevisions):]
+		}
+	}
+}
+
+// copyRevision returns a copy of rev that shares no due date with it
+func copyRevision(rev *models.TaskRevision) *models.TaskRevision {
+	c := *rev
+	c.DueAt = copyTime(rev.DueAt)
+	return &c
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+)
+
+func TestMemoryRepository_Revisions(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+
+	task, _ := repo.Create(ctx, &models.Task{Title: "v1"})
+	created := task.UpdatedAt
+	repo.Update(ctx, task.ID, &models.Task{Title: "v2", Status: models.StatusTodo})
+	// An update that changes nothing keeps no revision
+	repo.Update(ctx, task.ID, &models.Task{Title: "v2", Status: models.StatusTodo})
+	repo.Update(ctx, task.ID, &models.Task{Title: "v3", Status: models.StatusDone})
+
+	revs, err := repo.ListRevisions(ctx, task.ID)
+	if err != nil {
+		t.Fatalf("ListRevisions() error = %v", err)
+	}
+	if len(revs) != 2 || revs[0].Number != 1 || revs[0].Title != "v1" || revs[1].Number != 2 || revs[1].Title != "v2" {
+		t.Fatalf("revisions = %+v, want v1 and v2", revs)
+	}
+	if !revs[0].UpdatedAt.Equal(created) || revs[0].ReplacedAt.Before(revs[0].UpdatedAt) {
+		t.Errorf("revision 1 times = %v, %v", revs[0].UpdatedAt, revs[0].ReplacedAt)
+	}
+
+	rev, err := repo.GetRevision(ctx, task.ID, 2)
+	if err != nil || rev.Title != "v2" {
+		t.Errorf("GetRevision(2) = %+v, %v", rev, err)
+	}
+	if _, err := repo.GetRevision(ctx, task.ID, 3); !errors.Is(err, ErrRevisionNotFound) {
+		t.Errorf("GetRevision(3) error = %v, want ErrRevisionNotFound", err)
+	}
+
+	// Revisions are scoped like their task
+	other := WithWorkspace(ctx, "acme")
+	if _, err := repo.ListRevisions(other, task.ID); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("ListRevisions from another workspace error = %v, want ErrTaskNotFound", err)
+	}
+
+	repo.Delete(ctx, task.ID)
+	if _, err := repo.ListRevisions(ctx, task.ID); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("ListRevisions after delete error = %v, want ErrTaskNotFound", err)
+	}
+}
+
+func TestMemoryRepository_RevisionsAreCapped(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+
+	task, _ := repo.Create(ctx, &models.Task{Title: "v0"})
+	for i := 1; i <= MaxRevisions+5; i++ {
+		repo.Update(ctx, task.ID, &models.Task{Title: fmt.Sprintf("v%d", i), Status: models.StatusTodo})
+	}
+
+	revs, _ := repo.ListRevisions(ctx, task.ID)
+	if len(revs) != MaxRevisions {
+		t.Fatalf("kept %d revisions, want %d", len(revs), MaxRevisions)
+	}
+	// The oldest are dropped without renumbering the rest
+	if revs[0].Number != 6 || revs[0].Title != "v5" || revs[len(revs)-1].Number != MaxRevisions+5 {
+		t.Errorf("revisions run from %+v to %+v", revs[0], revs[len(revs)-1])
+	}
+}
+
+func TestMemoryRepository_RevisionsInTx(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+
+	task, _ := repo.Create(ctx, &models.Task{Title: "v1"})
+	repo.Update(ctx, task.ID, &models.Task{Title: "v2", Status: models.StatusTodo})
+	err := repo.WithTx(ctx, func(tx TaskRepository) error {
+		_, err := tx.Update(ctx, task.ID, &models.Task{Title: "v3", Status: models.StatusTodo})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WithTx() error = %v", err)
+	}
+
+	revs, _ := repo.ListRevisions(ctx, task.ID)
+	if len(revs) != 2 || revs[1].Title != "v2" {
+		t.Errorf("revisions after a transaction = %+v, want v1 and v2", revs)
+	}
+}
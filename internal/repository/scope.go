@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+)
+
+// ownerKey is the context key for the owner repository calls act for
+type ownerKey struct{}
+
+// workspaceKey is the context key for the workspace repository calls act in
+type workspaceKey struct{}
+
+// WithOwner scopes repository calls made with the returned context to the
+// tasks of owner: created tasks belong to owner, and tasks of other owners
+// are invisible, as if they did not exist. Calls without an owner see
+// every task.
+func WithOwner(ctx context.Context, owner string) context.Context {
+	return context.WithValue(ctx, ownerKey{}, owner)
+}
+
+// OwnerFromContext returns the owner set by WithOwner, or ""
+func OwnerFromContext(ctx context.Context) string {
+	owner, _ := ctx.Value(ownerKey{}).(string)
+	return owner
+}
+
+// WithWorkspace scopes repository calls made with the returned context to
+// workspace, the same way WithOwner scopes them to an owner. Created tasks
+// go to models.DefaultWorkspace when no workspace is set.
+func WithWorkspace(ctx context.Context, workspace string) context.Context {
+	return context.WithValue(ctx, workspaceKey{}, workspace)
+}
+
+// WorkspaceFromContext returns the workspace set by WithWorkspace, or ""
+func WorkspaceFromContext(ctx context.Context) string {
+	workspace, _ := ctx.Value(workspaceKey{}).(string)
+	return workspace
+}
+
+// scope is the part of the task space a call may see
+type scope struct {
+	owner     string
+	workspace string
+}
+
+// scopeFrom returns the scope set on ctx
+func scopeFrom(ctx context.Context) scope {
+	return scope{owner: OwnerFromContext(ctx), workspace: WorkspaceFromContext(ctx)}
+}
+
+// unrestricted reports whether the scope sees every task
+func (s scope) unrestricted() bool {
+	return s == scope{}
+}
+
+// allows reports whether a call in the scope may see task
+func (s scope) allows(task *models.Task) bool {
+	return (s.owner == "" || task.OwnerID == s.owner) &&
+		(s.workspace == "" || task.WorkspaceID == s.workspace)
+}
+
+// preconditionKey is the context key for the check writes must pass
+type preconditionKey struct{}
+
+// WithPrecondition makes Update, SetStatus and Delete called with the
+// returned context run check on the task as stored, under the same lock as
+// the write, and fail with ErrPreconditionFailed instead of writing when
+// it returns false. Conditional requests use it to compare and swap.
+func WithPrecondition(ctx context.Context, check func(current *models.Task) bool) context.Context {
+	return context.WithValue(ctx, preconditionKey{}, check)
+}
+
+// checkPrecondition runs the check set by WithPrecondition on a copy of
+// task, returning ErrPreconditionFailed if it fails
+func checkPrecondition(ctx context.Context, task *models.Task) error {
+	check, _ := ctx.Value(preconditionKey{}).(func(*models.Task) bool)
+	if check != nil && !check(copyTask(task)) {
+		return ErrPreconditionFailed
+	}
+	return nil
+}
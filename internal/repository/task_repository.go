@@ -1,28 +1,154 @@
 package repository
 
 import (
+	"context"
 	"errors"
+	"time"
 
 	"github.com/light-bringer/cert-tasks/internal/models"
 )
 
-// ErrTaskNotFound is returned when a task is not found
-var ErrTaskNotFound = errors.New("task not found")
+var (
+	// ErrTaskNotFound is returned when a task is not found
+	ErrTaskNotFound = errors.New("task not found")
+
+	// ErrLinkTargetNotFound is returned when a link points to a missing task
+	ErrLinkTargetNotFound = errors.New("linked task not found")
+
+	// ErrSelfLink is returned when a task is linked to itself
+	ErrSelfLink = errors.New("task cannot be linked to itself")
+
+	// ErrLinkExists is returned when an identical link already exists
+	ErrLinkExists = errors.New("link already exists")
+
+	// ErrTaskExists is returned when undeleting a task whose ID is taken
+	ErrTaskExists = errors.New("task already exists")
+
+	// ErrPreconditionFailed is returned when a write's precondition, set
+	// with WithPrecondition, does not hold for the stored task
+	ErrPreconditionFailed = errors.New("task precondition failed")
+
+	// ErrTaskLimitReached is returned when creating a task would exceed the
+	// configured maximum number of stored tasks
+	ErrTaskLimitReached = errors.New("task limit reached")
+
+	// ErrNotSupported is returned when a backend lacks the capability an
+	// operation requires
+	ErrNotSupported = errors.New("operation not supported by storage backend")
+
+	// ErrUnavailable is returned, usually wrapped, when a backend subsystem
+	// such as a search index is temporarily unreachable
+	ErrUnavailable = errors.New("storage subsystem unavailable")
+)
+
+// Capabilities describes the optional features a storage backend supports.
+// Handlers consult it to disable features up front instead of failing at
+// runtime.
+type Capabilities struct {
+	Transactions   bool `json:"transactions"`
+	FullTextSearch bool `json:"full_text_search"`
+	Cursors        bool `json:"cursors"`
+	TTL            bool `json:"ttl"`
+}
+
+// ListOptions controls pagination for List. Results are always ordered by
+// ascending ID so pages are stable while tasks are being created.
+type ListOptions struct {
+	// AfterID is a keyset cursor: only tasks with a greater ID are returned
+	AfterID int64
+
+	// Offset skips this many tasks; ignored when AfterID is set
+	Offset int
+
+	// Limit caps the number of tasks returned; zero means no limit
+	Limit int
+
+	// Filter selects the tasks listed; offsets and limits count only
+	// selected tasks
+	Filter TaskFilter
+}
+
+// TaskFilter selects tasks by their fields for List and Count. The zero
+// value selects every task.
+type TaskFilter struct {
+	// Status, when set, selects only tasks with this status
+	Status models.TaskStatus
+}
+
+// matches reports whether task is selected by f
+func (f TaskFilter) matches(task *models.Task) bool {
+	return f.Status == "" || task.Status == f.Status
+}
 
 // TaskRepository defines the interface for task storage operations
 type TaskRepository interface {
 	// Create creates a new task and returns it with generated ID
-	Create(task *models.Task) (*models.Task, error)
+	Create(ctx context.Context, task *models.Task) (*models.Task, error)
 
 	// GetAll returns all tasks
-	GetAll() ([]*models.Task, error)
+	GetAll(ctx context.Context) ([]*models.Task, error)
+
+	// List returns a page of tasks ordered by ID
+	List(ctx context.Context, opts ListOptions) ([]*models.Task, error)
 
 	// GetByID returns a task by ID or ErrTaskNotFound if not found
-	GetByID(id int64) (*models.Task, error)
+	GetByID(ctx context.Context, id int64) (*models.Task, error)
+
+	// GetByUID returns a task by its opaque identifier, such as a ULID, or
+	// ErrTaskNotFound if no task has it
+	GetByUID(ctx context.Context, uid string) (*models.Task, error)
+
+	// GetByIDs returns the tasks with the given IDs in ID order, skipping
+	// IDs that do not exist, so related tasks load in one call rather than
+	// one per ID
+	GetByIDs(ctx context.Context, ids []int64) ([]*models.Task, error)
+
+	// Exists reports whether a task with the given ID exists, without
+	// loading it
+	Exists(ctx context.Context, id int64) (bool, error)
+
+	// Count returns how many tasks filter selects, without loading them
+	Count(ctx context.Context, filter TaskFilter) (int, error)
+
+	// EstimateTotal adds up the estimates of the tasks filter selects,
+	// without loading them
+	EstimateTotal(ctx context.Context, filter TaskFilter) (int, error)
+
+	// Update updates an existing task and returns the updated task. Update,
+	// SetStatus and Delete check a precondition set with WithPrecondition
+	// atomically with the write.
+	Update(ctx context.Context, id int64, task *models.Task) (*models.Task, error)
+
+	// SetStatus changes only the status of a task and returns it. Setting
+	// the status the task already has changes nothing.
+	SetStatus(ctx context.Context, id int64, status models.TaskStatus) (*models.Task, error)
+
+	// Delete deletes a task by ID and removes any links pointing to it
+	Delete(ctx context.Context, id int64) error
+
+	// Search returns tasks whose title or description match the query, or
+	// ErrNotSupported if the backend lacks full-text search
+	Search(ctx context.Context, query string) ([]*models.Task, error)
+
+	// Capabilities reports the optional features the backend supports
+	Capabilities() Capabilities
+
+	// LastModified returns when any task was last created, changed or
+	// deleted, for Last-Modified headers; it may be later than the last
+	// change but never earlier
+	LastModified(ctx context.Context) (time.Time, error)
+
+	// Undelete puts deleted tasks back as they were, keeping their IDs, and
+	// returns them. Links to tasks that no longer exist are dropped; links
+	// other tasks had to them are not restored. It fails with
+	// ErrTaskExists if any ID is taken, storing none of the tasks.
+	Undelete(ctx context.Context, tasks []*models.Task) ([]*models.Task, error)
 
-	// Update updates an existing task and returns the updated task
-	Update(id int64, task *models.Task) (*models.Task, error)
+	// AddLink adds a typed link from the task with the given ID to another task
+	AddLink(ctx context.Context, id int64, link models.TaskLink) (*models.Task, error)
 
-	// Delete deletes a task by ID
-	Delete(id int64) error
+	// WithTx runs fn as one transaction: its changes through tx are kept
+	// only if fn returns nil, and other calls see none of them until then.
+	// tx must not be used once fn returns.
+	WithTx(ctx context.Context, fn func(tx TaskRepository) error) error
 }
@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+)
+
+// tracerName identifies spans created by the repository layer
+const tracerName = "github.com/light-bringer/cert-tasks/internal/repository"
+
+// TracedRepository decorates a TaskRepository and records a child span for
+// every call, parented to the span carried by the caller's context
+type TracedRepository struct {
+	TaskRepository
+	tracer trace.Tracer
+}
+
+// NewTracedRepository wraps repo so that each call is traced with the global
+// tracer provider
+func NewTracedRepository(repo TaskRepository) *TracedRepository {
+	return &TracedRepository{
+		TaskRepository: repo,
+		tracer:         otel.Tracer(tracerName),
+	}
+}
+
+// start opens a span for the named repository operation
+func (r *TracedRepository) start(ctx context.Context, op string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return r.tracer.Start(ctx, "repository."+op,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// end records err on the span, if any, and ends it
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Create traces TaskRepository.Create
+func (r *TracedRepository) Create(ctx context.Context, task *models.Task) (*models.Task, error) {
+	ctx, span := r.start(ctx, "Create")
+	created, err := r.TaskRepository.Create(ctx, task)
+	if err == nil {
+		span.SetAttributes(attribute.Int64("task.id", created.ID))
+	}
+	end(span, err)
+	return created, err
+}
+
+// GetAll traces TaskRepository.GetAll
+func (r *TracedRepository) GetAll(ctx context.Context) ([]*models.Task, error) {
+	ctx, span := r.start(ctx, "GetAll")
+	tasks, err := r.TaskRepository.GetAll(ctx)
+	span.SetAttributes(attribute.Int("tasks.count", len(tasks)))
+	end(span, err)
+	return tasks, err
+}
+
+// List traces TaskRepository.List
+func (r *TracedRepository) List(ctx context.Context, opts ListOptions) ([]*models.Task, error) {
+	ctx, span := r.start(ctx, "List",
+		attribute.Int64("list.after_id", opts.AfterID),
+		attribute.Int("list.offset", opts.Offset),
+		attribute.Int("list.limit", opts.Limit),
+		attribute.String("list.status", string(opts.Filter.Status)),
+	)
+	tasks, err := r.TaskRepository.List(ctx, opts)
+	span.SetAttributes(attribute.Int("tasks.count", len(tasks)))
+	end(span, err)
+	return tasks, err
+}
+
+// GetByID traces TaskRepository.GetByID
+func (r *TracedRepository) GetByID(ctx context.Context, id int64) (*models.Task, error) {
+	ctx, span := r.start(ctx, "GetByID", attribute.Int64("task.id", id))
+	task, err := r.TaskRepository.GetByID(ctx, id)
+	end(span, err)
+	return task, err
+}
+
+// GetByUID traces TaskRepository.GetByUID
+func (r *TracedRepository) GetByUID(ctx context.Context, uid string) (*models.Task, error) {
+	ctx, span := r.start(ctx, "GetByUID", attribute.String("task.uid", uid))
+	task, err := r.TaskRepository.GetByUID(ctx, uid)
+	end(span, err)
+	return task, err
+}
+
+// GetByIDs traces TaskRepository.GetByIDs
+func (r *TracedRepository) GetByIDs(ctx context.Context, ids []int64) ([]*models.Task, error) {
+	ctx, span := r.start(ctx, "GetByIDs", attribute.Int("tasks.requested", len(ids)))
+	tasks, err := r.TaskRepository.GetByIDs(ctx, ids)
+	span.SetAttributes(attribute.Int("tasks.count", len(tasks)))
+	end(span, err)
+	return tasks, err
+}
+
+// Exists traces TaskRepository.Exists
+func (r *TracedRepository) Exists(ctx context.Context, id int64) (bool, error) {
+	ctx, span := r.start(ctx, "Exists", attribute.Int64("task.id", id))
+	exists, err := r.TaskRepository.Exists(ctx, id)
+	end(span, err)
+	return exists, err
+}
+
+// Count traces TaskRepository.Count
+func (r *TracedRepository) Count(ctx context.Context, filter TaskFilter) (int, error) {
+	ctx, span := r.start(ctx, "Count", attribute.String("filter.status", string(filter.Status)))
+	n, err := r.TaskRepository.Count(ctx, filter)
+	span.SetAttributes(attribute.Int("tasks.count", n))
+	end(span, err)
+	return n, err
+}
+
+// EstimateTotal traces TaskRepository.EstimateTotal
+func (r *TracedRepository) EstimateTotal(ctx context.Context, filter TaskFilter) (int, error) {
+	ctx, span := r.start(ctx, "EstimateTotal", attribute.String("filter.status", string(filter.Status)))
+	total, err := r.TaskRepository.EstimateTotal(ctx, filter)
+	end(span, err)
+	return total, err
+}
+
+// LastModified traces TaskRepository.LastModified
+func (r *TracedRepository) LastModified(ctx context.Context) (time.Time, error) {
+	ctx, span := r.start(ctx, "LastModified")
+	modified, err := r.TaskRepository.LastModified(ctx)
+	end(span, err)
+	return modified, err
+}
+
+// Update traces TaskRepository.Update
+func (r *TracedRepository) Update(ctx context.Context, id int64, task *models.Task) (*models.Task, error) {
+	ctx, span := r.start(ctx, "Update", attribute.Int64("task.id", id))
+	updated, err := r.TaskRepository.Update(ctx, id, task)
+	end(span, err)
+	return updated, err
+}
+
+// SetStatus traces TaskRepository.SetStatus
+func (r *TracedRepository) SetStatus(ctx context.Context, id int64, status models.TaskStatus) (*models.Task, error) {
+	ctx, span := r.start(ctx, "SetStatus", attribute.Int64("task.id", id), attribute.String("task.status", string(status)))
+	updated, err := r.TaskRepository.SetStatus(ctx, id, status)
+	end(span, err)
+	return updated, err
+}
+
+// Delete traces TaskRepository.Delete
+func (r *TracedRepository) Delete(ctx context.Context, id int64) error {
+	ctx, span := r.start(ctx, "Delete", attribute.Int64("task.id", id))
+	err := r.TaskRepository.Delete(ctx, id)
+	end(span, err)
+	return err
+}
+
+// Undelete traces TaskRepository.Undelete
+func (r *TracedRepository) Undelete(ctx context.Context, tasks []*models.Task) ([]*models.Task, error) {
+	ctx, span := r.start(ctx, "Undelete", attribute.Int("tasks.count", len(tasks)))
+	restored, err := r.TaskRepository.Undelete(ctx, tasks)
+	end(span, err)
+	return restored, err
+}
+
+// Search traces TaskRepository.Search. The query itself is not recorded
+// since it may contain user content.
+func (r *TracedRepository) Search(ctx context.Context, query string) ([]*models.Task, error) {
+	ctx, span := r.start(ctx, "Search")
+	tasks, err := r.TaskRepository.Search(ctx, query)
+	span.SetAttributes(attribute.Int("tasks.count", len(tasks)))
+	end(span, err)
+	return tasks, err
+}
+
+// AddLink traces TaskRepository.AddLink
+func (r *TracedRepository) AddLink(ctx context.Context, id int64, link models.TaskLink) (*models.Task, error) {
+	ctx, span := r.start(ctx, "AddLink",
+		attribute.Int64("task.id", id),
+		attribute.Int64("link.task_id", link.TaskID),
+		attribute.String("link.type", string(link.Type)),
+	)
+	task, err := r.TaskRepository.AddLink(ctx, id, link)
+	end(span, err)
+	return task, err
+}
+
+// WithTx traces TaskRepository.WithTx, and the calls made through tx
+func (r *TracedRepository) WithTx(ctx context.Context, fn func(tx TaskRepository) error) error {
+	ctx, span := r.start(ctx, "WithTx")
+	err := r.TaskRepository.WithTx(ctx, func(tx TaskRepository) error {
+		return fn(NewTracedRepository(tx))
+	})
+	end(span, err)
+	return err
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+)
+
+func TestTracedRepository(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	repo := NewTracedRepository(NewMemoryRepository())
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "request")
+	if _, err := repo.Create(ctx, &models.Task{Title: "traced", Status: models.StatusTodo}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := repo.GetByID(ctx, 999); !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("GetByID error = %v, want ErrTaskNotFound", err)
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("got %d spans, want 3", len(spans))
+	}
+
+	create, get := spans[0], spans[1]
+	if create.Name() != "repository.Create" || get.Name() != "repository.GetByID" {
+		t.Fatalf("span names = %q, %q", create.Name(), get.Name())
+	}
+	for _, span := range []sdktrace.ReadOnlySpan{create, get} {
+		if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("%s is not a child of the request span", span.Name())
+		}
+	}
+	if create.Status().Code == codes.Error {
+		t.Error("successful Create should not be marked as an error")
+	}
+	if get.Status().Code != codes.Error {
+		t.Error("failed GetByID should be marked as an error")
+	}
+
+	// Capabilities pass through the decorator
+	if !repo.Capabilities().Cursors {
+		t.Error("expected wrapped capabilities to be preserved")
+	}
+}
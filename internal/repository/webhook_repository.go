@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+)
+
+// ErrWebhookNotFound is returned when a webhook does not exist in the
+// caller's scope
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// WebhookRepository stores webhooks. Webhooks belong to the workspace and
+// owner of the context they are created with, and are scoped like tasks.
+type WebhookRepository interface {
+	// CreateWebhook stores a webhook and returns it with generated ID
+	CreateWebhook(ctx context.Context, hook *models.Webhook) (*models.Webhook, error)
+
+	// ListWebhooks returns the webhooks in scope ordered by ID
+	ListWebhooks(ctx context.Context) ([]*models.Webhook, error)
+
+	// GetWebhook returns a webhook or ErrWebhookNotFound
+	GetWebhook(ctx context.Context, id int64) (*models.Webhook, error)
+
+	// DeleteWebhook deletes a webhook or returns ErrWebhookNotFound
+	DeleteWebhook(ctx context.Context, id int64) error
+}
+
+// webhooks holds the webhooks of a MemoryRepository. Like API keys they
+// are kept apart from the tasks so that Reset leaves them in place.
+type webhooks struct {
+	byID   map[int64]*models.Webhook
+	lastID int64
+}
+
+// allowsWebhook reports whether a call in the scope may see hook
+func (s scope) allowsWebhook(hook *models.Webhook) bool {
+	return (s.owner == "" || hook.OwnerID == s.owner) &&
+		(s.workspace == "" || hook.WorkspaceID == s.workspace)
+}
+
+// CreateWebhook stores a webhook in the caller's workspace and owner
+func (r *MemoryRepository) CreateWebhook(ctx context.Context, hook *models.Webhook) (*models.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	workspace := WorkspaceFromContext(ctx)
+	if workspace == "" {
+		workspace = models.DefaultWorkspace
+	}
+	if _, exists := r.workspaces[workspace]; !exists {
+		return nil, ErrWorkspaceNotFound
+	}
+
+	if r.hooks.byID == nil {
+		r.hooks.byID = make(map[int64]*models.Webhook)
+	}
+	r.hooks.lastID++
+
+	stored := *hook
+	stored.ID = r.hooks.lastID
+	stored.Events = append([]models.TaskEventType(nil), hook.Events...)
+	stored.WorkspaceID = workspace
+	stored.OwnerID = OwnerFromContext(ctx)
+	stored.CreatedAt = time.Now()
+	r.hooks.byID[stored.ID] = &stored
+
+	return copyWebhook(&stored), nil
+}
+
+// ListWebhooks returns the webhooks in scope ordered by ID
+func (r *MemoryRepository) ListWebhooks(ctx context.Context) ([]*models.Webhook, error) {
+	sc := scopeFrom(ctx)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := []*models.Webhook{}
+	for _, hook := range r.hooks.byID {
+		if sc.allowsWebhook(hook) {
+			list = append(list, copyWebhook(hook))
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
+
+// GetWebhook returns a webhook by ID
+func (r *MemoryRepository) GetWebhook(ctx context.Context, id int64) (*models.Webhook, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	hook, exists := r.hooks.byID[id]
+	if !exists || !scopeFrom(ctx).allowsWebhook(hook) {
+		return nil, ErrWebhookNotFound
+	}
+	return copyWebhook(hook), nil
+}
+
+// DeleteWebhook deletes a webhook by ID
+func (r *MemoryRepository) DeleteWebhook(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	hook, exists := r.hooks.byID[id]
+	if !exists || !scopeFrom(ctx).allowsWebhook(hook) {
+		return ErrWebhookNotFound
+	}
+	delete(r.hooks.byID, id)
+	return nil
+}
+
+// WebhookSnapshot returns copies of every webhook in ID order, for
+// persisting the repository
+func (r *MemoryRepository) WebhookSnapshot() []*models.Webhook {
+	list, _ := r.ListWebhooks(context.Background())
+	return list
+}
+
+// RestoreWebhooks replaces the stored webhooks
+func (r *MemoryRepository) RestoreWebhooks(hooks []*models.Webhook) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.hooks = webhooks{byID: make(map[int64]*models.Webhook, len(hooks))}
+	for _, hook := range hooks {
+		r.hooks.byID[hook.ID] = hook
+		r.hooks.lastID = max(r.hooks.lastID, hook.ID)
+	}
+}
+
+// copyWebhook returns a copy of hook that shares no slices with it
+func copyWebhook(hook *models.Webhook) *models.Webhook {
+	c := *hook
+	c.Events = append([]models.TaskEventType(nil), hook.Events...)
+	return &c
+}
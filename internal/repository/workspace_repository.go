@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+)
+
+var (
+	// ErrWorkspaceNotFound is returned when a workspace does not exist
+	ErrWorkspaceNotFound = errors.New("workspace not found")
+
+	// ErrWorkspaceExists is returned when creating a workspace whose ID is
+	// taken
+	ErrWorkspaceExists = errors.New("workspace already exists")
+
+	// ErrWorkspaceNotEmpty is returned when deleting a workspace that still
+	// has tasks
+	ErrWorkspaceNotEmpty = errors.New("workspace still has tasks")
+
+	// ErrDefaultWorkspace is returned when deleting the default workspace
+	ErrDefaultWorkspace = errors.New("default workspace cannot be deleted")
+)
+
+// WorkspaceRepository stores workspaces. The default workspace always
+// exists.
+type WorkspaceRepository interface {
+	// CreateWorkspace stores a new workspace with the given ID
+	CreateWorkspace(ctx context.Context, ws *models.Workspace) (*models.Workspace, error)
+
+	// GetWorkspace returns a workspace or ErrWorkspaceNotFound
+	GetWorkspace(ctx context.Context, id string) (*models.Workspace, error)
+
+	// ListWorkspaces returns every workspace ordered by ID
+	ListWorkspaces(ctx context.Context) ([]*models.Workspace, error)
+
+	// UpdateWorkspace renames a workspace and sets its time zone and
+	// estimate unit
+	UpdateWorkspace(ctx context.Context, id string, ws *models.Workspace) (*models.Workspace, error)
+
+	// DeleteWorkspace deletes an empty workspace and its webhooks
+	DeleteWorkspace(ctx context.Context, id string) error
+}
+
+// defaultWorkspace returns the workspace every repository starts with
+func defaultWorkspace() *models.Workspace {
+	return &models.Workspace{ID: models.DefaultWorkspace, Name: "Default"}
+}
+
+// CreateWorkspace stores a new workspace
+func (r *MemoryRepository) CreateWorkspace(ctx context.Context, ws *models.Workspace) (*models.Workspace, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.orderMu.Lock()
+	defer r.orderMu.Unlock()
+
+	if _, exists := r.workspaces[ws.ID]; exists {
+		return nil, ErrWorkspaceExists
+	}
+
+	now := time.Now()
+	stored := &models.Workspace{ID: ws.ID, Name: ws.Name, Timezone: ws.Timezone, EstimateUnit: ws.EstimateUnit, CreatedAt: now, UpdatedAt: now}
+	r.workspaces[ws.ID] = stored
+
+	created := *stored
+	return &created, nil
+}
+
+// GetWorkspace returns a workspace by ID
+func (r *MemoryRepository) GetWorkspace(ctx context.Context, id string) (*models.Workspace, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ws, exists := r.workspaces[id]
+	if !exists {
+		return nil, ErrWorkspaceNotFound
+	}
+	found := *ws
+	return &found, nil
+}
+
+// ListWorkspaces returns every workspace ordered by ID
+func (r *MemoryRepository) ListWorkspaces(ctx context.Context) ([]*models.Workspace, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.workspaceSnapshot(), nil
+}
+
+// UpdateWorkspace renames a workspace and sets its time zone and
+// estimate unit
+func (r *MemoryRepository) UpdateWorkspace(ctx context.Context, id string, ws *models.Workspace) (*models.Workspace, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.orderMu.Lock()
+	defer r.orderMu.Unlock()
+
+	existing, exists := r.workspaces[id]
+	if !exists {
+		return nil, ErrWorkspaceNotFound
+	}
+	existing.Name = ws.Name
+	existing.Timezone = ws.Timezone
+	existing.EstimateUnit = ws.EstimateUnit
+	existing.UpdatedAt = time.Now()
+
+	updated := *existing
+	return &updated, nil
+}
+
+// DeleteWorkspace deletes a workspace that has no tasks, along with its
+// webhooks
+func (r *MemoryRepository) DeleteWorkspace(ctx context.Context, id string) error {
+	// Holding orderMu keeps tasks from being created in the workspace
+	// while it is checked
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.orderMu.Lock()
+	defer r.orderMu.Unlock()
+
+	if id == models.DefaultWorkspace {
+		return ErrDefaultWorkspace
+	}
+	if _, exists := r.workspaces[id]; !exists {
+		return ErrWorkspaceNotFound
+	}
+	empty := true
+	r.each(func(task *models.Task) bool {
+		empty = task.WorkspaceID != id
+		return empty
+	})
+	if !empty {
+		return ErrWorkspaceNotEmpty
+	}
+
+	delete(r.workspaces, id)
+	for hookID, hook := range r.hooks.byID {
+		if hook.WorkspaceID == id {
+			delete(r.hooks.byID, hookID)
+		}
+	}
+	return nil
+}
+
+// WorkspaceSnapshot returns copies of every workspace ordered by ID, for
+// persisting the repository
+func (r *MemoryRepository) WorkspaceSnapshot() []*models.Workspace {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.workspaceSnapshot()
+}
+
+// workspaceSnapshot copies the workspaces; r.mu must be held
+func (r *MemoryRepository) workspaceSnapshot() []*models.Workspace {
+	list := make([]*models.Workspace, 0, len(r.workspaces))
+	for _, ws := range r.workspaces {
+		w := *ws
+		list = append(list, &w)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// RestoreWorkspaces replaces the stored workspaces; the default workspace
+// is kept even if missing from workspaces
+func (r *MemoryRepository) RestoreWorkspaces(workspaces []*models.Workspace) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.orderMu.Lock()
+	defer r.orderMu.Unlock()
+
+	r.workspaces = map[string]*models.Workspace{models.DefaultWorkspace: defaultWorkspace()}
+	for _, ws := range workspaces {
+		r.workspaces[ws.ID] = ws
+	}
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// writeBehind counts task writes not yet in the snapshot and schedules
+// the save that will cover them
+type writeBehind struct {
+	maxDelay   time.Duration
+	maxPending int
+
+	mu      sync.Mutex
+	pending int
+	timer   *time.Timer
+	err     error // from the last background save, reported by the next write
+}
+
+// EnableWriteBehind makes task creates, updates, deletes and links return
+// before the snapshot is saved. One save then covers every write made
+// within maxDelay of the first unsaved one, so a burst of writes costs one
+// rewrite of the file instead of one each. A crash loses at most maxDelay
+// of writes. The write that brings the unsaved count to maxPending saves
+// the batch itself, which slows writers to the speed of the disk rather
+// than letting unsaved writes pile up. Call Flush before exiting.
+//
+// API key, webhook and workspace changes, transactions and compaction
+// still save at once. Call EnableWriteBehind before serving requests.
+func (r *FileRepository) EnableWriteBehind(maxDelay time.Duration, maxPending int) {
+	r.writeBehind = &writeBehind{maxDelay: maxDelay, maxPending: max(maxPending, 1)}
+}
+
+// saveTasks persists a task write, at once or with write-behind
+func (r *FileRepository) saveTasks() error {
+	wb := r.writeBehind
+	if wb == nil {
+		return r.save()
+	}
+
+	wb.mu.Lock()
+	err := wb.err
+	wb.err = nil
+	wb.pending++
+	full := wb.pending >= wb.maxPending
+	if !full && wb.timer == nil {
+		wb.timer = time.AfterFunc(wb.maxDelay, r.flushInBackground)
+	}
+	wb.mu.Unlock()
+
+	if full {
+		return r.Flush()
+	}
+	if err != nil {
+		return fmt.Errorf("saving earlier writes: %w", err)
+	}
+	return nil
+}
+
+// Flush saves task writes queued by write-behind. It does nothing if none
+// are queued or write-behind is off.
+func (r *FileRepository) Flush() error {
+	wb := r.writeBehind
+	if wb == nil {
+		return nil
+	}
+
+	wb.mu.Lock()
+	n := wb.pending
+	wb.pending = 0
+	if wb.timer != nil {
+		wb.timer.Stop()
+		wb.timer = nil
+	}
+	wb.mu.Unlock()
+	if n == 0 {
+		return nil
+	}
+
+	// The snapshot is taken after every counted write reached memory, so
+	// one save covers them all
+	if err := r.save(); err != nil {
+		// Keep them queued, so the next write or Flush tries again
+		wb.mu.Lock()
+		wb.pending += n
+		wb.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// flushInBackground runs a scheduled Flush, keeping a failure for the
+// next write to report as no caller is waiting on this one
+func (r *FileRepository) flushInBackground() {
+	if err := r.Flush(); err != nil {
+		slog.Error("saving queued task writes", slog.String("path", r.path), slog.Any("error", err))
+		wb := r.writeBehind
+		wb.mu.Lock()
+		wb.err = err
+		wb.mu.Unlock()
+	}
+}
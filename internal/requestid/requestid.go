@@ -0,0 +1,86 @@
+// Package requestid assigns every request a unique ID, echoes it in the
+// X-Request-ID response header and makes it available to logs and error
+// responses through the request context.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"net/http"
+	"net/netip"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/light-bringer/cert-tasks/internal/forwarded"
+)
+
+// Header carries the request ID on requests and responses
+//
+//api:changelog 0.2.0 added header X-Request-ID: Unique ID of every request, echoed on responses
+const Header = "X-Request-ID"
+
+// maxInboundLength bounds IDs accepted from proxies so they cannot bloat logs
+const maxInboundLength = 128
+
+// Middleware assigns a request ID. An inbound X-Request-ID is kept only when
+// the request comes directly from one of the trusted proxy networks;
+// otherwise a new ULID is generated. The ID is stored where chi's
+// middleware.GetReqID finds it.
+func Middleware(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(Header)
+			if !validInbound(id) || !forwarded.FromTrustedProxy(r.RemoteAddr, trusted) {
+				id = New()
+			}
+
+			w.Header().Set(Header, id)
+			ctx := context.WithValue(r.Context(), middleware.RequestIDKey, id)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// FromRequest returns the ID assigned to r, or "" if the middleware did not run
+func FromRequest(r *http.Request) string {
+	return middleware.GetReqID(r.Context())
+}
+
+// validInbound reports whether id is safe to adopt: non-empty, bounded and
+// limited to printable ASCII without spaces
+func validInbound(id string) bool {
+	if id == "" || len(id) > maxInboundLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// crockford is the ULID base32 alphabet
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// New returns a new ULID: a 48-bit millisecond timestamp followed by 80
+// random bits, so IDs sort by creation time
+func New() string {
+	var b [16]byte
+	ms := uint64(time.Now().UnixMilli())
+	binary.BigEndian.PutUint16(b[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(b[2:6], uint32(ms))
+	rand.Read(b[6:])
+
+	// 128 bits encode to 26 characters; the first holds the top 3 bits
+	hi := binary.BigEndian.Uint64(b[0:8])
+	lo := binary.BigEndian.Uint64(b[8:16])
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
@@ -0,0 +1,70 @@
+package requestid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMiddleware(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		inbound    string
+		wantKept   bool
+	}{
+		{"no inbound ID", "10.0.0.1:1234", "", false},
+		{"trusted proxy", "10.0.0.1:1234", "edge-abc123", true},
+		{"untrusted peer", "203.0.113.9:1234", "edge-abc123", false},
+		{"IPv4-mapped trusted proxy", "[::ffff:10.1.2.3]:1234", "edge-abc123", true},
+		{"trusted proxy, unsafe ID", "10.0.0.1:1234", "bad id\n", false},
+		{"trusted proxy, oversized ID", "10.0.0.1:1234", strings.Repeat("a", maxInboundLength+1), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			handler := Middleware(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = FromRequest(r)
+			}))
+
+			req := httptest.NewRequest("GET", "/tasks", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.inbound != "" {
+				req.Header.Set(Header, tt.inbound)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if got := rec.Header().Get(Header); got != seen || got == "" {
+				t.Fatalf("response header = %q, context ID = %q; want the same non-empty ID", got, seen)
+			}
+			if kept := seen == tt.inbound; kept != tt.wantKept {
+				t.Errorf("ID = %q, kept inbound = %v, want %v", seen, kept, tt.wantKept)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	before := time.Now().UnixMilli()
+	a, b := New(), New()
+
+	if len(a) != 26 || a == b {
+		t.Fatalf("New() = %q, %q; want distinct 26-character IDs", a, b)
+	}
+
+	// The first 10 characters encode the millisecond timestamp
+	var ms int64
+	for _, c := range a[:10] {
+		ms = ms<<5 | int64(strings.IndexRune(crockford, c))
+	}
+	if ms < before || ms > time.Now().UnixMilli() {
+		t.Errorf("decoded timestamp %d outside [%d, now]", ms, before)
+	}
+}
@@ -0,0 +1,296 @@
+// Package scheduler runs named periodic background jobs and watches them
+// for stuck runs. A run proves progress by calling its heartbeat; a run
+// with no heartbeat for longer than its job's StuckAfter is reported and
+// can be aborted or re-queued without ever overlapping another run of the
+// same job.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrUnknownJob is returned for a job name that was never added
+	ErrUnknownJob = errors.New("unknown job")
+
+	// ErrNotRunning is returned when aborting a job that has no active run
+	ErrNotRunning = errors.New("job is not running")
+)
+
+// watchInterval is how often runs are checked for missed heartbeats
+const watchInterval = time.Second
+
+// Func is one run of a job. It should call beat as it makes progress and
+// return promptly once ctx is cancelled.
+type Func func(ctx context.Context, beat func()) error
+
+// Job describes a periodic background job
+type Job struct {
+	Name     string
+	Interval time.Duration
+
+	// StuckAfter is how long a run may go without a heartbeat before it
+	// is reported as stuck
+	StuckAfter time.Duration
+
+	// RunAtStart runs the job once as soon as the scheduler starts
+	RunAtStart bool
+
+	Run Func
+}
+
+// Status is the state of one job, as served by the jobs admin API
+type Status struct {
+	Name          string    `json:"name"`
+	IntervalMs    int64     `json:"interval_ms"`
+	StuckAfterMs  int64     `json:"stuck_after_ms"`
+	Running       bool      `json:"running"`
+	Stuck         bool      `json:"stuck"`
+	Queued        bool      `json:"queued"`
+	StartedAt     time.Time `json:"started_at,omitzero"`
+	LastHeartbeat time.Time `json:"last_heartbeat,omitzero"`
+	LastFinished  time.Time `json:"last_finished,omitzero"`
+	LastError     string    `json:"last_error,omitempty"`
+	Runs          int       `json:"runs"`
+	Failures      int       `json:"failures"`
+	Aborts        int       `json:"aborts"`
+	StuckRuns     int       `json:"stuck_runs"`
+}
+
+// job is a Job plus its run state, guarded by Scheduler.mu
+type job struct {
+	Job
+	requeue chan struct{}
+
+	running       bool
+	stuck         bool
+	aborted       bool
+	cancel        context.CancelFunc
+	startedAt     time.Time
+	lastHeartbeat time.Time
+	lastFinished  time.Time
+	lastError     string
+	runs          int
+	failures      int
+	aborts        int
+	stuckRuns     int
+}
+
+// Scheduler runs jobs on their intervals
+type Scheduler struct {
+	now func() time.Time
+
+	mu   sync.Mutex
+	jobs []*job
+}
+
+// New creates an empty scheduler
+func New() *Scheduler {
+	return &Scheduler{now: time.Now}
+}
+
+// Add registers a job. It must be called before Run.
+func (s *Scheduler) Add(j Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = append(s.jobs, &job{Job: j, requeue: make(chan struct{}, 1)})
+}
+
+// Run starts every job and the watchdog, and blocks until ctx is done and
+// all runs have returned
+func (s *Scheduler) Run(ctx context.Context) {
+	s.mu.Lock()
+	jobs := append([]*job(nil), s.jobs...)
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, j := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.loop(ctx, j)
+		}()
+	}
+
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case <-ticker.C:
+			s.watch()
+		}
+	}
+}
+
+// loop runs j on its interval and whenever it is re-queued. Runs of one
+// job never overlap.
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	if j.RunAtStart {
+		s.run(ctx, j)
+	}
+
+	ticker := time.NewTicker(j.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.run(ctx, j)
+		case <-j.requeue:
+			s.run(ctx, j)
+		}
+	}
+}
+
+// run executes one run of j and records its outcome
+func (s *Scheduler) run(ctx context.Context, j *job) {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s.mu.Lock()
+	now := s.now()
+	j.running, j.stuck, j.aborted = true, false, false
+	j.cancel = cancel
+	j.startedAt, j.lastHeartbeat = now, now
+	s.mu.Unlock()
+
+	err := j.Run(runCtx, func() { s.beat(j) })
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j.running = false
+	j.cancel = nil
+	j.lastFinished = s.now()
+	j.runs++
+	if j.aborted {
+		j.aborts++
+	}
+	j.lastError = ""
+	if err != nil {
+		j.failures++
+		j.lastError = err.Error()
+		slog.Error("background job failed", slog.String("job", j.Name), slog.Any("error", err))
+	}
+}
+
+// beat records a heartbeat for j's current run
+func (s *Scheduler) beat(j *job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j.lastHeartbeat = s.now()
+}
+
+// watch reports runs that missed their heartbeat deadline, once per run
+func (s *Scheduler) watch() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for _, j := range s.jobs {
+		if !j.running || j.stuck || now.Sub(j.lastHeartbeat) <= j.StuckAfter {
+			continue
+		}
+		j.stuck = true
+		j.stuckRuns++
+		slog.Error("background job stuck",
+			slog.String("job", j.Name),
+			slog.Duration("running_for", now.Sub(j.startedAt)),
+			slog.Duration("since_heartbeat", now.Sub(j.lastHeartbeat)),
+		)
+	}
+}
+
+// Abort cancels the current run of the named job. The run ends once it
+// observes the cancellation; the job keeps its schedule.
+func (s *Scheduler) Abort(name string) (Status, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j := s.find(name)
+	if j == nil {
+		return Status{}, ErrUnknownJob
+	}
+	if !j.running {
+		return j.status(), ErrNotRunning
+	}
+	s.abort(j)
+	return j.status(), nil
+}
+
+// Requeue schedules an extra run of the named job right after the current
+// one, if any. A stuck run is aborted first; a healthy one is left to
+// finish, so a re-queued run never overlaps it.
+func (s *Scheduler) Requeue(name string) (Status, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j := s.find(name)
+	if j == nil {
+		return Status{}, ErrUnknownJob
+	}
+	if j.running && j.stuck {
+		s.abort(j)
+	}
+	select {
+	case j.requeue <- struct{}{}:
+	default: // already queued
+	}
+	return j.status(), nil
+}
+
+// abort cancels j's run; s.mu must be held
+func (s *Scheduler) abort(j *job) {
+	j.aborted = true
+	j.cancel()
+	slog.Warn("background job aborted", slog.String("job", j.Name))
+}
+
+// Status returns the state of every job in the order they were added
+func (s *Scheduler) Status() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]Status, len(s.jobs))
+	for i, j := range s.jobs {
+		statuses[i] = j.status()
+	}
+	return statuses
+}
+
+// find returns the named job or nil; s.mu must be held
+func (s *Scheduler) find(name string) *job {
+	for _, j := range s.jobs {
+		if j.Name == name {
+			return j
+		}
+	}
+	return nil
+}
+
+// status snapshots j; the scheduler's mutex must be held
+func (j *job) status() Status {
+	return Status{
+		Name:          j.Name,
+		IntervalMs:    j.Interval.Milliseconds(),
+		StuckAfterMs:  j.StuckAfter.Milliseconds(),
+		Running:       j.running,
+		Stuck:         j.stuck,
+		Queued:        len(j.requeue) > 0,
+		StartedAt:     j.startedAt,
+		LastHeartbeat: j.lastHeartbeat,
+		LastFinished:  j.lastFinished,
+		LastError:     j.lastError,
+		Runs:          j.runs,
+		Failures:      j.failures,
+		Aborts:        j.aborts,
+		StuckRuns:     j.stuckRuns,
+	}
+}
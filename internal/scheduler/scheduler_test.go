@@ -0,0 +1,159 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// clock is a settable time source for the scheduler
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// blockingJob runs until its context is cancelled, beating once at start
+func blockingJob(started chan<- struct{}) Func {
+	return func(ctx context.Context, beat func()) error {
+		beat()
+		started <- struct{}{}
+		<-ctx.Done()
+		return ctx.Err()
+	}
+}
+
+func TestScheduler_StuckAndAbort(t *testing.T) {
+	c := &clock{now: time.Now()}
+	s := New()
+	s.now = c.Now
+
+	started := make(chan struct{}, 2)
+	s.Add(Job{Name: "sync", Interval: time.Hour, StuckAfter: time.Minute, Run: blockingJob(started)})
+	j := s.find("sync")
+
+	done := make(chan struct{})
+	go func() {
+		s.run(context.Background(), j)
+		close(done)
+	}()
+	<-started
+
+	c.Advance(30 * time.Second)
+	s.watch()
+	if st := s.Status()[0]; !st.Running || st.Stuck {
+		t.Fatalf("status after 30s = %+v, want running and not stuck", st)
+	}
+
+	c.Advance(time.Minute)
+	s.watch()
+	s.watch() // reported once per run
+	if st := s.Status()[0]; !st.Stuck || st.StuckRuns != 1 {
+		t.Fatalf("status after 90s = %+v, want stuck once", st)
+	}
+
+	if _, err := s.Abort("sync"); err != nil {
+		t.Fatalf("Abort() = %v", err)
+	}
+	<-done
+
+	st := s.Status()[0]
+	if st.Running || st.Aborts != 1 || st.Failures != 1 || st.LastError == "" {
+		t.Errorf("status after abort = %+v", st)
+	}
+	if _, err := s.Abort("sync"); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("Abort() on idle job = %v, want ErrNotRunning", err)
+	}
+	if _, err := s.Abort("nope"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("Abort() on unknown job = %v, want ErrUnknownJob", err)
+	}
+}
+
+func TestScheduler_Requeue(t *testing.T) {
+	s := New()
+	ran := make(chan struct{}, 4)
+	s.Add(Job{
+		Name:       "backup",
+		Interval:   time.Hour,
+		StuckAfter: time.Minute,
+		RunAtStart: true,
+		Run: func(ctx context.Context, beat func()) error {
+			ran <- struct{}{}
+			return nil
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+
+	<-ran // RunAtStart
+	if _, err := s.Requeue("backup"); err != nil {
+		t.Fatalf("Requeue() = %v", err)
+	}
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("re-queued run did not start")
+	}
+
+	cancel()
+	<-done
+	if st := s.Status()[0]; st.Runs != 2 || st.Failures != 0 {
+		t.Errorf("status = %+v, want 2 successful runs", st)
+	}
+	if _, err := s.Requeue("nope"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("Requeue() on unknown job = %v, want ErrUnknownJob", err)
+	}
+}
+
+func TestScheduler_RequeueAbortsStuckRun(t *testing.T) {
+	c := &clock{now: time.Now()}
+	s := New()
+	s.now = c.Now
+
+	started := make(chan struct{}, 2)
+	s.Add(Job{Name: "reset", Interval: time.Hour, StuckAfter: time.Minute, Run: blockingJob(started)})
+	j := s.find("reset")
+
+	done := make(chan struct{})
+	go func() {
+		s.run(context.Background(), j)
+		close(done)
+	}()
+	<-started
+
+	// A healthy run is left alone
+	if st, _ := s.Requeue("reset"); !st.Running || !st.Queued {
+		t.Fatalf("status = %+v, want running with a queued run", st)
+	}
+	select {
+	case <-done:
+		t.Fatal("healthy run was aborted by Requeue")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	c.Advance(2 * time.Minute)
+	s.watch()
+	s.Requeue("reset")
+	<-done
+	if st := s.Status()[0]; st.Aborts != 1 || !st.Queued {
+		t.Errorf("status = %+v, want the stuck run aborted and a run queued", st)
+	}
+}
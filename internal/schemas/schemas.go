@@ -0,0 +1,10 @@
+// Package schemas embeds the JSON Schema documents describing the API's
+// request and response bodies.
+package schemas
+
+import "embed"
+
+// FS holds every schema document, keyed by file name
+//
+//go:embed *.json
+var FS embed.FS
@@ -0,0 +1,302 @@
+// Package seed replaces the contents of an in-memory repository with a
+// fixed set of workspaces, API keys and tasks, so demos and integration
+// tests start from a known state.
+package seed
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/auth"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/validation"
+)
+
+// Fixtures is the seed file format. Workspaces are the projects tasks are
+// grouped in, and API keys are the users, with secrets fixed in the file
+// so clients can authenticate as them.
+type Fixtures struct {
+	Workspaces []Workspace `json:"workspaces,omitempty"`
+	APIKeys    []APIKey    `json:"api_keys,omitempty"`
+	Tasks      []Task      `json:"tasks,omitempty"`
+}
+
+// Workspace is a workspace to create; listing the default workspace
+// renames it
+type Workspace struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Timezone     string `json:"timezone,omitempty"`
+	EstimateUnit string `json:"estimate_unit,omitempty"`
+}
+
+// APIKey is an API key with a chosen secret
+type APIKey struct {
+	Name        string             `json:"name"`
+	Scope       models.APIKeyScope `json:"scope"`
+	WorkspaceID string             `json:"workspace_id,omitempty"`
+
+	// Key is the secret clients send; it must start with "ctk_" and be at
+	// least 32 characters long
+	Key string `json:"key"`
+}
+
+// Task is a task with a fixed ID. Tasks without an ID are numbered after
+// the highest ID in the file; an empty workspace means the default one
+// and an empty status means todo.
+type Task struct {
+	ID          int64             `json:"id,omitempty"`
+	WorkspaceID string            `json:"workspace_id,omitempty"`
+	OwnerID     string            `json:"owner_id,omitempty"`
+	Title       string            `json:"title"`
+	Description string            `json:"description,omitempty"`
+	Status      models.TaskStatus `json:"status,omitempty"`
+	DueAt       *time.Time        `json:"due_at,omitempty"`
+	Estimate    int               `json:"estimate,omitempty"`
+	Links       []models.TaskLink `json:"links,omitempty"`
+}
+
+// Result counts what a Load stored
+type Result struct {
+	Workspaces int `json:"workspaces"`
+	APIKeys    int `json:"api_keys"`
+	Tasks      int `json:"tasks"`
+}
+
+// Store is a repository whose contents can be replaced wholesale;
+// MemoryRepository implements it
+type Store interface {
+	Restore(tasks []*models.Task, lastID int64)
+	RestoreWorkspaces(workspaces []*models.Workspace)
+	RestoreAPIKeys(keys []*models.APIKey)
+	RestoreWebhooks(hooks []*models.Webhook)
+}
+
+// Decode reads fixtures from r, rejecting unknown fields so that typos in
+// a seed file are not silently ignored
+func Decode(r io.Reader) (*Fixtures, error) {
+	var fx Fixtures
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&fx); err != nil {
+		return nil, err
+	}
+	return &fx, nil
+}
+
+// ReadFile decodes the fixtures in the file at path
+func ReadFile(path string) (*Fixtures, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fx, err := Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", path, err)
+	}
+	return fx, nil
+}
+
+// Seeder loads fixtures into a store
+type Seeder struct {
+	store     Store
+	validator *validation.Validator
+
+	mu   sync.Mutex
+	last *Fixtures
+}
+
+// New creates a Seeder for store
+func New(store Store) *Seeder {
+	return &Seeder{store: store, validator: validation.Default()}
+}
+
+// Load replaces every task, workspace, API key and webhook in the store
+// with fx. Invalid fixtures leave the store unchanged and return an error
+// listing every problem. Load does not publish task events.
+func (s *Seeder) Load(fx *Fixtures) (Result, error) {
+	data, err := s.build(fx, time.Now().UTC())
+	if err != nil {
+		return Result{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.last = fx
+	return s.apply(data), nil
+}
+
+// Reload loads the last fixtures again, or empties the store if none were
+// loaded
+func (s *Seeder) Reload() Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fx := s.last
+	if fx == nil {
+		fx = &Fixtures{}
+	}
+	data, _ := s.build(fx, time.Now().UTC()) // validated by Load
+	return s.apply(data)
+}
+
+// Reset calls Reload. Demo mode takes a Seeder in place of the repository
+// so that its periodic resets return to the fixtures.
+func (s *Seeder) Reset() {
+	s.Reload()
+}
+
+// data is fixtures converted to the stored models
+type data struct {
+	workspaces []*models.Workspace
+	keys       []*models.APIKey
+	tasks      []*models.Task
+}
+
+// apply replaces the store's contents; s.mu must be held
+func (s *Seeder) apply(d data) Result {
+	s.store.RestoreWebhooks(nil)
+	s.store.RestoreWorkspaces(d.workspaces)
+	s.store.RestoreAPIKeys(d.keys)
+	s.store.Restore(d.tasks, 0)
+	return Result{Workspaces: len(d.workspaces), APIKeys: len(d.keys), Tasks: len(d.tasks)}
+}
+
+// build validates fx and converts it, with timestamps at now
+func (s *Seeder) build(fx *Fixtures, now time.Time) (data, error) {
+	var d data
+	var errs []string
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	}
+
+	workspaces := map[string]bool{models.DefaultWorkspace: true}
+	for i, w := range fx.Workspaces {
+		if w.ID != models.DefaultWorkspace && workspaces[w.ID] {
+			fail("workspaces[%d]: duplicate id %q", i, w.ID)
+			continue
+		}
+		if err := s.validator.ValidateCreateWorkspace(&models.CreateWorkspaceRequest{ID: w.ID, Name: w.Name, Timezone: w.Timezone, EstimateUnit: w.EstimateUnit}); err != nil {
+			fail("workspaces[%d]: %v", i, err)
+			continue
+		}
+		workspaces[w.ID] = true
+		d.workspaces = append(d.workspaces, &models.Workspace{ID: w.ID, Name: w.Name, Timezone: w.Timezone, EstimateUnit: w.EstimateUnit, CreatedAt: now, UpdatedAt: now})
+	}
+
+	secrets := make(map[string]bool)
+	for i, k := range fx.APIKeys {
+		if err := s.validator.ValidateAPIKey(&models.CreateAPIKeyRequest{Name: k.Name, Scope: k.Scope, WorkspaceID: k.WorkspaceID}); err != nil {
+			fail("api_keys[%d]: %v", i, err)
+			continue
+		}
+		if k.WorkspaceID != "" && !workspaces[k.WorkspaceID] {
+			fail("api_keys[%d]: unknown workspace %q", i, k.WorkspaceID)
+			continue
+		}
+		if secrets[k.Key] {
+			fail("api_keys[%d]: duplicate key", i)
+			continue
+		}
+		key, err := auth.KeyFromSecret(k.Name, k.Scope, k.WorkspaceID, k.Key)
+		if err != nil {
+			fail("api_keys[%d]: %v", i, err)
+			continue
+		}
+		secrets[k.Key] = true
+		key.ID = int64(i + 1)
+		key.CreatedAt = now
+		d.keys = append(d.keys, key)
+	}
+
+	// Number the tasks first, so links can point at tasks later in the file
+	var lastID int64
+	for _, t := range fx.Tasks {
+		lastID = max(lastID, t.ID)
+	}
+	ids := make([]int64, len(fx.Tasks))
+	byID := make(map[int64]Task, len(fx.Tasks))
+	for i, t := range fx.Tasks {
+		switch {
+		case t.ID < 0:
+			fail("tasks[%d]: id must be positive", i)
+			continue
+		case t.ID == 0:
+			lastID++
+			t.ID = lastID
+		case hasID(byID, t.ID):
+			fail("tasks[%d]: duplicate id %d", i, t.ID)
+			continue
+		}
+		if t.WorkspaceID == "" {
+			t.WorkspaceID = models.DefaultWorkspace
+		}
+		ids[i] = t.ID
+		byID[t.ID] = t
+	}
+
+	for i, t := range fx.Tasks {
+		if ids[i] == 0 {
+			continue
+		}
+		t = byID[ids[i]]
+		if t.Status == "" {
+			t.Status = models.StatusTodo
+		}
+
+		req := models.UpdateTaskRequest{Title: t.Title, Description: t.Description, Status: t.Status, DueAt: t.DueAt, Estimate: t.Estimate}
+		if err := s.validator.ValidateUpdate(&req); err != nil {
+			fail("tasks[%d]: %v", i, err)
+		}
+		if !workspaces[t.WorkspaceID] {
+			fail("tasks[%d]: unknown workspace %q", i, t.WorkspaceID)
+		}
+		for _, link := range t.Links {
+			target, ok := byID[link.TaskID]
+			switch {
+			case !link.Type.IsValid():
+				fail("tasks[%d]: unknown link type %q", i, link.Type)
+			case link.TaskID == t.ID:
+				fail("tasks[%d]: task cannot be linked to itself", i)
+			case !ok || target.WorkspaceID != t.WorkspaceID:
+				fail("tasks[%d]: linked task %d is not in workspace %q", i, link.TaskID, t.WorkspaceID)
+			}
+		}
+
+		task := &models.Task{
+			ID:          t.ID,
+			WorkspaceID: t.WorkspaceID,
+			OwnerID:     t.OwnerID,
+			Title:       req.Title,
+			Description: t.Description,
+			Status:      t.Status,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+			Estimate:    t.Estimate,
+			Links:       append([]models.TaskLink(nil), t.Links...),
+		}
+		if t.DueAt != nil {
+			due := t.DueAt.UTC()
+			task.DueAt = &due
+		}
+		d.tasks = append(d.tasks, task)
+	}
+
+	if len(errs) > 0 {
+		return data{}, errors.New(strings.Join(errs, "; "))
+	}
+	return d, nil
+}
+
+func hasID(byID map[int64]Task, id int64) bool {
+	_, ok := byID[id]
+	return ok
+}
@@ -0,0 +1,106 @@
+package seed
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/light-bringer/cert-tasks/internal/auth"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+const fixturesJSON = `{
+  "workspaces": [{"id": "acme", "name": "Acme"}],
+  "api_keys": [{"name": "alice", "scope": "read_write", "workspace_id": "acme", "key": "ctk_alice_0123456789abcdefghijklmn"}],
+  "tasks": [
+    {"id": 10, "workspace_id": "acme", "title": "Renew cert", "links": [{"type": "relates_to", "task_id": 11}]},
+    {"id": 11, "workspace_id": "acme", "title": "Rotate keys", "status": "done", "due_at": "2026-03-01T09:00:00+01:00"},
+    {"title": "Default task"}
+  ]
+}`
+
+func TestSeeder_Load(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	repo.Create(ctx, &models.Task{Title: "Old", Status: models.StatusTodo})
+
+	fx, err := Decode(strings.NewReader(fixturesJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(repo)
+	result, err := s.Load(fx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != (Result{Workspaces: 1, APIKeys: 1, Tasks: 3}) {
+		t.Errorf("result = %+v", result)
+	}
+
+	tasks, _ := repo.List(ctx, repository.ListOptions{})
+	if len(tasks) != 3 || tasks[0].ID != 10 || tasks[1].ID != 11 || tasks[2].ID != 12 {
+		t.Fatalf("tasks = %+v, want IDs 10, 11, 12", tasks)
+	}
+	if tasks[0].Status != models.StatusTodo || len(tasks[0].Links) != 1 || tasks[2].WorkspaceID != models.DefaultWorkspace {
+		t.Errorf("defaults not applied: %+v, %+v", tasks[0], tasks[2])
+	}
+	if due := tasks[1].DueAt; due == nil || due.Location().String() != "UTC" || due.Hour() != 8 {
+		t.Errorf("due_at = %v, want 08:00 UTC", due)
+	}
+	if _, err := repo.GetWorkspace(ctx, "acme"); err != nil {
+		t.Errorf("workspace not created: %v", err)
+	}
+	key, err := auth.New(repo).Authenticate(ctx, fx.APIKeys[0].Key)
+	if err != nil || key.WorkspaceID != "acme" {
+		t.Errorf("Authenticate = %+v, %v", key, err)
+	}
+
+	// New tasks continue after the highest seeded ID
+	created, _ := repo.Create(ctx, &models.Task{Title: "New", Status: models.StatusTodo})
+	if created.ID != 13 {
+		t.Errorf("next ID = %d, want 13", created.ID)
+	}
+
+	// Reset returns to the fixtures
+	s.Reset()
+	if tasks, _ := repo.List(ctx, repository.ListOptions{}); len(tasks) != 3 {
+		t.Errorf("after Reset: %d tasks, want 3", len(tasks))
+	}
+}
+
+func TestSeeder_LoadInvalid(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	repo.Create(ctx, &models.Task{Title: "Kept", Status: models.StatusTodo})
+
+	fx := &Fixtures{
+		Workspaces: []Workspace{{ID: "Bad ID", Name: "x"}},
+		APIKeys:    []APIKey{{Name: "k", Scope: models.ScopeRead, Key: "short"}},
+		Tasks: []Task{
+			{ID: 1, Title: "a", WorkspaceID: "nowhere"},
+			{ID: 1, Title: "b"},
+			{ID: 2, Title: "", Status: "doing"},
+			{ID: 3, Title: "c", Links: []models.TaskLink{{Type: models.LinkRelatesTo, TaskID: 99}}},
+		},
+	}
+	_, err := New(repo).Load(fx)
+	if err == nil {
+		t.Fatal("Load succeeded")
+	}
+	for _, want := range []string{"workspaces[0]", "api_keys[0]", `unknown workspace "nowhere"`, "duplicate id 1", "tasks[2]", "linked task 99"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+
+	if tasks, _ := repo.List(ctx, repository.ListOptions{}); len(tasks) != 1 || tasks[0].Title != "Kept" {
+		t.Errorf("store changed by invalid fixtures: %+v", tasks)
+	}
+}
+
+func TestDecode_UnknownField(t *testing.T) {
+	if _, err := Decode(strings.NewReader(`{"users": []}`)); err == nil {
+		t.Error("Decode accepted an unknown field")
+	}
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"time"
+)
+
+// AdminHandler returns the handler for the admin listener: the
+// net/http/pprof profiles under /debug/pprof/ and expvar variables at
+// /debug/vars. It must never be exposed on the public listener.
+func AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	return mux
+}
+
+// RunAdmin serves AdminHandler on ln, or on addr if ln is nil, until ctx
+// is cancelled
+func RunAdmin(ctx context.Context, addr string, ln net.Listener) error {
+	srv := &http.Server{
+		Addr:        addr,
+		Handler:     AdminHandler(),
+		ReadTimeout: 15 * time.Second,
+		// No WriteTimeout: CPU profiles and traces stream for as long as
+		// the ?seconds= parameter asks
+		IdleTimeout: 60 * time.Second,
+	}
+
+	ln, err := listen(ln, addr)
+	if err != nil {
+		return fmt.Errorf("admin server error: %w", err)
+	}
+
+	serverErrors := make(chan error, 1)
+	go func() {
+		slog.Info("admin server starting", slog.String("addr", ln.Addr().String()))
+		serverErrors <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-serverErrors:
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("admin server error: %w", err)
+		}
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			return fmt.Errorf("admin server shutdown failed: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/light-bringer/cert-tasks/internal/config"
+	"github.com/light-bringer/cert-tasks/internal/handlers"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+func TestAdminHandler(t *testing.T) {
+	admin := AdminHandler()
+
+	rec := httptest.NewRecorder()
+	admin.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/vars", nil))
+	var vars map[string]json.RawMessage
+	if err := json.NewDecoder(rec.Body).Decode(&vars); err != nil {
+		t.Fatalf("/debug/vars is not JSON: %v", err)
+	}
+	if _, ok := vars["memstats"]; !ok {
+		t.Error("/debug/vars is missing memstats")
+	}
+
+	rec = httptest.NewRecorder()
+	admin.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/pprof/heap?debug=1", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("heap profile status = %v, want %v", rec.Code, http.StatusOK)
+	}
+}
+
+func TestServer_DebugEndpointsNotPublic(t *testing.T) {
+	srv := NewServer(config.Default(false).Server, handlers.NewTaskHandler(repository.NewMemoryRepository()))
+
+	for _, path := range []string{"/debug/vars", "/debug/pprof/"} {
+		rec := httptest.NewRecorder()
+		srv.router.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s on the public router = %v, want %v", path, rec.Code, http.StatusNotFound)
+		}
+	}
+}
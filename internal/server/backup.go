@@ -0,0 +1,87 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/light-bringer/cert-tasks/internal/backup"
+	"github.com/light-bringer/cert-tasks/internal/handlers"
+	"github.com/light-bringer/cert-tasks/internal/logging"
+	"github.com/light-bringer/cert-tasks/internal/maintenance"
+)
+
+// backupRoutes writes backups to the configured destination and restores
+// them. A restore puts the server in maintenance mode while it runs, so
+// that no write lands between reading the storage and replacing it, and
+// leaves it as it was afterwards. Both run under the bulk request timeout.
+//
+//api:changelog 0.2.0 added endpoint POST /admin/backup: Write a consistent snapshot of all tasks, revisions, workspaces, API keys and webhooks to the backup destination as a tarball of NDJSON files
+//api:changelog 0.2.0 added endpoint POST /admin/restore: Load a backup with a replace, skip, overwrite or fail conflict policy, or preview the changes with dry_run
+func backupRoutes(r chi.Router, handler *handlers.TaskHandler, backups *backup.Manager, mode *maintenance.Mode) {
+	r.Post("/admin/backup", func(w http.ResponseWriter, r *http.Request) {
+		info, err := backups.Backup(r.Context())
+		if err != nil {
+			logging.FromContext(r.Context()).Error("writing backup", slog.Any("error", err))
+			handler.Error(w, r, http.StatusInternalServerError, handlers.CodeInternal, "failed to write backup")
+			return
+		}
+		logging.FromContext(r.Context()).Info("backup written",
+			slog.String("name", info.Name),
+			slog.Int64("size_bytes", info.SizeBytes),
+			slog.Int("tasks", info.Counts.Tasks),
+		)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(info)
+	})
+
+	r.Post("/admin/restore", func(w http.ResponseWriter, r *http.Request) {
+		var req backup.Request
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&req); err != nil {
+			handler.Error(w, r, http.StatusBadRequest, handlers.CodeInvalidJSON, "invalid request body: "+err.Error())
+			return
+		}
+		if req.Name == "" {
+			handler.Error(w, r, http.StatusUnprocessableEntity, handlers.CodeValidationFailed, "name is required")
+			return
+		}
+		if req.Policy != "" && !req.Policy.Valid() {
+			handler.Error(w, r, http.StatusUnprocessableEntity, handlers.CodeValidationFailed,
+				"policy must be replace, skip, overwrite or fail")
+			return
+		}
+
+		if !req.DryRun {
+			release := mode.Hold("Restoring a backup")
+			defer release()
+		}
+		result, err := backups.Restore(r.Context(), req)
+		switch {
+		case errors.Is(err, backup.ErrNotFound):
+			handler.Error(w, r, http.StatusNotFound, handlers.CodeNotFound, "backup not found")
+			return
+		case errors.Is(err, backup.ErrInvalidArchive):
+			handler.Error(w, r, http.StatusUnprocessableEntity, handlers.CodeValidationFailed, err.Error())
+			return
+		case errors.Is(err, backup.ErrConflict):
+			handler.Error(w, r, http.StatusConflict, handlers.CodeConflict,
+				"backup conflicts with stored records; list them with dry_run or choose another policy")
+			return
+		case err != nil:
+			logging.FromContext(r.Context()).Error("restoring backup", slog.Any("error", err))
+			handler.Error(w, r, http.StatusInternalServerError, handlers.CodeInternal, "failed to restore backup")
+			return
+		}
+		logging.FromContext(r.Context()).Warn("backup restored",
+			slog.String("name", result.Name),
+			slog.String("policy", string(result.Policy)),
+			slog.Bool("dry_run", result.DryRun),
+		)
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(result)
+	})
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/backup"
+	"github.com/light-bringer/cert-tasks/internal/blob"
+	"github.com/light-bringer/cert-tasks/internal/config"
+	"github.com/light-bringer/cert-tasks/internal/handlers"
+	"github.com/light-bringer/cert-tasks/internal/maintenance"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+func TestServer_BackupRestore(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	repo.Create(ctx, &models.Task{Title: "Renew certificate"})
+
+	store, err := blob.NewDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mode := &maintenance.Mode{}
+	srv := NewServer(config.Default(false).Server, handlers.NewTaskHandler(repo),
+		WithBackups(backup.New(repo, store, "file:///backups")), WithMaintenance(mode), WithAdminKey(testAdminKey))
+	post := func(target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.router.ServeHTTP(rec, adminRequest("POST", target, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := post("/admin/backup", "")
+	var info backup.Info
+	json.NewDecoder(rec.Body).Decode(&info)
+	if rec.Code != http.StatusCreated || info.Name == "" || info.Counts.Tasks != 1 {
+		t.Fatalf("backup = %v %+v", rec.Code, info)
+	}
+
+	// The task is both stored and in the backup
+	rec = post("/admin/restore", `{"name": "`+info.Name+`"}`)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("restore with conflicts status = %v, want %v", rec.Code, http.StatusConflict)
+	}
+
+	rec = post("/admin/restore", `{"name": "`+info.Name+`", "policy": "overwrite", "dry_run": true}`)
+	var result backup.Result
+	json.NewDecoder(rec.Body).Decode(&result)
+	if rec.Code != http.StatusOK || !result.DryRun || result.Tasks.Overwritten != 1 {
+		t.Errorf("dry run = %v %+v", rec.Code, result)
+	}
+
+	rec = post("/admin/restore", `{"name": "`+info.Name+`", "policy": "replace"}`)
+	if rec.Code != http.StatusOK {
+		t.Errorf("restore status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if mode.State().Enabled {
+		t.Error("maintenance mode left on after the restore")
+	}
+
+	for _, tc := range []struct {
+		body string
+		want int
+	}{
+		{`{"name": "missing.tar.gz"}`, http.StatusNotFound},
+		{`{"name": "` + info.Name + `", "policy": "merge"}`, http.StatusUnprocessableEntity},
+		{`{}`, http.StatusUnprocessableEntity},
+		{`{"name": 1}`, http.StatusBadRequest},
+	} {
+		if rec := post("/admin/restore", tc.body); rec.Code != tc.want {
+			t.Errorf("restore %s status = %v, want %v", tc.body, rec.Code, tc.want)
+		}
+	}
+}
+
+func TestServer_RestoreNeedsAdminKey(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	store, err := blob.NewDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer(config.Default(false).Server, handlers.NewTaskHandler(repo),
+		WithBackups(backup.New(repo, store, "file:///backups")))
+
+	for _, target := range []string{"/admin/backup", "/admin/restore"} {
+		rec := httptest.NewRecorder()
+		srv.router.ServeHTTP(rec, httptest.NewRequest("POST", target, strings.NewReader(`{"name": "x.tar.gz", "policy": "replace"}`)))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("POST %s without an admin key = %v, want %v", target, rec.Code, http.StatusNotFound)
+		}
+	}
+}
+
+func TestServer_RestoreKeepsMaintenanceAndOutlivesAdminTimeout(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	repo.Create(ctx, &models.Task{Title: "Renew certificate"})
+	store, err := blob.NewDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	backups := backup.New(repo, store, "file:///backups")
+	info, err := backups.Backup(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Under the admin timeout, the restore would get 503 at once
+	cfg := config.Default(false).Server
+	cfg.RequestTimeouts.Admin = time.Nanosecond
+	mode := &maintenance.Mode{}
+	mode.Set(true, "Migrating storage")
+	srv := NewServer(cfg, handlers.NewTaskHandler(repo), WithBackups(backups), WithMaintenance(mode), WithAdminKey(testAdminKey))
+
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, adminRequest("POST", "/admin/restore", strings.NewReader(`{"name": "`+info.Name+`", "policy": "replace"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("restore status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if s := mode.State(); !s.Enabled || s.Message != "Migrating storage" {
+		t.Errorf("maintenance state after the restore = %+v, want it still on", s)
+	}
+}
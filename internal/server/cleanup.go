@@ -0,0 +1,48 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/light-bringer/cert-tasks/internal/cleanup"
+	"github.com/light-bringer/cert-tasks/internal/handlers"
+	"github.com/light-bringer/cert-tasks/internal/logging"
+)
+
+// cleanupRoute runs the janitor's retention policies on demand, without
+// waiting for its scheduled run. With ?dry_run=true it only reports what
+// would be deleted.
+//
+//api:changelog 0.2.0 added endpoint POST /admin/cleanup: Delete the tasks the retention policies select now, or preview them with ?dry_run=true
+func cleanupRoute(r chi.Router, handler *handlers.TaskHandler, janitor *cleanup.Janitor) {
+	r.Post("/admin/cleanup", func(w http.ResponseWriter, r *http.Request) {
+		dryRun := false
+		if v := r.URL.Query().Get("dry_run"); v != "" {
+			var err error
+			if dryRun, err = strconv.ParseBool(v); err != nil {
+				handler.Error(w, r, http.StatusBadRequest, handlers.CodeInvalidQuery, "dry_run must be true or false")
+				return
+			}
+		}
+
+		run := func() (cleanup.Result, error) { return janitor.Run(r.Context(), func() {}) }
+		if dryRun {
+			run = func() (cleanup.Result, error) { return janitor.Preview(r.Context()) }
+		}
+		result, err := run()
+		if err != nil {
+			logging.FromContext(r.Context()).Error("running cleanup", slog.Any("error", err))
+			handler.Error(w, r, http.StatusInternalServerError, handlers.CodeInternal, "failed to run cleanup")
+			return
+		}
+		logging.FromContext(r.Context()).Info("cleanup run on demand",
+			slog.Bool("dry_run", result.DryRun),
+			slog.Int("deleted", result.Deleted),
+		)
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(result)
+	})
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/cleanup"
+	"github.com/light-bringer/cert-tasks/internal/config"
+	"github.com/light-bringer/cert-tasks/internal/handlers"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+func TestServer_Cleanup(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	task, _ := repo.Create(ctx, &models.Task{Title: "Old report"})
+	repo.Update(ctx, task.ID, &models.Task{Title: "Old report", Status: models.StatusDone})
+
+	janitor := cleanup.New(repo, []cleanup.Policy{{Status: models.StatusDone, OlderThan: time.Nanosecond}})
+	srv := NewServer(config.Default(false).Server, handlers.NewTaskHandler(repo), WithCleanup(janitor), WithAdminKey(testAdminKey))
+	post := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.router.ServeHTTP(rec, adminRequest("POST", target, nil))
+		return rec
+	}
+
+	rec := post("/admin/cleanup?dry_run=true")
+	var result cleanup.Result
+	json.NewDecoder(rec.Body).Decode(&result)
+	if rec.Code != http.StatusOK || !result.DryRun || result.Deleted != 1 {
+		t.Fatalf("dry run = %v %+v", rec.Code, result)
+	}
+	if exists, _ := repo.Exists(ctx, task.ID); !exists {
+		t.Fatal("dry run deleted the task")
+	}
+
+	rec = post("/admin/cleanup")
+	json.NewDecoder(rec.Body).Decode(&result)
+	if rec.Code != http.StatusOK || result.DryRun || result.Deleted != 1 {
+		t.Fatalf("run = %v %+v", rec.Code, result)
+	}
+	if exists, _ := repo.Exists(ctx, task.ID); exists {
+		t.Error("task survived the cleanup")
+	}
+
+	if rec := post("/admin/cleanup?dry_run=maybe"); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid dry_run status = %v, want %v", rec.Code, http.StatusBadRequest)
+	}
+
+	// Without WithCleanup the route does not exist
+	plain := NewServer(config.Default(false).Server, handlers.NewTaskHandler(repo), WithAdminKey(testAdminKey))
+	rec = httptest.NewRecorder()
+	plain.router.ServeHTTP(rec, adminRequest("POST", "/admin/cleanup", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("without WithCleanup status = %v, want %v", rec.Code, http.StatusNotFound)
+	}
+}
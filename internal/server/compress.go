@@ -0,0 +1,299 @@
+package server
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/light-bringer/cert-tasks/internal/config"
+)
+
+// Writers are pooled, as each holds several hundred KB of state
+var (
+	gzipWriters = sync.Pool{New: func() any {
+		w, _ := gzip.NewWriterLevel(io.Discard, gzip.DefaultCompression)
+		return w
+	}}
+	flateWriters = sync.Pool{New: func() any {
+		w, _ := flate.NewWriter(io.Discard, flate.DefaultCompression)
+		return w
+	}}
+)
+
+// compress encodes responses with gzip or deflate, as negotiated with
+// Accept-Encoding, when their content type is listed and their body
+// reaches the minimum size. Bodies are compressed as they are written, so
+// streamed exports stay streamed. A compressed body is a different
+// representation, so a strong ETag gets the encoding appended ("…-gzip");
+// the suffix is removed again from If-Match and If-None-Match, so the
+// handlers compare the ETags they computed.
+func compress(cfg config.Compression) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ifNoneMatch := r.Header.Get("If-None-Match")
+			for _, name := range []string{"If-Match", "If-None-Match"} {
+				if v := r.Header.Get(name); v != "" {
+					r.Header.Set(name, decodeETags(v))
+				}
+			}
+
+			// WebSocket upgrades need the connection itself
+			if r.Header.Get("Upgrade") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Accept-Encoding")
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+			if encoding == "" || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Not deferred: after a panic nothing must be sent, so that
+			// the recoverer can still write its 500
+			cw := &compressWriter{ResponseWriter: w, cfg: cfg, encoding: encoding, ifNoneMatch: ifNoneMatch}
+			next.ServeHTTP(cw, r)
+			cw.close()
+		})
+	}
+}
+
+// negotiateEncoding picks gzip or deflate from an Accept-Encoding header,
+// preferring the higher quality and gzip on a tie, or returns "" when the
+// client accepts neither
+func negotiateEncoding(header string) string {
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		qualities[name] = q
+	}
+
+	var best string
+	var bestQ float64
+	for _, encoding := range []string{"gzip", "deflate"} {
+		q, ok := qualities[encoding]
+		if !ok {
+			q = qualities["*"]
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// encodingSuffixes are the ETag suffixes of compressed representations
+var encodingSuffixes = []string{`-gzip"`, `-deflate"`}
+
+// encodedETag returns the ETag of the representation of etag compressed
+// with encoding. Weak ETags already allow for such differences.
+func encodedETag(etag, encoding string) string {
+	if !strings.HasPrefix(etag, `"`) || !strings.HasSuffix(etag, `"`) || len(etag) < 2 {
+		return etag
+	}
+	return etag[:len(etag)-1] + "-" + encoding + `"`
+}
+
+// decodeETags removes the encoding suffixes from a list of entity tags, as
+// sent in If-Match and If-None-Match
+func decodeETags(header string) string {
+	tags := strings.Split(header, ",")
+	for i, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		for _, suffix := range encodingSuffixes {
+			if base, ok := strings.CutSuffix(tag, suffix); ok {
+				tag = base + `"`
+				break
+			}
+		}
+		tags[i] = tag
+	}
+	return strings.Join(tags, ", ")
+}
+
+// compressWriter holds back the body until it reaches the minimum size,
+// then decides whether to compress it. A flush decides early.
+type compressWriter struct {
+	http.ResponseWriter
+	cfg      config.Compression
+	encoding string
+
+	// ifNoneMatch is the request's header before decodeETags, so that a
+	// 304 can repeat the ETag the client holds
+	ifNoneMatch string
+
+	status  int
+	buf     []byte
+	decided bool
+	enc     interface {
+		io.WriteCloser
+		Flush() error
+	}
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if status < http.StatusOK {
+		w.ResponseWriter.WriteHeader(status) // informational, such as 103
+		return
+	}
+	if w.status != 0 || w.decided {
+		return
+	}
+	w.status = status
+	if status == http.StatusNotModified {
+		w.notModifiedETag()
+	}
+	// Bodiless and partial responses are never compressed
+	if status == http.StatusNoContent || status == http.StatusNotModified || status == http.StatusPartialContent {
+		w.decide(false)
+	}
+}
+
+// notModifiedETag sends back the ETag the request's If-None-Match matched
+// in the form the client holds it, which may be that of a compressed
+// response
+func (w *compressWriter) notModifiedETag() {
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		return
+	}
+	for _, tag := range strings.Split(w.ifNoneMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag != etag && decodeETags(tag) == etag {
+			w.Header().Set("ETag", tag)
+			return
+		}
+	}
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.decided {
+		if w.enc != nil {
+			return w.enc.Write(p)
+		}
+		return w.ResponseWriter.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= w.cfg.MinSize {
+		if err := w.decide(w.compressible()); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush sends what has been written so far, compressing it if the type
+// qualifies, so that streamed responses are not held back
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide(w.compressible() && len(w.buf) > 0)
+	}
+	if w.enc != nil {
+		w.enc.Flush()
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// compressible reports whether the response may be compressed
+func (w *compressWriter) compressible() bool {
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	for _, pattern := range w.cfg.ContentTypes {
+		if pattern == mediaType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasPrefix(mediaType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// decide writes the header, switching to the negotiated encoding if
+// compress is set, and then the held-back body
+func (w *compressWriter) decide(compress bool) error {
+	w.decided = true
+	if compress {
+		h := w.Header()
+		h.Set("Content-Encoding", w.encoding)
+		h.Del("Content-Length")
+		if etag := h.Get("ETag"); etag != "" {
+			h.Set("ETag", encodedETag(etag, w.encoding))
+		}
+		switch w.encoding {
+		case "gzip":
+			gz := gzipWriters.Get().(*gzip.Writer)
+			gz.Reset(w.ResponseWriter)
+			w.enc = gz
+		case "deflate":
+			fw := flateWriters.Get().(*flate.Writer)
+			fw.Reset(w.ResponseWriter)
+			w.enc = fw
+		}
+	}
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if w.enc != nil {
+		_, err := w.enc.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+// close sends a body that never reached the minimum size uncompressed, or
+// finishes the compressed stream
+func (w *compressWriter) close() {
+	if !w.decided {
+		w.decide(false)
+	}
+	if w.enc == nil {
+		return
+	}
+	w.enc.Close()
+	switch enc := w.enc.(type) {
+	case *gzip.Writer:
+		enc.Reset(io.Discard)
+		gzipWriters.Put(enc)
+	case *flate.Writer:
+		enc.Reset(io.Discard)
+		flateWriters.Put(enc)
+	}
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/light-bringer/cert-tasks/internal/config"
+	"github.com/light-bringer/cert-tasks/internal/handlers"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := map[string]string{
+		"":                        "",
+		"gzip":                    "gzip",
+		"deflate":                 "deflate",
+		"deflate, gzip":           "gzip",
+		"gzip;q=0.5, deflate":     "deflate",
+		"br, *":                   "gzip",
+		"gzip;q=0, *":             "deflate",
+		"gzip;q=0, deflate;q=0":   "",
+		"identity":                "",
+		"GZIP ; q=0.8, br;q=1.0":  "gzip",
+		"gzip;q=bad, deflate;q=1": "deflate",
+	}
+	for header, want := range tests {
+		if got := negotiateEncoding(header); got != want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestServer_Compression(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	for i := range 50 {
+		repo.Create(ctx, &models.Task{Title: fmt.Sprintf("Task %d", i), Status: models.StatusTodo})
+	}
+	cfg := config.Default(false).Server
+	srv := NewServer(cfg, handlers.NewTaskHandler(repo))
+
+	get := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+	plain := get("/tasks", "")
+	if plain.Header().Get("Content-Encoding") != "" || !strings.Contains(plain.Header().Get("Vary"), "Accept-Encoding") {
+		t.Fatalf("without Accept-Encoding: headers %v", plain.Header())
+	}
+
+	for _, tt := range []struct {
+		encoding string
+		reader   func(io.Reader) (io.Reader, error)
+	}{
+		{"gzip", func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }},
+		{"deflate", func(r io.Reader) (io.Reader, error) { return flate.NewReader(r), nil }},
+	} {
+		t.Run(tt.encoding, func(t *testing.T) {
+			rec := get("/tasks", tt.encoding)
+			if got := rec.Header().Get("Content-Encoding"); got != tt.encoding {
+				t.Fatalf("Content-Encoding = %q, want %q", got, tt.encoding)
+			}
+			if rec.Body.Len() >= plain.Body.Len() {
+				t.Errorf("compressed body is %d bytes, plain %d", rec.Body.Len(), plain.Body.Len())
+			}
+			r, err := tt.reader(rec.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := io.ReadAll(r)
+			if err != nil || string(body) != plain.Body.String() {
+				t.Errorf("decompressed body differs (%v)", err)
+			}
+			etag := rec.Header().Get("ETag")
+			if want := strings.TrimSuffix(plain.Header().Get("ETag"), `"`) + "-" + tt.encoding + `"`; etag != want {
+				t.Errorf("ETag = %s, want %s", etag, want)
+			}
+
+			// The compressed ETag validates like the plain one
+			req := httptest.NewRequest("GET", "/tasks", nil)
+			req.Header.Set("Accept-Encoding", tt.encoding)
+			req.Header.Set("If-None-Match", etag)
+			rec = httptest.NewRecorder()
+			srv.Handler().ServeHTTP(rec, req)
+			if rec.Code != http.StatusNotModified || rec.Header().Get("ETag") != etag {
+				t.Errorf("If-None-Match %s: status %d, ETag %s", etag, rec.Code, rec.Header().Get("ETag"))
+			}
+		})
+	}
+
+	// Small bodies and bodiless responses go out as they are
+	small := get("/tasks/1", "gzip")
+	var task models.Task
+	if small.Header().Get("Content-Encoding") != "" || json.Unmarshal(small.Body.Bytes(), &task) != nil {
+		t.Errorf("small response: Content-Encoding %q, body %q", small.Header().Get("Content-Encoding"), small.Body)
+	}
+	req := httptest.NewRequest("GET", "/tasks", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("If-None-Match", plain.Header().Get("ETag"))
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 || rec.Header().Get("Content-Encoding") != "" {
+		t.Errorf("304: status %d, %d bytes, Content-Encoding %q", rec.Code, rec.Body.Len(), rec.Header().Get("Content-Encoding"))
+	}
+
+	// If-Match takes the compressed form of a task's ETag too
+	etag := get("/tasks/1", "").Header().Get("ETag")
+	put := httptest.NewRequest("PUT", "/tasks/1", strings.NewReader(`{"title":"Renamed","status":"todo"}`))
+	put.Header.Set("If-Match", strings.TrimSuffix(etag, `"`)+`-gzip"`)
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, put)
+	if rec.Code != http.StatusOK {
+		t.Errorf("PUT with the gzip ETag: status %d, body %s", rec.Code, rec.Body)
+	}
+
+	// Unlisted content types are not compressed
+	cfg.Compression.ContentTypes = []string{"text/csv"}
+	srv = NewServer(cfg, handlers.NewTaskHandler(repo))
+	if rec := get("/tasks", "gzip"); rec.Header().Get("Content-Encoding") != "" {
+		t.Errorf("application/json compressed with content_types %v", cfg.Compression.ContentTypes)
+	}
+	if rec := get("/tasks/export", "gzip"); rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("CSV export not compressed: headers %v", rec.Header())
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/light-bringer/cert-tasks/internal/capture"
+	"github.com/light-bringer/cert-tasks/internal/handlers"
+	"github.com/light-bringer/cert-tasks/internal/models"
+)
+
+// examplesFile holds examples captured from real traffic with
+// CAPTURE_EXAMPLES_FILE; refresh it by running a capture session
+const examplesFile = "../../docs/openapi/examples.json"
+
+// contracts maps an operation to the types its request and successful
+// response bodies must decode into
+var contracts = map[string]struct {
+	request  func() any
+	response func() any
+}{
+	"POST /tasks":            {func() any { return &models.CreateTaskRequest{} }, func() any { return &models.Task{} }},
+	"GET /tasks":             {nil, func() any { return &[]models.Task{} }},
+	"GET /tasks/{id}":        {nil, func() any { return &models.Task{} }},
+	"PUT /tasks/{id}":        {func() any { return &models.UpdateTaskRequest{} }, func() any { return &models.Task{} }},
+	"DELETE /tasks/{id}":     {nil, nil},
+	"POST /tasks/{id}/links": {func() any { return &models.CreateLinkRequest{} }, func() any { return &models.Task{} }},
+}
+
+// TestCapturedExamples_MatchContract fails when a documented example no
+// longer decodes strictly into the current request, response or error types
+func TestCapturedExamples_MatchContract(t *testing.T) {
+	f, err := os.Open(examplesFile)
+	if err != nil {
+		t.Fatalf("opening captured examples: %v", err)
+	}
+	defer f.Close()
+
+	doc, err := capture.LoadDocument(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	examples := doc.Examples()
+	if len(examples) == 0 {
+		t.Fatal("no captured examples")
+	}
+
+	for _, ex := range examples {
+		op := ex.Method + " " + ex.Route
+		t.Run(fmt.Sprintf("%s %d", op, ex.Status), func(t *testing.T) {
+			contract, ok := contracts[op]
+			if !ok {
+				t.Fatalf("no contract for %s; add it to contracts", op)
+			}
+
+			if ex.Request != nil && contract.request != nil {
+				strictDecode(t, "request", ex.Request, contract.request())
+			}
+
+			switch {
+			case ex.Response == nil:
+			case ex.Status >= http.StatusBadRequest:
+				strictDecode(t, "error response", ex.Response, &handlers.ErrorResponse{})
+			case contract.response == nil:
+				t.Errorf("%d response has a body, but the contract has none", ex.Status)
+			default:
+				strictDecode(t, "response", ex.Response, contract.response())
+			}
+		})
+	}
+}
+
+func strictDecode(t *testing.T, what string, data json.RawMessage, v any) {
+	t.Helper()
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		t.Errorf("%s example does not match %T: %v\n%s", what, v, err, data)
+	}
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/light-bringer/cert-tasks/internal/config"
+	"github.com/light-bringer/cert-tasks/internal/handlers"
+	"github.com/light-bringer/cert-tasks/internal/requestid"
+)
+
+// cors allows browser requests from the configured origins and answers
+// their preflight requests with the configured methods the route has.
+// Requests from other origins pass through without CORS headers, so the
+// browser blocks the response.
+func cors(cfg config.CORS, routes chi.Routes) func(http.Handler) http.Handler {
+	anyOrigin := slices.Contains(cfg.AllowedOrigins, "*")
+	headers := strings.Join(cfg.AllowedHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge.Seconds()))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Origin")
+			if !anyOrigin && !slices.Contains(cfg.AllowedOrigins, origin) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if anyOrigin {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+			w.Header().Set("Access-Control-Expose-Headers", requestid.Header+", ETag, Location, "+handlers.UndoTokenHeader)
+
+			// Preflight: answer directly, the route itself never sees it
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Set("Access-Control-Allow-Methods", preflightMethods(cfg, routes, r.URL.Path))
+				w.Header().Set("Access-Control-Allow-Headers", headers)
+				w.Header().Set("Access-Control-Max-Age", maxAge)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// preflightMethods returns the configured methods that routes answers at
+// path, or every configured method for a path with no route
+func preflightMethods(cfg config.CORS, routes chi.Routes, path string) string {
+	allowed := allowedMethods(routes, path)
+	if len(allowed) == 0 {
+		return strings.Join(cfg.AllowedMethods, ", ")
+	}
+	var methods []string
+	for _, method := range cfg.AllowedMethods {
+		if slices.Contains(allowed, strings.ToUpper(method)) {
+			methods = append(methods, method)
+		}
+	}
+	return strings.Join(methods, ", ")
+}
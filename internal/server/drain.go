@@ -0,0 +1,48 @@
+package server
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/light-bringer/cert-tasks/internal/handlers"
+)
+
+// drainRetryAfter is suggested to clients turned away during shutdown; by
+// then the load balancer should route them to another instance
+const drainRetryAfter = "1"
+
+// drain counts in-flight requests and turns new ones away once shutdown
+// has started, so a rolling deploy finishes the requests it has instead of
+// accepting more
+type drain struct {
+	inFlight atomic.Int64
+	draining atomic.Bool
+}
+
+// start marks the server as draining and returns the number of requests
+// still in flight
+func (d *drain) start() int64 {
+	d.draining.Store(true)
+	return d.inFlight.Load()
+}
+
+// middleware tracks requests and answers 503 with Connection: close while
+// draining
+//
+//api:changelog 0.2.0 added error shutting_down: Requests arriving while the server drains return 503 with Connection: close
+func (d *drain) middleware(handler *handlers.TaskHandler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if d.draining.Load() {
+				w.Header().Set("Connection", "close")
+				w.Header().Set("Retry-After", drainRetryAfter)
+				handler.Error(w, r, http.StatusServiceUnavailable, handlers.CodeShuttingDown, "server is shutting down; retry the request")
+				return
+			}
+
+			d.inFlight.Add(1)
+			defer d.inFlight.Add(-1)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
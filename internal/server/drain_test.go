@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/config"
+	"github.com/light-bringer/cert-tasks/internal/handlers"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+func TestDrain_Middleware(t *testing.T) {
+	var d drain
+	release := make(chan struct{})
+	started := make(chan struct{})
+	h := d.middleware(handlers.NewTaskHandler(repository.NewMemoryRepository()))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	inFlight := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		h.ServeHTTP(inFlight, httptest.NewRequest("GET", "/tasks", nil))
+		close(done)
+	}()
+	<-started
+
+	if n := d.start(); n != 1 {
+		t.Errorf("start() = %d in flight, want 1", n)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/tasks", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %v, want %v", rec.Code, http.StatusServiceUnavailable)
+	}
+	if got := rec.Header().Get("Connection"); got != "close" {
+		t.Errorf("Connection = %q, want close", got)
+	}
+	var errResp handlers.ErrorResponse
+	json.NewDecoder(rec.Body).Decode(&errResp)
+	if errResp.Code != handlers.CodeShuttingDown {
+		t.Errorf("code = %q, want %q", errResp.Code, handlers.CodeShuttingDown)
+	}
+
+	// The request admitted before draining still completes
+	close(release)
+	<-done
+	if inFlight.Code != http.StatusOK {
+		t.Errorf("in-flight status = %v, want %v", inFlight.Code, http.StatusOK)
+	}
+	if n := d.inFlight.Load(); n != 0 {
+		t.Errorf("in flight after completion = %d, want 0", n)
+	}
+}
+
+func TestServer_RunWaitsForInFlight(t *testing.T) {
+	cfg := config.Default(false).Server
+	cfg.Addr = freeAddr(t)
+	cfg.ShutdownTimeout = 5 * time.Second
+	srv := NewServer(cfg, handlers.NewTaskHandler(repository.NewMemoryRepository()))
+
+	release := make(chan struct{})
+	srv.router.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+
+	// Without keep-alives the client leaves no spare connection open, which
+	// Shutdown would wait five seconds for before counting it as idle
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Run(ctx) }()
+	waitFor(t, client, "http://"+cfg.Addr+"/tasks").Body.Close()
+
+	slow := make(chan int, 1)
+	go func() {
+		resp, err := client.Get("http://" + cfg.Addr + "/slow")
+		if err != nil {
+			slow <- 0
+			return
+		}
+		resp.Body.Close()
+		slow <- resp.StatusCode
+	}()
+	for srv.drain.inFlight.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		t.Fatalf("Run() returned %v before the in-flight request finished", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if status := <-slow; status != http.StatusOK {
+		t.Errorf("in-flight status = %v, want %v", status, http.StatusOK)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Run() = %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/light-bringer/cert-tasks/internal/erasure"
+	"github.com/light-bringer/cert-tasks/internal/handlers"
+	"github.com/light-bringer/cert-tasks/internal/logging"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+	"github.com/light-bringer/cert-tasks/internal/requestid"
+)
+
+// erasureRoute erases a user's data and answers with the signed report.
+// ?mode=anonymize keeps their tasks without owner and description instead
+// of deleting them. The user ID is not logged, as it is what is erased.
+//
+//api:changelog 0.2.0 added endpoint DELETE /users/{id}/data: Erase a user's tasks, webhooks and audit entries, deleting or, with ?mode=anonymize, anonymizing their tasks, and return a signed erasure report
+func erasureRoute(r chi.Router, handler *handlers.TaskHandler, eraser *erasure.Eraser) {
+	r.Delete("/users/{id}/data", func(w http.ResponseWriter, r *http.Request) {
+		mode := r.URL.Query().Get("mode")
+		if mode == "" {
+			mode = erasure.ModeDelete
+		}
+		// Subjects such as "auth0|1234" arrive escaped
+		userID, err := url.PathUnescape(chi.URLParam(r, "id"))
+		if err != nil {
+			handler.Error(w, r, http.StatusBadRequest, handlers.CodeInvalidID, "user ID is not a valid path segment")
+			return
+		}
+		signed, err := eraser.Erase(r.Context(), userID, mode, requestid.FromRequest(r))
+		switch {
+		case errors.Is(err, erasure.ErrInvalidMode):
+			handler.Error(w, r, http.StatusBadRequest, handlers.CodeInvalidQuery, err.Error())
+			return
+		case errors.Is(err, repository.ErrNotSupported):
+			handler.Error(w, r, http.StatusNotImplemented, handlers.CodeNotImplemented, "storage backend cannot anonymize tasks; erase with mode=delete")
+			return
+		case err != nil:
+			logging.FromContext(r.Context()).Error("erasing user data", slog.Any("error", err))
+			handler.Error(w, r, http.StatusInternalServerError, handlers.CodeInternal, "failed to erase user data; erasing again finishes the job")
+			return
+		}
+
+		var report erasure.Report
+		json.Unmarshal(signed.Report, &report)
+		logging.FromContext(r.Context()).Info("user data erased",
+			slog.String("mode", report.Mode),
+			slog.Int("tasks_deleted", len(report.TasksDeleted)),
+			slog.Int("tasks_anonymized", len(report.TasksAnonymized)),
+			slog.Int("webhooks_deleted", len(report.WebhooksDeleted)),
+			slog.Int("audit_entries_anonymized", report.AuditEntriesAnonymized),
+		)
+		// The report must reach the client byte for byte as it was signed
+		w.WriteHeader(http.StatusOK)
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		enc.Encode(signed)
+	})
+}
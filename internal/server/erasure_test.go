@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/light-bringer/cert-tasks/internal/config"
+	"github.com/light-bringer/cert-tasks/internal/erasure"
+	"github.com/light-bringer/cert-tasks/internal/handlers"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+func TestServer_Erasure(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	repo.Create(repository.WithOwner(ctx, "alice&co"), &models.Task{Title: "Call back", Description: "Jane, 555-0100"})
+	repo.Create(repository.WithOwner(ctx, "bob"), &models.Task{Title: "Bob's"})
+
+	secret := []byte(strings.Repeat("s", 32))
+	srv := NewServer(config.Default(false).Server, handlers.NewTaskHandler(repo),
+		WithErasure(erasure.New(repo, secret)), WithAdminKey(testAdminKey))
+	erase := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.router.ServeHTTP(rec, adminRequest("DELETE", target, nil))
+		return rec
+	}
+
+	// The report is signed as sent, characters HTML-escaped by default
+	// included
+	rec := erase("/users/alice%26co/data")
+	var signed erasure.Signed
+	json.NewDecoder(rec.Body).Decode(&signed)
+	if rec.Code != http.StatusOK || !erasure.Verify(signed, secret) {
+		t.Fatalf("erase = %v %+v, want a signed report", rec.Code, signed)
+	}
+	var report erasure.Report
+	json.Unmarshal(signed.Report, &report)
+	if report.UserID != "alice&co" || report.Mode != erasure.ModeDelete || len(report.TasksDeleted) != 1 {
+		t.Errorf("report = %+v, want alice's task deleted", report)
+	}
+	if tasks, _ := repo.GetAll(ctx); len(tasks) != 1 || tasks[0].OwnerID != "bob" {
+		t.Errorf("tasks left = %+v, want only bob's", tasks)
+	}
+
+	if rec := erase("/users/bob/data?mode=shred"); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid mode status = %v, want %v", rec.Code, http.StatusBadRequest)
+	}
+	if rec := erase("/users/bob/data?mode=anonymize"); rec.Code != http.StatusNotImplemented {
+		t.Errorf("anonymize without an anonymizer status = %v, want %v", rec.Code, http.StatusNotImplemented)
+	}
+}
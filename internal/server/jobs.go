@@ -0,0 +1,65 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/light-bringer/cert-tasks/internal/handlers"
+	"github.com/light-bringer/cert-tasks/internal/jobs"
+	"github.com/light-bringer/cert-tasks/internal/scheduler"
+)
+
+// jobRoutes serves the background jobs admin API
+//
+//api:changelog 0.2.0 added endpoint GET /admin/jobs: Background job status, including runs stuck without a heartbeat
+//api:changelog 0.2.0 added endpoint POST /admin/jobs/{name}/abort: Cancel the current run of a background job
+//api:changelog 0.2.0 added endpoint POST /admin/jobs/{name}/requeue: Run a background job again, aborting it first if stuck
+func jobRoutes(r chi.Router, handler *handlers.TaskHandler, sched *scheduler.Scheduler) {
+	r.Get("/admin/jobs", func(w http.ResponseWriter, r *http.Request) {
+		statuses := sched.Status()
+		w.Header().Set(handlers.TotalCountHeader, strconv.Itoa(len(statuses)))
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(statuses)
+	})
+
+	jobAction := func(action func(string) (scheduler.Status, error)) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			status, err := action(chi.URLParam(r, "name"))
+			switch {
+			case errors.Is(err, scheduler.ErrUnknownJob):
+				handler.Error(w, r, http.StatusNotFound, handlers.CodeNotFound, "job not found")
+				return
+			case errors.Is(err, scheduler.ErrNotRunning):
+				handler.Error(w, r, http.StatusConflict, handlers.CodeConflict, "job is not running")
+				return
+			}
+
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(status)
+		}
+	}
+	r.Post("/admin/jobs/{name}/abort", jobAction(sched.Abort))
+	r.Post("/admin/jobs/{name}/requeue", jobAction(sched.Requeue))
+}
+
+// queueRoute serves the job queue's status. ?state= lists only the jobs in
+// one state; the counts always cover every job.
+//
+//api:changelog 0.2.0 added endpoint GET /admin/jobs/queue: Job queue status: counts by state and the retained job records, filtered with ?state=
+func queueRoute(r chi.Router, handler *handlers.TaskHandler, queue *jobs.Queue) {
+	r.Get("/admin/jobs/queue", func(w http.ResponseWriter, r *http.Request) {
+		state := jobs.State(r.URL.Query().Get("state"))
+		switch state {
+		case "", jobs.StatePending, jobs.StateRunning, jobs.StateSucceeded, jobs.StateFailed:
+		default:
+			handler.Error(w, r, http.StatusBadRequest, handlers.CodeInvalidQuery, "state must be pending, running, succeeded or failed")
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(queue.Status(state))
+	})
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/config"
+	"github.com/light-bringer/cert-tasks/internal/handlers"
+	"github.com/light-bringer/cert-tasks/internal/jobs"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+	"github.com/light-bringer/cert-tasks/internal/scheduler"
+)
+
+func TestServer_Jobs(t *testing.T) {
+	sched := scheduler.New()
+	sched.Add(scheduler.Job{
+		Name:       "noop",
+		Interval:   time.Hour,
+		StuckAfter: time.Minute,
+		Run:        func(ctx context.Context, beat func()) error { return nil },
+	})
+	srv := NewServer(config.Default(false).Server, handlers.NewTaskHandler(repository.NewMemoryRepository()), WithScheduler(sched), WithAdminKey(testAdminKey))
+
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, adminRequest("GET", "/admin/jobs", nil))
+	var statuses []scheduler.Status
+	if err := json.NewDecoder(rec.Body).Decode(&statuses); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || len(statuses) != 1 || statuses[0].Name != "noop" || rec.Header().Get(handlers.TotalCountHeader) != "1" {
+		t.Fatalf("GET /admin/jobs = %v %+v, %s %q", rec.Code, statuses, handlers.TotalCountHeader, rec.Header().Get(handlers.TotalCountHeader))
+	}
+
+	tests := []struct {
+		path       string
+		wantStatus int
+	}{
+		{"/admin/jobs/noop/requeue", http.StatusAccepted},
+		{"/admin/jobs/noop/abort", http.StatusConflict},
+		{"/admin/jobs/missing/abort", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		srv.router.ServeHTTP(rec, adminRequest("POST", tt.path, nil))
+		if rec.Code != tt.wantStatus {
+			t.Errorf("POST %s status = %v, want %v", tt.path, rec.Code, tt.wantStatus)
+		}
+	}
+}
+
+func TestServer_JobQueue(t *testing.T) {
+	queue := jobs.New(jobs.DefaultConfig())
+	queue.Register("import", func(ctx context.Context, job jobs.Job) error { return nil })
+	queue.Enqueue("import", nil)
+	srv := NewServer(config.Default(false).Server, handlers.NewTaskHandler(repository.NewMemoryRepository()), WithJobQueue(queue), WithAdminKey(testAdminKey))
+
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, adminRequest("GET", "/admin/jobs/queue?state=pending", nil))
+	var status jobs.Status
+	json.NewDecoder(rec.Body).Decode(&status)
+	if rec.Code != http.StatusOK || status.Counts[jobs.StatePending] != 1 || len(status.Jobs) != 1 || status.Jobs[0].Kind != "import" {
+		t.Fatalf("GET /admin/jobs/queue = %v %+v", rec.Code, status)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, adminRequest("GET", "/admin/jobs/queue?state=done", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown state status = %v, want %v", rec.Code, http.StatusBadRequest)
+	}
+}
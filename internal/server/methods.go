@@ -0,0 +1,121 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// routeMethods lists the methods probed when building an Allow header
+var routeMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodOptions,
+}
+
+// allowedMethods returns the methods routes answers at path. HEAD is
+// allowed wherever GET is, and OPTIONS wherever any method is.
+func allowedMethods(routes chi.Routes, path string) []string {
+	var allowed []string
+	for _, method := range routeMethods {
+		ok := routes.Match(chi.NewRouteContext(), method, path)
+		switch method {
+		case http.MethodHead:
+			ok = ok || slices.Contains(allowed, http.MethodGet)
+		case http.MethodOptions:
+			ok = ok || len(allowed) > 0
+		}
+		if ok {
+			allowed = append(allowed, method)
+		}
+	}
+	return allowed
+}
+
+// methods answers OPTIONS requests with the route's Allow header and
+// serves HEAD requests with the route's GET handler, sending its status
+// and headers without the body. CORS preflights are answered earlier, by
+// cors.
+//
+//api:changelog 0.2.0 added header Allow: OPTIONS answers 204 with the methods a route allows, and HEAD is served wherever GET is
+func methods(routes chi.Routes) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodOptions:
+				allowed := allowedMethods(routes, r.URL.Path)
+				if len(allowed) == 0 {
+					break // unknown route: 404 as usual
+				}
+				w.Header().Set("Allow", strings.Join(allowed, ", "))
+				w.WriteHeader(http.StatusNoContent)
+				return
+
+			case http.MethodHead:
+				if routes.Match(chi.NewRouteContext(), http.MethodHead, r.URL.Path) {
+					break
+				}
+				// The GET handler sees a GET, so it answers exactly as it
+				// would one. Not deferred, like compress: after a panic
+				// the recoverer must still be able to write its 500.
+				get := *r
+				get.Method = http.MethodGet
+				hw := &headWriter{ResponseWriter: w}
+				next.ServeHTTP(hw, &get)
+				hw.close()
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// headWriter discards the body of a HEAD response, counting it so that
+// Content-Length matches the GET response. The header is held back until
+// the handler returns.
+type headWriter struct {
+	http.ResponseWriter
+	status int
+	length int
+}
+
+func (w *headWriter) WriteHeader(status int) {
+	if status < http.StatusOK {
+		w.ResponseWriter.WriteHeader(status) // informational, such as 103
+		return
+	}
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *headWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.length += len(p)
+	return len(p), nil
+}
+
+// Flush does nothing: the header goes out once the handler is done, with
+// the full length
+func (w *headWriter) Flush() {}
+
+// close sends the held-back header
+func (w *headWriter) close() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	h := w.Header()
+	if h.Get("Content-Length") == "" && w.length > 0 {
+		h.Set("Content-Length", strconv.Itoa(w.length))
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
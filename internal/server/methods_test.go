@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/light-bringer/cert-tasks/internal/config"
+	"github.com/light-bringer/cert-tasks/internal/handlers"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+func TestServer_Head(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	repo.Create(context.Background(), &models.Task{Title: "Renew cert"})
+	srv := NewServer(config.Default(false).Server, handlers.NewTaskHandler(repo))
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec
+	}
+
+	for _, path := range []string{"/tasks", "/tasks/1", "/version", "/tasks/99"} {
+		get, head := serve("GET", path), serve("HEAD", path)
+		if head.Code != get.Code || head.Body.Len() != 0 {
+			t.Errorf("HEAD %s: status %d with %d bytes, want %d without a body", path, head.Code, head.Body.Len(), get.Code)
+		}
+		for _, name := range []string{"Content-Type", "ETag", "Last-Modified"} {
+			if head.Header().Get(name) != get.Header().Get(name) {
+				t.Errorf("HEAD %s: %s = %q, GET sent %q", path, name, head.Header().Get(name), get.Header().Get(name))
+			}
+		}
+		if want := get.Body.Len(); head.Header().Get("Content-Length") != strconv.Itoa(want) {
+			t.Errorf("HEAD %s: Content-Length = %q, want %d", path, head.Header().Get("Content-Length"), want)
+		}
+	}
+
+	// Routes without GET still refuse HEAD
+	if rec := serve("HEAD", "/tasks/1/links"); rec.Code != http.StatusMethodNotAllowed || rec.Body.Len() != 0 {
+		t.Errorf("HEAD /tasks/1/links: status %d with %d bytes", rec.Code, rec.Body.Len())
+	}
+}
+
+func TestServer_Options(t *testing.T) {
+	srv := NewServer(config.Default(false).Server, handlers.NewTaskHandler(repository.NewMemoryRepository()))
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantAllow  string
+	}{
+		{"/tasks", http.StatusNoContent, "GET, HEAD, POST, DELETE, OPTIONS"},
+		{"/tasks/1", http.StatusNoContent, "GET, HEAD, PUT, DELETE, OPTIONS"},
+		{"/tasks/1/links", http.StatusNoContent, "POST, OPTIONS"},
+		{"/nope", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, httptest.NewRequest("OPTIONS", tt.path, nil))
+		if rec.Code != tt.wantStatus || rec.Header().Get("Allow") != tt.wantAllow {
+			t.Errorf("OPTIONS %s: status %d, Allow %q; want %d, %q", tt.path, rec.Code, rec.Header().Get("Allow"), tt.wantStatus, tt.wantAllow)
+		}
+	}
+}
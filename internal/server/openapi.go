@@ -0,0 +1,177 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/light-bringer/cert-tasks/internal/audit"
+	"github.com/light-bringer/cert-tasks/internal/backup"
+	"github.com/light-bringer/cert-tasks/internal/bodylog"
+	"github.com/light-bringer/cert-tasks/internal/changelog"
+	"github.com/light-bringer/cert-tasks/internal/cleanup"
+	"github.com/light-bringer/cert-tasks/internal/diagnostics"
+	"github.com/light-bringer/cert-tasks/internal/erasure"
+	"github.com/light-bringer/cert-tasks/internal/handlers"
+	"github.com/light-bringer/cert-tasks/internal/health"
+	"github.com/light-bringer/cert-tasks/internal/jobs"
+	"github.com/light-bringer/cert-tasks/internal/latency"
+	"github.com/light-bringer/cert-tasks/internal/logging"
+	"github.com/light-bringer/cert-tasks/internal/maintenance"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/openapi"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+	"github.com/light-bringer/cert-tasks/internal/scheduler"
+	"github.com/light-bringer/cert-tasks/internal/schemas"
+	"github.com/light-bringer/cert-tasks/internal/seed"
+	"github.com/light-bringer/cert-tasks/internal/version"
+)
+
+// idParam types the numeric {id} of tasks, webhooks and API keys
+var idParam = map[string]any{"id": int64(0)}
+
+// apiOperations declares the bodies and security of every endpoint in the
+// changelog. Tests check it against the router, so a route cannot be added
+// without documenting it here.
+func apiOperations() []openapi.Operation {
+	ok := func(body any) []openapi.Response {
+		return []openapi.Response{{Status: http.StatusOK, Body: body}}
+	}
+	created := func(body any) []openapi.Response {
+		return []openapi.Response{{Status: http.StatusCreated, Body: body}}
+	}
+	noContent := []openapi.Response{{Status: http.StatusNoContent}}
+	// cached adds the 304 that GET answers to a current validator
+	cached := func(responses []openapi.Response) []openapi.Response {
+		return append(responses, openapi.Response{Status: http.StatusNotModified, Description: "Unchanged since the If-None-Match or If-Modified-Since validator"})
+	}
+	// conditional adds the 412 that writes with a stale If-Match get
+	conditional := func(responses []openapi.Response) []openapi.Response {
+		return append(responses, openapi.Response{Status: http.StatusPreconditionFailed, Description: "Task changed since the If-Match ETag was read"})
+	}
+	document := func(contentType string, body any) []openapi.Response {
+		return []openapi.Response{{Status: http.StatusOK, Body: body, ContentType: contentType}}
+	}
+
+	return []openapi.Operation{
+		// Tasks
+		{Method: http.MethodPost, Path: "/tasks", Tag: "tasks", Request: models.CreateTaskRequest{}, Responses: created(models.Task{})},
+		{Method: http.MethodGet, Path: "/tasks", Tag: "tasks", Responses: cached(ok([]models.Task{}))},
+		{Method: http.MethodGet, Path: "/tasks/todo", Tag: "tasks", Responses: cached(ok([]models.Task{}))},
+		{Method: http.MethodGet, Path: "/tasks/done", Tag: "tasks", Responses: cached(ok([]models.Task{}))},
+		{Method: http.MethodGet, Path: "/tasks/status/{status}", Tag: "tasks", PathParams: map[string]any{"status": models.StatusTodo}, Responses: cached(ok([]models.Task{}))},
+		{Method: http.MethodGet, Path: "/tasks/export", Tag: "tasks", Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "CSV, or NDJSON with ?format=ndjson", Body: "", ContentType: "text/csv"},
+			{Status: http.StatusAccepted, Description: "Export job started with ?async=true", Body: models.TaskJob{}},
+		}},
+		{Method: http.MethodPost, Path: "/tasks/import", Tag: "tasks", Request: "", RequestContentType: "text/csv", Responses: []openapi.Response{
+			{Status: http.StatusOK, Body: models.ImportResult{}},
+			{Status: http.StatusAccepted, Description: "Import job started with ?async=true", Body: models.TaskJob{}},
+		}},
+		{Method: http.MethodGet, Path: "/tasks/{id}", Tag: "tasks", PathParams: idParam, Responses: cached(ok(models.Task{}))},
+		{Method: http.MethodPut, Path: "/tasks/{id}", Tag: "tasks", PathParams: idParam, Request: models.UpdateTaskRequest{}, Responses: conditional(ok(models.Task{}))},
+		{Method: http.MethodDelete, Path: "/tasks", Tag: "tasks", Responses: ok(openapi.OneOf{models.BulkDeletePreview{}, models.BulkDeleteResult{}})},
+		{Method: http.MethodDelete, Path: "/tasks/{id}", Tag: "tasks", PathParams: idParam, Responses: conditional(noContent)},
+		{Method: http.MethodPost, Path: "/tasks/{id}/complete", Tag: "tasks", PathParams: idParam, Responses: conditional(ok(models.Task{}))},
+		{Method: http.MethodPost, Path: "/tasks/{id}/reopen", Tag: "tasks", PathParams: idParam, Responses: conditional(ok(models.Task{}))},
+		{Method: http.MethodPost, Path: "/tasks/{id}/links", Tag: "tasks", PathParams: idParam, Request: models.CreateLinkRequest{}, Responses: created(models.Task{})},
+		{Method: http.MethodGet, Path: "/tasks/{id}/revisions", Tag: "tasks", PathParams: idParam, Responses: ok([]models.TaskRevision{})},
+		{Method: http.MethodPost, Path: "/tasks/{id}/revisions/{n}/restore", Tag: "tasks", PathParams: map[string]any{"id": int64(0), "n": 0}, Responses: conditional(ok(models.Task{}))},
+		{Method: http.MethodPost, Path: "/undo/{token}", Tag: "tasks", PathParams: map[string]any{"token": ""}, Responses: ok(models.UndoResult{})},
+		{Method: http.MethodGet, Path: "/jobs/{id}", Tag: "tasks", PathParams: idParam, Responses: ok(models.TaskJob{})},
+		{Method: http.MethodGet, Path: "/jobs/{id}/download", Tag: "tasks", PathParams: idParam, Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "The exported CSV or NDJSON file", Body: "", ContentType: "text/csv"},
+		}},
+		{Method: http.MethodGet, Path: "/blobs/{name}", Tag: "tasks", Public: true, PathParams: map[string]any{"name": ""}, Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "The linked file", Body: "", ContentType: "application/octet-stream"},
+		}},
+
+		// Webhooks
+		{Method: http.MethodPost, Path: "/webhooks", Tag: "webhooks", Request: models.CreateWebhookRequest{}, Responses: created(models.CreatedWebhook{})},
+		{Method: http.MethodGet, Path: "/webhooks", Tag: "webhooks", Responses: ok([]models.Webhook{})},
+		{Method: http.MethodDelete, Path: "/webhooks/{id}", Tag: "webhooks", PathParams: idParam, Responses: noContent},
+		{Method: http.MethodGet, Path: "/webhooks/{id}/deliveries", Tag: "webhooks", PathParams: idParam, Responses: ok([]models.WebhookDelivery{})},
+
+		// Reports
+		{Method: http.MethodGet, Path: "/reports/burndown", Tag: "reports", Responses: ok(models.BurndownReport{})},
+		{Method: http.MethodGet, Path: "/reports/throughput", Tag: "reports", Responses: ok(models.ThroughputReport{})},
+		{Method: http.MethodGet, Path: "/reports/workload", Tag: "reports", Responses: ok(models.WorkloadReport{})},
+
+		// Calendar and realtime
+		{Method: http.MethodGet, Path: "/calendar/token", Tag: "calendar", Responses: ok(models.CalendarFeed{})},
+		{Method: http.MethodGet, Path: "/calendar.ics", Tag: "calendar", Public: true, Responses: document("text/calendar", "")},
+		{Method: http.MethodGet, Path: "/ws", Tag: "realtime", Responses: []openapi.Response{
+			{Status: http.StatusSwitchingProtocols, Description: "WebSocket connection"},
+		}},
+
+		// Administration
+		{Method: http.MethodPost, Path: "/apikeys", Tag: "admin", Admin: true, Request: models.CreateAPIKeyRequest{}, Responses: created(models.CreatedAPIKey{})},
+		{Method: http.MethodPost, Path: "/workspaces", Tag: "admin", Admin: true, Request: models.CreateWorkspaceRequest{}, Responses: created(models.Workspace{})},
+		{Method: http.MethodGet, Path: "/workspaces", Tag: "admin", Admin: true, Responses: ok([]models.Workspace{})},
+		{Method: http.MethodGet, Path: "/workspaces/{id}", Tag: "admin", Admin: true, Responses: ok(models.Workspace{})},
+		{Method: http.MethodPut, Path: "/workspaces/{id}", Tag: "admin", Admin: true, Request: models.UpdateWorkspaceRequest{}, Responses: ok(models.Workspace{})},
+		{Method: http.MethodDelete, Path: "/workspaces/{id}", Tag: "admin", Admin: true, Responses: noContent},
+		{Method: http.MethodGet, Path: "/audit", Tag: "admin", Admin: true, Responses: ok([]audit.Entry{})},
+		{Method: http.MethodGet, Path: "/admin/slow-report", Tag: "admin", Admin: true, Responses: ok(latency.Report{})},
+		{Method: http.MethodGet, Path: "/admin/jobs", Tag: "admin", Admin: true, Responses: ok([]scheduler.Status{})},
+		{Method: http.MethodPost, Path: "/admin/jobs/{name}/abort", Tag: "admin", Admin: true, Responses: []openapi.Response{
+			{Status: http.StatusAccepted, Body: scheduler.Status{}},
+		}},
+		{Method: http.MethodPost, Path: "/admin/jobs/{name}/requeue", Tag: "admin", Admin: true, Responses: []openapi.Response{
+			{Status: http.StatusAccepted, Body: scheduler.Status{}},
+		}},
+		{Method: http.MethodGet, Path: "/admin/jobs/queue", Tag: "admin", Admin: true, Responses: ok(jobs.Status{})},
+		{Method: http.MethodGet, Path: "/admin/apikeys", Tag: "admin", Admin: true, Responses: ok([]models.APIKey{})},
+		{Method: http.MethodPost, Path: "/admin/apikeys/{id}/rotate", Tag: "admin", Admin: true, PathParams: idParam, Responses: ok(models.CreatedAPIKey{})},
+		{Method: http.MethodGet, Path: "/admin/stats", Tag: "admin", Admin: true, Responses: ok(repository.Stats{})},
+		{Method: http.MethodPost, Path: "/admin/cleanup", Tag: "admin", Admin: true, Responses: ok(cleanup.Result{})},
+		{Method: http.MethodDelete, Path: "/users/{id}/data", Tag: "admin", Admin: true, Responses: ok(erasure.Signed{})},
+		{Method: http.MethodGet, Path: "/users/{id}/export", Tag: "admin", Admin: true, Responses: []openapi.Response{
+			{Status: http.StatusAccepted, Description: "User export job started", Body: models.TaskJob{}},
+		}},
+		{Method: http.MethodGet, Path: "/users/{id}/exports/{job}", Tag: "admin", Admin: true, PathParams: map[string]any{"job": int64(0)}, Responses: ok(models.TaskJob{})},
+		{Method: http.MethodGet, Path: "/users/{id}/exports/{job}/download", Tag: "admin", Admin: true, PathParams: map[string]any{"job": int64(0)}, Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "The zip of the user's data", Body: "", ContentType: "application/zip"},
+		}},
+		{Method: http.MethodPost, Path: "/admin/backup", Tag: "admin", Admin: true, Responses: created(backup.Info{})},
+		{Method: http.MethodPost, Path: "/admin/restore", Tag: "admin", Admin: true, Request: backup.Request{}, Responses: ok(backup.Result{})},
+		{Method: http.MethodPost, Path: "/admin/compact", Tag: "admin", Admin: true, Responses: ok(repository.CompactResult{})},
+		{Method: http.MethodGet, Path: "/admin/maintenance", Tag: "admin", Admin: true, Responses: ok(maintenance.State{})},
+		{Method: http.MethodPut, Path: "/admin/maintenance", Tag: "admin", Admin: true, Request: maintenance.Request{}, Responses: ok(maintenance.State{})},
+		{Method: http.MethodGet, Path: "/admin/debug/bodies", Tag: "admin", Admin: true, Responses: ok(bodylog.State{})},
+		{Method: http.MethodPut, Path: "/admin/debug/bodies", Tag: "admin", Admin: true, Request: bodylog.Request{}, Responses: ok(bodylog.State{})},
+		{Method: http.MethodGet, Path: "/admin/loglevel", Tag: "admin", Admin: true, Responses: ok(logging.LevelState{})},
+		{Method: http.MethodPut, Path: "/admin/loglevel", Tag: "admin", Admin: true, Request: logging.LevelState{}, Responses: ok(logging.LevelState{})},
+		{Method: http.MethodGet, Path: "/admin/diagnostics", Tag: "admin", Admin: true, Responses: ok(diagnostics.Report{})},
+		{Method: http.MethodPost, Path: "/admin/seed", Tag: "admin", Admin: true, Request: seed.Fixtures{}, Responses: ok(seed.Result{})},
+
+		// Service documents
+		{Method: http.MethodGet, Path: "/health", Tag: "meta", Public: true, Responses: []openapi.Response{
+			{Status: http.StatusOK, Body: health.Report{}},
+			{Status: http.StatusServiceUnavailable, Description: "A critical dependency is down", Body: health.Report{}},
+		}},
+		{Method: http.MethodGet, Path: "/version", Tag: "meta", Public: true, Responses: ok(version.Info{})},
+		{Method: http.MethodGet, Path: "/changelog.json", Tag: "meta", Public: true, Responses: ok(changelog.Changelog{})},
+		{Method: http.MethodGet, Path: "/schemas/{name}", Tag: "meta", Public: true, Responses: ok(map[string]any{})},
+		{Method: http.MethodGet, Path: "/openapi.json", Tag: "meta", Public: true, Responses: ok(map[string]any{})},
+		{Method: http.MethodGet, Path: "/docs", Tag: "meta", Public: true, Responses: document("text/html", "")},
+	}
+}
+
+// openAPIDocument builds the OpenAPI document of the running version
+func openAPIDocument() ([]byte, error) {
+	var cl changelog.Changelog
+	if err := json.Unmarshal(changelog.JSON(), &cl); err != nil {
+		return nil, err
+	}
+	doc, err := openapi.Build(openapi.Spec{
+		Version:    version.Get().Version,
+		Changelog:  &cl,
+		Operations: apiOperations(),
+		Schemas:    schemas.FS,
+		ErrorBody:  handlers.ErrorResponse{},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return doc.JSON()
+}
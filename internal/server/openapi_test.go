@@ -0,0 +1,224 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/light-bringer/cert-tasks/internal/audit"
+	"github.com/light-bringer/cert-tasks/internal/auth"
+	"github.com/light-bringer/cert-tasks/internal/backup"
+	"github.com/light-bringer/cert-tasks/internal/cleanup"
+	"github.com/light-bringer/cert-tasks/internal/config"
+	"github.com/light-bringer/cert-tasks/internal/erasure"
+	"github.com/light-bringer/cert-tasks/internal/handlers"
+	"github.com/light-bringer/cert-tasks/internal/health"
+	"github.com/light-bringer/cert-tasks/internal/jobs"
+	"github.com/light-bringer/cert-tasks/internal/openapi"
+	"github.com/light-bringer/cert-tasks/internal/realtime"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+	"github.com/light-bringer/cert-tasks/internal/scheduler"
+	"github.com/light-bringer/cert-tasks/internal/seed"
+)
+
+// fetchOpenAPI returns the document served at /openapi.json
+func fetchOpenAPI(t *testing.T, srv *Server) openapi.Document {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest("GET", "/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json: status = %v, want %v", rec.Code, http.StatusOK)
+	}
+	var doc openapi.Document
+	if err := json.NewDecoder(rec.Body).Decode(&doc); err != nil {
+		t.Fatalf("decoding document: %v", err)
+	}
+	return doc
+}
+
+// TestServer_OpenAPIMatchesRouter fails when a route is not in the served
+// document or the document describes a route the server does not have
+func TestServer_OpenAPIMatchesRouter(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	hub := realtime.NewHub(realtime.DefaultConfig())
+	defer hub.Close()
+	srv := NewServer(config.Default(false).Server, handlers.NewTaskHandler(repo, handlers.WithAPIKeys(repo)),
+		WithAuth(auth.New(repo), strings.Repeat("a", 32)),
+		WithAudit(audit.New(10)),
+		WithHealth(health.NewRegistry()),
+		WithScheduler(scheduler.New()),
+		WithRealtime(hub),
+		WithDocs(),
+		WithSeed(seed.New(repo)),
+		WithStorage(repo),
+		WithCleanup(cleanup.New(repo, nil)),
+		WithErasure(erasure.New(repo, nil)),
+		WithUserExport(),
+		WithBackups(backup.New(repo, nil, "")),
+		WithJobQueue(jobs.New(jobs.DefaultConfig())),
+		WithLogLevel(new(slog.LevelVar)),
+	)
+	doc := fetchOpenAPI(t, srv)
+
+	var routed []string
+	err := chi.Walk(srv.router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		route = strings.TrimSuffix(route, "/")
+		if route == "/schemas/*" {
+			// The file server is mounted for every method; only GET is
+			// documented
+			if method != http.MethodGet {
+				return nil
+			}
+			route = "/schemas/{name}"
+		}
+		routed = append(routed, method+" "+route)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var documented []string
+	for path, item := range doc.Paths {
+		for method := range item {
+			documented = append(documented, strings.ToUpper(method)+" "+path)
+		}
+	}
+
+	for _, op := range routed {
+		if !slices.Contains(documented, op) {
+			t.Errorf("%s is routed but not in /openapi.json; declare it in apiOperations", op)
+		}
+	}
+	for _, op := range documented {
+		if !slices.Contains(routed, op) {
+			t.Errorf("%s is in /openapi.json but not routed", op)
+		}
+	}
+}
+
+// TestServer_OpenAPIResponses checks real responses against the schemas
+// the document declares for them
+func TestServer_OpenAPIResponses(t *testing.T) {
+	srv := NewServer(config.Default(false).Server, handlers.NewTaskHandler(repository.NewMemoryRepository()))
+	doc := fetchOpenAPI(t, srv)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.router.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+	do("POST", "/tasks", `{"title":"Write docs","due_at":"2026-01-02T15:04:05Z"}`)
+
+	tests := []struct {
+		method, path, route, body string
+		status                    int
+	}{
+		{"POST", "/tasks", "/tasks", `{"title":"Ship"}`, http.StatusCreated},
+		{"GET", "/tasks", "/tasks", "", http.StatusOK},
+		{"GET", "/tasks/1", "/tasks/{id}", "", http.StatusOK},
+		{"PUT", "/tasks/1", "/tasks/{id}", `{"title":"Write docs","status":"done"}`, http.StatusOK},
+		{"GET", "/tasks/99", "/tasks/{id}", "", http.StatusNotFound},
+		{"DELETE", "/tasks?status=done", "/tasks", "", http.StatusOK},
+		{"GET", "/version", "/version", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			rec := do(tt.method, tt.path, tt.body)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %v, want %v", rec.Code, tt.status)
+			}
+
+			op := doc.Paths[tt.route][strings.ToLower(tt.method)]
+			if op == nil {
+				t.Fatalf("%s %s is not documented", tt.method, tt.route)
+			}
+			reply, ok := op.Responses[fmt.Sprint(tt.status)]
+			if !ok {
+				reply, ok = op.Responses["default"]
+			}
+			if !ok {
+				t.Fatalf("status %d is not documented", tt.status)
+			}
+
+			var body any
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decoding body: %v", err)
+			}
+			for _, err := range schemaErrors(doc, reply.Content["application/json"].Schema, body, "body") {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// schemaErrors lists members of v that the schema does not declare and
+// declared required members v lacks
+func schemaErrors(doc openapi.Document, s openapi.Schema, v any, at string) []string {
+	if ref, ok := s["$ref"].(string); ok {
+		s = doc.Components.Schemas[strings.TrimPrefix(ref, "#/components/schemas/")]
+	}
+	if alts, ok := s["oneOf"].([]any); ok {
+		for _, alt := range alts {
+			if len(schemaErrors(doc, openapi.Schema(alt.(map[string]any)), v, at)) == 0 {
+				return nil
+			}
+		}
+		return []string{at + " matches none of the documented alternatives"}
+	}
+
+	var errs []string
+	switch v := v.(type) {
+	case []any:
+		items, _ := s["items"].(map[string]any)
+		for _, item := range v {
+			errs = append(errs, schemaErrors(doc, items, item, at+"[]")...)
+		}
+	case map[string]any:
+		props, _ := s["properties"].(map[string]any)
+		for name, member := range v {
+			prop, ok := props[name].(map[string]any)
+			if !ok {
+				errs = append(errs, at+"."+name+" is not documented")
+				continue
+			}
+			errs = append(errs, schemaErrors(doc, prop, member, at+"."+name)...)
+		}
+		required, _ := s["required"].([]any)
+		for _, name := range required {
+			if _, ok := v[name.(string)]; !ok {
+				errs = append(errs, fmt.Sprintf("%s lacks required member %s", at, name))
+			}
+		}
+	}
+	return errs
+}
+
+func TestServer_Docs(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		opts   []Option
+		status int
+	}{
+		{"disabled", nil, http.StatusNotFound},
+		{"enabled", []Option{WithDocs()}, http.StatusOK},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := NewServer(config.Default(false).Server, handlers.NewTaskHandler(repository.NewMemoryRepository()), tt.opts...)
+			rec := httptest.NewRecorder()
+			srv.router.ServeHTTP(rec, httptest.NewRequest("GET", "/docs", nil))
+			if rec.Code != tt.status {
+				t.Fatalf("status = %v, want %v", rec.Code, tt.status)
+			}
+			if tt.status == http.StatusOK && !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+				t.Errorf("Content-Type = %q, want text/html", rec.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/light-bringer/cert-tasks/internal/bodylog"
+	"github.com/light-bringer/cert-tasks/internal/diagnostics"
+	"github.com/light-bringer/cert-tasks/internal/handlers"
+	"github.com/light-bringer/cert-tasks/internal/logging"
+	"github.com/light-bringer/cert-tasks/internal/maintenance"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+	"github.com/light-bringer/cert-tasks/internal/scheduler"
+	"github.com/light-bringer/cert-tasks/internal/version"
+)
+
+// maintenanceRoutes serves maintenance mode, in which writes get 503
+// maintenance. These routes are never blocked, so the mode can always be
+// turned off again.
+//
+//api:changelog 0.2.0 added endpoint GET /admin/maintenance: Whether maintenance (read-only) mode is on, with its message and start time
+//api:changelog 0.2.0 added endpoint PUT /admin/maintenance: Turn maintenance (read-only) mode on or off; while on, every write to tasks, webhooks, workspaces and API keys gets 503
+func maintenanceRoutes(r chi.Router, handler *handlers.TaskHandler, mode *maintenance.Mode) {
+	r.Get("/admin/maintenance", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(mode.State())
+	})
+
+	r.Put("/admin/maintenance", func(w http.ResponseWriter, r *http.Request) {
+		var req maintenance.Request
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4*maintenance.MaxMessageLength))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&req); err != nil {
+			handler.Error(w, r, http.StatusBadRequest, handlers.CodeInvalidJSON, "invalid request body: "+err.Error())
+			return
+		}
+		if utf8.RuneCountInString(req.Message) > maintenance.MaxMessageLength {
+			handler.Error(w, r, http.StatusUnprocessableEntity, handlers.CodeValidationFailed,
+				fmt.Sprintf("message must be at most %d characters", maintenance.MaxMessageLength))
+			return
+		}
+
+		state := mode.Set(req.Enabled, req.Message)
+		logging.FromContext(r.Context()).Warn("maintenance mode changed",
+			slog.Bool("enabled", state.Enabled),
+			slog.String("message", state.Message),
+		)
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(state)
+	})
+}
+
+// bodyLogRoutes serves the body logging switch. Turned on here, logging
+// ends by itself after the requested duration, so it is not left on by
+// mistake.
+//
+//api:changelog 0.2.0 added endpoint GET /admin/debug/bodies: Whether request and response bodies are being logged, and until when
+//api:changelog 0.2.0 added endpoint PUT /admin/debug/bodies: Turn logging of redacted, size-capped request and response bodies on for a duration (default 15m, at most 24h) or off
+func bodyLogRoutes(r chi.Router, handler *handlers.TaskHandler, bodies *bodylog.Logger) {
+	r.Get("/admin/debug/bodies", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(bodies.State())
+	})
+
+	r.Put("/admin/debug/bodies", func(w http.ResponseWriter, r *http.Request) {
+		var req bodylog.Request
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&req); err != nil {
+			handler.Error(w, r, http.StatusBadRequest, handlers.CodeInvalidJSON, "invalid request body: "+err.Error())
+			return
+		}
+		d := bodylog.DefaultDuration
+		if req.Duration != "" {
+			parsed, err := time.ParseDuration(req.Duration)
+			if err != nil || parsed <= 0 || parsed > bodylog.MaxDuration {
+				handler.Error(w, r, http.StatusUnprocessableEntity, handlers.CodeValidationFailed,
+					fmt.Sprintf("duration must be a positive duration of at most %s, e.g. \"30m\"", bodylog.MaxDuration))
+				return
+			}
+			d = parsed
+		}
+
+		state := bodies.Set(req.Enabled, d)
+		logging.FromContext(r.Context()).Warn("body logging changed",
+			slog.Bool("enabled", state.Enabled),
+			slog.Any("until", state.Until),
+		)
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(state)
+	})
+}
+
+// logLevelRoutes serves the minimum log level, which can be lowered to
+// debug while investigating a problem and raised again without a restart.
+// The access log is not affected.
+//
+//api:changelog 0.2.0 added endpoint GET /admin/loglevel: The minimum level of the server log
+//api:changelog 0.2.0 added endpoint PUT /admin/loglevel: Change the minimum level of the server log to debug, info, warn or error without restarting
+func logLevelRoutes(r chi.Router, handler *handlers.TaskHandler, level *slog.LevelVar) {
+	r.Get("/admin/loglevel", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(logging.StateOf(level))
+	})
+
+	r.Put("/admin/loglevel", func(w http.ResponseWriter, r *http.Request) {
+		var req logging.LevelState
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&req); err != nil {
+			handler.Error(w, r, http.StatusBadRequest, handlers.CodeInvalidJSON, "invalid request body: "+err.Error())
+			return
+		}
+		var parsed slog.Level
+		if err := parsed.UnmarshalText([]byte(req.Level)); err != nil || req.Level == "" {
+			handler.Error(w, r, http.StatusUnprocessableEntity, handlers.CodeValidationFailed,
+				`level must be "debug", "info", "warn" or "error"`)
+			return
+		}
+
+		previous := logging.StateOf(level)
+		level.Set(parsed)
+		// Logged at WARN so the change shows whatever the new level
+		logging.FromContext(r.Context()).Warn("log level changed",
+			slog.String("from", previous.Level),
+			slog.String("to", logging.StateOf(level).Level),
+		)
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(logging.StateOf(level))
+	})
+}
+
+// storageRoutes serves storage statistics and compaction
+//
+//api:changelog 0.2.0 added endpoint GET /admin/stats: Counts of stored tasks, workspaces, API keys and webhooks, and the snapshot size for file storage
+//api:changelog 0.2.0 added field Stats.lead_time: Average, median and 90th percentile time from creation to completion of done tasks
+//api:changelog 0.2.0 added field Stats.cycle_time: Average, median and 90th percentile time from last opening to completion of done tasks
+//api:changelog 0.2.0 added endpoint POST /admin/compact: Reclaim space left by deleted records, drop dangling links and remove stale snapshot files
+func storageRoutes(r chi.Router, handler *handlers.TaskHandler, store repository.Maintainer) {
+	r.Get("/admin/stats", func(w http.ResponseWriter, r *http.Request) {
+		stats, err := store.Stats(r.Context())
+		if err != nil {
+			logging.FromContext(r.Context()).Error("reading storage stats", slog.Any("error", err))
+			handler.Error(w, r, http.StatusInternalServerError, handlers.CodeInternal, "failed to read storage stats")
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(stats)
+	})
+
+	r.Post("/admin/compact", func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		result, err := store.Compact(r.Context())
+		if err != nil {
+			logging.FromContext(r.Context()).Error("compacting storage", slog.Any("error", err))
+			handler.Error(w, r, http.StatusInternalServerError, handlers.CodeInternal, "failed to compact storage")
+			return
+		}
+		logging.FromContext(r.Context()).Info("storage compacted",
+			slog.Int("dangling_links", result.DanglingLinks),
+			slog.Int("stale_files", result.StaleFiles),
+			slog.Duration("duration", time.Since(start)),
+		)
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(result)
+	})
+}
+
+// diagnosticsRoute serves a report of the server's build, runtime,
+// maintenance, storage and job state. store and sched may be nil.
+//
+//api:changelog 0.2.0 added endpoint GET /admin/diagnostics: Build, uptime, Go runtime and memory figures, maintenance state, storage stats and job status in one report
+func diagnosticsRoute(r chi.Router, mode *maintenance.Mode, store repository.Maintainer, sched *scheduler.Scheduler, started time.Time) {
+	r.Get("/admin/diagnostics", func(w http.ResponseWriter, r *http.Request) {
+		report := diagnostics.Report{
+			Version:     version.Get(),
+			StartedAt:   started,
+			Uptime:      time.Since(started).Round(time.Second).String(),
+			Runtime:     diagnostics.ReadRuntime(),
+			Maintenance: mode.State(),
+		}
+		if store != nil {
+			if stats, err := store.Stats(r.Context()); err == nil {
+				report.Storage = &stats
+			} else {
+				logging.FromContext(r.Context()).Warn("reading storage stats", slog.Any("error", err))
+			}
+		}
+		if sched != nil {
+			report.Jobs = sched.Status()
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(report)
+	})
+}
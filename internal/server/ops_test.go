@@ -0,0 +1,222 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/bodylog"
+	"github.com/light-bringer/cert-tasks/internal/config"
+	"github.com/light-bringer/cert-tasks/internal/diagnostics"
+	"github.com/light-bringer/cert-tasks/internal/handlers"
+	"github.com/light-bringer/cert-tasks/internal/logging"
+	"github.com/light-bringer/cert-tasks/internal/maintenance"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+func TestServer_Maintenance(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	srv := NewServer(config.Default(false).Server, handlers.NewTaskHandler(repo, handlers.WithWorkspaces(repo)), WithAdminKey(testAdminKey))
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.router.ServeHTTP(rec, adminRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := serve("PUT", "/admin/maintenance", `{"enabled": true, "message": "migrating storage"}`)
+	var state maintenance.State
+	json.NewDecoder(rec.Body).Decode(&state)
+	if rec.Code != http.StatusOK || !state.Enabled || state.Message != "migrating storage" || state.Since == nil {
+		t.Fatalf("PUT /admin/maintenance = %v %+v", rec.Code, state)
+	}
+
+	rec = serve("POST", "/tasks", `{"title": "Blocked"}`)
+	var errResp handlers.ErrorResponse
+	json.NewDecoder(rec.Body).Decode(&errResp)
+	if rec.Code != http.StatusServiceUnavailable || errResp.Code != handlers.CodeMaintenance {
+		t.Errorf("POST /tasks in maintenance = %v %+v", rec.Code, errResp)
+	}
+	if rec := serve("GET", "/tasks", ""); rec.Code != http.StatusOK {
+		t.Errorf("GET /tasks in maintenance = %v, want 200", rec.Code)
+	}
+	if rec := serve("POST", "/workspaces", `{"id": "ops", "name": "Ops"}`); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("POST /workspaces in maintenance = %v, want 503", rec.Code)
+	}
+	if rec := serve("GET", "/workspaces", ""); rec.Code != http.StatusOK {
+		t.Errorf("GET /workspaces in maintenance = %v, want 200", rec.Code)
+	}
+
+	if rec := serve("PUT", "/admin/maintenance", `{"enabled": false}`); rec.Code != http.StatusOK {
+		t.Fatalf("turning maintenance off = %v", rec.Code)
+	}
+	if rec := serve("POST", "/tasks", `{"title": "Allowed"}`); rec.Code != http.StatusCreated {
+		t.Errorf("POST /tasks after maintenance = %v, want 201", rec.Code)
+	}
+
+	for _, body := range []string{`{"enabled": "yes"}`, `{"enabled": true, "reason": "x"}`} {
+		if rec := serve("PUT", "/admin/maintenance", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("PUT /admin/maintenance %s = %v, want 400", body, rec.Code)
+		}
+	}
+	long := `{"enabled": true, "message": "` + strings.Repeat("x", maintenance.MaxMessageLength+1) + `"}`
+	if rec := serve("PUT", "/admin/maintenance", long); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("long message = %v, want 422", rec.Code)
+	}
+}
+
+func TestServer_StorageAndDiagnostics(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	repo.Restore([]*models.Task{
+		{ID: 1, Title: "a", Status: models.StatusTodo, Links: []models.TaskLink{{Type: models.LinkRelatesTo, TaskID: 9}}},
+	}, 0)
+	srv := NewServer(config.Default(false).Server, handlers.NewTaskHandler(repo), WithStorage(repo), WithAdminKey(testAdminKey))
+
+	serve := func(method, path string, out any) int {
+		rec := httptest.NewRecorder()
+		srv.router.ServeHTTP(rec, adminRequest(method, path, nil))
+		json.NewDecoder(rec.Body).Decode(out)
+		return rec.Code
+	}
+
+	var stats repository.Stats
+	if code := serve("GET", "/admin/stats", &stats); code != http.StatusOK || stats.Tasks != 1 || stats.Workspaces != 1 {
+		t.Errorf("GET /admin/stats = %v %+v", code, stats)
+	}
+
+	var result repository.CompactResult
+	if code := serve("POST", "/admin/compact", &result); code != http.StatusOK || result.DanglingLinks != 1 {
+		t.Errorf("POST /admin/compact = %v %+v", code, result)
+	}
+
+	var report diagnostics.Report
+	if code := serve("GET", "/admin/diagnostics", &report); code != http.StatusOK {
+		t.Fatalf("GET /admin/diagnostics = %v", code)
+	}
+	if report.Storage == nil || report.Storage.Tasks != 1 || report.Runtime.Goroutines == 0 || report.StartedAt.IsZero() {
+		t.Errorf("diagnostics = %+v", report)
+	}
+
+	// Without WithStorage there are no storage routes, and diagnostics
+	// leave the section out
+	plain := NewServer(config.Default(false).Server, handlers.NewTaskHandler(repo), WithAdminKey(testAdminKey))
+	rec := httptest.NewRecorder()
+	plain.router.ServeHTTP(rec, adminRequest("GET", "/admin/stats", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("without WithStorage: status = %v, want 404", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	plain.router.ServeHTTP(rec, adminRequest("GET", "/admin/diagnostics", nil))
+	if strings.Contains(rec.Body.String(), `"storage"`) {
+		t.Errorf("diagnostics without storage: %s", rec.Body)
+	}
+}
+
+func TestServer_StartInMaintenance(t *testing.T) {
+	mode := &maintenance.Mode{}
+	mode.Set(true, "migrating")
+	srv := NewServer(config.Default(false).Server, handlers.NewTaskHandler(repository.NewMemoryRepository()), WithMaintenance(mode), WithAdminKey(testAdminKey))
+
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, adminRequest("DELETE", "/tasks/1", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("DELETE /tasks/1 = %v, want 503", rec.Code)
+	}
+
+	// The toggle itself is never blocked
+	rec = httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, adminRequest("PUT", "/admin/maintenance", strings.NewReader(`{"enabled": false}`)))
+	if rec.Code != http.StatusOK || mode.State().Enabled {
+		t.Errorf("PUT /admin/maintenance = %v, enabled = %v", rec.Code, mode.State().Enabled)
+	}
+}
+
+func TestServer_LogLevel(t *testing.T) {
+	level := new(slog.LevelVar)
+	srv := NewServer(config.Default(false).Server, handlers.NewTaskHandler(repository.NewMemoryRepository()), WithLogLevel(level), WithAdminKey(testAdminKey))
+
+	serve := func(method, body string) (*httptest.ResponseRecorder, logging.LevelState) {
+		rec := httptest.NewRecorder()
+		srv.router.ServeHTTP(rec, adminRequest(method, "/admin/loglevel", strings.NewReader(body)))
+		var state logging.LevelState
+		json.NewDecoder(rec.Body).Decode(&state)
+		return rec, state
+	}
+
+	if rec, state := serve("GET", ""); rec.Code != http.StatusOK || state.Level != "info" {
+		t.Errorf("GET /admin/loglevel = %v %+v", rec.Code, state)
+	}
+	if rec, state := serve("PUT", `{"level": "DEBUG"}`); rec.Code != http.StatusOK || state.Level != "debug" || level.Level() != slog.LevelDebug {
+		t.Errorf("PUT /admin/loglevel = %v %+v, level = %v", rec.Code, state, level.Level())
+	}
+
+	for body, want := range map[string]int{
+		`{"level": 4}`:                   http.StatusBadRequest,
+		`{"level": "warn", "for": "1h"}`: http.StatusBadRequest,
+		`{"level": "loud"}`:              http.StatusUnprocessableEntity,
+		`{}`:                             http.StatusUnprocessableEntity,
+	} {
+		if rec, _ := serve("PUT", body); rec.Code != want {
+			t.Errorf("PUT /admin/loglevel %s = %v, want %v", body, rec.Code, want)
+		}
+	}
+	if level.Level() != slog.LevelDebug {
+		t.Errorf("rejected changes moved the level to %v", level.Level())
+	}
+}
+
+func TestServer_BodyLog(t *testing.T) {
+	bodies := bodylog.New(bodylog.DefaultMaxBytes, nil)
+	srv := NewServer(config.Default(false).Server, handlers.NewTaskHandler(repository.NewMemoryRepository()), WithBodyLog(bodies), WithAdminKey(testAdminKey))
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.router.ServeHTTP(rec, adminRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := serve("PUT", "/admin/debug/bodies", `{"enabled": true, "duration": "30m"}`)
+	var state bodylog.State
+	json.NewDecoder(rec.Body).Decode(&state)
+	if rec.Code != http.StatusOK || !state.Enabled || state.Until == nil || time.Until(*state.Until) > 30*time.Minute {
+		t.Fatalf("PUT /admin/debug/bodies = %v %+v", rec.Code, state)
+	}
+	if !bodies.State().Enabled {
+		t.Error("body logging is off after turning it on")
+	}
+
+	rec = serve("GET", "/admin/debug/bodies", "")
+	state = bodylog.State{}
+	json.NewDecoder(rec.Body).Decode(&state)
+	if rec.Code != http.StatusOK || !state.Enabled || state.MaxBytes != bodylog.DefaultMaxBytes {
+		t.Errorf("GET /admin/debug/bodies = %v %+v", rec.Code, state)
+	}
+
+	// Without a duration it stays on for the default
+	rec = serve("PUT", "/admin/debug/bodies", `{"enabled": true}`)
+	state = bodylog.State{}
+	json.NewDecoder(rec.Body).Decode(&state)
+	if state.Until == nil || time.Until(*state.Until) <= bodylog.DefaultDuration-time.Minute {
+		t.Errorf("PUT without a duration = %+v, want on for %s", state, bodylog.DefaultDuration)
+	}
+
+	if rec := serve("PUT", "/admin/debug/bodies", `{"enabled": false}`); rec.Code != http.StatusOK || bodies.State().Enabled {
+		t.Errorf("turning body logging off = %v, enabled = %v", rec.Code, bodies.State().Enabled)
+	}
+
+	for body, want := range map[string]int{
+		`{"enabled": "yes"}`:                    http.StatusBadRequest,
+		`{"enabled": true, "for": "1h"}`:        http.StatusBadRequest,
+		`{"enabled": true, "duration": "soon"}`: http.StatusUnprocessableEntity,
+		`{"enabled": true, "duration": "48h"}`:  http.StatusUnprocessableEntity,
+	} {
+		if rec := serve("PUT", "/admin/debug/bodies", body); rec.Code != want {
+			t.Errorf("PUT /admin/debug/bodies %s = %v, want %v", body, rec.Code, want)
+		}
+	}
+}
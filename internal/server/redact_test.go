@@ -0,0 +1,165 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/light-bringer/cert-tasks/internal/audit"
+	"github.com/light-bringer/cert-tasks/internal/bodylog"
+	"github.com/light-bringer/cert-tasks/internal/calendar"
+	"github.com/light-bringer/cert-tasks/internal/cleanup"
+	"github.com/light-bringer/cert-tasks/internal/config"
+	"github.com/light-bringer/cert-tasks/internal/erasure"
+	"github.com/light-bringer/cert-tasks/internal/handlers"
+	"github.com/light-bringer/cert-tasks/internal/health"
+	"github.com/light-bringer/cert-tasks/internal/jobs"
+	"github.com/light-bringer/cert-tasks/internal/logging"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/realtime"
+	"github.com/light-bringer/cert-tasks/internal/redact"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+	"github.com/light-bringer/cert-tasks/internal/scheduler"
+)
+
+// routeParam matches the parameters in a route pattern
+var routeParam = regexp.MustCompile(`\{[^}]*\}|\*$`)
+
+// TestServer_NoRouteBypassesRedaction sends a description and a search
+// query to every route, with bodies logged, and fails if either reaches
+// the log or the audit log. A handler logging a sensitive field by
+// another name, or through a logger other than the request's, fails it.
+func TestServer_NoRouteBypassesRedaction(t *testing.T) {
+	const secret = "Jane Example, 555-0100"
+
+	var logs bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(logging.New(&logs, slog.LevelDebug))
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	redactor := redact.New([]string{"description"})
+	bodies := bodylog.New(bodylog.DefaultMaxBytes, redactor)
+	bodies.Set(true, 0)
+	auditLog := audit.New(1000)
+	repo := repository.NewMemoryRepository()
+	hub := realtime.NewHub(realtime.DefaultConfig())
+	defer hub.Close()
+	queue := jobs.New(jobs.DefaultConfig())
+	srv := NewServer(config.Default(false).Server,
+		handlers.NewTaskHandler(repo,
+			handlers.WithAPIKeys(repo),
+			handlers.WithWorkspaces(repo),
+			handlers.WithRedactor(redactor),
+		),
+		WithRedactor(redactor),
+		WithBodyLog(bodies),
+		WithAudit(auditLog),
+		WithHealth(health.NewRegistry()),
+		WithScheduler(scheduler.New()),
+		WithRealtime(hub),
+		WithStorage(repo),
+		WithCleanup(cleanup.New(repo, nil)),
+		WithErasure(erasure.New(repo, nil)),
+		WithUserExport(),
+		WithJobQueue(queue),
+		WithLogLevel(new(slog.LevelVar)),
+		WithAdminKey(testAdminKey),
+	)
+
+	body := `{"title":"Call back","description":"` + secret + `"}`
+	query := "q=" + url.QueryEscape(secret) + "&description=" + url.QueryEscape(secret)
+	send := func(method, path string) {
+		if strings.Contains(path, "?") {
+			path += "&" + query
+		} else {
+			path += "?" + query
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		req := adminRequest(method, path, strings.NewReader(body)).WithContext(ctx)
+		req.Header.Set("Content-Type", "application/json")
+		srv.router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// A task to read, change, export and delete
+	send("POST", "/tasks")
+	send("GET", "/tasks/export")
+	send("GET", "/tasks/export?format=ndjson&redact=true")
+
+	routes := 0
+	err := chi.Walk(srv.router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		path := routeParam.ReplaceAllString(strings.TrimSuffix(route, "/"), "1")
+		if path == "" {
+			path = "/"
+		}
+		send(method, path)
+		routes++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if routes < 50 {
+		t.Fatalf("walked %d routes, want every route", routes)
+	}
+
+	if !strings.Contains(logs.String(), redact.Mask) {
+		t.Fatalf("nothing was redacted; were bodies logged?\n%s", logs.String())
+	}
+	for _, leak := range []string{"Jane", "Jane+Example", "Jane%20Example"} {
+		if i := strings.Index(logs.String(), leak); i >= 0 {
+			start := strings.LastIndex(logs.String()[:i], "\n") + 1
+			end := i + strings.Index(logs.String()[i:], "\n")
+			t.Errorf("the log contains %q:\n%s", leak, logs.String()[start:end])
+		}
+	}
+	recorded, _ := auditLog.Query(audit.Filter{})
+	entries, _ := json.Marshal(recorded)
+	if len(entries) < 100 || strings.Contains(string(entries), "Jane") {
+		t.Errorf("audit entries = %s, want many, none with the description", entries)
+	}
+}
+
+// TestServer_BodyLogHidesCalendarToken fetches a calendar feed token with
+// bodies logged and fails if the token reaches the log, as the token
+// itself or inside the feed's path
+func TestServer_BodyLogHidesCalendarToken(t *testing.T) {
+	var logs bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(logging.New(&logs, slog.LevelDebug))
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	bodies := bodylog.New(bodylog.DefaultMaxBytes, nil)
+	bodies.Set(true, 0)
+	repo := repository.NewMemoryRepository()
+	srv := NewServer(config.Default(false).Server,
+		handlers.NewTaskHandler(repo, handlers.WithCalendar(calendar.NewTokens([]byte(strings.Repeat("c", 32))))),
+		WithBodyLog(bodies),
+	)
+
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest("GET", "/calendar/token", nil))
+	var feed models.CalendarFeed
+	json.NewDecoder(rec.Body).Decode(&feed)
+	if rec.Code != http.StatusOK || feed.Token == "" {
+		t.Fatalf("GET /calendar/token = %v %+v", rec.Code, feed)
+	}
+
+	if !strings.Contains(logs.String(), "request and response bodies") {
+		t.Fatalf("no body record in the log:\n%s", logs.String())
+	}
+	for _, leak := range []string{feed.Token, url.QueryEscape(feed.Token)} {
+		if strings.Contains(logs.String(), leak) {
+			t.Errorf("the log contains the feed token %q:\n%s", leak, logs.String())
+		}
+	}
+}
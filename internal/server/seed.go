@@ -0,0 +1,53 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/light-bringer/cert-tasks/internal/handlers"
+	"github.com/light-bringer/cert-tasks/internal/seed"
+)
+
+// maxSeedBytes caps the fixtures document accepted by POST /admin/seed
+const maxSeedBytes = 10 << 20
+
+// seedRoute serves the fixture loader of development mode. A request
+// without a body reloads the fixtures loaded last, such as the -seed file.
+//
+//api:changelog 0.2.0 added endpoint POST /admin/seed: Development mode only; replace all tasks, workspaces, API keys and webhooks with fixtures
+func seedRoute(r chi.Router, handler *handlers.TaskHandler, seeder *seed.Seeder) {
+	r.Post("/admin/seed", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSeedBytes))
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			handler.Error(w, r, http.StatusRequestEntityTooLarge, handlers.CodeBodyTooLarge, "fixtures exceed 10 MiB")
+			return
+		case err != nil:
+			handler.Error(w, r, http.StatusBadRequest, handlers.CodeInvalidJSON, "failed to read request body")
+			return
+		}
+
+		var result seed.Result
+		if len(bytes.TrimSpace(body)) == 0 {
+			result = seeder.Reload()
+		} else {
+			fx, err := seed.Decode(bytes.NewReader(body))
+			if err != nil {
+				handler.Error(w, r, http.StatusBadRequest, handlers.CodeInvalidJSON, "invalid fixtures: "+err.Error())
+				return
+			}
+			if result, err = seeder.Load(fx); err != nil {
+				handler.Error(w, r, http.StatusUnprocessableEntity, handlers.CodeValidationFailed, err.Error())
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(result)
+	})
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/light-bringer/cert-tasks/internal/config"
+	"github.com/light-bringer/cert-tasks/internal/handlers"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+	"github.com/light-bringer/cert-tasks/internal/seed"
+)
+
+func TestServer_Seed(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	srv := NewServer(config.Default(false).Server, handlers.NewTaskHandler(repo), WithSeed(seed.New(repo)), WithAdminKey(testAdminKey))
+
+	post := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.router.ServeHTTP(rec, adminRequest("POST", "/admin/seed", strings.NewReader(body)))
+		return rec
+	}
+
+	rec := post(`{"tasks": [{"id": 5, "title": "Seeded"}]}`)
+	var result seed.Result
+	json.NewDecoder(rec.Body).Decode(&result)
+	if rec.Code != http.StatusOK || result.Tasks != 1 {
+		t.Fatalf("POST /admin/seed = %v %+v", rec.Code, result)
+	}
+
+	// An empty body returns to the fixtures loaded last
+	repo.Create(context.Background(), &models.Task{Title: "Extra", Status: models.StatusTodo})
+	if rec := post(""); rec.Code != http.StatusOK {
+		t.Fatalf("reload: status = %v", rec.Code)
+	}
+	if tasks, _ := repo.List(context.Background(), repository.ListOptions{}); len(tasks) != 1 || tasks[0].ID != 5 {
+		t.Errorf("after reload: %+v", tasks)
+	}
+
+	tests := []struct {
+		body       string
+		wantStatus int
+	}{
+		{`{"tasks": [`, http.StatusBadRequest},
+		{`{"projects": []}`, http.StatusBadRequest},
+		{`{"tasks": [{"title": ""}]}`, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		if rec := post(tt.body); rec.Code != tt.wantStatus {
+			t.Errorf("POST /admin/seed %s: status = %v, want %v", tt.body, rec.Code, tt.wantStatus)
+		}
+	}
+
+	// Without WithSeed the route does not exist
+	plain := NewServer(config.Default(false).Server, handlers.NewTaskHandler(repo), WithAdminKey(testAdminKey))
+	rec = httptest.NewRecorder()
+	plain.router.ServeHTTP(rec, adminRequest("POST", "/admin/seed", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("without WithSeed: status = %v, want %v", rec.Code, http.StatusNotFound)
+	}
+}
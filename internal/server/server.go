@@ -2,81 +2,820 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/light-bringer/cert-tasks/internal/accesslog"
+	"github.com/light-bringer/cert-tasks/internal/audit"
+	"github.com/light-bringer/cert-tasks/internal/auth"
+	"github.com/light-bringer/cert-tasks/internal/backup"
+	"github.com/light-bringer/cert-tasks/internal/bodylog"
+	"github.com/light-bringer/cert-tasks/internal/changelog"
+	"github.com/light-bringer/cert-tasks/internal/cleanup"
+	"github.com/light-bringer/cert-tasks/internal/config"
+	"github.com/light-bringer/cert-tasks/internal/erasure"
+	"github.com/light-bringer/cert-tasks/internal/forwarded"
 	"github.com/light-bringer/cert-tasks/internal/handlers"
+	"github.com/light-bringer/cert-tasks/internal/health"
+	"github.com/light-bringer/cert-tasks/internal/jobs"
+	"github.com/light-bringer/cert-tasks/internal/latency"
+	"github.com/light-bringer/cert-tasks/internal/logging"
+	"github.com/light-bringer/cert-tasks/internal/maintenance"
+	"github.com/light-bringer/cert-tasks/internal/openapi"
+	"github.com/light-bringer/cert-tasks/internal/ratelimit"
+	"github.com/light-bringer/cert-tasks/internal/realtime"
+	"github.com/light-bringer/cert-tasks/internal/recovery"
+	"github.com/light-bringer/cert-tasks/internal/redact"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+	"github.com/light-bringer/cert-tasks/internal/requestid"
+	"github.com/light-bringer/cert-tasks/internal/scheduler"
+	"github.com/light-bringer/cert-tasks/internal/schemas"
+	"github.com/light-bringer/cert-tasks/internal/seed"
+	"github.com/light-bringer/cert-tasks/internal/static"
+	"github.com/light-bringer/cert-tasks/internal/ui"
+	"github.com/light-bringer/cert-tasks/internal/version"
 )
 
 // Server represents the HTTP server
 type Server struct {
+	config config.Server
 	router *chi.Mux
 	server *http.Server
+	logger *slog.Logger
+	drain  *drain
+
+	listener, redirectListener net.Listener
+	ready                      func()
+}
+
+// Option configures a Server
+type Option func(*options)
+
+// options holds the settings collected from Option values
+type options struct {
+	middlewares []func(http.Handler) http.Handler
+	mounts      []mount
+	ui          bool
+	health      *health.Registry
+	scheduler   *scheduler.Scheduler
+	limiter     *ratelimit.Limiter
+	auth        *auth.Authenticator
+	adminKey    string
+	audit       *audit.Log
+	realtime    *realtime.Hub
+	docs        bool
+	seeder      *seed.Seeder
+	storage     repository.Maintainer
+	maintenance *maintenance.Mode
+	panics      recovery.Reporter
+	bodies      *bodylog.Logger
+	redactor    *redact.Redactor
+	logLevel    *slog.LevelVar
+	accessLog   *accesslog.Logger
+	janitor     *cleanup.Janitor
+	eraser      *erasure.Eraser
+	userExport  bool
+	backups     *backup.Manager
+	queue       *jobs.Queue
+	listener    net.Listener
+	redirect    net.Listener
+	ready       func()
+}
+
+// WithMiddleware appends middleware to the router after the built-in stack
+func WithMiddleware(mw ...func(http.Handler) http.Handler) Option {
+	return func(o *options) {
+		o.middlewares = append(o.middlewares, mw...)
+	}
+}
+
+// mount is a handler served under a path prefix
+type mount struct {
+	prefix  string
+	handler http.Handler
+}
+
+// WithMount serves h under prefix, authenticated and scoped to a
+// workspace like the task routes. Plugin routes are mounted with it.
+func WithMount(prefix string, h http.Handler) Option {
+	return func(o *options) {
+		o.mounts = append(o.mounts, mount{prefix, h})
+	}
+}
+
+// WithUI mounts the embedded browser UI at /ui
+func WithUI() Option {
+	return func(o *options) {
+		o.ui = true
+	}
+}
+
+// WithHealth serves the registry's dependency report at /health
+func WithHealth(reg *health.Registry) Option {
+	return func(o *options) {
+		o.health = reg
+	}
+}
+
+// WithCleanup lets the janitor's retention policies be run on demand at
+// POST /admin/cleanup
+func WithCleanup(janitor *cleanup.Janitor) Option {
+	return func(o *options) {
+		o.janitor = janitor
+	}
+}
+
+// WithErasure serves DELETE /users/{id}/data, which erases a user's data
+// through eraser
+func WithErasure(eraser *erasure.Eraser) Option {
+	return func(o *options) {
+		o.eraser = eraser
+	}
+}
+
+// WithUserExport serves GET /users/{id}/export and the routes polling and
+// downloading a user export. Like erasure it answers data subject requests
+// and is only served when those are set up.
+func WithUserExport() Option {
+	return func(o *options) {
+		o.userExport = true
+	}
+}
+
+// WithBackups serves POST /admin/backup and POST /admin/restore, writing
+// and reading archives through m
+func WithBackups(m *backup.Manager) Option {
+	return func(o *options) {
+		o.backups = m
+	}
+}
+
+// WithScheduler serves the scheduler's jobs admin API at /admin/jobs
+func WithScheduler(sched *scheduler.Scheduler) Option {
+	return func(o *options) {
+		o.scheduler = sched
+	}
+}
+
+// WithJobQueue serves the job queue's status at GET /admin/jobs/queue
+func WithJobQueue(queue *jobs.Queue) Option {
+	return func(o *options) {
+		o.queue = queue
+	}
+}
+
+// WithRateLimit limits task API requests per client; clients over their
+// rate get 429 with Retry-After
+func WithRateLimit(limiter *ratelimit.Limiter) Option {
+	return func(o *options) {
+		o.limiter = limiter
+	}
+}
+
+// WithAuth requires an API key on task routes, and adminKey on /apikeys,
+// /workspaces and the /admin endpoints
+func WithAuth(authenticator *auth.Authenticator, adminKey string) Option {
+	return func(o *options) {
+		o.auth = authenticator
+		o.adminKey = adminKey
+	}
+}
+
+// WithAdminKey serves /workspaces and the /admin endpoints behind
+// adminKey on a server without WithAuth. Without an admin key they are not
+// served.
+func WithAdminKey(adminKey string) Option {
+	return func(o *options) {
+		o.adminKey = adminKey
+	}
+}
+
+// WithAudit records mutating task and admin requests in log and serves it
+// at /audit
+func WithAudit(log *audit.Log) Option {
+	return func(o *options) {
+		o.audit = log
+	}
+}
+
+// WithRealtime serves the hub's WebSocket API at /ws
+func WithRealtime(hub *realtime.Hub) Option {
+	return func(o *options) {
+		o.realtime = hub
+	}
+}
+
+// WithDocs serves a Swagger UI for /openapi.json at /docs
+func WithDocs() Option {
+	return func(o *options) {
+		o.docs = true
+	}
 }
 
-// NewServer creates a new HTTP server with configured routes and middleware
-func NewServer(handler *handlers.TaskHandler) *Server {
+// WithSeed serves POST /admin/seed, which replaces all data with fixtures.
+// It is meant for development mode only.
+func WithSeed(seeder *seed.Seeder) Option {
+	return func(o *options) {
+		o.seeder = seeder
+	}
+}
+
+// WithStorage serves storage statistics at /admin/stats and compaction at
+// /admin/compact, and adds the statistics to /admin/diagnostics
+func WithStorage(store repository.Maintainer) Option {
+	return func(o *options) {
+		o.storage = store
+	}
+}
+
+// WithMaintenance uses mode for maintenance (read-only) mode, so that it
+// can be turned on before the server starts. Without it the server starts
+// with maintenance mode off.
+func WithMaintenance(mode *maintenance.Mode) Option {
+	return func(o *options) {
+		o.maintenance = mode
+	}
+}
+
+// WithPanicReporter sends recovered handler panics to reporter, such as
+// an error tracker, besides logging them
+func WithPanicReporter(reporter recovery.Reporter) Option {
+	return func(o *options) {
+		o.panics = reporter
+	}
+}
+
+// WithBodyLog uses bodies for logging request and response bodies, so
+// that it can be turned on before the server starts. Without it body
+// logging starts off; either way it is toggled at /admin/debug/bodies.
+func WithBodyLog(bodies *bodylog.Logger) Option {
+	return func(o *options) {
+		o.bodies = bodies
+	}
+}
+
+// WithRedactor masks the fields redactor names in every record of the
+// request log and in logged bodies, instead of redact.DefaultFields. A
+// body log given to WithBodyLog carries its own.
+func WithRedactor(redactor *redact.Redactor) Option {
+	return func(o *options) {
+		o.redactor = redactor
+	}
+}
+
+// WithLogLevel serves level, the minimum level of the server log, at
+// /admin/loglevel so it can be changed at runtime
+func WithLogLevel(level *slog.LevelVar) Option {
+	return func(o *options) {
+		o.logLevel = level
+	}
+}
+
+// WithAccessLog writes the record of each completed request to access
+// instead of the default logger
+func WithAccessLog(access *accesslog.Logger) Option {
+	return func(o *options) {
+		o.accessLog = access
+	}
+}
+
+// WithListeners serves on api, and the HTTP to HTTPS redirect on
+// redirect, instead of listening on the configured addresses, as for
+// sockets passed by systemd. Either may be nil.
+func WithListeners(api, redirect net.Listener) Option {
+	return func(o *options) {
+		o.listener = api
+		o.redirect = redirect
+	}
+}
+
+// WithReady calls ready once Run is accepting connections
+func WithReady(ready func()) Option {
+	return func(o *options) {
+		o.ready = ready
+	}
+}
+
+// NewServer creates a new HTTP server with configured routes and middleware.
+// cfg supplies the listen address, timeouts, trusted proxies and CORS policy.
+func NewServer(cfg config.Server, handler *handlers.TaskHandler, opts ...Option) *Server {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	r := chi.NewRouter()
 
 	// Middleware
-	r.Use(middleware.Logger)                        // Log all requests
-	r.Use(middleware.Recoverer)                     // Recover from panics
+	redactor := o.redactor
+	if redactor == nil {
+		redactor = redact.New(redact.DefaultFields)
+	}
+	logger := slog.New(redact.Handler(slog.Default().Handler(), redactor))
+	var access logging.AccessLog
+	if o.accessLog != nil {
+		access = o.accessLog
+	}
+	drainer := &drain{}
+	r.Use(requestid.Middleware(cfg.TrustedProxies))             // Assign a request ID, echoed in X-Request-ID
+	r.Use(forwarded.Middleware(cfg.TrustedProxies))             // Client address and scheme from trusted proxies' X-Forwarded-*
+	r.Use(logging.Middleware(logger, access))                   // Request-scoped logger, log all requests
+	r.Use(drainer.middleware(handler))                          // Count in-flight requests, reject new ones during shutdown
+	r.Use(recovery.Middleware(o.panics, handler.InternalError)) // Recover from panics, log and report them
+	if len(cfg.CORS.AllowedOrigins) > 0 {
+		r.Use(cors(cfg.CORS, r)) // Browser access from allowed origins
+	}
+	if cfg.Compression.Enabled {
+		r.Use(compress(cfg.Compression)) // gzip or deflate, as the client accepts
+	}
+	r.Use(methods(r)) // HEAD through GET handlers, OPTIONS with the allowed methods
+	bodies := o.bodies
+	if bodies == nil {
+		bodies = bodylog.New(bodylog.DefaultMaxBytes, redactor)
+	}
+	r.Use(bodies.Middleware) // Redacted bodies in the log, while turned on
 	r.Use(middleware.SetHeader("Content-Type", "application/json"))
+	r.Use(o.middlewares...)
+
+	// Unknown routes and methods get the same error format as handlers
+	r.NotFound(handler.NotFound)
+	r.MethodNotAllowed(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Allow", strings.Join(allowedMethods(r, req.URL.Path), ", "))
+		handler.MethodNotAllowed(w, req)
+	})
+
+	// Routes, timed against their latency budgets
+	routes := taskRoutes(handler)
+	budgets := make(map[string]time.Duration, len(routes))
+	for _, rt := range routes {
+		budgets[rt.method+" "+rt.pattern] = rt.budget
+	}
+	tracker := latency.NewTracker(budgets)
+	mode := o.maintenance
+	if mode == nil {
+		mode = &maintenance.Mode{}
+	}
+	started := time.Now().UTC()
+
+	r.Group(func(r chi.Router) {
+		if o.auth != nil {
+			r.Use(o.auth.Middleware(handler.Unauthorized, handler.Forbidden))
+		}
+		r.Use(handler.ResolveWorkspace)
+		if o.audit != nil {
+			r.Use(o.audit.Middleware("anonymous"))
+		}
+		r.Use(mode.Middleware(handler.Maintenance))
+		if o.limiter != nil {
+			r.Use(o.limiter.Middleware(handler.RateLimited))
+		}
+		r.Use(tracker.Middleware)
+		tasksTimeout := timeout(handler, cfg.RequestTimeouts.Tasks)
+		bulkTimeout := timeout(handler, cfg.RequestTimeouts.Bulk)
+		for _, rt := range routes {
+			if bulkRoutes[rt.method+" "+rt.pattern] {
+				r.Method(rt.method, rt.pattern, bulkTimeout(rt.handler))
+				continue
+			}
+			r.Method(rt.method, rt.pattern, tasksTimeout(rt.handler))
+		}
+		for _, m := range o.mounts {
+			r.Mount(m.prefix, tasksTimeout(m.handler))
+		}
+	})
+
+	// The WebSocket API, authenticated like the task routes; mutations sent
+	// over it are replayed through the whole router
+	if o.realtime != nil {
+		router := r
+		r.Group(func(r chi.Router) {
+			r.Use(realtime.AccessToken)
+			if o.auth != nil {
+				r.Use(o.auth.Middleware(handler.Unauthorized, handler.Forbidden))
+			}
+			r.Use(handler.ResolveWorkspace)
+			if o.limiter != nil {
+				r.Use(o.limiter.Middleware(handler.RateLimited))
+			}
 
-	// Routes
-	r.Post("/tasks", handler.CreateTask)
-	r.Get("/tasks", handler.ListTasks)
-	r.Get("/tasks/{id}", handler.GetTask)
-	r.Put("/tasks/{id}", handler.UpdateTask)
-	r.Delete("/tasks/{id}", handler.DeleteTask)
+			//api:changelog 0.2.0 added endpoint GET /ws: WebSocket streaming task events with subscription filters and accepting create, update and delete messages
+			r.Get("/ws", o.realtime.Handler(router).ServeHTTP)
+		})
+	}
+
+	// The calendar feed carries its own token, as calendar apps cannot
+	// send API keys
+	r.Group(func(r chi.Router) {
+		if o.limiter != nil {
+			r.Use(o.limiter.Middleware(handler.RateLimited))
+		}
+		r.Use(timeout(handler, cfg.RequestTimeouts.Tasks))
+		r.Get("/calendar.ics", handler.CalendarFeed)
+	})
+
+	// Signed download links carry their own signature, so they can be
+	// handed to a browser
+	r.Group(func(r chi.Router) {
+		if o.limiter != nil {
+			r.Use(o.limiter.Middleware(handler.RateLimited))
+		}
+		r.Get("/blobs/{name}", handler.DownloadBlob)
+	})
+
+	if o.health != nil {
+		//api:changelog 0.2.0 added endpoint GET /health: Dependency status; 503 when a critical dependency is down
+		r.Get("/health", o.health.ServeHTTP)
+	}
+
+	// Admin endpoints, always behind the admin key whether or not auth is
+	// enabled, and not served at all without one
+	if o.adminKey != "" {
+		r.Group(func(r chi.Router) {
+			r.Use(auth.AdminMiddleware(o.adminKey, handler.Unauthorized))
+			if o.audit != nil {
+				r.Use(o.audit.Middleware("admin"))
+			}
+
+			// Backups and restores take as long as the data is large, so
+			// they run under the bulk timeout, not the admin one
+			if o.backups != nil {
+				r.Group(func(r chi.Router) {
+					r.Use(timeout(handler, cfg.RequestTimeouts.Bulk))
+					backupRoutes(r, handler, o.backups, mode)
+				})
+			}
+
+			r.Group(func(r chi.Router) {
+				r.Use(timeout(handler, cfg.RequestTimeouts.Admin))
+				if o.audit != nil {
+					//api:changelog 0.2.0 added endpoint GET /audit: Audit trail of mutating requests, filterable by actor, workspace, route, result and time
+					r.Get("/audit", auditQuery(handler, o.audit))
+				}
+
+				// Data the admin key manages is read-only in maintenance mode
+				// too; the operational endpoints below stay usable
+				r.Group(func(r chi.Router) {
+					r.Use(mode.Middleware(handler.Maintenance))
+					if o.auth != nil {
+						r.Post("/apikeys", handler.CreateAPIKey)
+						r.Get("/admin/apikeys", handler.ListAPIKeys)
+						r.Post("/admin/apikeys/{id}/rotate", handler.RotateAPIKey)
+					}
+
+					r.Route("/workspaces", func(r chi.Router) {
+						r.Post("/", handler.CreateWorkspace)
+						r.Get("/", handler.ListWorkspaces)
+						r.Get("/{id}", handler.GetWorkspace)
+						r.Put("/{id}", handler.UpdateWorkspace)
+						r.Delete("/{id}", handler.DeleteWorkspace)
+					})
+
+					if o.seeder != nil {
+						seedRoute(r, handler, o.seeder)
+					}
+					if o.janitor != nil {
+						cleanupRoute(r, handler, o.janitor)
+					}
+					if o.eraser != nil {
+						erasureRoute(r, handler, o.eraser)
+					}
+				})
+
+				// Exporting a user's data only reads it, so it stays usable in
+				// maintenance mode
+				if o.userExport {
+					r.Get("/users/{id}/export", handler.ExportUser)
+					r.Get("/users/{id}/exports/{job}", handler.GetUserExport)
+					r.Get("/users/{id}/exports/{job}/download", handler.DownloadUserExport)
+				}
+
+				//api:changelog 0.2.0 added endpoint GET /admin/slow-report: Routes ranked by latency budget breaches
+				r.Get("/admin/slow-report", slowReport(handler, tracker))
+
+				if o.scheduler != nil {
+					jobRoutes(r, handler, o.scheduler)
+				}
+				if o.queue != nil {
+					queueRoute(r, handler, o.queue)
+				}
+				if o.storage != nil {
+					storageRoutes(r, handler, o.storage)
+				}
+				maintenanceRoutes(r, handler, mode)
+				bodyLogRoutes(r, handler, bodies)
+				if o.logLevel != nil {
+					logLevelRoutes(r, handler, o.logLevel)
+				}
+				diagnosticsRoute(r, mode, o.storage, o.scheduler, started)
+			})
+		})
+	}
+
+	// Static documents, served with ETag/Cache-Control handling
+	//api:changelog 0.2.0 added endpoint GET /version: Build version and commit of the server
+	versionJSON, _ := json.Marshal(version.Get())
+	r.Get("/version", static.NewAsset("version.json", "", versionJSON).ServeHTTP)
+
+	//api:changelog 0.2.0 added endpoint GET /changelog.json: Machine-readable list of API changes per release
+	r.Get("/changelog.json", static.NewAsset("changelog.json", "", changelog.JSON()).ServeHTTP)
+
+	schemaFS, err := static.NewFS(schemas.FS)
+	if err != nil {
+		panic(err) // embedded files are always readable
+	}
+	//api:changelog 0.2.0 added endpoint GET /schemas/{name}: JSON Schemas for the task and request documents
+	r.Handle("/schemas/*", http.StripPrefix("/schemas", schemaFS))
+
+	openAPIJSON, err := openAPIDocument()
+	if err != nil {
+		panic(err) // the operations are checked against the changelog by tests
+	}
+	//api:changelog 0.2.0 added endpoint GET /openapi.json: OpenAPI 3.1 description of the API
+	r.Get("/openapi.json", static.NewAsset("openapi.json", "", openAPIJSON).ServeHTTP)
+
+	if o.docs {
+		//api:changelog 0.2.0 added endpoint GET /docs: Swagger UI for the OpenAPI description
+		r.Get("/docs", static.NewAsset("docs.html", "", openapi.DocsPage).ServeHTTP)
+	}
+
+	if o.ui {
+		uiHandler, err := ui.Handler("/ui")
+		if err != nil {
+			panic(err) // embedded files are always readable
+		}
+		r.Get("/ui", http.RedirectHandler("/ui/", http.StatusMovedPermanently).ServeHTTP)
+		r.Handle("/ui/*", http.StripPrefix("/ui", uiHandler))
+	}
 
 	return &Server{
+		config: cfg,
 		router: r,
+		logger: logger,
+		drain:  drainer,
+
+		listener:         o.listener,
+		redirectListener: o.redirect,
+		ready:            o.ready,
+	}
+}
+
+// route is an entry in the route registry
+type route struct {
+	method  string
+	pattern string
+	handler http.HandlerFunc
+
+	// budget is the latency the route is expected to stay within;
+	// slower requests are reported by /admin/slow-report
+	budget time.Duration
+}
+
+// taskRoutes is the registry of task API routes
+func taskRoutes(handler *handlers.TaskHandler) []route {
+	return []route{
+		{http.MethodPost, "/tasks", handler.CreateTask, 100 * time.Millisecond},
+		{http.MethodGet, "/tasks", handler.ListTasks, 250 * time.Millisecond},
+		{http.MethodGet, "/tasks/todo", handler.ListTodoTasks, 250 * time.Millisecond},
+		{http.MethodGet, "/tasks/done", handler.ListDoneTasks, 250 * time.Millisecond},
+		{http.MethodGet, "/tasks/status/{status}", handler.ListTasksByStatus, 250 * time.Millisecond},
+		{http.MethodGet, "/tasks/export", handler.ExportTasks, time.Second},
+		{http.MethodPost, "/tasks/import", handler.ImportTasks, time.Second},
+		{http.MethodGet, "/tasks/{id}", handler.GetTask, 50 * time.Millisecond},
+		{http.MethodPut, "/tasks/{id}", handler.UpdateTask, 100 * time.Millisecond},
+		{http.MethodDelete, "/tasks", handler.DeleteTasks, 500 * time.Millisecond},
+		{http.MethodDelete, "/tasks/{id}", handler.DeleteTask, 100 * time.Millisecond},
+		{http.MethodPost, "/tasks/{id}/complete", handler.CompleteTask, 100 * time.Millisecond},
+		{http.MethodPost, "/tasks/{id}/reopen", handler.ReopenTask, 100 * time.Millisecond},
+		{http.MethodPost, "/tasks/{id}/links", handler.CreateLink, 100 * time.Millisecond},
+		{http.MethodGet, "/tasks/{id}/revisions", handler.ListRevisions, 50 * time.Millisecond},
+		{http.MethodPost, "/tasks/{id}/revisions/{n}/restore", handler.RestoreRevision, 100 * time.Millisecond},
+		{http.MethodPost, "/undo/{token}", handler.Undo, 500 * time.Millisecond},
+		{http.MethodGet, "/jobs/{id}", handler.GetJob, 50 * time.Millisecond},
+		{http.MethodGet, "/jobs/{id}/download", handler.DownloadJob, time.Second},
+		{http.MethodPost, "/webhooks", handler.CreateWebhook, 100 * time.Millisecond},
+		{http.MethodGet, "/webhooks", handler.ListWebhooks, 100 * time.Millisecond},
+		{http.MethodDelete, "/webhooks/{id}", handler.DeleteWebhook, 100 * time.Millisecond},
+		{http.MethodGet, "/webhooks/{id}/deliveries", handler.ListDeliveries, 100 * time.Millisecond},
+		{http.MethodGet, "/calendar/token", handler.CalendarToken, 50 * time.Millisecond},
+		{http.MethodGet, "/reports/burndown", handler.Burndown, 500 * time.Millisecond},
+		{http.MethodGet, "/reports/throughput", handler.Throughput, 500 * time.Millisecond},
+		{http.MethodGet, "/reports/workload", handler.Workload, 500 * time.Millisecond},
+	}
+}
+
+// slowReport serves the latency budget report. ?hours= selects the window
+// (default 24, at most a week).
+func slowReport(handler *handlers.TaskHandler, tracker *latency.Tracker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hours := 24
+		if v := r.URL.Query().Get("hours"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > int(latency.MaxWindow/time.Hour) {
+				handler.Error(w, r, http.StatusBadRequest, handlers.CodeInvalidQuery,
+					fmt.Sprintf("hours must be between 1 and %d", int(latency.MaxWindow/time.Hour)))
+				return
+			}
+			hours = n
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(tracker.Report(time.Duration(hours) * time.Hour))
+	}
+}
+
+// maxAuditLimit caps the entries one /audit request returns
+const maxAuditLimit = 1000
+
+// auditQuery serves the audit log, newest first. ?actor=, ?workspace=,
+// ?method=, ?route= and ?result= match exactly; ?since= and ?until= take
+// RFC 3339 times; ?limit= defaults to 100.
+func auditQuery(handler *handlers.TaskHandler, log *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		f := audit.Filter{
+			Actor:     q.Get("actor"),
+			Workspace: q.Get("workspace"),
+			Method:    strings.ToUpper(q.Get("method")),
+			Route:     q.Get("route"),
+			Result:    q.Get("result"),
+			Limit:     100,
+		}
+		if f.Result != "" && f.Result != audit.ResultSuccess && f.Result != audit.ResultFailure {
+			handler.Error(w, r, http.StatusBadRequest, handlers.CodeInvalidQuery,
+				fmt.Sprintf("result must be %q or %q", audit.ResultSuccess, audit.ResultFailure))
+			return
+		}
+		for name, dst := range map[string]*time.Time{"since": &f.Since, "until": &f.Until} {
+			if v := q.Get(name); v != "" {
+				t, err := time.Parse(time.RFC3339, v)
+				if err != nil {
+					handler.Error(w, r, http.StatusBadRequest, handlers.CodeInvalidQuery,
+						name+" must be an RFC 3339 time, e.g. 2026-01-02T15:04:05Z")
+					return
+				}
+				*dst = t
+			}
+		}
+		if v := q.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > maxAuditLimit {
+				handler.Error(w, r, http.StatusBadRequest, handlers.CodeInvalidQuery,
+					fmt.Sprintf("limit must be between 1 and %d", maxAuditLimit))
+				return
+			}
+			f.Limit = n
+		}
+
+		entries, total := log.Query(f)
+		w.Header().Set(handlers.TotalCountHeader, strconv.Itoa(total))
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(entries)
 	}
 }
 
-// Run starts the HTTP server and handles graceful shutdown
-func (s *Server) Run(ctx context.Context, port string) error {
+// Handler returns the server's router, for serving it in tests
+func (s *Server) Handler() http.Handler {
+	return s.router
+}
+
+// Run starts the HTTP server on the configured address, or the listener
+// passed to WithListeners, and handles graceful shutdown. With TLS
+// configured it serves HTTPS, and optionally a plain HTTP listener that
+// redirects to it. The listeners are open before the WithReady callback
+// runs, so an address already in use is returned at once.
+func (s *Server) Run(ctx context.Context) error {
 	s.server = &http.Server{
-		Addr:         port,
+		Addr:         s.config.Addr,
 		Handler:      s.router,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		ReadTimeout:  s.config.ReadTimeout,
+		WriteTimeout: s.config.WriteTimeout,
+		IdleTimeout:  s.config.IdleTimeout,
 	}
+	servers := []*http.Server{s.server}
 
-	// Channel to listen for errors from the server
-	serverErrors := make(chan error, 1)
+	ln, err := listen(s.listener, s.config.Addr)
+	if err != nil {
+		return fmt.Errorf("server error: %w", err)
+	}
+
+	// Channel to listen for errors from the servers
+	serverErrors := make(chan error, 2)
 
-	// Start server in a goroutine
-	go func() {
-		log.Printf("Server starting on %s", port)
-		serverErrors <- s.server.ListenAndServe()
-	}()
+	tlsCfg := s.config.TLS
+	if tlsCfg.Enabled() {
+		redirect := redirectToHTTPS(s.config.Addr)
+		if len(tlsCfg.AutocertDomains) > 0 {
+			manager := autocertManager(tlsCfg)
+			s.server.TLSConfig = manager.TLSConfig()
+			redirect = manager.HTTPHandler(redirect)
+		}
+
+		if tlsCfg.RedirectAddr != "" || s.redirectListener != nil {
+			redirectLn, err := listen(s.redirectListener, tlsCfg.RedirectAddr)
+			if err != nil {
+				ln.Close()
+				return fmt.Errorf("redirect server error: %w", err)
+			}
+			redirectServer := &http.Server{
+				Addr:         tlsCfg.RedirectAddr,
+				Handler:      redirect,
+				ReadTimeout:  s.config.ReadTimeout,
+				WriteTimeout: s.config.WriteTimeout,
+				IdleTimeout:  s.config.IdleTimeout,
+			}
+			servers = append(servers, redirectServer)
+			go func() {
+				s.logger.Info("redirect server starting", slog.String("addr", redirectLn.Addr().String()))
+				serverErrors <- redirectServer.Serve(redirectLn)
+			}()
+		}
+
+		go func() {
+			s.logger.Info("server starting", slog.String("addr", ln.Addr().String()), slog.Bool("tls", true))
+			serverErrors <- s.server.ServeTLS(ln, tlsCfg.CertFile, tlsCfg.KeyFile)
+		}()
+	} else {
+		go func() {
+			s.logger.Info("server starting", slog.String("addr", ln.Addr().String()))
+			serverErrors <- s.server.Serve(ln)
+		}()
+	}
+	if s.ready != nil {
+		s.ready()
+	}
 
 	// Block until context is cancelled or server error
 	select {
 	case err := <-serverErrors:
 		if err != nil && err != http.ErrServerClosed {
+			for _, srv := range servers {
+				srv.Close()
+			}
 			return fmt.Errorf("server error: %w", err)
 		}
 	case <-ctx.Done():
-		log.Println("Shutting down server...")
+		s.logger.Info("shutting down server",
+			slog.Int64("in_flight", s.drain.start()),
+			slog.Duration("timeout", s.config.ShutdownTimeout),
+		)
 
 		// Graceful shutdown with timeout
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), s.config.ShutdownTimeout)
 		defer cancel()
+		go s.logDrain(shutdownCtx)
 
-		if err := s.server.Shutdown(shutdownCtx); err != nil {
-			return fmt.Errorf("graceful shutdown failed: %w", err)
+		for _, srv := range servers {
+			if err := srv.Shutdown(shutdownCtx); err != nil {
+				s.logger.Warn("shutdown timed out", slog.Int64("in_flight", s.drain.inFlight.Load()))
+				return fmt.Errorf("graceful shutdown failed: %w", err)
+			}
 		}
 
-		log.Println("Server stopped gracefully")
+		s.logger.Info("server stopped gracefully")
 	}
 
 	return nil
 }
+
+// listen returns ln, or a new TCP listener on addr if ln is nil
+func listen(ln net.Listener, addr string) (net.Listener, error) {
+	if ln != nil {
+		return ln, nil
+	}
+	if addr == "" {
+		addr = ":http"
+	}
+	return net.Listen("tcp", addr)
+}
+
+// drainLogInterval is how often the remaining in-flight requests are
+// logged while shutting down
+const drainLogInterval = time.Second
+
+// logDrain logs the number of in-flight requests until they have finished
+// or ctx is done
+func (s *Server) logDrain(ctx context.Context) {
+	ticker := time.NewTicker(drainLogInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n := s.drain.inFlight.Load()
+			if n == 0 {
+				return
+			}
+			s.logger.Info("waiting for in-flight requests", slog.Int64("in_flight", n))
+		}
+	}
+}
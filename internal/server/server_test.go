@@ -0,0 +1,640 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/light-bringer/cert-tasks/internal/audit"
+	"github.com/light-bringer/cert-tasks/internal/auth"
+	"github.com/light-bringer/cert-tasks/internal/config"
+	"github.com/light-bringer/cert-tasks/internal/handlers"
+	"github.com/light-bringer/cert-tasks/internal/latency"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/ratelimit"
+	"github.com/light-bringer/cert-tasks/internal/realtime"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+	"golang.org/x/net/websocket"
+)
+
+// testAdminKey is the admin key of the servers tests build with
+// WithAdminKey
+const testAdminKey = "test-admin-key-0123456789abcdef0123"
+
+// adminRequest returns a request carrying testAdminKey
+func adminRequest(method, target string, body io.Reader) *http.Request {
+	req := httptest.NewRequest(method, target, body)
+	req.Header.Set("Authorization", "Bearer "+testAdminKey)
+	return req
+}
+
+func TestServer_NotFoundAndMethodNotAllowed(t *testing.T) {
+	srv := NewServer(config.Default(false).Server, handlers.NewTaskHandler(repository.NewMemoryRepository()))
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantCode   string
+		wantAllow  string
+	}{
+		{
+			name:       "unknown route",
+			method:     "GET",
+			path:       "/nope",
+			wantStatus: http.StatusNotFound,
+			wantCode:   handlers.CodeNotFound,
+		},
+		{
+			name:       "wrong method on collection",
+			method:     "PATCH",
+			path:       "/tasks",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantCode:   handlers.CodeMethodNotAllowed,
+			wantAllow:  "GET, HEAD, POST, DELETE, OPTIONS",
+		},
+		{
+			name:       "wrong method on item",
+			method:     "POST",
+			path:       "/tasks/1",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantCode:   handlers.CodeMethodNotAllowed,
+			wantAllow:  "GET, HEAD, PUT, DELETE, OPTIONS",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			srv.router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %v, want %v", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", got)
+			}
+			if got := rec.Header().Get("Allow"); got != tt.wantAllow {
+				t.Errorf("Allow = %q, want %q", got, tt.wantAllow)
+			}
+
+			var errResp handlers.ErrorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&errResp); err != nil {
+				t.Fatalf("body is not JSON: %v", err)
+			}
+			if errResp.Code != tt.wantCode {
+				t.Errorf("code = %q, want %q", errResp.Code, tt.wantCode)
+			}
+			if id := rec.Header().Get("X-Request-ID"); id == "" || errResp.RequestID != id {
+				t.Errorf("request_id = %q, X-Request-ID = %q; want matching non-empty IDs", errResp.RequestID, id)
+			}
+		})
+	}
+}
+
+func TestServer_AdminKeyRequired(t *testing.T) {
+	// Auth is disabled in both servers; the admin key still guards the
+	// admin endpoints, which are not served without one
+	withKey := NewServer(config.Default(false).Server, handlers.NewTaskHandler(repository.NewMemoryRepository()), WithAdminKey(testAdminKey))
+	withoutKey := NewServer(config.Default(false).Server, handlers.NewTaskHandler(repository.NewMemoryRepository()))
+
+	for _, tt := range []struct {
+		name string
+		srv  *Server
+		req  *http.Request
+		want int
+	}{
+		{"no credential", withKey, httptest.NewRequest("GET", "/admin/slow-report", nil), http.StatusUnauthorized},
+		{"admin key", withKey, adminRequest("GET", "/admin/slow-report", nil), http.StatusOK},
+		{"no admin key configured", withoutKey, httptest.NewRequest("GET", "/admin/slow-report", nil), http.StatusNotFound},
+		{"workspaces without admin key configured", withoutKey, httptest.NewRequest("GET", "/workspaces", nil), http.StatusNotFound},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.srv.router.ServeHTTP(rec, tt.req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %v, want %v", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestServer_SlowReport(t *testing.T) {
+	srv := NewServer(config.Default(false).Server, handlers.NewTaskHandler(repository.NewMemoryRepository()), WithAdminKey(testAdminKey))
+
+	for i := 0; i < 2; i++ {
+		srv.router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/tasks", nil))
+	}
+
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, adminRequest("GET", "/admin/slow-report?hours=6", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %v, want %v", rec.Code, http.StatusOK)
+	}
+
+	var report latency.Report
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if report.WindowHours != 6 || len(report.Routes) != 1 {
+		t.Fatalf("report = %+v", report)
+	}
+	if got := report.Routes[0]; got.Route != "GET /tasks" || got.Requests != 2 || got.BudgetMs != 250 {
+		t.Errorf("route stats = %+v", got)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, adminRequest("GET", "/admin/slow-report?hours=0", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %v, want %v", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestServer_CORS(t *testing.T) {
+	cfg := config.Default(false).Server
+	cfg.CORS.AllowedOrigins = []string{"https://app.example.com"}
+	srv := NewServer(cfg, handlers.NewTaskHandler(repository.NewMemoryRepository()))
+
+	tests := []struct {
+		name       string
+		method     string
+		origin     string
+		preflight  bool
+		wantStatus int
+		wantOrigin string
+	}{
+		{"allowed origin", "GET", "https://app.example.com", false, http.StatusOK, "https://app.example.com"},
+		{"preflight", "OPTIONS", "https://app.example.com", true, http.StatusNoContent, "https://app.example.com"},
+		{"other origin", "GET", "https://evil.example.com", false, http.StatusOK, ""},
+		{"no origin", "GET", "", false, http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/tasks", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", "POST")
+			}
+			rec := httptest.NewRecorder()
+			srv.router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %v, want %v", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if tt.preflight && rec.Header().Get("Access-Control-Allow-Methods") != "GET, POST, DELETE" {
+				t.Errorf("Access-Control-Allow-Methods = %q, want the configured methods /tasks has", rec.Header().Get("Access-Control-Allow-Methods"))
+			}
+		})
+	}
+}
+
+func TestServer_RateLimit(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.NewMemoryStore(), ratelimit.Config{RPS: 1, Burst: 2})
+	srv := NewServer(config.Default(false).Server, handlers.NewTaskHandler(repository.NewMemoryRepository()), WithRateLimit(limiter))
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.router.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec
+	}
+
+	for i := range 2 {
+		if rec := get("/tasks"); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %v, want %v", i+1, rec.Code, http.StatusOK)
+		}
+	}
+
+	rec := get("/tasks")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %v, want %v", rec.Code, http.StatusTooManyRequests)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("429 response has no Retry-After")
+	}
+	var errResp handlers.ErrorResponse
+	json.NewDecoder(rec.Body).Decode(&errResp)
+	if errResp.Code != handlers.CodeRateLimited {
+		t.Errorf("code = %q, want %q", errResp.Code, handlers.CodeRateLimited)
+	}
+
+	// Probes stay outside the limit
+	if rec := get("/health"); rec.Code == http.StatusTooManyRequests {
+		t.Error("/health is rate limited")
+	}
+}
+
+func TestServer_RateLimitBehindProxy(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.NewMemoryStore(), ratelimit.Config{RPS: 1, Burst: 1})
+	cfg := config.Default(false).Server
+	cfg.TrustedProxies = []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	srv := NewServer(cfg, handlers.NewTaskHandler(repository.NewMemoryRepository()), WithRateLimit(limiter))
+
+	get := func(peer, forwardedFor string) int {
+		req := httptest.NewRequest("GET", "/tasks", nil)
+		req.RemoteAddr = peer
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		rec := httptest.NewRecorder()
+		srv.router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Two clients behind the same proxy have a bucket each
+	if code := get("10.0.0.2:4000", "198.51.100.1"); code != http.StatusOK {
+		t.Fatalf("first client: status = %v", code)
+	}
+	if code := get("10.0.0.2:4000", "198.51.100.2"); code != http.StatusOK {
+		t.Errorf("second client: status = %v, want 200", code)
+	}
+	if code := get("10.0.0.2:4000", "198.51.100.1"); code != http.StatusTooManyRequests {
+		t.Errorf("first client again: status = %v, want 429", code)
+	}
+
+	// Clients connecting directly cannot pick a bucket
+	if code := get("203.0.113.9:4000", "198.51.100.3"); code != http.StatusOK {
+		t.Fatalf("direct client: status = %v", code)
+	}
+	if code := get("203.0.113.9:4000", "198.51.100.4"); code != http.StatusTooManyRequests {
+		t.Errorf("direct client with another X-Forwarded-For: status = %v, want 429", code)
+	}
+}
+
+func TestServer_Auth(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	adminKey := strings.Repeat("a", 32)
+	srv := NewServer(config.Default(false).Server, handlers.NewTaskHandler(repo, handlers.WithAPIKeys(repo)),
+		WithAuth(auth.New(repo), adminKey))
+
+	do := func(method, path, credential, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if credential != "" {
+			req.Header.Set("Authorization", "Bearer "+credential)
+		}
+		rec := httptest.NewRecorder()
+		srv.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do("GET", "/tasks", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous GET /tasks: status = %v, want %v", rec.Code, http.StatusUnauthorized)
+	}
+	if rec := do("GET", "/admin/slow-report", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous GET /admin/slow-report: status = %v, want %v", rec.Code, http.StatusUnauthorized)
+	}
+
+	newKey := func(scope string) string {
+		rec := do("POST", "/apikeys", adminKey, `{"name":"test","scope":"`+scope+`"}`)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("POST /apikeys: status = %v, want %v: %s", rec.Code, http.StatusCreated, rec.Body)
+		}
+		var created models.CreatedAPIKey
+		json.NewDecoder(rec.Body).Decode(&created)
+		return created.Key
+	}
+	readKey, writeKey := newKey("read"), newKey("read_write")
+
+	if rec := do("POST", "/apikeys", writeKey, `{"name":"escalate","scope":"read_write"}`); rec.Code != http.StatusUnauthorized {
+		t.Errorf("POST /apikeys with an API key: status = %v, want %v", rec.Code, http.StatusUnauthorized)
+	}
+	if rec := do("POST", "/tasks", writeKey, `{"title":"Task"}`); rec.Code != http.StatusCreated {
+		t.Errorf("POST /tasks with read_write key: status = %v, want %v", rec.Code, http.StatusCreated)
+	}
+	if rec := do("GET", "/tasks", readKey, ""); rec.Code != http.StatusOK {
+		t.Errorf("GET /tasks with read key: status = %v, want %v", rec.Code, http.StatusOK)
+	}
+
+	rec := do("DELETE", "/tasks/1", readKey, "")
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("DELETE /tasks/1 with read key: status = %v, want %v", rec.Code, http.StatusForbidden)
+	}
+	var errResp handlers.ErrorResponse
+	json.NewDecoder(rec.Body).Decode(&errResp)
+	if errResp.Code != handlers.CodeForbidden {
+		t.Errorf("code = %q, want %q", errResp.Code, handlers.CodeForbidden)
+	}
+}
+
+func TestServer_Mount(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	status := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(repository.WorkspaceFromContext(r.Context())))
+	})
+	adminKey := strings.Repeat("a", 32)
+	srv := NewServer(config.Default(false).Server, handlers.NewTaskHandler(repo, handlers.WithAPIKeys(repo)),
+		WithAuth(auth.New(repo), adminKey), WithMount("/plugins/status", status))
+
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest("GET", "/plugins/status/", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous request: status = %v, want %v", rec.Code, http.StatusUnauthorized)
+	}
+
+	req := httptest.NewRequest("POST", "/apikeys", strings.NewReader(`{"name":"plugin","scope":"read"}`))
+	req.Header.Set("Authorization", "Bearer "+adminKey)
+	rec = httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+	var key models.CreatedAPIKey
+	json.NewDecoder(rec.Body).Decode(&key)
+
+	req = httptest.NewRequest("GET", "/plugins/status/", nil)
+	req.Header.Set("Authorization", "Bearer "+key.Key)
+	rec = httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != models.DefaultWorkspace {
+		t.Errorf("authenticated request = %v %q, want 200 in the default workspace", rec.Code, rec.Body)
+	}
+}
+
+func TestServer_JWTOwnership(t *testing.T) {
+	secret := strings.Repeat("s", 32)
+	repo := repository.NewMemoryRepository()
+	authenticator := auth.New(repo, auth.WithJWT(auth.NewJWTVerifier(auth.JWTConfig{Secret: secret})))
+	srv := NewServer(config.Default(false).Server, handlers.NewTaskHandler(repo), WithAuth(authenticator, strings.Repeat("a", 32)))
+
+	token := func(subject string) string {
+		signed, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"sub": subject,
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}).SignedString([]byte(secret))
+		return signed
+	}
+	do := func(method, path, subject, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token(subject))
+		rec := httptest.NewRecorder()
+		srv.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do("POST", "/tasks", "alice", `{"title":"Alice's task"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: status = %v, want %v", rec.Code, http.StatusCreated)
+	}
+	var created models.Task
+	json.NewDecoder(rec.Body).Decode(&created)
+	if created.OwnerID != "alice" {
+		t.Errorf("owner_id = %q, want alice", created.OwnerID)
+	}
+
+	path := "/tasks/" + strconv.FormatInt(created.ID, 10)
+	if rec := do("GET", path, "bob", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("bob GET alice's task: status = %v, want %v", rec.Code, http.StatusNotFound)
+	}
+	if rec := do("DELETE", path, "bob", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("bob DELETE alice's task: status = %v, want %v", rec.Code, http.StatusNotFound)
+	}
+	var tasks []models.Task
+	json.NewDecoder(do("GET", "/tasks", "bob", "").Body).Decode(&tasks)
+	if len(tasks) != 0 {
+		t.Errorf("bob lists %d tasks, want 0", len(tasks))
+	}
+	if rec := do("GET", path, "alice", ""); rec.Code != http.StatusOK {
+		t.Errorf("alice GET own task: status = %v, want %v", rec.Code, http.StatusOK)
+	}
+}
+
+func TestServer_Workspaces(t *testing.T) {
+	adminKey := strings.Repeat("a", 32)
+	repo := repository.NewMemoryRepository()
+	handler := handlers.NewTaskHandler(repo, handlers.WithAPIKeys(repo), handlers.WithWorkspaces(repo))
+	srv := NewServer(config.Default(false).Server, handler, WithAuth(auth.New(repo), adminKey))
+
+	do := func(method, path, credential, body string, headers ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+credential)
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		rec := httptest.NewRecorder()
+		srv.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do("POST", "/workspaces", "ctk_wrong", `{"id":"acme","name":"Acme"}`); rec.Code != http.StatusUnauthorized {
+		t.Errorf("create workspace without admin key: status = %v, want %v", rec.Code, http.StatusUnauthorized)
+	}
+	if rec := do("POST", "/workspaces", adminKey, `{"id":"acme","name":"Acme"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("create workspace: status = %v, want %v: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+
+	var bound, unbound models.CreatedAPIKey
+	json.NewDecoder(do("POST", "/apikeys", adminKey, `{"name":"acme","scope":"read_write","workspace_id":"acme"}`).Body).Decode(&bound)
+	json.NewDecoder(do("POST", "/apikeys", adminKey, `{"name":"ops","scope":"read_write"}`).Body).Decode(&unbound)
+	if rec := do("POST", "/apikeys", adminKey, `{"name":"x","scope":"read","workspace_id":"globex"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("key for unknown workspace: status = %v, want %v", rec.Code, http.StatusNotFound)
+	}
+
+	rec := do("POST", "/tasks", bound.Key, `{"title":"Acme task"}`)
+	var created models.Task
+	json.NewDecoder(rec.Body).Decode(&created)
+	if rec.Code != http.StatusCreated || created.WorkspaceID != "acme" {
+		t.Fatalf("create with bound key = %v %+v, want 201 in acme", rec.Code, created)
+	}
+	path := "/tasks/" + strconv.FormatInt(created.ID, 10)
+
+	if rec := do("GET", path, bound.Key, "", handlers.WorkspaceHeader, models.DefaultWorkspace); rec.Code != http.StatusForbidden {
+		t.Errorf("bound key naming another workspace: status = %v, want %v", rec.Code, http.StatusForbidden)
+	}
+	if rec := do("GET", path, unbound.Key, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("default workspace GET acme task: status = %v, want %v", rec.Code, http.StatusNotFound)
+	}
+	if rec := do("GET", path, unbound.Key, "", handlers.WorkspaceHeader, "acme"); rec.Code != http.StatusOK {
+		t.Errorf("unbound key selecting acme: status = %v, want %v", rec.Code, http.StatusOK)
+	}
+	if rec := do("GET", "/tasks", unbound.Key, "", handlers.WorkspaceHeader, "globex"); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown workspace: status = %v, want %v", rec.Code, http.StatusNotFound)
+	}
+	if rec := do("DELETE", "/workspaces/acme", adminKey, ""); rec.Code != http.StatusConflict {
+		t.Errorf("delete non-empty workspace: status = %v, want %v", rec.Code, http.StatusConflict)
+	}
+}
+
+func TestServer_Audit(t *testing.T) {
+	adminKey := strings.Repeat("a", 32)
+	repo := repository.NewMemoryRepository()
+	handler := handlers.NewTaskHandler(repo, handlers.WithAPIKeys(repo), handlers.WithWorkspaces(repo))
+	srv := NewServer(config.Default(false).Server, handler, WithAuth(auth.New(repo), adminKey), WithAudit(audit.New(100)))
+
+	do := func(method, path, credential, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+credential)
+		rec := httptest.NewRecorder()
+		srv.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	var key models.CreatedAPIKey
+	json.NewDecoder(do("POST", "/apikeys", adminKey, `{"name":"ci","scope":"read_write"}`).Body).Decode(&key)
+	do("POST", "/tasks", key.Key, `{"title":"Audited"}`)
+	do("POST", "/tasks", key.Key, `{"title":""}`)
+	do("GET", "/tasks", key.Key, "")
+
+	if rec := do("GET", "/audit", key.Key, ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("GET /audit with an API key: status = %v, want %v", rec.Code, http.StatusUnauthorized)
+	}
+
+	var entries []audit.Entry
+	json.NewDecoder(do("GET", "/audit?actor=key:"+strconv.FormatInt(key.ID, 10), adminKey, "").Body).Decode(&entries)
+	if len(entries) != 2 || entries[0].Result != audit.ResultFailure || entries[1].Result != audit.ResultSuccess {
+		t.Fatalf("key entries = %+v, want the failed and the successful create", entries)
+	}
+	if entries[1].Route != "/tasks" || entries[1].Workspace != "default" || entries[1].Status != http.StatusCreated {
+		t.Errorf("create entry = %+v", entries[1])
+	}
+
+	json.NewDecoder(do("GET", "/audit?actor=admin&route=/apikeys", adminKey, "").Body).Decode(&entries)
+	if len(entries) != 1 || entries[0].Method != "POST" {
+		t.Errorf("admin entries = %+v, want the key creation", entries)
+	}
+
+	// The total counts every match, not only the page
+	rec := do("GET", "/audit?limit=1", adminKey, "")
+	json.NewDecoder(rec.Body).Decode(&entries)
+	if len(entries) != 1 || rec.Header().Get(handlers.TotalCountHeader) != "3" {
+		t.Errorf("limit=1: %d entries, %s %q, want 1 entry of 3", len(entries), handlers.TotalCountHeader, rec.Header().Get(handlers.TotalCountHeader))
+	}
+	if rec := do("GET", "/audit?since=yesterday", adminKey, ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad since: status = %v, want %v", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestServer_Realtime(t *testing.T) {
+	adminKey := strings.Repeat("a", 32)
+	repo := repository.NewMemoryRepository()
+	hub := realtime.NewHub(realtime.DefaultConfig())
+	handler := handlers.NewTaskHandler(repository.NewNotifyingRepository(repo, hub.Publish), handlers.WithAPIKeys(repo))
+	srv := NewServer(config.Default(false).Server, handler, WithAuth(auth.New(repo), adminKey), WithRealtime(hub))
+	ts := httptest.NewServer(srv.router)
+	defer ts.Close()
+	defer hub.Close()
+
+	newKey := func(scope string) string {
+		req := httptest.NewRequest("POST", "/apikeys", strings.NewReader(`{"name":"ws","scope":"`+scope+`"}`))
+		req.Header.Set("Authorization", "Bearer "+adminKey)
+		rec := httptest.NewRecorder()
+		srv.router.ServeHTTP(rec, req)
+		var key models.CreatedAPIKey
+		json.NewDecoder(rec.Body).Decode(&key)
+		return key.Key
+	}
+	dial := func(token string) (*websocket.Conn, error) {
+		return websocket.Dial(strings.Replace(ts.URL, "http", "ws", 1)+"/ws?access_token="+token, "", ts.URL)
+	}
+	result := func(conn *websocket.Conn, msg realtime.ClientMessage) (event, res realtime.ServerMessage) {
+		t.Helper()
+		websocket.JSON.Send(conn, msg)
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		for res.Type != realtime.TypeResult {
+			var m realtime.ServerMessage
+			if err := websocket.JSON.Receive(conn, &m); err != nil {
+				t.Fatalf("receive: %v", err)
+			}
+			if m.Type == realtime.TypeEvent {
+				event = m
+			} else {
+				res = m
+			}
+		}
+		return event, res
+	}
+
+	if conn, err := dial("tsk_wrong"); err == nil {
+		conn.Close()
+		t.Error("dial with a bad token succeeded, want 401")
+	}
+
+	reader, err := dial(newKey("read"))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer reader.Close()
+	if _, res := result(reader, realtime.ClientMessage{Type: realtime.TypeCreate, Task: json.RawMessage(`{"title":"Nope"}`)}); res.Status != http.StatusForbidden {
+		t.Errorf("create with a read key: status = %v, want %v", res.Status, http.StatusForbidden)
+	}
+
+	writer, err := dial(newKey("read_write"))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer writer.Close()
+	event, res := result(writer, realtime.ClientMessage{Type: realtime.TypeCreate, Ref: "1", Task: json.RawMessage(`{"title":"Live"}`)})
+	if res.Status != http.StatusCreated || res.Ref != "1" {
+		t.Fatalf("create result = %+v, want 201", res)
+	}
+	if event.Event == nil || event.Event.Type != models.EventTaskCreated || event.Event.Task.Title != "Live" {
+		t.Errorf("event = %+v, want task.created for the new task", event)
+	}
+	if _, res := result(writer, realtime.ClientMessage{Type: realtime.TypeUpdate, ID: 1, Task: json.RawMessage(`{"title":""}`)}); res.Status != http.StatusUnprocessableEntity {
+		t.Errorf("invalid update: status = %v, want %v", res.Status, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestServer_RunOnListener(t *testing.T) {
+	// As systemd passes it: bound before the server starts
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ready := make(chan struct{})
+	cfg := config.Default(false).Server
+	cfg.Addr = "127.0.0.1:1" // not used
+	srv := NewServer(cfg, handlers.NewTaskHandler(repository.NewMemoryRepository()),
+		WithListeners(ln, nil),
+		WithReady(func() { close(ready) }),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Run(ctx) }()
+	select {
+	case <-ready:
+	case err := <-done:
+		t.Fatalf("Run() = %v before it was ready", err)
+	}
+
+	// Ready means accepting: no retries needed
+	resp, err := http.Get("http://" + ln.Addr().String() + "/tasks")
+	if err != nil {
+		t.Fatalf("GET /tasks after ready: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %v, want 200", resp.StatusCode)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Run() = %v", err)
+	}
+}
+
+func TestServer_RunAddressInUse(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	cfg := config.Default(false).Server
+	cfg.Addr = ln.Addr().String()
+	readied := false
+	srv := NewServer(cfg, handlers.NewTaskHandler(repository.NewMemoryRepository()), WithReady(func() { readied = true }))
+
+	if err := srv.Run(context.Background()); err == nil || readied {
+		t.Errorf("Run() = %v, ready = %v; want an error before ready", err, readied)
+	}
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/handlers"
+	"github.com/light-bringer/cert-tasks/internal/logging"
+)
+
+// bulkRoutes are the task routes that move many tasks at once and get the
+// bulk request timeout instead of the task one
+var bulkRoutes = map[string]bool{
+	http.MethodGet + " /tasks/export":  true,
+	http.MethodPost + " /tasks/import": true,
+	http.MethodDelete + " /tasks":      true,
+}
+
+// timeout gives each request d to complete. Its context is cancelled at
+// the deadline, and a client still waiting for the response gets a 503
+// instead of a connection held open until the write timeout. A response
+// already under way cannot be replaced, so it is cut off. The handler may
+// keep running until it notices the cancellation; what it writes after
+// the deadline is discarded. A zero d sets no deadline.
+//
+//api:changelog 0.2.0 added error request_timeout: Requests still running at their route group's deadline (SERVER_REQUEST_TIMEOUT, SERVER_BULK_REQUEST_TIMEOUT, SERVER_ADMIN_REQUEST_TIMEOUT) are cancelled and get 503
+func timeout(handler *handlers.TaskHandler, d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if d <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			r = r.WithContext(ctx)
+
+			tw := &timeoutWriter{w: w, h: w.Header().Clone()}
+			done := make(chan struct{})
+			panicked := make(chan any, 1)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicked <- p
+					}
+				}()
+				next.ServeHTTP(tw, r)
+				close(done)
+			}()
+
+			select {
+			case <-done:
+				tw.finish()
+			case p := <-panicked:
+				// Re-raised here, where the recoverer can catch it. Once
+				// the request has timed out nobody is left to, and the
+				// panic is dropped with the handler's later writes.
+				panic(p)
+			case <-ctx.Done():
+				started := tw.timeOut(func() {
+					handler.Error(w, r, http.StatusServiceUnavailable, handlers.CodeRequestTimeout,
+						fmt.Sprintf("the request did not complete within %s and was cancelled", d))
+				})
+				logging.FromContext(r.Context()).Warn("request timed out",
+					slog.Duration("timeout", d), slog.Bool("response_started", started))
+			}
+		})
+	}
+}
+
+// timeoutWriter passes a handler's response through until the request
+// times out. The handler gets its own header map, copied out when the
+// response starts, so that a 503 sent at the deadline carries none of the
+// headers it had set.
+type timeoutWriter struct {
+	w http.ResponseWriter
+	h http.Header
+
+	// mu serializes writes from the handler's goroutine with the timeout
+	mu       sync.Mutex
+	started  bool
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.h
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return
+	}
+	tw.start()
+	tw.w.WriteHeader(status)
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.start()
+	return tw.w.Write(p)
+}
+
+// Flush sends what has been written so far, keeping streamed responses
+// streamed
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return
+	}
+	tw.start()
+	http.NewResponseController(tw.w).Flush()
+}
+
+// start copies the handler's headers out before the first write. mu must
+// be held.
+func (tw *timeoutWriter) start() {
+	if tw.started {
+		return
+	}
+	tw.started = true
+	dst := tw.w.Header()
+	clear(dst)
+	maps.Copy(dst, tw.h)
+}
+
+// finish copies the headers of a handler that returned without writing
+func (tw *timeoutWriter) finish() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.start()
+}
+
+// timeOut stops the handler's writes and, if its response has not
+// started, sends the one respond writes instead. A started response is cut
+// off by expiring the connection's write deadline, which also unblocks a
+// write stuck on a slow client. It reports whether the response had
+// started.
+func (tw *timeoutWriter) timeOut(respond func()) bool {
+	expire := func() { http.NewResponseController(tw.w).SetWriteDeadline(time.Now()) }
+	if !tw.mu.TryLock() {
+		// A write is under way and may be stuck
+		expire()
+		tw.mu.Lock()
+	}
+	defer tw.mu.Unlock()
+
+	tw.timedOut = true
+	if tw.started {
+		expire()
+		return true
+	}
+	respond()
+	return false
+}
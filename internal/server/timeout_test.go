@@ -0,0 +1,138 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/handlers"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+func TestTimeout(t *testing.T) {
+	handler := handlers.NewTaskHandler(repository.NewMemoryRepository())
+
+	t.Run("fast requests pass through", func(t *testing.T) {
+		h := timeout(handler, time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := r.Context().Deadline(); !ok {
+				t.Error("request context has no deadline")
+			}
+			w.Header().Set("ETag", `"1"`)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{}`))
+		}))
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("POST", "/tasks", nil))
+		if rec.Code != http.StatusCreated {
+			t.Errorf("status = %v, want %v", rec.Code, http.StatusCreated)
+		}
+		if got := rec.Header().Get("ETag"); got != `"1"` {
+			t.Errorf("ETag = %q, want %q", got, `"1"`)
+		}
+		if rec.Body.String() != `{}` {
+			t.Errorf("body = %q, want {}", rec.Body)
+		}
+	})
+
+	t.Run("headers of a handler that writes nothing", func(t *testing.T) {
+		h := timeout(handler, time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Handled", "yes")
+		}))
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/tasks", nil))
+		if got := rec.Header().Get("X-Handled"); got != "yes" {
+			t.Errorf("X-Handled = %q, want yes", got)
+		}
+	})
+
+	t.Run("slow requests get 503", func(t *testing.T) {
+		lateWrite := make(chan error, 1)
+		h := timeout(handler, 20*time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"1"`)
+			<-r.Context().Done()
+			time.Sleep(10 * time.Millisecond)
+			_, err := w.Write([]byte(`{}`))
+			lateWrite <- err
+		}))
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/tasks", nil))
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("status = %v, want %v", rec.Code, http.StatusServiceUnavailable)
+		}
+		if got := rec.Header().Get("ETag"); got != "" {
+			t.Errorf("ETag = %q, want none on the timeout response", got)
+		}
+		var errResp handlers.ErrorResponse
+		json.NewDecoder(rec.Body).Decode(&errResp)
+		if errResp.Code != handlers.CodeRequestTimeout {
+			t.Errorf("code = %q, want %q", errResp.Code, handlers.CodeRequestTimeout)
+		}
+
+		if err := <-lateWrite; !errors.Is(err, http.ErrHandlerTimeout) {
+			t.Errorf("write after the timeout = %v, want %v", err, http.ErrHandlerTimeout)
+		}
+	})
+
+	t.Run("started responses are cut off", func(t *testing.T) {
+		srv := httptest.NewServer(timeout(handler, 50*time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`[`))
+			http.NewResponseController(w).Flush()
+			<-r.Context().Done()
+		})))
+		defer srv.Close()
+
+		resp, err := http.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("GET: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("status = %v, want %v", resp.StatusCode, http.StatusOK)
+		}
+		if _, err := io.ReadAll(resp.Body); err == nil {
+			t.Error("reading the body succeeded, want it cut off")
+		}
+	})
+
+	t.Run("panics reach the recoverer", func(t *testing.T) {
+		h := timeout(handler, time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		}))
+
+		defer func() {
+			if p := recover(); p != "boom" {
+				t.Errorf("recovered %v, want boom", p)
+			}
+		}()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/tasks", nil))
+	})
+
+	t.Run("zero disables the timeout", func(t *testing.T) {
+		h := timeout(handler, 0)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := r.Context().Deadline(); ok {
+				t.Error("request context has a deadline")
+			}
+		}))
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/tasks", nil))
+	})
+}
+
+func TestBulkRoutes_AreRegistered(t *testing.T) {
+	registered := make(map[string]bool)
+	for _, rt := range taskRoutes(handlers.NewTaskHandler(repository.NewMemoryRepository())) {
+		registered[rt.method+" "+rt.pattern] = true
+	}
+	for route := range bulkRoutes {
+		if !registered[route] {
+			t.Errorf("bulk route %s is not a task route", route)
+		}
+	}
+}
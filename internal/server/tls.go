@@ -0,0 +1,43 @@
+package server
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/light-bringer/cert-tasks/internal/config"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// autocertManager obtains and renews certificates for the configured
+// domains over ACME, caching them on disk
+func autocertManager(cfg config.TLS) *autocert.Manager {
+	return &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(cfg.AutocertDomains...),
+		Cache:      autocert.DirCache(cfg.AutocertCacheDir),
+		Email:      cfg.AutocertEmail,
+	}
+}
+
+// redirectToHTTPS redirects plain HTTP requests to the same URL on the
+// HTTPS listener at httpsAddr. Only GET and HEAD may be downgraded to a
+// 301; other methods get a 308 so clients repeat the body.
+func redirectToHTTPS(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+
+		status := http.StatusPermanentRedirect
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			status = http.StatusMovedPermanently
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), status)
+	})
+}
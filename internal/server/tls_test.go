@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/config"
+	"github.com/light-bringer/cert-tasks/internal/handlers"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		name       string
+		httpsAddr  string
+		method     string
+		target     string
+		wantStatus int
+		wantURL    string
+	}{
+		{"default port", ":443", "GET", "http://example.com/tasks?limit=5", http.StatusMovedPermanently, "https://example.com/tasks?limit=5"},
+		{"drops plain port", ":443", "GET", "http://example.com:80/tasks", http.StatusMovedPermanently, "https://example.com/tasks"},
+		{"custom port", ":8443", "HEAD", "http://example.com/tasks/1", http.StatusMovedPermanently, "https://example.com:8443/tasks/1"},
+		{"keeps method", ":443", "POST", "http://example.com/tasks", http.StatusPermanentRedirect, "https://example.com/tasks"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			redirectToHTTPS(tt.httpsAddr).ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %v, want %v", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Location"); got != tt.wantURL {
+				t.Errorf("Location = %q, want %q", got, tt.wantURL)
+			}
+		})
+	}
+}
+
+func TestServer_RunTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeSelfSigned(t, dir)
+
+	cfg := config.Default(false).Server
+	cfg.Addr = freeAddr(t)
+	cfg.TLS = config.TLS{CertFile: certFile, KeyFile: keyFile, RedirectAddr: freeAddr(t)}
+	srv := NewServer(cfg, handlers.NewTaskHandler(repository.NewMemoryRepository()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Run(ctx) }()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Run() = %v", err)
+		}
+	}()
+
+	client := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp := waitFor(t, client, "https://"+cfg.Addr+"/tasks")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.TLS == nil {
+		t.Errorf("HTTPS status = %v, TLS = %v; want 200 over TLS", resp.StatusCode, resp.TLS != nil)
+	}
+
+	resp = waitFor(t, client, "http://"+cfg.TLS.RedirectAddr+"/tasks")
+	resp.Body.Close()
+	_, port, _ := net.SplitHostPort(cfg.Addr)
+	if want := "https://127.0.0.1:" + port + "/tasks"; resp.Header.Get("Location") != want {
+		t.Errorf("Location = %q, want %q", resp.Header.Get("Location"), want)
+	}
+}
+
+// freeAddr returns a localhost address with a currently unused port
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+// waitFor retries GET url until the listener is up
+func waitFor(t *testing.T, client *http.Client, url string) *http.Response {
+	t.Helper()
+	var err error
+	for range 50 {
+		var resp *http.Response
+		if resp, err = client.Get(url); err == nil {
+			return resp
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("GET %s: %v", url, err)
+	return nil
+}
+
+// writeSelfSigned writes a self-signed certificate for 127.0.0.1 and its
+// key into dir
+func writeSelfSigned(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	return certFile, keyFile
+}
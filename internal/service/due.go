@@ -0,0 +1,72 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/duedate"
+	"github.com/light-bringer/cert-tasks/internal/validation"
+)
+
+// resolveDue sets *dueAt from due, a natural-language due date such as
+// "next friday 5pm" read in zone. It fails with ErrInvalid if the zone is
+// rejected, or due is ambiguous, not understood, or sent together with
+// due_at. An empty due leaves *dueAt as it is.
+//
+//api:changelog 0.2.0 added field FieldError.candidates: Times an ambiguous due could mean, reported with the ambiguous code; due and due_at together are rejected with exclusive
+func (s *Tasks) resolveDue(zone Zone, due string, dueAt **time.Time) error {
+	loc, err := zone()
+	if err != nil {
+		return invalid(err)
+	}
+	if due == "" {
+		return nil
+	}
+
+	if *dueAt != nil {
+		return invalid(validation.Errors{{
+			Field:   "due",
+			Rule:    validation.RuleExclusive,
+			Message: "due and due_at cannot both be set",
+			Params:  map[string]string{"other": "due_at"},
+		}})
+	}
+
+	t, err := duedate.Parse(due, s.now().In(loc))
+	if err != nil {
+		var perr *duedate.Error
+		if !errors.As(err, &perr) || !perr.Ambiguous() {
+			return invalid(validation.Errors{{
+				Field:   "due",
+				Rule:    validation.RuleFormat,
+				Message: fmt.Sprintf(`due %q %s; try a date such as "tomorrow 5pm", "next friday" or "in 3 days"`, due, reason(err)),
+			}})
+		}
+
+		candidates := make([]string, len(perr.Candidates))
+		for i, c := range perr.Candidates {
+			candidates[i] = c.Format(time.RFC3339)
+		}
+		return invalid(validation.Errors{{
+			Field:      "due",
+			Rule:       validation.RuleAmbiguous,
+			Message:    fmt.Sprintf("due %q could mean %s; send one as due_at or write the date unambiguously", due, strings.Join(candidates, " or ")),
+			Params:     map[string]string{"candidates": strings.Join(candidates, ", ")},
+			Candidates: candidates,
+		}})
+	}
+
+	*dueAt = &t
+	return nil
+}
+
+// reason returns why a due date was not understood
+func reason(err error) string {
+	var perr *duedate.Error
+	if errors.As(err, &perr) {
+		return perr.Reason
+	}
+	return err.Error()
+}
@@ -0,0 +1,232 @@
+// Package service holds the rules for changing tasks, between the
+// frontends and the repository: validating requests, resolving natural
+// language due dates, running the tenant's content policies and moving
+// tasks between statuses. The HTTP handlers call it, and so should any
+// other frontend, so that every way in applies the same rules.
+//
+// Events are not sent from here: they come from the repository hooks the
+// service's repository is wrapped in, so changes made through it are
+// reported like any other.
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/content"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+	"github.com/light-bringer/cert-tasks/internal/validation"
+)
+
+var (
+	// ErrInvalid wraps the validation.Errors of a request that breaks the
+	// validation rules
+	ErrInvalid = errors.New("invalid task")
+
+	// ErrContentRejected wraps the error of a content policy that rejected
+	// a title or description, usually content.Rejections
+	ErrContentRejected = errors.New("content rejected")
+)
+
+// Tasks creates and changes tasks by the rules every frontend shares.
+// Repository errors such as repository.ErrTaskNotFound are returned as
+// they are.
+type Tasks struct {
+	repo      repository.TaskRepository
+	validator *validation.Validator
+	policies  *content.PolicySet
+	now       func() time.Time
+}
+
+// Option configures Tasks
+type Option func(*Tasks)
+
+// WithValidator sets the validator requests are checked with
+func WithValidator(v *validation.Validator) Option {
+	return func(s *Tasks) {
+		s.validator = v
+	}
+}
+
+// WithContentPolicies runs the tenant's content pipeline on every title
+// and description, rejecting or rewriting them
+func WithContentPolicies(policies *content.PolicySet) Option {
+	return func(s *Tasks) {
+		s.policies = policies
+	}
+}
+
+// NewTasks creates Tasks storing tasks in repo
+func NewTasks(repo repository.TaskRepository, opts ...Option) *Tasks {
+	s := &Tasks{
+		repo:      repo,
+		validator: validation.Default(),
+		now:       time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Zone returns the time zone natural language due dates are read in. The
+// service calls it once a request is otherwise valid; an error, usually
+// validation.Errors, rejects the request.
+type Zone func() (*time.Location, error)
+
+// In returns a Zone that is always loc
+func In(loc *time.Location) Zone {
+	return func() (*time.Location, error) { return loc, nil }
+}
+
+type tenantKey struct{}
+
+// WithTenant returns a context whose content policies are those of tenant.
+// Without it, the workspace the context is scoped to selects them.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// tenant returns the tenant whose content policies apply in ctx
+func tenant(ctx context.Context) string {
+	if t, ok := ctx.Value(tenantKey{}).(string); ok {
+		return t
+	}
+	return repository.WorkspaceFromContext(ctx)
+}
+
+// Create validates req and creates its task. A natural language due date
+// is read in zone.
+func (s *Tasks) Create(ctx context.Context, req models.CreateTaskRequest, zone Zone) (*models.Task, error) {
+	if err := s.validator.ValidateCreate(&req); err != nil {
+		return nil, invalid(err)
+	}
+	if err := s.resolveDue(zone, req.Due, &req.DueAt); err != nil {
+		return nil, err
+	}
+	c, err := s.checkContent(ctx, req.Title, req.Description)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.Create(ctx, &models.Task{
+		Title:       c.Title,
+		Description: c.Description,
+		DueAt:       req.DueAt,
+		Estimate:    req.Estimate,
+	})
+}
+
+// Get returns a task
+func (s *Tasks) Get(ctx context.Context, id int64) (*models.Task, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+// Update validates req and replaces the task's fields with it. A natural
+// language due date is read in zone.
+func (s *Tasks) Update(ctx context.Context, id int64, req models.UpdateTaskRequest, zone Zone) (*models.Task, error) {
+	if err := s.validator.ValidateUpdate(&req); err != nil {
+		return nil, invalid(err)
+	}
+	if err := s.resolveDue(zone, req.Due, &req.DueAt); err != nil {
+		return nil, err
+	}
+	c, err := s.checkContent(ctx, req.Title, req.Description)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.Update(ctx, id, &models.Task{
+		Title:       c.Title,
+		Description: c.Description,
+		Status:      req.Status,
+		DueAt:       req.DueAt,
+		Estimate:    req.Estimate,
+	})
+}
+
+// SetStatus moves a task to status, leaving the rest of it alone. A task
+// already in the status is returned unchanged.
+func (s *Tasks) SetStatus(ctx context.Context, id int64, status models.TaskStatus) (*models.Task, error) {
+	if err := s.validator.ValidateStatus(status); err != nil {
+		return nil, invalid(err)
+	}
+	return s.repo.SetStatus(ctx, id, status)
+}
+
+// Complete marks a task done
+func (s *Tasks) Complete(ctx context.Context, id int64) (*models.Task, error) {
+	return s.SetStatus(ctx, id, models.StatusDone)
+}
+
+// Reopen marks a task todo again
+func (s *Tasks) Reopen(ctx context.Context, id int64) (*models.Task, error) {
+	return s.SetStatus(ctx, id, models.StatusTodo)
+}
+
+// Delete deletes a task and returns a copy of it as it was, which can be
+// put back with the repository's Undelete
+func (s *Tasks) Delete(ctx context.Context, id int64) (*models.Task, error) {
+	task, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	deleted := *task
+	deleted.Links = slices.Clone(task.Links)
+
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return nil, err
+	}
+	return &deleted, nil
+}
+
+// Link validates req and adds its link to a task
+func (s *Tasks) Link(ctx context.Context, id int64, req models.CreateLinkRequest) (*models.Task, error) {
+	if err := s.validator.ValidateLink(&req); err != nil {
+		return nil, invalid(err)
+	}
+	return s.repo.AddLink(ctx, id, models.TaskLink{
+		Type:   req.Type,
+		TaskID: req.TaskID,
+	})
+}
+
+// Import validates a task described as an update would, in any status,
+// and creates it. With dryRun it only checks the task, returning nil for
+// one that would be created.
+func (s *Tasks) Import(ctx context.Context, req models.UpdateTaskRequest, dryRun bool) (*models.Task, error) {
+	if err := s.validator.ValidateUpdate(&req); err != nil {
+		return nil, invalid(err)
+	}
+	c, err := s.checkContent(ctx, req.Title, req.Description)
+	if err != nil || dryRun {
+		return nil, err
+	}
+
+	return s.repo.Create(ctx, &models.Task{
+		Title:       c.Title,
+		Description: c.Description,
+		Status:      req.Status,
+		DueAt:       req.DueAt,
+		Estimate:    req.Estimate,
+	})
+}
+
+// checkContent runs the tenant's content pipeline on a title and
+// description, returning them as the pipeline left them
+func (s *Tasks) checkContent(ctx context.Context, title, description string) (content.Content, error) {
+	c := content.Content{Title: title, Description: description}
+	if err := s.policies.For(tenant(ctx)).Run(&c); err != nil {
+		return c, fmt.Errorf("%w: %w", ErrContentRejected, err)
+	}
+	return c, nil
+}
+
+// invalid wraps a validation error in ErrInvalid
+func invalid(err error) error {
+	return fmt.Errorf("%w: %w", ErrInvalid, err)
+}
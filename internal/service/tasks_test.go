@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/content"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+	"github.com/light-bringer/cert-tasks/internal/validation"
+)
+
+func TestTasks_Create(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewTasks(repository.NewMemoryRepository(), WithContentPolicies(&content.PolicySet{
+		Default: content.Pipeline{content.MaxLinks{Max: 0}},
+		Tenants: map[string]content.Pipeline{"acme": nil},
+	}))
+	s.now = func() time.Time { return time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC) } // a Monday
+	ctx := context.Background()
+
+	task, err := s.Create(ctx, models.CreateTaskRequest{Title: "  report  ", Due: "tomorrow 5pm"}, In(tokyo))
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if task.Title != "report" {
+		t.Errorf("Title = %q, want it normalized", task.Title)
+	}
+	if want := time.Date(2026, 3, 3, 17, 0, 0, 0, tokyo); task.DueAt == nil || !task.DueAt.Equal(want) {
+		t.Errorf("DueAt = %v, want %v", task.DueAt, want)
+	}
+
+	var verrs validation.Errors
+	_, err = s.Create(ctx, models.CreateTaskRequest{Title: ""}, In(time.UTC))
+	if !errors.Is(err, ErrInvalid) || !errors.As(err, &verrs) || verrs[0].Field != "title" {
+		t.Errorf("Create(no title) error = %v, want ErrInvalid with the title violation", err)
+	}
+
+	zoneErr := validation.Errors{{Field: "X-Timezone", Rule: validation.RuleFormat}}
+	_, err = s.Create(ctx, models.CreateTaskRequest{Title: "t"}, func() (*time.Location, error) { return nil, zoneErr })
+	if !errors.Is(err, ErrInvalid) {
+		t.Errorf("Create(bad zone) error = %v, want ErrInvalid", err)
+	}
+
+	link := models.CreateTaskRequest{Title: "t", Description: "see https://example.com"}
+	var rejections content.Rejections
+	if _, err := s.Create(ctx, link, In(time.UTC)); !errors.Is(err, ErrContentRejected) || !errors.As(err, &rejections) {
+		t.Errorf("Create(link) error = %v, want ErrContentRejected with the rejections", err)
+	}
+	if _, err := s.Create(WithTenant(ctx, "acme"), link, In(time.UTC)); err != nil {
+		t.Errorf("Create(link) for a tenant without policies error = %v", err)
+	}
+}
+
+func TestTasks_StatusAndDelete(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	s := NewTasks(repo)
+	ctx := context.Background()
+	task, err := s.Create(ctx, models.CreateTaskRequest{Title: "t"}, In(time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if done, err := s.Complete(ctx, task.ID); err != nil || done.Status != models.StatusDone {
+		t.Errorf("Complete() = %v, %v", done, err)
+	}
+	if _, err := s.SetStatus(ctx, task.ID, "archived"); !errors.Is(err, ErrInvalid) {
+		t.Errorf("SetStatus(archived) error = %v, want ErrInvalid", err)
+	}
+
+	deleted, err := s.Delete(ctx, task.ID)
+	if err != nil || deleted.ID != task.ID || deleted.Status != models.StatusDone {
+		t.Fatalf("Delete() = %v, %v, want the task as it was", deleted, err)
+	}
+	if _, err := s.Get(ctx, task.ID); !errors.Is(err, repository.ErrTaskNotFound) {
+		t.Errorf("Get() after delete error = %v, want ErrTaskNotFound", err)
+	}
+	if _, err := s.Delete(ctx, task.ID); !errors.Is(err, repository.ErrTaskNotFound) {
+		t.Errorf("Delete() twice error = %v, want ErrTaskNotFound", err)
+	}
+}
+
+func TestTasks_Import(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	s := NewTasks(repo)
+	ctx := context.Background()
+	req := models.UpdateTaskRequest{Title: "t", Status: models.StatusDone}
+
+	if task, err := s.Import(ctx, req, true); task != nil || err != nil {
+		t.Errorf("Import(dry run) = %v, %v, want nothing created", task, err)
+	}
+	if n, _ := repo.Count(ctx, repository.TaskFilter{}); n != 0 {
+		t.Errorf("dry run stored %d tasks", n)
+	}
+	task, err := s.Import(ctx, req, false)
+	if err != nil || task.Status != models.StatusDone {
+		t.Errorf("Import() = %v, %v, want a done task", task, err)
+	}
+	if _, err := s.Import(ctx, models.UpdateTaskRequest{Title: "t"}, true); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Import(no status) error = %v, want ErrInvalid", err)
+	}
+}
@@ -0,0 +1,242 @@
+package startup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/config"
+	"github.com/light-bringer/cert-tasks/internal/content"
+	"github.com/light-bringer/cert-tasks/internal/encryption"
+	"github.com/light-bringer/cert-tasks/internal/outbound"
+	"github.com/light-bringer/cert-tasks/internal/personal"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+// ResolveTimeout bounds the DNS lookup of each webhook host
+const ResolveTimeout = 3 * time.Second
+
+// CheckConfig validates the configuration and the resources it points at
+// without starting the server, printing one line per check: the listen
+// addresses, TLS material, content policies, the storage and the webhook
+// URLs. It returns false if any check failed; warnings about features left
+// off, or about hosts that do not resolve from where the check runs, do
+// not count. api --check and taskctl config validate both run it.
+func CheckConfig(out io.Writer, cfg *config.Config, loadErrs []error) bool {
+	ok := true
+	report := func(name string, err error) {
+		if err != nil {
+			ok = false
+			fmt.Fprintf(out, "FAIL  %s: %v\n", name, err)
+			return
+		}
+		fmt.Fprintf(out, "ok    %s\n", name)
+	}
+	warn := func(name, message string) {
+		fmt.Fprintf(out, "WARN  %s: %s\n", name, message)
+	}
+
+	for _, err := range loadErrs {
+		report("configuration", err)
+	}
+	if len(loadErrs) == 0 {
+		report("configuration", nil)
+	}
+
+	// The listen address must be free, otherwise the server dies on start
+	addr := cfg.Server.Addr
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		err = fmt.Errorf("cannot listen on %s: %w (stop the other process or set PORT)", addr, err)
+	} else {
+		ln.Close()
+	}
+	report("listen address "+addr, err)
+
+	if adminAddr := cfg.Server.AdminAddr; adminAddr != "" {
+		ln, err := net.Listen("tcp", adminAddr)
+		if err != nil {
+			err = fmt.Errorf("cannot listen on %s: %w (stop the other process or change ADMIN_ADDR)", adminAddr, err)
+		} else {
+			ln.Close()
+		}
+		report("admin address "+adminAddr, err)
+	}
+
+	if tlsCfg := cfg.Server.TLS; tlsCfg.Enabled() {
+		name := "TLS autocert cache"
+		if tlsCfg.CertFile != "" {
+			name = "TLS certificate " + tlsCfg.CertFile
+		}
+		report(name, TLS(tlsCfg))
+	}
+
+	if redirectAddr := cfg.Server.TLS.RedirectAddr; redirectAddr != "" {
+		ln, err := net.Listen("tcp", redirectAddr)
+		if err != nil {
+			err = fmt.Errorf("cannot listen on %s: %w (stop the other process or change TLS_REDIRECT_ADDR)", redirectAddr, err)
+		} else {
+			ln.Close()
+		}
+		report("redirect address "+redirectAddr, err)
+	}
+
+	if path := cfg.Content.PolicyFile; path != "" {
+		report("content policies "+path, checkContentPolicies(path))
+	}
+
+	if cfg.Personal {
+		report("personal data directory", checkPersonalDir())
+	} else if cfg.Auth.AdminKey == "" {
+		warn("admin key", "not set, so the admin endpoints are not served (set AUTH_ADMIN_KEY)")
+	}
+
+	// The DSN and keys of the storage come from the configuration, so it
+	// is only opened once that is valid
+	if len(loadErrs) > 0 {
+		return ok
+	}
+	name, repo, err := checkStorage(cfg)
+	report(name, err)
+
+	// Only the host is printed: webhook paths and queries often carry a
+	// token. Demo mode delivers no webhooks.
+	ctx := context.Background()
+	for _, c := range cfg.Notifications.Connectors {
+		name := c.Kind + " connector " + c.Name
+		if _, host, err := resolve(ctx, c.URL); err != nil {
+			warn(name, err.Error())
+		} else {
+			report(name+" "+host, nil)
+		}
+	}
+	if repo != nil && !cfg.Demo.Enabled {
+		for _, hook := range repo.WebhookSnapshot() {
+			name := fmt.Sprintf("webhook %d", hook.ID)
+			addrs, host, err := resolve(ctx, hook.URL)
+			if err != nil {
+				warn(name, err.Error())
+				continue
+			}
+			report(name+" "+host, checkWebhookAddrs(addrs, cfg.Webhooks.AllowedNetworks))
+		}
+	}
+
+	return ok
+}
+
+// checkContentPolicies loads the content policy file
+func checkContentPolicies(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("%w (check CONTENT_POLICY_FILE)", err)
+	}
+	defer f.Close()
+
+	_, err = content.LoadPolicies(f)
+	return err
+}
+
+// checkPersonalDir verifies the personal mode data directory is writable
+func checkPersonalDir() error {
+	cfg, err := personal.DefaultConfig()
+	if err != nil {
+		return err
+	}
+	if err := cfg.Prepare(); err != nil {
+		return err
+	}
+	return checkWritable(cfg.DataDir)
+}
+
+// checkWritable creates and removes a file in dir
+func checkWritable(dir string) error {
+	probe, err := os.CreateTemp(dir, ".check-*")
+	if err != nil {
+		return fmt.Errorf("%s is not writable: %w", filepath.Clean(dir), err)
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
+
+// checkStorage opens the storage the server would, without changing it,
+// and returns the check's name and, for a snapshot file, the repository
+// read from it. A missing snapshot is created by the server, so only its
+// directory must be writable; an existing one must load with the
+// configured keys and need no migration the server is not allowed to do.
+func checkStorage(cfg *config.Config) (string, *repository.FileRepository, error) {
+	backend, path, _ := cfg.Storage.Backend() // validated by Load
+	if cfg.Personal && cfg.Storage.DSN == "" {
+		personalCfg, err := personal.DefaultConfig()
+		if err != nil {
+			return "storage", nil, err
+		}
+		backend, path = config.BackendFile, personalCfg.SnapshotPath()
+	}
+	if backend != config.BackendFile {
+		return "storage " + backend, nil, nil
+	}
+
+	name := "storage file " + path
+	if err := checkWritable(filepath.Dir(path)); err != nil {
+		return name, nil, fmt.Errorf("%w (snapshots are replaced through a temporary file next to them)", err)
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return name, nil, nil
+	}
+
+	var keys *encryption.Keyring
+	if enc := cfg.Storage.Encryption; enc.Enabled() {
+		var err error
+		if keys, err = enc.Keyring(); err != nil {
+			return name, nil, fmt.Errorf("loading encryption key: %w", err)
+		}
+	}
+	repo, err := repository.ReadSnapshot(path, keys)
+	if errors.Is(err, repository.ErrSnapshotEncrypted) {
+		err = fmt.Errorf("%w (set STORAGE_ENCRYPTION_KEY or STORAGE_ENCRYPTION_KEY_FILE)", err)
+	}
+	if err != nil {
+		return name, nil, err
+	}
+	if !cfg.Storage.Migrate {
+		if _, err := Migrate(repo, false); err != nil {
+			return name, repo, err
+		}
+	}
+	return name, repo, nil
+}
+
+// resolve looks up the host of rawURL, returning its addresses and the
+// host itself
+func resolve(ctx context.Context, rawURL string) ([]netip.Addr, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return nil, "", errors.New("not a valid URL")
+	}
+	ctx, cancel := context.WithTimeout(ctx, ResolveTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil {
+		return nil, u.Hostname(), fmt.Errorf("%s does not resolve: %w (deliveries fail until it does)", u.Hostname(), err)
+	}
+	return addrs, u.Hostname(), nil
+}
+
+// checkWebhookAddrs reports the first address webhook deliveries would be
+// refused to connect to
+func checkWebhookAddrs(addrs []netip.Addr, allowed []netip.Prefix) error {
+	for _, addr := range addrs {
+		if err := outbound.CheckAddress(addr, allowed); err != nil {
+			return fmt.Errorf("%w (delete the webhook, or add its network to WEBHOOK_ALLOWED_NETWORKS)", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package startup
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/light-bringer/cert-tasks/internal/config"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+func TestCheckConfig(t *testing.T) {
+	t.Setenv("CONTENT_POLICY_FILE", "/does/not/exist.json")
+	cfg, errs := config.Load("", false)
+	cfg.Server.Addr = "127.0.0.1:0"
+
+	var out bytes.Buffer
+	if CheckConfig(&out, cfg, errs) {
+		t.Error("CheckConfig() = true, want false for missing policy file")
+	}
+	if !strings.Contains(out.String(), "FAIL  content policies") {
+		t.Errorf("output missing policy failure:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "WARN  admin key") {
+		t.Errorf("output missing admin key warning:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "ok    listen address") {
+		t.Errorf("output missing listen check:\n%s", out.String())
+	}
+}
+
+func TestCheckConfig_DefaultsPass(t *testing.T) {
+	cfg, errs := config.Load("", false)
+	cfg.Server.Addr = "127.0.0.1:0"
+
+	var out bytes.Buffer
+	if !CheckConfig(&out, cfg, errs) {
+		t.Errorf("CheckConfig() = false for the default configuration:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "WARN  admin key") || strings.Contains(out.String(), "FAIL") {
+		t.Errorf("output = %s, want only a warning about the admin key", out.String())
+	}
+}
+
+func TestCheckConfig_Storage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	t.Setenv("STORAGE_DSN", "file://"+path)
+	load := func() *config.Config {
+		t.Helper()
+		cfg, errs := config.Load("", false)
+		if len(errs) > 0 {
+			t.Fatal(errs)
+		}
+		cfg.Server.Addr = "127.0.0.1:0"
+		return cfg
+	}
+
+	// A missing snapshot is created by the server, and not by the check
+	var out bytes.Buffer
+	if !CheckConfig(&out, load(), nil) || !strings.Contains(out.String(), "ok    storage file "+path) {
+		t.Errorf("missing snapshot:\n%s", out.String())
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("the check created the snapshot")
+	}
+
+	repo, err := repository.NewFileRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, url := range []string{"https://93.184.216.34/hook", "http://10.1.2.3/hook?token=abc"} {
+		if _, err := repo.CreateWebhook(context.Background(), &models.Webhook{URL: url}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out.Reset()
+	if CheckConfig(&out, load(), nil) {
+		t.Error("CheckConfig() = true, want false for a webhook to a private address")
+	}
+	for _, want := range []string{"ok    storage file", "ok    webhook 1 93.184.216.34", "FAIL  webhook 2 10.1.2.3: address not allowed"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "token=abc") {
+		t.Errorf("output shows a webhook query:\n%s", out.String())
+	}
+
+	t.Setenv("WEBHOOK_ALLOWED_NETWORKS", "10.1.0.0/16")
+	out.Reset()
+	if !CheckConfig(&out, load(), nil) {
+		t.Errorf("CheckConfig() = false with the network allowed:\n%s", out.String())
+	}
+
+	// A snapshot that does not load fails the check
+	os.WriteFile(path, []byte("not json"), 0o600)
+	out.Reset()
+	if CheckConfig(&out, load(), nil) || !strings.Contains(out.String(), "FAIL  storage file") {
+		t.Errorf("corrupt snapshot:\n%s", out.String())
+	}
+}
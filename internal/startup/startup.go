@@ -0,0 +1,148 @@
+// Package startup checks at boot that the server can do its job: the
+// storage answers, its format is current, and the files the configuration
+// points at are usable. A failed check stops the server before it accepts
+// connections, instead of surfacing on the first request. The checks are
+// recorded in a Report, written as JSON for deploy tooling to read.
+package startup
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/config"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+	"github.com/light-bringer/cert-tasks/internal/version"
+)
+
+// StorageTimeout bounds the storage connectivity check
+const StorageTimeout = 5 * time.Second
+
+// Check is the outcome of one check
+type Check struct {
+	Name string `json:"name"`
+	OK   bool   `json:"ok"`
+
+	// Detail says what was found, such as the snapshot version
+	Detail string `json:"detail,omitempty"`
+
+	// Error is why a failed check failed
+	Error string `json:"error,omitempty"`
+}
+
+// Report is the outcome of the checks run at startup
+type Report struct {
+	Version   version.Info `json:"version"`
+	StartedAt time.Time    `json:"started_at"`
+
+	// OK is false if any check failed, and the server did not start
+	OK     bool    `json:"ok"`
+	Checks []Check `json:"checks"`
+}
+
+// NewReport starts an empty report
+func NewReport() *Report {
+	return &Report{
+		Version:   version.Get(),
+		StartedAt: time.Now().UTC(),
+		OK:        true,
+		Checks:    []Check{},
+	}
+}
+
+// Record adds the outcome of a check and returns err, so that a check can
+// be recorded where its error is handled
+func (r *Report) Record(name, detail string, err error) error {
+	c := Check{Name: name, OK: err == nil, Detail: detail}
+	if err != nil {
+		c.Error = err.Error()
+		r.OK = false
+	}
+	r.Checks = append(r.Checks, c)
+	return err
+}
+
+// Write writes the report to w as one line of JSON
+func (r *Report) Write(w io.Writer) error {
+	return json.NewEncoder(w).Encode(r)
+}
+
+// Output writes the report where the startup_report setting says:
+// "stdout", "stderr" or a file, which is replaced. Empty writes nothing.
+func (r *Report) Output(dest string) error {
+	switch dest {
+	case "":
+		return nil
+	case "stdout":
+		return r.Write(os.Stdout)
+	case "stderr":
+		return r.Write(os.Stderr)
+	}
+	f, err := os.Create(dest)
+	if err != nil {
+		return fmt.Errorf("writing startup report: %w", err)
+	}
+	if err := r.Write(f); err != nil {
+		f.Close()
+		return fmt.Errorf("writing startup report: %w", err)
+	}
+	return f.Close()
+}
+
+// Storage checks that repo answers a query
+func Storage(ctx context.Context, repo repository.TaskRepository) error {
+	ctx, cancel := context.WithTimeout(ctx, StorageTimeout)
+	defer cancel()
+	if _, err := repo.List(ctx, repository.ListOptions{Limit: 1}); err != nil {
+		return fmt.Errorf("storage did not answer: %w", err)
+	}
+	return nil
+}
+
+// ErrMigrationPending is returned when the snapshot is in an older format
+// and migrations are turned off
+var ErrMigrationPending = errors.New("snapshot needs migrating")
+
+// Migrate brings a snapshot file up to the current format, or, when
+// migrate is false, refuses to. It returns what it found or did.
+func Migrate(repo *repository.FileRepository, migrate bool) (string, error) {
+	from := repo.FileVersion()
+	if from == repository.SnapshotVersion {
+		return fmt.Sprintf("snapshot version %d, up to date", from), nil
+	}
+	if !migrate {
+		return "", fmt.Errorf("%w from version %d to %d (back up %s, then start with STORAGE_MIGRATE=true)",
+			ErrMigrationPending, from, repository.SnapshotVersion, repo.Path())
+	}
+	if err := repo.Migrate(); err != nil {
+		return "", fmt.Errorf("migrating snapshot: %w", err)
+	}
+	return fmt.Sprintf("snapshot migrated from version %d to %d", from, repository.SnapshotVersion), nil
+}
+
+// TLS checks that the certificate and key load, and that the autocert
+// cache directory can be written
+func TLS(cfg config.TLS) error {
+	if cfg.CertFile != "" && cfg.KeyFile != "" {
+		if _, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile); err != nil {
+			return fmt.Errorf("%w (check TLS_CERT_FILE and TLS_KEY_FILE)", err)
+		}
+	}
+	if dir := cfg.AutocertCacheDir; len(cfg.AutocertDomains) > 0 && dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return fmt.Errorf("autocert cache: %w (check TLS_AUTOCERT_CACHE_DIR)", err)
+		}
+		probe, err := os.CreateTemp(dir, ".check-*")
+		if err != nil {
+			return fmt.Errorf("autocert cache %s is not writable: %w", dir, err)
+		}
+		probe.Close()
+		os.Remove(probe.Name())
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package startup
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/light-bringer/cert-tasks/internal/config"
+)
+
+func TestReport(t *testing.T) {
+	r := NewReport()
+	r.Record("storage", "memory", nil)
+	broken := errors.New("no such file")
+	if err := r.Record("tls", "", broken); err != broken {
+		t.Errorf("Record() = %v, want the check's error back", err)
+	}
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var got Report
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("report %s: %v", buf.Bytes(), err)
+	}
+	if got.OK || len(got.Checks) != 2 || !got.Checks[0].OK || got.Checks[1].Error != "no such file" {
+		t.Errorf("report = %+v, want a passed storage check and a failed tls check", got)
+	}
+}
+
+func TestTLS(t *testing.T) {
+	dir := t.TempDir()
+	if err := TLS(config.TLS{CertFile: filepath.Join(dir, "cert.pem"), KeyFile: filepath.Join(dir, "key.pem")}); err == nil {
+		t.Error("TLS() with missing files = nil, want an error")
+	}
+
+	cache := filepath.Join(dir, "autocert")
+	if err := TLS(config.TLS{AutocertDomains: []string{"example.com"}, AutocertCacheDir: cache}); err != nil {
+		t.Errorf("TLS() with a cache directory error = %v", err)
+	}
+	if _, err := os.Stat(cache); err != nil {
+		t.Errorf("cache directory not created: %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package static
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Cache-Control policies applied to static responses
+const (
+	// CacheRevalidate makes clients revalidate with the ETag on every use
+	CacheRevalidate = "no-cache"
+
+	// CacheImmutable is used for fingerprinted URLs whose content never changes
+	CacheImmutable = "public, max-age=31536000, immutable"
+)
+
+// Asset is an in-memory response body served with a precomputed strong ETag
+type Asset struct {
+	Name        string
+	ContentType string
+	Body        []byte
+	ETag        string
+	Hash        string // short content hash used in fingerprinted names
+}
+
+// NewAsset creates an asset for body. When contentType is empty it is
+// derived from the name's extension.
+func NewAsset(name, contentType string, body []byte) *Asset {
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(name))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+
+	return &Asset{
+		Name:        name,
+		ContentType: contentType,
+		Body:        body,
+		ETag:        `"` + hash + `"`,
+		Hash:        hash[:12],
+	}
+}
+
+// ServeHTTP writes the asset, revalidated on every request via its ETag
+func (a *Asset) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.serve(w, r, CacheRevalidate)
+}
+
+// serve writes the asset with the given Cache-Control policy, answering
+// conditional requests with 304 Not Modified
+func (a *Asset) serve(w http.ResponseWriter, r *http.Request, cacheControl string) {
+	w.Header().Set("Content-Type", a.ContentType)
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("ETag", a.ETag)
+
+	if etagMatches(r.Header.Get("If-None-Match"), a.ETag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write(a.Body)
+	}
+}
+
+// FS serves a tree of files loaded into memory. Every file is reachable by
+// its plain name (revalidated via ETag) and by a fingerprinted name such as
+// app.3f2a1b9c0d4e.js (cached forever), so pages can reference assets
+// through Path and never serve stale content.
+type FS struct {
+	assets      map[string]*Asset
+	fingerprint map[string]*Asset
+}
+
+// NewFS loads every file in fsys
+func NewFS(fsys fs.FS) (*FS, error) {
+	s := &FS{
+		assets:      make(map[string]*Asset),
+		fingerprint: make(map[string]*Asset),
+	}
+
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		body, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		asset := NewAsset(name, "", body)
+		s.assets[name] = asset
+		s.fingerprint[fingerprintName(name, asset.Hash)] = asset
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("loading static files: %w", err)
+	}
+
+	return s, nil
+}
+
+// Path returns the fingerprinted name for a file, or the name unchanged if
+// the file is unknown
+func (s *FS) Path(name string) string {
+	asset, ok := s.assets[name]
+	if !ok {
+		return name
+	}
+	return fingerprintName(name, asset.Hash)
+}
+
+// ServeHTTP serves the file named by the request path, which must already
+// have any mount prefix stripped
+func (s *FS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/")
+
+	if asset, ok := s.fingerprint[name]; ok {
+		asset.serve(w, r, CacheImmutable)
+		return
+	}
+	if asset, ok := s.assets[name]; ok {
+		asset.serve(w, r, CacheRevalidate)
+		return
+	}
+
+	http.NotFound(w, r)
+}
+
+// fingerprintName inserts hash before the file extension
+func fingerprintName(name, hash string) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// etagMatches reports whether an If-None-Match header matches etag
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package static
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestAsset_ConditionalGet(t *testing.T) {
+	asset := NewAsset("version.json", "", []byte(`{"version":"dev"}`))
+
+	rec := httptest.NewRecorder()
+	asset.ServeHTTP(rec, httptest.NewRequest("GET", "/version", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %v, want %v", rec.Code, http.StatusOK)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	if got := rec.Header().Get("Cache-Control"); got != CacheRevalidate {
+		t.Errorf("Cache-Control = %q, want %q", got, CacheRevalidate)
+	}
+
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected ETag header")
+	}
+
+	req := httptest.NewRequest("GET", "/version", nil)
+	req.Header.Set("If-None-Match", `"stale", `+etag)
+	rec = httptest.NewRecorder()
+	asset.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("status = %v, want %v", rec.Code, http.StatusNotModified)
+	}
+	if rec.Body.Len() != 0 {
+		t.Error("304 response should not have a body")
+	}
+}
+
+func TestFS_Fingerprinting(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app.js":    {Data: []byte("console.log('hi')")},
+		"css/a.css": {Data: []byte("body{}")},
+	}
+
+	s, err := NewFS(fsys)
+	if err != nil {
+		t.Fatalf("NewFS() error = %v", err)
+	}
+
+	fingerprinted := s.Path("app.js")
+	if fingerprinted == "app.js" {
+		t.Fatal("expected fingerprinted path")
+	}
+	if s.Path("missing.js") != "missing.js" {
+		t.Error("unknown files should keep their name")
+	}
+
+	tests := []struct {
+		name         string
+		path         string
+		wantStatus   int
+		wantCache    string
+		wantMimeType string
+	}{
+		{name: "fingerprinted", path: "/" + fingerprinted, wantStatus: http.StatusOK, wantCache: CacheImmutable, wantMimeType: "text/javascript; charset=utf-8"},
+		{name: "plain", path: "/app.js", wantStatus: http.StatusOK, wantCache: CacheRevalidate, wantMimeType: "text/javascript; charset=utf-8"},
+		{name: "nested", path: "/" + s.Path("css/a.css"), wantStatus: http.StatusOK, wantCache: CacheImmutable, wantMimeType: "text/css; charset=utf-8"},
+		{name: "missing", path: "/nope.js", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %v, want %v", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if got := rec.Header().Get("Cache-Control"); got != tt.wantCache {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.wantCache)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.wantMimeType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantMimeType)
+			}
+		})
+	}
+}
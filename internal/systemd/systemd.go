@@ -0,0 +1,107 @@
+// Package systemd integrates the server with systemd: it takes over the
+// sockets of a socket-activated service (LISTEN_FDS) and tells systemd
+// when the service is ready or stopping (sd_notify). Both are no-ops when
+// the server is not started by systemd.
+//
+// With socket activation systemd owns the listening sockets, so a restart
+// does not refuse connections: they wait in the socket's backlog until
+// the new process accepts them.
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Socket names, set with FileDescriptorName= in the socket unit. A lone
+// socket without a name is the API listener.
+const (
+	API      = "api"
+	Redirect = "redirect"
+	Admin    = "admin"
+)
+
+// Notification states
+const (
+	Ready    = "READY=1"
+	Stopping = "STOPPING=1"
+)
+
+// listenFDsStart is the first file descriptor systemd passes
+var listenFDsStart = 3
+
+// Listeners returns the sockets systemd passed to this process, by name,
+// or none when it was not socket-activated. The LISTEN_* variables are
+// unset so that child processes do not take the sockets too.
+func Listeners() (map[string]net.Listener, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil, nil
+	}
+	var names []string
+	if v := os.Getenv("LISTEN_FDNAMES"); v != "" {
+		names = strings.Split(v, ":")
+	}
+
+	listeners := make(map[string]net.Listener, n)
+	for i := range n {
+		name := "unknown"
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		if n == 1 && name == "unknown" {
+			name = API
+		}
+		if _, dup := listeners[name]; dup {
+			return nil, fmt.Errorf("two sockets are named %q; set FileDescriptorName= to %s, %s or %s", name, API, Redirect, Admin)
+		}
+
+		f := os.NewFile(uintptr(listenFDsStart+i), name)
+		l, err := net.FileListener(f)
+		// The listener holds its own descriptor
+		f.Close()
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("socket %q: %w", name, err)
+		}
+		listeners[name] = l
+	}
+	return listeners, nil
+}
+
+// Notify sends state, such as Ready, to systemd. It does nothing when the
+// service is not of Type=notify.
+func Notify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	// An initial @ names a socket in the abstract namespace
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("notifying systemd: %w", err)
+	}
+	return nil
+}
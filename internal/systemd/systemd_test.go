@@ -0,0 +1,77 @@
+package systemd
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestListeners(t *testing.T) {
+	// Not for this process
+	t.Setenv("LISTEN_PID", "1")
+	t.Setenv("LISTEN_FDS", "1")
+	if listeners, err := Listeners(); err != nil || listeners != nil {
+		t.Fatalf("Listeners() for another process = %v, %v", listeners, err)
+	}
+	if _, ok := os.LookupEnv("LISTEN_FDS"); ok {
+		t.Error("LISTEN_FDS is still set")
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	start := listenFDsStart
+	defer func() { listenFDsStart = start }()
+
+	for _, names := range []string{"", "api"} {
+		t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+		t.Setenv("LISTEN_FDS", "1")
+		t.Setenv("LISTEN_FDNAMES", names)
+		// A copy of the descriptor stands in for systemd's; Listeners
+		// takes it over
+		dup, err := ln.(*net.TCPListener).File()
+		if err != nil {
+			t.Fatal(err)
+		}
+		listenFDsStart = int(dup.Fd())
+
+		listeners, err := Listeners()
+		if err != nil {
+			t.Fatalf("Listeners() error = %v", err)
+		}
+		api := listeners[API]
+		if len(listeners) != 1 || api == nil || api.Addr().String() != ln.Addr().String() {
+			t.Errorf("LISTEN_FDNAMES=%q: Listeners() = %v", names, listeners)
+			continue
+		}
+		api.Close()
+	}
+}
+
+func TestNotify(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if err := Notify(Ready); err != nil {
+		t.Errorf("Notify() without a socket = %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skip("no unix datagram sockets:", err)
+	}
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", path)
+
+	if err := Notify(Ready); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	buf := make([]byte, 64)
+	n, err := conn.Read(buf)
+	if err != nil || string(buf[:n]) != Ready {
+		t.Errorf("systemd got %q, %v; want %q", buf[:n], err, Ready)
+	}
+}
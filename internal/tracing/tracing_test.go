@@ -0,0 +1,112 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func setupRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+
+	return recorder
+}
+
+func attr(attrs []attribute.KeyValue, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range attrs {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestMiddleware(t *testing.T) {
+	recorder := setupRecorder(t)
+
+	r := chi.NewRouter()
+	r.Use(Middleware)
+	r.Get("/tasks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	r.Get("/boom", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	t.Run("names span after route and continues remote trace", func(t *testing.T) {
+		recorder.Reset()
+
+		req := httptest.NewRequest(http.MethodGet, "/tasks/42", nil)
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+
+		spans := recorder.Ended()
+		if len(spans) != 1 {
+			t.Fatalf("got %d spans, want 1", len(spans))
+		}
+		span := spans[0]
+
+		if span.Name() != "GET /tasks/{id}" {
+			t.Errorf("span name = %q, want %q", span.Name(), "GET /tasks/{id}")
+		}
+		if got := span.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("trace ID = %s, want the propagated one", got)
+		}
+		if got := span.Parent().SpanID().String(); got != "00f067aa0ba902b7" {
+			t.Errorf("parent span ID = %s, want the propagated one", got)
+		}
+		if v, _ := attr(span.Attributes(), "http.response.status_code"); v.AsInt64() != http.StatusNotFound {
+			t.Errorf("status code attribute = %d, want %d", v.AsInt64(), http.StatusNotFound)
+		}
+		if v, _ := attr(span.Attributes(), "http.route"); v.AsString() != "/tasks/{id}" {
+			t.Errorf("route attribute = %q, want %q", v.AsString(), "/tasks/{id}")
+		}
+		if span.Status().Code == codes.Error {
+			t.Error("4xx responses should not mark the span as failed")
+		}
+	})
+
+	t.Run("marks server errors", func(t *testing.T) {
+		recorder.Reset()
+
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/boom", nil))
+
+		spans := recorder.Ended()
+		if len(spans) != 1 {
+			t.Fatalf("got %d spans, want 1", len(spans))
+		}
+		if spans[0].Status().Code != codes.Error {
+			t.Errorf("span status = %v, want Error", spans[0].Status().Code)
+		}
+		if spans[0].Parent().IsValid() {
+			t.Error("span without incoming traceparent should be a root span")
+		}
+	})
+}
+
+func TestSetupRejectsUnknownExporter(t *testing.T) {
+	t.Setenv("OTEL_TRACES_EXPORTER", "carrier-pigeon")
+
+	if _, err := Setup(t.Context(), "test"); err == nil {
+		t.Fatal("expected error for unsupported exporter")
+	}
+}
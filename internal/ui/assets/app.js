@@ -0,0 +1,77 @@
+(function () {
+  "use strict";
+
+  var list = document.getElementById("tasks");
+  var form = document.getElementById("new-task");
+  var title = document.getElementById("title");
+  var errorBox = document.getElementById("error");
+
+  function request(method, path, body) {
+    return fetch(path, {
+      method: method,
+      headers: body ? { "Content-Type": "application/json" } : {},
+      body: body ? JSON.stringify(body) : undefined
+    }).then(function (resp) {
+      if (resp.status === 204) {
+        return null;
+      }
+      return resp.json().then(function (data) {
+        if (!resp.ok) {
+          throw new Error(data.message || data.detail || resp.statusText);
+        }
+        return data;
+      });
+    });
+  }
+
+  function showError(err) {
+    errorBox.textContent = err.message;
+    errorBox.hidden = false;
+  }
+
+  function render(tasks) {
+    errorBox.hidden = true;
+    list.replaceChildren();
+    tasks.forEach(function (task) {
+      var item = document.createElement("li");
+      item.className = task.status;
+
+      var done = document.createElement("input");
+      done.type = "checkbox";
+      done.checked = task.status === "done";
+      done.addEventListener("change", function () {
+        request("PUT", "/tasks/" + task.id, {
+          title: task.title,
+          description: task.description,
+          status: done.checked ? "done" : "todo"
+        }).then(load, showError);
+      });
+
+      var label = document.createElement("span");
+      label.textContent = task.title;
+
+      var remove = document.createElement("button");
+      remove.textContent = "Delete";
+      remove.addEventListener("click", function () {
+        request("DELETE", "/tasks/" + task.id).then(load, showError);
+      });
+
+      item.append(done, label, remove);
+      list.append(item);
+    });
+  }
+
+  function load() {
+    return request("GET", "/tasks").then(render, showError);
+  }
+
+  form.addEventListener("submit", function (event) {
+    event.preventDefault();
+    request("POST", "/tasks", { title: title.value }).then(function () {
+      title.value = "";
+      return load();
+    }, showError);
+  });
+
+  load();
+})();
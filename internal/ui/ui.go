@@ -0,0 +1,56 @@
+// Package ui embeds the browser UI served at /ui.
+package ui
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"io/fs"
+	"net/http"
+
+	"github.com/light-bringer/cert-tasks/internal/static"
+)
+
+//go:embed assets
+var assets embed.FS
+
+//go:embed index.html.tmpl
+var indexTemplate string
+
+// Handler serves the UI. It must be mounted with its prefix stripped; the
+// index page references assets under prefix using fingerprinted names so
+// browsers can cache them forever.
+func Handler(prefix string) (http.Handler, error) {
+	sub, err := fs.Sub(assets, "assets")
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := static.NewFS(sub)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := template.New("index").Parse(indexTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, map[string]string{
+		"Script": prefix + "/" + files.Path("app.js"),
+		"Style":  prefix + "/" + files.Path("style.css"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	index := static.NewAsset("index.html", "", buf.Bytes())
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" || r.URL.Path == "" {
+			index.ServeHTTP(w, r)
+			return
+		}
+		files.ServeHTTP(w, r)
+	}), nil
+}
@@ -0,0 +1,431 @@
+package validation
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Violation describes a single failed validation rule
+type Violation struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+
+	// Params holds the values a translated message needs besides the
+	// field, such as "max" for length limits and "values" for one_of
+	Params map[string]string `json:"-"`
+
+	// Candidates are the values an ambiguous input could mean
+	Candidates []string `json:"candidates,omitempty"`
+}
+
+// Errors lists every rule a request violated
+type Errors []Violation
+
+// Error implements the error interface
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, v := range e {
+		msgs[i] = v.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Rule names reported in violations
+const (
+	RuleRequired     = "required"
+	RuleMaxLength    = "max_length"
+	RuleMaxBytes     = "max_bytes"
+	RuleAllowedChars = "allowed_chars"
+	RuleOneOf        = "one_of"
+	RuleFormat       = "format"
+	RuleAmbiguous    = "ambiguous"
+	RuleExclusive    = "exclusive"
+	RuleMin          = "min"
+)
+
+// Rules configures per-field validation limits. Zero values disable a rule.
+type Rules struct {
+	// MaxTitleLength is the maximum title length in characters
+	MaxTitleLength int
+
+	// MaxDescriptionBytes is the maximum description size in bytes
+	MaxDescriptionBytes int
+
+	// AllowedTitleChars is a regexp character class body (e.g. `\p{L}\p{N} `)
+	// every title character must belong to
+	AllowedTitleChars string
+}
+
+// DefaultRules returns the limits used when no configuration is provided
+func DefaultRules() Rules {
+	return Rules{
+		MaxTitleLength:      200,
+		MaxDescriptionBytes: 10000,
+	}
+}
+
+// Validator validates task requests against a set of rules
+type Validator struct {
+	rules        Rules
+	allowedTitle *regexp.Regexp
+}
+
+// New creates a validator for the given rules
+func New(rules Rules) (*Validator, error) {
+	v := &Validator{rules: rules}
+
+	if rules.AllowedTitleChars != "" {
+		re, err := regexp.Compile("^[" + rules.AllowedTitleChars + "]*$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed title characters: %w", err)
+		}
+		v.allowedTitle = re
+	}
+
+	return v, nil
+}
+
+// Default returns a validator using DefaultRules
+func Default() *Validator {
+	v, _ := New(DefaultRules())
+	return v
+}
+
+// ValidateCreate normalizes the title of a create task request with
+// NormalizeTitle and validates the request
+//
+//api:changelog 0.2.0 changed field Task.title: Titles are stored in Unicode NFC with surrounding whitespace, including full-width spaces, trimmed; control and zero-width characters are rejected with allowed_chars
+func (v *Validator) ValidateCreate(req *models.CreateTaskRequest) error {
+	req.Title = NormalizeTitle(req.Title)
+	var errs Errors
+	errs = v.checkTitle(errs, req.Title)
+	errs = v.checkDescription(errs, req.Description)
+	errs = checkEstimate(errs, req.Estimate)
+	return errs.orNil()
+}
+
+// ValidateUpdate normalizes the title of an update task request with
+// NormalizeTitle and validates the request
+func (v *Validator) ValidateUpdate(req *models.UpdateTaskRequest) error {
+	req.Title = NormalizeTitle(req.Title)
+	var errs Errors
+	errs = v.checkTitle(errs, req.Title)
+	errs = v.checkDescription(errs, req.Description)
+	errs = checkEstimate(errs, req.Estimate)
+	errs = checkStatus(errs, req.Status)
+	return errs.orNil()
+}
+
+// ValidateStatus validates a status a task is moved to
+func (v *Validator) ValidateStatus(status models.TaskStatus) error {
+	return checkStatus(nil, status).orNil()
+}
+
+// ValidateLink validates a create link request
+func (v *Validator) ValidateLink(req *models.CreateLinkRequest) error {
+	var errs Errors
+	if !req.Type.IsValid() {
+		errs = append(errs, Violation{
+			Field:   "type",
+			Rule:    RuleOneOf,
+			Message: "type must be one of 'relates_to', 'duplicate_of' or 'caused_by'",
+			Params:  map[string]string{"values": "relates_to, duplicate_of, caused_by"},
+		})
+	}
+	if req.TaskID <= 0 {
+		errs = append(errs, Violation{
+			Field:   "task_id",
+			Rule:    RuleRequired,
+			Message: "task_id is required",
+		})
+	}
+	return errs.orNil()
+}
+
+// ValidateAPIKey validates a create API key request
+func (v *Validator) ValidateAPIKey(req *models.CreateAPIKeyRequest) error {
+	var errs Errors
+	if strings.TrimSpace(req.Name) == "" {
+		errs = append(errs, Violation{
+			Field:   "name",
+			Rule:    RuleRequired,
+			Message: "name is required and cannot be empty",
+		})
+	} else if utf8.RuneCountInString(req.Name) > maxAPIKeyNameLength {
+		errs = append(errs, Violation{
+			Field:   "name",
+			Rule:    RuleMaxLength,
+			Message: fmt.Sprintf("name must be at most %d characters", maxAPIKeyNameLength),
+			Params:  map[string]string{"max": strconv.Itoa(maxAPIKeyNameLength)},
+		})
+	}
+	if !req.Scope.IsValid() {
+		errs = append(errs, Violation{
+			Field:   "scope",
+			Rule:    RuleOneOf,
+			Message: "scope must be either 'read' or 'read_write'",
+			Params:  map[string]string{"values": "read, read_write"},
+		})
+	}
+	return errs.orNil()
+}
+
+// maxAPIKeyNameLength is the maximum API key name length in characters
+const maxAPIKeyNameLength = 100
+
+// maxWebhookURLLength is the maximum webhook URL length in bytes
+const maxWebhookURLLength = 2048
+
+// ValidateWebhook validates a webhook registration request
+func (v *Validator) ValidateWebhook(req *models.CreateWebhookRequest) error {
+	var errs Errors
+	if req.URL == "" {
+		errs = append(errs, Violation{
+			Field:   "url",
+			Rule:    RuleRequired,
+			Message: "url is required",
+		})
+	} else if len(req.URL) > maxWebhookURLLength {
+		errs = append(errs, Violation{
+			Field:   "url",
+			Rule:    RuleMaxLength,
+			Message: fmt.Sprintf("url must be at most %d bytes", maxWebhookURLLength),
+			Params:  map[string]string{"max": strconv.Itoa(maxWebhookURLLength)},
+		})
+	} else if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		errs = append(errs, Violation{
+			Field:   "url",
+			Rule:    RuleFormat,
+			Message: "url must be an absolute http or https URL",
+		})
+	}
+
+	if len(req.Events) == 0 {
+		errs = append(errs, Violation{
+			Field:   "events",
+			Rule:    RuleRequired,
+			Message: "events must name at least one event",
+		})
+	}
+	for _, e := range req.Events {
+		if !e.IsValid() {
+			errs = append(errs, Violation{
+				Field:   "events",
+				Rule:    RuleOneOf,
+				Message: fmt.Sprintf("unknown event %q; use task.created, task.updated, task.completed or task.deleted", e),
+				Params:  map[string]string{"values": "task.created, task.updated, task.completed, task.deleted"},
+			})
+			break
+		}
+	}
+	return errs.orNil()
+}
+
+// workspaceIDPattern keeps workspace IDs usable in headers, URLs and
+// token claims without escaping
+var workspaceIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// maxWorkspaceNameLength is the maximum workspace name length in characters
+const maxWorkspaceNameLength = 100
+
+// ValidateCreateWorkspace validates a workspace creation request
+func (v *Validator) ValidateCreateWorkspace(req *models.CreateWorkspaceRequest) error {
+	var errs Errors
+	if req.ID == "" {
+		errs = append(errs, Violation{
+			Field:   "id",
+			Rule:    RuleRequired,
+			Message: "id is required",
+		})
+	} else if !workspaceIDPattern.MatchString(req.ID) {
+		errs = append(errs, Violation{
+			Field:   "id",
+			Rule:    RuleAllowedChars,
+			Message: "id must be 1-63 lowercase letters, digits or hyphens, starting with a letter or digit",
+		})
+	}
+	errs = checkWorkspaceName(errs, req.Name)
+	errs = checkEstimateUnit(errs, req.EstimateUnit)
+	return checkTimezone(errs, req.Timezone).orNil()
+}
+
+// ValidateUpdateWorkspace validates a workspace update request
+func (v *Validator) ValidateUpdateWorkspace(req *models.UpdateWorkspaceRequest) error {
+	errs := checkWorkspaceName(nil, req.Name)
+	errs = checkEstimateUnit(errs, req.EstimateUnit)
+	return checkTimezone(errs, req.Timezone).orNil()
+}
+
+// LoadTimezone returns the IANA time zone named name, such as
+// Europe/Berlin. Unlike time.LoadLocation it rejects "Local", the server's
+// own zone, and the empty name.
+func LoadTimezone(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("%q is not an IANA time zone", name)
+	}
+	return time.LoadLocation(name)
+}
+
+func checkTimezone(errs Errors, name string) Errors {
+	if name == "" {
+		return errs
+	}
+	if _, err := LoadTimezone(name); err != nil {
+		return append(errs, Violation{
+			Field:   "timezone",
+			Rule:    RuleFormat,
+			Message: "timezone must be an IANA time zone such as Europe/Berlin",
+		})
+	}
+	return errs
+}
+
+func checkEstimateUnit(errs Errors, unit string) Errors {
+	switch unit {
+	case "", models.EstimateUnitPoints, models.EstimateUnitMinutes:
+		return errs
+	}
+	return append(errs, Violation{
+		Field:   "estimate_unit",
+		Rule:    RuleOneOf,
+		Message: "estimate_unit must be either 'points' or 'minutes'",
+		Params:  map[string]string{"values": "points, minutes"},
+	})
+}
+
+func checkWorkspaceName(errs Errors, name string) Errors {
+	if strings.TrimSpace(name) == "" {
+		return append(errs, Violation{
+			Field:   "name",
+			Rule:    RuleRequired,
+			Message: "name is required and cannot be empty",
+		})
+	}
+	if utf8.RuneCountInString(name) > maxWorkspaceNameLength {
+		return append(errs, Violation{
+			Field:   "name",
+			Rule:    RuleMaxLength,
+			Message: fmt.Sprintf("name must be at most %d characters", maxWorkspaceNameLength),
+			Params:  map[string]string{"max": strconv.Itoa(maxWorkspaceNameLength)},
+		})
+	}
+	return errs
+}
+
+// NormalizeTitle puts title in Unicode normalization form C, so the same
+// text typed with precomposed or combining characters is stored alike, and
+// trims leading and trailing whitespace, including full-width spaces such
+// as U+3000, and invisible format characters such as U+200B
+func NormalizeTitle(title string) string {
+	return strings.TrimFunc(norm.NFC.String(title), func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.Is(unicode.Cf, r)
+	})
+}
+
+// zeroWidth lists invisible characters that have no place inside a title.
+// The zero-width joiner and non-joiner are left alone: emoji sequences and
+// several scripts need them.
+var zeroWidth = map[rune]bool{
+	'\u180e': true, // Mongolian vowel separator
+	'\u200b': true, // zero-width space
+	'\u2060': true, // word joiner
+	'\ufeff': true, // zero-width no-break space (byte order mark)
+}
+
+// hasInvisible reports whether s contains a control character, such as a
+// newline or tab, or a zero-width character
+func hasInvisible(s string) bool {
+	return strings.ContainsFunc(s, func(r rune) bool {
+		return unicode.IsControl(r) || zeroWidth[r]
+	})
+}
+
+func (v *Validator) checkTitle(errs Errors, title string) Errors {
+	if strings.TrimSpace(title) == "" {
+		return append(errs, Violation{
+			Field:   "title",
+			Rule:    RuleRequired,
+			Message: "title is required and cannot be empty",
+		})
+	}
+
+	if max := v.rules.MaxTitleLength; max > 0 && utf8.RuneCountInString(title) > max {
+		errs = append(errs, Violation{
+			Field:   "title",
+			Rule:    RuleMaxLength,
+			Message: fmt.Sprintf("title must be at most %d characters", max),
+			Params:  map[string]string{"max": strconv.Itoa(max)},
+		})
+	}
+
+	if hasInvisible(title) {
+		errs = append(errs, Violation{
+			Field:   "title",
+			Rule:    RuleAllowedChars,
+			Message: "title must not contain control or zero-width characters",
+		})
+	} else if v.allowedTitle != nil && !v.allowedTitle.MatchString(title) {
+		errs = append(errs, Violation{
+			Field:   "title",
+			Rule:    RuleAllowedChars,
+			Message: "title contains characters that are not allowed",
+		})
+	}
+
+	return errs
+}
+
+func (v *Validator) checkDescription(errs Errors, description string) Errors {
+	if max := v.rules.MaxDescriptionBytes; max > 0 && len(description) > max {
+		errs = append(errs, Violation{
+			Field:   "description",
+			Rule:    RuleMaxBytes,
+			Message: fmt.Sprintf("description must be at most %d bytes", max),
+			Params:  map[string]string{"max": strconv.Itoa(max)},
+		})
+	}
+	return errs
+}
+
+// checkStatus adds a violation unless status is todo or done
+func checkStatus(errs Errors, status models.TaskStatus) Errors {
+	if status != models.StatusTodo && status != models.StatusDone {
+		errs = append(errs, Violation{
+			Field:   "status",
+			Rule:    RuleOneOf,
+			Message: "status must be either 'todo' or 'done'",
+			Params:  map[string]string{"values": "todo, done"},
+		})
+	}
+	return errs
+}
+
+func checkEstimate(errs Errors, estimate int) Errors {
+	if estimate < 0 {
+		errs = append(errs, Violation{
+			Field:   "estimate",
+			Rule:    RuleMin,
+			Message: "estimate must not be negative",
+			Params:  map[string]string{"min": "0"},
+		})
+	}
+	return errs
+}
+
+// orNil returns nil for an empty list so callers can compare against nil
+func (e Errors) orNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
@@ -0,0 +1,235 @@
+package validation
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+)
+
+func TestValidator_ValidateCreate(t *testing.T) {
+	v, err := New(Rules{
+		MaxTitleLength:      10,
+		MaxDescriptionBytes: 8,
+		AllowedTitleChars:   `\p{L}\p{N} `,
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		req       models.CreateTaskRequest
+		wantRules []string
+	}{
+		{
+			name: "valid",
+			req:  models.CreateTaskRequest{Title: "Täsk 1", Description: "short"},
+		},
+		{
+			name:      "missing title",
+			req:       models.CreateTaskRequest{Title: "   "},
+			wantRules: []string{RuleRequired},
+		},
+		{
+			name:      "title counts characters not bytes",
+			req:       models.CreateTaskRequest{Title: "ééééééééééé"},
+			wantRules: []string{RuleMaxLength},
+		},
+		{
+			name:      "disallowed characters",
+			req:       models.CreateTaskRequest{Title: "<b>hi</b>"},
+			wantRules: []string{RuleAllowedChars},
+		},
+		{
+			name:      "every violation is reported",
+			req:       models.CreateTaskRequest{Title: "way too long!", Description: "much too long"},
+			wantRules: []string{RuleMaxLength, RuleAllowedChars, RuleMaxBytes},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.ValidateCreate(&tt.req)
+			if len(tt.wantRules) == 0 {
+				if err != nil {
+					t.Fatalf("ValidateCreate() error = %v", err)
+				}
+				return
+			}
+
+			var errs Errors
+			if !errors.As(err, &errs) {
+				t.Fatalf("ValidateCreate() error = %v, want Errors", err)
+			}
+			if len(errs) != len(tt.wantRules) {
+				t.Fatalf("got %d violations %+v, want %d", len(errs), errs, len(tt.wantRules))
+			}
+			for i, rule := range tt.wantRules {
+				if errs[i].Rule != rule {
+					t.Errorf("violation[%d].Rule = %q, want %q", i, errs[i].Rule, rule)
+				}
+			}
+		})
+	}
+}
+
+func TestValidator_ValidateUpdate(t *testing.T) {
+	v := Default()
+
+	err := v.ValidateUpdate(&models.UpdateTaskRequest{Title: "", Status: "in-progress"})
+
+	var errs Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("ValidateUpdate() error = %v, want Errors", err)
+	}
+	if len(errs) != 2 || errs[0].Field != "title" || errs[1].Field != "status" {
+		t.Errorf("violations = %+v, want title and status", errs)
+	}
+
+	long := models.UpdateTaskRequest{Title: strings.Repeat("a", 201), Status: models.StatusDone}
+	if err := v.ValidateUpdate(&long); err == nil {
+		t.Error("expected error for title over the default limit")
+	}
+
+	ok := models.UpdateTaskRequest{Title: "Title", Status: models.StatusDone}
+	if err := v.ValidateUpdate(&ok); err != nil {
+		t.Errorf("ValidateUpdate() error = %v", err)
+	}
+}
+
+func TestNormalizeTitle(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"Write report", "Write report"},
+		// e followed by a combining acute accent becomes é
+		{"Cafe\u0301", "Caf\u00e9"},
+		{"\u3000Write report\u3000", "Write report"},
+		{"\u200b\ufeff Write report \u2060", "Write report"},
+		{"\u3000", ""},
+		{"\u200b", ""},
+		// Inner characters are left for validation to judge
+		{"Write\u200breport", "Write\u200breport"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeTitle(tt.title); got != tt.want {
+			t.Errorf("NormalizeTitle(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestValidator_ValidateCreate_Unicode(t *testing.T) {
+	v := Default()
+	tests := []struct {
+		name     string
+		title    string
+		wantRule string
+	}{
+		{"ideographic space only", "\u3000", RuleRequired},
+		{"zero-width space only", "\u200b\u200b", RuleRequired},
+		{"newline", "Write\nreport", RuleAllowedChars},
+		{"escape sequence", "Write \x1b[31mreport", RuleAllowedChars},
+		{"inner zero-width space", "Write\u200breport", RuleAllowedChars},
+		{"emoji joined with a zero-width joiner", "Family \U0001F468\u200d\U0001F469\u200d\U0001F467", ""},
+		{"full-width letters", "\uff37\uff52\uff49\uff54\uff45", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.ValidateCreate(&models.CreateTaskRequest{Title: tt.title})
+			if tt.wantRule == "" {
+				if err != nil {
+					t.Fatalf("ValidateCreate() error = %v", err)
+				}
+				return
+			}
+			var errs Errors
+			if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Rule != tt.wantRule {
+				t.Errorf("ValidateCreate() error = %v, want one %s violation", err, tt.wantRule)
+			}
+		})
+	}
+
+	req := models.CreateTaskRequest{Title: "\u3000Cafe\u0301\u200b"}
+	if err := v.ValidateCreate(&req); err != nil {
+		t.Fatalf("ValidateCreate() error = %v", err)
+	}
+	if req.Title != "Caf\u00e9" {
+		t.Errorf("title = %q, want it normalized to %q", req.Title, "Caf\u00e9")
+	}
+}
+
+func TestNew_InvalidCharacterClass(t *testing.T) {
+	if _, err := New(Rules{AllowedTitleChars: `\p{Bogus}`}); err == nil {
+		t.Error("expected error for invalid character class")
+	}
+}
+
+func TestValidator_ValidateCreateWorkspace(t *testing.T) {
+	v, _ := New(DefaultRules())
+
+	tests := []struct {
+		name       string
+		req        models.CreateWorkspaceRequest
+		wantFields []string
+	}{
+		{"valid", models.CreateWorkspaceRequest{ID: "acme-eu", Name: "Acme EU"}, nil},
+		{"missing both", models.CreateWorkspaceRequest{}, []string{"id", "name"}},
+		{"uppercase ID", models.CreateWorkspaceRequest{ID: "Acme", Name: "Acme"}, []string{"id"}},
+		{"leading hyphen", models.CreateWorkspaceRequest{ID: "-acme", Name: "Acme"}, []string{"id"}},
+		{"ID too long", models.CreateWorkspaceRequest{ID: strings.Repeat("a", 64), Name: "Acme"}, []string{"id"}},
+		{"name too long", models.CreateWorkspaceRequest{ID: "acme", Name: strings.Repeat("a", 101)}, []string{"name"}},
+		{"estimate unit", models.CreateWorkspaceRequest{ID: "acme", Name: "Acme", EstimateUnit: models.EstimateUnitMinutes}, nil},
+		{"unknown estimate unit", models.CreateWorkspaceRequest{ID: "acme", Name: "Acme", EstimateUnit: "hours"}, []string{"estimate_unit"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fields []string
+			var verrs Errors
+			if err := v.ValidateCreateWorkspace(&tt.req); errors.As(err, &verrs) {
+				for _, violation := range verrs {
+					fields = append(fields, violation.Field)
+				}
+			}
+			if !slices.Equal(fields, tt.wantFields) {
+				t.Errorf("violated fields = %v, want %v", fields, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestValidator_ValidateWebhook(t *testing.T) {
+	v, _ := New(DefaultRules())
+	created := []models.TaskEventType{models.EventTaskCreated}
+
+	tests := []struct {
+		name      string
+		req       models.CreateWebhookRequest
+		wantRules []string
+	}{
+		{"valid", models.CreateWebhookRequest{URL: "https://example.com/hook", Events: created}, nil},
+		{"missing both", models.CreateWebhookRequest{}, []string{RuleRequired, RuleRequired}},
+		{"relative URL", models.CreateWebhookRequest{URL: "/hook", Events: created}, []string{RuleFormat}},
+		{"other scheme", models.CreateWebhookRequest{URL: "ftp://example.com/hook", Events: created}, []string{RuleFormat}},
+		{"unknown event", models.CreateWebhookRequest{URL: "https://example.com/hook", Events: []models.TaskEventType{"task.exploded"}}, []string{RuleOneOf}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rules []string
+			var verrs Errors
+			if err := v.ValidateWebhook(&tt.req); errors.As(err, &verrs) {
+				for _, violation := range verrs {
+					rules = append(rules, violation.Rule)
+				}
+			}
+			if !slices.Equal(rules, tt.wantRules) {
+				t.Errorf("violated rules = %v, want %v", rules, tt.wantRules)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package version
+
+import "runtime"
+
+// Build information, overridden at link time with
+// -ldflags "-X github.com/light-bringer/cert-tasks/internal/version.Version=..."
+var (
+	Version = "dev"
+	Commit  = "unknown"
+)
+
+// Info describes the running build
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build information for the running binary
+func Get() Info {
+	return Info{
+		Version:   Version,
+		Commit:    Commit,
+		GoVersion: runtime.Version(),
+	}
+}
@@ -0,0 +1,296 @@
+// Package webhook delivers task events to registered callback URLs.
+// Publish matches an event against the webhooks of the task's workspace
+// and queues one delivery per subscriber; workers started by Run POST the
+// signed payload and retry failures with exponential backoff. Deliveries
+// are background work bounded by the server's lifetime, not by the request
+// that caused them, and each attempt is capped by the outbound webhook
+// budget. The queue lives in memory: deliveries still pending at shutdown
+// are dropped.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/logging"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+// Headers set on every delivery
+//
+//api:changelog 0.2.0 added header X-Webhook-Event: Event type of a webhook delivery
+//api:changelog 0.2.0 added header X-Webhook-ID: Event ID, the same on every retry of one delivery
+//api:changelog 0.2.0 added header X-Webhook-Signature: "t=<unix time>,v1=<hex HMAC-SHA256 of "<t>.<body>">" signed with the webhook's secret
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderEventID   = "X-Webhook-ID"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// secretPrefix marks webhook signing secrets
+const secretPrefix = "whsec_"
+
+// Config tunes delivery
+type Config struct {
+	// MaxAttempts is how often one event is tried before it is given up
+	MaxAttempts int
+
+	// InitialBackoff is the wait before the first retry; each further
+	// retry waits twice as long, up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// Workers is the number of concurrent deliveries
+	Workers int
+
+	// QueueSize bounds deliveries waiting for a worker; events beyond it
+	// are dropped and logged as failed
+	QueueSize int
+
+	// LogSize is the number of attempts kept per webhook for the
+	// delivery log
+	LogSize int
+}
+
+// DefaultConfig tries each event 6 times over about 30 seconds
+func DefaultConfig() Config {
+	return Config{
+		MaxAttempts:    6,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+		Workers:        4,
+		QueueSize:      1000,
+		LogSize:        100,
+	}
+}
+
+// delivery is one event on its way to one webhook
+type delivery struct {
+	hook    *models.Webhook
+	event   *models.TaskEvent
+	body    []byte
+	attempt int
+}
+
+// Dispatcher queues and delivers events
+type Dispatcher struct {
+	hooks  repository.WebhookRepository
+	client *http.Client
+	cfg    Config
+	now    func() time.Time
+
+	queue chan *delivery
+
+	mu  sync.Mutex
+	log map[int64][]models.WebhookDelivery // newest last, at most cfg.LogSize
+}
+
+// New creates a Dispatcher for the webhooks in hooks. client should be an
+// outbound.PublicClient bounded by the webhook budget, since webhook URLs
+// are chosen by users.
+func New(hooks repository.WebhookRepository, client *http.Client, cfg Config) *Dispatcher {
+	return &Dispatcher{
+		hooks:  hooks,
+		client: client,
+		cfg:    cfg,
+		now:    time.Now,
+		queue:  make(chan *delivery, cfg.QueueSize),
+		log:    make(map[int64][]models.WebhookDelivery),
+	}
+}
+
+// NewSecret generates a signing secret for a webhook
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating webhook secret: %w", err)
+	}
+	return secretPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Sign returns the X-Webhook-Signature value for body sent at t
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Publish queues event for every webhook of the task's workspace that is
+// subscribed to it. Webhooks created by a JWT user only receive events for
+// that user's tasks. It never blocks on delivery.
+func (d *Dispatcher) Publish(ctx context.Context, typ models.TaskEventType, task *models.Task) {
+	// Subscribers are every webhook of the task's workspace, whoever
+	// created them; ownership is matched below
+	scoped := repository.WithOwner(repository.WithWorkspace(ctx, task.WorkspaceID), "")
+	hooks, err := d.hooks.ListWebhooks(scoped)
+	if err != nil {
+		logging.FromContext(ctx).Error("listing webhooks failed", slog.Any("error", err))
+		return
+	}
+
+	var event *models.TaskEvent
+	var body []byte
+	for _, hook := range hooks {
+		if !hook.Subscribed(typ) || (hook.OwnerID != "" && hook.OwnerID != task.OwnerID) {
+			continue
+		}
+		if event == nil {
+			event = models.NewTaskEvent(typ, task, d.now())
+			body, _ = json.Marshal(event)
+		}
+
+		dl := &delivery{hook: hook, event: event, body: body, attempt: 1}
+		select {
+		case d.queue <- dl:
+		default:
+			logging.FromContext(ctx).Warn("webhook queue full, dropping delivery",
+				slog.Int64("webhook_id", hook.ID), slog.String("event", string(typ)))
+			d.record(dl, models.WebhookDelivery{Status: models.DeliveryFailed, Error: "delivery queue full"})
+		}
+	}
+}
+
+// Run delivers queued events until ctx is done
+func (d *Dispatcher) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for range d.cfg.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case dl := <-d.queue:
+					d.attempt(ctx, dl)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// attempt makes one delivery attempt and schedules a retry if it failed
+func (d *Dispatcher) attempt(ctx context.Context, dl *delivery) {
+	start := d.now()
+	status, err := d.post(ctx, dl, start)
+	result := models.WebhookDelivery{
+		ResponseStatus: status,
+		DurationMs:     float64(d.now().Sub(start).Microseconds()) / 1000,
+		AttemptedAt:    start,
+		Status:         models.DeliverySucceeded,
+	}
+	if err == nil && (status < 200 || status > 299) {
+		err = fmt.Errorf("unexpected status %d", status)
+	}
+	if err == nil {
+		d.record(dl, result)
+		return
+	}
+
+	result.Error = err.Error()
+	if dl.attempt >= d.cfg.MaxAttempts || ctx.Err() != nil {
+		result.Status = models.DeliveryFailed
+		d.record(dl, result)
+		slog.Warn("webhook delivery failed",
+			slog.Int64("webhook_id", dl.hook.ID),
+			slog.String("event_id", dl.event.ID),
+			slog.Int("attempts", dl.attempt),
+			slog.Any("error", err),
+		)
+		return
+	}
+
+	wait := d.backoff(dl.attempt)
+	next := d.now().Add(wait)
+	result.Status = models.DeliveryRetrying
+	result.NextAttemptAt = &next
+	d.record(dl, result)
+
+	retry := *dl
+	retry.attempt++
+	time.AfterFunc(wait, func() {
+		select {
+		case d.queue <- &retry:
+		case <-ctx.Done():
+		}
+	})
+}
+
+// post sends the delivery and returns the response status
+func (d *Dispatcher) post(ctx context.Context, dl *delivery, at time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dl.hook.URL, bytes.NewReader(dl.body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "cert-tasks-webhook")
+	req.Header.Set(HeaderEvent, string(dl.event.Type))
+	req.Header.Set(HeaderEventID, dl.event.ID)
+	req.Header.Set(HeaderSignature, Sign(dl.hook.Secret, at, dl.body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return resp.StatusCode, nil
+}
+
+// backoff returns the wait after the given failed attempt
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	wait := d.cfg.InitialBackoff
+	for i := 1; i < attempt && wait < d.cfg.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, d.cfg.MaxBackoff)
+}
+
+// record adds an attempt to the webhook's delivery log
+func (d *Dispatcher) record(dl *delivery, result models.WebhookDelivery) {
+	result.WebhookID = dl.hook.ID
+	result.EventID = dl.event.ID
+	result.Event = dl.event.Type
+	result.Attempt = dl.attempt
+	if result.AttemptedAt.IsZero() {
+		result.AttemptedAt = d.now()
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	entries := append(d.log[dl.hook.ID], result)
+	if len(entries) > d.cfg.LogSize {
+		entries = entries[len(entries)-d.cfg.LogSize:]
+	}
+	d.log[dl.hook.ID] = entries
+}
+
+// Deliveries returns the recorded attempts for a webhook, newest first
+func (d *Dispatcher) Deliveries(webhookID int64) []models.WebhookDelivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	entries := d.log[webhookID]
+	out := make([]models.WebhookDelivery, len(entries))
+	for i, e := range entries {
+		out[len(entries)-1-i] = e
+	}
+	return out
+}
@@ -0,0 +1,190 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+// testConfig retries quickly so tests finish fast
+func testConfig() Config {
+	cfg := DefaultConfig()
+	cfg.MaxAttempts = 3
+	cfg.InitialBackoff = 10 * time.Millisecond
+	cfg.MaxBackoff = 20 * time.Millisecond
+	return cfg
+}
+
+// receiver records deliveries and answers with the next status in
+// statuses, then 200
+type receiver struct {
+	*httptest.Server
+	calls    atomic.Int32
+	statuses []int
+	got      chan *http.Request
+	bodies   chan []byte
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	rv := &receiver{statuses: statuses, got: make(chan *http.Request, 10), bodies: make(chan []byte, 10)}
+	rv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(rv.calls.Add(1))
+		body, _ := io.ReadAll(r.Body)
+		rv.got <- r
+		rv.bodies <- body
+		if n <= len(rv.statuses) {
+			w.WriteHeader(rv.statuses[n-1])
+		}
+	}))
+	t.Cleanup(rv.Close)
+	return rv
+}
+
+// setup registers a webhook for url and starts a dispatcher
+func setup(t *testing.T, url string, events ...models.TaskEventType) (*Dispatcher, *models.Webhook) {
+	t.Helper()
+	repo := repository.NewMemoryRepository()
+	hook, err := repo.CreateWebhook(context.Background(), &models.Webhook{URL: url, Events: events, Secret: "whsec_test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := New(repo, http.DefaultClient, testConfig())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return d, hook
+}
+
+// waitFor polls until cond holds or a second has passed
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for deliveries")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDispatcher_SignedDelivery(t *testing.T) {
+	rv := newReceiver(t)
+	d, hook := setup(t, rv.URL, models.EventTaskCreated)
+
+	task := &models.Task{ID: 7, WorkspaceID: models.DefaultWorkspace, Title: "Ship it"}
+	d.Publish(context.Background(), models.EventTaskUpdated, task) // not subscribed
+	d.Publish(context.Background(), models.EventTaskCreated, task)
+
+	req, body := <-rv.got, <-rv.bodies
+	if req.Header.Get(HeaderEvent) != "task.created" {
+		t.Errorf("%s = %q, want task.created", HeaderEvent, req.Header.Get(HeaderEvent))
+	}
+
+	var event models.TaskEvent
+	if err := json.Unmarshal(body, &event); err != nil || event.Task.ID != 7 || event.ID != req.Header.Get(HeaderEventID) {
+		t.Errorf("payload = %s, %v; want task 7 with the header's event ID", body, err)
+	}
+
+	// The receiver recomputes the signature from the timestamp it was given
+	sig := req.Header.Get(HeaderSignature)
+	tsPart, _, _ := strings.Cut(sig, ",")
+	ts, err := strconv.ParseInt(strings.TrimPrefix(tsPart, "t="), 10, 64)
+	if err != nil {
+		t.Fatalf("malformed signature %q: %v", sig, err)
+	}
+	if want := Sign(hook.Secret, time.Unix(ts, 0), body); want != sig {
+		t.Errorf("signature = %q, want %q", sig, want)
+	}
+
+	waitFor(t, func() bool { return len(d.Deliveries(hook.ID)) == 1 })
+	if got := d.Deliveries(hook.ID)[0]; got.Status != models.DeliverySucceeded || got.ResponseStatus != http.StatusOK || got.Attempt != 1 {
+		t.Errorf("delivery = %+v, want one successful attempt", got)
+	}
+	if n := rv.calls.Load(); n != 1 {
+		t.Errorf("receiver called %d times, want 1", n)
+	}
+}
+
+func TestDispatcher_Retries(t *testing.T) {
+	t.Run("succeeds after failures", func(t *testing.T) {
+		rv := newReceiver(t, http.StatusInternalServerError, http.StatusServiceUnavailable)
+		d, hook := setup(t, rv.URL, models.EventTaskDeleted)
+		d.Publish(context.Background(), models.EventTaskDeleted, &models.Task{ID: 1, WorkspaceID: models.DefaultWorkspace})
+
+		waitFor(t, func() bool { return len(d.Deliveries(hook.ID)) == 3 })
+		log := d.Deliveries(hook.ID)
+		if log[0].Status != models.DeliverySucceeded || log[0].Attempt != 3 {
+			t.Errorf("latest attempt = %+v, want attempt 3 succeeded", log[0])
+		}
+		if log[2].Status != models.DeliveryRetrying || log[2].ResponseStatus != http.StatusInternalServerError || log[2].NextAttemptAt == nil {
+			t.Errorf("first attempt = %+v, want retrying after 500", log[2])
+		}
+		if log[0].EventID != log[2].EventID {
+			t.Errorf("event IDs differ across retries: %q, %q", log[0].EventID, log[2].EventID)
+		}
+	})
+
+	t.Run("gives up after MaxAttempts", func(t *testing.T) {
+		rv := newReceiver(t, 500, 500, 500, 500)
+		d, hook := setup(t, rv.URL, models.EventTaskDeleted)
+		d.Publish(context.Background(), models.EventTaskDeleted, &models.Task{ID: 1, WorkspaceID: models.DefaultWorkspace})
+
+		waitFor(t, func() bool { return len(d.Deliveries(hook.ID)) == 3 })
+		if latest := d.Deliveries(hook.ID)[0]; latest.Status != models.DeliveryFailed {
+			t.Errorf("latest attempt = %+v, want failed", latest)
+		}
+		time.Sleep(50 * time.Millisecond)
+		if n := rv.calls.Load(); n != 3 {
+			t.Errorf("receiver called %d times, want 3", n)
+		}
+	})
+}
+
+func TestDispatcher_Publish_Scoping(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	repo.CreateWorkspace(ctx, &models.Workspace{ID: "acme", Name: "Acme"})
+
+	events := []models.TaskEventType{models.EventTaskCreated}
+	shared, _ := repo.CreateWebhook(ctx, &models.Webhook{URL: "http://example.invalid/a", Events: events})
+	alice, _ := repo.CreateWebhook(repository.WithOwner(ctx, "alice"), &models.Webhook{URL: "http://example.invalid/b", Events: events})
+	acme, _ := repo.CreateWebhook(repository.WithWorkspace(ctx, "acme"), &models.Webhook{URL: "http://example.invalid/c", Events: events})
+
+	d := New(repo, http.DefaultClient, testConfig())
+	d.Publish(ctx, models.EventTaskCreated, &models.Task{ID: 1, WorkspaceID: models.DefaultWorkspace, OwnerID: "bob"})
+
+	queued := map[int64]bool{}
+	for len(d.queue) > 0 {
+		queued[(<-d.queue).hook.ID] = true
+	}
+	if !queued[shared.ID] || queued[alice.ID] || queued[acme.ID] {
+		t.Errorf("queued webhooks = %v, want only the shared default-workspace webhook %d", queued, shared.ID)
+	}
+}
+
+func TestDispatcher_Backoff(t *testing.T) {
+	d := New(nil, nil, Config{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second})
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 10: 5 * time.Second} {
+		if got := d.backoff(attempt); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+}
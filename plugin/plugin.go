@@ -0,0 +1,228 @@
+// Package plugin lets Go modules extend the server without forking it. A
+// plugin registers itself by name from an init function, like a
+// database/sql driver:
+//
+//	func init() {
+//		plugin.Register("audit-mirror", func(r *plugin.Registrar, cfg plugin.Config) error {
+//			var settings struct {
+//				URL string `yaml:"url"`
+//			}
+//			if err := cfg.Decode(&settings); err != nil {
+//				return err
+//			}
+//			r.Consume(func(ctx context.Context, event plugin.EventType, task *plugin.Task) {
+//				// send it to settings.URL
+//			})
+//			return nil
+//		})
+//	}
+//
+// The server binary imports the plugin module for its side effects, and
+// the plugins section of the configuration enables it. At startup each
+// enabled plugin's SetupFunc adds request middlewares, task event
+// consumers and routes through its Registrar.
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"gopkg.in/yaml.v3"
+)
+
+// Task is a task as the server stores it
+type Task = models.Task
+
+// EventType names a change to a task
+type EventType = models.TaskEventType
+
+// Task events passed to a Consumer
+const (
+	EventCreated   = models.EventTaskCreated
+	EventUpdated   = models.EventTaskUpdated
+	EventCompleted = models.EventTaskCompleted
+	EventDeleted   = models.EventTaskDeleted
+)
+
+// Consumer is told about each change to a task once it is stored, with
+// the task as it is after the change, or as it was before a delete. It is
+// called on the request's goroutine, so it must not block.
+type Consumer func(ctx context.Context, event EventType, task *Task)
+
+// SetupFunc sets up a plugin from its configuration, adding what it
+// provides to r. An error stops the server from starting.
+type SetupFunc func(r *Registrar, cfg Config) error
+
+var (
+	mu      sync.RWMutex
+	plugins = make(map[string]SetupFunc)
+)
+
+// Register makes a plugin available under name. It panics if name is
+// empty or already registered, or setup is nil, so it belongs in an init
+// function.
+func Register(name string, setup SetupFunc) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if name == "" || setup == nil {
+		panic("plugin: Register needs a name and a SetupFunc")
+	}
+	if _, dup := plugins[name]; dup {
+		panic("plugin: Register called twice for " + name)
+	}
+	plugins[name] = setup
+}
+
+// Registered returns the names of the registered plugins, sorted
+func Registered() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(plugins))
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Spec enables a plugin in the configuration file:
+//
+//	plugins:
+//	  - name: audit-mirror
+//	    config:
+//	      url: https://audit.example.com/events
+type Spec struct {
+	Name   string    `yaml:"name"`
+	Config yaml.Node `yaml:"config"`
+}
+
+// Config is a plugin's own section of the configuration
+type Config struct {
+	node yaml.Node
+}
+
+// Decode decodes the section into v, a pointer, as yaml.Unmarshal would.
+// An absent section leaves v unchanged.
+func (c Config) Decode(v any) error {
+	if c.node.Kind == 0 {
+		return nil
+	}
+	return c.node.Decode(v)
+}
+
+// Registrar collects what one plugin adds to the server
+type Registrar struct {
+	name        string
+	logger      *slog.Logger
+	middlewares []func(http.Handler) http.Handler
+	consumers   []Consumer
+	routes      chi.Router
+}
+
+// Name returns the plugin's registered name
+func (r *Registrar) Name() string {
+	return r.name
+}
+
+// Logger returns a logger that tags records with the plugin's name
+func (r *Registrar) Logger() *slog.Logger {
+	return r.logger
+}
+
+// Use adds request middlewares. They wrap every request, after the
+// server's own stack and before authentication.
+func (r *Registrar) Use(mw ...func(http.Handler) http.Handler) {
+	r.middlewares = append(r.middlewares, mw...)
+}
+
+// Consume adds a consumer of task events
+func (r *Registrar) Consume(c Consumer) {
+	r.consumers = append(r.consumers, c)
+}
+
+// Handle adds a route under /plugins/{name}, so a pattern of /status is
+// served at /plugins/{name}/status. Plugin routes are authenticated and
+// scoped to a workspace like the task routes.
+func (r *Registrar) Handle(method, pattern string, h http.HandlerFunc) {
+	if r.routes == nil {
+		r.routes = chi.NewRouter()
+	}
+	r.routes.Method(method, pattern, h)
+}
+
+// Set is the plugins a server was started with
+type Set struct {
+	plugins []*Registrar
+}
+
+// ErrUnknown is returned by Load for a plugin that is not registered
+var ErrUnknown = errors.New("plugin not registered")
+
+// Load sets up the plugins specs enable, in order
+func Load(specs []Spec) (*Set, error) {
+	set := &Set{}
+	for _, spec := range specs {
+		mu.RLock()
+		setup, ok := plugins[spec.Name]
+		mu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknown, spec.Name)
+		}
+		r := &Registrar{name: spec.Name, logger: slog.Default().With(slog.String("plugin", spec.Name))}
+		if err := setup(r, Config{node: spec.Config}); err != nil {
+			return nil, fmt.Errorf("setting up plugin %s: %w", spec.Name, err)
+		}
+		set.plugins = append(set.plugins, r)
+	}
+	return set, nil
+}
+
+// Names returns the names of the loaded plugins, in load order
+func (s *Set) Names() []string {
+	names := make([]string, len(s.plugins))
+	for i, r := range s.plugins {
+		names[i] = r.name
+	}
+	return names
+}
+
+// Middlewares returns the request middlewares of every plugin, in load
+// order
+func (s *Set) Middlewares() []func(http.Handler) http.Handler {
+	var mw []func(http.Handler) http.Handler
+	for _, r := range s.plugins {
+		mw = append(mw, r.middlewares...)
+	}
+	return mw
+}
+
+// Consumers returns the task event consumers of every plugin, in load
+// order
+func (s *Set) Consumers() []Consumer {
+	var consumers []Consumer
+	for _, r := range s.plugins {
+		consumers = append(consumers, r.consumers...)
+	}
+	return consumers
+}
+
+// Routes returns the routes of each plugin that added any, by the prefix
+// they are served under
+func (s *Set) Routes() map[string]http.Handler {
+	routes := make(map[string]http.Handler)
+	for _, r := range s.plugins {
+		if r.routes != nil {
+			routes["/plugins/"+r.name] = r.routes
+		}
+	}
+	return routes
+}
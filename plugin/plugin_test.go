@@ -0,0 +1,79 @@
+package plugin_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/light-bringer/cert-tasks/plugin"
+	"gopkg.in/yaml.v3"
+)
+
+func TestLoad(t *testing.T) {
+	var events []plugin.EventType
+	plugin.Register("test-recorder", func(r *plugin.Registrar, cfg plugin.Config) error {
+		var settings struct {
+			Header string `yaml:"header"`
+		}
+		if err := cfg.Decode(&settings); err != nil {
+			return err
+		}
+		r.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.Header().Set(settings.Header, r.Name())
+				next.ServeHTTP(w, req)
+			})
+		})
+		r.Consume(func(ctx context.Context, event plugin.EventType, task *plugin.Task) {
+			events = append(events, event)
+		})
+		r.Handle(http.MethodGet, "/status", func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		})
+		return nil
+	})
+	errBroken := errors.New("broken")
+	plugin.Register("test-broken", func(*plugin.Registrar, plugin.Config) error { return errBroken })
+
+	var spec plugin.Spec
+	if err := yaml.Unmarshal([]byte("name: test-recorder\nconfig:\n  header: X-Plugin\n"), &spec); err != nil {
+		t.Fatal(err)
+	}
+	set, err := plugin.Load([]plugin.Spec{spec})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if names := set.Names(); len(names) != 1 || names[0] != "test-recorder" {
+		t.Errorf("Names() = %v", names)
+	}
+	rec := httptest.NewRecorder()
+	set.Middlewares()[0](http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if got := rec.Header().Get("X-Plugin"); got != "test-recorder" {
+		t.Errorf("middleware set X-Plugin = %q, want the configured header", got)
+	}
+	for _, consume := range set.Consumers() {
+		consume(context.Background(), plugin.EventCreated, &plugin.Task{ID: 1})
+	}
+	if len(events) != 1 || events[0] != plugin.EventCreated {
+		t.Errorf("events = %v", events)
+	}
+	routes, ok := set.Routes()["/plugins/test-recorder"]
+	if !ok {
+		t.Fatalf("Routes() = %v, want /plugins/test-recorder", set.Routes())
+	}
+	rec = httptest.NewRecorder()
+	routes.ServeHTTP(rec, httptest.NewRequest("GET", "/status", nil))
+	if rec.Code != http.StatusTeapot {
+		t.Errorf("GET /status = %d, want %d", rec.Code, http.StatusTeapot)
+	}
+
+	if _, err := plugin.Load([]plugin.Spec{{Name: "test-missing"}}); !errors.Is(err, plugin.ErrUnknown) {
+		t.Errorf("Load(unknown) error = %v, want ErrUnknown", err)
+	}
+	if _, err := plugin.Load([]plugin.Spec{{Name: "test-broken"}}); !errors.Is(err, errBroken) {
+		t.Errorf("Load(broken) error = %v, want the setup error", err)
+	}
+}
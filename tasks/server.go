@@ -0,0 +1,52 @@
+package tasks
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/light-bringer/cert-tasks/internal/app"
+)
+
+// Server is a task API server and the background work that goes with it:
+// the job queue, webhook and event delivery, and scheduled jobs
+type Server struct {
+	app *app.App
+}
+
+// NewServer builds a server from its options. The configuration is
+// validated first. The caller must Close the server.
+func NewServer(opts ...Option) (*Server, error) {
+	o := options{cfg: DefaultConfig()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	a, err := app.New(o.cfg, o.app)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{app: a}, nil
+}
+
+// Handler returns the server's router, for serving it from another
+// http.Server. Background work runs only while RunBackground does.
+func (s *Server) Handler() http.Handler {
+	return s.app.Handler()
+}
+
+// Run listens on the configured addresses and runs the background work
+// until ctx is done, then shuts down gracefully
+func (s *Server) Run(ctx context.Context) error {
+	return s.app.Run(ctx)
+}
+
+// RunBackground runs the job queue, webhook and event delivery and the
+// scheduled jobs until ctx is done and they have stopped
+func (s *Server) RunBackground(ctx context.Context) {
+	s.app.RunBackground(ctx)
+}
+
+// Close saves queued task writes and releases what the server opened. It
+// must be called after Run or RunBackground has returned.
+func (s *Server) Close() {
+	s.app.Close()
+}
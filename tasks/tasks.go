@@ -0,0 +1,170 @@
+// Package tasks embeds the task API in another Go program. The server is
+// the one cmd/api runs, wired from the same configuration, so an embedding
+// program gets every feature the binary has and adds its own pieces
+// through options rather than a fork:
+//
+//	cfg, err := tasks.LoadConfig("config.yaml")
+//	if err != nil {
+//		return err
+//	}
+//	return tasks.Run(ctx, cfg,
+//		tasks.WithRepository(myRepo),
+//		tasks.WithMiddleware(myMiddleware),
+//		tasks.WithMount("/billing", billingRoutes),
+//	)
+//
+// Run serves until ctx is done. NewServer builds a server without running
+// it, for programs that serve its Handler from their own http.Server.
+package tasks
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+
+	"github.com/light-bringer/cert-tasks/internal/app"
+	"github.com/light-bringer/cert-tasks/internal/config"
+	"github.com/light-bringer/cert-tasks/internal/models"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+)
+
+// Config is the server's configuration, as config.yaml and the
+// environment set it
+type Config = config.Config
+
+// Repository stores tasks. The built-in backends implement it, and so can
+// an embedding program's own storage; the optional interfaces of the
+// repository package, such as storage of workspaces and API keys, are
+// used when it implements them too.
+type Repository = repository.TaskRepository
+
+// Hooks are called around each change to a task, whatever the repository.
+// Embed NopHooks to implement only some of them.
+type (
+	Hooks    = repository.Hooks
+	NopHooks = repository.NopHooks
+)
+
+// Task is a task as the server stores it
+type Task = models.Task
+
+// DefaultConfig returns the configuration the server uses when nothing is
+// set
+func DefaultConfig() *Config {
+	return config.Default(false)
+}
+
+// LoadConfig reads the YAML file at path, if path is not empty, applies
+// the environment variables on top and validates the result, as the
+// binary does
+func LoadConfig(path string) (*Config, error) {
+	cfg, errs := config.Load(path, false)
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return cfg, nil
+}
+
+// Run serves the task API configured by cfg until ctx is done, then shuts
+// down gracefully and releases what the server opened
+func Run(ctx context.Context, cfg *Config, opts ...Option) error {
+	s, err := NewServer(append([]Option{WithConfig(cfg)}, opts...)...)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	return s.Run(ctx)
+}
+
+type options struct {
+	cfg *Config
+	app app.Options
+}
+
+// Option configures a Server
+type Option func(*options)
+
+// WithConfig sets the configuration; without it the server runs with
+// DefaultConfig
+func WithConfig(cfg *Config) Option {
+	return func(o *options) {
+		o.cfg = cfg
+	}
+}
+
+// WithRepository stores tasks in repo instead of the backend the
+// configuration selects. Fixtures, development mode and demo mode need
+// the built-in memory storage, so they cannot be combined with it.
+func WithRepository(repo Repository) Option {
+	return func(o *options) {
+		o.app.Repository = repo
+	}
+}
+
+// WithHooks adds hooks called around every change to a task, after those
+// of the server's own features
+func WithHooks(hooks ...Hooks) Option {
+	return func(o *options) {
+		o.app.Hooks = append(o.app.Hooks, hooks...)
+	}
+}
+
+// WithMiddleware adds request middlewares. They wrap every request, after
+// the server's own stack and before authentication.
+func WithMiddleware(mw ...func(http.Handler) http.Handler) Option {
+	return func(o *options) {
+		o.app.Middlewares = append(o.app.Middlewares, mw...)
+	}
+}
+
+// WithMount serves h under prefix, such as /billing. The routes are
+// authenticated and scoped to a workspace like the task routes, and must
+// not clash with them.
+func WithMount(prefix string, h http.Handler) Option {
+	return func(o *options) {
+		o.app.Mounts = append(o.app.Mounts, app.Mount{Prefix: prefix, Handler: h})
+	}
+}
+
+// WithLogLevel sets the level that /admin/loglevel changes, which should
+// be the level of the default slog logger. Without it the server keeps a
+// level of its own, starting at the configured one.
+func WithLogLevel(level *slog.LevelVar) Option {
+	return func(o *options) {
+		o.app.LogLevel = level
+	}
+}
+
+// WithSeedFile loads the JSON fixtures at path at startup, replacing all
+// data. It needs memory storage.
+func WithSeedFile(path string) Option {
+	return func(o *options) {
+		o.app.SeedFile = path
+	}
+}
+
+// WithDevMode serves POST /admin/seed, which replaces all data with
+// fixtures. It needs memory storage.
+func WithDevMode() Option {
+	return func(o *options) {
+		o.app.DevMode = true
+	}
+}
+
+// WithListeners serves on listeners that are already open, such as
+// sockets passed by systemd, instead of the configured addresses. Any of
+// them may be nil.
+func WithListeners(api, redirect, admin net.Listener) Option {
+	return func(o *options) {
+		o.app.APIListener, o.app.RedirectListener, o.app.AdminListener = api, redirect, admin
+	}
+}
+
+// WithReady sets a function Run calls once the server accepts connections
+func WithReady(ready func()) Option {
+	return func(o *options) {
+		o.app.Ready = ready
+	}
+}
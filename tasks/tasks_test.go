@@ -0,0 +1,122 @@
+package tasks_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/light-bringer/cert-tasks/internal/repository"
+	"github.com/light-bringer/cert-tasks/tasks"
+)
+
+// createRecorder records the tasks it is told were created
+type createRecorder struct {
+	tasks.NopHooks
+	created []string
+}
+
+func (c *createRecorder) AfterCreate(ctx context.Context, task *tasks.Task) {
+	c.created = append(c.created, task.Title)
+}
+
+func TestNewServer(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	recorder := &createRecorder{}
+	s, err := tasks.NewServer(
+		tasks.WithRepository(repo),
+		tasks.WithHooks(recorder),
+		tasks.WithMiddleware(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Embedded", "yes")
+				next.ServeHTTP(w, r)
+			})
+		}),
+		tasks.WithMount("/billing", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		})),
+	)
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	defer s.Close()
+	h := s.Handler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/tasks", strings.NewReader(`{"title":"embedded"}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST /tasks = %d %s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("X-Embedded"); got != "yes" {
+		t.Errorf("X-Embedded = %q, want the middleware to run", got)
+	}
+	if len(recorder.created) != 1 || recorder.created[0] != "embedded" {
+		t.Errorf("hook saw %v, want the created task", recorder.created)
+	}
+	if all, _ := repo.GetAll(context.Background()); len(all) != 1 {
+		t.Errorf("repository has %d tasks, want the task stored in it", len(all))
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/billing/", nil))
+	if rec.Code != http.StatusTeapot {
+		t.Errorf("GET /billing/ = %d, want %d from the mounted handler", rec.Code, http.StatusTeapot)
+	}
+}
+
+func TestNewServer_Errors(t *testing.T) {
+	invalid := tasks.DefaultConfig()
+	invalid.Storage.IDFormat = "bogus"
+
+	tests := []struct {
+		name string
+		opts []tasks.Option
+	}{
+		{"invalid configuration", []tasks.Option{tasks.WithConfig(invalid)}},
+		{"dev mode with a repository", []tasks.Option{
+			tasks.WithRepository(repository.NewMemoryRepository()),
+			tasks.WithDevMode(),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if s, err := tasks.NewServer(tt.opts...); err == nil {
+				s.Close()
+				t.Error("NewServer() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	ready := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- tasks.Run(ctx, tasks.DefaultConfig(),
+			tasks.WithListeners(ln, nil, nil),
+			tasks.WithReady(func() { close(ready) }),
+		)
+	}()
+
+	<-ready
+	resp, err := http.Get("http://" + ln.Addr().String() + "/tasks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /tasks = %d, want 200", resp.StatusCode)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Run() error = %v, want a clean shutdown", err)
+	}
+}
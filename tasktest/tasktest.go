@@ -0,0 +1,203 @@
+// Package tasktest runs the task API in-process for tests. The server is
+// the same router cmd/api serves, backed by an in-memory repository and
+// listening on an ephemeral loopback port, so tests need no server of
+// their own:
+//
+//	srv := tasktest.NewServer(t)
+//	c := srv.Client()
+//	task, err := c.CreateTask(ctx, client.CreateTaskRequest{Title: "t"})
+//
+// Asynchronous imports and exports run on an in-memory job queue.
+// Webhooks, event publishing, rate limiting and the scheduled background
+// jobs are not enabled; every server starts empty unless given fixtures.
+package tasktest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/light-bringer/cert-tasks/client"
+	"github.com/light-bringer/cert-tasks/internal/audit"
+	"github.com/light-bringer/cert-tasks/internal/auth"
+	"github.com/light-bringer/cert-tasks/internal/blob"
+	"github.com/light-bringer/cert-tasks/internal/calendar"
+	"github.com/light-bringer/cert-tasks/internal/config"
+	"github.com/light-bringer/cert-tasks/internal/handlers"
+	"github.com/light-bringer/cert-tasks/internal/jobs"
+	"github.com/light-bringer/cert-tasks/internal/realtime"
+	"github.com/light-bringer/cert-tasks/internal/repository"
+	"github.com/light-bringer/cert-tasks/internal/seed"
+	"github.com/light-bringer/cert-tasks/internal/server"
+)
+
+// Server is a running in-process API server
+type Server struct {
+	// URL is the base URL of the server, such as http://127.0.0.1:40123
+	URL string
+
+	// AdminKey is the random admin key the admin endpoints require
+	AdminKey string
+
+	ts  *httptest.Server
+	hub *realtime.Hub
+
+	// stopJobs stops the job queue, which runs until done is closed
+	stopJobs context.CancelFunc
+	done     chan struct{}
+	jobsDir  string
+}
+
+type options struct {
+	auth     bool
+	docs     bool
+	fixtures *client.Fixtures
+}
+
+// Option configures a Server
+type Option func(*options)
+
+// WithAuth requires API keys, as AUTH_ENABLED does. Server.AdminKey
+// creates them.
+func WithAuth() Option {
+	return func(o *options) {
+		o.auth = true
+	}
+}
+
+// WithDocs serves the Swagger UI at /docs
+func WithDocs() Option {
+	return func(o *options) {
+		o.docs = true
+	}
+}
+
+// WithFixtures starts the server with fx loaded, and serves POST
+// /admin/seed so that Client().Seed(ctx, nil) can restore them between
+// tests
+func WithFixtures(fx *client.Fixtures) Option {
+	return func(o *options) {
+		o.fixtures = fx
+	}
+}
+
+// New starts a server; the caller must Close it. Tests should use
+// NewServer, which closes it when the test ends. New panics if the
+// fixtures are invalid.
+func New(opts ...Option) *Server {
+	s, err := start(opts)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// NewServer starts a server that is closed when t and its subtests end
+func NewServer(t testing.TB, opts ...Option) *Server {
+	t.Helper()
+	s, err := start(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+func start(opts []Option) (*Server, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	s := &Server{hub: realtime.NewHub(realtime.DefaultConfig())}
+	memRepo := repository.NewMemoryRepository()
+	repo := repository.NewNotifyingRepository(memRepo, s.hub.Publish)
+
+	jobsDir, err := os.MkdirTemp("", "tasktest-jobs-")
+	if err != nil {
+		s.hub.Close()
+		return nil, fmt.Errorf("tasktest: %w", err)
+	}
+	queue := jobs.New(jobs.DefaultConfig())
+	exports, err := blob.NewDir(jobsDir)
+	if err != nil {
+		s.hub.Close()
+		return nil, fmt.Errorf("tasktest: %w", err)
+	}
+
+	handlerOpts := []handlers.Option{
+		handlers.WithWorkspaces(memRepo),
+		handlers.WithRevisions(memRepo),
+		handlers.WithCalendar(calendar.NewTokens(randomBytes(32))),
+		handlers.WithJobs(queue, jobsDir, exports),
+		handlers.WithDownloadLinks(blob.NewLinks(exports, randomBytes(32), "/blobs/"), 15*time.Minute),
+	}
+	s.AdminKey = hex.EncodeToString(randomBytes(32))
+	serverOpts := []server.Option{server.WithRealtime(s.hub), server.WithStorage(memRepo), server.WithAdminKey(s.AdminKey)}
+	if o.auth {
+		handlerOpts = append(handlerOpts, handlers.WithAPIKeys(memRepo))
+		serverOpts = append(serverOpts,
+			server.WithAuth(auth.New(memRepo), s.AdminKey),
+			server.WithAudit(audit.New(audit.DefaultMaxEntries)),
+		)
+	}
+	if o.docs {
+		serverOpts = append(serverOpts, server.WithDocs())
+	}
+	if o.fixtures != nil {
+		seeder := seed.New(memRepo)
+		if _, err := seeder.Load(o.fixtures); err != nil {
+			s.hub.Close()
+			os.RemoveAll(jobsDir)
+			return nil, fmt.Errorf("tasktest: loading fixtures: %w", err)
+		}
+		serverOpts = append(serverOpts, server.WithSeed(seeder))
+	}
+
+	srv := server.NewServer(config.Default(false).Server, handlers.NewTaskHandler(repo, handlerOpts...), serverOpts...)
+	s.ts = httptest.NewServer(srv.Handler())
+	s.URL = s.ts.URL
+
+	var ctx context.Context
+	ctx, s.stopJobs = context.WithCancel(context.Background())
+	s.done = make(chan struct{})
+	s.jobsDir = jobsDir
+	go func() {
+		queue.Run(ctx)
+		close(s.done)
+	}()
+	return s, nil
+}
+
+// Close disconnects WebSocket clients, shuts the server down and waits for
+// running jobs
+func (s *Server) Close() {
+	s.hub.Close()
+	s.ts.Close()
+	s.stopJobs()
+	<-s.done
+	os.RemoveAll(s.jobsDir)
+}
+
+// Client returns a client for the server carrying the admin key. With
+// auth enabled, add client.WithAPIKey for the task endpoints.
+func (s *Server) Client(opts ...client.Option) *client.Client {
+	opts = append([]client.Option{client.WithAdminKey(s.AdminKey)}, opts...)
+	c, err := client.New(s.URL, opts...)
+	if err != nil {
+		// The URL always comes from httptest
+		panic(err)
+	}
+	return c
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	rand.Read(b)
+	return b
+}
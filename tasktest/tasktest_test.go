@@ -0,0 +1,79 @@
+package tasktest_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/light-bringer/cert-tasks/client"
+	"github.com/light-bringer/cert-tasks/tasktest"
+)
+
+func TestNewServer(t *testing.T) {
+	ctx := context.Background()
+	srv := tasktest.NewServer(t)
+
+	task, err := srv.Client().CreateTask(ctx, client.CreateTaskRequest{Title: "t"})
+	if err != nil || task.ID != 1 {
+		t.Fatalf("CreateTask = %+v, %v", task, err)
+	}
+	if _, err := srv.Client().ListWorkspaces(ctx); err != nil {
+		t.Errorf("ListWorkspaces without auth = %v, want the admin key to be sent", err)
+	}
+
+	// Every server starts empty
+	page, err := tasktest.NewServer(t).Client().ListTasks(ctx, client.TaskQuery{})
+	if err != nil || len(page.Tasks) != 0 {
+		t.Errorf("second server ListTasks = %+v, %v", page, err)
+	}
+}
+
+func TestNewServer_Auth(t *testing.T) {
+	ctx := context.Background()
+	srv := tasktest.NewServer(t, tasktest.WithAuth(), tasktest.WithDocs())
+
+	admin := srv.Client()
+	if _, err := admin.ListTasks(ctx, client.TaskQuery{}); !errors.Is(err, client.ErrUnauthorized) {
+		t.Fatalf("ListTasks without API key error = %v, want unauthorized", err)
+	}
+	key, err := admin.CreateAPIKey(ctx, client.CreateAPIKeyRequest{Name: "test", Scope: client.ScopeReadWrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.Client(client.WithAPIKey(key.Key)).ListTasks(ctx, client.TaskQuery{}); err != nil {
+		t.Errorf("ListTasks with API key: %v", err)
+	}
+
+	resp, err := http.Get(srv.URL + "/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /docs status = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestNewServer_Fixtures(t *testing.T) {
+	ctx := context.Background()
+	const key = "ctk_fixture_0123456789abcdefghijkl"
+	srv := tasktest.NewServer(t, tasktest.WithAuth(), tasktest.WithFixtures(&client.Fixtures{
+		APIKeys: []client.SeedAPIKey{{Name: "tests", Scope: client.ScopeReadWrite, Key: key}},
+		Tasks:   []client.SeedTask{{ID: 7, Title: "Fixture"}},
+	}))
+	c := srv.Client(client.WithAPIKey(key))
+
+	if task, err := c.GetTask(ctx, 7); err != nil || task.Title != "Fixture" {
+		t.Fatalf("GetTask(7) = %+v, %v", task, err)
+	}
+	if err := c.DeleteTask(ctx, 7); err != nil {
+		t.Fatal(err)
+	}
+	if result, err := c.Seed(ctx, nil); err != nil || result.Tasks != 1 {
+		t.Fatalf("Seed(nil) = %+v, %v", result, err)
+	}
+	if _, err := c.GetTask(ctx, 7); err != nil {
+		t.Errorf("GetTask(7) after reseeding: %v", err)
+	}
+}
@@ -2,20 +2,38 @@ package integration_test
 
 import (
 	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/light-bringer/cert-tasks/tasktest"
 )
 
-const (
-	baseURL = "http://localhost:8080"
+// The suite runs against TASKS_API_URL when it is set, authenticating
+// with TASKS_API_KEY and TASKS_WORKSPACE if given, or else against an
+// in-process server started by TestMain
+var (
+	baseURL   = os.Getenv("TASKS_API_URL")
+	apiKey    = os.Getenv("TASKS_API_KEY")
+	workspace = os.Getenv("TASKS_WORKSPACE")
 )
 
+// namespace prefixes the title of every task a run creates, so concurrent
+// runs against one deployment can tell their data apart
+var namespace = newNamespace()
+
+// missingPath names a task that no deployment will have
+var missingPath = fmt.Sprintf("/tasks/%d", int64(math.MaxInt64))
+
 // TestResult tracks individual test results
 type TestResult struct {
 	Category     string
@@ -52,9 +70,16 @@ type UpdateTaskRequest struct {
 	Status      string `json:"status"`
 }
 
-var results []TestResult
+var (
+	resultsMu sync.Mutex
+	results   []TestResult
+)
 
 func TestMain(m *testing.M) {
+	if baseURL == "" {
+		os.Exit(runEmbedded(m))
+	}
+
 	// Check if server is reachable before running tests
 	if err := checkServerHealth(); err != nil {
 		fmt.Println("\n❌ ERROR: Cannot connect to API server")
@@ -70,6 +95,7 @@ func TestMain(m *testing.M) {
 	fmt.Println("🚀 TASK MANAGEMENT API - INTEGRATION TEST SUITE")
 	fmt.Println(strings.Repeat("=", 120))
 	fmt.Printf("Base URL: %s\n", baseURL)
+	fmt.Printf("Namespace: %s\n", namespace)
 	fmt.Println(strings.Repeat("=", 120))
 	fmt.Println("✅ Server is reachable")
 	fmt.Println()
@@ -83,12 +109,61 @@ func TestMain(m *testing.M) {
 	os.Exit(code)
 }
 
+// runEmbedded runs the tests against an in-process server
+func runEmbedded(m *testing.M) int {
+	srv := tasktest.New()
+	defer srv.Close()
+	baseURL = srv.URL
+
+	code := m.Run()
+	printSummary()
+	return code
+}
+
 func checkServerHealth() error {
 	client := &http.Client{Timeout: 2 * time.Second}
 	_, err := client.Get(baseURL + "/tasks")
 	return err
 }
 
+func newNamespace() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return "it-" + hex.EncodeToString(b)
+}
+
+// title returns s prefixed with the run's namespace
+func title(s string) string {
+	return "[" + namespace + "] " + s
+}
+
+// record adds a result to the summary; tests may run in parallel
+func record(result TestResult) {
+	resultsMu.Lock()
+	defer resultsMu.Unlock()
+	results = append(results, result)
+}
+
+// get sends a GET request with the run's credentials
+func get(url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return do(req)
+}
+
+// do sends req with the run's credentials
+func do(req *http.Request) (*http.Response, error) {
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	if workspace != "" {
+		req.Header.Set("X-Workspace-ID", workspace)
+	}
+	return http.DefaultClient.Do(req)
+}
+
 func runTest(t *testing.T, category, testName, method, endpoint string, expectedCode int, testFunc func() (*http.Response, error)) bool {
 	t.Helper()
 	start := time.Now()
@@ -108,7 +183,7 @@ func runTest(t *testing.T, category, testName, method, endpoint string, expected
 	if err != nil {
 		result.Error = err.Error()
 		result.Passed = false
-		results = append(results, result)
+		record(result)
 		t.Errorf("%s - %s: %v", category, testName, err)
 		return false
 	}
@@ -122,16 +197,25 @@ func runTest(t *testing.T, category, testName, method, endpoint string, expected
 		t.Errorf("%s - %s: expected status %d, got %d", category, testName, expectedCode, resp.StatusCode)
 	}
 
-	results = append(results, result)
+	record(result)
 	return result.Passed
 }
 
 func TestCreateTasks(t *testing.T) {
 	// Test 1: Valid task with description
 	var task1ID int64
+	t.Cleanup(func() {
+		// Leave a shared deployment as it was; task 2 is deleted by the tests
+		if task1ID > 0 {
+			req, _ := http.NewRequest("DELETE", fmt.Sprintf("%s/tasks/%d", baseURL, task1ID), nil)
+			if resp, err := do(req); err == nil {
+				resp.Body.Close()
+			}
+		}
+	})
 	runTest(t, "CREATE", "Valid task with description", "POST", "/tasks", 201, func() (*http.Response, error) {
 		payload := CreateTaskRequest{
-			Title:       "Complete project documentation",
+			Title:       title("Complete project documentation"),
 			Description: "Write comprehensive API documentation",
 		}
 		resp, err := makeRequest("POST", "/tasks", payload)
@@ -147,7 +231,7 @@ func TestCreateTasks(t *testing.T) {
 	var task2ID int64
 	runTest(t, "CREATE", "Valid task without description", "POST", "/tasks", 201, func() (*http.Response, error) {
 		payload := CreateTaskRequest{
-			Title: "Review pull requests",
+			Title: title("Review pull requests"),
 		}
 		resp, err := makeRequest("POST", "/tasks", payload)
 		if err == nil && resp.StatusCode == 201 {
@@ -159,13 +243,13 @@ func TestCreateTasks(t *testing.T) {
 	})
 
 	// Test 3: Missing title
-	runTest(t, "CREATE", "Missing title (validation)", "POST", "/tasks", 400, func() (*http.Response, error) {
+	runTest(t, "CREATE", "Missing title (validation)", "POST", "/tasks", 422, func() (*http.Response, error) {
 		payload := map[string]string{"description": "No title"}
 		return makeRequest("POST", "/tasks", payload)
 	})
 
 	// Test 4: Empty title
-	runTest(t, "CREATE", "Empty title (validation)", "POST", "/tasks", 400, func() (*http.Response, error) {
+	runTest(t, "CREATE", "Empty title (validation)", "POST", "/tasks", 422, func() (*http.Response, error) {
 		payload := CreateTaskRequest{
 			Title:       "   ",
 			Description: "Empty",
@@ -177,7 +261,7 @@ func TestCreateTasks(t *testing.T) {
 	runTest(t, "CREATE", "Malformed JSON", "POST", "/tasks", 400, func() (*http.Response, error) {
 		req, _ := http.NewRequest("POST", baseURL+"/tasks", bytes.NewBufferString("invalid json"))
 		req.Header.Set("Content-Type", "application/json")
-		return http.DefaultClient.Do(req)
+		return do(req)
 	})
 
 	// Store IDs for subsequent tests
@@ -195,24 +279,24 @@ func TestCreateTasks(t *testing.T) {
 
 func testListTasks(t *testing.T) {
 	runTest(t, "LIST", "Get all tasks", "GET", "/tasks", 200, func() (*http.Response, error) {
-		return http.Get(baseURL + "/tasks")
+		return get(baseURL + "/tasks")
 	})
 }
 
 func testGetTask(t *testing.T, taskID int64) {
 	// Test 1: Get existing task
 	runTest(t, "GET", "Get existing task", "GET", fmt.Sprintf("/tasks/%d", taskID), 200, func() (*http.Response, error) {
-		return http.Get(fmt.Sprintf("%s/tasks/%d", baseURL, taskID))
+		return get(fmt.Sprintf("%s/tasks/%d", baseURL, taskID))
 	})
 
 	// Test 2: Get non-existent task
-	runTest(t, "GET", "Get non-existent task", "GET", "/tasks/9999", 404, func() (*http.Response, error) {
-		return http.Get(baseURL + "/tasks/9999")
+	runTest(t, "GET", "Get non-existent task", "GET", missingPath, 404, func() (*http.Response, error) {
+		return get(baseURL + missingPath)
 	})
 
 	// Test 3: Invalid ID
 	runTest(t, "GET", "Invalid task ID", "GET", "/tasks/abc", 400, func() (*http.Response, error) {
-		return http.Get(baseURL + "/tasks/abc")
+		return get(baseURL + "/tasks/abc")
 	})
 }
 
@@ -220,7 +304,7 @@ func testUpdateTask(t *testing.T, taskID int64) {
 	// Test 1: Valid update to done
 	runTest(t, "UPDATE", "Update task to done", "PUT", fmt.Sprintf("/tasks/%d", taskID), 200, func() (*http.Response, error) {
 		payload := UpdateTaskRequest{
-			Title:       "Updated task",
+			Title:       title("Updated task"),
 			Description: "Updated desc",
 			Status:      "done",
 		}
@@ -230,7 +314,7 @@ func testUpdateTask(t *testing.T, taskID int64) {
 	// Test 2: Update back to todo
 	runTest(t, "UPDATE", "Update task to todo", "PUT", fmt.Sprintf("/tasks/%d", taskID), 200, func() (*http.Response, error) {
 		payload := UpdateTaskRequest{
-			Title:       "Updated task",
+			Title:       title("Updated task"),
 			Description: "Back to todo",
 			Status:      "todo",
 		}
@@ -238,7 +322,7 @@ func testUpdateTask(t *testing.T, taskID int64) {
 	})
 
 	// Test 3: Invalid status
-	runTest(t, "UPDATE", "Invalid status (validation)", "PUT", fmt.Sprintf("/tasks/%d", taskID), 400, func() (*http.Response, error) {
+	runTest(t, "UPDATE", "Invalid status (validation)", "PUT", fmt.Sprintf("/tasks/%d", taskID), 422, func() (*http.Response, error) {
 		payload := map[string]string{
 			"title":  "Test",
 			"status": "in-progress",
@@ -247,7 +331,7 @@ func testUpdateTask(t *testing.T, taskID int64) {
 	})
 
 	// Test 4: Missing title
-	runTest(t, "UPDATE", "Missing title (validation)", "PUT", fmt.Sprintf("/tasks/%d", taskID), 400, func() (*http.Response, error) {
+	runTest(t, "UPDATE", "Missing title (validation)", "PUT", fmt.Sprintf("/tasks/%d", taskID), 422, func() (*http.Response, error) {
 		payload := map[string]string{
 			"description": "No title",
 			"status":      "done",
@@ -256,12 +340,12 @@ func testUpdateTask(t *testing.T, taskID int64) {
 	})
 
 	// Test 5: Update non-existent task
-	runTest(t, "UPDATE", "Update non-existent task", "PUT", "/tasks/9999", 404, func() (*http.Response, error) {
+	runTest(t, "UPDATE", "Update non-existent task", "PUT", missingPath, 404, func() (*http.Response, error) {
 		payload := UpdateTaskRequest{
 			Title:  "Test",
 			Status: "done",
 		}
-		return makeRequest("PUT", "/tasks/9999", payload)
+		return makeRequest("PUT", missingPath, payload)
 	})
 }
 
@@ -269,24 +353,24 @@ func testDeleteTask(t *testing.T, taskID int64) {
 	// Test 1: Delete existing task
 	runTest(t, "DELETE", "Delete existing task", "DELETE", fmt.Sprintf("/tasks/%d", taskID), 204, func() (*http.Response, error) {
 		req, _ := http.NewRequest("DELETE", fmt.Sprintf("%s/tasks/%d", baseURL, taskID), nil)
-		return http.DefaultClient.Do(req)
+		return do(req)
 	})
 
 	// Test 2: Verify task is deleted
 	runTest(t, "DELETE", "Verify task deleted", "GET", fmt.Sprintf("/tasks/%d", taskID), 404, func() (*http.Response, error) {
-		return http.Get(fmt.Sprintf("%s/tasks/%d", baseURL, taskID))
+		return get(fmt.Sprintf("%s/tasks/%d", baseURL, taskID))
 	})
 
 	// Test 3: Delete non-existent task
-	runTest(t, "DELETE", "Delete non-existent task", "DELETE", "/tasks/9999", 404, func() (*http.Response, error) {
-		req, _ := http.NewRequest("DELETE", baseURL+"/tasks/9999", nil)
-		return http.DefaultClient.Do(req)
+	runTest(t, "DELETE", "Delete non-existent task", "DELETE", missingPath, 404, func() (*http.Response, error) {
+		req, _ := http.NewRequest("DELETE", baseURL+missingPath, nil)
+		return do(req)
 	})
 
 	// Test 4: Invalid ID
 	runTest(t, "DELETE", "Invalid task ID", "DELETE", "/tasks/abc", 400, func() (*http.Response, error) {
 		req, _ := http.NewRequest("DELETE", baseURL+"/tasks/abc", nil)
-		return http.DefaultClient.Do(req)
+		return do(req)
 	})
 }
 
@@ -309,7 +393,7 @@ func makeRequest(method, endpoint string, payload interface{}) (*http.Response,
 		req.Header.Set("Content-Type", "application/json")
 	}
 
-	return http.DefaultClient.Do(req)
+	return do(req)
 }
 
 func printSummary() {
@@ -246,13 +246,13 @@ def test_create_tasks(runner: TestRunner) -> Tuple[int, int]:
 
     # Test 3: Missing title
     runner.run_test(
-        "CREATE", "Missing title (validation)", "POST", "/tasks", 400,
+        "CREATE", "Missing title (validation)", "POST", "/tasks", 422,
         lambda: requests.post(f"{BASE_URL}/tasks", json={"description": "No title"})
     )
 
     # Test 4: Empty title
     runner.run_test(
-        "CREATE", "Empty title (validation)", "POST", "/tasks", 400,
+        "CREATE", "Empty title (validation)", "POST", "/tasks", 422,
         lambda: requests.post(f"{BASE_URL}/tasks", json={"title": "   ", "description": "Empty"})
     )
 
@@ -319,7 +319,7 @@ def test_update_task(runner: TestRunner, task_id: int):
 
     # Test 3: Invalid status
     runner.run_test(
-        "UPDATE", "Invalid status (validation)", "PUT", f"/tasks/{task_id}", 400,
+        "UPDATE", "Invalid status (validation)", "PUT", f"/tasks/{task_id}", 422,
         lambda: requests.put(f"{BASE_URL}/tasks/{task_id}", json={
             "title": "Test",
             "status": "in-progress"
@@ -328,7 +328,7 @@ def test_update_task(runner: TestRunner, task_id: int):
 
     # Test 4: Missing title
     runner.run_test(
-        "UPDATE", "Missing title (validation)", "PUT", f"/tasks/{task_id}", 400,
+        "UPDATE", "Missing title (validation)", "PUT", f"/tasks/{task_id}", 422,
         lambda: requests.put(f"{BASE_URL}/tasks/{task_id}", json={
             "description": "No title",
             "status": "done"